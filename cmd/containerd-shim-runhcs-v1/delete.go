@@ -20,6 +20,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/memory"
 	"github.com/Microsoft/hcsshim/internal/oc"
 	"github.com/Microsoft/hcsshim/internal/winapi"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
 // LimitedRead reads at max `readLimitBytes` bytes from the file at path `filePath`. If the file has
@@ -71,11 +72,18 @@ The delete command will be executed in the container's bundle as its cwd.
 		// This should be done as the first thing so that we don't miss any panic logs even if
 		// something goes wrong during delete op.
 		// The file can be very large so read only first 1MB of data.
+		// The shim of a UVM sharing group writes them in the directory of the group.
 		readLimit := int64(memory.MiB) // 1MB
-		logBytes, err := limitedRead(filepath.Join(bundleFlag, "panic.log"), readLimit)
+		panicLog := filepath.Join(bundleFlag, "panic.log")
+		if a, err := getSpecAnnotations(bundleFlag); err == nil && a[annotations.UVMSharingGroup] != "" {
+			if dir, err := groupDir(namespaceFlag, a[annotations.UVMSharingGroup]); err == nil {
+				panicLog = filepath.Join(dir, "panic.log")
+			}
+		}
+		logBytes, err := limitedRead(panicLog, readLimit)
 		if err == nil && len(logBytes) > 0 {
 			if int64(len(logBytes)) == readLimit {
-				logrus.Warnf("shim panic log file %s is larger than 1MB, logging only first 1MB", filepath.Join(bundleFlag, "panic.log"))
+				logrus.Warnf("shim panic log file %s is larger than 1MB, logging only first 1MB", panicLog)
 			}
 			logrus.WithField("log", string(logBytes)).Warn("found shim panic logs during delete")
 		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -0,0 +1,150 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/containerd/api/types"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// eventJournalDir is the directory, relative to the bundle, of the event journal.
+	eventJournalDir = "events"
+	// eventJournalExt is the file extension of journaled events.
+	eventJournalExt = ".event"
+)
+
+// journaledEvent is an event envelope and the sequence number it was
+// published with.
+type journaledEvent struct {
+	seq      uint64
+	envelope *types.Envelope
+}
+
+// eventJournal persists events that have not yet been forwarded to containerd,
+// so they can be replayed if the shim restarts before they are acknowledged.
+//
+// Each event is stored in its own file, named after its sequence number, which
+// is removed once containerd has acknowledged the event.
+type eventJournal struct {
+	dir string
+}
+
+func newEventJournal(dir string) (*eventJournal, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create event journal directory: %w", err)
+	}
+	return &eventJournal{dir: dir}, nil
+}
+
+func (j *eventJournal) path(seq uint64) string {
+	return filepath.Join(j.dir, fmt.Sprintf("%020d%s", seq, eventJournalExt))
+}
+
+// write persists `ev`. The event is written to a temporary file first so that a
+// crash cannot leave a partially written event in the journal.
+func (j *eventJournal) write(ev *journaledEvent) error {
+	if j == nil {
+		return nil
+	}
+	b, err := proto.Marshal(ev.envelope)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(j.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) //nolint:errcheck
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path(ev.seq))
+}
+
+// remove deletes the event with sequence number `seq` from the journal.
+func (j *eventJournal) remove(seq uint64) error {
+	if j == nil {
+		return nil
+	}
+	if err := os.Remove(j.path(seq)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// load returns the events in the journal, ordered by sequence number.
+//
+// Events that cannot be read are removed.
+func (j *eventJournal) load() ([]*journaledEvent, error) {
+	if j == nil {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var evs []*journaledEvent
+	for _, e := range entries {
+		name := e.Name()
+		p := filepath.Join(j.dir, name)
+		if e.IsDir() {
+			continue
+		}
+		if filepath.Ext(name) != eventJournalExt {
+			// left over from an interrupted write
+			_ = os.Remove(p)
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, eventJournalExt), 10, 64)
+		if err != nil {
+			logrus.WithError(err).WithField("path", p).Warn("removing invalid event journal entry")
+			_ = os.Remove(p)
+			continue
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		env := &types.Envelope{}
+		if err := proto.Unmarshal(b, env); err != nil {
+			logrus.WithError(err).WithField("path", p).Warn("removing invalid event journal entry")
+			_ = os.Remove(p)
+			continue
+		}
+		evs = append(evs, &journaledEvent{seq: seq, envelope: env})
+	}
+
+	slices.SortFunc(evs, func(a, b *journaledEvent) int {
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	})
+	return evs, nil
+}
@@ -4,38 +4,153 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
-	"github.com/containerd/containerd/v2/pkg/namespaces"
-	"github.com/containerd/containerd/v2/pkg/shim"
+	eventsapi "github.com/containerd/containerd/api/services/ttrpc/events/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/containerd/v2/pkg/protobuf"
+	"github.com/containerd/containerd/v2/pkg/ttrpcutil"
+	"github.com/containerd/ttrpc"
+	"github.com/containerd/typeurl/v2"
+	"github.com/sirupsen/logrus"
 	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// eventSequenceKey is the ttrpc metadata key that holds the sequence number
+	// of a forwarded event.
+	//
+	// Events are retried until containerd acknowledges them, so consumers may see
+	// the same event more than once. Sequence numbers increase monotonically for
+	// a shim (including across restarts of the shim), and a retried event keeps
+	// both its sequence number and its envelope timestamp.
+	eventSequenceKey = "io.microsoft.shim.event.sequence"
+
+	eventForwardTimeout = 5 * time.Second
+	// eventFlushTimeout bounds how long the shim waits for pending events to be
+	// forwarded before it exits.
+	eventFlushTimeout    = 2 * eventForwardTimeout
+	eventRetryMinBackoff = time.Second
+	eventRetryMaxBackoff = 30 * time.Second
+	// maxEventForwardAttempts is the number of times an event is forwarded
+	// before it is dropped, which is about 10 minutes with the backoff above.
+	maxEventForwardAttempts = 24
+	// maxPendingEvents is the maximum number of events waiting to be retried.
+	// When it is exceeded the oldest event is dropped.
+	maxPendingEvents = 1024
 )
 
 type publisher interface {
 	publishEvent(ctx context.Context, topic string, event interface{}) (err error)
 }
 
+// forwardFunc forwards an event to containerd.
+type forwardFunc func(ctx context.Context, req *eventsapi.ForwardRequest) error
+
+// eventPublisher forwards events to containerd.
+//
+// Published events are queued and forwarded in order by a single goroutine, so
+// a slow or unavailable containerd never blocks the caller. Events that cannot
+// be forwarded (eg, because containerd is restarting) are retried in order,
+// with backoff, until they are acknowledged or [maxEventForwardAttempts] is
+// reached. Events that containerd rejects are dropped. Events are also
+// written to an on-disk journal until they are acknowledged, and any events left
+// in the journal are replayed when the publisher is created.
 type eventPublisher struct {
-	namespace       string
-	remotePublisher *shim.RemoteEventsPublisher
+	namespace string
+	client    *ttrpcutil.Client
+	forward   forwardFunc
+	journal   *eventJournal
+
+	// mu protects seq and pending. It is never held while forwarding an event.
+	mu      sync.Mutex
+	seq     uint64
+	pending []*journaledEvent
+
+	retry     chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
 }
 
 var _ publisher = &eventPublisher{}
 
-func newEventPublisher(address, namespace string) (*eventPublisher, error) {
-	p, err := shim.NewPublisher(address)
+// newEventPublisher creates a publisher that forwards events to the containerd
+// ttrpc server at `address`, journaling unacknowledged events in `journalDir`.
+//
+// If `journalDir` is empty, events are only retried while the shim is running.
+func newEventPublisher(address, namespace, journalDir string) (*eventPublisher, error) {
+	client, err := ttrpcutil.NewClient(address)
 	if err != nil {
 		return nil, err
 	}
-	return &eventPublisher{
-		namespace:       namespace,
-		remotePublisher: p,
-	}, nil
+
+	var j *eventJournal
+	if journalDir != "" {
+		if j, err = newEventJournal(journalDir); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	e, err := startEventPublisher(namespace, j, func(ctx context.Context, req *eventsapi.ForwardRequest) error {
+		return forwardEvent(ctx, client, req)
+	})
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	e.client = client
+	return e, nil
 }
 
+// startEventPublisher creates an eventPublisher that forwards events with `f`,
+// and starts retrying any events left in the journal.
+func startEventPublisher(namespace string, j *eventJournal, f forwardFunc) (*eventPublisher, error) {
+	evs, err := j.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load event journal: %w", err)
+	}
+
+	e := &eventPublisher{
+		namespace: namespace,
+		forward:   f,
+		journal:   j,
+		seq:       uint64(time.Now().UnixNano()),
+		pending:   evs,
+		retry:     make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if n := len(evs); n > 0 {
+		logrus.WithField("count", n).Info("replaying journaled events")
+		e.seq = max(e.seq, evs[n-1].seq)
+		e.signalRetry()
+	}
+
+	go e.retryLoop()
+	return e, nil
+}
+
+// close stops retrying events and closes the connection to containerd.
+// Unacknowledged events are left in the journal.
 func (e *eventPublisher) close() error {
-	return e.remotePublisher.Close()
+	e.closeOnce.Do(func() {
+		close(e.closed)
+	})
+	<-e.done
+	if e.client == nil {
+		return nil
+	}
+	return e.client.Close()
 }
 
 func (e *eventPublisher) publishEvent(ctx context.Context, topic string, event interface{}) (err error) {
@@ -50,5 +165,198 @@ func (e *eventPublisher) publishEvent(ctx context.Context, topic string, event i
 		return nil
 	}
 
-	return e.remotePublisher.Publish(namespaces.WithNamespace(ctx, e.namespace), topic, event)
+	a, err := typeurl.MarshalAnyToProto(event)
+	if err != nil {
+		return err
+	}
+	ev := &journaledEvent{
+		envelope: &types.Envelope{
+			Timestamp: protobuf.ToTimestamp(time.Now()),
+			Namespace: e.namespace,
+			Topic:     topic,
+			Event:     a,
+		},
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	select {
+	case <-e.closed:
+		return errors.New("event publisher is closed")
+	default:
+	}
+
+	e.seq++
+	ev.seq = e.seq
+	span.AddAttributes(trace.Int64Attribute("sequence", int64(ev.seq)))
+	if err := e.journal.write(ev); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to journal event")
+	}
+
+	// queue the event behind any older ones; retryLoop forwards it
+	if len(e.pending) >= maxPendingEvents {
+		dropped := e.pending[0]
+		e.pending = e.pending[1:]
+		e.remove(ctx, dropped)
+		log.G(ctx).WithFields(logrus.Fields{
+			"topic":    dropped.envelope.Topic,
+			"sequence": dropped.seq,
+		}).Error("dropping event: too many pending events")
+	}
+	e.pending = append(e.pending, ev)
+	e.signalRetry()
+	return nil
+}
+
+// flush waits until all pending events have been forwarded, or `ctx` is done.
+func (e *eventPublisher) flush(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	t := time.NewTicker(10 * time.Millisecond)
+	defer t.Stop()
+	for {
+		e.mu.Lock()
+		n := len(e.pending)
+		e.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d events not forwarded: %w", n, ctx.Err())
+		case <-e.closed:
+			return fmt.Errorf("%d events not forwarded: event publisher is closed", n)
+		case <-t.C:
+		}
+	}
+}
+
+// send forwards `ev`, passing along its sequence number.
+func (e *eventPublisher) send(ctx context.Context, ev *journaledEvent) error {
+	ctx = ttrpc.WithMetadata(ctx, ttrpc.MD{
+		eventSequenceKey: []string{strconv.FormatUint(ev.seq, 10)},
+	})
+	return e.forward(ctx, &eventsapi.ForwardRequest{Envelope: ev.envelope})
+}
+
+// finish stops forwarding `ev`, which was either forwarded or dropped.
+func (e *eventPublisher) finish(ctx context.Context, ev *journaledEvent) {
+	e.mu.Lock()
+	// the event may have been dropped while it was being forwarded
+	if len(e.pending) > 0 && e.pending[0] == ev {
+		e.pending = e.pending[1:]
+	}
+	e.mu.Unlock()
+	e.remove(ctx, ev)
+}
+
+// retryableForwardError returns if forwarding an event may succeed later after
+// it failed with `err`. Errors without a status, such as [ttrpc.ErrClosed] or a
+// timeout, come from the connection to containerd, which may be restarting.
+// Any error from containerd other than Unavailable or DeadlineExceeded means
+// that it rejected the event, which retrying does not change.
+func retryableForwardError(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// remove deletes `ev` from the journal.
+func (e *eventPublisher) remove(ctx context.Context, ev *journaledEvent) {
+	if err := e.journal.remove(ev.seq); err != nil {
+		log.G(ctx).WithError(err).WithField("sequence", ev.seq).Warn("failed to remove event from journal")
+	}
+}
+
+func (e *eventPublisher) signalRetry() {
+	select {
+	case e.retry <- struct{}{}:
+	default:
+	}
+}
+
+// retryLoop forwards pending events, oldest first, until the publisher is closed.
+// It is the only caller of send, so events are forwarded one at a time and in order.
+func (e *eventPublisher) retryLoop() {
+	defer close(e.done)
+
+	ctx := context.Background()
+	for {
+		select {
+		case <-e.closed:
+			return
+		case <-e.retry:
+		}
+
+		backoff := eventRetryMinBackoff
+		attempts := 0
+		for {
+			e.mu.Lock()
+			if len(e.pending) == 0 {
+				e.mu.Unlock()
+				break
+			}
+			ev := e.pending[0]
+			e.mu.Unlock()
+
+			if err := e.send(ctx, ev); err != nil {
+				attempts++
+				entry := log.G(ctx).WithError(err).WithFields(logrus.Fields{
+					"topic":    ev.envelope.Topic,
+					"sequence": ev.seq,
+					"attempts": attempts,
+				})
+				if !retryableForwardError(err) || attempts >= maxEventForwardAttempts {
+					entry.Error("dropping event: failed to forward event")
+					e.finish(ctx, ev)
+					backoff, attempts = eventRetryMinBackoff, 0
+					continue
+				}
+				entry.WithField("backoff", backoff).Warn("failed to forward event, will retry")
+
+				select {
+				case <-e.closed:
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(2*backoff, eventRetryMaxBackoff)
+				continue
+			}
+			backoff, attempts = eventRetryMinBackoff, 0
+			e.finish(ctx, ev)
+		}
+	}
+}
+
+// forwardEvent forwards an event to containerd, reconnecting if the connection
+// was closed (eg, because containerd restarted).
+func forwardEvent(ctx context.Context, client *ttrpcutil.Client, req *eventsapi.ForwardRequest) error {
+	forward := func() error {
+		service, err := client.EventsService()
+		if err != nil {
+			return err
+		}
+		fCtx, cancel := context.WithTimeout(ctx, eventForwardTimeout)
+		defer cancel()
+		_, err = service.Forward(fCtx, req)
+		return err
+	}
+
+	err := forward()
+	if !errors.Is(err, ttrpc.ErrClosed) {
+		return err
+	}
+	if err := client.Reconnect(); err != nil {
+		return fmt.Errorf("failed to reconnect to containerd: %w", err)
+	}
+	return forward()
 }
@@ -2,7 +2,23 @@
 
 package main
 
-import "context"
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	eventsapi "github.com/containerd/containerd/api/services/ttrpc/events/v1"
+	"github.com/containerd/ttrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
 type fakePublisher struct {
 	events []interface{}
@@ -21,3 +37,288 @@ func (p *fakePublisher) publishEvent(ctx context.Context, topic string, event in
 	p.events = append(p.events, event)
 	return nil
 }
+
+type fakeForwarder struct {
+	mu   sync.Mutex
+	fail int
+	seqs []uint64
+	reqs []*eventsapi.ForwardRequest
+	sent chan struct{}
+}
+
+func newFakeForwarder(fail int) *fakeForwarder {
+	return &fakeForwarder{fail: fail, sent: make(chan struct{}, 16)}
+}
+
+func (f *fakeForwarder) forward(ctx context.Context, req *eventsapi.ForwardRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail > 0 {
+		f.fail--
+		return ttrpc.ErrClosed
+	}
+	v, _ := ttrpc.GetMetadataValue(ctx, eventSequenceKey)
+	seq, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	f.seqs = append(f.seqs, seq)
+	f.reqs = append(f.reqs, req)
+	f.sent <- struct{}{}
+	return nil
+}
+
+func (f *fakeForwarder) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-f.sent:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for event %d to be forwarded", i)
+		}
+	}
+}
+
+func (f *fakeForwarder) topics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ts []string
+	for _, r := range f.reqs {
+		ts = append(ts, r.Envelope.Topic)
+	}
+	return ts
+}
+
+func waitJournalEmpty(t *testing.T, j *eventJournal) {
+	t.Helper()
+	var (
+		evs []*journaledEvent
+		err error
+	)
+	for i := 0; i < 100; i++ {
+		if evs, err = j.load(); err != nil {
+			t.Fatalf("failed to load journal: %v", err)
+		}
+		if len(evs) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected empty journal, got %d events", len(evs))
+}
+
+func Test_EventPublisher_Forward(t *testing.T) {
+	j, err := newEventJournal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	f := newFakeForwarder(0)
+	e, err := startEventPublisher("test", j, f.forward)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	defer e.close()
+
+	for _, topic := range []string{"/tasks/create", "/tasks/start"} {
+		if err := e.publishEvent(context.Background(), topic, &eventstypes.TaskStart{ContainerID: t.Name()}); err != nil {
+			t.Fatalf("failed to publish event: %v", err)
+		}
+	}
+	f.wait(t, 2)
+
+	if got := f.topics(); !slices.Equal(got, []string{"/tasks/create", "/tasks/start"}) {
+		t.Fatalf("unexpected topics forwarded: %v", got)
+	}
+	if f.reqs[0].Envelope.Namespace != "test" {
+		t.Fatalf("expected namespace %q, got %q", "test", f.reqs[0].Envelope.Namespace)
+	}
+	if len(f.seqs) != 2 || f.seqs[0] >= f.seqs[1] {
+		t.Fatalf("expected increasing sequence numbers, got %v", f.seqs)
+	}
+	waitJournalEmpty(t, j)
+}
+
+func Test_EventPublisher_Retry(t *testing.T) {
+	j, err := newEventJournal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	f := newFakeForwarder(1)
+	e, err := startEventPublisher("test", j, f.forward)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	defer e.close()
+
+	// the first event fails to forward, so the second must be queued behind it
+	for _, topic := range []string{"/tasks/exit", "/tasks/delete"} {
+		if err := e.publishEvent(context.Background(), topic, &eventstypes.TaskExit{ContainerID: t.Name()}); err != nil {
+			t.Fatalf("failed to publish event: %v", err)
+		}
+	}
+	f.wait(t, 2)
+
+	if got := f.topics(); !slices.Equal(got, []string{"/tasks/exit", "/tasks/delete"}) {
+		t.Fatalf("unexpected topics forwarded: %v", got)
+	}
+	waitJournalEmpty(t, j)
+}
+
+func Test_EventPublisher_DropRejected(t *testing.T) {
+	j, err := newEventJournal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	f := newFakeForwarder(0)
+	e, err := startEventPublisher("test", j, func(ctx context.Context, req *eventsapi.ForwardRequest) error {
+		if req.Envelope.Topic == "/tasks/exit" {
+			return status.Error(codes.InvalidArgument, "rejected")
+		}
+		return f.forward(ctx, req)
+	})
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	defer e.close()
+
+	// the first event is rejected, so it is dropped rather than holding up the second
+	for _, topic := range []string{"/tasks/exit", "/tasks/delete"} {
+		if err := e.publishEvent(context.Background(), topic, &eventstypes.TaskExit{ContainerID: t.Name()}); err != nil {
+			t.Fatalf("failed to publish event: %v", err)
+		}
+	}
+	f.wait(t, 1)
+
+	if got := f.topics(); !slices.Equal(got, []string{"/tasks/delete"}) {
+		t.Fatalf("unexpected topics forwarded: %v", got)
+	}
+	waitJournalEmpty(t, j)
+}
+
+func Test_RetryableForwardError(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		retryable bool
+	}{
+		{err: ttrpc.ErrClosed, retryable: true},
+		{err: context.DeadlineExceeded, retryable: true},
+		{err: status.Error(codes.Unavailable, "unavailable"), retryable: true},
+		{err: status.Error(codes.DeadlineExceeded, "timeout"), retryable: true},
+		{err: status.Error(codes.InvalidArgument, "invalid"), retryable: false},
+		{err: status.Error(codes.NotFound, "not found"), retryable: false},
+	} {
+		if got := retryableForwardError(tc.err); got != tc.retryable {
+			t.Errorf("expected retryable %t for %v, got %t", tc.retryable, tc.err, got)
+		}
+	}
+}
+
+func Test_EventPublisher_SlowForward(t *testing.T) {
+	j, err := newEventJournal(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+	f := newFakeForwarder(0)
+	unblock := make(chan struct{})
+	e, err := startEventPublisher("test", j, func(ctx context.Context, req *eventsapi.ForwardRequest) error {
+		<-unblock
+		return f.forward(ctx, req)
+	})
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	defer e.close()
+
+	// publishing must not wait for containerd to acknowledge earlier events
+	published := make(chan error)
+	go func() {
+		for _, topic := range []string{"/tasks/exit", "/tasks/delete"} {
+			if err := e.publishEvent(context.Background(), topic, &eventstypes.TaskExit{ContainerID: t.Name()}); err != nil {
+				published <- err
+				return
+			}
+		}
+		published <- nil
+	}()
+	select {
+	case err := <-published:
+		if err != nil {
+			t.Fatalf("failed to publish event: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out publishing events while forwarding is blocked")
+	}
+	close(unblock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.flush(ctx); err != nil {
+		t.Fatalf("failed to flush events: %v", err)
+	}
+	f.wait(t, 2)
+
+	if got := f.topics(); !slices.Equal(got, []string{"/tasks/exit", "/tasks/delete"}) {
+		t.Fatalf("unexpected topics forwarded: %v", got)
+	}
+	waitJournalEmpty(t, j)
+}
+
+func Test_EventPublisher_ReplayJournal(t *testing.T) {
+	dir := t.TempDir()
+	j, err := newEventJournal(dir)
+	if err != nil {
+		t.Fatalf("failed to create journal: %v", err)
+	}
+
+	// simulate a shim that exited before its events were acknowledged
+	f := newFakeForwarder(math.MaxInt)
+	e, err := startEventPublisher("test", j, f.forward)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	for _, topic := range []string{"/tasks/exit", "/tasks/delete"} {
+		if err := e.publishEvent(context.Background(), topic, &eventstypes.TaskExit{ContainerID: t.Name()}); err != nil {
+			t.Fatalf("failed to publish event: %v", err)
+		}
+	}
+	if err := e.close(); err != nil {
+		t.Fatalf("failed to close publisher: %v", err)
+	}
+	evs, err := j.load()
+	if err != nil {
+		t.Fatalf("failed to load journal: %v", err)
+	}
+	if len(evs) != 2 {
+		t.Fatalf("expected 2 journaled events, got %d", len(evs))
+	}
+	// partial writes should be ignored
+	if err := os.WriteFile(filepath.Join(dir, "1.tmp"), []byte("partial"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	f = newFakeForwarder(0)
+	e, err = startEventPublisher("test", j, f.forward)
+	if err != nil {
+		t.Fatalf("failed to create publisher: %v", err)
+	}
+	defer e.close()
+	f.wait(t, 2)
+
+	if got := f.topics(); !slices.Equal(got, []string{"/tasks/exit", "/tasks/delete"}) {
+		t.Fatalf("unexpected topics forwarded: %v", got)
+	}
+	// replayed events keep their sequence numbers
+	for i, ev := range evs {
+		if f.seqs[i] != ev.seq {
+			t.Fatalf("expected sequence %d, got %d", ev.seq, f.seqs[i])
+		}
+	}
+	waitJournalEmpty(t, j)
+
+	if err := e.publishEvent(context.Background(), "/tasks/oom", &eventstypes.TaskOOM{ContainerID: t.Name()}); err != nil {
+		t.Fatalf("failed to publish event: %v", err)
+	}
+	f.wait(t, 1)
+	if f.seqs[2] <= evs[1].seq {
+		t.Fatalf("expected sequence after %d, got %d", evs[1].seq, f.seqs[2])
+	}
+}
@@ -4,7 +4,9 @@ package main
 
 import (
 	"context"
+	"math"
 	"sync"
+	"syscall"
 	"time"
 
 	eventstypes "github.com/containerd/containerd/api/events"
@@ -20,6 +22,7 @@ import (
 
 	"github.com/Microsoft/hcsshim/internal/cmd"
 	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcs"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
@@ -42,6 +45,9 @@ func newHcsExec(
 	id, bundle string,
 	isWCOW bool,
 	spec *specs.Process,
+	shells []string,
+	createCwd bool,
+	extraCaps, dropCaps []string,
 	io cmd.UpstreamIO) shimExec {
 	log.G(ctx).WithFields(logrus.Fields{
 		"tid":    tid,
@@ -59,6 +65,10 @@ func newHcsExec(
 		bundle:      bundle,
 		isWCOW:      isWCOW,
 		spec:        spec,
+		shells:      shells,
+		createCwd:   createCwd,
+		extraCaps:   extraCaps,
+		dropCaps:    dropCaps,
 		io:          io,
 		processDone: make(chan struct{}),
 		state:       shimExecStateCreated,
@@ -71,6 +81,12 @@ func newHcsExec(
 
 var _ = (shimExec)(&hcsExec{})
 
+// signaledProcess is a process that reports the signal that terminated it, if
+// any, such as a process in an LCOW container.
+type signaledProcess interface {
+	ExitStatus() (code int, signal syscall.Signal, dumped bool)
+}
+
 type hcsExec struct {
 	events publisher
 	// tid is the task id of the container hosting this process.
@@ -106,6 +122,22 @@ type hcsExec struct {
 	//
 	// This MUST be treated as read only in the lifetime of the exec.
 	spec *specs.Process
+	// shells are the shells to try if `spec` is a terminal process that
+	// requests one of them and it does not exist in an LCOW container.
+	//
+	// This MUST be treated as read only in the lifetime of the exec.
+	shells []string
+	// createCwd is whether the working directory of `spec`, or of the
+	// container's process for the init exec, is created if it does not exist
+	// in an LCOW container.
+	//
+	// This MUST be treated as read only in the lifetime of the exec.
+	createCwd bool
+	// extraCaps and dropCaps are the capabilities that the guest adds to and
+	// removes from `spec` for an exec in an LCOW container.
+	//
+	// This MUST be treated as read only in the lifetime of the exec.
+	extraCaps, dropCaps []string
 	// io is the upstream io connections used for copying between the upstream
 	// io and the downstream io. The upstream IO MUST already be connected at
 	// create time in order to be valid.
@@ -212,9 +244,19 @@ func (he *hcsExec) startInternal(ctx context.Context, initializeContainer bool)
 		// An init exec passes the process as part of the config. We only pass
 		// the spec if this is a true exec.
 		cmd.Spec = he.spec
+		cmd.ShellCandidates = he.shells
+		cmd.ExtraCapabilities = he.extraCaps
+		cmd.DropCapabilities = he.dropCaps
 	}
+	cmd.WorkingDirectoryCreateIfMissing = he.createCwd
 	err = cmd.Start()
 	if err != nil {
+		if errors.Is(err, gcs.ErrExecutableNotFound) {
+			return errors.Wrapf(errdefs.ErrNotFound, "exec: '%s' in task: '%s': %v", he.id, he.tid, err)
+		}
+		if errors.Is(err, gcs.ErrTooManyProcesses) {
+			return errors.Wrapf(errdefs.ErrResourceExhausted, "exec: '%s' in task: '%s': %v", he.id, he.tid, err)
+		}
 		return err
 	}
 	he.p = cmd
@@ -347,6 +389,10 @@ func (he *hcsExec) ResizePty(ctx context.Context, width, height uint32) error {
 	if !he.io.Terminal() {
 		return errors.Wrapf(errdefs.ErrFailedPrecondition, "exec: '%s' in task: '%s' is not a tty", he.id, he.tid)
 	}
+	// the console size of a process is 16 bits wide, so don't let it wrap around
+	if width > math.MaxUint16 || height > math.MaxUint16 {
+		return errors.Wrapf(errdefs.ErrInvalidArgument, "exec: '%s' in task: '%s': console size %dx%d is too large", he.id, he.tid, width, height)
+	}
 
 	if he.state == shimExecStateRunning {
 		return he.p.Process.ResizeConsole(ctx, uint16(width), uint16(height))
@@ -467,6 +513,22 @@ func (he *hcsExec) waitForExit() {
 	code, err := he.p.Process.ExitCode()
 	if err != nil {
 		log.G(ctx).WithError(err).Error("failed to get ExitCode")
+	} else if p, ok := he.p.Process.(signaledProcess); ok {
+		// report the same exit code as runc for processes terminated by a signal
+		var (
+			signal syscall.Signal
+			dumped bool
+		)
+		code, signal, dumped = p.ExitStatus()
+		span.AddAttributes(
+			trace.Int64Attribute("exitCode", int64(code)),
+			trace.Int64Attribute("signal", int64(signal)),
+			trace.BoolAttribute("coreDumped", dumped))
+		log.G(ctx).WithFields(logrus.Fields{
+			"exitCode":   code,
+			"signal":     signal,
+			"coreDumped": dumped,
+		}).Debug("exited")
 	} else {
 		log.G(ctx).WithField("exitCode", code).Debug("exited")
 	}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -65,6 +66,16 @@ func etwCallback(sourceID guid.GUID, state etw.ProviderState, level etw.Level, m
 		if resp.GuestStacks != "" {
 			log.WithField("stack", resp.GuestStacks).Info("guest stack dump")
 		}
+		if t, _ := svc.getTask(svc.tid); t != nil {
+			if d, ok := t.(scsiStateDumper); ok {
+				buf := &bytes.Buffer{}
+				if err := d.DumpSCSIState(buf); err == nil {
+					log.WithField("mounts", buf.String()).Info("scsi mount state dump")
+				} else if !errors.Is(err, errTaskNotIsolated) {
+					log.WithError(err).Warn("failed to dump scsi mount state")
+				}
+			}
+		}
 	}
 }
 
@@ -188,9 +188,43 @@ type Options struct {
 	// UTC.
 	NoInheritHostTimezone bool `protobuf:"varint,19,opt,name=no_inherit_host_timezone,json=noInheritHostTimezone,proto3" json:"no_inherit_host_timezone,omitempty"`
 	// scrub_logs enables removing environment variables and other potentially sensitive information from logs
-	ScrubLogs     bool `protobuf:"varint,20,opt,name=scrub_logs,json=scrubLogs,proto3" json:"scrub_logs,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	ScrubLogs bool `protobuf:"varint,20,opt,name=scrub_logs,json=scrubLogs,proto3" json:"scrub_logs,omitempty"`
+	// hcs_operation_concurrency is the maximum number of expensive HCS operations (creating and starting compute
+	// systems, and mounting container layers) the shim runs concurrently. Operations over the limit wait
+	// until another finishes or their context is done. A 0 for this field means there is no limit.
+	HcsOperationConcurrency int32 `protobuf:"varint,21,opt,name=hcs_operation_concurrency,json=hcsOperationConcurrency,proto3" json:"hcs_operation_concurrency,omitempty"`
+	// host_hcs_operation_concurrency is the maximum number of expensive HCS operations run concurrently by all the
+	// shims on the host that set this field, at most 63. It is enforced with a named mutex per slot, and every shim
+	// should set the same value. Slots held by a shim that exits without releasing them (e.g., because it crashed)
+	// are freed. A 0 for this field means there is no limit.
+	HostHcsOperationConcurrency int32 `protobuf:"varint,22,opt,name=host_hcs_operation_concurrency,json=hostHcsOperationConcurrency,proto3" json:"host_hcs_operation_concurrency,omitempty"`
+	// hcs_operation_wait_log_threshold_in_ms is how long an HCS operation can wait for the limits above before a log
+	// line is written when it starts. A 0 for this field uses the default of 1000ms.
+	HcsOperationWaitLogThresholdInMs int32 `protobuf:"varint,23,opt,name=hcs_operation_wait_log_threshold_in_ms,json=hcsOperationWaitLogThresholdInMs,proto3" json:"hcs_operation_wait_log_threshold_in_ms,omitempty"`
+	// vsmb_consolidate_layers shares the read-only layers of Hyper-V isolated WCOW containers into the UVM through a
+	// single VSMB share of their parent directory, rather than a share for each layer, which keeps containers with
+	// deep layer chains within the UVM's VSMB share limit. Layers are only consolidated if their parent directory
+	// holds nothing but the layers of the container, since the UVM can read all of it.
+	VsmbConsolidateLayers bool `protobuf:"varint,24,opt,name=vsmb_consolidate_layers,json=vsmbConsolidateLayers,proto3" json:"vsmb_consolidate_layers,omitempty"`
+	// uvm_sharing_groups are the UVM sharing groups that hypervisor isolated LCOW pods may join with the
+	// `io.microsoft.cri.uvm-sharing-group` annotation. Pods in a group share a UVM and are not isolated from each
+	// other by a VM boundary, so a pod that names a group not listed here fails to be created. If empty, pods
+	// cannot share a UVM.
+	UvmSharingGroups []string `protobuf:"bytes,25,rep,name=uvm_sharing_groups,json=uvmSharingGroups,proto3" json:"uvm_sharing_groups,omitempty"`
+	// tpm_state_root is the directory on the host that the files the state of the virtual TPMs of UVMs persist in are
+	// kept in, named by the `io.microsoft.virtualmachine.tpm.state-file-path` annotation relative to it. It must only be
+	// writable by the shim. If empty, the state of a virtual TPM cannot persist in a file.
+	TpmStateRoot string `protobuf:"bytes,26,opt,name=tpm_state_root,json=tpmStateRoot,proto3" json:"tpm_state_root,omitempty"`
+	// lcow_extra_layer_root is the directory on the host that the layer VHDs added to LCOW containers with the
+	// `io.microsoft.container.lcow.extralayers` annotation must be in. It must only be writable by trusted users. If
+	// empty, extra layers cannot be added to containers.
+	LcowExtraLayerRoot string `protobuf:"bytes,27,opt,name=lcow_extra_layer_root,json=lcowExtraLayerRoot,proto3" json:"lcow_extra_layer_root,omitempty"`
+	// wcow_extra_layer_root is the directory on the host, or the SMB share, that the layer folders added to WCOW
+	// containers with the `io.microsoft.container.wcow.layerFolders.extra` annotation must be in. It must only be
+	// writable by trusted users. If empty, extra layers cannot be added to containers.
+	WcowExtraLayerRoot string `protobuf:"bytes,28,opt,name=wcow_extra_layer_root,json=wcowExtraLayerRoot,proto3" json:"wcow_extra_layer_root,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *Options) Reset() {
@@ -363,6 +397,62 @@ func (x *Options) GetScrubLogs() bool {
 	return false
 }
 
+func (x *Options) GetHcsOperationConcurrency() int32 {
+	if x != nil {
+		return x.HcsOperationConcurrency
+	}
+	return 0
+}
+
+func (x *Options) GetHostHcsOperationConcurrency() int32 {
+	if x != nil {
+		return x.HostHcsOperationConcurrency
+	}
+	return 0
+}
+
+func (x *Options) GetHcsOperationWaitLogThresholdInMs() int32 {
+	if x != nil {
+		return x.HcsOperationWaitLogThresholdInMs
+	}
+	return 0
+}
+
+func (x *Options) GetVsmbConsolidateLayers() bool {
+	if x != nil {
+		return x.VsmbConsolidateLayers
+	}
+	return false
+}
+
+func (x *Options) GetUvmSharingGroups() []string {
+	if x != nil {
+		return x.UvmSharingGroups
+	}
+	return nil
+}
+
+func (x *Options) GetTpmStateRoot() string {
+	if x != nil {
+		return x.TpmStateRoot
+	}
+	return ""
+}
+
+func (x *Options) GetLcowExtraLayerRoot() string {
+	if x != nil {
+		return x.LcowExtraLayerRoot
+	}
+	return ""
+}
+
+func (x *Options) GetWcowExtraLayerRoot() string {
+	if x != nil {
+		return x.WcowExtraLayerRoot
+	}
+	return ""
+}
+
 // ProcessDetails contains additional information about a process. This is the additional
 // info returned in the Pids query.
 type ProcessDetails struct {
@@ -477,7 +567,7 @@ var File_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_options_runh
 
 const file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_options_runhcs_proto_rawDesc = "" +
 	"\n" +
-	"Ogithub.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options/runhcs.proto\x12\x14containerd.runhcs.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd9\t\n" +
+	"Ogithub.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options/runhcs.proto\x12\x14containerd.runhcs.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9e\r\n" +
 	"\aOptions\x12\x14\n" +
 	"\x05debug\x18\x01 \x01(\bR\x05debug\x12F\n" +
 	"\n" +
@@ -503,7 +593,15 @@ const file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_options_ru
 	"\x1ddefault_container_annotations\x18\x12 \x03(\v2>.containerd.runhcs.v1.Options.DefaultContainerAnnotationsEntryR\x1bdefaultContainerAnnotations\x127\n" +
 	"\x18no_inherit_host_timezone\x18\x13 \x01(\bR\x15noInheritHostTimezone\x12\x1d\n" +
 	"\n" +
-	"scrub_logs\x18\x14 \x01(\bR\tscrubLogs\x1aN\n" +
+	"scrub_logs\x18\x14 \x01(\bR\tscrubLogs\x12:\n" +
+	"\x19hcs_operation_concurrency\x18\x15 \x01(\x05R\x17hcsOperationConcurrency\x12C\n" +
+	"\x1ehost_hcs_operation_concurrency\x18\x16 \x01(\x05R\x1bhostHcsOperationConcurrency\x12P\n" +
+	"&hcs_operation_wait_log_threshold_in_ms\x18\x17 \x01(\x05R hcsOperationWaitLogThresholdInMs\x126\n" +
+	"\x17vsmb_consolidate_layers\x18\x18 \x01(\bR\x15vsmbConsolidateLayers\x12,\n" +
+	"\x12uvm_sharing_groups\x18\x19 \x03(\tR\x10uvmSharingGroups\x12$\n" +
+	"\x0etpm_state_root\x18\x1a \x01(\tR\ftpmStateRoot\x121\n" +
+	"\x15lcow_extra_layer_root\x18\x1b \x01(\tR\x12lcowExtraLayerRoot\x121\n" +
+	"\x15wcow_extra_layer_root\x18\x1c \x01(\tR\x12wcowExtraLayerRoot\x1aN\n" +
 	" DefaultContainerAnnotationsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\")\n" +
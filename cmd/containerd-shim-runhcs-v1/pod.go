@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/Microsoft/hcsshim/internal/copyfile"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/hcsoci"
 	"github.com/Microsoft/hcsshim/internal/layers"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oci"
@@ -139,39 +142,49 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 		return nil, errors.Wrapf(errdefs.ErrFailedPrecondition, "pod support is not available on Windows versions previous to RS5 (%d)", osversion.RS5)
 	}
 
-	ct, sid, err := oci.GetSandboxTypeAndID(s.Annotations)
-	if err != nil {
+	if err := validatePodSandbox(ctx, req, s); err != nil {
 		return nil, err
 	}
-	if ct != oci.KubernetesContainerTypeSandbox {
-		return nil, errors.Wrapf(
-			errdefs.ErrFailedPrecondition,
-			"expected annotation: '%s': '%s' got '%s'",
-			annotations.KubernetesContainerType,
-			oci.KubernetesContainerTypeSandbox,
-			ct)
-	}
-	if sid != req.ID {
-		return nil, errors.Wrapf(
-			errdefs.ErrFailedPrecondition,
-			"expected annotation '%s': '%s' got '%s'",
-			annotations.KubernetesSandboxID,
-			req.ID,
-			sid)
+
+	shimOpts, err := shimOptions(req)
+	if err != nil {
+		return nil, err
 	}
 
 	owner := filepath.Base(os.Args[0])
 	isWCOW := oci.IsWCOW(s)
 
+	group := s.Annotations[annotations.UVMSharingGroup]
+	if group != "" {
+		// the UVM is shared with the other pods of the group, so the pod is a
+		// virtual pod in it
+		s.Annotations[annotations.VirtualPodID] = req.ID
+	}
+
 	p := pod{
 		events: events,
 		id:     req.ID,
+		group:  group,
 		spec:   s,
 	}
 
 	var parent *uvm.UtilityVM
 	var lopts *uvm.OptionsLCOW
+	var netNSPrewarm *hcsoci.NetworkNamespacePrewarm
 	if oci.IsIsolated(s) {
+		// If the sandbox task will create its own network namespace, set it up
+		// while the UVM boots instead of after.
+		if !isWCOW && s.Windows != nil && s.Windows.Network != nil &&
+			s.Windows.Network.NetworkNamespace == "" && len(s.Windows.Network.EndpointList) > 0 {
+			netNSPrewarm = hcsoci.PrewarmNetworkNamespace(ctx, req.ID, s.Windows.Network.EndpointList)
+			defer func() {
+				// no-op if the sandbox task took ownership of the namespace
+				if err := netNSPrewarm.Release(ctx); err != nil {
+					log.G(ctx).WithError(err).Warn("failed to release prewarmed network namespace")
+				}
+			}()
+		}
+
 		// Create the UVM parent
 		opts, err := oci.SpecToUVMCreateOpts(ctx, s, fmt.Sprintf("%s@vm", req.ID), owner)
 		if err != nil {
@@ -181,12 +194,15 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 		case *uvm.OptionsLCOW:
 			lopts = (opts).(*uvm.OptionsLCOW)
 			lopts.BundleDirectory = req.Bundle
+			lopts.TPMStateRoot = shimOpts.GetTpmStateRoot()
 			parent, err = uvm.CreateLCOW(ctx, lopts)
 			if err != nil {
 				return nil, err
 			}
 		case *uvm.OptionsWCOW:
 			wopts := (opts).(*uvm.OptionsWCOW)
+			wopts.ConsolidateVSMBLayers = shimOpts.GetVsmbConsolidateLayers()
+			wopts.TPMStateRoot = shimOpts.GetTpmStateRoot()
 			err = initializeWCOWBootFiles(ctx, wopts, req.Rootfs, s)
 			if err != nil {
 				return nil, err
@@ -238,11 +254,7 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 
 	p.host = parent
 	if parent != nil {
-		cid := req.ID
-		if id, ok := s.Annotations[annotations.NcproxyContainerID]; ok {
-			cid = id
-		}
-		caAddr := fmt.Sprintf(uvm.ComputeAgentAddrFmt, cid)
+		caAddr, cid := computeAgentAddress(req.ID, s)
 		if err := parent.CreateAndAssignNetworkSetup(ctx, caAddr, cid); err != nil {
 			return nil, err
 		}
@@ -308,16 +320,84 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 			}
 		}
 		// LCOW (and WCOW Process Isolated for the time being) requires a real
-		// task for the sandbox.
-		lt, err := newHcsTask(ctx, events, parent, true, req, s)
+		// task for the sandbox. The UVM of a group is owned by the group
+		// rather than the task, as it outlives the pod.
+		lt, err := newHcsTask(ctx, events, parent, group == "", req, s, netNSPrewarm)
 		if err != nil {
 			return nil, err
 		}
 		p.sandboxTask = lt
 	}
+	if group != "" {
+		return newPodGroup(events, group, &p), nil
+	}
 	return &p, nil
 }
 
+// validatePodSandbox returns an error if `s` is not the spec of the sandbox
+// `req.ID` of a pod, or of a pod that cannot be created.
+func validatePodSandbox(ctx context.Context, req *task.CreateTaskRequest, s *specs.Spec) error {
+	ct, sid, err := oci.GetSandboxTypeAndID(s.Annotations)
+	if err != nil {
+		return err
+	}
+	if ct != oci.KubernetesContainerTypeSandbox {
+		return errors.Wrapf(
+			errdefs.ErrFailedPrecondition,
+			"expected annotation: '%s': '%s' got '%s'",
+			annotations.KubernetesContainerType,
+			oci.KubernetesContainerTypeSandbox,
+			ct)
+	}
+	if sid != req.ID {
+		return errors.Wrapf(
+			errdefs.ErrFailedPrecondition,
+			"expected annotation '%s': '%s' got '%s'",
+			annotations.KubernetesSandboxID,
+			req.ID,
+			sid)
+	}
+
+	if group := s.Annotations[annotations.UVMSharingGroup]; group != "" {
+		if !oci.IsLCOW(s) || !oci.IsIsolated(s) {
+			return errors.Wrapf(
+				errdefs.ErrNotImplemented,
+				"annotation '%s' is only supported for hypervisor isolated LCOW pods",
+				annotations.UVMSharingGroup)
+		}
+		// The annotation is set by the user of the pod, so the groups a pod
+		// can join are set by the shim options of its runtime.
+		shimOpts, err := shimOptions(req)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(shimOpts.GetUvmSharingGroups(), group) {
+			return errors.Wrapf(
+				errdefs.ErrFailedPrecondition,
+				"UVM sharing group '%s' is not allowed by the shim options",
+				group)
+		}
+	}
+
+	// check the sandbox's labels before the UVM is created for it
+	if oci.IsLCOW(s) {
+		if err := oci.ValidateLCOWLSMLabels(ctx, s.Annotations, s.Process, s.Linux.MountLabel); err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "%v", err)
+		}
+	}
+	return nil
+}
+
+// computeAgentAddress returns the address to serve the compute agent of the
+// pod of sandbox `id` on, and the container ID to register it with ncproxy as.
+func computeAgentAddress(id string, s *specs.Spec) (string, string) {
+	cid := id
+	if v, ok := s.Annotations[annotations.NcproxyContainerID]; ok {
+		cid = v
+	}
+	return fmt.Sprintf(uvm.ComputeAgentAddrFmt, cid), cid
+}
+
 var _ = (shimPod)(&pod{})
 
 type pod struct {
@@ -337,6 +417,11 @@ type pod struct {
 	//
 	// It MUST be treated as read only in the lifetime of the pod.
 	host *uvm.UtilityVM
+	// group is the UVM sharing group of the pod, whose pods share `host`, or
+	// `""` if `host` is the pod's own.
+	//
+	// It MUST be treated as read only in the lifetime of the pod.
+	group string
 
 	// jobContainer specifies whether this pod is for WCOW job containers only.
 	//
@@ -347,6 +432,9 @@ type pod struct {
 	spec *specs.Spec
 
 	workloadTasks sync.Map
+	// failedCreates are the ids of workload tasks that failed to be created in
+	// `host`, which may have left the container behind in the guest.
+	failedCreates sync.Map
 }
 
 func (p *pod) ID() string {
@@ -408,15 +496,43 @@ func (p *pod) CreateTask(ctx context.Context, req *task.CreateTaskRequest, s *sp
 			sid)
 	}
 
-	st, err := newHcsTask(ctx, p.events, p.host, false, req, s)
+	if p.group != "" {
+		// place the container in the virtual pod of the sandbox
+		s.Annotations[annotations.VirtualPodID] = p.id
+	}
+
+	// CRI retries a failed create with the same id, which the guest refuses
+	// if the failed attempt got as far as creating the container in it
+	if _, ok := p.failedCreates.Load(req.ID); ok {
+		if err := p.deleteContainerState(ctx, req.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	st, err := newHcsTask(ctx, p.events, p.host, false, req, s, nil)
 	if err != nil {
+		if p.host != nil && p.host.DeleteContainerStateSupported() {
+			p.failedCreates.Store(req.ID, struct{}{})
+		}
 		return nil, err
 	}
+	p.failedCreates.Delete(req.ID)
 
 	p.workloadTasks.Store(req.ID, st)
 	return st, nil
 }
 
+// deleteContainerState deletes what the guest has of the container `tid`, left
+// behind by a failed attempt to create the task, so that it can be created
+// again.
+func (p *pod) deleteContainerState(ctx context.Context, tid string) error {
+	log.G(ctx).WithField("tid", tid).Info("deleting guest state of a failed create before retrying it")
+	if err := p.host.DeleteContainerState(ctx, tid); err != nil && !hcs.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to delete guest state of the previous attempt to create task with id: '%s'", tid)
+	}
+	return nil
+}
+
 func (p *pod) GetTask(tid string) (shimTask, error) {
 	if tid == p.id {
 		return p.sandboxTask, nil
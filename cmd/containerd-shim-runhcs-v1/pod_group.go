@@ -0,0 +1,307 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	task "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+var _ = (shimPod)(&podGroup{})
+
+// podGroup is the pods of a UVM sharing group (see
+// [annotations.UVMSharingGroup]), which share the UVM created for the first
+// pod of the group and are all served by the shim of the group.
+//
+// Requests for a task are forwarded to the pod the task is in. The UVM is
+// closed once the last pod of the group is deleted.
+type podGroup struct {
+	events publisher
+	// id is the id of the first pod of the group, which the shim was started
+	// for.
+	//
+	// It MUST be treated as read only in the lifetime of the group.
+	id string
+	// name is the name of the group.
+	//
+	// It MUST be treated as read only in the lifetime of the group.
+	name string
+	// host is the UtilityVM shared by the pods of the group.
+	//
+	// It MUST be treated as read only in the lifetime of the group.
+	host *uvm.UtilityVM
+
+	// mu serializes adding and removing pods, so that no pod is added to
+	// `host` once it is closed.
+	mu sync.Mutex
+	// pods are the `*pod`s of the group by their id.
+	pods sync.Map
+	// closed is set once `host` is closed with the last pod of the group.
+	closed bool
+}
+
+// newPodGroup returns the group `name` with its `first` pod, which created the
+// UVM of the group.
+func newPodGroup(events publisher, name string, first *pod) *podGroup {
+	g := &podGroup{
+		events: events,
+		id:     first.id,
+		name:   name,
+		host:   first.host,
+	}
+	g.pods.Store(first.id, first)
+	return g
+}
+
+func (g *podGroup) ID() string {
+	return g.id
+}
+
+// CreateTask creates the sandbox task of a new pod in the group, or a workload
+// task in the pod of its sandbox.
+func (g *podGroup) CreateTask(ctx context.Context, req *task.CreateTaskRequest, s *specs.Spec) (shimTask, error) {
+	// The GCS keys containers by their id, so ids are unique across the group
+	// rather than in each pod.
+	if _, err := g.GetTask(req.ID); err == nil {
+		return nil, errors.Wrapf(errdefs.ErrAlreadyExists, "task with id: '%s' already exists in pod group: '%s'", req.ID, g.name)
+	}
+
+	ct, sid, err := oci.GetSandboxTypeAndID(s.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if ct == oci.KubernetesContainerTypeSandbox {
+		p, err := g.addPod(ctx, req, s)
+		if err != nil {
+			return nil, err
+		}
+		return p.sandboxTask, nil
+	}
+	raw, ok := g.pods.Load(sid)
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "pod with id: '%s' not found in pod group: '%s'", sid, g.name)
+	}
+	return raw.(*pod).CreateTask(ctx, req, s)
+}
+
+// addPod creates the pod of the sandbox task `req` in the UVM of the group.
+func (g *podGroup) addPod(ctx context.Context, req *task.CreateTaskRequest, s *specs.Spec) (*pod, error) {
+	log.G(ctx).WithFields(logrus.Fields{
+		"tid":   req.ID,
+		"group": g.name,
+	}).Debug("podGroup::addPod")
+
+	if err := validatePodSandbox(ctx, req, s); err != nil {
+		return nil, err
+	}
+	if group := s.Annotations[annotations.UVMSharingGroup]; group != g.name {
+		return nil, errors.Wrapf(
+			errdefs.ErrFailedPrecondition,
+			"expected annotation '%s': '%s' got '%s'",
+			annotations.UVMSharingGroup,
+			g.name,
+			group)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, errors.Wrapf(errdefs.ErrFailedPrecondition, "the UVM of pod group: '%s' is closed", g.name)
+	}
+
+	// The UVM's network setup registers the compute agent of the first pod
+	// with ncproxy, so the namespace of every other pod gets its own.
+	if g.host != nil {
+		nsid := ""
+		if s.Windows != nil && s.Windows.Network != nil {
+			nsid = s.Windows.Network.NetworkNamespace
+		}
+		if g.host.NCProxyEnabled() && nsid == "" {
+			return nil, errors.Wrapf(errdefs.ErrFailedPrecondition, "pods in pod group: '%s' must be given a network namespace for ncproxy", g.name)
+		}
+		caAddr, cid := computeAgentAddress(req.ID, s)
+		if err := g.host.CreateAndAssignNamespaceNetworkSetup(ctx, caAddr, cid, nsid); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Annotations[annotations.VirtualPodID] = req.ID
+	t, err := newHcsTask(ctx, g.events, g.host, false, req, s, nil)
+	if err != nil {
+		return nil, err
+	}
+	p := &pod{
+		events:      g.events,
+		id:          req.ID,
+		sandboxTask: t,
+		host:        g.host,
+		group:       g.name,
+		spec:        s,
+	}
+	g.pods.Store(p.id, p)
+	return p, nil
+}
+
+// podOf returns the pod of the group that task `tid` is in.
+func (g *podGroup) podOf(tid string) (*pod, error) {
+	if raw, ok := g.pods.Load(tid); ok {
+		return raw.(*pod), nil
+	}
+	var p *pod
+	g.pods.Range(func(_, value interface{}) bool {
+		if _, err := value.(*pod).GetTask(tid); err == nil {
+			p = value.(*pod)
+			return false
+		}
+		return true
+	})
+	if p == nil {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "task with id: '%s' not found", tid)
+	}
+	return p, nil
+}
+
+func (g *podGroup) GetTask(tid string) (shimTask, error) {
+	p, err := g.podOf(tid)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetTask(tid)
+}
+
+func (g *podGroup) ListTasks() (_ []shimTask, err error) {
+	var tasks []shimTask
+	g.pods.Range(func(_, value interface{}) bool {
+		var pt []shimTask
+		pt, err = value.(*pod).ListTasks()
+		if err != nil {
+			return false
+		}
+		tasks = append(tasks, pt...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (g *podGroup) KillTask(ctx context.Context, tid, eid string, signal uint32, all bool) error {
+	p, err := g.podOf(tid)
+	if err != nil {
+		return err
+	}
+	return p.KillTask(ctx, tid, eid, signal, all)
+}
+
+// DeleteTask deletes the task `tid` from its pod. Deleting the sandbox task of
+// a pod removes the pod from the group.
+func (g *podGroup) DeleteTask(ctx context.Context, tid string) error {
+	p, err := g.podOf(tid)
+	if err != nil {
+		return errors.Wrap(err, "could not find task to delete")
+	}
+	if err := p.DeleteTask(ctx, tid); err != nil {
+		return err
+	}
+	if tid == p.id {
+		g.removePod(ctx, tid)
+	}
+	return nil
+}
+
+// removePod removes the pod `id` from the group, and closes the UVM of the
+// group if it was the last pod.
+func (g *podGroup) removePod(ctx context.Context, id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pods.Delete(id)
+	if g.len() > 0 || g.closed {
+		return
+	}
+	log.G(ctx).WithField("group", g.name).Info("closing the UVM of the pod group with its last pod")
+	// g.host should never be nil for a real group but in testing we stub it.
+	if g.host != nil {
+		if err := g.host.Close(); err != nil {
+			log.G(ctx).WithError(err).Error("failed host vm shutdown")
+		}
+	}
+	g.closed = true
+}
+
+// len returns the number of pods in the group.
+func (g *podGroup) len() (n int) {
+	g.pods.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// sandboxTask returns the sandbox task of a pod of the group, to inspect the
+// UVM of the group with, or `nil` if the group has no pods.
+func (g *podGroup) sandboxTask() (t shimTask) {
+	g.pods.Range(func(_, value interface{}) bool {
+		t = value.(*pod).sandboxTask
+		return false
+	})
+	return t
+}
+
+// podStates returns the resources of the UVM attributed to each pod of the
+// group by the tasks in it, such as "pod-a: 3 tasks, 1024 MB and 2 processors
+// admitted, 2 SCSI attachments", for diagnostics.
+func (g *podGroup) podStates() []string {
+	var admitted map[string]uvm.ContainerResources
+	scsiOwners := make(map[string]int)
+	if g.host != nil {
+		admitted = g.host.AdmittedContainers()
+		if pools, err := g.host.SCSIManager.Pools(); err == nil {
+			for _, p := range pools {
+				for owner, n := range p.Owners {
+					scsiOwners[owner] += n
+				}
+			}
+		}
+	}
+
+	var states []string
+	g.pods.Range(func(_, value interface{}) bool {
+		p := value.(*pod)
+		tasks, err := p.ListTasks()
+		if err != nil {
+			states = append(states, fmt.Sprintf("%s: %v", p.id, err))
+			return true
+		}
+		var (
+			memoryInMB  uint64
+			processors  int32
+			attachments int
+		)
+		for _, t := range tasks {
+			r := admitted[t.ID()]
+			memoryInMB += r.MemoryInMB
+			processors += r.ProcessorCount
+			attachments += scsiOwners[t.ID()]
+		}
+		states = append(states, fmt.Sprintf("%s: %d tasks, %d MB and %d processors admitted, %d SCSI attachments",
+			p.id, len(tasks), memoryInMB, processors, attachments))
+		return true
+	})
+	sort.Strings(states)
+	return states
+}
@@ -0,0 +1,218 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	task "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/errdefs"
+	typeurl "github.com/containerd/typeurl/v2"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+// setupTestPodGroupWithFakes returns a group of two fake pods, each with a
+// workload task.
+func setupTestPodGroupWithFakes(t *testing.T) (*podGroup, []*pod, []*testShimTask) {
+	t.Helper()
+	p1, _ := setupTestPodWithFakes(t)
+	p1.id = t.Name() + "-1"
+	p1.sandboxTask.(*testShimTask).id = p1.id
+	p2, _ := setupTestPodWithFakes(t)
+	p2.id = t.Name() + "-2"
+	p2.sandboxTask.(*testShimTask).id = p2.id
+
+	g := newPodGroup(nil, "group", p1)
+	g.pods.Store(p2.id, p2)
+	return g, []*pod{p1, p2}, []*testShimTask{setupTestTaskInPod(t, p1), setupTestTaskInPod(t, p2)}
+}
+
+func Test_podGroup_GetTask(t *testing.T) {
+	g, pods, tasks := setupTestPodGroupWithFakes(t)
+	for i, p := range pods {
+		st, err := g.GetTask(p.id)
+		if err != nil {
+			t.Fatalf("should not have failed, got: %v", err)
+		}
+		if st != p.sandboxTask {
+			t.Fatalf("should have returned the sandbox task of pod %s", p.id)
+		}
+		wt, err := g.GetTask(tasks[i].id)
+		if err != nil {
+			t.Fatalf("should not have failed, got: %v", err)
+		}
+		if wt != tasks[i] {
+			t.Fatalf("should have returned the workload task of pod %s", p.id)
+		}
+	}
+	if _, err := g.GetTask("thisshouldnotmatch"); !errors.Is(err, errdefs.ErrNotFound) {
+		t.Fatalf("should have returned ErrNotFound, got: %v", err)
+	}
+}
+
+func Test_podGroup_ListTasks(t *testing.T) {
+	g, _, _ := setupTestPodGroupWithFakes(t)
+	tasks, err := g.ListTasks()
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(tasks) != 4 {
+		t.Fatalf("expected the sandbox and workload tasks of both pods, got %d tasks", len(tasks))
+	}
+}
+
+func Test_podGroup_CreateTask_Duplicate_Error(t *testing.T) {
+	g, pods, tasks := setupTestPodGroupWithFakes(t)
+	// the task id is taken in the other pod of the group
+	s := &specs.Spec{Annotations: map[string]string{
+		annotations.KubernetesContainerType: string(oci.KubernetesContainerTypeContainer),
+		annotations.KubernetesSandboxID:     pods[0].id,
+	}}
+	_, err := g.CreateTask(context.TODO(), &task.CreateTaskRequest{ID: tasks[1].id}, s)
+	if !errors.Is(err, errdefs.ErrAlreadyExists) {
+		t.Fatalf("should have returned ErrAlreadyExists, got: %v", err)
+	}
+}
+
+func Test_podGroup_CreateTask_UnknownPod_Error(t *testing.T) {
+	g, _, _ := setupTestPodGroupWithFakes(t)
+	s := &specs.Spec{Annotations: map[string]string{
+		annotations.KubernetesContainerType: string(oci.KubernetesContainerTypeContainer),
+		annotations.KubernetesSandboxID:     "thisshouldnotmatch",
+	}}
+	_, err := g.CreateTask(context.TODO(), &task.CreateTaskRequest{ID: t.Name()}, s)
+	if !errors.Is(err, errdefs.ErrNotFound) {
+		t.Fatalf("should have returned ErrNotFound, got: %v", err)
+	}
+}
+
+// createGroupSandboxRequest returns the request to create the sandbox task of
+// a pod in UVM sharing group `group`, with shim options that allow `allowed`.
+func createGroupSandboxRequest(t *testing.T, group string, allowed ...string) (*task.CreateTaskRequest, *specs.Spec) {
+	t.Helper()
+	opts, err := typeurl.MarshalAny(&runhcsopts.Options{UvmSharingGroups: allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &specs.Spec{
+		Annotations: map[string]string{
+			annotations.KubernetesContainerType: string(oci.KubernetesContainerTypeSandbox),
+			annotations.KubernetesSandboxID:     t.Name(),
+			annotations.UVMSharingGroup:         group,
+		},
+		Linux:   &specs.Linux{},
+		Windows: &specs.Windows{HyperV: &specs.WindowsHyperV{}},
+	}
+	return &task.CreateTaskRequest{ID: t.Name(), Options: typeurl.MarshalProto(opts)}, s
+}
+
+func Test_podGroup_CreateTask_OtherGroup_Error(t *testing.T) {
+	g, _, _ := setupTestPodGroupWithFakes(t)
+	req, s := createGroupSandboxRequest(t, "other", "group", "other")
+	_, err := g.CreateTask(context.TODO(), req, s)
+	if !errors.Is(err, errdefs.ErrFailedPrecondition) {
+		t.Fatalf("should have returned ErrFailedPrecondition, got: %v", err)
+	}
+}
+
+func Test_validatePodSandbox_UVMSharingGroup(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		allowed []string
+		wantErr bool
+	}{
+		{name: "allowed", allowed: []string{"other", "group"}},
+		{name: "not allowed", allowed: []string{"other"}, wantErr: true},
+		{name: "no groups allowed", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, s := createGroupSandboxRequest(t, "group", tc.allowed...)
+			err := validatePodSandbox(context.TODO(), req, s)
+			if tc.wantErr {
+				if !errors.Is(err, errdefs.ErrFailedPrecondition) {
+					t.Fatalf("should have returned ErrFailedPrecondition, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("should not have failed, got: %v", err)
+			}
+		})
+	}
+}
+
+func Test_podGroup_KillTask_SandboxID_All(t *testing.T) {
+	g, pods, tasks := setupTestPodGroupWithFakes(t)
+	for _, wt := range tasks {
+		if err := wt.exec.Start(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.KillTask(context.TODO(), pods[0].id, "", 0xf, true); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if s := tasks[0].exec.State(); s != shimExecStateExited {
+		t.Fatalf("workload task of the killed pod should have exited, got state: %s", s)
+	}
+	if s := tasks[1].exec.State(); s != shimExecStateRunning {
+		t.Fatalf("workload task of the other pod should still be running, got state: %s", s)
+	}
+}
+
+func Test_podGroup_DeleteTask_LastPod(t *testing.T) {
+	g, pods, tasks := setupTestPodGroupWithFakes(t)
+
+	if err := g.DeleteTask(context.TODO(), tasks[0].id); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if g.len() != 2 {
+		t.Fatalf("deleting a workload task should not remove its pod, got %d pods", g.len())
+	}
+
+	for i, p := range pods {
+		if err := g.DeleteTask(context.TODO(), p.id); err != nil {
+			t.Fatalf("should not have failed, got: %v", err)
+		}
+		if _, err := g.GetTask(p.id); !errors.Is(err, errdefs.ErrNotFound) {
+			t.Fatalf("deleted pod %s should not be found, got: %v", p.id, err)
+		}
+		if last := i == len(pods)-1; g.closed != last {
+			t.Fatalf("expected the UVM to be closed: %t, got: %t", last, g.closed)
+		}
+	}
+}
+
+func Test_PodGroupShim_shutdownInternal(t *testing.T) {
+	g, pods, _ := setupTestPodGroupWithFakes(t)
+	s, err := NewService(WithTID(pods[0].id), WithIsSandbox(true))
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+	s.taskOrPod.Store(g)
+
+	// the pod the shim was started for is deleted first
+	for _, p := range pods {
+		if _, err := s.shutdownInternal(context.Background(), &task.ShutdownRequest{ID: pods[0].id}); err != nil {
+			t.Fatalf("could not shut down service: %v", err)
+		}
+		if s.IsShutdown() {
+			t.Fatal("service shutdown with pods left in the group")
+		}
+		if err := g.DeleteTask(context.TODO(), p.id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.shutdownInternal(context.Background(), &task.ShutdownRequest{ID: pods[1].id}); err != nil {
+		t.Fatalf("could not shut down service: %v", err)
+	}
+	if !s.IsShutdown() {
+		t.Fatal("service did not shutdown with the last pod of the group")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 	"unsafe"
@@ -26,6 +27,7 @@ import (
 	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
 	"github.com/Microsoft/hcsshim/internal/extendedtask"
 	hcslog "github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oplimit"
 	"github.com/Microsoft/hcsshim/internal/shimdiag"
 	"github.com/Microsoft/hcsshim/pkg/octtrpc"
 )
@@ -174,8 +176,30 @@ var serveCommand = cli.Command{
 			return errors.New("socket is required to be pipe address")
 		}
 
+		// limit concurrent HCS operations, if configured
+		if shimOpts.HcsOperationConcurrency != 0 || shimOpts.HostHcsOperationConcurrency != 0 {
+			opLimiter, err := oplimit.New(oplimit.Config{
+				Limit:            int(shimOpts.HcsOperationConcurrency),
+				HostLimit:        int(shimOpts.HostHcsOperationConcurrency),
+				WaitLogThreshold: time.Duration(shimOpts.HcsOperationWaitLogThresholdInMs) * time.Millisecond,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to create HCS operation limiter")
+			}
+			defer opLimiter.Close()
+			if err := oplimit.SetDefault(opLimiter); err != nil {
+				return err
+			}
+		}
+
+		// serve is started in the bundle directory
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
 		ttrpcAddress := os.Getenv(ttrpcAddressEnv)
-		ttrpcEventPublisher, err := newEventPublisher(ttrpcAddress, namespaceFlag)
+		ttrpcEventPublisher, err := newEventPublisher(ttrpcAddress, namespaceFlag, filepath.Join(cwd, eventJournalDir))
 		if err != nil {
 			return err
 		}
@@ -247,6 +271,13 @@ var serveCommand = cli.Command{
 		case err = <-serrs:
 			// the ttrpc server shutdown without processing a shutdown request
 		case <-svc.Done():
+			// events are forwarded asynchronously, so give the final ones
+			// (eg, the task delete) a chance to reach containerd
+			fctx, fcancel := context.WithTimeout(context.Background(), eventFlushTimeout)
+			if ferr := ttrpcEventPublisher.flush(fctx); ferr != nil {
+				logrus.WithError(ferr).Warn("failed to flush events before exiting")
+			}
+			fcancel()
 			if !svc.gracefulShutdown {
 				// Return immediately, but still close ttrpc server, pipes, and spans
 				// Shouldn't need to os.Exit without clean up (ie, deferred `.Close()`s)
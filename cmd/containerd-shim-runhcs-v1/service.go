@@ -18,6 +18,7 @@ import (
 
 	"github.com/Microsoft/hcsshim/internal/extendedtask"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/oplimit"
 	"github.com/Microsoft/hcsshim/internal/shimdiag"
 )
 
@@ -341,6 +342,23 @@ func (s *service) DiagShare(ctx context.Context, req *shimdiag.ShareRequest) (_
 	return r, errdefs.ToGRPC(e)
 }
 
+func (s *service) DiagSnapshot(ctx context.Context, req *shimdiag.SnapshotRequest) (_ *shimdiag.SnapshotResponse, err error) {
+	ctx, span := oc.StartSpan(ctx, "DiagSnapshot")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+
+	span.AddAttributes(
+		trace.StringAttribute("tid", req.ID),
+		trace.StringAttribute("path", req.Path))
+
+	if s.isSandbox {
+		span.AddAttributes(trace.StringAttribute("pod-id", s.tid))
+	}
+
+	r, e := s.diagSnapshotInternal(ctx, req)
+	return r, errdefs.ToGRPC(e)
+}
+
 func (s *service) DiagTasks(ctx context.Context, req *shimdiag.TasksRequest) (_ *shimdiag.TasksResponse, err error) {
 	ctx, span := oc.StartSpan(ctx, "DiagTasks")
 	defer span.End()
@@ -532,6 +550,54 @@ func (s *service) DiagPid(ctx context.Context, req *shimdiag.PidRequest) (*shimd
 	}, nil
 }
 
+func (s *service) DiagState(ctx context.Context, req *shimdiag.StateRequest) (*shimdiag.StateResponse, error) {
+	if s == nil {
+		return nil, nil
+	}
+	ctx, span := oc.StartSpan(ctx, "DiagState") //nolint:ineffassign,staticcheck
+	defer span.End()
+
+	span.AddAttributes(trace.StringAttribute("tid", s.tid))
+
+	l := oplimit.Default()
+	resp := &shimdiag.StateResponse{
+		QueuedOperations: int32(l.Queued()),
+		ActiveOperations: int32(l.Active()),
+	}
+	t, _ := s.getTask(s.tid)
+	if g := s.getPodGroup(); g != nil {
+		// the pod the shim was started for can be deleted before the other
+		// pods of its group
+		t = g.sandboxTask()
+		resp.Pods = g.podStates()
+	}
+	if t != nil {
+		if bt, ok := t.(uvmBootTimer); ok {
+			if b, err := bt.UVMBootTimes(); err == nil {
+				resp.UvmCreateMs = b.Create.Milliseconds()
+				resp.UvmBootMs = b.Boot.Milliseconds()
+				resp.UvmGcsStartMs = b.GCSStart.Milliseconds()
+				resp.UvmNegotiateMs = b.Negotiate.Milliseconds()
+				resp.UvmFirstModifyMs = b.FirstModify.Milliseconds()
+			}
+		}
+		if pc, ok := t.(uvmStdioPortCounter); ok {
+			if p, err := pc.UVMStdioPorts(); err == nil {
+				resp.UvmStdioPortsInUse = int32(p.InUse)
+				resp.UvmStdioPortsAllocated = int32(p.Allocated)
+			}
+		}
+		if pl, ok := t.(uvmSCSIPoolLister); ok {
+			if pools, err := pl.UVMSCSIPools(); err == nil {
+				for _, p := range pools {
+					resp.UvmScsiPools = append(resp.UvmScsiPools, p.String())
+				}
+			}
+		}
+	}
+	return resp, nil
+}
+
 func (s *service) ComputeProcessorInfo(ctx context.Context, req *extendedtask.ComputeProcessorInfoRequest) (*extendedtask.ComputeProcessorInfoResponse, error) {
 	ctx, span := oc.StartSpan(ctx, "ComputeProcessorInfo")
 	defer span.End()
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	task "github.com/containerd/containerd/api/runtime/task/v2"
 	containerd_v1_types "github.com/containerd/containerd/api/types/task"
@@ -26,6 +27,8 @@ import (
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/shimdiag"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
 var empty = &emptypb.Empty{}
@@ -43,6 +46,16 @@ func (s *service) getPod() (shimPod, error) {
 	return raw.(shimPod), nil
 }
 
+// getPodGroup returns the pods of the UVM sharing group this shim is serving,
+// or `nil` if the shim is not serving a group.
+func (s *service) getPodGroup() *podGroup {
+	if !s.isSandbox {
+		return nil
+	}
+	g, _ := s.taskOrPod.Load().(*podGroup)
+	return g
+}
+
 // getTask returns a task matching `tid` or else returns `nil`. This properly
 // handles a task in a pod or a singular task shim.
 //
@@ -75,6 +88,17 @@ func (s *service) stateInternal(ctx context.Context, req *task.StateRequest) (*t
 	return e.Status(), nil
 }
 
+// scsiExhaustedError returns `err` as an [errdefs.ErrResourceExhausted] error if
+// it is due to the UVM having no free SCSI slots, which retrying the create
+// will not fix, with guidance on how to make room.
+func scsiExhaustedError(err error) error {
+	if !errors.Is(err, scsi.ErrNoAvailableLocation) {
+		return err
+	}
+	return fmt.Errorf("%w: %w: remove containers or volumes from the pod, or create the pod with more SCSI controllers with the %q annotation",
+		errdefs.ErrResourceExhausted, err, annotations.SCSIControllerCount)
+}
+
 func (s *service) createInternal(ctx context.Context, req *task.CreateTaskRequest) (*task.CreateTaskResponse, error) {
 	setupDebuggerEvent()
 
@@ -169,7 +193,7 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 			s.cl.Unlock()
 			t, err := pod.CreateTask(ctx, req, &spec)
 			if err != nil {
-				return nil, err
+				return nil, scsiExhaustedError(err)
 			}
 			e, _ := t.GetExec("")
 			resp.Pid = uint32(e.Pid())
@@ -178,7 +202,7 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 		pod, err = createPod(ctx, s.events, req, &spec)
 		if err != nil {
 			s.cl.Unlock()
-			return nil, err
+			return nil, scsiExhaustedError(err)
 		}
 		t, _ := pod.GetTask(req.ID)
 		e, _ := t.GetExec("")
@@ -188,7 +212,7 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 		t, err := newHcsStandaloneTask(ctx, s.events, req, &spec)
 		if err != nil {
 			s.cl.Unlock()
-			return nil, err
+			return nil, scsiExhaustedError(err)
 		}
 		e, _ := t.GetExec("")
 		resp.Pid = uint32(e.Pid())
@@ -371,6 +395,29 @@ func (s *service) diagShareInternal(ctx context.Context, req *shimdiag.ShareRequ
 	return &shimdiag.ShareResponse{}, nil
 }
 
+func (s *service) diagSnapshotInternal(ctx context.Context, req *shimdiag.SnapshotRequest) (*shimdiag.SnapshotResponse, error) {
+	id := req.ID
+	if id == "" {
+		id = s.tid
+	}
+	t, err := s.getTask(id)
+	if err != nil {
+		return nil, err
+	}
+	ss, ok := t.(scratchSnapshotter)
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotImplemented, "task with id: '%s' does not support snapshots", id)
+	}
+	path := req.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), fmt.Sprintf("%s-snapshot-%d", id, time.Now().UnixNano()))
+	}
+	if err := ss.SnapshotScratch(ctx, path); err != nil {
+		return nil, err
+	}
+	return &shimdiag.SnapshotResponse{Path: path}, nil
+}
+
 func (s *service) diagListExecs(task shimTask) ([]*shimdiag.Exec, error) {
 	var sdExecs []*shimdiag.Exec
 	execs, err := task.ListExecs()
@@ -524,9 +571,15 @@ func (s *service) connectInternal(ctx context.Context, req *task.ConnectRequest)
 }
 
 func (s *service) shutdownInternal(ctx context.Context, req *task.ShutdownRequest) (*emptypb.Empty, error) {
-	// Because a pod shim hosts multiple tasks only the init task can issue the
-	// shutdown request.
-	if req.ID != s.tid {
+	if g := s.getPodGroup(); g != nil {
+		// The shim of a group serves every pod of the group, so only shuts
+		// down once the last of them is deleted.
+		if g.len() > 0 {
+			return empty, nil
+		}
+	} else if req.ID != s.tid {
+		// Because a pod shim hosts multiple tasks only the init task can issue
+		// the shutdown request.
 		return empty, nil
 	}
 
@@ -6,12 +6,16 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 	v1 "github.com/containerd/cgroups/v3/cgroup1/stats"
 	task "github.com/containerd/containerd/api/runtime/task/v2"
+	"github.com/containerd/errdefs"
 	"github.com/pkg/errors"
 )
 
@@ -172,3 +176,22 @@ func Test_Service_shutdownInternal(t *testing.T) {
 		})
 	}
 }
+
+func Test_scsiExhaustedError(t *testing.T) {
+	err := scsiExhaustedError(fmt.Errorf("adding SCSI virtual disk mount: %w", &scsi.NoAvailableLocationError{Pool: "shared"}))
+	if !errdefs.IsResourceExhausted(err) {
+		t.Fatalf("expected resource exhausted error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), annotations.SCSIControllerCount) {
+		t.Fatalf("expected error to name the %s annotation, got %v", annotations.SCSIControllerCount, err)
+	}
+	var locErr *scsi.NoAvailableLocationError
+	if !errors.As(err, &locErr) || locErr.Pool != "shared" {
+		t.Fatalf("expected error to wrap the scsi.NoAvailableLocationError, got %v", err)
+	}
+
+	other := errors.New("other")
+	if err := scsiExhaustedError(other); err != other { //nolint:errorlint
+		t.Fatalf("expected %v, got %v", other, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/Microsoft/go-winio"
 	"github.com/Microsoft/hcsshim/internal/oci"
@@ -21,6 +22,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/windows"
 )
 
 var startCommand = cli.Command{
@@ -54,18 +56,29 @@ The start command can either start a new shim or return an address to an existin
 		// therefore is a process isolated Windows Container, a hypervisor
 		// isolated Windows Container, or a hypervisor isolated Linux Container
 		// on Windows.
+		//
+		// The sandbox of a pod in a UVM sharing group
+		// (`io.microsoft.cri.uvm-sharing-group`) is instead served by the shim
+		// of the group, which is launched for the first pod of the group. Its
+		// address is written for the pod as for any other pod, for the
+		// containers of the pod to find it. The shim of the group outlives the
+		// bundle of that pod, so it runs in a directory of the group instead.
 
 		const addrFmt = "\\\\.\\pipe\\ProtectedPrefix\\Administrators\\containerd-shim-%s-%s-pipe"
 
 		var (
 			address string
 			pid     int
+			// serveAddress is the address to serve a new shim on.
+			serveAddress = fmt.Sprintf(addrFmt, namespaceFlag, idFlag)
 		)
 
 		cwd, err := os.Getwd()
 		if err != nil {
 			return err
 		}
+		// serveDir is the directory to serve a new shim in.
+		serveDir := cwd
 
 		a, err := getSpecAnnotations(cwd)
 		if err != nil {
@@ -77,26 +90,49 @@ The start command can either start a new shim or return an address to an existin
 			return err
 		}
 
-		if ct == oci.KubernetesContainerTypeContainer {
+		switch ct {
+		case oci.KubernetesContainerTypeContainer:
 			address = fmt.Sprintf(addrFmt, namespaceFlag, sbid)
 
 			// Connect to the hosting shim and get the pid
-			c, err := winio.DialPipe(address, nil)
+			pid, err = connectShim(address, sbid)
 			if err != nil {
-				return errors.Wrap(err, "failed to connect to hosting shim")
+				// The pod may be served by the shim of its UVM sharing group,
+				// whose address is in the bundle of the pod's sandbox, next to
+				// the bundle of the container.
+				b, rerr := os.ReadFile(filepath.Join(filepath.Dir(cwd), sbid, "address"))
+				if rerr != nil || string(b) == address {
+					return err
+				}
+				address = string(b)
+				if pid, err = connectShim(address, sbid); err != nil {
+					return err
+				}
 			}
-			cl := ttrpc.NewClient(c, ttrpc.WithOnClose(func() { c.Close() }))
-			t := task.NewTaskClient(cl)
-			ctx := gocontext.Background()
-			req := &task.ConnectRequest{ID: sbid}
-			cr, err := t.Connect(ctx, req)
-
-			cl.Close()
-			c.Close()
-			if err != nil {
-				return errors.Wrap(err, "failed to get shim pid from hosting shim")
+		case oci.KubernetesContainerTypeSandbox:
+			if group := a[annotations.UVMSharingGroup]; group != "" {
+				if serveDir, err = groupDir(namespaceFlag, group); err != nil {
+					return err
+				}
+				if err := os.MkdirAll(serveDir, 0o700); err != nil {
+					return err
+				}
+				// The first pods of the group may be started concurrently, and
+				// only one shim can serve the pipe of the group, so the shim is
+				// connected to or started under the lock of the group.
+				unlock, err := lockFile(filepath.Join(serveDir, "start.lock"))
+				if err != nil {
+					return err
+				}
+				defer unlock()
+
+				// Join the shim of the group if it is serving another pod of
+				// the group already, or else serve it.
+				serveAddress = fmt.Sprintf(addrFmt, namespaceFlag, "group-"+group)
+				if pid, err = connectShim(serveAddress, idFlag); err == nil {
+					address = serveAddress
+				}
 			}
-			pid = int(cr.ShimPid)
 		}
 
 		// We need to serve a new one.
@@ -122,13 +158,13 @@ The start command can either start a new shim or return an address to an existin
 			defer r.Close()
 			defer w.Close()
 
-			f, err := os.Create(filepath.Join(cwd, "panic.log"))
+			f, err := os.Create(filepath.Join(serveDir, "panic.log"))
 			if err != nil {
 				return err
 			}
 			defer f.Close()
 
-			address = fmt.Sprintf(addrFmt, namespaceFlag, idFlag)
+			address = serveAddress
 			args := []string{
 				self,
 				"--namespace", namespaceFlag,
@@ -145,7 +181,7 @@ The start command can either start a new shim or return an address to an existin
 				Path:   self,
 				Args:   args,
 				Env:    os.Environ(),
-				Dir:    cwd,
+				Dir:    serveDir,
 				Stdin:  os.Stdin,
 				Stdout: w,
 				Stderr: f,
@@ -184,6 +220,60 @@ The start command can either start a new shim or return an address to an existin
 	},
 }
 
+// connectShim connects to the shim serving `address` for the task `id`, and
+// returns the pid of the shim.
+func connectShim(address, id string) (int, error) {
+	c, err := winio.DialPipe(address, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to connect to hosting shim")
+	}
+	cl := ttrpc.NewClient(c, ttrpc.WithOnClose(func() { c.Close() }))
+	t := task.NewTaskClient(cl)
+	ctx := gocontext.Background()
+	req := &task.ConnectRequest{ID: id}
+	cr, err := t.Connect(ctx, req)
+
+	cl.Close()
+	c.Close()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get shim pid from hosting shim")
+	}
+	return int(cr.ShimPid), nil
+}
+
+// groupDir returns the directory that the shim of UVM sharing group `group` in
+// `namespace` is served in. It is kept after the shim exits, for the next shim of
+// the group.
+func groupDir(namespace, group string) (string, error) {
+	if strings.ContainsAny(group, `\/:`) {
+		return "", errors.Errorf("invalid '%s' annotation: '%s'", annotations.UVMSharingGroup, group)
+	}
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		return "", errors.New("ProgramData is not set")
+	}
+	return filepath.Join(programData, "containerd-shim-runhcs-v1", namespace, "group-"+group), nil
+}
+
+// lockFile takes an exclusive lock of the file at `path`, creating it if needed,
+// and waits until it is taken. The returned function releases the lock, which is
+// also released if the process exits.
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to lock %s", path)
+	}
+	return func() {
+		_ = windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+		f.Close()
+	}, nil
+}
+
 // writeAddress writes an address file atomically
 func writeAddress(path, address string) error {
 	path, err := filepath.Abs(path)
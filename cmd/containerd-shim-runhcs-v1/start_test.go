@@ -0,0 +1,56 @@
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_groupDir(t *testing.T) {
+	t.Setenv("ProgramData", `C:\ProgramData`)
+	dir, err := groupDir("k8s.io", "g1")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if want := `C:\ProgramData\containerd-shim-runhcs-v1\k8s.io\group-g1`; dir != want {
+		t.Fatalf("expected group directory %q, got %q", want, dir)
+	}
+	for _, group := range []string{`a\b`, "a/b", "a:b"} {
+		if _, err := groupDir("k8s.io", group); err == nil {
+			t.Fatalf("expected group %q to be invalid", group)
+		}
+	}
+}
+
+func Test_lockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "start.lock")
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		unlock2, err := lockFile(path)
+		if err != nil {
+			t.Errorf("failed to lock again: %v", err)
+			return
+		}
+		unlock2()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("expected the lock to be held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the lock to be taken once released")
+	}
+}
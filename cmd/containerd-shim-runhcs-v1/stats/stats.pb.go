@@ -29,10 +29,15 @@ type Statistics struct {
 	//
 	//	*Statistics_Windows
 	//	*Statistics_Linux
-	Container     isStatistics_Container    `protobuf_oneof:"container"`
-	VM            *VirtualMachineStatistics `protobuf:"bytes,3,opt,name=vm,proto3" json:"vm,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	Container isStatistics_Container    `protobuf_oneof:"container"`
+	VM        *VirtualMachineStatistics `protobuf:"bytes,3,opt,name=vm,proto3" json:"vm,omitempty"`
+	// linux_scratch_usage_bytes is the disk space used by the scratch of an
+	// LCOW container with a scratch quota, as set by the
+	// io.microsoft.container.storage.rootfs.size-gb annotation. It is 0 for
+	// other containers.
+	LinuxScratchUsageBytes uint64 `protobuf:"varint,4,opt,name=linux_scratch_usage_bytes,json=linuxScratchUsageBytes,proto3" json:"linux_scratch_usage_bytes,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
 }
 
 func (x *Statistics) Reset() {
@@ -97,6 +102,13 @@ func (x *Statistics) GetVM() *VirtualMachineStatistics {
 	return nil
 }
 
+func (x *Statistics) GetLinuxScratchUsageBytes() uint64 {
+	if x != nil {
+		return x.LinuxScratchUsageBytes
+	}
+	return 0
+}
+
 type isStatistics_Container interface {
 	isStatistics_Container()
 }
@@ -121,6 +133,7 @@ type WindowsContainerStatistics struct {
 	Processor          *WindowsContainerProcessorStatistics `protobuf:"bytes,4,opt,name=processor,proto3" json:"processor,omitempty"`
 	Memory             *WindowsContainerMemoryStatistics    `protobuf:"bytes,5,opt,name=memory,proto3" json:"memory,omitempty"`
 	Storage            *WindowsContainerStorageStatistics   `protobuf:"bytes,6,opt,name=storage,proto3" json:"storage,omitempty"`
+	Limits             *WindowsContainerLimits              `protobuf:"bytes,7,opt,name=limits,proto3" json:"limits,omitempty"`
 	unknownFields      protoimpl.UnknownFields
 	sizeCache          protoimpl.SizeCache
 }
@@ -197,6 +210,13 @@ func (x *WindowsContainerStatistics) GetStorage() *WindowsContainerStorageStatis
 	return nil
 }
 
+func (x *WindowsContainerStatistics) GetLimits() *WindowsContainerLimits {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
 type WindowsContainerProcessorStatistics struct {
 	state           protoimpl.MessageState `protogen:"open.v1"`
 	TotalRuntimeNS  uint64                 `protobuf:"varint,1,opt,name=total_runtime_ns,json=totalRuntimeNs,proto3" json:"total_runtime_ns,omitempty"`
@@ -385,17 +405,103 @@ func (x *WindowsContainerStorageStatistics) GetWriteSizeBytes() uint64 {
 	return 0
 }
 
+// WindowsContainerLimits are the resource limits in effect on the container. They are
+// only reported for job containers, in the units of the job object. Limits that are not
+// set are zero.
+type WindowsContainerLimits struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Hard cap on processor usage, in 1/100ths of a percent of the host's processors.
+	ProcessorMaximum uint32 `protobuf:"varint,1,opt,name=processor_maximum,json=processorMaximum,proto3" json:"processor_maximum,omitempty"`
+	// Relative processor weight, from 1 to 9.
+	ProcessorWeight         uint32 `protobuf:"varint,2,opt,name=processor_weight,json=processorWeight,proto3" json:"processor_weight,omitempty"`
+	MemoryLimitBytes        uint64 `protobuf:"varint,3,opt,name=memory_limit_bytes,json=memoryLimitBytes,proto3" json:"memory_limit_bytes,omitempty"`
+	StorageIopsMaximum      uint64 `protobuf:"varint,4,opt,name=storage_iops_maximum,json=storageIopsMaximum,proto3" json:"storage_iops_maximum,omitempty"`
+	StorageBandwidthMaximum uint64 `protobuf:"varint,5,opt,name=storage_bandwidth_maximum,json=storageBandwidthMaximum,proto3" json:"storage_bandwidth_maximum,omitempty"`
+	unknownFields           protoimpl.UnknownFields
+	sizeCache               protoimpl.SizeCache
+}
+
+func (x *WindowsContainerLimits) Reset() {
+	*x = WindowsContainerLimits{}
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WindowsContainerLimits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowsContainerLimits) ProtoMessage() {}
+
+func (x *WindowsContainerLimits) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowsContainerLimits.ProtoReflect.Descriptor instead.
+func (*WindowsContainerLimits) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WindowsContainerLimits) GetProcessorMaximum() uint32 {
+	if x != nil {
+		return x.ProcessorMaximum
+	}
+	return 0
+}
+
+func (x *WindowsContainerLimits) GetProcessorWeight() uint32 {
+	if x != nil {
+		return x.ProcessorWeight
+	}
+	return 0
+}
+
+func (x *WindowsContainerLimits) GetMemoryLimitBytes() uint64 {
+	if x != nil {
+		return x.MemoryLimitBytes
+	}
+	return 0
+}
+
+func (x *WindowsContainerLimits) GetStorageIopsMaximum() uint64 {
+	if x != nil {
+		return x.StorageIopsMaximum
+	}
+	return 0
+}
+
+func (x *WindowsContainerLimits) GetStorageBandwidthMaximum() uint64 {
+	if x != nil {
+		return x.StorageBandwidthMaximum
+	}
+	return 0
+}
+
 type VirtualMachineStatistics struct {
-	state         protoimpl.MessageState             `protogen:"open.v1"`
-	Processor     *VirtualMachineProcessorStatistics `protobuf:"bytes,1,opt,name=processor,proto3" json:"processor,omitempty"`
-	Memory        *VirtualMachineMemoryStatistics    `protobuf:"bytes,2,opt,name=memory,proto3" json:"memory,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state     protoimpl.MessageState             `protogen:"open.v1"`
+	Processor *VirtualMachineProcessorStatistics `protobuf:"bytes,1,opt,name=processor,proto3" json:"processor,omitempty"`
+	Memory    *VirtualMachineMemoryStatistics    `protobuf:"bytes,2,opt,name=memory,proto3" json:"memory,omitempty"`
+	Storage   *VirtualMachineStorageStatistics   `protobuf:"bytes,3,opt,name=storage,proto3" json:"storage,omitempty"`
+	// network_egress_bandwidth_maximum is the cap, in bits per second, on the
+	// bandwidth of the traffic sent by each of the virtual machine's network
+	// endpoints, or 0 if it is not capped.
+	NetworkEgressBandwidthMaximum uint64 `protobuf:"varint,4,opt,name=network_egress_bandwidth_maximum,json=networkEgressBandwidthMaximum,proto3" json:"network_egress_bandwidth_maximum,omitempty"`
+	unknownFields                 protoimpl.UnknownFields
+	sizeCache                     protoimpl.SizeCache
 }
 
 func (x *VirtualMachineStatistics) Reset() {
 	*x = VirtualMachineStatistics{}
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[5]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -407,7 +513,7 @@ func (x *VirtualMachineStatistics) String() string {
 func (*VirtualMachineStatistics) ProtoMessage() {}
 
 func (x *VirtualMachineStatistics) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[5]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -420,7 +526,7 @@ func (x *VirtualMachineStatistics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VirtualMachineStatistics.ProtoReflect.Descriptor instead.
 func (*VirtualMachineStatistics) Descriptor() ([]byte, []int) {
-	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{5}
+	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *VirtualMachineStatistics) GetProcessor() *VirtualMachineProcessorStatistics {
@@ -437,6 +543,20 @@ func (x *VirtualMachineStatistics) GetMemory() *VirtualMachineMemoryStatistics {
 	return nil
 }
 
+func (x *VirtualMachineStatistics) GetStorage() *VirtualMachineStorageStatistics {
+	if x != nil {
+		return x.Storage
+	}
+	return nil
+}
+
+func (x *VirtualMachineStatistics) GetNetworkEgressBandwidthMaximum() uint64 {
+	if x != nil {
+		return x.NetworkEgressBandwidthMaximum
+	}
+	return 0
+}
+
 type VirtualMachineProcessorStatistics struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	TotalRuntimeNS uint64                 `protobuf:"varint,1,opt,name=total_runtime_ns,json=totalRuntimeNs,proto3" json:"total_runtime_ns,omitempty"`
@@ -446,7 +566,7 @@ type VirtualMachineProcessorStatistics struct {
 
 func (x *VirtualMachineProcessorStatistics) Reset() {
 	*x = VirtualMachineProcessorStatistics{}
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[6]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -458,7 +578,7 @@ func (x *VirtualMachineProcessorStatistics) String() string {
 func (*VirtualMachineProcessorStatistics) ProtoMessage() {}
 
 func (x *VirtualMachineProcessorStatistics) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[6]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -471,7 +591,7 @@ func (x *VirtualMachineProcessorStatistics) ProtoReflect() protoreflect.Message
 
 // Deprecated: Use VirtualMachineProcessorStatistics.ProtoReflect.Descriptor instead.
 func (*VirtualMachineProcessorStatistics) Descriptor() ([]byte, []int) {
-	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{6}
+	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *VirtualMachineProcessorStatistics) GetTotalRuntimeNS() uint64 {
@@ -492,7 +612,7 @@ type VirtualMachineMemoryStatistics struct {
 
 func (x *VirtualMachineMemoryStatistics) Reset() {
 	*x = VirtualMachineMemoryStatistics{}
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[7]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -504,7 +624,7 @@ func (x *VirtualMachineMemoryStatistics) String() string {
 func (*VirtualMachineMemoryStatistics) ProtoMessage() {}
 
 func (x *VirtualMachineMemoryStatistics) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[7]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -517,7 +637,7 @@ func (x *VirtualMachineMemoryStatistics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VirtualMachineMemoryStatistics.ProtoReflect.Descriptor instead.
 func (*VirtualMachineMemoryStatistics) Descriptor() ([]byte, []int) {
-	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{7}
+	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *VirtualMachineMemoryStatistics) GetWorkingSetBytes() uint64 {
@@ -541,6 +661,77 @@ func (x *VirtualMachineMemoryStatistics) GetVmMemory() *VirtualMachineMemory {
 	return nil
 }
 
+// VirtualMachineStorageStatistics are the storage IO counters of the virtual
+// machine since it started, as reported by HCS. They are not set on builds that
+// don't report them.
+type VirtualMachineStorageStatistics struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	ReadCountNormalized  uint64                 `protobuf:"varint,1,opt,name=read_count_normalized,json=readCountNormalized,proto3" json:"read_count_normalized,omitempty"`
+	ReadSizeBytes        uint64                 `protobuf:"varint,2,opt,name=read_size_bytes,json=readSizeBytes,proto3" json:"read_size_bytes,omitempty"`
+	WriteCountNormalized uint64                 `protobuf:"varint,3,opt,name=write_count_normalized,json=writeCountNormalized,proto3" json:"write_count_normalized,omitempty"`
+	WriteSizeBytes       uint64                 `protobuf:"varint,4,opt,name=write_size_bytes,json=writeSizeBytes,proto3" json:"write_size_bytes,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *VirtualMachineStorageStatistics) Reset() {
+	*x = VirtualMachineStorageStatistics{}
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VirtualMachineStorageStatistics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VirtualMachineStorageStatistics) ProtoMessage() {}
+
+func (x *VirtualMachineStorageStatistics) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VirtualMachineStorageStatistics.ProtoReflect.Descriptor instead.
+func (*VirtualMachineStorageStatistics) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *VirtualMachineStorageStatistics) GetReadCountNormalized() uint64 {
+	if x != nil {
+		return x.ReadCountNormalized
+	}
+	return 0
+}
+
+func (x *VirtualMachineStorageStatistics) GetReadSizeBytes() uint64 {
+	if x != nil {
+		return x.ReadSizeBytes
+	}
+	return 0
+}
+
+func (x *VirtualMachineStorageStatistics) GetWriteCountNormalized() uint64 {
+	if x != nil {
+		return x.WriteCountNormalized
+	}
+	return 0
+}
+
+func (x *VirtualMachineStorageStatistics) GetWriteSizeBytes() uint64 {
+	if x != nil {
+		return x.WriteSizeBytes
+	}
+	return 0
+}
+
 type VirtualMachineMemory struct {
 	state                 protoimpl.MessageState `protogen:"open.v1"`
 	AvailableMemory       int32                  `protobuf:"varint,1,opt,name=available_memory,json=availableMemory,proto3" json:"available_memory,omitempty"`
@@ -556,7 +747,7 @@ type VirtualMachineMemory struct {
 
 func (x *VirtualMachineMemory) Reset() {
 	*x = VirtualMachineMemory{}
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[8]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -568,7 +759,7 @@ func (x *VirtualMachineMemory) String() string {
 func (*VirtualMachineMemory) ProtoMessage() {}
 
 func (x *VirtualMachineMemory) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[8]
+	mi := &file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -581,7 +772,7 @@ func (x *VirtualMachineMemory) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VirtualMachineMemory.ProtoReflect.Descriptor instead.
 func (*VirtualMachineMemory) Descriptor() ([]byte, []int) {
-	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{8}
+	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *VirtualMachineMemory) GetAvailableMemory() int32 {
@@ -637,20 +828,22 @@ var File_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_
 
 const file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDesc = "" +
 	"\n" +
-	"Lgithub.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats/stats.proto\x12\x1acontainerd.runhcs.stats.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a<github.com/containerd/cgroups/v3/cgroup1/stats/metrics.proto\"\xee\x01\n" +
+	"Lgithub.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats/stats.proto\x12\x1acontainerd.runhcs.stats.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a<github.com/containerd/cgroups/v3/cgroup1/stats/metrics.proto\"\xa9\x02\n" +
 	"\n" +
 	"Statistics\x12R\n" +
 	"\awindows\x18\x01 \x01(\v26.containerd.runhcs.stats.v1.WindowsContainerStatisticsH\x00R\awindows\x129\n" +
 	"\x05linux\x18\x02 \x01(\v2!.io.containerd.cgroups.v1.MetricsH\x00R\x05linux\x12D\n" +
-	"\x02vm\x18\x03 \x01(\v24.containerd.runhcs.stats.v1.VirtualMachineStatisticsR\x02vmB\v\n" +
-	"\tcontainer\"\xcf\x03\n" +
+	"\x02vm\x18\x03 \x01(\v24.containerd.runhcs.stats.v1.VirtualMachineStatisticsR\x02vm\x129\n" +
+	"\x19linux_scratch_usage_bytes\x18\x04 \x01(\x04R\x16linuxScratchUsageBytesB\v\n" +
+	"\tcontainer\"\x9b\x04\n" +
 	"\x1aWindowsContainerStatistics\x128\n" +
 	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12L\n" +
 	"\x14container_start_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x12containerStartTime\x12\x1b\n" +
 	"\tuptime_ns\x18\x03 \x01(\x04R\buptimeNs\x12]\n" +
 	"\tprocessor\x18\x04 \x01(\v2?.containerd.runhcs.stats.v1.WindowsContainerProcessorStatisticsR\tprocessor\x12T\n" +
 	"\x06memory\x18\x05 \x01(\v2<.containerd.runhcs.stats.v1.WindowsContainerMemoryStatisticsR\x06memory\x12W\n" +
-	"\astorage\x18\x06 \x01(\v2=.containerd.runhcs.stats.v1.WindowsContainerStorageStatisticsR\astorage\"\xa3\x01\n" +
+	"\astorage\x18\x06 \x01(\v2=.containerd.runhcs.stats.v1.WindowsContainerStorageStatisticsR\astorage\x12J\n" +
+	"\x06limits\x18\a \x01(\v22.containerd.runhcs.stats.v1.WindowsContainerLimitsR\x06limits\"\xa3\x01\n" +
 	"#WindowsContainerProcessorStatistics\x12(\n" +
 	"\x10total_runtime_ns\x18\x01 \x01(\x04R\x0etotalRuntimeNs\x12&\n" +
 	"\x0fruntime_user_ns\x18\x02 \x01(\x04R\rruntimeUserNs\x12*\n" +
@@ -663,16 +856,29 @@ const file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stat
 	"\x15read_count_normalized\x18\x01 \x01(\x04R\x13readCountNormalized\x12&\n" +
 	"\x0fread_size_bytes\x18\x02 \x01(\x04R\rreadSizeBytes\x124\n" +
 	"\x16write_count_normalized\x18\x03 \x01(\x04R\x14writeCountNormalized\x12(\n" +
-	"\x10write_size_bytes\x18\x04 \x01(\x04R\x0ewriteSizeBytes\"\xcb\x01\n" +
+	"\x10write_size_bytes\x18\x04 \x01(\x04R\x0ewriteSizeBytes\"\x8c\x02\n" +
+	"\x16WindowsContainerLimits\x12+\n" +
+	"\x11processor_maximum\x18\x01 \x01(\rR\x10processorMaximum\x12)\n" +
+	"\x10processor_weight\x18\x02 \x01(\rR\x0fprocessorWeight\x12,\n" +
+	"\x12memory_limit_bytes\x18\x03 \x01(\x04R\x10memoryLimitBytes\x120\n" +
+	"\x14storage_iops_maximum\x18\x04 \x01(\x04R\x12storageIopsMaximum\x12:\n" +
+	"\x19storage_bandwidth_maximum\x18\x05 \x01(\x04R\x17storageBandwidthMaximum\"\xeb\x02\n" +
 	"\x18VirtualMachineStatistics\x12[\n" +
 	"\tprocessor\x18\x01 \x01(\v2=.containerd.runhcs.stats.v1.VirtualMachineProcessorStatisticsR\tprocessor\x12R\n" +
-	"\x06memory\x18\x02 \x01(\v2:.containerd.runhcs.stats.v1.VirtualMachineMemoryStatisticsR\x06memory\"M\n" +
+	"\x06memory\x18\x02 \x01(\v2:.containerd.runhcs.stats.v1.VirtualMachineMemoryStatisticsR\x06memory\x12U\n" +
+	"\astorage\x18\x03 \x01(\v2;.containerd.runhcs.stats.v1.VirtualMachineStorageStatisticsR\astorage\x12G\n" +
+	" network_egress_bandwidth_maximum\x18\x04 \x01(\x04R\x1dnetworkEgressBandwidthMaximum\"M\n" +
 	"!VirtualMachineProcessorStatistics\x12(\n" +
 	"\x10total_runtime_ns\x18\x01 \x01(\x04R\x0etotalRuntimeNs\"\xc9\x01\n" +
 	"\x1eVirtualMachineMemoryStatistics\x12*\n" +
 	"\x11working_set_bytes\x18\x01 \x01(\x04R\x0fworkingSetBytes\x12,\n" +
 	"\x12virtual_node_count\x18\x02 \x01(\rR\x10virtualNodeCount\x12M\n" +
-	"\tvm_memory\x18\x03 \x01(\v20.containerd.runhcs.stats.v1.VirtualMachineMemoryR\bvmMemory\"\xd0\x02\n" +
+	"\tvm_memory\x18\x03 \x01(\v20.containerd.runhcs.stats.v1.VirtualMachineMemoryR\bvmMemory\"\xdd\x01\n" +
+	"\x1fVirtualMachineStorageStatistics\x122\n" +
+	"\x15read_count_normalized\x18\x01 \x01(\x04R\x13readCountNormalized\x12&\n" +
+	"\x0fread_size_bytes\x18\x02 \x01(\x04R\rreadSizeBytes\x124\n" +
+	"\x16write_count_normalized\x18\x03 \x01(\x04R\x14writeCountNormalized\x12(\n" +
+	"\x10write_size_bytes\x18\x04 \x01(\x04R\x0ewriteSizeBytes\"\xd0\x02\n" +
 	"\x14VirtualMachineMemory\x12)\n" +
 	"\x10available_memory\x18\x01 \x01(\x05R\x0favailableMemory\x126\n" +
 	"\x17available_memory_buffer\x18\x02 \x01(\x05R\x15availableMemoryBuffer\x12'\n" +
@@ -695,37 +901,41 @@ func file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats
 	return file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDescData
 }
 
-var file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_goTypes = []any{
 	(*Statistics)(nil),                          // 0: containerd.runhcs.stats.v1.Statistics
 	(*WindowsContainerStatistics)(nil),          // 1: containerd.runhcs.stats.v1.WindowsContainerStatistics
 	(*WindowsContainerProcessorStatistics)(nil), // 2: containerd.runhcs.stats.v1.WindowsContainerProcessorStatistics
 	(*WindowsContainerMemoryStatistics)(nil),    // 3: containerd.runhcs.stats.v1.WindowsContainerMemoryStatistics
 	(*WindowsContainerStorageStatistics)(nil),   // 4: containerd.runhcs.stats.v1.WindowsContainerStorageStatistics
-	(*VirtualMachineStatistics)(nil),            // 5: containerd.runhcs.stats.v1.VirtualMachineStatistics
-	(*VirtualMachineProcessorStatistics)(nil),   // 6: containerd.runhcs.stats.v1.VirtualMachineProcessorStatistics
-	(*VirtualMachineMemoryStatistics)(nil),      // 7: containerd.runhcs.stats.v1.VirtualMachineMemoryStatistics
-	(*VirtualMachineMemory)(nil),                // 8: containerd.runhcs.stats.v1.VirtualMachineMemory
-	(*stats.Metrics)(nil),                       // 9: io.containerd.cgroups.v1.Metrics
-	(*timestamppb.Timestamp)(nil),               // 10: google.protobuf.Timestamp
+	(*WindowsContainerLimits)(nil),              // 5: containerd.runhcs.stats.v1.WindowsContainerLimits
+	(*VirtualMachineStatistics)(nil),            // 6: containerd.runhcs.stats.v1.VirtualMachineStatistics
+	(*VirtualMachineProcessorStatistics)(nil),   // 7: containerd.runhcs.stats.v1.VirtualMachineProcessorStatistics
+	(*VirtualMachineMemoryStatistics)(nil),      // 8: containerd.runhcs.stats.v1.VirtualMachineMemoryStatistics
+	(*VirtualMachineStorageStatistics)(nil),     // 9: containerd.runhcs.stats.v1.VirtualMachineStorageStatistics
+	(*VirtualMachineMemory)(nil),                // 10: containerd.runhcs.stats.v1.VirtualMachineMemory
+	(*stats.Metrics)(nil),                       // 11: io.containerd.cgroups.v1.Metrics
+	(*timestamppb.Timestamp)(nil),               // 12: google.protobuf.Timestamp
 }
 var file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_depIdxs = []int32{
 	1,  // 0: containerd.runhcs.stats.v1.Statistics.windows:type_name -> containerd.runhcs.stats.v1.WindowsContainerStatistics
-	9,  // 1: containerd.runhcs.stats.v1.Statistics.linux:type_name -> io.containerd.cgroups.v1.Metrics
-	5,  // 2: containerd.runhcs.stats.v1.Statistics.vm:type_name -> containerd.runhcs.stats.v1.VirtualMachineStatistics
-	10, // 3: containerd.runhcs.stats.v1.WindowsContainerStatistics.timestamp:type_name -> google.protobuf.Timestamp
-	10, // 4: containerd.runhcs.stats.v1.WindowsContainerStatistics.container_start_time:type_name -> google.protobuf.Timestamp
+	11, // 1: containerd.runhcs.stats.v1.Statistics.linux:type_name -> io.containerd.cgroups.v1.Metrics
+	6,  // 2: containerd.runhcs.stats.v1.Statistics.vm:type_name -> containerd.runhcs.stats.v1.VirtualMachineStatistics
+	12, // 3: containerd.runhcs.stats.v1.WindowsContainerStatistics.timestamp:type_name -> google.protobuf.Timestamp
+	12, // 4: containerd.runhcs.stats.v1.WindowsContainerStatistics.container_start_time:type_name -> google.protobuf.Timestamp
 	2,  // 5: containerd.runhcs.stats.v1.WindowsContainerStatistics.processor:type_name -> containerd.runhcs.stats.v1.WindowsContainerProcessorStatistics
 	3,  // 6: containerd.runhcs.stats.v1.WindowsContainerStatistics.memory:type_name -> containerd.runhcs.stats.v1.WindowsContainerMemoryStatistics
 	4,  // 7: containerd.runhcs.stats.v1.WindowsContainerStatistics.storage:type_name -> containerd.runhcs.stats.v1.WindowsContainerStorageStatistics
-	6,  // 8: containerd.runhcs.stats.v1.VirtualMachineStatistics.processor:type_name -> containerd.runhcs.stats.v1.VirtualMachineProcessorStatistics
-	7,  // 9: containerd.runhcs.stats.v1.VirtualMachineStatistics.memory:type_name -> containerd.runhcs.stats.v1.VirtualMachineMemoryStatistics
-	8,  // 10: containerd.runhcs.stats.v1.VirtualMachineMemoryStatistics.vm_memory:type_name -> containerd.runhcs.stats.v1.VirtualMachineMemory
-	11, // [11:11] is the sub-list for method output_type
-	11, // [11:11] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	5,  // 8: containerd.runhcs.stats.v1.WindowsContainerStatistics.limits:type_name -> containerd.runhcs.stats.v1.WindowsContainerLimits
+	7,  // 9: containerd.runhcs.stats.v1.VirtualMachineStatistics.processor:type_name -> containerd.runhcs.stats.v1.VirtualMachineProcessorStatistics
+	8,  // 10: containerd.runhcs.stats.v1.VirtualMachineStatistics.memory:type_name -> containerd.runhcs.stats.v1.VirtualMachineMemoryStatistics
+	9,  // 11: containerd.runhcs.stats.v1.VirtualMachineStatistics.storage:type_name -> containerd.runhcs.stats.v1.VirtualMachineStorageStatistics
+	10, // 12: containerd.runhcs.stats.v1.VirtualMachineMemoryStatistics.vm_memory:type_name -> containerd.runhcs.stats.v1.VirtualMachineMemory
+	13, // [13:13] is the sub-list for method output_type
+	13, // [13:13] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_init() }
@@ -743,7 +953,7 @@ func file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDesc), len(file_github_com_Microsoft_hcsshim_cmd_containerd_shim_runhcs_v1_stats_stats_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -5,12 +5,16 @@ package main
 import (
 	"context"
 	"errors"
+	"io"
 	"time"
 
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
+	"github.com/Microsoft/hcsshim/internal/gcs"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/shimdiag"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
 	"github.com/Microsoft/hcsshim/pkg/ctrdtaskapi"
 	task "github.com/containerd/containerd/api/runtime/task/v2"
 	"github.com/containerd/errdefs"
@@ -101,6 +105,68 @@ type shimTask interface {
 	Update(ctx context.Context, req *task.UpdateTaskRequest) error
 }
 
+// scratchSnapshotter is implemented by tasks whose scratch layer can be
+// snapshotted for diagnostics.
+type scratchSnapshotter interface {
+	// SnapshotScratch copies the task's scratch layer into a new layer at
+	// `path`, which must not already exist. The task is paused while the copy
+	// is taken, so that the snapshot is not affected by later writes.
+	SnapshotScratch(ctx context.Context, path string) error
+}
+
+// pausableContainer is implemented by containers that can be paused and
+// resumed, such as those backed by an HCS compute system.
+type pausableContainer interface {
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+}
+
+// mappedDirectoryModifier is implemented by containers whose mapped directories
+// can be changed while they run, such as process isolated containers backed by
+// an HCS compute system.
+type mappedDirectoryModifier interface {
+	ModifyMappedDirectory(ctx context.Context, add bool, hostPath, containerPath string, readOnly bool) error
+}
+
+// scsiStateDumper is implemented by tasks that own a host UVM and can dump the
+// state of its SCSI mounts for diagnostics.
+type scsiStateDumper interface {
+	// DumpSCSIState writes the SCSI mounts tracked for the host UVM to w.
+	//
+	// If the host is not hypervisor isolated returns `errTaskNotIsolated`.
+	DumpSCSIState(w io.Writer) error
+}
+
+// uvmBootTimer is implemented by tasks that own a host UVM and can report the
+// time taken to boot it for diagnostics.
+type uvmBootTimer interface {
+	// UVMBootTimes returns the time taken by each phase of booting the host
+	// UVM.
+	//
+	// If the host is not hypervisor isolated returns `errTaskNotIsolated`.
+	UVMBootTimes() (uvm.BootTimes, error)
+}
+
+// uvmStdioPortCounter is implemented by tasks that own a host UVM and can report
+// the vsock ports used by the stdio relays of its processes for diagnostics.
+type uvmStdioPortCounter interface {
+	// UVMStdioPorts returns the accounting of the stdio relay ports of the
+	// host UVM.
+	//
+	// If the host is not hypervisor isolated returns `errTaskNotIsolated`.
+	UVMStdioPorts() (gcs.StdioPortStats, error)
+}
+
+// uvmSCSIPoolLister is implemented by tasks that own a host UVM and can report
+// the allocation state of its SCSI controller pools for diagnostics.
+type uvmSCSIPoolLister interface {
+	// UVMSCSIPools returns the allocation state of the SCSI controller pools
+	// of the host UVM.
+	//
+	// If the host is not hypervisor isolated returns `errTaskNotIsolated`.
+	UVMSCSIPools() ([]scsi.PoolState, error)
+}
+
 type processorInfo struct {
 	count int32
 }
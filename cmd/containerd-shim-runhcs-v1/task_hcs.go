@@ -3,12 +3,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	eventstypes "github.com/containerd/containerd/api/events"
@@ -28,6 +33,7 @@ import (
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
 	"github.com/Microsoft/hcsshim/internal/cmd"
 	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcs"
 	"github.com/Microsoft/hcsshim/internal/guestpath"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/hcs/resourcepaths"
@@ -35,6 +41,7 @@ import (
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/hcsoci"
 	"github.com/Microsoft/hcsshim/internal/jobcontainers"
+	"github.com/Microsoft/hcsshim/internal/jobobject"
 	"github.com/Microsoft/hcsshim/internal/layers"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/memory"
@@ -46,6 +53,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/shimdiag"
 	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
 	"github.com/Microsoft/hcsshim/osversion"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
 	"github.com/Microsoft/hcsshim/pkg/ctrdtaskapi"
@@ -66,6 +74,11 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 			ct)
 	}
 
+	shimOpts, err := shimOptions(req)
+	if err != nil {
+		return nil, err
+	}
+
 	owner := filepath.Base(os.Args[0])
 
 	var parent *uvm.UtilityVM
@@ -78,6 +91,7 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 		switch opts.(type) {
 		case *uvm.OptionsLCOW:
 			lopts := (opts).(*uvm.OptionsLCOW)
+			lopts.TPMStateRoot = shimOpts.GetTpmStateRoot()
 			parent, err = uvm.CreateLCOW(ctx, lopts)
 			if err != nil {
 				return nil, err
@@ -88,6 +102,8 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 				layerFolders = s.Windows.LayerFolders
 			}
 			wopts := (opts).(*uvm.OptionsWCOW)
+			wopts.ConsolidateVSMBLayers = shimOpts.GetVsmbConsolidateLayers()
+			wopts.TPMStateRoot = shimOpts.GetTpmStateRoot()
 			wopts.BootFiles, err = layers.GetWCOWUVMBootFilesFromLayers(ctx, req.Rootfs, layerFolders)
 			if err != nil {
 				return nil, err
@@ -105,7 +121,7 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 		return nil, errors.Wrap(errdefs.ErrFailedPrecondition, "oci spec does not contain WCOW or LCOW spec")
 	}
 
-	shim, err := newHcsTask(ctx, events, parent, true, req, s)
+	shim, err := newHcsTask(ctx, events, parent, true, req, s, nil)
 	if err != nil {
 		if parent != nil {
 			parent.Close()
@@ -115,6 +131,19 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 	return shim, nil
 }
 
+// shimOptions returns the runhcs options of the task create request `req`, or
+// nil if it has none.
+func shimOptions(req *task.CreateTaskRequest) (*runhcsopts.Options, error) {
+	if req.Options == nil {
+		return nil, nil
+	}
+	v, err := typeurl.UnmarshalAny(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*runhcsopts.Options), nil
+}
+
 // createContainer is a generic call to return either a process/hypervisor isolated container, or a job container
 // based on what is set in the OCI spec.
 func createContainer(
@@ -126,6 +155,7 @@ func createContainer(
 	parent *uvm.UtilityVM,
 	shimOpts *runhcsopts.Options,
 	rootfs []*types.Mount,
+	netNSPrewarm *hcsoci.NetworkNamespacePrewarm,
 ) (cow.Container, *resources.Resources, error) {
 	var (
 		err       error
@@ -140,9 +170,18 @@ func createContainer(
 		layerFolders = s.Windows.LayerFolders
 	}
 	if s.Linux != nil {
-		lcowLayers, err = layers.ParseLCOWLayers(rootfs, layerFolders)
+		var extra []string
+		extra, err = oci.ParseContainerReadonlyLayers(s.Annotations)
+		if err != nil {
+			return nil, nil, err
+		}
+		lcowLayers, err = layers.ParseLCOWLayers(rootfs, layerFolders, layers.WithExtraLCOWLayers(shimOpts.GetLcowExtraLayerRoot(), extra))
 	} else {
-		wcowLayers, err = layers.ParseWCOWLayers(rootfs, layerFolders)
+		var layerOpts []layers.WCOWLayerOption
+		if extra := oci.ParseAnnotationCommaSeparated(annotations.WCOWExtraLayerFolders, s.Annotations); len(extra) > 0 {
+			layerOpts = append(layerOpts, layers.WithExtraLayers(shimOpts.GetWcowExtraLayerRoot(), extra))
+		}
+		wcowLayers, err = layers.ParseWCOWLayers(rootfs, layerFolders, layerOpts...)
 	}
 	if err != nil {
 		return nil, nil, err
@@ -156,13 +195,14 @@ func createContainer(
 		}
 	} else {
 		opts := &hcsoci.CreateOptions{
-			ID:               id,
-			Owner:            owner,
-			Spec:             s,
-			HostingSystem:    parent,
-			NetworkNamespace: netNS,
-			LCOWLayers:       lcowLayers,
-			WCOWLayers:       wcowLayers,
+			ID:                      id,
+			Owner:                   owner,
+			Spec:                    s,
+			HostingSystem:           parent,
+			NetworkNamespace:        netNS,
+			LCOWLayers:              lcowLayers,
+			WCOWLayers:              wcowLayers,
+			NetworkNamespacePrewarm: netNSPrewarm,
 		}
 
 		if shimOpts != nil {
@@ -180,13 +220,17 @@ func createContainer(
 // the `shimExecCreated` state and returns the task that tracks its lifetime.
 //
 // If `parent == nil` the container is created on the host.
+//
+// If `netNSPrewarm != nil` it is used as the container's network namespace,
+// instead of creating one.
 func newHcsTask(
 	ctx context.Context,
 	events publisher,
 	parent *uvm.UtilityVM,
 	ownsParent bool,
 	req *task.CreateTaskRequest,
-	s *specs.Spec) (_ shimTask, err error) {
+	s *specs.Spec,
+	netNSPrewarm *hcsoci.NetworkNamespacePrewarm) (_ shimTask, err error) {
 	log.G(ctx).WithFields(logrus.Fields{
 		"tid":        req.ID,
 		"ownsParent": ownsParent,
@@ -200,13 +244,9 @@ func newHcsTask(
 		netNS = s.Windows.Network.NetworkNamespace
 	}
 
-	var shimOpts *runhcsopts.Options
-	if req.Options != nil {
-		v, err := typeurl.UnmarshalAny(req.Options)
-		if err != nil {
-			return nil, err
-		}
-		shimOpts = v.(*runhcsopts.Options)
+	shimOpts, err := shimOptions(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// Default to an infinite timeout (zero value)
@@ -219,22 +259,48 @@ func newHcsTask(
 		return nil, err
 	}
 
-	container, resources, err := createContainer(ctx, req.ID, owner, netNS, s, parent, shimOpts, req.Rootfs)
+	// A process isolated WCOW pod has no UVM to cap the bandwidth of its
+	// endpoints, so its sandbox task caps them before the sandbox starts. The
+	// endpoints are removed with the pod if the sandbox cannot be created.
+	var egressNetNS string
+	var egressBandwidth uint64
+	if oci.IsWCOW(s) && !oci.IsJobContainer(s) && parent == nil && netNS != "" {
+		if ct, _, _ := oci.GetSandboxTypeAndID(s.Annotations); ct == oci.KubernetesContainerTypeSandbox {
+			egressNetNS = netNS
+			egressBandwidth, err = updatePodEgressBandwidth(ctx, netNS, 0, s.Annotations)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	container, resources, err := createContainer(ctx, req.ID, owner, netNS, s, parent, shimOpts, req.Rootfs, netNSPrewarm)
 	if err != nil {
 		return nil, err
 	}
 
+	var scratchLayerPath string
+	if oci.IsWCOW(s) && !oci.IsJobContainer(s) {
+		scratchLayerPath, err = layers.WCOWScratchLayerPath(req.Rootfs, s.Windows.LayerFolders)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	ht := &hcsTask{
-		events:         events,
-		id:             req.ID,
-		isWCOW:         oci.IsWCOW(s),
-		c:              container,
-		cr:             resources,
-		ownsHost:       ownsParent,
-		host:           parent,
-		closed:         make(chan struct{}),
-		taskSpec:       s,
-		ioRetryTimeout: ioRetryTimeout,
+		events:           events,
+		id:               req.ID,
+		isWCOW:           oci.IsWCOW(s),
+		c:                container,
+		cr:               resources,
+		ownsHost:         ownsParent,
+		host:             parent,
+		closed:           make(chan struct{}),
+		taskSpec:         s,
+		ioRetryTimeout:   ioRetryTimeout,
+		scratchLayerPath: scratchLayerPath,
+		egressNetNS:      egressNetNS,
+		egressBandwidth:  egressBandwidth,
 	}
 	ht.init = newHcsExec(
 		ctx,
@@ -246,6 +312,10 @@ func newHcsTask(
 		req.Bundle,
 		ht.isWCOW,
 		s.Process,
+		nil,
+		!ht.isWCOW && oci.ParseAnnotationsBool(ctx, s.Annotations, annotations.LCOWExecCreateWorkingDirectory, false),
+		nil,
+		nil,
 		io,
 	)
 
@@ -335,12 +405,50 @@ type hcsTask struct {
 	// closeHostOnce is used to close `host`. This will only be used if
 	// `ownsHost==true` and `host != nil`.
 	closeHostOnce sync.Once
+	// initKilled is set once the init process is signaled, so that its exit is
+	// not treated as unexpected.
+	initKilled atomic.Bool
 
 	// taskSpec represents the spec/configuration for this task.
 	taskSpec *specs.Spec
 
 	// ioRetryTimeout is the time for how long to try reconnecting to stdio pipes from containerd.
 	ioRetryTimeout time.Duration
+
+	// scratchLayerPath is the path to the scratch layer of a WCOW container, or
+	// `""` if its scratch cannot be snapshotted.
+	//
+	// It MUST be treated as read only in the lifetime of the task.
+	scratchLayerPath string
+	// snapshotLock serializes snapshots of the scratch layer, so that one
+	// snapshot cannot resume the container while another is being taken.
+	snapshotLock sync.Mutex
+
+	// mountsLock protects mounts.
+	mountsLock sync.Mutex
+	// mounts are the host directories mapped into the running WCOW container
+	// by updates, by their case-insensitive container path.
+	mounts map[string]*containerMount
+
+	// egressNetNS is the network namespace of a process isolated WCOW pod if
+	// this is its sandbox task, or `""` otherwise. The task caps the bandwidth
+	// of the traffic sent by the namespace's endpoints to `egressBandwidth`
+	// bits per second.
+	//
+	// It MUST be treated as read only in the lifetime of the task.
+	egressNetNS string
+	// egressLock protects egressBandwidth.
+	egressLock      sync.Mutex
+	egressBandwidth uint64
+}
+
+// containerMount is a host directory mapped into a running WCOW container by an
+// update.
+type containerMount struct {
+	settings hcsschema.MappedDirectory
+	// vsmb is the share of the host directory into the UVM of a hypervisor
+	// isolated container, or nil if the container is process isolated.
+	vsmb resources.ResourceCloser
 }
 
 func (ht *hcsTask) ID() string {
@@ -361,6 +469,37 @@ func (ht *hcsTask) CreateExec(ctx context.Context, req *task.ExecProcessRequest,
 		return errors.Wrapf(errdefs.ErrFailedPrecondition, "exec: '' in task: '%s' must be running to create additional execs", ht.id)
 	}
 
+	var (
+		shells              []string
+		createCwd           bool
+		extraCaps, dropCaps []string
+	)
+	if ht.isWCOW {
+		if err := validateExecCredentialSpec(ht.taskSpec, req); err != nil {
+			return err
+		}
+	} else {
+		var a map[string]string
+		if ht.taskSpec != nil {
+			a = ht.taskSpec.Annotations
+		}
+		if err := oci.ValidateLCOWLSMLabels(ctx, a, spec, ""); err != nil {
+			return errors.Wrapf(errdefs.ErrInvalidArgument, "exec: '%s' in task: '%s': %v", req.ExecID, ht.id, err)
+		}
+		oci.ClearLCOWLSMLabels(spec, nil)
+		if spec.Terminal {
+			shells = applyLCOWTerminalExecDefaults(ht.taskSpec, spec)
+		}
+		createCwd = oci.ParseAnnotationsBool(ctx, a, annotations.LCOWExecCreateWorkingDirectory, false)
+
+		ea, err := execSpecAnnotations(req)
+		if err != nil {
+			return err
+		}
+		extraCaps = oci.ParseAnnotationCommaSeparated(annotations.LCOWExecExtraCapabilities, ea)
+		dropCaps = oci.ParseAnnotationCommaSeparated(annotations.LCOWExecDropCapabilities, ea)
+	}
+
 	io, err := cmd.NewUpstreamIO(ctx, req.ID, req.Stdout, req.Stderr, req.Stdin, req.Terminal, ht.ioRetryTimeout)
 	if err != nil {
 		return err
@@ -376,6 +515,10 @@ func (ht *hcsTask) CreateExec(ctx context.Context, req *task.ExecProcessRequest,
 		ht.init.Status().Bundle,
 		ht.isWCOW,
 		spec,
+		shells,
+		createCwd,
+		extraCaps,
+		dropCaps,
 		io,
 	)
 
@@ -391,6 +534,104 @@ func (ht *hcsTask) CreateExec(ctx context.Context, req *task.ExecProcessRequest,
 		})
 }
 
+// defaultLCOWExecShells are the shells tried by a terminal exec in an LCOW
+// container that requests one of them and it does not exist, unless overridden
+// by the [annotations.LCOWExecShells] annotation.
+var defaultLCOWExecShells = []string{"/bin/sh", "/bin/bash", "/bin/ash", "/busybox/sh"}
+
+// applyLCOWTerminalExecDefaults sets the `TERM` and `PATH` environment variables
+// of the terminal exec `p` in an LCOW container if it does not set them, and
+// returns the shells to try if `p` requests a shell that does not exist in the
+// container.
+//
+// Minimal images often have no environment for interactive use, which leaves
+// `kubectl exec -it` sessions without a usable terminal.
+func applyLCOWTerminalExecDefaults(s *specs.Spec, p *specs.Process) []string {
+	var a map[string]string
+	if s != nil {
+		a = s.Annotations
+	}
+	hasEnv := func(key string) bool {
+		return slices.ContainsFunc(p.Env, func(e string) bool {
+			return strings.HasPrefix(e, key+"=")
+		})
+	}
+	if term := oci.ParseAnnotationsString(a, annotations.LCOWExecDefaultTerm, "xterm"); term != "" && !hasEnv("TERM") {
+		p.Env = append(p.Env, "TERM="+term)
+	}
+	if !hasEnv("PATH") {
+		p.Env = append(p.Env, "PATH="+guestpath.LCOWDefaultPathEnv)
+	}
+	if _, ok := a[annotations.LCOWExecShells]; !ok {
+		return defaultLCOWExecShells
+	}
+	return oci.ParseAnnotationCommaSeparated(annotations.LCOWExecShells, a)
+}
+
+// validateExecCredentialSpec validates the gMSA credential spec requested by
+// an exec with the [annotations.WCOWExecCredentialSpec] annotation, if any.
+//
+// The Container Credential Guard (CCG) instance for a container is created
+// along with the container, and processes in the container cannot use a different
+// instance. Therefore an exec can only request the credential spec that the
+// container was created with.
+func validateExecCredentialSpec(s *specs.Spec, req *task.ExecProcessRequest) error {
+	a, err := execSpecAnnotations(req)
+	if err != nil {
+		return err
+	}
+	execCredSpec := a[annotations.WCOWExecCredentialSpec]
+	if execCredSpec == "" {
+		return nil
+	}
+
+	var credSpec string
+	if s != nil && s.Windows != nil {
+		credSpec, _ = s.Windows.CredentialSpec.(string)
+	}
+	if credSpec == "" {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition,
+			"exec: '%s' in task: '%s' requested a credential spec, but the task was not created with one: credential guard instances cannot be added to a running container",
+			req.ExecID, req.ID)
+	}
+	if !equalCredentialSpecs(credSpec, execCredSpec) {
+		return errors.Wrapf(errdefs.ErrNotImplemented,
+			"exec: '%s' in task: '%s' requested a different credential spec than the task: credential guard instances cannot be multiplexed within a container",
+			req.ExecID, req.ID)
+	}
+	return nil
+}
+
+// execSpecAnnotations returns the annotations of the exec process spec in
+// `req`. Since OCI process specs do not have annotations, they are read from an
+// "annotations" field added to the JSON exec process spec, which containerd
+// passes to the shim unchanged.
+func execSpecAnnotations(req *task.ExecProcessRequest) (map[string]string, error) {
+	if req.Spec == nil {
+		return nil, nil
+	}
+	var p struct {
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
+	if err := json.Unmarshal(req.Spec.Value, &p); err != nil {
+		return nil, errors.Wrap(err, "request.Spec was not oci process")
+	}
+	return p.Annotations, nil
+}
+
+// equalCredentialSpecs returns if two credential specs are the same, ignoring
+// insignificant whitespace.
+func equalCredentialSpecs(a, b string) bool {
+	compact := func(s string) string {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(s)); err != nil {
+			return strings.TrimSpace(s)
+		}
+		return buf.String()
+	}
+	return compact(a) == compact(b)
+}
+
 func (ht *hcsTask) GetExec(eid string) (shimExec, error) {
 	if eid == "" {
 		return ht.init, nil
@@ -427,6 +668,9 @@ func (ht *hcsTask) KillExec(ctx context.Context, eid string, signal uint32, all
 	if all && eid != "" {
 		return errors.Wrapf(errdefs.ErrFailedPrecondition, "cannot signal all for non-empty exec: '%s'", eid)
 	}
+	if eid == "" {
+		ht.initKilled.Store(true)
+	}
 	if all {
 		// We are in a kill all on the init task. Signal everything.
 		ht.execs.Range(func(key, value interface{}) bool {
@@ -720,6 +964,30 @@ func (ht *hcsTask) close(ctx context.Context) {
 	})
 }
 
+// guestLogsTimeout is the maximum time to wait for the guest logs to be
+// collected when closing the hosting UVM. The logs are bounded, so this is only
+// reached if the guest is unresponsive.
+const guestLogsTimeout = 2 * time.Second
+
+// dumpGuestLogs logs the output of [uvm.UtilityVM.DumpGuestLogs] for the
+// hosting UVM, one entry per line.
+func (ht *hcsTask) dumpGuestLogs(ctx context.Context, exitStatus uint32) {
+	ctx, cancel := context.WithTimeout(ctx, guestLogsTimeout)
+	defer cancel()
+
+	var b strings.Builder
+	if err := ht.host.DumpGuestLogs(ctx, &b); err != nil {
+		log.G(ctx).WithError(err).Warn("failed to dump guest logs")
+	}
+	if b.Len() == 0 {
+		return
+	}
+	log.G(ctx).WithField("exitStatus", exitStatus).Warn("container exited unexpectedly, dumping guest logs")
+	for _, line := range strings.Split(strings.TrimRight(b.String(), "\r\n"), "\n") {
+		log.G(ctx).WithField("guestLog", strings.TrimRight(line, "\r")).Warn("guest log")
+	}
+}
+
 // closeHost safely closes the hosting UVM if this task is the owner. Once
 // closed and all resources released it events the `runtime.TaskExitEventTopic`
 // for all upstream listeners.
@@ -731,13 +999,19 @@ func (ht *hcsTask) closeHost(ctx context.Context) {
 	ht.closeHostOnce.Do(func() {
 		log.G(ctx).Debug("hcsTask::closeHostOnce")
 
+		exit := ht.init.Status()
 		if ht.ownsHost && ht.host != nil {
+			// the guest is still running, so capture its logs before shutting
+			// it down to help debug why the container failed, unless it exited
+			// because it was signaled
+			if exit.ExitStatus != 0 && !ht.initKilled.Load() {
+				ht.dumpGuestLogs(ctx, exit.ExitStatus)
+			}
 			if err := ht.host.Close(); err != nil {
 				log.G(ctx).WithError(err).Error("failed host vm shutdown")
 			}
 		}
 		// Send the `init` exec exit notification always.
-		exit := ht.init.Status()
 
 		if err := ht.events.publishEvent(
 			ctx,
@@ -763,6 +1037,7 @@ func (ht *hcsTask) ExecInHost(ctx context.Context, req *shimdiag.ExecProcessRequ
 		Stdin:    req.Stdin,
 		Stdout:   req.Stdout,
 		Stderr:   req.Stderr,
+		User:     req.User,
 	}
 
 	if ht.host == nil {
@@ -783,6 +1058,63 @@ func (ht *hcsTask) DumpGuestStacks(ctx context.Context) string {
 	return ""
 }
 
+func (ht *hcsTask) UVMBootTimes() (uvm.BootTimes, error) {
+	if ht.host == nil {
+		return uvm.BootTimes{}, errTaskNotIsolated
+	}
+	return ht.host.BootTimes(), nil
+}
+
+func (ht *hcsTask) UVMStdioPorts() (gcs.StdioPortStats, error) {
+	if ht.host == nil {
+		return gcs.StdioPortStats{}, errTaskNotIsolated
+	}
+	return ht.host.StdioPorts(), nil
+}
+
+func (ht *hcsTask) UVMSCSIPools() ([]scsi.PoolState, error) {
+	if ht.host == nil {
+		return nil, errTaskNotIsolated
+	}
+	return ht.host.SCSIManager.Pools()
+}
+
+func (ht *hcsTask) DumpSCSIState(w io.Writer) error {
+	if ht.host == nil {
+		return errTaskNotIsolated
+	}
+	return ht.host.SCSIManager.DumpState(w)
+}
+
+// SnapshotScratch pauses the container, copies its scratch layer into a new
+// layer at `path` and resumes the container.
+func (ht *hcsTask) SnapshotScratch(ctx context.Context, path string) (err error) {
+	if ht.scratchLayerPath == "" {
+		return errors.Wrap(errdefs.ErrNotImplemented, "scratch snapshots are only supported for WCOW containers")
+	}
+	pc, ok := ht.c.(pausableContainer)
+	if !ok {
+		return errors.Wrapf(errdefs.ErrNotImplemented, "container of type %T cannot be paused", ht.c)
+	}
+
+	ht.snapshotLock.Lock()
+	defer ht.snapshotLock.Unlock()
+
+	if err := pc.Pause(ctx); err != nil {
+		return errors.Wrap(err, "failed to pause container")
+	}
+	defer func() {
+		// the container must be resumed even if the snapshot request was cancelled
+		if rErr := pc.Resume(context.WithoutCancel(ctx)); rErr != nil {
+			log.G(ctx).WithError(rErr).Error("failed to resume container after snapshot")
+			if err == nil {
+				err = errors.Wrap(rErr, "failed to resume container")
+			}
+		}
+	}()
+	return layers.SnapshotWCOWScratch(ctx, ht.scratchLayerPath, path)
+}
+
 func (ht *hcsTask) Share(ctx context.Context, req *shimdiag.ShareRequest) error {
 	if ht.host == nil {
 		return errTaskNotIsolated
@@ -790,55 +1122,97 @@ func (ht *hcsTask) Share(ctx context.Context, req *shimdiag.ShareRequest) error
 	return ht.host.Share(ctx, req.HostPath, req.UvmPath, req.ReadOnly)
 }
 
-func hcsPropertiesToWindowsStats(props *hcsschema.Properties) *stats.Statistics_Windows {
+func hcsStatisticsToWindowsStats(st *hcsschema.Statistics) *stats.Statistics_Windows {
 	wcs := &stats.Statistics_Windows{Windows: &stats.WindowsContainerStatistics{}}
-	if props.Statistics != nil {
-		wcs.Windows.Timestamp = timestamppb.New(props.Statistics.Timestamp)
-		wcs.Windows.ContainerStartTime = timestamppb.New(props.Statistics.ContainerStartTime)
-		wcs.Windows.UptimeNS = props.Statistics.Uptime100ns * 100
-		if props.Statistics.Processor != nil {
+	if st != nil {
+		wcs.Windows.Timestamp = timestamppb.New(st.Timestamp)
+		wcs.Windows.ContainerStartTime = timestamppb.New(st.ContainerStartTime)
+		wcs.Windows.UptimeNS = st.Uptime100ns * 100
+		if st.Processor != nil {
 			wcs.Windows.Processor = &stats.WindowsContainerProcessorStatistics{
-				TotalRuntimeNS:  props.Statistics.Processor.TotalRuntime100ns * 100,
-				RuntimeUserNS:   props.Statistics.Processor.RuntimeUser100ns * 100,
-				RuntimeKernelNS: props.Statistics.Processor.RuntimeKernel100ns * 100,
+				TotalRuntimeNS:  st.Processor.TotalRuntime100ns * 100,
+				RuntimeUserNS:   st.Processor.RuntimeUser100ns * 100,
+				RuntimeKernelNS: st.Processor.RuntimeKernel100ns * 100,
 			}
 		}
-		if props.Statistics.Memory != nil {
+		if st.Memory != nil {
 			wcs.Windows.Memory = &stats.WindowsContainerMemoryStatistics{
-				MemoryUsageCommitBytes:            props.Statistics.Memory.MemoryUsageCommitBytes,
-				MemoryUsageCommitPeakBytes:        props.Statistics.Memory.MemoryUsageCommitPeakBytes,
-				MemoryUsagePrivateWorkingSetBytes: props.Statistics.Memory.MemoryUsagePrivateWorkingSetBytes,
+				MemoryUsageCommitBytes:            st.Memory.MemoryUsageCommitBytes,
+				MemoryUsageCommitPeakBytes:        st.Memory.MemoryUsageCommitPeakBytes,
+				MemoryUsagePrivateWorkingSetBytes: st.Memory.MemoryUsagePrivateWorkingSetBytes,
 			}
 		}
-		if props.Statistics.Storage != nil {
+		if st.Storage != nil {
 			wcs.Windows.Storage = &stats.WindowsContainerStorageStatistics{
-				ReadCountNormalized:  props.Statistics.Storage.ReadCountNormalized,
-				ReadSizeBytes:        props.Statistics.Storage.ReadSizeBytes,
-				WriteCountNormalized: props.Statistics.Storage.WriteCountNormalized,
-				WriteSizeBytes:       props.Statistics.Storage.WriteSizeBytes,
+				ReadCountNormalized:  st.Storage.ReadCountNormalized,
+				ReadSizeBytes:        st.Storage.ReadSizeBytes,
+				WriteCountNormalized: st.Storage.WriteCountNormalized,
+				WriteSizeBytes:       st.Storage.WriteSizeBytes,
 			}
 		}
 	}
 	return wcs
 }
 
-func (ht *hcsTask) Stats(ctx context.Context) (*stats.Statistics, error) {
-	s := &stats.Statistics{}
-	props, err := ht.c.PropertiesV2(ctx, hcsschema.PTStatistics)
-	if err != nil {
-		if isStatsNotFound(err) {
-			return nil, errors.Wrapf(errdefs.ErrNotFound, "failed to fetch stats: %s", err)
+// statisticsContainer is implemented by containers with a typed statistics query,
+// such as those backed by an HCS compute system.
+type statisticsContainer interface {
+	Statistics(ctx context.Context) (*hcsschema.Statistics, error)
+}
+
+// containerStats fills in the container statistics of `s`.
+func (ht *hcsTask) containerStats(ctx context.Context, s *stats.Statistics) error {
+	if sc, ok := ht.c.(statisticsContainer); ok && ht.isWCOW {
+		st, err := sc.Statistics(ctx)
+		if err != nil {
+			return err
 		}
-		return nil, err
+		s.Container = hcsStatisticsToWindowsStats(st)
+		return nil
 	}
 
+	props, err := ht.c.PropertiesV2(ctx, hcsschema.PTStatistics)
+	if err != nil {
+		return err
+	}
 	if props != nil {
 		if ht.isWCOW {
-			s.Container = hcsPropertiesToWindowsStats(props)
+			wcs := hcsStatisticsToWindowsStats(props.Statistics)
+			if jc, ok := ht.c.(*jobcontainers.JobContainer); ok {
+				// the limits are optional, so the rest of the statistics are still returned
+				if limits, err := jc.ResourceLimits(); err != nil {
+					log.G(ctx).WithError(err).Warn("failed to get job container resource limits")
+				} else {
+					wcs.Windows.Limits = jobLimitsToWindowsLimits(limits)
+				}
+			}
+			s.Container = wcs
 		} else {
 			s.Container = &stats.Statistics_Linux{Linux: props.Metrics}
+			s.LinuxScratchUsageBytes = props.ScratchUsageBytes
 		}
 	}
+	return nil
+}
+
+func jobLimitsToWindowsLimits(l *jobobject.JobLimits) *stats.WindowsContainerLimits {
+	return &stats.WindowsContainerLimits{
+		ProcessorMaximum:        l.CPULimit,
+		ProcessorWeight:         l.CPUWeight,
+		MemoryLimitBytes:        l.MemoryLimitInBytes,
+		StorageIopsMaximum:      uint64(l.MaxIOPS),
+		StorageBandwidthMaximum: uint64(l.MaxBandwidth),
+	}
+}
+
+func (ht *hcsTask) Stats(ctx context.Context) (*stats.Statistics, error) {
+	s := &stats.Statistics{}
+	if err := ht.containerStats(ctx, s); err != nil {
+		if isStatsNotFound(err) {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "failed to fetch stats: %s", err)
+		}
+		return nil, err
+	}
 	if ht.ownsHost && ht.host != nil {
 		vmStats, err := ht.host.Stats(ctx)
 		if err != nil && !isStatsNotFound(err) {
@@ -850,6 +1224,15 @@ func (ht *hcsTask) Stats(ctx context.Context) (*stats.Statistics, error) {
 }
 
 func (ht *hcsTask) Update(ctx context.Context, req *task.UpdateTaskRequest) error {
+	if _, ok := req.Annotations[annotations.LCOWHostAliases]; ok && !ht.isWCOW {
+		if err := ht.addLCOWHostAliases(ctx, req.Annotations); err != nil {
+			return err
+		}
+		if req.Resources == nil {
+			return nil
+		}
+	}
+
 	resources, err := typeurl.UnmarshalAny(req.Resources)
 	if err != nil {
 		return errors.Wrapf(err, "failed to unmarshal resources for container %s update request", req.ID)
@@ -863,9 +1246,44 @@ func (ht *hcsTask) Update(ctx context.Context, req *task.UpdateTaskRequest) erro
 		return ht.host.Update(ctx, resources, req.Annotations)
 	}
 
+	if ht.egressNetNS != "" {
+		ht.egressLock.Lock()
+		bps, err := updatePodEgressBandwidth(ctx, ht.egressNetNS, ht.egressBandwidth, req.Annotations)
+		ht.egressBandwidth = bps
+		ht.egressLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
 	return ht.updateTaskContainerResources(ctx, resources, req.Annotations)
 }
 
+// updatePodEgressBandwidth changes the cap on the bandwidth of the traffic sent
+// by each endpoint in the network namespace `netNS` of a process isolated WCOW
+// pod from `from` bits per second to the value of the
+// [annotations.NetworkQoSEgressBandwidthMaximum] annotation in `annots`, and
+// returns the cap. The cap is unchanged if the annotation is not set, or if the
+// update fails.
+func updatePodEgressBandwidth(ctx context.Context, netNS string, from uint64, annots map[string]string) (uint64, error) {
+	v, ok := annots[annotations.NetworkQoSEgressBandwidthMaximum]
+	if !ok {
+		return from, nil
+	}
+	bps, err := uvm.ParseEgressBandwidth(v)
+	if err != nil {
+		return from, err
+	}
+	endpoints, err := uvm.GetHCNNamespaceEndpoints(ctx, netNS)
+	if err != nil {
+		return from, fmt.Errorf("failed to get the endpoints of network namespace %s: %w", netNS, err)
+	}
+	if err := uvm.UpdateEndpointsEgressBandwidth(ctx, endpoints, from, bps); err != nil {
+		return from, err
+	}
+	return bps, nil
+}
+
 func (ht *hcsTask) updateTaskContainerResources(ctx context.Context, data interface{}, annotations map[string]string) error {
 	if ht.isWCOW {
 		switch resources := data.(type) {
@@ -913,6 +1331,38 @@ func isValidWindowsCPUResources(c *specs.WindowsCPUResources) bool {
 }
 
 func (ht *hcsTask) updateWCOWResources(ctx context.Context, resources *specs.WindowsResources, annotations map[string]string) error {
+	if resources.CPU != nil && !isValidWindowsCPUResources(resources.CPU) {
+		return fmt.Errorf("invalid cpu resources request for container %s: %v", ht.id, resources.CPU)
+	}
+	// Job containers apply the update to their job object directly, rather than through
+	// HCS, so every limit can be changed without recreating the container.
+	if jc, ok := ht.c.(*jobcontainers.JobContainer); ok {
+		return jc.Update(ctx, resources)
+	}
+	restore, err := ht.updateAdmission(ctx, hcsoci.WindowsUVMResources(resources))
+	if err != nil {
+		return err
+	}
+	if err := ht.updateWCOWLimits(ctx, resources); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+// updateAdmission updates the resources the task's container was admitted to
+// its uVM with to `res`, if the uVM admits containers, and returns a function
+// to restore them if the update fails.
+func (ht *hcsTask) updateAdmission(ctx context.Context, res uvm.ContainerResources) (func(), error) {
+	if ht.host == nil || !ht.host.AdmitsContainers() {
+		return func() {}, nil
+	}
+	return ht.host.UpdateContainerAdmission(ctx, ht.id, res)
+}
+
+// updateWCOWLimits applies the memory and processor limits in `resources` to
+// the task's container.
+func (ht *hcsTask) updateWCOWLimits(ctx context.Context, resources *specs.WindowsResources) error {
 	if resources.Memory != nil && resources.Memory.Limit != nil {
 		newMemorySizeInMB := *resources.Memory.Limit / memory.MiB
 		memoryLimit := hcsoci.NormalizeMemorySize(ctx, ht.id, newMemorySizeInMB)
@@ -921,9 +1371,6 @@ func (ht *hcsTask) updateWCOWResources(ctx context.Context, resources *specs.Win
 		}
 	}
 	if resources.CPU != nil {
-		if !isValidWindowsCPUResources(resources.CPU) {
-			return fmt.Errorf("invalid cpu resources request for container %s: %v", ht.id, resources.CPU)
-		}
 		if err := ht.updateWCOWContainerCPU(ctx, resources.CPU); err != nil {
 			return err
 		}
@@ -931,7 +1378,7 @@ func (ht *hcsTask) updateWCOWResources(ctx context.Context, resources *specs.Win
 	return nil
 }
 
-func (ht *hcsTask) updateLCOWResources(ctx context.Context, data interface{}, annotations map[string]string) error {
+func (ht *hcsTask) updateLCOWResources(ctx context.Context, data interface{}, a map[string]string) error {
 	resources, ok := data.(*specs.LinuxResources)
 	if !ok || resources == nil {
 		return errors.New("must have resources be non-nil and type *LinuxResources when updating a lcow container")
@@ -939,7 +1386,44 @@ func (ht *hcsTask) updateLCOWResources(ctx context.Context, data interface{}, an
 	settings := guestresource.LCOWContainerConstraints{
 		Linux: *resources,
 	}
-	return ht.requestUpdateContainer(ctx, "", settings)
+	if resources.CPU != nil && resources.CPU.Cpus != "" {
+		// have the guest validate the CPUs against the uVM and set the matching
+		// memory nodes
+		settings.PinnedCPUs = resources.CPU.Cpus
+	}
+	weight, err := oci.ParseContainerIOWeight(a)
+	if err != nil {
+		return err
+	}
+	settings.IOWeight = weight
+	restore, err := ht.updateAdmission(ctx, hcsoci.LinuxUVMResources(resources))
+	if err != nil {
+		return err
+	}
+	if err := ht.requestUpdateContainer(ctx, "", settings); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+// addLCOWHostAliases adds the host aliases in `annotations` to the hosts file
+// of the pod. The task must be the pod's sandbox.
+func (ht *hcsTask) addLCOWHostAliases(ctx context.Context, annotations map[string]string) error {
+	hostAliases, err := oci.ParseHostAliases(annotations)
+	if err != nil {
+		return err
+	}
+	if len(hostAliases) == 0 {
+		return nil
+	}
+	return ht.c.Modify(ctx, guestrequest.ModificationRequest{
+		ResourceType: guestresource.ResourceTypeHostAliases,
+		RequestType:  guestrequest.RequestTypeAdd,
+		Settings: guestresource.LCOWHostAliases{
+			HostAliases: hostAliases,
+		},
+	})
 }
 
 func (ht *hcsTask) requestUpdateContainer(ctx context.Context, resourcePath string, settings interface{}) error {
@@ -972,10 +1456,10 @@ func (ht *hcsTask) ProcessorInfo(ctx context.Context) (*processorInfo, error) {
 	}, nil
 }
 
-func (ht *hcsTask) requestAddContainerMount(ctx context.Context, resourcePath string, settings interface{}) error {
+func (ht *hcsTask) requestContainerMount(ctx context.Context, requestType guestrequest.RequestType, resourcePath string, settings interface{}) error {
 	modification := &hcsschema.ModifySettingRequest{
 		ResourcePath: resourcePath,
-		RequestType:  guestrequest.RequestTypeAdd,
+		RequestType:  requestType,
 		Settings:     settings,
 	}
 	return ht.c.Modify(ctx, modification)
@@ -1003,59 +1487,152 @@ func isMountTypeSupported(hostPath, mountType string) bool {
 	}
 }
 
-func (ht *hcsTask) updateWCOWContainerMount(ctx context.Context, resources *ctrdtaskapi.ContainerMount, annotations map[string]string) error {
+func (ht *hcsTask) updateWCOWContainerMount(ctx context.Context, mount *ctrdtaskapi.ContainerMount, annotations map[string]string) error {
 	// Hcsschema v2 should be supported
 	if osversion.Build() < osversion.RS5 {
 		// OSVerions < RS5 only support hcsshema v1
 		return fmt.Errorf("hcsschema v1 unsupported")
 	}
 
-	if resources.HostPath == "" || resources.ContainerPath == "" {
+	if mount.Remove {
+		if mount.ContainerPath == "" {
+			return fmt.Errorf("invalid OCI spec - a mount to remove must have a container path set")
+		}
+		return ht.removeWCOWContainerMount(ctx, mount.ContainerPath)
+	}
+
+	if mount.HostPath == "" || mount.ContainerPath == "" {
 		return fmt.Errorf("invalid OCI spec - a mount must have both host and container path set")
 	}
 
 	// Check for valid mount type
-	if !isMountTypeSupported(resources.HostPath, resources.Type) {
-		return fmt.Errorf("invalid mount type %v. Currently only host volumes/directories can be mounted to running containers", resources.Type)
+	if !isMountTypeSupported(mount.HostPath, mount.Type) {
+		return fmt.Errorf("invalid mount type %v. Currently only host volumes/directories can be mounted to running containers", mount.Type)
+	}
+
+	key := containerMountKey(mount.ContainerPath)
+	ht.mountsLock.Lock()
+	defer ht.mountsLock.Unlock()
+	if _, ok := ht.mounts[key]; ok {
+		return errors.Wrapf(errdefs.ErrAlreadyExists, "a mount was already added at %s", mount.ContainerPath)
 	}
 
+	m := &containerMount{}
 	if ht.host == nil {
 		// HCS has a bug where it does not correctly resolve file (not dir) paths
 		// if the path includes a symlink. Therefore, we resolve the path here before
 		// passing it in. The issue does not occur with VSMB, so don't need to worry
 		// about the isolated case.
-		hostPath, err := fs.ResolvePath(resources.HostPath)
+		hostPath, err := fs.ResolvePath(mount.HostPath)
 		if err != nil {
-			return errors.Wrapf(err, "failed to resolve path for hostPath %s", resources.HostPath)
+			return errors.Wrapf(err, "failed to resolve path for hostPath %s", mount.HostPath)
 		}
 
 		// process isolated windows container
-		settings := hcsschema.MappedDirectory{
+		m.settings = hcsschema.MappedDirectory{
 			HostPath:      hostPath,
-			ContainerPath: resources.ContainerPath,
-			ReadOnly:      resources.ReadOnly,
+			ContainerPath: mount.ContainerPath,
+			ReadOnly:      mount.ReadOnly,
 		}
-		if err := ht.requestAddContainerMount(ctx, resourcepaths.SiloMappedDirectoryResourcePath, settings); err != nil {
-			return errors.Wrapf(err, "failed to add mount to process isolated container")
+		if err := ht.modifyMappedDirectory(ctx, true, m.settings); err != nil {
+			return wrapContainerMountError(err, "failed to add mount to process isolated container")
 		}
 	} else {
 		// if it is a mount request for a running hyperV WCOW container, we should first mount volume to the
 		// UVM as a VSMB share and then mount to the running container using the src path as seen by the UVM
-		vsmbShare, guestPath, err := ht.host.AddVsmbAndGetSharePath(ctx, resources.HostPath, resources.ContainerPath, resources.ReadOnly)
+		vsmbShare, guestPath, err := ht.host.AddVsmbAndGetSharePath(ctx, mount.HostPath, mount.ContainerPath, mount.ReadOnly)
 		if err != nil {
 			return err
 		}
-		// Add mount to list of resources to be released on container cleanup
-		ht.cr.Add(vsmbShare)
+		m.vsmb = vsmbShare
 
-		settings := hcsschema.MappedDirectory{
+		m.settings = hcsschema.MappedDirectory{
 			HostPath:      guestPath,
-			ContainerPath: resources.ContainerPath,
-			ReadOnly:      resources.ReadOnly,
+			ContainerPath: mount.ContainerPath,
+			ReadOnly:      mount.ReadOnly,
 		}
-		if err := ht.requestAddContainerMount(ctx, resourcepaths.SiloMappedDirectoryResourcePath, settings); err != nil {
-			return errors.Wrapf(err, "failed to add mount to hyperV container")
+		if err := ht.modifyMappedDirectory(ctx, true, m.settings); err != nil {
+			if rerr := vsmbShare.Release(ctx); rerr != nil {
+				log.G(ctx).WithError(rerr).Warn("failed to release VSMB share")
+			}
+			return wrapContainerMountError(err, "failed to add mount to hyperV container")
+		}
+	}
+
+	if ht.mounts == nil {
+		ht.mounts = make(map[string]*containerMount)
+		// release the mounts still added when the container's resources are
+		// released on exit
+		ht.cr.Add(resources.ResourceCloserFunc(ht.releaseWCOWContainerMounts))
+	}
+	ht.mounts[key] = m
+	return nil
+}
+
+// removeWCOWContainerMount removes the mount at `containerPath` that was added
+// to the running container by an update.
+func (ht *hcsTask) removeWCOWContainerMount(ctx context.Context, containerPath string) error {
+	key := containerMountKey(containerPath)
+	ht.mountsLock.Lock()
+	defer ht.mountsLock.Unlock()
+	m, ok := ht.mounts[key]
+	if !ok {
+		return errors.Wrapf(errdefs.ErrNotFound, "no mount was added at %s", containerPath)
+	}
+
+	if err := ht.modifyMappedDirectory(ctx, false, m.settings); err != nil {
+		return wrapContainerMountError(err, "failed to remove mount from container")
+	}
+	delete(ht.mounts, key)
+	if m.vsmb != nil {
+		if err := m.vsmb.Release(ctx); err != nil {
+			return errors.Wrap(err, "failed to release VSMB share")
 		}
 	}
 	return nil
 }
+
+// releaseWCOWContainerMounts releases the host resources of the mounts still
+// added to the container once it has exited.
+func (ht *hcsTask) releaseWCOWContainerMounts(ctx context.Context) (err error) {
+	ht.mountsLock.Lock()
+	defer ht.mountsLock.Unlock()
+	for key, m := range ht.mounts {
+		if m.vsmb != nil {
+			if rerr := m.vsmb.Release(ctx); rerr != nil {
+				log.G(ctx).WithError(rerr).WithField("containerPath", m.settings.ContainerPath).Error("failed to release VSMB share")
+				err = rerr
+			}
+		}
+		delete(ht.mounts, key)
+	}
+	return err
+}
+
+// modifyMappedDirectory adds, or removes, the mapped directory `settings` of
+// the running container.
+func (ht *hcsTask) modifyMappedDirectory(ctx context.Context, add bool, settings hcsschema.MappedDirectory) error {
+	if md, ok := ht.c.(mappedDirectoryModifier); ok {
+		return md.ModifyMappedDirectory(ctx, add, settings.HostPath, settings.ContainerPath, settings.ReadOnly)
+	}
+	requestType := guestrequest.RequestTypeAdd
+	if !add {
+		requestType = guestrequest.RequestTypeRemove
+	}
+	return ht.requestContainerMount(ctx, requestType, resourcepaths.SiloMappedDirectoryResourcePath, settings)
+}
+
+// containerMountKey returns the key of a mount at `containerPath` in the mounts
+// added to a WCOW container, which are case-insensitive.
+func containerMountKey(containerPath string) string {
+	return strings.ToLower(filepath.Clean(containerPath))
+}
+
+// wrapContainerMountError wraps `err` with `msg`, and with `errdefs.ErrNotImplemented`
+// if the platform does not support changing the mounts of a running container.
+func wrapContainerMountError(err error, msg string) error {
+	if hcs.IsNotSupported(err) {
+		return errors.Wrapf(errdefs.ErrNotImplemented, "%s: %v", msg, err)
+	}
+	return errors.Wrap(err, msg)
+}
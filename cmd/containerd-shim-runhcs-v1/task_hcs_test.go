@@ -4,12 +4,20 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"math/rand"
+	"slices"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/containerd/containerd/api/runtime/task/v2"
 	"github.com/containerd/errdefs"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/Microsoft/hcsshim/internal/guestpath"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
 func setupTestHcsTask(t *testing.T) (*hcsTask, *testShimExec, *testShimExec) {
@@ -318,3 +326,127 @@ func Test_hcsTask_DeleteExec_2ndExecID_ExitedState_Success(t *testing.T) {
 	}
 	verifyDeleteSuccessValues(t, pid, status, at, second)
 }
+
+func Test_validateExecCredentialSpec(t *testing.T) {
+	const credSpec = `{"CmsPlugins":["ActiveDirectory"],"DomainJoinConfig":{"GMSA":"webapp01"}}`
+
+	newReq := func(t *testing.T, credSpec string) *task.ExecProcessRequest {
+		t.Helper()
+		p := struct {
+			specs.Process
+			Annotations map[string]string `json:"annotations,omitempty"`
+		}{}
+		if credSpec != "" {
+			p.Annotations = map[string]string{annotations.WCOWExecCredentialSpec: credSpec}
+		}
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("failed to marshal exec spec: %v", err)
+		}
+		return &task.ExecProcessRequest{
+			ID:     t.Name(),
+			ExecID: "exec",
+			Spec:   &anypb.Any{Value: b},
+		}
+	}
+	withCredSpec := &specs.Spec{Windows: &specs.Windows{CredentialSpec: credSpec}}
+
+	for _, tc := range []struct {
+		name         string
+		spec         *specs.Spec
+		execCredSpec string
+		expected     error
+	}{
+		{
+			name: "NoExecCredSpec",
+			spec: withCredSpec,
+		},
+		{
+			name:         "SameCredSpec",
+			spec:         withCredSpec,
+			execCredSpec: credSpec,
+		},
+		{
+			name: "SameCredSpecWhitespace",
+			spec: withCredSpec,
+			execCredSpec: `{
+	"CmsPlugins": ["ActiveDirectory"],
+	"DomainJoinConfig": {"GMSA": "webapp01"}
+}`,
+		},
+		{
+			name:         "DifferentCredSpec",
+			spec:         withCredSpec,
+			execCredSpec: `{"CmsPlugins":["ActiveDirectory"],"DomainJoinConfig":{"GMSA":"webapp02"}}`,
+			expected:     errdefs.ErrNotImplemented,
+		},
+		{
+			name:         "NoContainerCredSpec",
+			spec:         &specs.Spec{Windows: &specs.Windows{}},
+			execCredSpec: credSpec,
+			expected:     errdefs.ErrFailedPrecondition,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateExecCredentialSpec(tc.spec, newReq(t, tc.execCredSpec))
+			if tc.expected == nil {
+				if err != nil {
+					t.Fatalf("expected nil err, got: %v", err)
+				}
+				return
+			}
+			verifyExpectedError(t, nil, err, tc.expected)
+		})
+	}
+}
+
+func Test_applyLCOWTerminalExecDefaults(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		env         []string
+		expectedEnv []string
+		shells      []string
+	}{
+		{
+			name:        "Defaults",
+			expectedEnv: []string{"TERM=xterm", "PATH=" + guestpath.LCOWDefaultPathEnv},
+			shells:      defaultLCOWExecShells,
+		},
+		{
+			name:        "ExistingEnv",
+			env:         []string{"PATH=/bin", "TERM=vt100"},
+			expectedEnv: []string{"PATH=/bin", "TERM=vt100"},
+			shells:      defaultLCOWExecShells,
+		},
+		{
+			name: "Annotations",
+			annotations: map[string]string{
+				annotations.LCOWExecDefaultTerm: "xterm-256color",
+				annotations.LCOWExecShells:      "/busybox/sh,/bin/zsh",
+			},
+			env:         []string{"PATH=/bin"},
+			expectedEnv: []string{"PATH=/bin", "TERM=xterm-256color"},
+			shells:      []string{"/busybox/sh", "/bin/zsh"},
+		},
+		{
+			name: "Disabled",
+			annotations: map[string]string{
+				annotations.LCOWExecDefaultTerm: "",
+				annotations.LCOWExecShells:      "",
+			},
+			expectedEnv: []string{"PATH=" + guestpath.LCOWDefaultPathEnv},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &specs.Process{Terminal: true, Env: tc.env}
+			shells := applyLCOWTerminalExecDefaults(&specs.Spec{Annotations: tc.annotations}, p)
+			if !slices.Equal(p.Env, tc.expectedEnv) {
+				t.Fatalf("expected env %v, got %v", tc.expectedEnv, p.Env)
+			}
+			if !slices.Equal(shells, tc.shells) {
+				t.Fatalf("expected shells %v, got %v", tc.shells, shells)
+			}
+		})
+	}
+}
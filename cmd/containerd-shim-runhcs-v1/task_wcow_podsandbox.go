@@ -4,16 +4,19 @@ package main
 
 import (
 	"context"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
 	"github.com/Microsoft/hcsshim/internal/cmd"
+	"github.com/Microsoft/hcsshim/internal/gcs"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
 	"github.com/Microsoft/hcsshim/internal/shimdiag"
 	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
 	eventstypes "github.com/containerd/containerd/api/events"
 	task "github.com/containerd/containerd/api/runtime/task/v2"
 	"github.com/containerd/containerd/v2/core/runtime"
@@ -249,6 +252,7 @@ func (wpst *wcowPodSandboxTask) ExecInHost(ctx context.Context, req *shimdiag.Ex
 		Stdin:    req.Stdin,
 		Stdout:   req.Stdout,
 		Stderr:   req.Stderr,
+		User:     req.User,
 	}
 	if wpst.host == nil {
 		return 0, errTaskNotIsolated
@@ -268,6 +272,34 @@ func (wpst *wcowPodSandboxTask) DumpGuestStacks(ctx context.Context) string {
 	return ""
 }
 
+func (wpst *wcowPodSandboxTask) UVMBootTimes() (uvm.BootTimes, error) {
+	if wpst.host == nil {
+		return uvm.BootTimes{}, errTaskNotIsolated
+	}
+	return wpst.host.BootTimes(), nil
+}
+
+func (wpst *wcowPodSandboxTask) UVMStdioPorts() (gcs.StdioPortStats, error) {
+	if wpst.host == nil {
+		return gcs.StdioPortStats{}, errTaskNotIsolated
+	}
+	return wpst.host.StdioPorts(), nil
+}
+
+func (wpst *wcowPodSandboxTask) UVMSCSIPools() ([]scsi.PoolState, error) {
+	if wpst.host == nil {
+		return nil, errTaskNotIsolated
+	}
+	return wpst.host.SCSIManager.Pools()
+}
+
+func (wpst *wcowPodSandboxTask) DumpSCSIState(w io.Writer) error {
+	if wpst.host == nil {
+		return errTaskNotIsolated
+	}
+	return wpst.host.SCSIManager.DumpState(w)
+}
+
 func (wpst *wcowPodSandboxTask) Update(ctx context.Context, req *task.UpdateTaskRequest) error {
 	if wpst.host == nil {
 		return errTaskNotIsolated
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -26,6 +27,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/guest/kmsg"
 	"github.com/Microsoft/hcsshim/internal/guest/runtime/hcsv2"
 	"github.com/Microsoft/hcsshim/internal/guest/runtime/runc"
+	"github.com/Microsoft/hcsshim/internal/guest/seccomp"
 	"github.com/Microsoft/hcsshim/internal/guest/transport"
 	"github.com/Microsoft/hcsshim/internal/guestpath"
 	"github.com/Microsoft/hcsshim/internal/log"
@@ -34,6 +36,15 @@ import (
 	"github.com/Microsoft/hcsshim/pkg/securitypolicy"
 )
 
+const (
+	// maxConcurrentProcessesEnv is the environment variable that sets the
+	// maximum number of processes the GCS runs at once. Zero is unlimited.
+	maxConcurrentProcessesEnv = "GCS_MAX_CONCURRENT_PROCESSES"
+	// defaultMaxConcurrentProcesses is the maximum number of processes the GCS
+	// runs at once if maxConcurrentProcessesEnv is not set.
+	defaultMaxConcurrentProcesses = 256
+)
+
 func memoryLogFormat(metrics *cgroupstats.Metrics) logrus.Fields {
 	return logrus.Fields{
 		"memoryUsage":      metrics.Memory.Usage.Usage,
@@ -205,6 +216,9 @@ func main() {
 	initialPolicyStance := flag.String("initial-policy-stance",
 		"allow",
 		"Stance: allow, deny.")
+	seccompMode := flag.String("seccomp",
+		string(seccomp.ModeNone),
+		"Seccomp filter to apply to the GCS and every process it launches: none, kernel, or all. See the seccomp package for the syscalls each denies.")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "\nUsage of %s:\n", os.Args[0])
@@ -276,6 +290,27 @@ func main() {
 
 	log.SetScrubbing(*scrubLogs)
 
+	// Apply the seccomp filter before anything else is launched, so that every
+	// process in the uVM is constrained by it.
+	mode, err := seccomp.ParseMode(*seccompMode)
+	if err != nil {
+		logrus.WithError(err).Fatal("invalid seccomp mode")
+	}
+	if err := seccomp.Apply(mode); err != nil {
+		logrus.WithError(err).Fatal("failed to apply seccomp filter")
+	}
+
+	maxProcesses := uint64(defaultMaxConcurrentProcesses)
+	if v, ok := os.LookupEnv(maxConcurrentProcessesEnv); ok {
+		maxProcesses, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				maxConcurrentProcessesEnv: v,
+				logrus.ErrorKey:           err,
+			}).Fatal("invalid maximum number of concurrent processes")
+		}
+	}
+
 	baseLogPath := guestpath.LCOWRootPrefixInUVM
 
 	logrus.WithFields(logrus.Fields{
@@ -360,8 +395,10 @@ func main() {
 	}
 	mux := bridge.NewBridgeMux()
 	b := bridge.Bridge{
-		Handler:  mux,
-		EnableV4: *v4,
+		Handler:                mux,
+		EnableV4:               *v4,
+		MaxConcurrentProcesses: uint32(maxProcesses),
+		StartTime:              startTime,
 	}
 	h := hcsv2.NewHost(rtime, tport, initialEnforcer, logWriter)
 	// Initialize virtual pod support in the host
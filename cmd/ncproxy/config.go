@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
@@ -60,10 +61,11 @@ var configCommand = cli.Command{
 // defaultConfig generates a default ncproxy configuration file with every config option filled in.
 func defaultConfig() *config {
 	return &config{
-		TTRPCAddr:      "\\\\.\\pipe\\ncproxy-ttrpc",
-		GRPCAddr:       "127.0.0.1:6669",
-		NodeNetSvcAddr: "127.0.0.1:6668",
-		Timeout:        10,
+		TTRPCAddr:            "\\\\.\\pipe\\ncproxy-ttrpc",
+		GRPCAddr:             "127.0.0.1:6669",
+		NodeNetSvcAddr:       "127.0.0.1:6668",
+		Timeout:              10,
+		ReconcileGracePeriod: uint32(defaultReconcileGracePeriod / time.Second),
 	}
 }
 
@@ -75,6 +77,30 @@ type config struct {
 	// 0 represents no timeout and ncproxy will continuously try and connect in the
 	// background.
 	Timeout uint32 `json:"timeout,omitempty"`
+	// ReconcileInterval is the time in seconds between reconciliations of the HNS
+	// endpoints created by ncproxy with HNS, which delete the endpoints leaked by
+	// pods that no longer exist.
+	// 0, the default, disables periodic reconciliation, which can still be run with
+	// the ReconcileEndpoints API. Since reconciliation deletes endpoints, it must be
+	// enabled explicitly.
+	ReconcileInterval uint32 `json:"reconcile_interval,omitempty"`
+	// ReconcileGracePeriod is the time in seconds that an HNS endpoint created by
+	// ncproxy may be left out of a namespace, from when reconciliation first sees
+	// it out of one, before reconciliation deletes it.
+	// 0 uses the default of 10 minutes.
+	ReconcileGracePeriod uint32 `json:"reconcile_grace_period,omitempty"`
+	// ReconcileDryRun makes periodic reconciliation log the endpoints it would
+	// delete or adopt, without changing them.
+	ReconcileDryRun bool `json:"reconcile_dry_run,omitempty"`
+}
+
+// reconcileGracePeriod returns the time that an HNS endpoint created by ncproxy may
+// be left out of a namespace before reconciliation deletes it.
+func (c *config) reconcileGracePeriod() time.Duration {
+	if c.ReconcileGracePeriod == 0 {
+		return defaultReconcileGracePeriod
+	}
+	return time.Duration(c.ReconcileGracePeriod) * time.Second
 }
 
 // Returns config. If path is "" will check the default location of the config
@@ -5,6 +5,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/Microsoft/go-winio"
@@ -53,12 +54,23 @@ type grpcService struct {
 	// ncproxyNetworking is a database that stores the ncproxy networking networks
 	// and endpoints persistently.
 	ncpNetworkingStore *ncproxystore.NetworkingStore
+
+	// reconcileMu is held for writing while the HNS endpoints owned by ncproxy are
+	// reconciled, and for reading while they are created or deleted.
+	reconcileMu sync.RWMutex
+	// reconcileGracePeriod is the time that an HNS endpoint created by ncproxy may be
+	// left out of a namespace before reconciliation deletes it.
+	reconcileGracePeriod time.Duration
+	// reconcileDetached is when each owned endpoint out of a namespace was first
+	// seen out of one by reconciliation. It is protected by reconcileMu.
+	reconcileDetached map[string]time.Time
 }
 
 func newGRPCService(agentCache *computeAgentCache, ncproxyNetworking *ncproxystore.NetworkingStore) *grpcService {
 	return &grpcService{
 		containerIDToComputeAgent: agentCache,
 		ncpNetworkingStore:        ncproxyNetworking,
+		reconcileGracePeriod:      defaultReconcileGracePeriod,
 	}
 }
 
@@ -388,10 +400,32 @@ func (s *grpcService) CreateEndpoint(ctx context.Context, req *ncproxygrpc.Creat
 			}
 			return nil, errors.Wrapf(err, "failed to get network with name %q", reqEndpoint.NetworkName)
 		}
+
+		s.reconcileMu.RLock()
+		defer s.reconcileMu.RUnlock()
+
+		// record that ncproxy owns the endpoint before creating it, so that it can
+		// be adopted if ncproxy stops before its ID is recorded
+		owned := &ncproxystore.OwnedEndpoint{
+			Name:      reqEndpoint.Name,
+			NetworkID: network.Id,
+			Created:   time.Now(),
+		}
+		if err := s.ncpNetworkingStore.PutOwnedEndpoint(ctx, owned); err != nil {
+			return nil, errors.Wrapf(err, "failed to record endpoint %q as owned", reqEndpoint.Name)
+		}
 		ep, err := createHCNEndpoint(ctx, network, reqEndpoint)
 		if err != nil {
+			if dErr := s.ncpNetworkingStore.DeleteOwnedEndpoint(ctx, owned); dErr != nil {
+				log.G(ctx).WithField("endpointName", owned.Name).WithError(dErr).Warn("failed to delete owned endpoint record")
+			}
 			return nil, err
 		}
+		owned.ID = ep.Id
+		if err := s.ncpNetworkingStore.PutOwnedEndpoint(ctx, owned); err != nil {
+			// reconciliation will adopt the endpoint
+			log.G(ctx).WithField("endpointName", owned.Name).WithError(err).Warn("failed to record owned endpoint ID")
+		}
 		return &ncproxygrpc.CreateEndpointResponse{
 			ID: ep.Id,
 		}, nil
@@ -518,9 +552,13 @@ func (s *grpcService) DeleteEndpoint(ctx context.Context, req *ncproxygrpc.Delet
 			return nil, errors.Wrapf(err, "failed to get endpoint with name %q", req.Name)
 		}
 
+		s.reconcileMu.RLock()
+		defer s.reconcileMu.RUnlock()
+
 		if err = ep.Delete(); err != nil {
 			return nil, errors.Wrapf(err, "failed to delete endpoint with name %q", req.Name)
 		}
+		s.forgetOwnedEndpoint(ctx, ep.Id)
 	}
 	return &ncproxygrpc.DeleteEndpointResponse{}, nil
 }
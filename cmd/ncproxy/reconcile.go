@@ -0,0 +1,346 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/Microsoft/hcsshim/internal/log"
+	ncproxystore "github.com/Microsoft/hcsshim/internal/ncproxy/store"
+	"github.com/Microsoft/hcsshim/internal/oc"
+	ncproxygrpc "github.com/Microsoft/hcsshim/pkg/ncproxy/ncproxygrpc/v1"
+)
+
+// defaultReconcileGracePeriod is the default time that an HNS endpoint created by
+// ncproxy may be left out of a namespace before reconciliation deletes it.
+const defaultReconcileGracePeriod = 10 * time.Minute
+
+// reconcileAction is a change reconciliation makes to an HNS endpoint, or to the
+// record of ncproxy owning it.
+type reconcileAction struct {
+	// owned is nil for an endpoint not owned by ncproxy.
+	owned *ncproxystore.OwnedEndpoint
+	// endpoint is nil for an endpoint no longer in HNS.
+	endpoint *hcn.HostComputeEndpoint
+	reason   string
+}
+
+func (a *reconcileAction) toReconciledEndpoint() *ncproxygrpc.ReconciledEndpoint {
+	result := &ncproxygrpc.ReconciledEndpoint{Reason: a.reason}
+	if a.owned != nil {
+		result.Name = a.owned.Name
+		result.ID = a.owned.ID
+		result.NetworkID = a.owned.NetworkID
+	}
+	if a.endpoint != nil {
+		result.Name = a.endpoint.Name
+		result.ID = a.endpoint.Id
+		result.NetworkID = a.endpoint.HostComputeNetwork
+		result.Namespace = a.endpoint.HostComputeNamespace
+	}
+	return result
+}
+
+// endpointReconcilePlan is the changes needed to reconcile the HNS endpoints owned
+// by ncproxy with HNS.
+type endpointReconcilePlan struct {
+	// leaked are the owned endpoints that no longer belong to a pod, to be deleted.
+	leaked []*reconcileAction
+	// adopted are the endpoints ncproxy created but did not record the ID of.
+	adopted []*reconcileAction
+	// forgotten are the owned endpoints no longer in HNS, whose records are deleted.
+	forgotten []*reconcileAction
+	// unknown are the endpoints not owned by ncproxy, which are left alone.
+	unknown []*reconcileAction
+	// detached is when each owned endpoint that is not in a namespace was first
+	// seen out of one, by lowercase endpoint ID, to be passed to the next plan.
+	detached map[string]time.Time
+}
+
+// planEndpointReconcile diffs the HNS endpoints owned by ncproxy with the endpoints
+// and namespaces in HNS.
+//
+// An owned endpoint is leaked if the namespace it was added to no longer exists,
+// or if it has been out of a namespace for longer than `grace`. Only endpoints
+// recorded as owned by ncproxy are ever leaked.
+//
+// `detached` is the [endpointReconcilePlan.detached] of the previous plan. An
+// endpoint is out of a namespace since it was first seen out of one, so an
+// endpoint that is moved between namespaces, or that is not added to one until
+// long after it is created, is not deleted as soon as it is seen out of one.
+func planEndpointReconcile(
+	owned []*ncproxystore.OwnedEndpoint,
+	endpoints []hcn.HostComputeEndpoint,
+	namespaces []hcn.HostComputeNamespace,
+	detached map[string]time.Time,
+	now time.Time,
+	grace time.Duration,
+) *endpointReconcilePlan {
+	plan := &endpointReconcilePlan{detached: make(map[string]time.Time)}
+
+	// HNS IDs are GUIDs, which may differ in case
+	liveNamespaces := make(map[string]struct{}, len(namespaces))
+	for _, ns := range namespaces {
+		liveNamespaces[strings.ToLower(ns.Id)] = struct{}{}
+	}
+	endpointsByID := make(map[string]*hcn.HostComputeEndpoint, len(endpoints))
+	for i := range endpoints {
+		endpointsByID[strings.ToLower(endpoints[i].Id)] = &endpoints[i]
+	}
+	matched := make(map[string]struct{}, len(owned))
+	for _, o := range owned {
+		if o.ID != "" {
+			matched[strings.ToLower(o.ID)] = struct{}{}
+		}
+	}
+
+	for _, o := range owned {
+		if o.ID == "" {
+			// ncproxy stopped between creating the endpoint and recording its ID,
+			// adopt the endpoint if it is the only one it could have created
+			var candidates []*hcn.HostComputeEndpoint
+			for i := range endpoints {
+				ep := &endpoints[i]
+				if _, ok := matched[strings.ToLower(ep.Id)]; ok {
+					continue
+				}
+				if ep.Name == o.Name && strings.EqualFold(ep.HostComputeNetwork, o.NetworkID) {
+					candidates = append(candidates, ep)
+				}
+			}
+			switch {
+			case len(candidates) == 1:
+				matched[strings.ToLower(candidates[0].Id)] = struct{}{}
+				plan.adopted = append(plan.adopted, &reconcileAction{
+					owned:    o,
+					endpoint: candidates[0],
+					reason:   "endpoint was created by ncproxy but its ID was not recorded",
+				})
+			case len(candidates) == 0 && now.Sub(o.Created) > grace:
+				plan.forgotten = append(plan.forgotten, &reconcileAction{
+					owned:  o,
+					reason: "endpoint was never created in HNS",
+				})
+			}
+			continue
+		}
+
+		ep, ok := endpointsByID[strings.ToLower(o.ID)]
+		if !ok {
+			plan.forgotten = append(plan.forgotten, &reconcileAction{
+				owned:  o,
+				reason: "endpoint no longer exists in HNS",
+			})
+			continue
+		}
+		if ep.HostComputeNamespace != "" {
+			if _, ok := liveNamespaces[strings.ToLower(ep.HostComputeNamespace)]; !ok {
+				plan.leaked = append(plan.leaked, &reconcileAction{
+					owned:    o,
+					endpoint: ep,
+					reason:   fmt.Sprintf("namespace %s no longer exists", ep.HostComputeNamespace),
+				})
+			}
+			continue
+		}
+		id := strings.ToLower(ep.Id)
+		since, ok := detached[id]
+		if !ok {
+			since = now
+		}
+		plan.detached[id] = since
+		if now.Sub(since) > grace {
+			plan.leaked = append(plan.leaked, &reconcileAction{
+				owned:    o,
+				endpoint: ep,
+				reason:   fmt.Sprintf("endpoint has not been in a namespace for %v", grace),
+			})
+		}
+	}
+
+	for i := range endpoints {
+		if _, ok := matched[strings.ToLower(endpoints[i].Id)]; !ok {
+			plan.unknown = append(plan.unknown, &reconcileAction{
+				endpoint: &endpoints[i],
+				reason:   "endpoint is not owned by ncproxy",
+			})
+		}
+	}
+	return plan
+}
+
+// reconcileEndpoints diffs the HNS endpoints owned by ncproxy with HNS. It deletes
+// the owned endpoints leaked by pods that no longer exist, adopts the endpoints
+// that ncproxy created but did not record, and forgets the owned endpoints no
+// longer in HNS. Endpoints not owned by ncproxy are only reported.
+//
+// If `dryRun` is set, the changes are reported but not made.
+func (s *grpcService) reconcileEndpoints(ctx context.Context, dryRun bool) (*ncproxygrpc.ReconcileEndpointsResponse, error) {
+	// block endpoints from being created or deleted, so that the diff does not go
+	// stale before it is acted on
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+
+	owned, err := s.ncpNetworkingStore.ListOwnedEndpoints(ctx)
+	if err != nil && !errors.Is(err, ncproxystore.ErrBucketNotFound) {
+		return nil, errors.Wrap(err, "failed to get owned HNS endpoints")
+	}
+	endpoints, err := hcn.ListEndpoints()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get HNS endpoints")
+	}
+	namespaces, err := hcn.ListNamespaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get HNS namespaces")
+	}
+
+	plan := planEndpointReconcile(owned, endpoints, namespaces, s.reconcileDetached, time.Now(), s.reconcileGracePeriod)
+	s.reconcileDetached = plan.detached
+
+	result := &ncproxygrpc.ReconcileEndpointsResponse{DryRun: dryRun}
+	for _, a := range plan.unknown {
+		result.Unknown = append(result.Unknown, a.toReconciledEndpoint())
+	}
+	if dryRun {
+		for _, a := range plan.leaked {
+			result.Deleted = append(result.Deleted, a.toReconciledEndpoint())
+		}
+		for _, a := range plan.adopted {
+			result.Adopted = append(result.Adopted, a.toReconciledEndpoint())
+		}
+		for _, a := range plan.forgotten {
+			result.Forgotten = append(result.Forgotten, a.toReconciledEndpoint())
+		}
+		return result, nil
+	}
+
+	failed := func(a *reconcileAction, err error) {
+		e := a.toReconciledEndpoint()
+		e.Reason = fmt.Sprintf("%s: %v", a.reason, err)
+		result.Failed = append(result.Failed, e)
+	}
+	for _, a := range plan.leaked {
+		if err := a.endpoint.Delete(); err != nil {
+			failed(a, errors.Wrap(err, "failed to delete endpoint"))
+			continue
+		}
+		if err := s.ncpNetworkingStore.DeleteOwnedEndpoint(ctx, a.owned); err != nil {
+			// the record will be forgotten by the next reconciliation
+			log.G(ctx).WithField("endpointName", a.owned.Name).WithError(err).Warn("failed to delete owned endpoint record")
+		}
+		result.Deleted = append(result.Deleted, a.toReconciledEndpoint())
+	}
+	for _, a := range plan.adopted {
+		a.owned.ID = a.endpoint.Id
+		if err := s.ncpNetworkingStore.PutOwnedEndpoint(ctx, a.owned); err != nil {
+			failed(a, errors.Wrap(err, "failed to record endpoint ID"))
+			continue
+		}
+		result.Adopted = append(result.Adopted, a.toReconciledEndpoint())
+	}
+	for _, a := range plan.forgotten {
+		if err := s.ncpNetworkingStore.DeleteOwnedEndpoint(ctx, a.owned); err != nil {
+			failed(a, errors.Wrap(err, "failed to delete owned endpoint record"))
+			continue
+		}
+		result.Forgotten = append(result.Forgotten, a.toReconciledEndpoint())
+	}
+	return result, nil
+}
+
+// forgetOwnedEndpoint deletes the record of ncproxy owning the HNS endpoint `id`,
+// if there is one. Failures are only logged, as reconciliation will forget the
+// endpoint later.
+func (s *grpcService) forgetOwnedEndpoint(ctx context.Context, id string) {
+	owned, err := s.ncpNetworkingStore.ListOwnedEndpoints(ctx)
+	if err != nil {
+		if !errors.Is(err, ncproxystore.ErrBucketNotFound) {
+			log.G(ctx).WithField("endpointID", id).WithError(err).Warn("failed to get owned endpoint records")
+		}
+		return
+	}
+	for _, o := range owned {
+		if !strings.EqualFold(o.ID, id) {
+			continue
+		}
+		if err := s.ncpNetworkingStore.DeleteOwnedEndpoint(ctx, o); err != nil {
+			log.G(ctx).WithField("endpointID", id).WithError(err).Warn("failed to delete owned endpoint record")
+		}
+	}
+}
+
+func (s *grpcService) ReconcileEndpoints(ctx context.Context, req *ncproxygrpc.ReconcileEndpointsRequest) (_ *ncproxygrpc.ReconcileEndpointsResponse, err error) {
+	ctx, span := oc.StartSpan(ctx, "ReconcileEndpoints")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+
+	span.AddAttributes(
+		trace.BoolAttribute("dryRun", req.DryRun))
+
+	result, err := s.reconcileEndpoints(ctx, req.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	logReconciledEndpoints(ctx, result)
+	return result, nil
+}
+
+// reconcileEndpointsPeriodically reconciles the HNS endpoints owned by ncproxy with
+// HNS when it is called, and then every `interval` until `ctx` is done.
+//
+// HNS has no notifications of namespaces being deleted, so endpoints leaked by
+// pods are only found by polling.
+func (s *grpcService) reconcileEndpointsPeriodically(ctx context.Context, interval time.Duration, dryRun bool) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		result, err := s.reconcileEndpoints(ctx, dryRun)
+		if err != nil {
+			log.G(ctx).WithError(err).Warn("failed to reconcile HNS endpoints")
+		} else {
+			logReconciledEndpoints(ctx, result)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func logReconciledEndpoints(ctx context.Context, result *ncproxygrpc.ReconcileEndpointsResponse) {
+	entry := log.G(ctx).WithField("dryRun", result.DryRun)
+	for _, l := range []struct {
+		msg       string
+		endpoints []*ncproxygrpc.ReconciledEndpoint
+	}{
+		{"deleted leaked HNS endpoint", result.Deleted},
+		{"adopted HNS endpoint", result.Adopted},
+		{"forgot owned HNS endpoint", result.Forgotten},
+	} {
+		for _, e := range l.endpoints {
+			entry.WithFields(logrus.Fields{
+				"endpointName": e.Name,
+				"endpointID":   e.ID,
+				"reason":       e.Reason,
+			}).Info(l.msg)
+		}
+	}
+	for _, e := range result.Failed {
+		entry.WithFields(logrus.Fields{
+			"endpointName": e.Name,
+			"endpointID":   e.ID,
+			"reason":       e.Reason,
+		}).Warn("failed to reconcile HNS endpoint")
+	}
+	entry.WithField("count", len(result.Unknown)).Debug("left HNS endpoints not owned by ncproxy")
+}
@@ -0,0 +1,206 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	ncproxystore "github.com/Microsoft/hcsshim/internal/ncproxy/store"
+	ncproxygrpc "github.com/Microsoft/hcsshim/pkg/ncproxy/ncproxygrpc/v1"
+)
+
+func TestPlanEndpointReconcile(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	grace := 10 * time.Minute
+	recent, old := now.Add(-time.Minute), now.Add(-time.Hour)
+
+	namespaces := []hcn.HostComputeNamespace{{Id: "NS-LIVE"}}
+	endpoints := []hcn.HostComputeEndpoint{
+		{Id: "ep-live", Name: "live", HostComputeNetwork: "net", HostComputeNamespace: "ns-live"},
+		{Id: "ep-dead-ns", Name: "dead-ns", HostComputeNetwork: "net", HostComputeNamespace: "ns-dead"},
+		{Id: "ep-new", Name: "new", HostComputeNetwork: "net"},
+		{Id: "ep-unattached", Name: "unattached", HostComputeNetwork: "net"},
+		{Id: "ep-moved", Name: "moved", HostComputeNetwork: "net"},
+		{Id: "ep-orphan", Name: "orphan", HostComputeNetwork: "NET"},
+		{Id: "ep-other", Name: "other", HostComputeNetwork: "net", HostComputeNamespace: "ns-dead"},
+		{Id: "ep-dup-1", Name: "dup", HostComputeNetwork: "net"},
+		{Id: "ep-dup-2", Name: "dup", HostComputeNetwork: "net"},
+		{Id: "ep-other-net", Name: "other-net", HostComputeNetwork: "net2"},
+	}
+	owned := []*ncproxystore.OwnedEndpoint{
+		{Name: "live", ID: "EP-LIVE", NetworkID: "net", Created: old},
+		{Name: "dead-ns", ID: "ep-dead-ns", NetworkID: "net", Created: recent},
+		{Name: "new", ID: "ep-new", NetworkID: "net", Created: recent},
+		{Name: "unattached", ID: "ep-unattached", NetworkID: "net", Created: old},
+		// created long ago, but only just seen out of a namespace
+		{Name: "moved", ID: "ep-moved", NetworkID: "net", Created: old},
+		{Name: "gone", ID: "ep-gone", NetworkID: "net", Created: old},
+		{Name: "orphan", NetworkID: "net", Created: old},
+		{Name: "creating", NetworkID: "net", Created: recent},
+		{Name: "never-created", NetworkID: "net", Created: old},
+		{Name: "dup", NetworkID: "net", Created: old},
+		{Name: "other-net", NetworkID: "net", Created: recent},
+	}
+
+	detached := map[string]time.Time{
+		"ep-unattached": old,
+		// attached again since, so it is no longer tracked
+		"ep-live": old,
+	}
+
+	plan := planEndpointReconcile(owned, endpoints, namespaces, detached, now, grace)
+
+	names := func(actions []*reconcileAction) []string {
+		var result []string
+		for _, a := range actions {
+			if a.owned != nil {
+				result = append(result, a.owned.Name)
+			} else {
+				result = append(result, a.endpoint.Id)
+			}
+		}
+		return result
+	}
+	for _, tc := range []struct {
+		name     string
+		actions  []*reconcileAction
+		expected []string
+	}{
+		{"leaked", plan.leaked, []string{"dead-ns", "unattached"}},
+		{"adopted", plan.adopted, []string{"orphan"}},
+		{"forgotten", plan.forgotten, []string{"gone", "never-created"}},
+		{"unknown", plan.unknown, []string{"ep-other", "ep-dup-1", "ep-dup-2", "ep-other-net"}},
+	} {
+		if actual := names(tc.actions); !slices.Equal(actual, tc.expected) {
+			t.Errorf("expected %s endpoints %v, instead got %v", tc.name, tc.expected, actual)
+		}
+	}
+
+	if len(plan.adopted) == 1 && plan.adopted[0].endpoint.Id != "ep-orphan" {
+		t.Errorf("expected to adopt endpoint ep-orphan, instead got %s", plan.adopted[0].endpoint.Id)
+	}
+
+	expectedDetached := map[string]time.Time{
+		"ep-new":        now,
+		"ep-unattached": old,
+		"ep-moved":      now,
+	}
+	if !maps.Equal(plan.detached, expectedDetached) {
+		t.Errorf("expected detached endpoints %v, instead got %v", expectedDetached, plan.detached)
+	}
+
+	// the endpoint is leaked once it has been out of a namespace for the grace period
+	later := now.Add(grace + time.Second)
+	plan = planEndpointReconcile(owned, endpoints, namespaces, plan.detached, later, grace)
+	if actual := names(plan.leaked); !slices.Contains(actual, "moved") {
+		t.Errorf("expected endpoint moved to be leaked after the grace period, instead got %v", actual)
+	}
+}
+
+func TestReconcileEndpoints_HCN(t *testing.T) {
+	ctx := context.Background()
+
+	networkingStore, closer, err := createTestNetworkingStore()
+	if err != nil {
+		t.Fatalf("failed to create a test ncproxy networking store with %v", err)
+	}
+	defer closer()
+
+	// setup test ncproxy grpc service, with no grace period so that endpoints not
+	// in a namespace are leaked
+	agentCache := newComputeAgentCache()
+	gService := newGRPCService(agentCache, networkingStore)
+	gService.reconcileGracePeriod = 0
+
+	// test network
+	networkName := t.Name() + "-network"
+	network, err := createTestIPv4NATNetwork(networkName)
+	if err != nil {
+		t.Fatalf("failed to create test network with %v", err)
+	}
+	defer func() {
+		_ = network.Delete()
+	}()
+
+	// endpoint created by ncproxy
+	endpointName := t.Name() + "-endpoint"
+	req := &ncproxygrpc.CreateEndpointRequest{
+		EndpointSettings: &ncproxygrpc.EndpointSettings{
+			Settings: &ncproxygrpc.EndpointSettings_HcnEndpoint{
+				HcnEndpoint: &ncproxygrpc.HcnEndpointSettings{
+					Name:                  endpointName,
+					Macaddress:            "00-15-5D-52-C0-00",
+					Ipaddress:             "192.168.100.4",
+					IpaddressPrefixlength: 24,
+					NetworkName:           networkName,
+				},
+			},
+		},
+	}
+	resp, err := gService.CreateEndpoint(ctx, req)
+	if err != nil {
+		t.Fatalf("expected CreateEndpoint to return no error, instead got %v", err)
+	}
+	defer func() {
+		if ep, err := hcn.GetEndpointByID(resp.ID); err == nil {
+			_ = ep.Delete()
+		}
+	}()
+
+	// endpoint created by another agent, which must never be deleted
+	otherEndpoint, err := createTestEndpoint(t.Name()+"-other-endpoint", network.Id)
+	if err != nil {
+		t.Fatalf("failed to create test endpoint with %v", err)
+	}
+	defer func() {
+		_ = otherEndpoint.Delete()
+	}()
+
+	reconciled := func(target string, endpoints []*ncproxygrpc.ReconciledEndpoint) bool {
+		for _, e := range endpoints {
+			if e.ID == target {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		result, err := gService.ReconcileEndpoints(ctx, &ncproxygrpc.ReconcileEndpointsRequest{DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("expected ReconcileEndpoints to return no error, instead got %v", err)
+		}
+		if !reconciled(resp.ID, result.Deleted) {
+			t.Fatalf("expected endpoint %s to be deleted, instead got %+v", resp.ID, result)
+		}
+		if !reconciled(otherEndpoint.Id, result.Unknown) || reconciled(otherEndpoint.Id, result.Deleted) {
+			t.Fatalf("expected endpoint %s to be unknown, instead got %+v", otherEndpoint.Id, result)
+		}
+
+		_, err = hcn.GetEndpointByID(resp.ID)
+		if dryRun && err != nil {
+			t.Fatalf("expected dry run to keep endpoint %s, instead got %v", resp.ID, err)
+		}
+		if !dryRun {
+			if _, ok := err.(hcn.EndpointNotFoundError); !ok { //nolint:errorlint
+				t.Fatalf("expected endpoint %s to be deleted, instead got %v", resp.ID, err)
+			}
+		}
+		if _, err := hcn.GetEndpointByID(otherEndpoint.Id); err != nil {
+			t.Fatalf("expected endpoint %s to be kept, instead got %v", otherEndpoint.Id, err)
+		}
+	}
+
+	owned, err := networkingStore.ListOwnedEndpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 0 {
+		t.Fatalf("expected no owned endpoints, instead got %v", owned)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Microsoft/go-winio"
 	"github.com/Microsoft/hcsshim/internal/log"
@@ -37,6 +38,11 @@ type server struct {
 	// database store for ncproxynetworking networks and endpoints
 	// database for network name to ncproxy networking network
 	ncproxyNetworking *ncproxystore.NetworkingStore
+
+	gService *grpcService
+	// stopReconcile stops the periodic reconciliation of HNS endpoints, if it is
+	// running, and waits for it to return.
+	stopReconcile func()
 }
 
 func newServer(ctx context.Context, conf *config, dbPath string) (*server, error) {
@@ -65,7 +71,9 @@ func newServer(ctx context.Context, conf *config, dbPath string) (*server, error
 
 func (s *server) setup(ctx context.Context) (net.Listener, net.Listener, error) {
 	gService := newGRPCService(s.cache, s.ncproxyNetworking)
+	gService.reconcileGracePeriod = s.conf.reconcileGracePeriod()
 	ncproxygrpc.RegisterNetworkConfigProxyServer(s.grpc, gService)
+	s.gService = gService
 
 	// support the v0 ncproxy api
 	v0Wrapper := newV0ServiceWrapper(gService)
@@ -91,6 +99,9 @@ func (s *server) setup(ctx context.Context) (net.Listener, net.Listener, error)
 
 // best effort graceful shutdown of the grpc and ttrpc servers
 func (s *server) gracefulShutdown(ctx context.Context) {
+	if s.stopReconcile != nil {
+		s.stopReconcile()
+	}
 	s.grpc.GracefulStop()
 	if err := s.ttrpc.Shutdown(ctx); err != nil {
 		log.G(ctx).WithError(err).Error("failed to gracefully shutdown ttrpc server")
@@ -135,6 +146,26 @@ func (s *server) serve(ctx context.Context, ttrpcListener net.Listener, grpcList
 		defer grpcListener.Close()
 		serveErr <- trapClosedConnErr(s.grpc.Serve(grpcListener))
 	}()
+
+	if s.conf.ReconcileInterval > 0 {
+		interval := time.Duration(s.conf.ReconcileInterval) * time.Second
+		log.G(ctx).WithFields(logrus.Fields{
+			"interval":    interval,
+			"gracePeriod": s.gService.reconcileGracePeriod,
+			"dryRun":      s.conf.ReconcileDryRun,
+		}).Info("Reconciling HNS endpoints periodically")
+
+		reconcileCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		s.stopReconcile = func() {
+			cancel()
+			<-done
+		}
+		go func() {
+			defer close(done)
+			s.gService.reconcileEndpointsPeriodically(reconcileCtx, interval, s.conf.ReconcileDryRun)
+		}()
+	}
 }
 
 // reconnectComputeAgents handles reconnecting to existing compute agents on ncproxy
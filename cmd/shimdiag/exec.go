@@ -32,6 +32,7 @@ func (r rawConReader) Read(b []byte) (int, error) {
 }
 
 var execTty bool
+var execUser string
 var execCommand = cli.Command{
 	Name:      "exec",
 	Usage:     "Executes a command in a shim's hosting utility VM",
@@ -41,6 +42,10 @@ var execCommand = cli.Command{
 			Name:        "tty,t",
 			Usage:       "run with a terminal",
 			Destination: &execTty},
+		cli.StringFlag{
+			Name:        "user,u",
+			Usage:       "user to run the command as in a Linux utility VM, in the form user[:group]",
+			Destination: &execUser},
 	},
 	SkipArgReorder: true,
 	Before:         appargs.Validate(appargs.String, appargs.String, appargs.Rest(appargs.String)),
@@ -98,6 +103,7 @@ var execCommand = cli.Command{
 			Stdout:   stdout,
 			Stderr:   stderr,
 			Terminal: execTty,
+			User:     execUser,
 		})
 		if err != nil {
 			return err
@@ -21,6 +21,8 @@ func main() {
 		stacksCommand,
 		tasksCommand,
 		shareCommand,
+		stateCommand,
+		snapshotCommand,
 	}
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, err)
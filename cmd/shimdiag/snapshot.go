@@ -0,0 +1,52 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/internal/appargs"
+	"github.com/Microsoft/hcsshim/internal/shimdiag"
+	"github.com/urfave/cli"
+)
+
+// The snapshot is a copy of the container's scratch layer taken while the container is
+// paused, and can be exported like any other writable layer, eg. with `wclayer export`.
+
+var snapshotCommand = cli.Command{
+	Name:      "snapshot",
+	Usage:     "Snapshot the scratch layer of a WCOW container",
+	ArgsUsage: "[flags] <shim name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "task,t",
+			Usage: "The task to snapshot. Defaults to the shim's init task",
+		},
+		cli.StringFlag{
+			Name:  "path,p",
+			Usage: "The directory to create the snapshot in, which must not already exist",
+		},
+	},
+	Before: appargs.Validate(appargs.String),
+	Action: func(c *cli.Context) error {
+		shim, err := shimdiag.GetShim(c.Args()[0])
+		if err != nil {
+			return err
+		}
+
+		req := &shimdiag.SnapshotRequest{
+			ID:   c.String("task"),
+			Path: c.String("path"),
+		}
+
+		svc := shimdiag.NewShimDiagClient(shim)
+		resp, err := svc.DiagSnapshot(context.Background(), req)
+		if err != nil {
+			return fmt.Errorf("failed to snapshot scratch layer: %w", err)
+		}
+
+		fmt.Printf("Snapshot created at %s\n", resp.Path)
+		return nil
+	},
+}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/internal/appargs"
+	"github.com/Microsoft/hcsshim/internal/shimdiag"
+	"github.com/urfave/cli"
+)
+
+var stateCommand = cli.Command{
+	Name:      "state",
+	Usage:     "Dump the shim's HCS operation queue, UVM boot times, stdio port usage, SCSI pools and the resources of each pod sharing the UVM",
+	ArgsUsage: "<shim name>",
+	Before:    appargs.Validate(appargs.String),
+	Action: func(c *cli.Context) error {
+		shim, err := shimdiag.GetShim(c.Args()[0])
+		if err != nil {
+			return err
+		}
+		svc := shimdiag.NewShimDiagClient(shim)
+		resp, err := svc.DiagState(context.Background(), &shimdiag.StateRequest{})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Queued operations: %d\n", resp.QueuedOperations)
+		fmt.Printf("Active operations: %d\n", resp.ActiveOperations)
+		fmt.Printf("UVM create:        %dms\n", resp.UvmCreateMs)
+		fmt.Printf("UVM boot:          %dms\n", resp.UvmBootMs)
+		fmt.Printf("GCS start:         %dms\n", resp.UvmGcsStartMs)
+		fmt.Printf("GCS negotiate:     %dms\n", resp.UvmNegotiateMs)
+		fmt.Printf("First modify:      %dms\n", resp.UvmFirstModifyMs)
+		fmt.Printf("Stdio ports:       %d in use, %d allocated\n", resp.UvmStdioPortsInUse, resp.UvmStdioPortsAllocated)
+		for _, p := range resp.UvmScsiPools {
+			fmt.Printf("SCSI pool:         %s\n", p)
+		}
+		for _, p := range resp.Pods {
+			fmt.Printf("Pod:               %s\n", p)
+		}
+		return nil
+	},
+}
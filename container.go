@@ -9,8 +9,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Microsoft/go-winio/pkg/guid"
+
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/hcs/schema1"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/mergemaps"
 )
 
@@ -32,6 +35,12 @@ type NetworkStats = schema1.NetworkStats
 // Statistics is the structure returned by a statistics call on a container
 type Statistics = schema1.Statistics
 
+// StatisticsV2 is the structure returned by a StatisticsV2 call on a container
+type StatisticsV2 = hcsschema.Statistics
+
+// GuestConnection is the structure returned by a GuestConnectionInfo call on a container
+type GuestConnection = hcs.GuestConnection
+
 // ProcessList is the structure of an item returned by a ProcessList call on a container
 type ProcessListItem = schema1.ProcessListItem
 
@@ -55,6 +64,11 @@ const (
 // Supported resource types are Network and Request Types are Add/Remove
 type ResourceModificationRequestResponse = schema1.ResourceModificationRequestResponse
 
+var (
+	_ ContainerPropertiesV2      = &container{}
+	_ ContainerMappedDirectories = &container{}
+)
+
 type container struct {
 	system   *hcs.System
 	waitOnce sync.Once
@@ -186,6 +200,33 @@ func (container *container) ProcessList() ([]ProcessListItem, error) {
 	return properties.ProcessList, nil
 }
 
+// StatisticsV2 returns the runtime statistics of the container.
+func (container *container) StatisticsV2(ctx context.Context) (*StatisticsV2, error) {
+	s, err := container.system.Statistics(ctx)
+	if err != nil {
+		return nil, convertSystemError(err, container)
+	}
+	return s, nil
+}
+
+// GuestConnectionInfo returns the state of the connection between HCS and the container's guest.
+func (container *container) GuestConnectionInfo(ctx context.Context) (*GuestConnection, error) {
+	gc, err := container.system.GuestConnectionInfo(ctx)
+	if err != nil {
+		return nil, convertSystemError(err, container)
+	}
+	return gc, nil
+}
+
+// SiloGUID returns the GUID of the silo backing the container.
+func (container *container) SiloGUID(ctx context.Context) (guid.GUID, error) {
+	g, err := container.system.SiloGUID(ctx)
+	if err != nil {
+		return guid.GUID{}, convertSystemError(err, container)
+	}
+	return g, nil
+}
+
 // This is a legacy v1 call
 func (container *container) MappedVirtualDisks() (map[int]MappedVirtualDiskController, error) {
 	properties, err := container.system.Properties(context.Background(), schema1.PropertyTypeMappedVirtualDisk)
@@ -223,3 +264,8 @@ func (container *container) Close() error {
 func (container *container) Modify(config *ResourceModificationRequestResponse) error {
 	return convertSystemError(container.system.Modify(context.Background(), config), container)
 }
+
+// ModifyMappedDirectory adds, or removes, a host directory mapped into the running container.
+func (container *container) ModifyMappedDirectory(ctx context.Context, add bool, hostPath, containerPath string, readOnly bool) error {
+	return convertSystemError(container.system.ModifyMappedDirectory(ctx, add, hostPath, containerPath, readOnly), container)
+}
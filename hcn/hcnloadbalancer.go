@@ -4,6 +4,11 @@ package hcn
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
 	"github.com/Microsoft/hcsshim/internal/interop"
@@ -371,3 +376,179 @@ func AddLoadBalancer(endpoints []HostComputeEndpoint, flags LoadBalancerFlags, p
 
 	return loadBalancer.Create()
 }
+
+// LoadBalancerBuilder builds a HostComputeLoadBalancer, validating its settings before
+// it is created.
+//
+// Errors from the With* methods are deferred until Build or Create is called.
+type LoadBalancerBuilder struct {
+	loadBalancer HostComputeLoadBalancer
+	errs         []error
+}
+
+// NewLoadBalancer returns a builder for a HostComputeLoadBalancer.
+func NewLoadBalancer() *LoadBalancerBuilder {
+	return &LoadBalancerBuilder{}
+}
+
+// WithVIP adds a frontend VIP to the load balancer.
+func (b *LoadBalancerBuilder) WithVIP(vip string) *LoadBalancerBuilder {
+	if net.ParseIP(vip) == nil {
+		b.errs = append(b.errs, fmt.Errorf("invalid frontend VIP %q", vip))
+		return b
+	}
+	b.loadBalancer.FrontendVIPs = append(b.loadBalancer.FrontendVIPs, vip)
+	return b
+}
+
+// WithSourceVIP sets the source VIP of the load balancer.
+func (b *LoadBalancerBuilder) WithSourceVIP(vip string) *LoadBalancerBuilder {
+	if net.ParseIP(vip) == nil {
+		b.errs = append(b.errs, fmt.Errorf("invalid source VIP %q", vip))
+		return b
+	}
+	b.loadBalancer.SourceVIP = vip
+	return b
+}
+
+// WithBackends adds the endpoints with IDs `endpointIDs` as backends of the load balancer.
+func (b *LoadBalancerBuilder) WithBackends(endpointIDs ...string) *LoadBalancerBuilder {
+	for _, id := range endpointIDs {
+		if _, err := guid.FromString(id); err != nil {
+			b.errs = append(b.errs, fmt.Errorf("%w %q", errInvalidEndpointID, id))
+			continue
+		}
+		b.loadBalancer.HostComputeEndpoints = append(b.loadBalancer.HostComputeEndpoints, id)
+	}
+	return b
+}
+
+// WithPortMapping adds a port mapping to the load balancer.
+func (b *LoadBalancerBuilder) WithPortMapping(protocol uint32, internalPort, externalPort uint16, distribution LoadBalancerDistribution, flags LoadBalancerPortMappingFlags) *LoadBalancerBuilder {
+	b.loadBalancer.PortMappings = append(b.loadBalancer.PortMappings, LoadBalancerPortMapping{
+		Protocol:         protocol,
+		InternalPort:     internalPort,
+		ExternalPort:     externalPort,
+		DistributionType: distribution,
+		Flags:            flags,
+	})
+	return b
+}
+
+// WithDSR enables Direct Server Return (DSR) on the load balancer.
+func (b *LoadBalancerBuilder) WithDSR() *LoadBalancerBuilder {
+	b.loadBalancer.Flags |= LoadBalancerFlagsDSR
+	return b
+}
+
+// Build validates the load balancer settings against each other and the features supported
+// by HNS, and returns the HostComputeLoadBalancer to create.
+func (b *LoadBalancerBuilder) Build() (*HostComputeLoadBalancer, error) {
+	features, err := GetCachedSupportedFeatures()
+	if err != nil {
+		return nil, err
+	}
+	return b.build(features)
+}
+
+// Create builds and creates the load balancer.
+func (b *LoadBalancerBuilder) Create() (*HostComputeLoadBalancer, error) {
+	loadBalancer, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return loadBalancer.Create()
+}
+
+func (b *LoadBalancerBuilder) build(features SupportedFeatures) (*HostComputeLoadBalancer, error) {
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+	loadBalancer := b.loadBalancer
+	loadBalancer.FrontendVIPs = slices.Clone(b.loadBalancer.FrontendVIPs)
+	loadBalancer.HostComputeEndpoints = slices.Clone(b.loadBalancer.HostComputeEndpoints)
+	loadBalancer.PortMappings = slices.Clone(b.loadBalancer.PortMappings)
+
+	if !features.Api.V2 {
+		return nil, platformDoesNotSupportError("V2 Api/Schema")
+	}
+	loadBalancer.SchemaVersion = V2SchemaVersion()
+
+	if len(loadBalancer.PortMappings) == 0 {
+		return nil, errors.New("load balancer must have at least one port mapping")
+	}
+
+	// all VIPs must be of the same IP family
+	ipv6 := false
+	vips := slices.Clone(loadBalancer.FrontendVIPs)
+	if loadBalancer.SourceVIP != "" {
+		vips = append(vips, loadBalancer.SourceVIP)
+	}
+	for i, vip := range vips {
+		v6 := net.ParseIP(vip).To4() == nil
+		if i == 0 {
+			ipv6 = v6
+		} else if v6 != ipv6 {
+			return nil, fmt.Errorf("load balancer VIPs %v mix IPv4 and IPv6 addresses", vips)
+		}
+	}
+	if ipv6 {
+		if !features.IPv6DualStack {
+			return nil, platformDoesNotSupportError("IPv6 DualStack")
+		}
+		loadBalancer.Flags |= LoadBalancerFlagsIPv6
+	}
+
+	if loadBalancer.Flags&LoadBalancerFlagsDSR != 0 {
+		if !features.DSR {
+			return nil, platformDoesNotSupportError("Direct Server Return (DSR)")
+		}
+		// HNS rejects internal load balancers that use DSR
+		for _, pm := range loadBalancer.PortMappings {
+			if pm.Flags&LoadBalancerPortMappingFlagsILB != 0 {
+				return nil, fmt.Errorf("port mapping %d->%d: internal load balancing cannot be used with Direct Server Return (DSR)",
+					pm.ExternalPort, pm.InternalPort)
+			}
+		}
+	}
+
+	return &loadBalancer, nil
+}
+
+// UpdateBackends sets the backends of the load balancer to the endpoints with IDs `endpointIDs`.
+//
+// If the backends are unchanged, no call is made to HNS. Otherwise, the load balancer is
+// modified in place, and an error is returned if HNS does not support modifying load balancers.
+func (loadBalancer *HostComputeLoadBalancer) UpdateBackends(endpointIDs []string) (*HostComputeLoadBalancer, error) {
+	added, removed := diffLoadBalancerBackends(loadBalancer.HostComputeEndpoints, endpointIDs)
+	logrus.Debugf("hcn::HostComputeLoadBalancer::UpdateBackends id=%s added=%v removed=%v", loadBalancer.Id, added, removed)
+	if len(added) == 0 && len(removed) == 0 {
+		return loadBalancer, nil
+	}
+
+	if err := ModifyLoadbalancerSupported(); err != nil {
+		return nil, err
+	}
+	updated := *loadBalancer
+	updated.HostComputeEndpoints = slices.Clone(endpointIDs)
+	return updated.Update(loadBalancer.Id)
+}
+
+// diffLoadBalancerBackends returns the endpoint IDs in `desired` that are not in `current`, and
+// the IDs in `current` that are not in `desired`. IDs are compared case-insensitively.
+func diffLoadBalancerBackends(current, desired []string) (added, removed []string) {
+	has := func(ids []string, id string) bool {
+		return slices.ContainsFunc(ids, func(s string) bool { return strings.EqualFold(s, id) })
+	}
+	for _, id := range desired {
+		if !has(current, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current {
+		if !has(desired, id) {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
@@ -6,6 +6,7 @@ package hcn
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"testing"
 )
 
@@ -320,3 +321,186 @@ func TestAddILBLoadBalancer(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLoadBalancerBuilderCreateUpdateBackends(t *testing.T) {
+	network, err := CreateTestOverlayNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, err := HcnCreateTestEndpoint(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint2, err := HcnCreateTestEndpoint(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loadBalancer, err := NewLoadBalancer().
+		WithSourceVIP("10.0.0.1").
+		WithVIP("1.1.1.2").
+		WithBackends(endpoint.Id).
+		WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone).
+		Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := loadBalancer.UpdateBackends([]string{endpoint.Id, endpoint2.Id})
+	if ModifyLoadbalancerSupported() != nil {
+		if err == nil {
+			t.Fatal("expected UpdateBackends to fail when ModifyLoadbalancer is not supported")
+		}
+		updated = loadBalancer
+	} else {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(updated.HostComputeEndpoints) != 2 {
+			t.Fatalf("expected 2 backends, got %v", updated.HostComputeEndpoints)
+		}
+		if updated.Id != loadBalancer.Id {
+			t.Fatalf("expected load balancer %s to be modified in place, got %s", loadBalancer.Id, updated.Id)
+		}
+	}
+
+	err = updated.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = endpoint2.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = endpoint.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = network.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadBalancerBuilder(t *testing.T) {
+	const endpointID = "1d8a2e8e-0b4f-4a7b-9e2e-6a4c1b0d3f5a"
+	allFeatures := SupportedFeatures{
+		Api:           ApiSupport{V1: true, V2: true},
+		DSR:           true,
+		IPv6DualStack: true,
+	}
+
+	for _, tc := range []struct {
+		name     string
+		builder  *LoadBalancerBuilder
+		features SupportedFeatures
+		flags    LoadBalancerFlags
+		wantErr  bool
+	}{
+		{
+			name: "Valid",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithSourceVIP("10.0.0.1").WithBackends(endpointID).
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsILB),
+			features: allFeatures,
+		},
+		{
+			name: "DSR",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithDSR().
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsPreserveDIP),
+			features: allFeatures,
+			flags:    LoadBalancerFlagsDSR,
+		},
+		{
+			name: "IPv6",
+			builder: NewLoadBalancer().WithVIP("fd00::1").WithSourceVIP("fd00::2").
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone),
+			features: allFeatures,
+			flags:    LoadBalancerFlagsIPv6,
+		},
+		{
+			name: "ILBWithDSR",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithDSR().
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsILB),
+			features: allFeatures,
+			wantErr:  true,
+		},
+		{
+			name: "ILBWithDSRMultiplePortMappings",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithDSR().
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone).
+				WithPortMapping(17, 5353, 53, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsILB),
+			features: allFeatures,
+			wantErr:  true,
+		},
+		{
+			name: "DSRNotSupported",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithDSR().
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone),
+			features: SupportedFeatures{Api: ApiSupport{V2: true}},
+			wantErr:  true,
+		},
+		{
+			name: "MixedIPFamilies",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithVIP("fd00::1").
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone),
+			features: allFeatures,
+			wantErr:  true,
+		},
+		{
+			name: "InvalidVIP",
+			builder: NewLoadBalancer().WithVIP("1.1.1").
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone),
+			features: allFeatures,
+			wantErr:  true,
+		},
+		{
+			name: "InvalidBackend",
+			builder: NewLoadBalancer().WithVIP("1.1.1.2").WithBackends("not-a-guid").
+				WithPortMapping(6, 8080, 80, LoadBalancerDistributionNone, LoadBalancerPortMappingFlagsNone),
+			features: allFeatures,
+			wantErr:  true,
+		},
+		{
+			name:     "NoPortMappings",
+			builder:  NewLoadBalancer().WithVIP("1.1.1.2"),
+			features: allFeatures,
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			loadBalancer, err := tc.builder.build(tc.features)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error building load balancer %+v", loadBalancer)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loadBalancer.SchemaVersion != V2SchemaVersion() {
+				t.Fatalf("expected schema version %v, got %v", V2SchemaVersion(), loadBalancer.SchemaVersion)
+			}
+			if loadBalancer.Flags != tc.flags {
+				t.Fatalf("expected flags %d, got %d", tc.flags, loadBalancer.Flags)
+			}
+		})
+	}
+}
+
+func TestDiffLoadBalancerBackends(t *testing.T) {
+	current := []string{"A", "b", "c"}
+	desired := []string{"a", "B", "d"}
+	added, removed := diffLoadBalancerBackends(current, desired)
+	if !slices.Equal(added, []string{"d"}) {
+		t.Fatalf("expected added [d], got %v", added)
+	}
+	if !slices.Equal(removed, []string{"c"}) {
+		t.Fatalf("expected removed [c], got %v", removed)
+	}
+
+	added, removed = diffLoadBalancerBackends(current, current)
+	if len(added) != 0 || len(removed) != 0 {
+		t.Fatalf("expected no changes, got added %v and removed %v", added, removed)
+	}
+}
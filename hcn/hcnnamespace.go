@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"sync"
 	"syscall"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
@@ -448,3 +449,34 @@ func RemoveNamespaceEndpoint(namespaceID string, endpointID string) error {
 
 	return ModifyNamespaceSettings(namespaceID, requestMessage)
 }
+
+// AddNamespaceEndpoints adds the endpoints to a Namespace concurrently. If any
+// endpoint cannot be added, the endpoints that were added are removed from the
+// Namespace before the error is returned.
+func AddNamespaceEndpoints(namespaceID string, endpointIDs []string) error {
+	logrus.Debugf("hcn::HostComputeNamespace::AddNamespaceEndpoints id=%s count=%d", namespaceID, len(endpointIDs))
+
+	errs := make([]error, len(endpointIDs))
+	var wg sync.WaitGroup
+	for i, endpointID := range endpointIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = AddNamespaceEndpoint(namespaceID, endpointID)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		for i, endpointID := range endpointIDs {
+			if errs[i] != nil {
+				continue
+			}
+			if rErr := RemoveNamespaceEndpoint(namespaceID, endpointID); rErr != nil {
+				logrus.WithError(rErr).Warnf("hcn::HostComputeNamespace::AddNamespaceEndpoints failed to remove endpoint %s", endpointID)
+			}
+		}
+		return err
+	}
+	return nil
+}
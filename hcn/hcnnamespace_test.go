@@ -214,6 +214,71 @@ func TestAddRemoveNamespaceEndpoint(t *testing.T) {
 	}
 }
 
+func TestAddNamespaceEndpoints(t *testing.T) {
+	network, err := HcnCreateTestNATNetwork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints, err := network.CreateEndpoints([]*HostComputeEndpoint{
+		{Name: NatTestEndpointName + "1", SchemaVersion: SchemaVersion{Major: 2}},
+		{Name: NatTestEndpointName + "2", SchemaVersion: SchemaVersion{Major: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespace, err := HcnCreateTestNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failure to add any endpoint must roll back the ones that were added
+	err = AddNamespaceEndpoints(namespace.Id, []string{endpoints[0].Id, "00000000-0000-0000-0000-000000000001"})
+	if err == nil {
+		t.Fatal("expected adding a missing endpoint to fail")
+	}
+	foundEndpoints, err := GetNamespaceEndpointIds(namespace.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foundEndpoints) != 0 {
+		t.Fatalf("expected no endpoints after rollback, found %d", len(foundEndpoints))
+	}
+
+	endpointIDs := []string{endpoints[0].Id, endpoints[1].Id}
+	err = AddNamespaceEndpoints(namespace.Id, endpointIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foundEndpoints, err = GetNamespaceEndpointIds(namespace.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foundEndpoints) != len(endpointIDs) {
+		t.Fatalf("expected %d endpoints, found %d", len(endpointIDs), len(foundEndpoints))
+	}
+
+	for _, id := range endpointIDs {
+		err = RemoveNamespaceEndpoint(namespace.Id, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = namespace.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range endpoints {
+		err = endpoint.Delete()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = network.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestModifyNamespaceSettings(t *testing.T) {
 	network, err := HcnCreateTestNATNetwork()
 	if err != nil {
@@ -5,6 +5,7 @@ package hcn
 import (
 	"encoding/json"
 	"errors"
+	"sync"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
 	"github.com/Microsoft/hcsshim/internal/interop"
@@ -474,3 +475,35 @@ func (network *HostComputeNetwork) CreateRemoteEndpoint(endpoint *HostComputeEnd
 	endpoint.Flags = EndpointFlagsRemoteEndpoint | endpoint.Flags
 	return network.CreateEndpoint(endpoint)
 }
+
+// CreateEndpoints creates the endpoints on the Network concurrently. If any
+// endpoint cannot be created, the endpoints that were created are deleted
+// before the error is returned.
+func (network *HostComputeNetwork) CreateEndpoints(endpoints []*HostComputeEndpoint) ([]*HostComputeEndpoint, error) {
+	logrus.Debugf("hcn::HostComputeNetwork::CreateEndpoints, networkId=%s count=%d", network.Id, len(endpoints))
+
+	newEndpoints := make([]*HostComputeEndpoint, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			newEndpoints[i], errs[i] = network.CreateEndpoint(endpoint)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		for _, endpoint := range newEndpoints {
+			if endpoint == nil {
+				continue
+			}
+			if dErr := endpoint.Delete(); dErr != nil {
+				logrus.WithError(dErr).Warnf("hcn::HostComputeNetwork::CreateEndpoints failed to delete endpoint %s", endpoint.Id)
+			}
+		}
+		return nil, err
+	}
+	return newEndpoints, nil
+}
@@ -3,9 +3,12 @@
 package hcsshim
 
 import (
+	"context"
 	"io"
 	"time"
 
+	"github.com/Microsoft/go-winio/pkg/guid"
+
 	"github.com/Microsoft/hcsshim/internal/hcs/schema1"
 )
 
@@ -114,3 +117,27 @@ type Process interface {
 	// or wait on it.
 	Close() error
 }
+
+// ContainerPropertiesV2 is implemented by the Container returned from CreateContainer and
+// OpenContainer, and queries the container's properties using the V2 schema.
+type ContainerPropertiesV2 interface {
+	// StatisticsV2 returns the runtime statistics of the container. The processor, memory
+	// and storage statistics are nil if they are not reported.
+	StatisticsV2(ctx context.Context) (*StatisticsV2, error)
+
+	// GuestConnectionInfo returns the state of the connection between HCS and the container's guest.
+	GuestConnectionInfo(ctx context.Context) (*GuestConnection, error)
+
+	// SiloGUID returns the GUID of the silo backing the container.
+	SiloGUID(ctx context.Context) (guid.GUID, error)
+}
+
+// ContainerMappedDirectories is implemented by the Container returned from CreateContainer and
+// OpenContainer, and changes the host directories mapped into a running process-isolated
+// container.
+type ContainerMappedDirectories interface {
+	// ModifyMappedDirectory adds, or removes if add is false, the host directory hostPath
+	// mapped into the container at containerPath. The error of the platform is returned if
+	// it does not support changing the mapped directories of a running container.
+	ModifyMappedDirectory(ctx context.Context, add bool, hostPath, containerPath string, readOnly bool) error
+}
@@ -51,6 +51,28 @@ const (
 	// work to support multiple custom network routes per adapter in LCOW breaks existing
 	// LCOW scenarios. Ideally, this annotation should be removed if no issues are found.
 	NetworkingPolicyBasedRouting = "io.microsoft.virtualmachine.lcow.network.policybasedrouting"
+
+	// NetworkingDHCPClasslessStaticRoutes specifies static classless routes that the guest adds
+	// to each of its network adapters that are configured with DHCP, once the lease is acquired,
+	// replacing any route to the same destination. The routes apply to every such adapter in the
+	// uVM, and are added by the guest rather than sent by the DHCP server.
+	// The format is the base64-encoded payload of the classless static route DHCP option (121),
+	// as defined in RFC 3442. For example, to add a route to 10.99.0.0/16 via 192.168.0.1:
+	//
+	//	EApjwKgAAQ==
+	NetworkingDHCPClasslessStaticRoutes = "io.microsoft.virtualmachine.lcow.network.dhcp-classless-static-routes"
+
+	// NetworkingMTU overrides the MTU set on the guest's network adapters.
+	// It must be at least 68, the smallest MTU an IPv4 link must support.
+	// By default, the guest reduces the MTU of each adapter by the encapsulation overhead of the
+	// HNS network the adapter's endpoint is on, if any.
+	NetworkingMTU = "io.microsoft.virtualmachine.lcow.network.mtu"
+
+	// NetworkingVLANTag specifies the 802.1Q VLAN ID, between 1 and 4094, the guest tags the
+	// traffic of its network adapters with.
+	// The guest configures each adapter on a VLAN link on top of it, named `<adapter>.<VLAN ID>`.
+	// By default, traffic is not tagged.
+	NetworkingVLANTag = "io.microsoft.virtualmachine.lcow.network.vlan-tag"
 )
 
 // WCOW uVM annotations.
@@ -67,3 +89,22 @@ const (
 	// [HCS RegistryValue]: https://learn.microsoft.com/en-us/virtualization/api/hcs/schemareference#registryvalue
 	AdditionalRegistryValues = "io.microsoft.virtualmachine.wcow.additional-reg-keys"
 )
+
+// WCOW container annotations.
+const (
+	// ContainerRegistryValues specifies registry values to set in a WCOW container.
+	// The format is the same as [AdditionalRegistryValues]: a JSON-encoded string of an
+	// array containing [HCS RegistryValue] objects.
+	//
+	// Only the `System` and `Software` hives are supported, and each value must set exactly
+	// one of `StringValue`, `BinaryValue`, `DWordValue`, or `QWordValue`, matching its type.
+	// Values that can cause programs to be loaded or run (e.g., a service's `ImagePath`)
+	// are rejected unless [ContainerRegistryValuesAllowUnsafe] is set to "true".
+	//
+	// [HCS RegistryValue]: https://learn.microsoft.com/en-us/virtualization/api/hcs/schemareference#registryvalue
+	ContainerRegistryValues = "io.microsoft.container.wcow.registry-values"
+
+	// ContainerRegistryValuesAllowUnsafe allows [ContainerRegistryValues] to set values
+	// under security-sensitive registry keys.
+	ContainerRegistryValuesAllowUnsafe = "io.microsoft.container.wcow.registry-values.allow-unsafe"
+)
@@ -21,8 +21,19 @@ const (
 	HrFail = Hresult(-2147467259) // 0x80004005
 	// HrErrNotFound is the HRESULT for an invalid process id.
 	HrErrNotFound = Hresult(-2147023728) // 0x80070490
+	// HrErrFileNotFound is the HRESULT for The system cannot find the file
+	// specified, such as the executable of a process.
+	HrErrFileNotFound = Hresult(-2147024894) // 0x80070002
 	// HrErrInvalidArg is the HRESULT for One or more arguments are invalid.
 	HrErrInvalidArg = Hresult(-2147024809) // 0x80070057
+	// HrErrAccessDenied is the HRESULT for Access is denied.
+	HrErrAccessDenied = Hresult(-2147024891) // 0x80070005
+	// HrErrDiskCorrupt is the HRESULT for The disk structure is corrupted and
+	// unreadable, such as a filesystem that cannot be mounted.
+	HrErrDiskCorrupt = Hresult(-2147023503) // 0x80070571
+	// HrOutOfMemory is the HRESULT for failing to allocate necessary resources,
+	// such as when too many processes are running.
+	HrOutOfMemory = Hresult(-2147024882) // 0x8007000E
 	// HvVmcomputeTimeout is the HRESULT for operations that timed out.
 	HvVmcomputeTimeout = Hresult(-1070137079) // 0xC0370109
 	// HrVmcomputeInvalidJSON is the HRESULT for failing to unmarshal a json
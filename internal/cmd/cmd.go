@@ -13,8 +13,11 @@ import (
 	"time"
 
 	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcs/prot"
+	"github.com/Microsoft/hcsshim/internal/guestpath"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
@@ -23,6 +26,10 @@ import (
 
 var errIOTimeOut = errors.New("timed out waiting for stdio relay")
 
+// errStdinClosed is returned when relaying stdin to a process that closed its
+// stdin, and is not reported to the caller.
+var errStdinClosed = errors.New("process closed stdin")
+
 // CmdProcessRequest stores information on command requests made through this package.
 type CmdProcessRequest struct {
 	Args     []string
@@ -31,6 +38,9 @@ type CmdProcessRequest struct {
 	Stdin    string
 	Stdout   string
 	Stderr   string
+	// User is the user to run the process as, in the form user[:group] in a
+	// Linux utility VM.
+	User string
 }
 
 // Cmd represents a command being prepared or run in a process host.
@@ -41,11 +51,39 @@ type Cmd struct {
 	// The OCI spec for the process.
 	Spec *specs.Process
 
+	// ExtraCapabilities and DropCapabilities are capabilities to add to and
+	// remove from Spec when the process is exec'd in an LCOW container.
+	ExtraCapabilities []string
+	DropCapabilities  []string
+
+	// ShellCandidates are the shells to try, in order, if Spec is a terminal
+	// process exec'd in an LCOW container that requests one of them and it
+	// does not exist in the container.
+	ShellCandidates []string
+
+	// WorkingDirectoryCreateIfMissing creates the working directory of Spec,
+	// or of the container's process if Spec is not set, if it does not exist in
+	// an LCOW container or utility VM.
+	WorkingDirectoryCreateIfMissing bool
+
+	// Namespace runs a process in a Linux utility VM in all the namespaces of
+	// a container in it, rather than in the utility VM's. It is an alias for
+	// running the process with the container as Host: the process is exec'd in
+	// the container, with Spec.
+	Namespace *guestresource.LCOWExecNamespaceConfig
+
 	// Standard IO streams to relay to/from the process.
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// StdoutFile and StderrFile redirect stdout and stderr of a process in a
+	// Linux guest to files in its container, or in the guest for processes in
+	// the utility VM, instead of relaying them. Stdout and Stderr must not be
+	// set for a redirected stream.
+	StdoutFile *prot.ExecuteProcessStdioFileSettings
+	StderrFile *prot.ExecuteProcessStdioFileSettings
+
 	// Log provides a logrus entry to use in logging IO copying status.
 	Log *logrus.Entry
 
@@ -97,6 +135,24 @@ func (err *ExitError) Error() string {
 type lcowProcessParameters struct {
 	hcsschema.ProcessParameters
 	OCIProcess *specs.Process `json:"OciProcess,omitempty"`
+
+	// stdoutFile and stderrFile are sent alongside the process parameters,
+	// not in them.
+	stdoutFile, stderrFile *prot.ExecuteProcessStdioFileSettings
+	// namespace is the container the process is exec'd in instead.
+	namespace *guestresource.LCOWExecNamespaceConfig
+}
+
+// StdioFiles returns the files that stdout and stderr are redirected to in the
+// guest.
+func (p *lcowProcessParameters) StdioFiles() (stdout, stderr *prot.ExecuteProcessStdioFileSettings) {
+	return p.stdoutFile, p.stderrFile
+}
+
+// ExecNamespace returns the container whose namespaces the process is exec'd
+// in, if any.
+func (p *lcowProcessParameters) ExecNamespace() *guestresource.LCOWExecNamespaceConfig {
+	return p.namespace
 }
 
 // escapeArgs makes a Windows-style escaped command line from a set of arguments.
@@ -122,7 +178,7 @@ func Command(host cow.ProcessHost, name string, arg ...string) *Cmd {
 		cmd.Spec.Cwd = `C:\`
 	} else {
 		cmd.Spec.Cwd = "/"
-		cmd.Spec.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+		cmd.Spec.Env = []string{"PATH=" + guestpath.LCOWDefaultPathEnv}
 	}
 	return cmd
 }
@@ -143,11 +199,34 @@ func (c *Cmd) Start() error {
 		return errors.New("empty ProcessHost")
 	}
 
+	redirect := c.StdoutFile != nil || c.StderrFile != nil
+	if redirect {
+		if c.Host.OS() == "windows" {
+			return errors.New("redirecting stdio to files is only supported in Linux guests")
+		}
+		if (c.StdoutFile != nil && c.Stdout != nil) || (c.StderrFile != nil && c.Stderr != nil) {
+			return errors.New("stdio stream cannot be both relayed and redirected to a file")
+		}
+	}
+
+	if c.Namespace != nil {
+		if c.Host.OS() == "windows" {
+			return errors.New("joining the namespaces of a container is only supported in Linux guests")
+		}
+		if c.Host.IsOCI() {
+			return errors.New("joining the namespaces of a container is only supported for processes in a utility VM")
+		}
+		if c.Spec == nil || len(c.Spec.Args) == 0 {
+			return errors.New("process args are required to join the namespaces of a container")
+		}
+	}
+
 	// closed in (*Cmd).Wait; signals command execution is done
 	c.allDoneCh = make(chan struct{})
 
 	var x interface{}
-	if !c.Host.IsOCI() {
+	// a process in the namespaces of a container is exec'd in the container
+	if !c.Host.IsOCI() && c.Namespace == nil {
 		if c.Spec == nil {
 			return errors.New("process spec is required for non-OCI ProcessHost")
 		}
@@ -169,6 +248,9 @@ func (c *Cmd) Start() error {
 				wpp.CommandArgs = c.Spec.Args
 			}
 		}
+		if c.Host.OS() != "windows" {
+			wpp.WorkingDirectoryCreateIfMissing = c.WorkingDirectoryCreateIfMissing
+		}
 
 		environment := make(map[string]string)
 		for _, v := range c.Spec.Env {
@@ -186,14 +268,29 @@ func (c *Cmd) Start() error {
 			}
 		}
 		x = wpp
+		if redirect {
+			x = &lcowProcessParameters{
+				ProcessParameters: *wpp,
+				stdoutFile:        c.StdoutFile,
+				stderrFile:        c.StderrFile,
+			}
+		}
 	} else {
 		lpp := &lcowProcessParameters{
 			ProcessParameters: hcsschema.ProcessParameters{
-				CreateStdInPipe:  c.Stdin != nil,
-				CreateStdOutPipe: c.Stdout != nil,
-				CreateStdErrPipe: c.Stderr != nil,
+				CreateStdInPipe:   c.Stdin != nil,
+				CreateStdOutPipe:  c.Stdout != nil,
+				CreateStdErrPipe:  c.Stderr != nil,
+				ExtraCapabilities: c.ExtraCapabilities,
+				DropCapabilities:  c.DropCapabilities,
+				ShellCandidates:   c.ShellCandidates,
+
+				WorkingDirectoryCreateIfMissing: c.WorkingDirectoryCreateIfMissing,
 			},
 			OCIProcess: c.Spec,
+			stdoutFile: c.StdoutFile,
+			stderrFile: c.StderrFile,
+			namespace:  c.Namespace,
 		}
 		x = lpp
 	}
@@ -215,12 +312,17 @@ func (c *Cmd) Start() error {
 		// Do not make stdin part of the error group because there is no way for
 		// us or the caller to reliably unblock the c.Stdin read when the
 		// process exits.
+		if sc, ok := p.(stdinCloseNotifier); ok && sc.StdinClosed() != nil {
+			// Stop relaying stdin once the process closes it, rather than
+			// failing a later write with a broken pipe.
+			stdin = &stdinWriter{w: stdin, closed: sc.StdinClosed()}
+		}
 		go func() {
 			_, err := relayIO(stdin, c.Stdin, c.Log, "stdin")
 			// Report the stdin copy error. If the process has exited, then the
 			// caller may never see it, but if the error was due to a failure in
 			// stdin read, then it is likely the process is still running.
-			if err != nil {
+			if err != nil && !errors.Is(err, errStdinClosed) {
 				c.stdinErr.Store(err)
 			}
 			// Notify the process that there is no more input.
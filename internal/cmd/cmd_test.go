@@ -280,3 +280,20 @@ func TestCmdStuckIo(t *testing.T) {
 		t.Fatalf("expected: %v; got: %v", errIOTimeOut, err)
 	}
 }
+
+func TestStdinWriterClosed(t *testing.T) {
+	var b bytes.Buffer
+	closed := make(chan struct{})
+	w := &stdinWriter{w: &b, closed: closed}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	close(closed)
+	n, err := io.Copy(w, strings.NewReader(" world"))
+	if !errors.Is(err, errStdinClosed) || n != 0 {
+		t.Fatalf("expected %v after 0 bytes, got %v after %d bytes", errStdinClosed, err, n)
+	}
+	if b.String() != "hello" {
+		t.Fatalf("unexpected stdin: %q", b.String())
+	}
+}
@@ -29,6 +29,9 @@ func ExecInUvm(ctx context.Context, vm *uvm.UtilityVM, req *CmdProcessRequest) (
 	if vm.OS() == "windows" {
 		cmd.Spec.User.Username = `NT AUTHORITY\SYSTEM`
 	}
+	if req.User != "" {
+		cmd.Spec.User.Username = req.User
+	}
 	cmd.Spec.Terminal = req.Terminal
 	cmd.Stdin = np.Stdin()
 	cmd.Stdout = np.Stdout()
@@ -44,6 +47,9 @@ func ExecInShimHost(ctx context.Context, req *CmdProcessRequest) (int, error) {
 	if len(req.Args) == 0 {
 		return 0, errors.New("missing command")
 	}
+	if req.User != "" {
+		return 0, errors.New("running a command as a user in the shim's host is not supported")
+	}
 	cmdArgsWithoutName := []string{""}
 	if len(req.Args) > 1 {
 		cmdArgsWithoutName = req.Args[1:]
@@ -4,6 +4,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
@@ -73,10 +74,43 @@ func relayIO(w io.Writer, r io.Reader, log *logrus.Entry, name string) (int64, e
 			"bytes": n,
 		})
 		if err != nil {
-			lvl = logrus.ErrorLevel
+			if !errors.Is(err, errStdinClosed) {
+				lvl = logrus.ErrorLevel
+			}
 			log = log.WithError(err)
 		}
 		log.Log(lvl, "Cmd IO relay complete")
 	}
 	return n, err
 }
+
+// stdinCloseNotifier is implemented by processes that report when they close
+// their stdin, such as the processes in a utility VM.
+type stdinCloseNotifier interface {
+	StdinClosed() <-chan struct{}
+}
+
+// stdinWriter writes to the stdin `w` of a process until `closed` is closed,
+// after which writes fail with errStdinClosed.
+type stdinWriter struct {
+	w      io.Writer
+	closed <-chan struct{}
+}
+
+func (s *stdinWriter) Write(b []byte) (int, error) {
+	select {
+	case <-s.closed:
+		return 0, errStdinClosed
+	default:
+	}
+	n, err := s.w.Write(b)
+	if err != nil {
+		// a write that failed as the process closed stdin is not an error
+		select {
+		case <-s.closed:
+			return n, errStdinClosed
+		default:
+		}
+	}
+	return n, err
+}
@@ -7,7 +7,9 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/Microsoft/hcsshim/internal/cmd"
 	"github.com/Microsoft/hcsshim/internal/log"
@@ -120,3 +122,27 @@ func GetDeviceInfoFromPath(rawDevicePath string) (string, uint16) {
 	// otherwise, just use default index and full device ID given
 	return rawDevicePath, 0
 }
+
+// GetDeviceFunctionsFromPath takes a device path and parses it into the PCI ID and
+// the virtual function indexes of its functions. The functions of a multi-function
+// device are specified as a comma separated list of indexes, such as
+// `<instance ID>/0,1`, in which each index may only appear once.
+func GetDeviceFunctionsFromPath(rawDevicePath string) (string, []uint16, error) {
+	indexString := filepath.Base(rawDevicePath)
+	if !strings.Contains(indexString, ",") {
+		pciID, index := GetDeviceInfoFromPath(rawDevicePath)
+		return pciID, []uint16{index}, nil
+	}
+	var indexes []uint16
+	for _, s := range strings.Split(indexString, ",") {
+		index, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid virtual function index %q in device %s: %w", s, rawDevicePath, err)
+		}
+		if slices.Contains(indexes, uint16(index)) {
+			return "", nil, fmt.Errorf("duplicate virtual function index %d in device %s", index, rawDevicePath)
+		}
+		indexes = append(indexes, uint16(index))
+	}
+	return filepath.Dir(rawDevicePath), indexes, nil
+}
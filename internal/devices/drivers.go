@@ -61,7 +61,7 @@ func InstallDrivers(ctx context.Context, vm *uvm.UtilityVM, share string) (close
 		true,
 		vm.ID(),
 		"",
-		&scsi.MountConfig{},
+		&scsi.MountConfig{PathTag: "driver"},
 	)
 	if err != nil {
 		return closer, fmt.Errorf("failed to add SCSI disk to utility VM for path %+v: %w", share, err)
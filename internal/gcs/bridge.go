@@ -19,6 +19,7 @@ import (
 	"go.opencensus.io/trace"
 	"golang.org/x/sys/windows"
 
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/gcs/prot"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
@@ -177,6 +178,13 @@ func (err *rpcError) Unwrap() error {
 	return windows.Errno(err.result)
 }
 
+// IsDiskCorrupt returns if `err` is a guest RPC failure because the filesystem
+// on a device is corrupt, which a Linux GCS fails a mount with.
+func IsDiskCorrupt(err error) bool {
+	var rpcErr *rpcError
+	return errors.As(err, &rpcErr) && rpcErr.result == int32(gcserr.HrErrDiskCorrupt)
+}
+
 // Err returns the RPC's result. This may be a transport error or an error from
 // the message response.
 func (call *rpc) Err() error {
@@ -236,6 +244,25 @@ func (brdg *bridge) RPC(ctx context.Context, proc prot.RPCProc, req requestMessa
 	}
 }
 
+// Ping issues a lightweight echo request to the guest and returns the round
+// trip latency. The request is safe to abandon, so Ping returns as soon as the
+// context is done even if the guest never responds.
+func (brdg *bridge) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	req := prot.PingRequest{
+		RequestBase: makeRequest(ctx, nullContainerID),
+		Timestamp:   start.UnixNano(),
+	}
+	var resp prot.PingResponse
+	if err := brdg.RPC(ctx, prot.RPCPing, &req, &resp, true); err != nil {
+		return 0, err
+	}
+	if resp.Timestamp != req.Timestamp {
+		return 0, fmt.Errorf("ping response timestamp %d does not match request timestamp %d", resp.Timestamp, req.Timestamp)
+	}
+	return time.Since(start), nil
+}
+
 func (brdg *bridge) recvLoopRoutine() {
 	brdg.kill(brdg.recvLoop())
 	// Fail any remaining RPCs.
@@ -311,7 +338,15 @@ func (brdg *bridge) recvLoop() error {
 			err := json.Unmarshal(b, call.resp)
 			if err != nil {
 				err = fmt.Errorf("bridge response unmarshal failed: %w", err)
-			} else if resp := call.resp.Base(); resp.Result != 0 {
+			} else if resp := call.resp.Base(); resp.Result == 0 {
+				for _, w := range resp.Warnings {
+					brdg.log.WithFields(logrus.Fields{
+						"message-id": id,
+						"type":       typ.String(),
+						"warning":    w,
+					}).Info("bridge RPC warning")
+				}
+			} else {
 				for _, rec := range resp.ErrorRecords {
 					brdg.log.WithFields(logrus.Fields{
 						"message-id":     id,
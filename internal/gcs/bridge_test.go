@@ -152,6 +152,34 @@ func TestBridgeRPCBridgeClosed(t *testing.T) {
 	}
 }
 
+func TestBridgePing(t *testing.T) {
+	b := startReflectedBridge(t, 0)
+	defer b.Close()
+	latency, err := b.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latency >= 100*time.Millisecond {
+		t.Fatalf("expected ping latency under 100ms, got %s", latency)
+	}
+}
+
+func TestBridgePingContextDone(t *testing.T) {
+	// simulate a dropped connection by never responding within the deadline
+	b := startReflectedBridge(t, time.Minute)
+	defer b.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	start := time.Now()
+	_, err := b.Ping(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected ping to return shortly after the context deadline, took %s", d)
+	}
+}
+
 func sendJSON(t *testing.T, w io.Writer, typ prot.MsgType, id int64, msg interface{}) error {
 	t.Helper()
 	msgb, err := json.Marshal(msg)
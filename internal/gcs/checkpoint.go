@@ -0,0 +1,322 @@
+//go:build windows
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/guestpath"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+	"go.opencensus.io/trace"
+)
+
+// ErrCheckpointNotSupported is returned when checkpointing or restoring a container
+// that is not running in a Linux guest.
+var ErrCheckpointNotSupported = errors.New("checkpoint and restore are only supported for Linux containers")
+
+// maxCheckpointErrorOutput is the most output from the guest's checkpoint or restore
+// process that is included in the error when it fails.
+const maxCheckpointErrorOutput = 4096
+
+// CheckpointOptions are the options used to checkpoint a container.
+type CheckpointOptions struct {
+	// LeaveRunning leaves the container running once it is checkpointed.
+	// Otherwise, the container is stopped.
+	LeaveRunning bool
+	// TCPEstablished allows checkpointing established TCP connections.
+	TCPEstablished bool
+	// FileLocks allows checkpointing held file locks.
+	FileLocks bool
+	// Layers are the container's combined layers. If set, and the container is not
+	// left running, the container's root filesystem is unmounted once the container is
+	// checkpointed, and is mounted again by [Container.Restore].
+	Layers *guestresource.LCOWCombinedLayers
+}
+
+// Checkpoint checkpoints the container's processes, including their memory, to
+// `destDir` in the guest.
+//
+// CRIU is run inside the guest (via runc) as an external process, so both must be
+// present in the guest's root filesystem.
+func (c *Container) Checkpoint(ctx context.Context, destDir string, opts *CheckpointOptions) (err error) {
+	ctx, span := oc.StartSpan(ctx, "gcs::Container::Checkpoint", oc.WithClientSpanKind)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("cid", c.id),
+		trace.StringAttribute("destDir", destDir))
+
+	if c.gc.os != "linux" {
+		return ErrCheckpointNotSupported
+	}
+	if opts == nil {
+		opts = &CheckpointOptions{}
+	}
+
+	c.checkpointMu.Lock()
+	defer c.checkpointMu.Unlock()
+
+	if err := c.runInGuest(ctx, checkpointArgs(c.id, destDir, opts)); err != nil {
+		return fmt.Errorf("failed to checkpoint container %s: %w", c.id, err)
+	}
+	c.checkpoint = opts
+
+	// the container's processes are gone, so its root filesystem can be unmounted
+	if opts.Layers != nil && !opts.LeaveRunning {
+		if err := c.gc.Modify(ctx, guestrequest.ModificationRequest{
+			ResourceType: guestresource.ResourceTypeCombinedLayers,
+			RequestType:  guestrequest.RequestTypeRemove,
+			Settings: guestresource.LCOWCombinedLayers{
+				ContainerRootPath: opts.Layers.ContainerRootPath,
+			},
+		}); err != nil {
+			return fmt.Errorf("failed to unmount checkpointed container %s root filesystem: %w", c.id, err)
+		}
+	}
+	return nil
+}
+
+// Restore restores the container's processes from the checkpoint in `srcDir` in the
+// guest, using the options the container was last checkpointed with, if any, and
+// returns the restored init process.
+//
+// The restored processes are children of a runc process in the guest, which the guest
+// connection tracks like any process it creates: the returned process exits with the
+// exit code of the restored init process, and signals sent to it are forwarded to the
+// restored init process. Its stdio is not relayed. The container's wait channel still
+// reflects the exit of its original init process.
+func (c *Container) Restore(ctx context.Context, srcDir string) (_ cow.Process, err error) {
+	ctx, span := oc.StartSpan(ctx, "gcs::Container::Restore", oc.WithClientSpanKind)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("cid", c.id),
+		trace.StringAttribute("srcDir", srcDir))
+
+	if c.gc.os != "linux" {
+		return nil, ErrCheckpointNotSupported
+	}
+
+	c.checkpointMu.Lock()
+	defer c.checkpointMu.Unlock()
+
+	opts := c.checkpoint
+	if opts == nil {
+		opts = &CheckpointOptions{}
+	}
+
+	if opts.Layers != nil && !opts.LeaveRunning {
+		if err := c.gc.Modify(ctx, guestrequest.ModificationRequest{
+			ResourceType: guestresource.ResourceTypeCombinedLayers,
+			RequestType:  guestrequest.RequestTypeAdd,
+			Settings:     *opts.Layers,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to mount container %s root filesystem: %w", c.id, err)
+		}
+	}
+
+	pidFile := restorePidFile(c.id)
+	if err := c.runInGuest(ctx, []string{"rm", "-f", pidFile}); err != nil {
+		return nil, fmt.Errorf("failed to remove container %s restore pid file: %w", c.id, err)
+	}
+	p, err := c.startInGuest(ctx, restoreArgs(c.id, srcDir, opts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore container %s: %w", c.id, err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = p.Wait()
+		close(exited)
+	}()
+
+	// runc writes the pid file once the container is restored, and otherwise exits
+	wctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-wctx.Done():
+		}
+	}()
+	err = c.runInGuest(wctx, []string{"/bin/sh", "-c", `while [ ! -s "$0" ]; do sleep 0.1; done`, pidFile})
+	cancel()
+	if err != nil && ctx.Err() == nil {
+		// the restored init process may have exited as soon as it was restored
+		err = c.runInGuest(ctx, []string{"test", "-s", pidFile})
+	}
+	if err == nil {
+		c.checkpoint = nil
+		return restoredProcess{p}, nil
+	}
+
+	if ctx.Err() != nil {
+		_, _ = p.Kill(context.WithoutCancel(ctx))
+		<-exited
+		p.Close()
+		return nil, ctx.Err()
+	}
+	<-exited
+	defer p.Close()
+	if err := p.result(); err != nil {
+		return nil, fmt.Errorf("failed to restore container %s: %w", c.id, err)
+	}
+	return nil, fmt.Errorf("failed to restore container %s: %q exited without restoring it", c.id, p.args[0])
+}
+
+// restoredProcess is the restored init process returned by [Container.Restore],
+// whose stdio is not relayed.
+type restoredProcess struct {
+	*guestProcess
+}
+
+func (restoredProcess) Stdio() (io.Writer, io.Reader, io.Reader) {
+	return nil, nil, nil
+}
+
+// restorePidFile is the file that runc writes the pid of the restored init process of
+// container `id` to.
+func restorePidFile(id string) string {
+	return path.Join(guestpath.LCOWRootPrefixInUVM, id, "restore.pid")
+}
+
+// checkpointArgs returns the command line to checkpoint container `id` to `destDir`.
+func checkpointArgs(id, destDir string, opts *CheckpointOptions) []string {
+	args := []string{"runc", "checkpoint", "--image-path", destDir}
+	if opts.LeaveRunning {
+		args = append(args, "--leave-running")
+	}
+	return append(append(args, criuArgs(opts)...), id)
+}
+
+// restoreArgs returns the command line to restore container `id` from `srcDir`.
+//
+// The container is restored from its original bundle, which the guest keeps until the
+// container is deleted. runc is not detached, so that it stays the parent of the
+// restored init process and exits with its exit code.
+func restoreArgs(id, srcDir string, opts *CheckpointOptions) []string {
+	args := []string{
+		"runc", "restore",
+		"--image-path", srcDir,
+		"--bundle", path.Join(guestpath.LCOWRootPrefixInUVM, id),
+		"--pid-file", restorePidFile(id),
+	}
+	return append(append(args, criuArgs(opts)...), id)
+}
+
+// criuArgs returns the arguments that must match between a checkpoint and restore.
+func criuArgs(opts *CheckpointOptions) (args []string) {
+	if opts.TCPEstablished {
+		args = append(args, "--tcp-established")
+	}
+	if opts.FileLocks {
+		args = append(args, "--file-locks")
+	}
+	return args
+}
+
+// guestProcess is a process started by [Container.startInGuest].
+type guestProcess struct {
+	cow.Process
+	args []string
+	// stderr is the start of the process's stderr, complete once stderrDone is closed
+	stderr     bytes.Buffer
+	stderrDone chan struct{}
+}
+
+// startInGuest starts `args` as an external process in the guest, draining its
+// stderr. criu needs root to checkpoint and restore containers, so the process is
+// exempt from [GuestConnectionConfig.DenyRootProcesses].
+func (c *Container) startInGuest(ctx context.Context, args []string) (*guestProcess, error) {
+	params := &hcsschema.ProcessParameters{
+		CommandArgs:      args,
+		WorkingDirectory: "/",
+		Environment:      map[string]string{"PATH": guestpath.LCOWDefaultPathEnv},
+		CreateStdErrPipe: true,
+	}
+	p, err := c.gc.CreateRootProcess(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %q: %w", args[0], err)
+	}
+	gp := &guestProcess{Process: p, args: args, stderrDone: make(chan struct{})}
+	go func() {
+		defer close(gp.stderrDone)
+		_, _, r := p.Stdio()
+		// drain stderr fully so the process is never blocked writing to it
+		_, _ = io.Copy(&limitedWriter{w: &gp.stderr, n: maxCheckpointErrorOutput}, r)
+	}()
+	return gp, nil
+}
+
+// result returns an error, including the process's output, if the exited process
+// did not exit with code 0.
+func (p *guestProcess) result() error {
+	<-p.stderrDone
+	code, err := p.ExitCode()
+	if err != nil {
+		return fmt.Errorf("failed to get %q exit code: %w", p.args[0], err)
+	}
+	if code != 0 {
+		return fmt.Errorf("%q exited with code %d: %s", strings.Join(p.args, " "), code, strings.TrimSpace(p.stderr.String()))
+	}
+	return nil
+}
+
+// runInGuest runs `args` as an external process in the guest with
+// [Container.startInGuest] and waits for it to exit, returning its output as part
+// of the error if it fails.
+func (c *Container) runInGuest(ctx context.Context, args []string) error {
+	p, err := c.startInGuest(ctx, args)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_, _ = p.Kill(context.WithoutCancel(ctx))
+		case <-done:
+		}
+	}()
+
+	if err := p.Wait(); err != nil {
+		return fmt.Errorf("failed to wait for %q: %w", args[0], err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return p.result()
+}
+
+// limitedWriter writes at most n bytes to w, and silently discards the rest.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(b []byte) (int, error) {
+	if l.n > 0 {
+		s := b
+		if len(s) > l.n {
+			s = s[:l.n]
+		}
+		n, err := l.w.Write(s)
+		l.n -= n
+		if err != nil {
+			return n, err
+		}
+	}
+	return len(b), nil
+}
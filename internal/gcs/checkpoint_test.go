@@ -0,0 +1,60 @@
+//go:build windows
+
+package gcs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_checkpointArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     CheckpointOptions
+		expected []string
+	}{
+		{
+			name:     "Default",
+			expected: []string{"runc", "checkpoint", "--image-path", "/run/ckpt", "c1"},
+		},
+		{
+			name: "AllOptions",
+			opts: CheckpointOptions{LeaveRunning: true, TCPEstablished: true, FileLocks: true},
+			expected: []string{"runc", "checkpoint", "--image-path", "/run/ckpt",
+				"--leave-running", "--tcp-established", "--file-locks", "c1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if args := checkpointArgs("c1", "/run/ckpt", &tc.opts); !reflect.DeepEqual(args, tc.expected) {
+				t.Fatalf("expected args %v, got %v", tc.expected, args)
+			}
+		})
+	}
+}
+
+func Test_restoreArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     CheckpointOptions
+		expected []string
+	}{
+		{
+			name: "Default",
+			expected: []string{"runc", "restore", "--image-path", "/run/ckpt",
+				"--bundle", "/run/gcs/c/c1", "--pid-file", "/run/gcs/c/c1/restore.pid", "c1"},
+		},
+		{
+			name: "LeaveRunningIgnored",
+			opts: CheckpointOptions{LeaveRunning: true, TCPEstablished: true, FileLocks: true},
+			expected: []string{"runc", "restore", "--image-path", "/run/ckpt",
+				"--bundle", "/run/gcs/c/c1", "--pid-file", "/run/gcs/c/c1/restore.pid",
+				"--tcp-established", "--file-locks", "c1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if args := restoreArgs("c1", "/run/ckpt", &tc.opts); !reflect.DeepEqual(args, tc.expected) {
+				t.Fatalf("expected args %v, got %v", tc.expected, args)
+			}
+		})
+	}
+}
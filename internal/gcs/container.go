@@ -4,7 +4,9 @@ package gcs
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/schemaversion"
 	"go.opencensus.io/trace"
 )
 
@@ -31,18 +34,45 @@ type Container struct {
 	waitBlock chan struct{}
 	// waitError indicates the container termination error if any
 	waitError error
+
+	// checkpointMu serializes checkpoint and restore operations
+	checkpointMu sync.Mutex
+	// checkpoint is the options the container was last checkpointed with, if any
+	checkpoint *CheckpointOptions
 }
 
 var _ cow.Container = &Container{}
 
+// CreateContainerOpt is an option for [GuestConnection.CreateContainer].
+type CreateContainerOpt func(*prot.ContainerCreate)
+
+// WithHostnameSuffix has a Linux guest add `suffix`, a DNS domain, to the search
+// domains of the container's /etc/resolv.conf when it creates the container.
+func WithHostnameSuffix(suffix string) CreateContainerOpt {
+	return func(req *prot.ContainerCreate) {
+		req.HostnameSuffix = suffix
+	}
+}
+
 // CreateContainer creates a container using ID `cid` and `cfg`. The request
 // will likely not be cancellable even if `ctx` becomes done.
-func (gc *GuestConnection) CreateContainer(ctx context.Context, cid string, config interface{}) (_ *Container, err error) {
+func (gc *GuestConnection) CreateContainer(ctx context.Context, cid string, config interface{}, opts ...CreateContainerOpt) (_ *Container, err error) {
 	ctx, span := oc.StartSpan(ctx, "gcs::GuestConnection::CreateContainer", oc.WithClientSpanKind)
 	defer span.End()
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("cid", cid))
 
+	if config != nil {
+		// reject malformed documents here, rather than with an opaque error from the GCS
+		b, err := json.Marshal(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal container config: %w", err)
+		}
+		if err := ValidateContainerConfig(string(b), *schemaversion.SchemaV21()); err != nil {
+			return nil, err
+		}
+	}
+
 	c := &Container{
 		gc:        gc,
 		id:        cid,
@@ -58,9 +88,14 @@ func (gc *GuestConnection) CreateContainer(ctx context.Context, cid string, conf
 		RequestBase:     makeRequest(ctx, cid),
 		ContainerConfig: prot.AnyInString{Value: config},
 	}
+	for _, o := range opts {
+		o(&req)
+	}
 	var resp prot.ContainerCreateResponse
 	err = gc.brdg.RPC(ctx, prot.RPCCreate, &req, &resp, false)
 	if err != nil {
+		// the container was not created, so the id can be used again
+		gc.cancelNotify(cid, c.notifyCh)
 		return nil, err
 	}
 	go c.waitBackground()
@@ -104,6 +139,9 @@ func (c *Container) Close() error {
 		span.AddAttributes(trace.StringAttribute("cid", c.id))
 
 		close(c.closeCh)
+		if c.gc.statistics != nil {
+			c.gc.statistics.remove(c.id)
+		}
 	})
 	return nil
 }
@@ -115,7 +153,7 @@ func (c *Container) CreateProcess(ctx context.Context, config interface{}) (_ co
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("cid", c.id))
 
-	return c.gc.exec(ctx, c.id, config)
+	return c.gc.exec(ctx, c.id, config, false)
 }
 
 // ID returns the container's ID.
@@ -124,18 +162,31 @@ func (c *Container) ID() string {
 }
 
 // Modify sends a modify request to the container.
-func (c *Container) Modify(ctx context.Context, config interface{}) (err error) {
+func (c *Container) Modify(ctx context.Context, config interface{}) error {
+	_, err := c.ModifySettings(ctx, config)
+	return err
+}
+
+// ModifySettings is [Container.Modify], returning the warnings the guest
+// reported for the modification.
+func (c *Container) ModifySettings(ctx context.Context, config interface{}) (_ *ModifyResult, err error) {
 	ctx, span := oc.StartSpan(ctx, "gcs::Container::Modify", oc.WithClientSpanKind)
 	defer span.End()
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("cid", c.id))
 
+	if err := c.gc.checkResourceType(config); err != nil {
+		return nil, err
+	}
 	req := prot.ContainerModifySettings{
 		RequestBase: makeRequest(ctx, c.id),
 		Request:     config,
 	}
 	var resp prot.ResponseBase
-	return c.gc.brdg.RPC(ctx, prot.RPCModifySettings, &req, &resp, false)
+	if err := c.gc.brdg.RPC(ctx, prot.RPCModifySettings, &req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &ModifyResult{warnings: resp.Warnings}, nil
 }
 
 // Properties returns the requested container properties targeting a V1 schema prot.Container.
@@ -164,16 +215,23 @@ func (c *Container) PropertiesV2(ctx context.Context, types ...hcsschema.Propert
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("cid", c.id))
 
-	req := prot.ContainerGetPropertiesV2{
-		RequestBase: makeRequest(ctx, c.id),
-		Query:       prot.ContainerPropertiesQueryV2{PropertyTypes: types},
+	if c.gc.statistics != nil && len(types) == 1 && types[0] == hcsschema.PTStatistics && !c.closed() {
+		props, err := c.gc.statistics.get(ctx, c.id)
+		if !errors.Is(err, errStatisticsClosed) {
+			return props, err
+		}
 	}
-	var resp prot.ContainerGetPropertiesResponseV2
-	err = c.gc.brdg.RPC(ctx, prot.RPCGetProperties, &req, &resp, true)
-	if err != nil {
-		return nil, err
+	return c.gc.propertiesV2(ctx, c.id, types)
+}
+
+// closed returns if the container was closed.
+func (c *Container) closed() bool {
+	select {
+	case <-c.closeCh:
+		return true
+	default:
+		return false
 	}
-	return (*hcsschema.Properties)(&resp.Properties), nil
 }
 
 // Start starts the container.
@@ -10,17 +10,21 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Microsoft/go-winio"
 	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/gcs/prot"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"go.opencensus.io/trace"
@@ -64,6 +68,23 @@ type GuestConnectionConfig struct {
 	IoListen IoListenFunc
 	// InitGuestState specifies settings to apply to the guest on creation/start. This includes things such as the timezone for the VM.
 	InitGuestState *InitialGuestState
+	// OnFirstModify, if set, is called with the time taken by the first
+	// successful modify request sent to the null container.
+	OnFirstModify func(ctx context.Context, d time.Duration)
+	// StatisticsInterval is the interval at which the statistics of the
+	// containers whose statistics are requested are sampled, together, and
+	// for which the statistics of a container are served from the latest
+	// sample. Defaults to 10 seconds. If negative, the statistics of each
+	// container are queried from the guest on every request.
+	StatisticsInterval time.Duration
+	// StdioPortsWarningThreshold is the number of vsock ports in use by the
+	// stdio relays of processes past which a warning is logged. Defaults to
+	// 1024.
+	StdioPortsWarningThreshold int
+	// DenyRootProcesses fails to create processes in a Linux guest, rather than
+	// in a container, that would run as root. The guest must support running
+	// these processes as another user.
+	DenyRootProcesses bool
 }
 
 // Connect establishes a GCS connection. `gcc.Conn` will be closed by this function.
@@ -73,9 +94,21 @@ func (gcc *GuestConnectionConfig) Connect(ctx context.Context, isColdStart bool)
 	defer func() { oc.SetSpanStatus(span, err) }()
 
 	gc := &GuestConnection{
-		nextPort:   firstIoChannelVsockPort,
-		notifyChs:  make(map[string]chan struct{}),
-		ioListenFn: gcc.IoListen,
+		notifyChs:     make(map[string]chan struct{}),
+		ioListenFn:    gcc.IoListen,
+		onFirstModify: gcc.OnFirstModify,
+
+		stdioPorts:        newStdioPorts(firstIoChannelVsockPort, gcc.StdioPortsWarningThreshold),
+		denyRootProcesses: gcc.DenyRootProcesses,
+	}
+	if gcc.StatisticsInterval >= 0 {
+		interval := gcc.StatisticsInterval
+		if interval == 0 {
+			interval = defaultStatisticsInterval
+		}
+		gc.statistics = newStatisticsAggregator(interval, func(ctx context.Context, ids []string) (map[string]*hcsschema.Properties, map[string]error) {
+			return gc.containersProperties(ctx, ids, []hcsschema.PropertyType{hcsschema.PTStatistics})
+		})
 	}
 	gc.brdg = newBridge(gcc.Conn, gc.notify, gcc.Log)
 	gc.brdg.Start()
@@ -96,10 +129,28 @@ type GuestConnection struct {
 	brdg       *bridge
 	ioListenFn IoListenFunc
 	mu         sync.Mutex
-	nextPort   uint32
 	notifyChs  map[string]chan struct{}
 	caps       GuestDefinedCapabilities
 	os         string
+	// resourceTypes are the resource types of the modify settings requests the
+	// guest handles, or nil if it does not report them.
+	resourceTypes []guestrequest.ResourceType
+	// maxProcesses is the maximum number of processes the guest runs at once,
+	// or zero if it is unlimited or not reported.
+	maxProcesses uint32
+
+	// gcsStartTime is when the GCS started, on the host's clock.
+	gcsStartTime  time.Time
+	onFirstModify func(ctx context.Context, d time.Duration)
+	firstModify   sync.Once
+
+	// statistics samples the statistics of containers, or is nil if they are
+	// queried on every request.
+	statistics *statisticsAggregator
+	// stdioPorts is protected by mu.
+	stdioPorts *stdioPorts
+
+	denyRootProcesses bool
 }
 
 var _ cow.ProcessHost = &GuestConnection{}
@@ -109,6 +160,18 @@ func (gc *GuestConnection) Capabilities() GuestDefinedCapabilities {
 	return gc.caps
 }
 
+// GCSStartTime returns when the GCS started, on the host's clock, or the zero
+// time if the GCS did not report it.
+func (gc *GuestConnection) GCSStartTime() time.Time {
+	return gc.gcsStartTime
+}
+
+// MaxConcurrentProcesses returns the maximum number of processes the guest runs
+// at once, or zero if it is unlimited or not reported.
+func (gc *GuestConnection) MaxConcurrentProcesses() uint32 {
+	return gc.maxProcesses
+}
+
 // Protocol returns the protocol version that is in use.
 func (gc *GuestConnection) Protocol() uint32 {
 	return protocolVersion
@@ -130,6 +193,9 @@ func (gc *GuestConnection) connect(ctx context.Context, isColdStart bool, initGu
 	if resp.Version != protocolVersion {
 		return fmt.Errorf("unexpected version %d returned", resp.Version)
 	}
+	if resp.GcsRunTime > 0 {
+		gc.gcsStartTime = time.Now().Add(-time.Duration(resp.GcsRunTime))
+	}
 
 	gc.os = strings.ToLower(resp.Capabilities.RuntimeOsType)
 	if gc.os == "" {
@@ -140,6 +206,8 @@ func (gc *GuestConnection) connect(ctx context.Context, isColdStart bool, initGu
 	if err != nil {
 		return fmt.Errorf("unmarshalGuestCapabilities: %w", err)
 	}
+	gc.resourceTypes = resp.Capabilities.SupportedResourceTypes
+	gc.maxProcesses = resp.Capabilities.MaxConcurrentProcesses
 
 	if isColdStart && resp.Capabilities.SendHostCreateMessage {
 		conf := &prot.UvmConfig{
@@ -169,19 +237,73 @@ func (gc *GuestConnection) connect(ctx context.Context, isColdStart bool, initGu
 	return nil
 }
 
+// ModifyResult is the result of a modify settings request that succeeded.
+type ModifyResult struct {
+	warnings []string
+}
+
+// Warnings returns the caveats the guest reported for the modification, such
+// as a requested value being adjusted to one the guest supports.
+func (r *ModifyResult) Warnings() []string {
+	return r.warnings
+}
+
 // Modify sends a modify settings request to the null container. This is
 // generally used to prepare virtual hardware that has been added to the guest.
-func (gc *GuestConnection) Modify(ctx context.Context, settings interface{}) (err error) {
+func (gc *GuestConnection) Modify(ctx context.Context, settings interface{}) error {
+	_, err := gc.ModifySettings(ctx, settings)
+	return err
+}
+
+// ModifySettings is [GuestConnection.Modify], returning the warnings the guest
+// reported for the modification.
+func (gc *GuestConnection) ModifySettings(ctx context.Context, settings interface{}) (_ *ModifyResult, err error) {
 	ctx, span := oc.StartSpan(ctx, "gcs::GuestConnection::Modify", oc.WithClientSpanKind)
 	defer span.End()
 	defer func() { oc.SetSpanStatus(span, err) }()
 
+	if err := gc.checkResourceType(settings); err != nil {
+		return nil, err
+	}
 	req := prot.ContainerModifySettings{
 		RequestBase: makeRequest(ctx, nullContainerID),
 		Request:     settings,
 	}
 	var resp prot.ResponseBase
-	return gc.brdg.RPC(ctx, prot.RPCModifySettings, &req, &resp, false)
+	start := time.Now()
+	if err := gc.brdg.RPC(ctx, prot.RPCModifySettings, &req, &resp, false); err != nil {
+		return nil, err
+	}
+	if gc.onFirstModify != nil {
+		d := time.Since(start)
+		gc.firstModify.Do(func() { gc.onFirstModify(ctx, d) })
+	}
+	return &ModifyResult{warnings: resp.Warnings}, nil
+}
+
+// checkResourceType fails, with E_NOTIMPL, a modify settings request for a
+// resource type the guest did not report that it handles. Requests are not
+// checked if the guest does not report the resource types it handles.
+func (gc *GuestConnection) checkResourceType(settings interface{}) error {
+	if gc.resourceTypes == nil {
+		return nil
+	}
+	var rt guestrequest.ResourceType
+	switch r := settings.(type) {
+	case guestrequest.ModificationRequest:
+		rt = r.ResourceType
+	case *guestrequest.ModificationRequest:
+		rt = r.ResourceType
+	default:
+		return nil
+	}
+	if slices.Contains(gc.resourceTypes, rt) {
+		return nil
+	}
+	return &rpcError{
+		result:  int32(gcserr.HrNotImpl),
+		message: fmt.Sprintf("resource type %q is not supported by the guest", rt),
+	}
 }
 
 func (gc *GuestConnection) ModifyServiceSettings(ctx context.Context, serviceType prot.ServiceModifyPropertyType, settings interface{}) (err error) {
@@ -211,6 +333,16 @@ func (gc *GuestConnection) DumpStacks(ctx context.Context) (response string, err
 	return resp.GuestStacks, err
 }
 
+// Ping checks that the bridge to the guest is alive and returns the round trip
+// latency of the request.
+func (gc *GuestConnection) Ping(ctx context.Context) (latency time.Duration, err error) {
+	ctx, span := oc.StartSpan(ctx, "gcs::GuestConnection::Ping", oc.WithClientSpanKind)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+
+	return gc.brdg.Ping(ctx)
+}
+
 func (gc *GuestConnection) DeleteContainerState(ctx context.Context, cid string) (err error) {
 	ctx, span := oc.StartSpan(ctx, "gcs::GuestConnection::DeleteContainerState", oc.WithClientSpanKind)
 	defer span.End()
@@ -227,6 +359,9 @@ func (gc *GuestConnection) DeleteContainerState(ctx context.Context, cid string)
 // Close terminates the guest connection. It is undefined to call any other
 // methods on the connection after this is called.
 func (gc *GuestConnection) Close() error {
+	if gc.statistics != nil {
+		gc.statistics.close()
+	}
 	if gc.brdg == nil {
 		return nil
 	}
@@ -239,7 +374,20 @@ func (gc *GuestConnection) CreateProcess(ctx context.Context, settings interface
 	defer span.End()
 	defer func() { oc.SetSpanStatus(span, err) }()
 
-	return gc.exec(ctx, nullContainerID, settings)
+	return gc.exec(ctx, nullContainerID, settings, false)
+}
+
+// CreateRootProcess creates a process in the guest, rather than in a
+// container, like [GuestConnection.CreateProcess], except that it may run as
+// root even if [GuestConnectionConfig.DenyRootProcesses] is set. It is only for
+// the processes that the host runs with fixed commands, such as to collect
+// diagnostics that need root.
+func (gc *GuestConnection) CreateRootProcess(ctx context.Context, settings interface{}) (_ cow.Process, err error) {
+	ctx, span := oc.StartSpan(ctx, "gcs::GuestConnection::CreateRootProcess", oc.WithClientSpanKind)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+
+	return gc.exec(ctx, nullContainerID, settings, true)
 }
 
 // OS returns the operating system of the container's host, "windows" or "linux".
@@ -253,18 +401,28 @@ func (gc *GuestConnection) IsOCI() bool {
 	return false
 }
 
-func (gc *GuestConnection) newIoChannel() (*ioChannel, uint32, error) {
+// newIoChannel listens for a stdio relay of a process in container `cid` on a
+// newly allocated port, which must be released with releaseStdioPorts.
+func (gc *GuestConnection) newIoChannel(ctx context.Context, cid string) (*ioChannel, uint32, error) {
 	gc.mu.Lock()
-	port := gc.nextPort
-	gc.nextPort++
+	port := gc.stdioPorts.allocate(ctx, cid)
 	gc.mu.Unlock()
 	l, err := gc.ioListenFn(port)
 	if err != nil {
+		gc.releaseStdioPorts(cid, port)
 		return nil, 0, err
 	}
 	return newIoChannel(l), port, nil
 }
 
+func (gc *GuestConnection) releaseStdioPorts(cid string, ports ...uint32) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	for range ports {
+		gc.stdioPorts.release(cid)
+	}
+}
+
 func (gc *GuestConnection) requestNotify(cid string, ch chan struct{}) error {
 	gc.mu.Lock()
 	defer gc.mu.Unlock()
@@ -278,6 +436,16 @@ func (gc *GuestConnection) requestNotify(cid string, ch chan struct{}) error {
 	return nil
 }
 
+// cancelNotify removes the notification channel `ch` of container `cid`, if it
+// is still registered, for a container that was not created.
+func (gc *GuestConnection) cancelNotify(cid string, ch chan struct{}) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.notifyChs[cid] == ch {
+		delete(gc.notifyChs, cid)
+	}
+}
+
 func (gc *GuestConnection) notify(ntf *prot.ContainerNotification) error {
 	cid := ntf.ContainerID
 	gc.mu.Lock()
@@ -287,7 +455,13 @@ func (gc *GuestConnection) notify(ntf *prot.ContainerNotification) error {
 	if ch == nil {
 		return fmt.Errorf("container %s not found", cid)
 	}
-	logrus.WithField(logfields.ContainerID, cid).Info("container terminated in guest")
+	entry := logrus.WithField(logfields.ContainerID, cid)
+	if ntf.ResultInfo.Value != nil {
+		// The guest reports any processes it had to kill after the container's
+		// init process exited.
+		entry = entry.WithField("resultInfo", ntf.ResultInfo.Value)
+	}
+	entry.Info("container terminated in guest")
 	close(ch)
 	return nil
 }
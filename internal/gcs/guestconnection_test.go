@@ -17,16 +17,37 @@ import (
 
 	"github.com/Microsoft/go-winio"
 	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"go.opencensus.io/trace"
 	"go.opencensus.io/trace/tracestate"
 
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/gcs/prot"
+	guestprot "github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 )
 
 const pipePortFmt = `\\.\pipe\gctest-port-%d`
 
+// console size expected by [simpleGcsLoop] for [prot.RPCResizeConsole].
+const (
+	testConsoleWidth  = 80
+	testConsoleHeight = 24
+)
+
+// exitingProcessID is the process ID returned by [simpleGcsLoop] for processes
+// run with the "exit" command, which exit immediately.
+const exitingProcessID = 43
+
+// testMaxProcesses is the maximum number of concurrent processes reported by
+// [simpleGcsLoop].
+const testMaxProcesses = 2
+
 func npipeIoListen(port uint32) (net.Listener, error) {
 	return winio.ListenPipe(fmt.Sprintf(pipePortFmt, port), &winio.PipeConfig{
 		MessageMode: true,
@@ -61,25 +82,80 @@ func simpleGcsLoop(t *testing.T, rw io.ReadWriter) error {
 			err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, &prot.NegotiateProtocolResponse{
 				Version: protocolVersion,
 				Capabilities: prot.GcsCapabilities{
-					RuntimeOsType: "linux",
+					RuntimeOsType:          "linux",
+					SupportedResourceTypes: []guestrequest.ResourceType{guestresource.ResourceTypeNetwork},
+					MaxConcurrentProcesses: testMaxProcesses,
 				},
 			})
 			if err != nil {
 				return err
 			}
 		case prot.RPCCreate:
-			err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, &prot.ContainerCreateResponse{})
+			var req struct {
+				prot.RequestBase
+				HostnameSuffix string
+			}
+			if err := json.Unmarshal(b, &req); err != nil {
+				return err
+			}
+			resp := &prot.ContainerCreateResponse{}
+			switch {
+			case req.ContainerID == "exists":
+				resp.Result = -1070137073 // HCS_E_SYSTEM_ALREADY_EXISTS
+				resp.ErrorMessage = `container exists already exists in state "created"`
+			case req.ContainerID == "suffix" && req.HostnameSuffix != "svc.cluster.local":
+				resp.Result = int32(gcserr.HrErrInvalidArg)
+				resp.ErrorMessage = fmt.Sprintf("unexpected hostname suffix %q", req.HostnameSuffix)
+			}
+			err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, resp)
 			if err != nil {
 				return err
 			}
 		case prot.RPCExecuteProcess:
 			var req prot.ContainerExecuteProcess
-			var params baseProcessParams
+			var params struct {
+				baseProcessParams
+				OCIProcess *struct{ Args []string } `json:"OciProcess,omitempty"`
+			}
 			req.Settings.ProcessParameters.Value = &params
 			err := json.Unmarshal(b, &req)
 			if err != nil {
 				return err
 			}
+			if params.OCIProcess != nil && len(params.OCIProcess.Args) > 0 && params.OCIProcess.Args[0] == "missing" {
+				err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, &prot.ContainerExecuteProcessResponse{
+					ResponseBase: prot.ResponseBase{
+						Result:       int32(-2147024894), // ERROR_FILE_NOT_FOUND
+						ErrorMessage: `executable "missing" not found in container, tried: /bin/missing`,
+					},
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if params.OCIProcess != nil && len(params.OCIProcess.Args) > 0 && params.OCIProcess.Args[0] == "toomany" {
+				err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, &prot.ContainerExecuteProcessResponse{
+					ResponseBase: prot.ResponseBase{
+						Result:       int32(-2147024882), // E_OUTOFMEMORY
+						ErrorMessage: "cannot run more than 2 concurrent processes: 2 running and 0 starting",
+					},
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if params.OCIProcess != nil && len(params.OCIProcess.Args) > 0 && params.OCIProcess.Args[0] == "exit" {
+				// exits without the guest connecting to its stdio
+				err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, &prot.ContainerExecuteProcessResponse{
+					ProcessID: exitingProcessID,
+				})
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			var stdin, stdout, stderr net.Conn
 			if params.CreateStdInPipe {
 				stdin, err = dialPort(req.Settings.VsockStdioRelaySettings.StdIn)
@@ -87,15 +163,21 @@ func simpleGcsLoop(t *testing.T, rw io.ReadWriter) error {
 					return err
 				}
 				defer stdin.Close()
+				if params.OCIProcess != nil && len(params.OCIProcess.Args) > 0 && params.OCIProcess.Args[0] == "closestdin" {
+					// like the GCS once the process closes its stdin
+					stdin.Close()
+					stdin = nil
+				}
 			}
-			if params.CreateStdOutPipe {
+			// like the GCS, streams redirected to files are not relayed
+			if params.CreateStdOutPipe && req.Settings.StdOutFile == nil {
 				stdout, err = dialPort(req.Settings.VsockStdioRelaySettings.StdOut)
 				if err != nil {
 					return err
 				}
 				defer stdout.Close()
 			}
-			if params.CreateStdErrPipe {
+			if params.CreateStdErrPipe && req.Settings.StdErrFile == nil {
 				stderr, err = dialPort(req.Settings.VsockStdioRelaySettings.StdErr)
 				if err != nil {
 					return err
@@ -119,7 +201,72 @@ func simpleGcsLoop(t *testing.T, rw io.ReadWriter) error {
 				return err
 			}
 		case prot.RPCWaitForProcess:
-			// nothing
+			var req prot.ContainerWaitForProcess
+			if err := json.Unmarshal(b, &req); err != nil {
+				return err
+			}
+			if req.ProcessID == exitingProcessID {
+				if err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, &prot.ContainerWaitForProcessResponse{}); err != nil {
+					return err
+				}
+			}
+		case prot.RPCGetProperties:
+			var req prot.ContainerGetPropertiesV2
+			if err := json.Unmarshal(b, &req); err != nil {
+				return err
+			}
+			resp := &prot.ContainerGetPropertiesResponseV2{}
+			if len(req.Query.ContainerIDs) != 0 {
+				resp.Properties.Containers = make(map[string]*hcsschema.Properties)
+				resp.Properties.ContainerErrors = make(map[string]hcsschema.PropertiesError)
+				for _, cid := range req.Query.ContainerIDs {
+					if cid == "missing" {
+						resp.Properties.ContainerErrors[cid] = hcsschema.PropertiesError{
+							Result:  -1070137074, // HCS_E_SYSTEM_NOT_FOUND
+							Message: "container not found",
+						}
+						continue
+					}
+					resp.Properties.Containers[cid] = &hcsschema.Properties{Id: cid}
+				}
+			} else if req.ContainerID == "missing" {
+				resp.Result = -1070137074 // HCS_E_SYSTEM_NOT_FOUND
+				resp.ErrorMessage = "container not found"
+			} else {
+				resp.Properties.Id = req.ContainerID
+			}
+			if err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, resp); err != nil {
+				return err
+			}
+		case prot.RPCResizeConsole:
+			var req prot.ContainerResizeConsole
+			if err := json.Unmarshal(b, &req); err != nil {
+				return err
+			}
+			resp := &prot.ResponseBase{}
+			if req.ProcessID != 42 || req.Width != testConsoleWidth || req.Height != testConsoleHeight {
+				resp.Result = -2147024809 // E_INVALIDARG
+				resp.ErrorMessage = fmt.Sprintf("unexpected resize of process %d to %dx%d", req.ProcessID, req.Width, req.Height)
+			}
+			if err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, resp); err != nil {
+				return err
+			}
+		case prot.RPCModifySettings:
+			var req prot.ContainerModifySettings
+			if err := json.Unmarshal(b, &req); err != nil {
+				return err
+			}
+			resp := &prot.ResponseBase{}
+			switch req.ContainerID {
+			case "rounded":
+				resp.Warnings = []string{"CPU frequency rounded to 2000 MHz"}
+			case "corrupt":
+				resp.Result = int32(gcserr.HrErrDiskCorrupt)
+				resp.ErrorMessage = "ext4 filesystem on /dev/sdb is corrupt: structure needs cleaning"
+			}
+			if err := sendJSON(t, rw, prot.MsgTypeResponse|prot.MsgType(proc), id, resp); err != nil {
+				return err
+			}
 		case prot.RPCShutdownForced:
 			var req prot.RequestBase
 			err = json.Unmarshal(b, &req)
@@ -183,6 +330,31 @@ func TestGcsCreateContainer(t *testing.T) {
 	c.Close()
 }
 
+func TestGcsCreateContainerHostnameSuffix(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	if _, err := gc.CreateContainer(context.Background(), "suffix", nil); err == nil {
+		t.Fatal("expected the guest to fail a create without the hostname suffix")
+	}
+	c, err := gc.CreateContainer(context.Background(), "suffix", nil, WithHostnameSuffix("svc.cluster.local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestGcsCreateContainerAlreadyExists(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	// the failed create must not keep the id from being used by a retry
+	for i := 0; i < 2; i++ {
+		_, err := gc.CreateContainer(context.Background(), "exists", nil)
+		if !hcs.IsAlreadyExists(err) {
+			t.Fatalf("attempt %d: expected an already exists error from the guest, got %v", i, err)
+		}
+	}
+}
+
 func TestGcsWaitContainer(t *testing.T) {
 	gc := connectGcs(context.Background(), t)
 	defer gc.Close()
@@ -246,6 +418,331 @@ func TestGcsCreateProcess(t *testing.T) {
 	}
 }
 
+func TestGcsProcessStdinClosed(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	p, err := gc.CreateProcess(context.Background(), &struct {
+		baseProcessParams
+		OCIProcess *specs.Process `json:"OciProcess,omitempty"`
+	}{
+		baseProcessParams: baseProcessParams{CreateStdInPipe: true},
+		OCIProcess:        &specs.Process{Args: []string{"closestdin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	select {
+	case <-p.(*Process).StdinClosed():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for stdin to be closed")
+	}
+}
+
+func TestGcsCreateProcessExecutableNotFound(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	_, err := gc.CreateProcess(context.Background(), &struct {
+		baseProcessParams
+		OCIProcess *specs.Process `json:"OciProcess,omitempty"`
+	}{
+		OCIProcess: &specs.Process{Args: []string{"missing"}},
+	})
+	if !errors.Is(err, ErrExecutableNotFound) {
+		t.Fatalf("expected %v, got %v", ErrExecutableNotFound, err)
+	}
+	if !strings.Contains(err.Error(), "/bin/missing") {
+		t.Fatalf("expected error to contain the guest's message, got %v", err)
+	}
+}
+
+func TestGcsCreateProcessTooMany(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	if n := gc.MaxConcurrentProcesses(); n != testMaxProcesses {
+		t.Fatalf("expected a maximum of %d processes, got %d", testMaxProcesses, n)
+	}
+	_, err := gc.CreateProcess(context.Background(), &struct {
+		baseProcessParams
+		OCIProcess *specs.Process `json:"OciProcess,omitempty"`
+	}{
+		OCIProcess: &specs.Process{Args: []string{"toomany"}},
+	})
+	if !errors.Is(err, ErrTooManyProcesses) {
+		t.Fatalf("expected %v, got %v", ErrTooManyProcesses, err)
+	}
+}
+
+type stdioFileTestParams struct {
+	baseProcessParams
+	stdoutFile *prot.ExecuteProcessStdioFileSettings
+}
+
+func (p *stdioFileTestParams) StdioFiles() (stdout, stderr *prot.ExecuteProcessStdioFileSettings) {
+	return p.stdoutFile, nil
+}
+
+func TestGcsCreateProcessStdioFile(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	p, err := gc.CreateProcess(context.Background(), &stdioFileTestParams{
+		baseProcessParams: baseProcessParams{
+			CreateStdInPipe:  true,
+			CreateStdOutPipe: true,
+			CreateStdErrPipe: true,
+		},
+		stdoutFile: &prot.ExecuteProcessStdioFileSettings{Path: "/var/log/out.log"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	stdoutFile, stderrFile := p.(*Process).StdioFiles()
+	if stdoutFile != "/var/log/out.log" || stderrFile != "" {
+		t.Fatalf("unexpected stdio files %q and %q", stdoutFile, stderrFile)
+	}
+	// no relay is set up for stdout
+	if stats := gc.StdioPorts(); stats.InUse != 2 {
+		t.Fatalf("expected 2 stdio ports in use, got %+v", stats)
+	}
+}
+
+func TestGcsCreateProcessUser(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+
+	// the guest does not report that it honors the user, so it would run the
+	// process as root
+	if _, err := gc.CreateProcess(context.Background(), &baseProcessParams{User: "diag"}); err == nil {
+		t.Fatal("expected process with a user to fail")
+	}
+	gc.denyRootProcesses = true
+	if _, err := gc.CreateProcess(context.Background(), &baseProcessParams{}); err == nil {
+		t.Fatal("expected process to fail when root is denied")
+	}
+	// unless it is explicitly exempt
+	p, err := gc.CreateRootProcess(context.Background(), &baseProcessParams{})
+	if err != nil {
+		t.Fatalf("failed to create root process when root is denied: %v", err)
+	}
+	p.Close()
+
+	gc.caps = &LCOWGuestDefinedCapabilities{
+		GcsGuestCapabilities: guestprot.GcsGuestCapabilities{ExternalProcessUserSupported: true},
+	}
+	p, err = gc.CreateProcess(context.Background(), &baseProcessParams{User: "diag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+}
+
+type execNamespaceTestParams struct {
+	baseProcessParams
+	namespace *guestresource.LCOWExecNamespaceConfig
+}
+
+func (p *execNamespaceTestParams) ExecNamespace() *guestresource.LCOWExecNamespaceConfig {
+	return p.namespace
+}
+
+func TestGcsCreateProcessNamespace(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+
+	if _, err := gc.CreateProcess(context.Background(), &execNamespaceTestParams{
+		namespace: &guestresource.LCOWExecNamespaceConfig{},
+	}); err == nil {
+		t.Fatal("expected process joining the namespaces of no container to fail")
+	}
+
+	params := &execNamespaceTestParams{
+		namespace: &guestresource.LCOWExecNamespaceConfig{ContainerID: "c"},
+	}
+	c := &Container{gc: gc, id: "c"}
+	if _, err := c.CreateProcess(context.Background(), params); err == nil {
+		t.Fatal("expected process in a container joining the namespaces of a container to fail")
+	}
+	// the process is exec'd in the container rather than in the guest
+	p, err := gc.CreateProcess(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if cid := p.(*Process).cid; cid != "c" {
+		t.Fatalf("expected process in container c, got %q", cid)
+	}
+}
+
+func TestGcsModifySettingsWarnings(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+
+	res, err := gc.ModifySettings(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := res.Warnings(); len(w) != 0 {
+		t.Fatalf("expected no warnings, got %v", w)
+	}
+
+	c := &Container{gc: gc, id: "rounded"}
+	res, err = c.ModifySettings(context.Background(), struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := res.Warnings(); len(w) != 1 || w[0] != "CPU frequency rounded to 2000 MHz" {
+		t.Fatalf("unexpected warnings %v", w)
+	}
+}
+
+func TestGcsModifySettingsDiskCorrupt(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+
+	c := &Container{gc: gc, id: "corrupt"}
+	err := c.Modify(context.Background(), struct{}{})
+	if !IsDiskCorrupt(fmt.Errorf("mount: %w", err)) {
+		t.Fatalf("expected a corrupt disk error, got %v", err)
+	}
+	if err := gc.Modify(context.Background(), struct{}{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestGcsModifySettingsUnsupportedResourceType(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+
+	if _, err := gc.ModifySettings(context.Background(), &guestrequest.ModificationRequest{
+		ResourceType: guestresource.ResourceTypeNetwork,
+		RequestType:  guestrequest.RequestTypeAdd,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Container{gc: gc, id: "c"}
+	for _, settings := range []interface{}{
+		guestrequest.ModificationRequest{ResourceType: guestresource.ResourceTypeHostAliases},
+		&guestrequest.ModificationRequest{ResourceType: guestresource.ResourceTypeHostAliases},
+	} {
+		_, err := c.ModifySettings(context.Background(), settings)
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) || rpcErr.result != int32(gcserr.HrNotImpl) {
+			t.Fatalf("expected E_NOTIMPL, got %v", err)
+		}
+	}
+}
+
+func TestGcsProcessResizeConsole(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	p, err := gc.CreateProcess(context.Background(), &baseProcessParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.ResizeConsole(context.Background(), testConsoleWidth, testConsoleHeight); err != nil {
+		t.Fatalf("failed to resize console: %v", err)
+	}
+
+	for _, size := range [][2]uint16{{0, testConsoleHeight}, {testConsoleWidth, 0}, {0, 0}} {
+		err := p.ResizeConsole(context.Background(), size[0], size[1])
+		if !errors.Is(err, ErrInvalidConsoleSize) {
+			t.Errorf("expected %v for size %dx%d, got: %v", ErrInvalidConsoleSize, size[0], size[1], err)
+		}
+	}
+}
+
+func TestGcsContainersProperties(t *testing.T) {
+	t.Run("Parallel", func(t *testing.T) {
+		testGcsContainersProperties(t, false)
+	})
+	t.Run("MultiContainer", func(t *testing.T) {
+		testGcsContainersProperties(t, true)
+	})
+}
+
+func testGcsContainersProperties(t *testing.T, multiContainer bool) {
+	t.Helper()
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	gc.caps = &LCOWGuestDefinedCapabilities{
+		GcsGuestCapabilities: guestprot.GcsGuestCapabilities{MultiContainerPropertiesSupported: multiContainer},
+	}
+	ids := []string{"foo", "missing", "bar", "baz"}
+	props, errs := gc.containersProperties(context.Background(), ids, []hcsschema.PropertyType{hcsschema.PTStatistics})
+	if len(errs) != 1 || !hcs.IsNotExist(errs["missing"]) {
+		t.Fatalf("expected only the missing container not to exist, got %v", errs)
+	}
+	if len(props) != len(ids)-1 {
+		t.Fatalf("expected properties for %d containers, got %d", len(ids)-1, len(props))
+	}
+	for _, id := range ids {
+		p, ok := props[id]
+		if id == "missing" {
+			if ok {
+				t.Fatalf("unexpected properties for missing container: %+v", p)
+			}
+			continue
+		}
+		if !ok || p.Id != id {
+			t.Fatalf("unexpected properties for container %s: %+v", id, p)
+		}
+	}
+}
+
+func TestGcsStdioPortsReleased(t *testing.T) {
+	gc := connectGcs(context.Background(), t)
+	defer gc.Close()
+	for i := 0; i < 300; i++ {
+		p, err := gc.CreateProcess(context.Background(), &struct {
+			baseProcessParams
+			OCIProcess *specs.Process `json:"OciProcess,omitempty"`
+		}{
+			baseProcessParams: baseProcessParams{
+				CreateStdInPipe:  true,
+				CreateStdOutPipe: true,
+				CreateStdErrPipe: true,
+			},
+			OCIProcess: &specs.Process{Args: []string{"exit"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		// the ports of half the processes are only released by their exit
+		if i%2 == 0 {
+			p.Close()
+		}
+		waitStdioPortsReleased(t, gc)
+	}
+
+	// ports are reused, rather than allocating new ones for every process
+	if stats := gc.StdioPorts(); stats.Allocated != 3 {
+		t.Fatalf("expected stdio ports to be reused, %d ports were allocated", stats.Allocated)
+	}
+}
+
+// waitStdioPortsReleased waits for all the stdio ports of processes to be
+// released, as exited processes release them in the background.
+func waitStdioPortsReleased(t *testing.T, gc *GuestConnection) {
+	t.Helper()
+	var stats StdioPortStats
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		if stats = gc.StdioPorts(); stats.InUse == 0 {
+			break
+		}
+	}
+	if stats.InUse != 0 || len(stats.Containers) != 0 {
+		t.Fatalf("expected no stdio ports in use, got %+v", stats)
+	}
+}
+
 func TestGcsWaitProcessBridgeTerminated(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
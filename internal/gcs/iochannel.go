@@ -40,6 +40,15 @@ func (c *ioChannel) Close() error {
 	return nil
 }
 
+// closeListener stops listening for the connection, if it was not accepted
+// yet, leaving an accepted connection open.
+func (c *ioChannel) closeListener() {
+	if c == nil {
+		return
+	}
+	c.l.Close()
+}
+
 type closeWriter interface {
 	CloseWrite() error
 }
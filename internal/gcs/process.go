@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"syscall"
 
 	"github.com/Microsoft/go-winio"
 	"github.com/Microsoft/hcsshim/internal/cow"
@@ -16,14 +17,30 @@ import (
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/sirupsen/logrus"
 	"go.opencensus.io/trace"
 )
 
 const (
-	hrNotFound = 0x80070490
+	hrNotFound     = 0x80070490
+	hrFileNotFound = 0x80070002
+	hrOutOfMemory  = 0x8007000E
 )
 
+// ErrInvalidConsoleSize is returned by [Process.ResizeConsole] if the width or
+// height is zero.
+var ErrInvalidConsoleSize = errors.New("console width and height must be non-zero")
+
+// ErrExecutableNotFound is returned when creating a process with shell
+// candidates in a Linux container whose executable, and any fallback shell,
+// does not exist in the container.
+var ErrExecutableNotFound = errors.New("executable not found in container")
+
+// ErrTooManyProcesses is returned when creating a process in a Linux guest that
+// already runs the maximum number of concurrent processes it reports.
+var ErrTooManyProcesses = errors.New("guest is running its maximum number of processes")
+
 // Process represents a process in a container or container host.
 type Process struct {
 	gc                    *GuestConnection
@@ -34,15 +51,80 @@ type Process struct {
 	stdin, stdout, stderr *ioChannel
 	stdinCloseWriteOnce   sync.Once
 	stdinCloseWriteErr    error
+	// stdinClosed is closed once the guest closes its end of stdin.
+	stdinClosed chan struct{}
+	// ports are the ports of the stdio relays, released by releasePorts.
+	ports            []uint32
+	releasePortsOnce sync.Once
+	// stdoutFile and stderrFile are the guest files that stdout and stderr
+	// are written to instead of being relayed.
+	stdoutFile, stderrFile string
 }
 
 var _ cow.Process = &Process{}
 
 type baseProcessParams struct {
 	CreateStdInPipe, CreateStdOutPipe, CreateStdErrPipe bool
+	User                                                string `json:",omitempty"`
+}
+
+// stdioFileParams is implemented by process parameters that redirect the
+// stdout or stderr of the process to files in the guest. A nil file is relayed
+// as requested by the parameters.
+type stdioFileParams interface {
+	StdioFiles() (stdout, stderr *prot.ExecuteProcessStdioFileSettings)
+}
+
+// execNamespaceParams is implemented by the parameters of a process created in
+// the guest that is run in the namespaces of a container instead.
+type execNamespaceParams interface {
+	ExecNamespace() *guestresource.LCOWExecNamespaceConfig
 }
 
-func (gc *GuestConnection) exec(ctx context.Context, cid string, params interface{}) (_ cow.Process, err error) {
+// checkProcessUser fails if a process in a Linux guest, rather than in a
+// container, is requested to run as `user` or denied from running as root, and
+// the guest would ignore it and run the process as root.
+func (gc *GuestConnection) checkProcessUser(user string, denyRoot bool) error {
+	if user == "" && !denyRoot {
+		return nil
+	}
+	if lc := GetLCOWCapabilities(gc.caps); lc == nil || !lc.ExternalProcessUserSupported {
+		return errors.New("guest does not support running processes in the utility VM as a user other than root")
+	}
+	return nil
+}
+
+// execNamespace returns the container that a process created in container
+// `cid` with `params` is exec'd in instead, to join its namespaces, or `cid`.
+//
+// Only a process in a Linux guest, rather than in a container, can join the
+// namespaces of a container. It is exec'd in the container, so the guest
+// enforces the container's exec policy and runs it with the container's
+// capabilities, seccomp profile and cgroup, as for any exec in the container.
+func (gc *GuestConnection) execNamespace(cid string, params interface{}) (string, error) {
+	np, ok := params.(execNamespaceParams)
+	if !ok || np.ExecNamespace() == nil {
+		return cid, nil
+	}
+	if cid != nullContainerID || gc.os == "windows" {
+		return "", errors.New("exec'ing in the namespaces of a container is only supported for processes in a Linux utility VM")
+	}
+	ns := np.ExecNamespace()
+	if ns.ContainerID == "" || ns.ContainerID == nullContainerID {
+		return "", errors.New("exec'ing in the namespaces of a container requires the ID of a container")
+	}
+	return ns.ContainerID, nil
+}
+
+// exec creates a process with `params` in the container `cid`, or in the guest
+// for [nullContainerID], unless [execNamespaceParams] names another container
+// to exec it in. If `allowRoot` is set, a process in a Linux guest may
+// run as root even if the connection denies it.
+func (gc *GuestConnection) exec(ctx context.Context, cid string, params interface{}, allowRoot bool) (_ cow.Process, err error) {
+	cid, err = gc.execNamespace(cid, params)
+	if err != nil {
+		return nil, err
+	}
 	b, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
@@ -67,6 +149,28 @@ func (gc *GuestConnection) exec(ctx context.Context, cid string, params interfac
 		}
 	}()
 
+	if sf, ok := params.(stdioFileParams); ok {
+		req.Settings.StdOutFile, req.Settings.StdErrFile = sf.StdioFiles()
+		if (req.Settings.StdOutFile != nil || req.Settings.StdErrFile != nil) && gc.os == "windows" {
+			return nil, errors.New("redirecting stdio to files is only supported in Linux guests")
+		}
+	}
+	if cid == nullContainerID && gc.os != "windows" {
+		denyRoot := gc.denyRootProcesses && !allowRoot
+		if err := gc.checkProcessUser(bp.User, denyRoot); err != nil {
+			return nil, err
+		}
+		req.Settings.DenyRootUser = denyRoot
+	}
+	if f := req.Settings.StdOutFile; f != nil {
+		p.stdoutFile = f.Path
+		bp.CreateStdOutPipe = false
+	}
+	if f := req.Settings.StdErrFile; f != nil {
+		p.stderrFile = f.Path
+		bp.CreateStdErrPipe = false
+	}
+
 	// Construct the stdio channels. Windows guests expect hvsock service IDs
 	// instead of vsock ports.
 	var hvsockSettings prot.ExecuteProcessStdioRelaySettings
@@ -77,26 +181,31 @@ func (gc *GuestConnection) exec(ctx context.Context, cid string, params interfac
 		req.Settings.VsockStdioRelaySettings = &vsockSettings
 	}
 	if bp.CreateStdInPipe {
-		p.stdin, vsockSettings.StdIn, err = gc.newIoChannel()
+		p.stdin, vsockSettings.StdIn, err = gc.newIoChannel(ctx, cid)
 		if err != nil {
 			return nil, err
 		}
+		p.ports = append(p.ports, vsockSettings.StdIn)
 		g := winio.VsockServiceID(vsockSettings.StdIn)
 		hvsockSettings.StdIn = &g
+		p.stdinClosed = make(chan struct{})
+		go p.watchStdinClosed()
 	}
 	if bp.CreateStdOutPipe {
-		p.stdout, vsockSettings.StdOut, err = gc.newIoChannel()
+		p.stdout, vsockSettings.StdOut, err = gc.newIoChannel(ctx, cid)
 		if err != nil {
 			return nil, err
 		}
+		p.ports = append(p.ports, vsockSettings.StdOut)
 		g := winio.VsockServiceID(vsockSettings.StdOut)
 		hvsockSettings.StdOut = &g
 	}
 	if bp.CreateStdErrPipe {
-		p.stderr, vsockSettings.StdErr, err = gc.newIoChannel()
+		p.stderr, vsockSettings.StdErr, err = gc.newIoChannel(ctx, cid)
 		if err != nil {
 			return nil, err
 		}
+		p.ports = append(p.ports, vsockSettings.StdErr)
 		g := winio.VsockServiceID(vsockSettings.StdErr)
 		hvsockSettings.StdErr = &g
 	}
@@ -104,6 +213,12 @@ func (gc *GuestConnection) exec(ctx context.Context, cid string, params interfac
 	var resp prot.ContainerExecuteProcessResponse
 	err = gc.brdg.RPC(ctx, prot.RPCExecuteProcess, &req, &resp, false)
 	if err != nil {
+		if gc.os != "windows" && uint32(resp.Result) == hrFileNotFound {
+			return nil, fmt.Errorf("%w: %w", ErrExecutableNotFound, err)
+		}
+		if gc.os != "windows" && gc.maxProcesses != 0 && uint32(resp.Result) == hrOutOfMemory {
+			return nil, fmt.Errorf("%w (%d): %w", ErrTooManyProcesses, gc.maxProcesses, err)
+		}
 		return nil, err
 	}
 	p.id = resp.ProcessID
@@ -140,9 +255,45 @@ func (p *Process) Close() error {
 	if err := p.stderr.Close(); err != nil {
 		log.G(ctx).WithError(err).Warn("close stderr failed")
 	}
+	p.releasePorts()
 	return nil
 }
 
+// releasePorts releases the ports of the process's stdio relays to be reused,
+// once the process exited or is closed. Relays the guest has not connected to
+// are closed, as it no longer will.
+func (p *Process) releasePorts() {
+	p.releasePortsOnce.Do(func() {
+		for _, c := range []*ioChannel{p.stdin, p.stdout, p.stderr} {
+			c.closeListener()
+		}
+		p.gc.releaseStdioPorts(p.cid, p.ports...)
+	})
+}
+
+// StdioFiles returns the paths of the files in the guest that the stdout and
+// stderr of the process are written to, or "" for the streams that are relayed
+// over [Process.Stdio] instead.
+func (p *Process) StdioFiles() (stdout, stderr string) {
+	return p.stdoutFile, p.stderrFile
+}
+
+// StdinClosed returns a channel that is closed once the process's stdin can
+// no longer be written, because the guest closed it after the process closed
+// its stdin, or the process exited or was closed. The channel is nil, and is
+// never closed, if the process has no stdin.
+func (p *Process) StdinClosed() <-chan struct{} {
+	return p.stdinClosed
+}
+
+// watchStdinClosed closes stdinClosed once the stdin relay is closed. The guest
+// never writes to the relay, so reading it only returns once the guest closes
+// its end, or the relay is closed on the host.
+func (p *Process) watchStdinClosed() {
+	_, _ = io.Copy(io.Discard, p.stdin)
+	close(p.stdinClosed)
+}
+
 // CloseStdin causes the process to read EOF on its stdin stream.
 func (p *Process) CloseStdin(ctx context.Context) (err error) {
 	ctx, span := oc.StartSpan(ctx, "gcs::Process::CloseStdin") //nolint:ineffassign,staticcheck
@@ -189,7 +340,31 @@ func (p *Process) ExitCode() (_ int, err error) {
 	if err := p.waitCall.Err(); err != nil {
 		return -1, err
 	}
-	return int(p.waitResp.ExitCode), nil
+	code, _, _ := exitStatus(&p.waitResp)
+	return code, nil
+}
+
+// ExitStatus returns the process's exit code and, if the process was terminated
+// by a signal, the signal and whether the process dumped core. If the process is
+// still running or the exit code is otherwise unknown, the exit code is -1.
+//
+// Only Linux guests report the signal that terminated a process.
+func (p *Process) ExitStatus() (code int, signal syscall.Signal, dumped bool) {
+	if !p.waitCall.Done() || p.waitCall.Err() != nil {
+		return -1, 0, false
+	}
+	return exitStatus(&p.waitResp)
+}
+
+// exitStatus returns the exit status from a wait response.
+func exitStatus(resp *prot.ContainerWaitForProcessResponse) (code int, signal syscall.Signal, dumped bool) {
+	code = int(resp.ExitCode)
+	if resp.Signal == nil || resp.Signal.Signal <= 0 {
+		return code, 0, false
+	}
+	signal = syscall.Signal(resp.Signal.Signal)
+	// match the exit code reported for signal deaths by runc and shells
+	return 128 + int(signal), signal, resp.Signal.CoreDumped
 }
 
 // Kill sends a forceful terminate signal to the process and returns whether the
@@ -212,14 +387,20 @@ func (p *Process) Pid() int {
 }
 
 // ResizeConsole requests that the pty associated with the process resize its
-// window.
+// window. Both `width` and `height` must be non-zero.
 func (p *Process) ResizeConsole(ctx context.Context, width, height uint16) (err error) {
 	ctx, span := oc.StartSpan(ctx, "gcs::Process::ResizeConsole", oc.WithClientSpanKind)
 	defer span.End()
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(
 		trace.StringAttribute("cid", p.cid),
-		trace.Int64Attribute("pid", int64(p.id)))
+		trace.Int64Attribute("pid", int64(p.id)),
+		trace.Int64Attribute("width", int64(width)),
+		trace.Int64Attribute("height", int64(height)))
+
+	if width == 0 || height == 0 {
+		return fmt.Errorf("%w: got %dx%d", ErrInvalidConsoleSize, width, height)
+	}
 
 	req := prot.ContainerResizeConsole{
 		RequestBase: makeRequest(ctx, p.cid),
@@ -285,6 +466,8 @@ func (p *Process) waitBackground() {
 		trace.Int64Attribute("pid", int64(p.id)))
 
 	p.waitCall.Wait()
+	// the IO of an exited process may never be closed, so release its ports now
+	p.releasePorts()
 	ec, err := p.ExitCode()
 	if err != nil {
 		log.G(ctx).WithError(err).Error("failed wait")
@@ -0,0 +1,62 @@
+//go:build windows
+
+package gcs
+
+import (
+	"encoding/json"
+	"syscall"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/gcs/prot"
+)
+
+func Test_exitStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		resp   string
+		code   int
+		signal syscall.Signal
+		dumped bool
+	}{
+		{
+			name: "Success",
+			resp: `{"ExitCode":0}`,
+		},
+		{
+			name: "Exited",
+			resp: `{"ExitCode":3}`,
+			code: 3,
+		},
+		{
+			name:   "SIGKILL",
+			resp:   `{"ExitCode":137,"Signal":{"Signal":9}}`,
+			code:   137,
+			signal: 9,
+		},
+		{
+			name:   "SIGSEGVCoreDumped",
+			resp:   `{"ExitCode":139,"Signal":{"Signal":11,"CoreDumped":true}}`,
+			code:   139,
+			signal: 11,
+			dumped: true,
+		},
+		{
+			name:   "SIGKILLRawWaitStatus",
+			resp:   `{"ExitCode":9,"Signal":{"Signal":9}}`,
+			code:   137,
+			signal: 9,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp prot.ContainerWaitForProcessResponse
+			if err := json.Unmarshal([]byte(tc.resp), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			code, signal, dumped := exitStatus(&resp)
+			if code != tc.code || signal != tc.signal || dumped != tc.dumped {
+				t.Fatalf("expected exit status (%d, %d, %t), got (%d, %d, %t)",
+					tc.code, tc.signal, tc.dumped, code, signal, dumped)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+//go:build windows
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Microsoft/hcsshim/internal/gcs/prot"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+)
+
+// propertiesConcurrency is the number of property queries that
+// [GuestConnection.containersProperties] sends at once to a guest that cannot
+// query the properties of several containers in a single request.
+const propertiesConcurrency = 8
+
+// containersProperties queries the properties `types` of each of the
+// containers `ids`, and returns them and the errors of the containers whose
+// properties could not be queried by container ID.
+func (gc *GuestConnection) containersProperties(ctx context.Context, ids []string, types []hcsschema.PropertyType) (map[string]*hcsschema.Properties, map[string]error) {
+	props := make(map[string]*hcsschema.Properties, len(ids))
+	errs := make(map[string]error)
+
+	if lc := GetLCOWCapabilities(gc.caps); lc != nil && lc.MultiContainerPropertiesSupported {
+		req := prot.ContainerGetPropertiesV2{
+			RequestBase: makeRequest(ctx, nullContainerID),
+			Query:       prot.ContainerPropertiesQueryV2{PropertyTypes: types, ContainerIDs: ids},
+		}
+		var resp prot.ContainerGetPropertiesResponseV2
+		if err := gc.brdg.RPC(ctx, prot.RPCGetProperties, &req, &resp, true); err != nil {
+			for _, id := range ids {
+				errs[id] = err
+			}
+			return props, errs
+		}
+		for _, id := range ids {
+			if p := resp.Properties.Containers[id]; p != nil {
+				props[id] = p
+			} else if e, ok := resp.Properties.ContainerErrors[id]; ok {
+				errs[id] = &rpcError{result: e.Result, message: e.Message}
+			} else {
+				errs[id] = errors.New("properties not reported by the guest")
+			}
+		}
+		return props, errs
+	}
+
+	var mu sync.Mutex
+	var g errgroup.Group
+	g.SetLimit(propertiesConcurrency)
+	for _, id := range ids {
+		g.Go(func() error {
+			p, err := gc.propertiesV2(ctx, id, types)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[id] = err
+			} else {
+				props[id] = p
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return props, errs
+}
+
+// propertiesV2 queries the properties `types` of the container `cid`.
+func (gc *GuestConnection) propertiesV2(ctx context.Context, cid string, types []hcsschema.PropertyType) (*hcsschema.Properties, error) {
+	req := prot.ContainerGetPropertiesV2{
+		RequestBase: makeRequest(ctx, cid),
+		Query:       prot.ContainerPropertiesQueryV2{PropertyTypes: types},
+	}
+	var resp prot.ContainerGetPropertiesResponseV2
+	if err := gc.brdg.RPC(ctx, prot.RPCGetProperties, &req, &resp, true); err != nil {
+		return nil, err
+	}
+	return (*hcsschema.Properties)(&resp.Properties), nil
+}
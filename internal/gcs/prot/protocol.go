@@ -11,6 +11,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/commonutils"
 	"github.com/Microsoft/hcsshim/internal/hcs/schema1"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 )
 
 const (
@@ -117,6 +118,7 @@ const (
 	RPCDeleteContainerState
 	RPCUpdateContainer
 	RPCLifecycleNotification
+	RPCPing
 )
 
 const (
@@ -162,6 +164,8 @@ func (rpc RPCProc) String() string {
 		return "UpdateContainer"
 	case RPCLifecycleNotification:
 		return "LifecycleNotification"
+	case RPCPing:
+		return "Ping"
 	case RPCModifyServiceSettings:
 		return "ModifyServiceSettings"
 	default:
@@ -247,6 +251,8 @@ type ResponseBase struct {
 	ErrorMessage string                    `json:",omitempty"`
 	ActivityID   guid.GUID                 `json:"ActivityId,omitempty"`
 	ErrorRecords []commonutils.ErrorRecord `json:",omitempty"`
+	// Warnings are caveats the guest reported for a request that succeeded.
+	Warnings []string `json:",omitempty"`
 }
 
 func (resp *ResponseBase) Base() *ResponseBase {
@@ -263,6 +269,9 @@ type NegotiateProtocolResponse struct {
 	ResponseBase
 	Version      uint32          `json:",omitempty"`
 	Capabilities GcsCapabilities `json:",omitempty"`
+	// GcsRunTime is how long, in nanoseconds, the GCS has been running for,
+	// measured with the monotonic clock of the guest.
+	GcsRunTime int64 `json:",omitempty"`
 }
 
 type DumpStacksRequest struct {
@@ -274,6 +283,16 @@ type DumpStacksResponse struct {
 	GuestStacks string
 }
 
+type PingRequest struct {
+	RequestBase
+	Timestamp int64
+}
+
+type PingResponse struct {
+	ResponseBase
+	Timestamp int64
+}
+
 type DeleteContainerStateRequest struct {
 	RequestBase
 }
@@ -281,6 +300,9 @@ type DeleteContainerStateRequest struct {
 type ContainerCreate struct {
 	RequestBase
 	ContainerConfig AnyInString
+	// HostnameSuffix is the DNS domain that a Linux GCS adds to the search
+	// domains of the container's /etc/resolv.conf.
+	HostnameSuffix string `json:",omitempty"`
 }
 
 type UvmConfig struct {
@@ -311,6 +333,27 @@ type ExecuteProcessSettings struct {
 	ProcessParameters       AnyInString
 	StdioRelaySettings      *ExecuteProcessStdioRelaySettings      `json:",omitempty"`
 	VsockStdioRelaySettings *ExecuteProcessVsockStdioRelaySettings `json:",omitempty"`
+	// StdOutFile and StdErrFile redirect stdout and stderr to files in a Linux
+	// guest, in which case no relay is set up for them.
+	StdOutFile *ExecuteProcessStdioFileSettings `json:",omitempty"`
+	StdErrFile *ExecuteProcessStdioFileSettings `json:",omitempty"`
+	// DenyRootUser fails to create a process in a Linux guest, rather than in
+	// a container, that would run as root.
+	DenyRootUser bool `json:",omitempty"`
+}
+
+// ExecuteProcessStdioFileSettings is a file that the stdout or stderr of a
+// process is written to in the guest.
+type ExecuteProcessStdioFileSettings struct {
+	// Path is the absolute path of the file in the container, or in the guest
+	// for processes created in the container host.
+	Path string
+	// MaxSizeInBytes is the size past which the file is rotated. Zero
+	// disables rotation.
+	MaxSizeInBytes uint64 `json:",omitempty"`
+	// MaxBackups is the number of rotated files kept, with the suffixes ".1"
+	// (the newest) to ".MaxBackups" (the oldest).
+	MaxBackups uint32 `json:",omitempty"`
 }
 
 type ExecuteProcessStdioRelaySettings struct {
@@ -388,6 +431,12 @@ type GcsCapabilities struct {
 	SupportedSchemaVersions        []hcsschema.Version
 	RuntimeOsType                  string
 	GuestDefinedCapabilities       json.RawMessage
+	// MaxConcurrentProcesses is the maximum number of processes a Linux GCS runs
+	// at once, or zero if it is unlimited or not reported.
+	MaxConcurrentProcesses uint32 `json:",omitempty"`
+	// SupportedResourceTypes are the resource types of the modify settings
+	// requests a Linux GCS handles, or nil if it does not report them.
+	SupportedResourceTypes []guestrequest.ResourceType `json:",omitempty"`
 }
 
 type ContainerCreateResponse struct {
@@ -401,7 +450,17 @@ type ContainerExecuteProcessResponse struct {
 
 type ContainerWaitForProcessResponse struct {
 	ResponseBase
+	// ExitCode is the exit code of the process. If the process was terminated
+	// by a signal, it is 128 plus the signal number.
 	ExitCode uint32
+	// Signal is set by Linux guests if the process was terminated by a signal.
+	Signal *ProcessExitSignal `json:",omitempty"`
+}
+
+// ProcessExitSignal describes the signal that terminated a process.
+type ProcessExitSignal struct {
+	Signal     int32
+	CoreDumped bool `json:",omitempty"`
 }
 
 type ContainerProperties schema1.ContainerProperties
@@ -0,0 +1,216 @@
+//go:build windows
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+)
+
+// defaultStatisticsInterval is the interval at which the statistics of
+// containers are sampled, if [GuestConnectionConfig.StatisticsInterval] is not
+// set.
+const defaultStatisticsInterval = 10 * time.Second
+
+// statisticsIdleIntervals is the number of intervals without a request for the
+// statistics of a container after which it is not sampled anymore.
+const statisticsIdleIntervals = 3
+
+// errStatisticsClosed is returned for the statistics of a container that was
+// closed, or of any container once the guest connection is closed.
+var errStatisticsClosed = errors.New("statistics are no longer sampled")
+
+// statisticsQueryFunc queries the statistics of each of the containers `ids`,
+// and returns them and the errors of the containers whose statistics could not
+// be queried by container ID.
+type statisticsQueryFunc func(ctx context.Context, ids []string) (map[string]*hcsschema.Properties, map[string]error)
+
+// statisticsAggregator samples the statistics of the containers of a guest with
+// a single query for all of them at a fixed interval, and serves the requests
+// for the statistics of each container from the latest sample. This replaces a
+// round trip to the guest for every container with one for the whole guest.
+//
+// Containers are only sampled while their statistics are requested: a
+// container is dropped once it is closed or its statistics have not been
+// requested for [statisticsIdleIntervals] intervals, and the sampling stops
+// when no container is left, so the samples kept do not grow as containers
+// come and go.
+type statisticsAggregator struct {
+	interval time.Duration
+	query    statisticsQueryFunc
+
+	mu sync.Mutex
+	// samples are the sampled containers by container ID.
+	samples map[string]*statisticsSample
+	// sampled is closed when the sample being taken is done, or nil if none is.
+	sampled chan struct{}
+	// ticking is set while the sampling loop runs.
+	ticking bool
+	closed  bool
+	done    chan struct{}
+}
+
+// statisticsSample is the latest sample of the statistics of a container.
+type statisticsSample struct {
+	props *hcsschema.Properties
+	err   error
+	// at is when the sample was taken, or zero until the container is first
+	// sampled.
+	at time.Time
+	// requested is when the statistics of the container were last requested.
+	requested time.Time
+}
+
+func newStatisticsAggregator(interval time.Duration, query statisticsQueryFunc) *statisticsAggregator {
+	return &statisticsAggregator{
+		interval: interval,
+		query:    query,
+		samples:  make(map[string]*statisticsSample),
+		done:     make(chan struct{}),
+	}
+}
+
+// get returns the statistics of container `cid` from its latest sample, or
+// waits for a new sample if the latest is more than an interval old.
+func (a *statisticsAggregator) get(ctx context.Context, cid string) (*hcsschema.Properties, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	s := a.samples[cid]
+	if s == nil {
+		if a.closed {
+			return nil, errStatisticsClosed
+		}
+		s = &statisticsSample{}
+		a.samples[cid] = s
+	}
+	s.requested = now
+	if !a.ticking && !a.closed {
+		a.ticking = true
+		go a.tick()
+	}
+
+	// a sample started after the request is always fresh enough, so this
+	// waits for two samples at most
+	fresh := now.Add(-a.interval)
+	for !s.at.After(fresh) {
+		ch := a.sampleLocked()
+		a.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			a.mu.Lock()
+			return nil, ctx.Err()
+		}
+		a.mu.Lock()
+		if a.samples[cid] != s {
+			return nil, errStatisticsClosed
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	// callers must not change the sample shared with other callers
+	props := *s.props
+	return &props, nil
+}
+
+// remove stops sampling container `cid`.
+func (a *statisticsAggregator) remove(cid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.samples, cid)
+}
+
+// close stops sampling all containers.
+func (a *statisticsAggregator) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	clear(a.samples)
+	close(a.done)
+}
+
+// tick samples the containers at every interval, until there are none to
+// sample.
+func (a *statisticsAggregator) tick() {
+	t := time.NewTicker(a.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-t.C:
+		}
+		a.mu.Lock()
+		a.sampleLocked()
+		if len(a.samples) == 0 {
+			a.ticking = false
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Unlock()
+	}
+}
+
+// sampleLocked starts sampling the containers whose statistics were requested
+// recently, dropping the others, unless a sample is already being taken. It
+// returns a channel closed when the sample is done.
+//
+// a.mu must be held.
+func (a *statisticsAggregator) sampleLocked() <-chan struct{} {
+	if a.sampled != nil {
+		return a.sampled
+	}
+
+	idle := time.Now().Add(-statisticsIdleIntervals * a.interval)
+	ids := make([]string, 0, len(a.samples))
+	for id, s := range a.samples {
+		if s.requested.Before(idle) {
+			delete(a.samples, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	ch := make(chan struct{})
+	if len(ids) == 0 {
+		close(ch)
+		return ch
+	}
+	a.sampled = ch
+
+	go func() {
+		// a sample that takes longer than the interval is stale anyway, and a
+		// guest that does not answer must not hold up every later request
+		ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+		defer cancel()
+		at := time.Now()
+		props, errs := a.query(ctx, ids)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for _, id := range ids {
+			s := a.samples[id]
+			if s == nil {
+				// removed while it was sampled
+				continue
+			}
+			s.at, s.props, s.err = at, props[id], errs[id]
+			if s.props == nil && s.err == nil {
+				s.err = fmt.Errorf("no statistics sampled for container %s", id)
+			}
+		}
+		a.sampled = nil
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,199 @@
+//go:build windows
+
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+)
+
+// testStatisticsQuery records the containers of each query, and fails the query
+// of container "missing".
+type testStatisticsQuery struct {
+	mu      sync.Mutex
+	queries [][]string
+	// block, if set, blocks the first query until it is closed.
+	block   chan struct{}
+	started chan struct{}
+}
+
+func (q *testStatisticsQuery) query(_ context.Context, ids []string) (map[string]*hcsschema.Properties, map[string]error) {
+	q.mu.Lock()
+	q.queries = append(q.queries, slices.Sorted(slices.Values(ids)))
+	first := len(q.queries) == 1
+	q.mu.Unlock()
+	if first && q.block != nil {
+		close(q.started)
+		<-q.block
+	}
+
+	props := make(map[string]*hcsschema.Properties)
+	errs := make(map[string]error)
+	for _, id := range ids {
+		if id == "missing" {
+			errs[id] = errors.New("container not found")
+			continue
+		}
+		props[id] = &hcsschema.Properties{Id: id}
+	}
+	return props, errs
+}
+
+func (q *testStatisticsQuery) count() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queries)
+}
+
+func TestStatisticsAggregatorCached(t *testing.T) {
+	q := &testStatisticsQuery{}
+	a := newStatisticsAggregator(time.Hour, q.query)
+	defer a.close()
+
+	for i, tc := range []struct {
+		id      string
+		queries int
+	}{
+		{id: "a", queries: 1},
+		// served from the sample of "a"
+		{id: "a", queries: 1},
+		// not sampled yet, so it is sampled with "a"
+		{id: "b", queries: 2},
+		{id: "a", queries: 2},
+		{id: "b", queries: 2},
+	} {
+		p, err := a.get(context.Background(), tc.id)
+		if err != nil || p.Id != tc.id {
+			t.Fatalf("request %d: unexpected statistics for container %s: %+v, %v", i, tc.id, p, err)
+		}
+		if n := q.count(); n != tc.queries {
+			t.Fatalf("request %d: expected %d queries, got %d", i, tc.queries, n)
+		}
+	}
+	if last := q.queries[len(q.queries)-1]; !slices.Equal(last, []string{"a", "b"}) {
+		t.Fatalf("expected both containers to be sampled together, got %v", last)
+	}
+
+	if _, err := a.get(context.Background(), "missing"); err == nil {
+		t.Fatal("expected the error of the missing container")
+	}
+}
+
+func TestStatisticsAggregatorConcurrent(t *testing.T) {
+	q := &testStatisticsQuery{block: make(chan struct{}), started: make(chan struct{})}
+	a := newStatisticsAggregator(time.Hour, q.query)
+	defer a.close()
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	get := func(id string) {
+		defer wg.Done()
+		p, err := a.get(context.Background(), id)
+		if err == nil && p.Id != id {
+			err = fmt.Errorf("got statistics of container %s", p.Id)
+		}
+		if err != nil {
+			errs <- fmt.Errorf("container %s: %w", id, err)
+		}
+	}
+
+	wg.Add(1)
+	go get("c0")
+	<-q.started
+	// requests made while the first sample is taken wait for the next one
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go get(fmt.Sprintf("c%d", i))
+	}
+	for {
+		a.mu.Lock()
+		l := len(a.samples)
+		a.mu.Unlock()
+		if l == n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(q.block)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if c := q.count(); c != 2 {
+		t.Fatalf("expected the containers to be sampled in 2 queries, got %d: %v", c, q.queries)
+	}
+}
+
+func TestStatisticsAggregatorIdle(t *testing.T) {
+	q := &testStatisticsQuery{}
+	a := newStatisticsAggregator(10*time.Millisecond, q.query)
+	defer a.close()
+
+	for _, id := range []string{"a", "b"} {
+		if _, err := a.get(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.remove("b")
+	removed := q.count()
+
+	// the containers are dropped once they are not requested anymore, which
+	// stops the sampling
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		a.mu.Lock()
+		l, ticking := len(a.samples), a.ticking
+		a.mu.Unlock()
+		if l == 0 && !ticking {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no containers to be sampled, got %d, ticking: %t", l, ticking)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i, ids := range q.queries[removed:] {
+		if slices.Contains(ids, "b") {
+			t.Fatalf("query %d sampled removed container: %v", removed+i, ids)
+		}
+	}
+}
+
+func TestStatisticsAggregatorClosed(t *testing.T) {
+	q := &testStatisticsQuery{}
+	a := newStatisticsAggregator(time.Hour, q.query)
+	a.close()
+	if _, err := a.get(context.Background(), "a"); !errors.Is(err, errStatisticsClosed) {
+		t.Fatalf("expected %v, got %v", errStatisticsClosed, err)
+	}
+	if c := q.count(); c != 0 {
+		t.Fatalf("expected no queries, got %d", c)
+	}
+}
+
+func TestStatisticsAggregatorQueryTimeout(t *testing.T) {
+	query := func(ctx context.Context, ids []string) (map[string]*hcsschema.Properties, map[string]error) {
+		<-ctx.Done()
+		errs := make(map[string]error)
+		for _, id := range ids {
+			errs[id] = ctx.Err()
+		}
+		return nil, errs
+	}
+	a := newStatisticsAggregator(10*time.Millisecond, query)
+	defer a.close()
+
+	if _, err := a.get(context.Background(), "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the query to time out, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+//go:build windows
+
+package gcs
+
+import (
+	"context"
+	"maps"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+)
+
+// defaultStdioPortsWarningThreshold is the number of stdio relay ports in use
+// past which a warning is logged, if
+// [GuestConnectionConfig.StdioPortsWarningThreshold] is not set.
+const defaultStdioPortsWarningThreshold = 1024
+
+// StdioPortStats is the accounting of the vsock ports used to relay the stdio of
+// processes in the guest.
+type StdioPortStats struct {
+	// InUse is the number of ports in use.
+	InUse int
+	// Allocated is the number of ports allocated so far. Ports are never
+	// reused, so this includes the released ports.
+	Allocated int
+	// Containers is the number of ports in use by the processes of each
+	// container, by container ID. Processes in the container host are under
+	// the null container ID.
+	Containers map[string]int
+}
+
+// stdioPorts allocates the vsock ports of stdio relays, and accounts for the
+// ports in use so that leaking processes can be spotted.
+//
+// Ports are allocated in increasing order and never reused: a process of a
+// failed or cancelled request may still connect to the port of its relay after
+// it is released, and must not be relayed to another process.
+type stdioPorts struct {
+	first, next uint32
+	containers  map[string]int
+	inUse       int
+
+	warningThreshold int
+	warned           bool
+}
+
+func newStdioPorts(first uint32, warningThreshold int) *stdioPorts {
+	if warningThreshold <= 0 {
+		warningThreshold = defaultStdioPortsWarningThreshold
+	}
+	return &stdioPorts{
+		first:            first,
+		next:             first,
+		containers:       make(map[string]int),
+		warningThreshold: warningThreshold,
+	}
+}
+
+// allocate returns a port for a stdio relay of a process in container `cid`.
+//
+// Must be called with the guest connection's lock held.
+func (sp *stdioPorts) allocate(ctx context.Context, cid string) uint32 {
+	port := sp.next
+	sp.next++
+	sp.containers[cid]++
+	sp.inUse++
+
+	if sp.inUse > sp.warningThreshold && !sp.warned {
+		sp.warned = true
+		log.G(ctx).WithFields(logrus.Fields{
+			logfields.ContainerID: cid,
+			"inUse":               sp.inUse,
+			"containerInUse":      sp.containers[cid],
+			"threshold":           sp.warningThreshold,
+		}).Warning("stdio relay ports in use exceed the warning threshold, process IO may be leaking")
+	}
+	return port
+}
+
+// release stops accounting for a port allocated for container `cid`.
+//
+// Must be called with the guest connection's lock held.
+func (sp *stdioPorts) release(cid string) {
+	if sp.containers[cid]--; sp.containers[cid] <= 0 {
+		delete(sp.containers, cid)
+	}
+	sp.inUse--
+	if sp.inUse <= sp.warningThreshold {
+		sp.warned = false
+	}
+}
+
+func (sp *stdioPorts) stats() StdioPortStats {
+	return StdioPortStats{
+		InUse:      sp.inUse,
+		Allocated:  int(sp.next - sp.first),
+		Containers: maps.Clone(sp.containers),
+	}
+}
+
+// StdioPorts returns the accounting of the vsock ports used to relay the stdio
+// of processes in the guest.
+func (gc *GuestConnection) StdioPorts() StdioPortStats {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	return gc.stdioPorts.stats()
+}
@@ -0,0 +1,53 @@
+//go:build windows
+
+package gcs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStdioPorts(t *testing.T) {
+	ctx := context.Background()
+	sp := newStdioPorts(100, 4)
+
+	var ports []uint32
+	for i := 0; i < 3; i++ {
+		ports = append(ports, sp.allocate(ctx, "c1"))
+	}
+	ports = append(ports, sp.allocate(ctx, "c2"))
+	if s := sp.stats(); s.InUse != 4 || s.Allocated != 4 || s.Containers["c1"] != 3 || s.Containers["c2"] != 1 {
+		t.Fatalf("unexpected stats after allocating: %+v", s)
+	}
+
+	sp.release("c1")
+	sp.release("c1")
+	// released ports are not reused, since the process they were allocated for
+	// may still connect to them
+	if p := sp.allocate(ctx, "c2"); p != 104 {
+		t.Fatalf("expected new port 104, got %d", p)
+	}
+	if p := sp.allocate(ctx, "c2"); p != 105 {
+		t.Fatalf("expected new port 105, got %d", p)
+	}
+
+	// past the warning threshold
+	if p := sp.allocate(ctx, "c2"); p != 106 {
+		t.Fatalf("expected new port 106, got %d", p)
+	}
+	if !sp.warned {
+		t.Fatal("expected a warning past the threshold")
+	}
+	sp.release("c2")
+	if sp.warned {
+		t.Fatal("expected the warning to be reset below the threshold")
+	}
+
+	for i := 0; i < 3; i++ {
+		sp.release("c2")
+	}
+	sp.release("c1")
+	if s := sp.stats(); s.InUse != 0 || s.Allocated != 7 || len(s.Containers) != 0 {
+		t.Fatalf("unexpected stats after releasing: %+v", s)
+	}
+}
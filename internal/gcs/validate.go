@@ -0,0 +1,114 @@
+//go:build windows
+
+package gcs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+)
+
+// ContainerConfigError is returned by [ValidateContainerConfig] when a
+// container create document is malformed.
+type ContainerConfigError struct {
+	// Field is the JSON name of the invalid field, or empty if the error
+	// applies to the whole document.
+	Field string
+	Err   error
+}
+
+var _ error = &ContainerConfigError{}
+
+func (e *ContainerConfigError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid container config: %v", e.Err)
+	}
+	return fmt.Sprintf("invalid container config field %q: %v", e.Field, e.Err)
+}
+
+func (e *ContainerConfigError) Unwrap() error { return e.Err }
+
+// containerConfigV2 holds the fields of all of the v2 container create
+// documents sent to the GCS:
+//   - [hcsschema.HostedSystem] for WCOW;
+//   - SchemaVersion and OciSpecification for LCOW; and
+//   - Spec and CWCOWHostedSystem for confidential WCOW.
+type containerConfigV2 struct {
+	SchemaVersion     *hcsschema.Version
+	Container         *hcsschema.Container
+	OciSpecification  *specs.Spec
+	Spec              *specs.Spec
+	CWCOWHostedSystem *hcsschema.HostedSystem
+}
+
+// ValidateContainerConfig checks that `configJSON` is a container create
+// document for schema version `sv`, so that malformed documents are rejected
+// before they are sent to the GCS.
+//
+// Any error returned is a [*ContainerConfigError].
+func ValidateContainerConfig(configJSON string, sv hcsschema.Version) error {
+	if sv.Major != 2 {
+		return &ContainerConfigError{
+			Field: "SchemaVersion",
+			Err:   fmt.Errorf("unsupported schema version %d.%d", sv.Major, sv.Minor),
+		}
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(configJSON), &raw); err != nil {
+		return newContainerConfigError(err)
+	}
+	if raw == nil {
+		return &ContainerConfigError{Err: errors.New("document is empty")}
+	}
+
+	var cfg containerConfigV2
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return newContainerConfigError(err)
+	}
+
+	version, container := cfg.SchemaVersion, cfg.Container
+	versionField, containerField := "SchemaVersion", "Container"
+	if cfg.CWCOWHostedSystem != nil {
+		if cfg.Spec == nil {
+			return &ContainerConfigError{Field: "Spec", Err: errors.New("field is required")}
+		}
+		version, container = cfg.CWCOWHostedSystem.SchemaVersion, cfg.CWCOWHostedSystem.Container
+		versionField, containerField = "CWCOWHostedSystem.SchemaVersion", "CWCOWHostedSystem.Container"
+	}
+
+	if version == nil {
+		return &ContainerConfigError{Field: versionField, Err: errors.New("field is required")}
+	}
+	if version.Major != sv.Major || version.Minor > sv.Minor {
+		return &ContainerConfigError{
+			Field: versionField,
+			Err: fmt.Errorf("schema version %d.%d is not compatible with %d.%d",
+				version.Major, version.Minor, sv.Major, sv.Minor),
+		}
+	}
+
+	switch {
+	case container != nil && cfg.OciSpecification != nil:
+		return &ContainerConfigError{
+			Err: fmt.Errorf("only one of %s and OciSpecification may be set", containerField),
+		}
+	case container == nil && cfg.OciSpecification == nil:
+		return &ContainerConfigError{
+			Err: fmt.Errorf("one of %s and OciSpecification is required", containerField),
+		}
+	}
+	return nil
+}
+
+func newContainerConfigError(err error) *ContainerConfigError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &ContainerConfigError{Field: typeErr.Field, Err: err}
+	}
+	return &ContainerConfigError{Err: err}
+}
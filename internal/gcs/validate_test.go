@@ -0,0 +1,119 @@
+//go:build windows
+
+package gcs
+
+import (
+	"errors"
+	"testing"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+)
+
+func TestValidateContainerConfig(t *testing.T) {
+	v21 := hcsschema.Version{Major: 2, Minor: 1}
+	for _, tc := range []struct {
+		name   string
+		config string
+		sv     hcsschema.Version
+		field  string
+		valid  bool
+	}{
+		{
+			name:   "WCOW",
+			config: `{"SchemaVersion":{"Major":2,"Minor":1},"Container":{}}`,
+			sv:     v21,
+			valid:  true,
+		},
+		{
+			name:   "LCOW",
+			config: `{"SchemaVersion":{"Major":2,"Minor":1},"OciBundlePath":"/run/gcs/c/foo","OciSpecification":{"ociVersion":"1.1.0"}}`,
+			sv:     v21,
+			valid:  true,
+		},
+		{
+			name:   "CWCOW",
+			config: `{"Spec":{"ociVersion":"1.1.0"},"CWCOWHostedSystem":{"SchemaVersion":{"Major":2,"Minor":1},"Container":{}}}`,
+			sv:     v21,
+			valid:  true,
+		},
+		{
+			name:   "OlderMinor",
+			config: `{"SchemaVersion":{"Major":2},"Container":{}}`,
+			sv:     v21,
+			valid:  true,
+		},
+		{
+			name:   "NotJSON",
+			config: `{"SchemaVersion":`,
+			sv:     v21,
+		},
+		{
+			name:   "Null",
+			config: `null`,
+			sv:     v21,
+		},
+		{
+			name:   "NotObject",
+			config: `["Container"]`,
+			sv:     v21,
+		},
+		{
+			name:   "UnsupportedVersion",
+			config: `{"SchemaVersion":{"Major":1},"Container":{}}`,
+			sv:     hcsschema.Version{Major: 1},
+			field:  "SchemaVersion",
+		},
+		{
+			name:   "MissingVersion",
+			config: `{"Container":{}}`,
+			sv:     v21,
+			field:  "SchemaVersion",
+		},
+		{
+			name:   "NewerVersion",
+			config: `{"SchemaVersion":{"Major":2,"Minor":5},"Container":{}}`,
+			sv:     v21,
+			field:  "SchemaVersion",
+		},
+		{
+			name:   "CWCOWMissingVersion",
+			config: `{"Spec":{},"CWCOWHostedSystem":{"Container":{}}}`,
+			sv:     v21,
+			field:  "CWCOWHostedSystem.SchemaVersion",
+		},
+		{
+			name:   "WrongType",
+			config: `{"SchemaVersion":{"Major":"2","Minor":1},"Container":{}}`,
+			sv:     v21,
+			field:  "SchemaVersion.Major",
+		},
+		{
+			name:   "MissingContainer",
+			config: `{"SchemaVersion":{"Major":2,"Minor":1}}`,
+			sv:     v21,
+		},
+		{
+			name:   "ContainerAndOciSpecification",
+			config: `{"SchemaVersion":{"Major":2,"Minor":1},"Container":{},"OciSpecification":{}}`,
+			sv:     v21,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateContainerConfig(tc.config, tc.sv)
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			var cfgErr *ContainerConfigError
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("expected a *ContainerConfigError, got: %v", err)
+			}
+			if cfgErr.Field != tc.field {
+				t.Fatalf("expected error for field %q, got %q: %v", tc.field, cfgErr.Field, err)
+			}
+		})
+	}
+}
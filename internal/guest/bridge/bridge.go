@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -145,6 +146,10 @@ type Request struct {
 	// Version is the version of the protocol that `Header` and `Message` were
 	// sent in.
 	Version prot.ProtocolVersion
+
+	// frameErr is the reason the message is invalid, in which case it is not
+	// dispatched to the handler and the error is returned to the host.
+	frameErr error
 }
 
 // RequestResponse is the base response for any bridge message request.
@@ -177,6 +182,13 @@ type Bridge struct {
 	Handler Handler
 	// EnableV4 enables the v4+ bridge and the schema v2+ interfaces.
 	EnableV4 bool
+	// MaxConcurrentProcesses is the maximum number of processes the GCS runs at
+	// once. Requests to start a process beyond it fail with E_OUTOFMEMORY.
+	// Zero is unlimited.
+	MaxConcurrentProcesses uint32
+	// StartTime is when the GCS started, which is reported to the host during
+	// protocol negotiation. It is not reported if zero.
+	StartTime time.Time
 
 	// responseChan is the response channel used for both request/response
 	// and publish notification workflows.
@@ -189,6 +201,10 @@ type Bridge struct {
 	hasQuitPending atomic.Bool
 
 	protVer prot.ProtocolVersion
+
+	// startingProcesses is the number of requests to start a process that are
+	// being handled.
+	startingProcesses atomic.Int64
 }
 
 // AssignHandlers creates and assigns the appropriate bridge
@@ -217,25 +233,108 @@ func (b *Bridge) AssignHandlers(mux *Mux, host *hcsv2.Host) {
 		mux.HandleFunc(prot.ComputeSystemModifySettingsV1, prot.PvV4, b.modifySettingsV2)
 		mux.HandleFunc(prot.ComputeSystemDumpStacksV1, prot.PvV4, b.dumpStacksV2)
 		mux.HandleFunc(prot.ComputeSystemDeleteContainerStateV1, prot.PvV4, b.deleteContainerStateV2)
+		mux.HandleFunc(prot.ComputeSystemPingV1, prot.PvV4, b.pingV2)
+	}
+}
+
+// serveMsg dispatches `r` to the bridge handler and returns the response to send
+// for it. If the handler fails, the error is set on the response and returned.
+func (b *Bridge) serveMsg(r *Request) (RequestResponse, error) {
+	var (
+		resp RequestResponse
+		err  error
+	)
+	if r.frameErr != nil {
+		err = r.frameErr
+	} else if release, rerr := b.reserveProcess(r); rerr != nil {
+		err = rerr
+	} else {
+		defer release()
+		resp, err = b.Handler.ServeMsg(r)
+	}
+	if resp == nil {
+		resp = &prot.MessageResponseBase{}
+	}
+	resp.Base().ActivityID = r.ActivityID
+	if err != nil {
+		setErrorForResponseBase(resp.Base(), err, "gcs" /* moduleName */)
+	}
+	return resp, err
+}
+
+// reserveProcess reserves a process for `r`, if it is a request to start one,
+// and returns a function to release the reservation once `r` is handled.
+//
+// It fails with E_OUTOFMEMORY if the processes already running and being
+// started would exceed [Bridge.MaxConcurrentProcesses].
+func (b *Bridge) reserveProcess(r *Request) (func(), error) {
+	if r.Header.Type != prot.ComputeSystemExecuteProcessV1 || b.MaxConcurrentProcesses == 0 {
+		return func() {}, nil
+	}
+
+	starting := b.startingProcesses.Add(1)
+	release := func() { b.startingProcesses.Add(-1) }
+	var running int
+	if b.hostState != nil {
+		running = b.hostState.RunningProcesses()
+	}
+	if int64(running)+starting > int64(b.MaxConcurrentProcesses) {
+		release()
+		return nil, gcserr.WrapHresult(
+			errors.Errorf("cannot run more than %d concurrent processes: %d running and %d starting",
+				b.MaxConcurrentProcesses, running, starting-1),
+			gcserr.HrOutOfMemory)
+	}
+	return release, nil
+}
+
+// serveOptions are the options of [Bridge.ListenAndServe].
+type serveOptions struct {
+	maxMessageSize uint32
+}
+
+// ServeOption is an option of [Bridge.ListenAndServe].
+type ServeOption func(*serveOptions)
+
+// WithMaxMessageSize sets the largest message, including its header, read from
+// the host to `n` bytes. The connection is closed on a larger message, without
+// reading it into memory. Defaults to [DefaultMaxMessageSize] if `n` is not
+// positive.
+func WithMaxMessageSize(n int) ServeOption {
+	return func(o *serveOptions) {
+		switch {
+		case n <= 0:
+			o.maxMessageSize = DefaultMaxMessageSize
+		case uint64(n) > math.MaxUint32:
+			o.maxMessageSize = math.MaxUint32
+		default:
+			o.maxMessageSize = uint32(n)
+		}
 	}
 }
 
 // ListenAndServe connects to the bridge transport, listens for
 // messages and dispatches the appropriate handlers to handle each
 // event in an asynchronous manner.
-func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser) error {
+func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser, opts ...ServeOption) error {
+	o := serveOptions{maxMessageSize: DefaultMaxMessageSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	requestChan := make(chan *Request)
-	requestErrChan := make(chan error)
+	// The error channels are buffered and not closed, so that the request and
+	// response loops can exit after ListenAndServe has returned on the error
+	// of the other one.
+	requestErrChan := make(chan error, 1)
 	b.responseChan = make(chan bridgeResponse)
-	responseErrChan := make(chan error)
+	responseErrChan := make(chan error, 1)
 	b.quitChan = make(chan bool)
 
 	defer close(b.quitChan)
 	defer bridgeOut.Close()
-	defer close(responseErrChan)
 	defer close(b.responseChan)
 	defer close(requestChan)
-	defer close(requestErrChan)
 	defer bridgeIn.Close()
 
 	// Receive bridge requests and schedule them to be processed.
@@ -243,20 +342,22 @@ func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser
 		var recverr error
 		for {
 			if !b.hasQuitPending.Load() {
-				header := &prot.MessageHeader{}
-				if err := binary.Read(bridgeIn, binary.LittleEndian, header); err != nil {
-					if err == io.ErrUnexpectedEOF || err == os.ErrClosed { //nolint:errorlint
-						break
+				header, message, err := ReadMessage(bridgeIn, o.maxMessageSize)
+				var frameErr *FrameError
+				if errors.As(err, &frameErr) {
+					// fail only this message, and respond to it if the host is
+					// waiting for a response
+					log.G(context.Background()).WithError(err).Warning("bridge: received invalid message")
+					if !frameErr.IsRequest() {
+						continue
 					}
-					recverr = errors.Wrap(err, "bridge: failed reading message header")
-					break
-				}
-				message := make([]byte, header.Size-prot.MessageHeaderSize)
-				if _, err := io.ReadFull(bridgeIn, message); err != nil {
-					if err == io.ErrUnexpectedEOF || err == os.ErrClosed { //nolint:errorlint
+					header = frameErr.Header
+				} else if err != nil {
+					if c := errors.Cause(err); c == io.ErrUnexpectedEOF || c == os.ErrClosed { //nolint:errorlint
 						break
 					}
-					recverr = errors.Wrap(err, "bridge: failed reading message payload")
+					log.G(context.Background()).WithError(err).Error("bridge: closing connection after failing to read message")
+					recverr = err
 					break
 				}
 
@@ -309,7 +410,7 @@ func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser
 					trace.StringAttribute("cid", base.ContainerID))
 
 				entry := log.G(ctx)
-				if entry.Logger.IsLevelEnabled(logrus.TraceLevel) {
+				if frameErr == nil && entry.Logger.IsLevelEnabled(logrus.TraceLevel) {
 					var err error
 					var msgBytes []byte
 					switch header.Type {
@@ -326,7 +427,7 @@ func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser
 					}
 					entry.WithField("message", s).Trace("request read message")
 				}
-				requestChan <- &Request{
+				req := &Request{
 					Context:     ctx,
 					Header:      header,
 					ContainerID: base.ContainerID,
@@ -334,6 +435,10 @@ func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser
 					Message:     message,
 					Version:     b.protVer,
 				}
+				if frameErr != nil {
+					req.frameErr = frameErr
+				}
+				requestChan <- req
 			}
 		}
 		requestErrChan <- recverr
@@ -349,17 +454,12 @@ func (b *Bridge) ListenAndServe(bridgeIn io.ReadCloser, bridgeOut io.WriteCloser
 						ID:   r.Header.ID,
 					},
 				}
-				resp, err := b.Handler.ServeMsg(r)
-				if resp == nil {
-					resp = &prot.MessageResponseBase{}
-				}
-				resp.Base().ActivityID = r.ActivityID
+				resp, err := b.serveMsg(r)
 				if err != nil {
 					span := trace.FromContext(r.Context)
 					if span != nil {
 						oc.SetSpanStatus(span, err)
 					}
-					setErrorForResponseBase(resp.Base(), err, "gcs" /* moduleName */)
 				}
 				br.response = resp
 				b.responseChan <- br
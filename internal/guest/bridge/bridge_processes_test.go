@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"io"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/guest/runtime/hcsv2"
+	"github.com/Microsoft/hcsshim/internal/guest/transport"
+	"github.com/Microsoft/hcsshim/pkg/securitypolicy"
+)
+
+func Test_Bridge_ReserveProcess(t *testing.T) {
+	b := &Bridge{
+		MaxConcurrentProcesses: 2,
+		hostState:              hcsv2.NewHost(nil, &transport.DevNullTransport{}, &securitypolicy.OpenDoorSecurityPolicyEnforcer{}, io.Discard),
+	}
+	exec := &Request{Header: &prot.MessageHeader{Type: prot.ComputeSystemExecuteProcessV1}}
+
+	release1, err := b.reserveProcess(exec)
+	if err != nil {
+		t.Fatalf("failed to reserve first process: %v", err)
+	}
+	release2, err := b.reserveProcess(exec)
+	if err != nil {
+		t.Fatalf("failed to reserve second process: %v", err)
+	}
+
+	_, err = b.reserveProcess(exec)
+	if err == nil {
+		t.Fatal("expected reserving a process over the limit to fail")
+	}
+	if hr, herr := gcserr.GetHresult(err); herr != nil || hr != gcserr.HrOutOfMemory {
+		t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrOutOfMemory, hr, herr)
+	}
+
+	// other requests are not limited
+	other := &Request{Header: &prot.MessageHeader{Type: prot.ComputeSystemWaitForProcessV1}}
+	if _, err := b.reserveProcess(other); err != nil {
+		t.Fatalf("expected request that does not start a process to succeed, got: %v", err)
+	}
+
+	release1()
+	release3, err := b.reserveProcess(exec)
+	if err != nil {
+		t.Fatalf("failed to reserve process after release: %v", err)
+	}
+	release2()
+	release3()
+	if n := b.startingProcesses.Load(); n != 0 {
+		t.Fatalf("expected no processes starting, got %d", n)
+	}
+}
+
+func Test_Bridge_ReserveProcess_Unlimited(t *testing.T) {
+	b := &Bridge{}
+	exec := &Request{Header: &prot.MessageHeader{Type: prot.ComputeSystemExecuteProcessV1}}
+	for i := 0; i < 1024; i++ {
+		if _, err := b.reserveProcess(exec); err != nil {
+			t.Fatalf("expected no limit on processes, got: %v", err)
+		}
+	}
+}
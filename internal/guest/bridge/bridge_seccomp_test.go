@@ -0,0 +1,77 @@
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/guest/seccomp"
+)
+
+// handlerSyscalls are the syscalls, beyond those every Go program makes, that the
+// handler of each bridge request type needs from the GCS, or from runc and the
+// container processes it launches, which inherit the GCS seccomp filter.
+//
+// A container that is actually created, exec'd in and deleted under each filter
+// is tested by TestContainerSeccomp in test/gcs, which runs in a uVM.
+var handlerSyscalls = map[prot.MessageIdentifier][]string{
+	// reads /proc/self/status to report if the filter is active
+	prot.ComputeSystemNegotiateProtocolV1: {"openat", "read"},
+	// runc sets up the container's namespaces, root filesystem, devices,
+	// cgroups and capabilities; the GCS sets up its overlay and scratch quota
+	prot.ComputeSystemCreateV1: {
+		"bpf", "capset", "chroot", "clone", "clone3", "execve", "keyctl", "mkdirat",
+		"mknodat", "mount", "pivot_root", "prctl", "quotactl_fd", "ioctl", "seccomp",
+		"sethostname", "setns", "symlinkat", "umount2", "unshare",
+	},
+	prot.ComputeSystemStartV1:          {"execve", "setns", "socket", "connect"},
+	prot.ComputeSystemExecuteProcessV1: {"clone", "clone3", "execve", "ioctl", "setns", "socket", "connect"},
+	// shutting down the uVM, rather than a container, powers it off
+	prot.ComputeSystemShutdownForcedV1:   {"kill", "reboot"},
+	prot.ComputeSystemShutdownGracefulV1: {"kill", "reboot"},
+	prot.ComputeSystemSignalProcessV1:    {"kill"},
+	// statistics are read from cgroupfs, and the scratch usage from its quota
+	prot.ComputeSystemGetPropertiesV1:  {"openat", "read", "quotactl_fd"},
+	prot.ComputeSystemWaitForProcessV1: {"wait4", "waitid"},
+	prot.ComputeSystemResizeConsoleV1:  {"ioctl"},
+	// mounts and unmounts disks, VPMem devices, shares and layers, sets up
+	// dm-verity and dm-crypt devices, loads drivers, and configures network
+	// adapters in the network namespaces over netlink
+	prot.ComputeSystemModifySettingsV1: {
+		"bind", "connect", "finit_module", "init_module", "ioctl", "mkdirat", "mknodat",
+		"mount", "sendto", "setns", "socket", "umount2", "unshare",
+	},
+	prot.ComputeSystemDumpStacksV1: {},
+	// removes the container's state and clears its scratch quota
+	prot.ComputeSystemDeleteContainerStateV1: {"quotactl_fd", "umount2", "unlinkat"},
+	prot.ComputeSystemPingV1:                 {},
+}
+
+// Test_Bridge_Seccomp_HandlerSyscalls checks that the seccomp filter of each mode
+// denies none of the syscalls that the handler of any bridge request type needs.
+func Test_Bridge_Seccomp_HandlerSyscalls(t *testing.T) {
+	mux := NewBridgeMux()
+	b := &Bridge{EnableV4: true}
+	b.AssignHandlers(mux, nil)
+
+	// every handler must declare the syscalls it needs
+	for id := range mux.m {
+		if _, ok := handlerSyscalls[id]; !ok {
+			t.Errorf("the syscalls needed by the handler of %v are not listed", id)
+		}
+	}
+
+	for _, mode := range []seccomp.Mode{seccomp.ModeKernel, seccomp.ModeAll} {
+		denied := seccomp.Denied(mode)
+		for id, syscalls := range handlerSyscalls {
+			for _, s := range syscalls {
+				if slices.Contains(denied, s) {
+					t.Errorf("seccomp mode %q denies %s, which the handler of %v needs", mode, s, id)
+				}
+			}
+		}
+	}
+}
@@ -590,6 +590,64 @@ func Test_Bridge_ListenAndServe_CorrectHandler_Success(t *testing.T) {
 	}
 }
 
+func Test_Bridge_ListenAndServe_Ping_Success(t *testing.T) {
+	// Turn off logging so as not to spam output.
+	logrus.SetOutput(io.Discard)
+
+	lc := newLoopbackConnection()
+	defer lc.close()
+
+	b := &Bridge{
+		protVer: prot.PvV4,
+	}
+	mux := NewBridgeMux()
+	mux.HandleFunc(prot.ComputeSystemPingV1, prot.PvV4, b.pingV2)
+	b.Handler = mux
+
+	go func() {
+		if err := b.ListenAndServe(lc.SRead(), lc.SWrite()); err != nil {
+			t.Error(err)
+		}
+	}()
+	defer func() {
+		b.quitChan <- true
+	}()
+
+	message := &prot.Ping{
+		MessageBase: prot.MessageBase{
+			ActivityID: "00000000-0000-0000-0000-000000000100",
+		},
+		Timestamp: 1234567890,
+	}
+	if err := serverSend(lc.CWrite(), prot.ComputeSystemPingV1, prot.SequenceID(1), message); err != nil {
+		t.Error("Failed to send message to server")
+		return
+	}
+	header, body, err := serverRead(lc.CRead())
+	if err != nil {
+		t.Error("Failed to read message response from server")
+		return
+	}
+	response := &prot.PingResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		t.Error("Failed to unmarshal response body from server")
+		return
+	}
+	// Verify.
+	if header.Type != prot.ComputeSystemResponsePingV1 {
+		t.Error("response header was not ping response.")
+	}
+	if header.ID != prot.SequenceID(1) {
+		t.Error("response header had wrong sequence id")
+	}
+	if response.Result != 0 {
+		t.Errorf("response result was not 0: %d", response.Result)
+	}
+	if response.Timestamp != message.Timestamp {
+		t.Errorf("response timestamp %d did not match request timestamp %d", response.Timestamp, message.Timestamp)
+	}
+}
+
 func Test_Bridge_ListenAndServe_HandlersAreAsync_Success(t *testing.T) {
 	// Turn off logging so as not to spam output.
 	logrus.SetOutput(io.Discard)
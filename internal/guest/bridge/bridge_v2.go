@@ -16,8 +16,11 @@ import (
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/commonutils"
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/guest/runtime"
 	"github.com/Microsoft/hcsshim/internal/guest/runtime/hcsv2"
+	"github.com/Microsoft/hcsshim/internal/guest/seccomp"
 	"github.com/Microsoft/hcsshim/internal/guest/stdio"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
@@ -28,7 +31,7 @@ var capabilities = prot.GcsCapabilities{
 	SendHostCreateMessage:   false,
 	SendHostStartMessage:    false,
 	HVSocketConfigOnStartup: false,
-	SupportedSchemaVersions: []prot.SchemaVersion{
+	SupportedSchemaVersions: []hcsschema.Version{
 		{
 			Major: 2,
 			Minor: 1,
@@ -40,7 +43,11 @@ var capabilities = prot.GcsCapabilities{
 		SignalProcessSupported:        true,
 		DumpStacksSupported:           true,
 		DeleteContainerStateSupported: true,
+		ExternalProcessUserSupported:  true,
+
+		MultiContainerPropertiesSupported: true,
 	},
+	SupportedResourceTypes: hcsv2.SupportedResourceTypes(),
 }
 
 // negotiateProtocolV2 was introduced in v4 so will not be called with a minimum
@@ -72,10 +79,25 @@ func (b *Bridge) negotiateProtocolV2(r *Request) (_ RequestResponse, err error)
 	// Set our protocol selected version before return.
 	b.protVer = prot.ProtocolVersion(major)
 
-	return &prot.NegotiateProtocolResponse{
+	caps := capabilities
+	caps.MaxConcurrentProcesses = b.MaxConcurrentProcesses
+	if active, err := seccomp.Active(); err != nil {
+		log.G(r.Context).WithError(err).Warn("failed to get seccomp status")
+	} else {
+		caps.GuestDefinedCapabilities.SeccompFilterActive = active
+	}
+	if b.hostState != nil {
+		caps.GuestDefinedCapabilities.SeccompProfilesSupported = b.hostState.SeccompProfilesSupported(r.Context)
+	}
+
+	resp := &prot.NegotiateProtocolResponse{
 		Version:      major,
-		Capabilities: capabilities,
-	}, nil
+		Capabilities: caps,
+	}
+	if !b.StartTime.IsZero() {
+		resp.GcsRunTime = int64(time.Since(b.StartTime))
+	}
+	return resp, nil
 }
 
 // createContainerV2 creates a container based on the settings passed in `r`.
@@ -103,7 +125,7 @@ func (b *Bridge) createContainerV2(r *Request) (_ RequestResponse, err error) {
 			gcserr.HrVmcomputeInvalidJSON)
 	}
 
-	c, err := b.hostState.CreateContainer(ctx, request.ContainerID, &settingsV2)
+	c, err := b.hostState.CreateContainer(ctx, request.ContainerID, &settingsV2, request.HostnameSuffix)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +145,14 @@ func (b *Bridge) createContainerV2(r *Request) (_ RequestResponse, err error) {
 			Result:     0,
 			ResultInfo: "",
 		}
+		if info := c.ExitResultInfo(); info != nil {
+			if b, err := json.Marshal(info); err != nil {
+				log.G(context.Background()).WithError(err).WithField("cid", request.ContainerID).Error(
+					"failed to marshal container exit result info")
+			} else {
+				notification.ResultInfo = string(b)
+			}
+		}
 		b.PublishNotification(notification)
 	}()
 
@@ -193,6 +223,13 @@ func (b *Bridge) execProcessV2(r *Request) (_ RequestResponse, err error) {
 	if params.CreateStdErrPipe {
 		conSettings.StdErr = &request.Settings.VsockStdioRelaySettings.StdErr
 	}
+	if f := request.Settings.StdOutFile; f != nil {
+		conSettings.StdOutFile = stdioFileSettings(f)
+	}
+	if f := request.Settings.StdErrFile; f != nil {
+		conSettings.StdErrFile = stdioFileSettings(f)
+	}
+	params.DenyRootUser = request.Settings.DenyRootUser
 
 	pid, err := b.hostState.ExecProcess(ctx, request.ContainerID, params, conSettings)
 
@@ -205,6 +242,16 @@ func (b *Bridge) execProcessV2(r *Request) (_ RequestResponse, err error) {
 	}, nil
 }
 
+// stdioFileSettings converts the settings of a file a stdio stream is redirected
+// to. Sizes that overflow are left negative, to be rejected by the host state.
+func stdioFileSettings(f *prot.ExecuteProcessStdioFileSettings) *stdio.FileSettings {
+	return &stdio.FileSettings{
+		Path:       f.Path,
+		MaxSize:    int64(f.MaxSizeInBytes),
+		MaxBackups: int(f.MaxBackups),
+	}
+}
+
 // killContainerV2 is a user forced terminate of the container and all processes
 // in the container. It is equivalent to sending SIGKILL to the init process and
 // all exec'd processes.
@@ -310,11 +357,19 @@ func (b *Bridge) getPropertiesV2(r *Request) (_ RequestResponse, err error) {
 		}
 	}
 
-	if request.ContainerID == hcsv2.UVMContainerID {
+	var properties *prot.PropertiesV2
+	switch {
+	case len(query.ContainerIDs) > 0:
+		if request.ContainerID != hcsv2.UVMContainerID {
+			return nil, gcserr.WrapHresult(errors.New("a multi-container properties query must be sent for the UVM"), gcserr.HrErrInvalidArg)
+		}
+		span.AddAttributes(trace.Int64Attribute("containers", int64(len(query.ContainerIDs))))
+		properties, err = b.hostState.GetContainersProperties(ctx, query)
+	case request.ContainerID == hcsv2.UVMContainerID:
 		return nil, errors.New("getPropertiesV2 is not supported against the UVM")
+	default:
+		properties, err = b.hostState.GetProperties(ctx, request.ContainerID, query)
 	}
-
-	properties, err := b.hostState.GetProperties(ctx, request.ContainerID, query)
 	if err != nil {
 		return nil, err
 	}
@@ -348,7 +403,7 @@ func (b *Bridge) waitOnProcessV2(r *Request) (_ RequestResponse, err error) {
 		trace.Int64Attribute("pid", int64(request.ProcessID)),
 		trace.Int64Attribute("timeout-ms", int64(request.TimeoutInMs)))
 
-	var exitCodeChan <-chan int
+	var exitCodeChan <-chan runtime.ExitStatus
 	var doneChan chan<- bool
 
 	if request.ContainerID == hcsv2.UVMContainerID {
@@ -379,10 +434,17 @@ func (b *Bridge) waitOnProcessV2(r *Request) (_ RequestResponse, err error) {
 		tc = t.C
 	}
 	select {
-	case exitCode := <-exitCodeChan:
-		return &prot.ContainerWaitForProcessResponse{
-			ExitCode: uint32(exitCode),
-		}, nil
+	case exitStatus := <-exitCodeChan:
+		resp := &prot.ContainerWaitForProcessResponse{
+			ExitCode: uint32(exitStatus.Code),
+		}
+		if exitStatus.Signal != 0 {
+			resp.Signal = &prot.ProcessExitSignal{
+				Signal:     int32(exitStatus.Signal),
+				CoreDumped: exitStatus.CoreDumped,
+			}
+		}
+		return resp, nil
 	case <-tc:
 		return nil, gcserr.NewHresultError(gcserr.HvVmcomputeTimeout)
 	}
@@ -455,6 +517,22 @@ func (b *Bridge) dumpStacksV2(r *Request) (_ RequestResponse, err error) {
 	}, nil
 }
 
+// pingV2 echoes the request timestamp back to the host so that it can measure
+// the round trip latency of the bridge.
+func (b *Bridge) pingV2(r *Request) (_ RequestResponse, err error) {
+	_, span := oc.StartSpan(r.Context, "opengcs::bridge::pingV2")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+
+	var request prot.Ping
+	if err := commonutils.UnmarshalJSONWithHresult(r.Message, &request); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal JSON in message \"%s\"", r.Message)
+	}
+	return &prot.PingResponse{
+		Timestamp: request.Timestamp,
+	}, nil
+}
+
 func (b *Bridge) deleteContainerStateV2(r *Request) (_ RequestResponse, err error) {
 	ctx, span := oc.StartSpan(r.Context, "opengcs::bridge::deleteContainerStateV2")
 	defer span.End()
@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/guest/runtime/hcsv2"
+)
+
+func Test_Bridge_NegotiateProtocol_GcsRunTime(t *testing.T) {
+	msg, err := json.Marshal(&prot.NegotiateProtocol{
+		MinimumVersion: uint32(prot.PvV4),
+		MaximumVersion: uint32(prot.PvV4),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	negotiate := func(b *Bridge) *prot.NegotiateProtocolResponse {
+		t.Helper()
+		resp, err := b.negotiateProtocolV2(&Request{Context: context.Background(), Message: msg})
+		if err != nil {
+			t.Fatalf("failed to negotiate protocol: %v", err)
+		}
+		return resp.(*prot.NegotiateProtocolResponse)
+	}
+
+	if rt := negotiate(&Bridge{}).GcsRunTime; rt != 0 {
+		t.Fatalf("expected no run time without a start time, got %d", rt)
+	}
+
+	const running = time.Minute
+	rt := time.Duration(negotiate(&Bridge{StartTime: time.Now().Add(-running)}).GcsRunTime)
+	if rt < running || rt > running+time.Minute {
+		t.Fatalf("expected run time of about %v, got %v", running, rt)
+	}
+}
+
+func Test_Bridge_Capabilities_SupportedResourceTypes(t *testing.T) {
+	for _, rt := range hcsv2.SupportedResourceTypes() {
+		msg, err := json.Marshal(map[string]interface{}{
+			"Request": map[string]interface{}{
+				"ResourceType": rt,
+				"Settings":     map[string]interface{}{},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := prot.UnmarshalContainerModifySettings(msg); err != nil {
+			t.Errorf("advertised resource type %q is not handled: %v", rt, err)
+		}
+	}
+}
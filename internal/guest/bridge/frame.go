@@ -0,0 +1,108 @@
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+)
+
+// DefaultMaxMessageSize is the largest message, including its header, that the
+// bridge reads if [WithMaxMessageSize] is not given.
+const DefaultMaxMessageSize = 256 * 1024 * 1024
+
+// ErrMessageTooLarge is returned by [ReadMessage] for a message larger than the
+// limit it is read with. The payload of the message is not read, so no further
+// messages can be read from the stream.
+var ErrMessageTooLarge = errors.New("bridge: message size exceeds the limit")
+
+// messageTypeMask and messageCategoryMask select the type and category of a
+// [prot.MessageIdentifier].
+const (
+	messageTypeMask     = 0xF0000000
+	messageCategoryMask = 0x0FF00000
+)
+
+// FrameError is returned by [ReadMessage] for a message that was read in full
+// but is not valid. The stream is still positioned at the start of the next
+// message, so only this message fails.
+type FrameError struct {
+	// Header is the header of the invalid message.
+	Header *prot.MessageHeader
+	// Err is the reason the message is invalid. It carries an HRESULT to
+	// return to the host.
+	Err error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("bridge: invalid message %v (id %d, size %d): %v", e.Header.Type, e.Header.ID, e.Header.Size, e.Err)
+}
+
+// Cause returns the reason the message is invalid, so that its HRESULT is found
+// by [gcserr.GetHresult].
+func (e *FrameError) Cause() error {
+	return e.Err
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// IsRequest returns if the invalid message is a request, so the host expects a
+// response correlated to it by its ID.
+func (e *FrameError) IsRequest() bool {
+	return uint32(e.Header.Type)&messageTypeMask == prot.MtRequest
+}
+
+// ReadMessage reads a message from `r` and returns its header and payload.
+//
+// Messages that are not requests or that are not in a known category are
+// skipped and fail with a [*FrameError]. Any other error, including
+// [ErrMessageTooLarge] for messages larger than `maxSize` bytes, means the
+// stream is not positioned at the start of a message anymore and no further
+// messages can be read from it.
+func ReadMessage(r io.Reader, maxSize uint32) (*prot.MessageHeader, []byte, error) {
+	header := &prot.MessageHeader{}
+	if err := binary.Read(r, binary.LittleEndian, header); err != nil {
+		return nil, nil, errors.Wrap(err, "bridge: failed reading message header")
+	}
+	if header.Size < prot.MessageHeaderSize {
+		// the end of the message is unknown, so the next one cannot be found
+		return nil, nil, errors.Errorf("bridge: message %v (id %d) size %d is smaller than its header",
+			header.Type, header.ID, header.Size)
+	}
+
+	if header.Size > maxSize {
+		// fail before the payload is allocated, as a host sending it is either
+		// broken or trying to exhaust the memory of the guest
+		return nil, nil, errors.Wrapf(ErrMessageTooLarge, "message %v (id %d) size %d, limit %d bytes",
+			header.Type, header.ID, header.Size, maxSize)
+	}
+
+	message := make([]byte, header.Size-prot.MessageHeaderSize)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, nil, errors.Wrap(err, "bridge: failed reading message payload")
+	}
+
+	typ := uint32(header.Type)
+	if typ&messageTypeMask != prot.MtRequest {
+		return nil, nil, &FrameError{
+			Header: header,
+			Err:    gcserr.WrapHresult(errors.Errorf("message type %#x is not a request", typ&messageTypeMask), gcserr.HrErrInvalidArg),
+		}
+	}
+	if typ&messageCategoryMask != prot.McComputeSystem {
+		return nil, nil, &FrameError{
+			Header: header,
+			Err:    gcserr.WrapHresult(errors.Errorf("unknown message category %#x", typ&messageCategoryMask), gcserr.HrVmcomputeUnknownMessage),
+		}
+	}
+	return header, message, nil
+}
@@ -0,0 +1,300 @@
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+)
+
+const testMaxMessageSize = 1024
+
+func frame(typ prot.MessageIdentifier, id prot.SequenceID, size uint32, payload []byte) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, prot.MessageHeader{Type: typ, Size: size, ID: id})
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func TestReadMessage(t *testing.T) {
+	payload := []byte(`{"ContainerId":"c1"}`)
+	size := uint32(len(payload) + prot.MessageHeaderSize)
+	for _, tc := range []struct {
+		name  string
+		b     []byte
+		hr    gcserr.Hresult // expected HRESULT of a *FrameError
+		fatal bool
+	}{
+		{
+			name: "valid",
+			b:    frame(prot.ComputeSystemCreateV1, 1, size, payload),
+		},
+		{
+			name: "unknown identifier",
+			b:    frame(prot.MessageIdentifier(prot.MtRequest|prot.McComputeSystem|0xfff01), 1, size, payload),
+		},
+		{
+			name: "response",
+			b:    frame(prot.ComputeSystemResponseCreateV1, 1, size, payload),
+			hr:   gcserr.HrErrInvalidArg,
+		},
+		{
+			name: "notification",
+			b:    frame(prot.ComputeSystemNotificationV1, 1, size, payload),
+			hr:   gcserr.HrErrInvalidArg,
+		},
+		{
+			name: "unknown category",
+			b:    frame(prot.MessageIdentifier(prot.MtRequest|0x00200101), 1, size, payload),
+			hr:   gcserr.HrVmcomputeUnknownMessage,
+		},
+		{
+			name:  "smaller than header",
+			b:     frame(prot.ComputeSystemCreateV1, 1, prot.MessageHeaderSize-1, payload),
+			fatal: true,
+		},
+		{
+			name:  "truncated header",
+			b:     frame(prot.ComputeSystemCreateV1, 1, size, nil)[:prot.MessageHeaderSize-1],
+			fatal: true,
+		},
+		{
+			name:  "truncated payload",
+			b:     frame(prot.ComputeSystemCreateV1, 1, size, payload[:len(payload)-1]),
+			fatal: true,
+		},
+		{
+			name:  "too large",
+			b:     frame(prot.ComputeSystemCreateV1, 1, testMaxMessageSize+1, make([]byte, testMaxMessageSize+1-prot.MessageHeaderSize)),
+			fatal: true,
+		},
+		{
+			name:  "too large without payload",
+			b:     frame(prot.ComputeSystemCreateV1, 1, 0xffffffff, nil),
+			fatal: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.b
+			if !tc.fatal {
+				b = append(b, frame(prot.ComputeSystemPingV1, 2, prot.MessageHeaderSize, nil)...)
+			}
+			r := bytes.NewReader(b)
+			header, message, err := ReadMessage(r, testMaxMessageSize)
+
+			var frameErr *FrameError
+			switch {
+			case tc.fatal:
+				if err == nil || errors.As(err, &frameErr) {
+					t.Fatalf("expected an unrecoverable error, got: %v", err)
+				}
+				return
+			case tc.hr != 0:
+				if !errors.As(err, &frameErr) {
+					t.Fatalf("expected a frame error, got: %v", err)
+				}
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != tc.hr {
+					t.Fatalf("expected HRESULT %v, got %v: %v", tc.hr, hr, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("failed to read message: %v", err)
+				}
+				if !bytes.Equal(message, payload) || header.Size != size {
+					t.Fatalf("unexpected message %+v: %q", header, message)
+				}
+			}
+
+			// the next message must still be readable
+			header, _, err = ReadMessage(r, testMaxMessageSize)
+			if err != nil || header.ID != 2 {
+				t.Fatalf("failed to read the next message %+v: %v", header, err)
+			}
+		})
+	}
+}
+
+func FuzzReadMessage(f *testing.F) {
+	payload := []byte(`{"ContainerId":"c1","ActivityId":"a1"}`)
+	size := uint32(len(payload) + prot.MessageHeaderSize)
+	f.Add(frame(prot.ComputeSystemCreateV1, 1, size, payload))
+	f.Add(frame(prot.ComputeSystemResponseCreateV1, 1, size, payload))
+	f.Add(frame(prot.MessageIdentifier(prot.MtRequest|0x00200101), 1, size, payload))
+	f.Add(frame(prot.ComputeSystemCreateV1, 1, testMaxMessageSize+1, payload))
+	f.Add(frame(prot.ComputeSystemCreateV1, 1, 0, payload))
+	f.Add(frame(prot.ComputeSystemCreateV1, 1, 0xffffffff, payload))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		r := bytes.NewReader(b)
+		header, message, err := ReadMessage(r, testMaxMessageSize)
+		read := len(b) - r.Len()
+
+		var frameErr *FrameError
+		switch {
+		case err == nil:
+			if header.Size > testMaxMessageSize || int(header.Size) != len(message)+prot.MessageHeaderSize {
+				t.Fatalf("unexpected message %+v with payload of %d bytes", header, len(message))
+			}
+			if read != int(header.Size) {
+				t.Fatalf("read %d bytes of a %d byte message", read, header.Size)
+			}
+		case errors.As(err, &frameErr):
+			// the invalid message is skipped, so the next one can be read
+			if read != int(frameErr.Header.Size) {
+				t.Fatalf("read %d bytes of a %d byte invalid message", read, frameErr.Header.Size)
+			}
+			// the error is returned to the host, which expects an HRESULT
+			if _, herr := gcserr.GetHresult(err); herr != nil {
+				t.Fatalf("error without an HRESULT: %v", err)
+			}
+		}
+	})
+}
+
+func Test_Bridge_ListenAndServe_InvalidMessages(t *testing.T) {
+	// Turn off logging so as not to spam output.
+	logrus.SetOutput(io.Discard)
+
+	lc := newLoopbackConnection()
+	defer lc.close()
+
+	b := &Bridge{
+		protVer: prot.PvV4,
+	}
+	mux := NewBridgeMux()
+	mux.HandleFunc(prot.ComputeSystemPingV1, prot.PvV4, b.pingV2)
+	b.Handler = mux
+
+	go func() {
+		if err := b.ListenAndServe(lc.SRead(), lc.SWrite(), WithMaxMessageSize(testMaxMessageSize)); err != nil {
+			t.Error(err)
+		}
+	}()
+	defer func() {
+		b.quitChan <- true
+	}()
+
+	for _, tc := range []struct {
+		name string
+		id   prot.SequenceID
+		b    []byte
+		hr   gcserr.Hresult
+	}{
+		{
+			name: "unknown category",
+			id:   2,
+			b:    frame(prot.MessageIdentifier(prot.MtRequest|0x00200101), 2, prot.MessageHeaderSize, nil),
+			hr:   gcserr.HrVmcomputeUnknownMessage,
+		},
+		{
+			name: "unknown identifier",
+			id:   3,
+			b:    frame(prot.MessageIdentifier(prot.MtRequest|prot.McComputeSystem|0xfff01), 3, prot.MessageHeaderSize, nil),
+			hr:   gcserr.HrNotImpl,
+		},
+	} {
+		// responses are not responded to, and must not stop the next message
+		// from being handled
+		if _, err := lc.CWrite().Write(frame(prot.ComputeSystemResponsePingV1, 100, prot.MessageHeaderSize, nil)); err != nil {
+			t.Fatalf("failed to send response: %v", err)
+		}
+		if _, err := lc.CWrite().Write(tc.b); err != nil {
+			t.Fatalf("failed to send %s message: %v", tc.name, err)
+		}
+		header, body, err := serverRead(lc.CRead())
+		if err != nil {
+			t.Fatalf("failed to read %s message response: %v", tc.name, err)
+		}
+		response := &prot.MessageResponseBase{}
+		if err := json.Unmarshal(body, response); err != nil {
+			t.Fatalf("failed to unmarshal %s message response: %v", tc.name, err)
+		}
+		if header.ID != tc.id {
+			t.Fatalf("%s message response has id %d, expected %d", tc.name, header.ID, tc.id)
+		}
+		if response.Result != int32(tc.hr) {
+			t.Fatalf("%s message response has result %#x, expected %#x", tc.name, uint32(response.Result), uint32(tc.hr))
+		}
+	}
+
+	// the bridge still handles valid messages
+	if err := serverSend(lc.CWrite(), prot.ComputeSystemPingV1, prot.SequenceID(4), &prot.Ping{}); err != nil {
+		t.Fatalf("failed to send ping: %v", err)
+	}
+	header, body, err := serverRead(lc.CRead())
+	if err != nil {
+		t.Fatalf("failed to read ping response: %v", err)
+	}
+	response := &prot.PingResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		t.Fatalf("failed to unmarshal ping response: %v", err)
+	}
+	if header.Type != prot.ComputeSystemResponsePingV1 || header.ID != 4 || response.Result != 0 {
+		t.Fatalf("unexpected ping response %+v: %+v", header, response)
+	}
+}
+
+func Test_Bridge_ListenAndServe_MessageTooLarge(t *testing.T) {
+	// Turn off logging so as not to spam output.
+	logrus.SetOutput(io.Discard)
+
+	lc := newLoopbackConnection()
+	defer lc.close()
+
+	b := &Bridge{
+		Handler: UnknownMessageHandler(),
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- b.ListenAndServe(lc.SRead(), lc.SWrite(), WithMaxMessageSize(testMaxMessageSize))
+	}()
+
+	// only the header is sent, as the bridge must not wait for the payload
+	if _, err := lc.CWrite().Write(frame(prot.ComputeSystemCreateV1, 1, 0xffffffff, nil)); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("expected ListenAndServe to fail with %v, got: %v", ErrMessageTooLarge, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the bridge to drop the connection")
+	}
+
+	// the connection is closed without a response
+	if _, _, err := serverRead(lc.CRead()); err == nil {
+		t.Fatal("expected the connection to be closed")
+	}
+}
+
+func TestWithMaxMessageSize(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want uint32
+	}{
+		{n: testMaxMessageSize, want: testMaxMessageSize},
+		{n: 0, want: DefaultMaxMessageSize},
+		{n: -1, want: DefaultMaxMessageSize},
+		{n: math.MaxUint32 + 1, want: math.MaxUint32},
+	} {
+		o := serveOptions{}
+		WithMaxMessageSize(tc.n)(&o)
+		if o.maxMessageSize != tc.want {
+			t.Errorf("expected a limit of %d bytes for %d, got %d", tc.want, tc.n, o.maxMessageSize)
+		}
+	}
+}
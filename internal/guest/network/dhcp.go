@@ -0,0 +1,90 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+)
+
+// ValidateClasslessStaticRoutes returns an error if `b` is not a valid payload
+// of the classless static route DHCP option.
+func ValidateClasslessStaticRoutes(b []byte) error {
+	if len(b) > 255 {
+		return errors.New("invalid classless static routes: payload is longer than 255 bytes")
+	}
+	if _, err := parseClasslessStaticRoutes(b); err != nil {
+		return fmt.Errorf("invalid classless static routes: %w", err)
+	}
+	return nil
+}
+
+// classlessStaticRoute is a route in the format of the classless static route
+// DHCP option, as defined in RFC 3442.
+type classlessStaticRoute struct {
+	Destination *net.IPNet
+	// Router is the next hop for the route, or 0.0.0.0 if the destination is
+	// on-link.
+	Router net.IP
+}
+
+// parseClasslessStaticRoutes parses the payload of the classless static route
+// DHCP option. Each route is encoded as the destination prefix length,
+// followed by the significant octets of the destination and the four octets
+// of the router.
+func parseClasslessStaticRoutes(b []byte) ([]classlessStaticRoute, error) {
+	var routes []classlessStaticRoute
+	for len(b) > 0 {
+		width := int(b[0])
+		if width > 32 {
+			return nil, fmt.Errorf("invalid destination prefix length %d", width)
+		}
+		n := (width + 7) / 8
+		if len(b) < 1+n+net.IPv4len {
+			return nil, errors.New("truncated classless static route")
+		}
+		dst := make(net.IP, net.IPv4len)
+		copy(dst, b[1:1+n])
+		mask := net.CIDRMask(width, 32)
+		routes = append(routes, classlessStaticRoute{
+			Destination: &net.IPNet{IP: dst.Mask(mask), Mask: mask},
+			Router:      net.IP(slices.Clone(b[1+n : 1+n+net.IPv4len])),
+		})
+		b = b[1+n+net.IPv4len:]
+	}
+	return routes, nil
+}
+
+// applyClasslessStaticRoutes adds the static classless routes in `b`, the
+// payload of the classless static route DHCP option, to `link` once its lease
+// was acquired.
+//
+// The DHCP client script in the guest does not know about these routes, so
+// they are added here, replacing any existing route to the same destination
+// (e.g., one added by the DHCP client script from the server's own option 121).
+func applyClasslessStaticRoutes(link netlink.Link, b []byte) error {
+	routes, err := parseClasslessStaticRoutes(b)
+	if err != nil {
+		return fmt.Errorf("invalid classless static routes: %w", err)
+	}
+	for _, r := range routes {
+		route := &netlink.Route{
+			LinkIndex: link.Attrs().Index,
+			Dst:       r.Destination,
+		}
+		if r.Router.IsUnspecified() {
+			route.Scope = netlink.SCOPE_LINK
+		} else {
+			route.Gw = r.Router
+		}
+		if err := netlinkRouteReplace(route); err != nil {
+			return errors.Wrapf(err, "netlink.RouteReplace(%#v) failed", route)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func Test_parseClasslessStaticRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		payload  []byte
+		expected []string // "destination via router"
+	}{
+		{
+			name:     "SingleRoute",
+			payload:  []byte{16, 10, 99, 192, 168, 0, 1},
+			expected: []string{"10.99.0.0/16 via 192.168.0.1"},
+		},
+		{
+			name:     "DefaultRoute",
+			payload:  []byte{0, 192, 168, 0, 1},
+			expected: []string{"0.0.0.0/0 via 192.168.0.1"},
+		},
+		{
+			name:     "OnLink",
+			payload:  []byte{24, 172, 16, 5, 0, 0, 0, 0},
+			expected: []string{"172.16.5.0/24 via 0.0.0.0"},
+		},
+		{
+			name:    "MultipleRoutes",
+			payload: []byte{32, 10, 0, 0, 1, 192, 168, 0, 1, 9, 10, 128, 192, 168, 0, 2},
+			expected: []string{
+				"10.0.0.1/32 via 192.168.0.1",
+				"10.128.0.0/9 via 192.168.0.2",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			routes, err := parseClasslessStaticRoutes(tc.payload)
+			if err != nil {
+				t.Fatalf("failed to parse routes: %v", err)
+			}
+			if len(routes) != len(tc.expected) {
+				t.Fatalf("expected %d routes, got %d", len(tc.expected), len(routes))
+			}
+			for i, r := range routes {
+				if s := r.Destination.String() + " via " + r.Router.String(); s != tc.expected[i] {
+					t.Fatalf("expected route %q, got %q", tc.expected[i], s)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseClasslessStaticRoutes_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		payload []byte
+	}{
+		{
+			name:    "PrefixTooLong",
+			payload: []byte{33, 10, 0, 0, 1, 0, 192, 168, 0, 1},
+		},
+		{
+			name:    "TruncatedDestination",
+			payload: []byte{16, 10},
+		},
+		{
+			name:    "TruncatedRouter",
+			payload: []byte{16, 10, 99, 192, 168},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseClasslessStaticRoutes(tc.payload); err == nil {
+				t.Fatal("expected error parsing invalid routes")
+			}
+			if err := ValidateClasslessStaticRoutes(tc.payload); err == nil {
+				t.Fatal("expected error validating invalid routes")
+			}
+		})
+	}
+}
+
+func Test_applyClasslessStaticRoutes(t *testing.T) {
+	orig := netlinkRouteReplace
+	t.Cleanup(func() { netlinkRouteReplace = orig })
+
+	link := newFakeLink("eth0", 2)
+	count := 0
+	expected := []*testRoute{
+		{dstIP: "10.99.0.0/16", gw: "192.168.0.1"},
+		{dstIP: "172.16.5.0/24", scope: netlink.SCOPE_LINK},
+	}
+	netlinkRouteReplace = standardNetlinkRouteAdd(&count, link, expected)
+
+	routes := []byte{16, 10, 99, 192, 168, 0, 1, 24, 172, 16, 5, 0, 0, 0, 0}
+	if err := applyClasslessStaticRoutes(link, routes); err != nil {
+		t.Fatalf("failed to add routes: %v", err)
+	}
+	if count != len(expected) {
+		t.Fatalf("expected %d routes to be added, got %d", len(expected), count)
+	}
+}
+
+func Test_ValidateClasslessStaticRoutes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		routes []byte
+		valid  bool
+	}{
+		{
+			name:  "none",
+			valid: true,
+		},
+		{
+			name:   "route",
+			routes: []byte{16, 10, 99, 192, 168, 0, 1},
+			valid:  true,
+		},
+		{
+			name:   "truncated route",
+			routes: []byte{16, 10, 99, 192},
+		},
+		{
+			name:   "too long",
+			routes: bytes.Repeat([]byte{0, 192, 168, 0, 1}, 52),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateClasslessStaticRoutes(tc.routes)
+			if tc.valid && err != nil {
+				t.Fatalf("expected routes to be valid, got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected routes to be invalid")
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ package network
 import (
 	"context"
 	"fmt"
+	"math"
 	"net"
 	"os/exec"
 	"runtime"
@@ -19,6 +20,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
 )
 
 var (
@@ -26,6 +28,10 @@ var (
 	netlinkAddrAdd  = netlink.AddrAdd
 	netlinkRouteAdd = netlink.RouteAdd
 	netlinkRuleAdd  = netlink.RuleAdd
+	// for mocking applyClasslessStaticRoutes
+	netlinkRouteReplace = netlink.RouteReplace
+	// for mocking setLinkMTU
+	netlinkLinkSetMTU = netlink.LinkSetMTU
 )
 
 const (
@@ -35,8 +41,246 @@ const (
 	ipv6EmptyGw       = "::"
 
 	unreachableErrStr = "network is unreachable"
+
+	// maxVLANTag is the largest valid VLAN ID; 4095 is reserved by 802.1Q.
+	maxVLANTag = 4094
+
+	// egressBurstDuration and egressLatency are the time at the shaped rate
+	// that the token bucket can send in a burst, and that a packet can wait
+	// in the queue before being dropped.
+	egressBurstDuration = 10 * time.Millisecond
+	egressLatency       = 100 * time.Millisecond
+	// minEgressBurst is the smallest burst, in bytes, of 1mbit.
+	minEgressBurst = 1_000_000 / 8
+
+	// ifbPrefix is the prefix of the name of the ifb interface that the
+	// traffic received on an interface is redirected to, to be shaped on its
+	// way out of the ifb interface.
+	ifbPrefix = "ifb"
 )
 
+// ValidateVLANTag returns an error if `tag` is not a valid 802.1Q VLAN ID.
+// Zero is valid, and means the traffic is not tagged.
+func ValidateVLANTag(tag uint16) error {
+	if tag > maxVLANTag {
+		return fmt.Errorf("invalid VLAN tag %d: must be between 1 and %d", tag, maxVLANTag)
+	}
+	return nil
+}
+
+// ValidateMTU returns an error if `mtu` is too small for an IP link.
+// Zero is valid, and means the interface MTU is left unchanged.
+func ValidateMTU(mtu uint16) error {
+	if mtu != 0 && mtu < guestresource.MinNetworkMTU {
+		return fmt.Errorf("invalid MTU %d: must be at least %d", mtu, guestresource.MinNetworkMTU)
+	}
+	return nil
+}
+
+// ValidateEgressBandwidth returns an error if `bps` is too small a rate, in
+// bits per second, to shape an interface's traffic to. Zero is valid, and means
+// the traffic is not shaped.
+func ValidateEgressBandwidth(bps uint64) error {
+	if bps != 0 && bps < guestresource.MinNetworkBandwidth {
+		return fmt.Errorf("invalid egress bandwidth %d: must be at least %d bits per second", bps, guestresource.MinNetworkBandwidth)
+	}
+	return nil
+}
+
+// AdapterEgressBandwidth returns the rate, in bits per second, to shape the
+// traffic sent on the interface of `adapter` to: the lower of the cap on the
+// bandwidth of the UVM's adapters and the adapter's own, or 0 if neither is
+// set.
+func AdapterEgressBandwidth(adapter *guestresource.LCOWNetworkAdapter) uint64 {
+	bps := uint64(adapter.EgressBandwidthMbps) * 1_000_000
+	if adapter.EgressBandwidth != 0 && (bps == 0 || adapter.EgressBandwidth < bps) {
+		bps = adapter.EgressBandwidth
+	}
+	return bps
+}
+
+// egressTbf returns the token bucket filter qdisc that shapes the traffic sent
+// on the interface with index `linkIndex` to `bps` bits per second, as with
+// `tc qdisc replace dev <link> root tbf rate <bps>bit burst 1mbit latency 100ms`,
+// with a larger burst at higher rates.
+func egressTbf(linkIndex int, bps uint64) *netlink.Tbf {
+	rate := bps / 8
+	burst := rate * uint64(egressBurstDuration) / uint64(time.Second)
+	if burst < minEgressBurst {
+		burst = minEgressBurst
+	}
+	return &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Handle:    netlink.MakeHandle(1, 0),
+			Parent:    netlink.HANDLE_ROOT,
+		},
+		Rate:   rate,
+		Limit:  uint32(min(rate*uint64(egressLatency)/uint64(time.Second)+burst, math.MaxUint32)),
+		Buffer: netlink.Xmittime(rate, uint32(min(burst, math.MaxUint32))),
+	}
+}
+
+// SetEgressBandwidth shapes the traffic sent on `link` to `bps` bits per second,
+// replacing the root qdisc of `link`. If `bps` is zero, the shaping is removed
+// and the default root qdisc is restored.
+func SetEgressBandwidth(link netlink.Link, bps uint64) error {
+	if err := ValidateEgressBandwidth(bps); err != nil {
+		return err
+	}
+	tbf := egressTbf(link.Attrs().Index, bps)
+	if bps == 0 {
+		if err := netlink.QdiscDel(tbf); err != nil && !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.EINVAL) {
+			return errors.Wrapf(err, "netlink.QdiscDel(%s) failed", link.Attrs().Name)
+		}
+		return nil
+	}
+	if err := netlink.QdiscReplace(tbf); err != nil {
+		return errors.Wrapf(err, "netlink.QdiscReplace(%s, %d) failed", link.Attrs().Name, bps)
+	}
+	return nil
+}
+
+// ValidateIngressBandwidth returns an error if `bps` is too small a rate, in
+// bits per second, to shape an interface's received traffic to. Zero is valid,
+// and means the traffic is not shaped.
+func ValidateIngressBandwidth(bps uint64) error {
+	if bps != 0 && bps < guestresource.MinNetworkBandwidth {
+		return fmt.Errorf("invalid ingress bandwidth %d: must be at least %d bits per second", bps, guestresource.MinNetworkBandwidth)
+	}
+	return nil
+}
+
+// ifbName returns the name of the ifb interface that the traffic received on
+// the interface with index `linkIndex` is redirected to.
+func ifbName(linkIndex int) string {
+	return fmt.Sprintf("%s%d", ifbPrefix, linkIndex)
+}
+
+// ingressQdisc returns the ingress qdisc of the interface with index
+// `linkIndex`, which the filters on the traffic received on it are attached to.
+func ingressQdisc(linkIndex int) *netlink.Ingress {
+	return &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Handle:    netlink.MakeHandle(0xffff, 0),
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+}
+
+// ingressRedirect returns the filter that redirects all the traffic received on
+// the interface with index `linkIndex` to the interface with index `ifbIndex`.
+func ingressRedirect(linkIndex, ifbIndex int) *netlink.U32 {
+	return &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: linkIndex,
+			Parent:    netlink.MakeHandle(0xffff, 0),
+			Priority:  1,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		// a nil selector matches all packets
+		Actions: []netlink.Action{netlink.NewMirredAction(ifbIndex)},
+	}
+}
+
+// SetIngressBandwidth shapes the traffic received on `link` to `bps` bits per
+// second. A qdisc can only shape the traffic an interface sends, so the traffic
+// is redirected to an ifb interface that is shaped as with
+// [SetEgressBandwidth]. This replaces the ingress qdisc of `link` and its
+// filters. If `bps` is zero, the shaping and the ifb interface are removed.
+func SetIngressBandwidth(link netlink.Link, bps uint64) error {
+	if err := ValidateIngressBandwidth(bps); err != nil {
+		return err
+	}
+	name := ifbName(link.Attrs().Index)
+	qdisc := ingressQdisc(link.Attrs().Index)
+	// deleting the ingress qdisc also deletes the filters attached to it
+	if err := netlink.QdiscDel(qdisc); err != nil && !errors.Is(err, unix.ENOENT) && !errors.Is(err, unix.EINVAL) {
+		return errors.Wrapf(err, "netlink.QdiscDel(%s) failed", link.Attrs().Name)
+	}
+	if bps == 0 {
+		ifb, err := netlink.LinkByName(name)
+		if err != nil {
+			var notFound netlink.LinkNotFoundError
+			if errors.As(err, &notFound) {
+				return nil
+			}
+			return errors.Wrapf(err, "netlink.LinkByName(%s) failed", name)
+		}
+		if err := netlink.LinkDel(ifb); err != nil {
+			return errors.Wrapf(err, "netlink.LinkDel(%s) failed", name)
+		}
+		return nil
+	}
+
+	ifb, err := netlink.LinkByName(name)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if !errors.As(err, &notFound) {
+			return errors.Wrapf(err, "netlink.LinkByName(%s) failed", name)
+		}
+		if err := netlink.LinkAdd(&netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: name}}); err != nil {
+			return errors.Wrapf(err, "netlink.LinkAdd(%s) failed", name)
+		}
+		if ifb, err = netlink.LinkByName(name); err != nil {
+			return errors.Wrapf(err, "netlink.LinkByName(%s) failed", name)
+		}
+	}
+	if err := netlink.LinkSetUp(ifb); err != nil {
+		return errors.Wrapf(err, "netlink.LinkSetUp(%s) failed", name)
+	}
+	if err := SetEgressBandwidth(ifb, bps); err != nil {
+		return err
+	}
+	if err := netlink.QdiscAdd(qdisc); err != nil {
+		return errors.Wrapf(err, "netlink.QdiscAdd(%s) failed", link.Attrs().Name)
+	}
+	if err := netlink.FilterAdd(ingressRedirect(link.Attrs().Index, ifb.Attrs().Index)); err != nil {
+		return errors.Wrapf(err, "netlink.FilterAdd(%s, %s) failed", link.Attrs().Name, name)
+	}
+	return nil
+}
+
+// adapterMTU returns the effective MTU of the interface of `adapter`, whose
+// current MTU is `linkMTU`, or 0 if it should be left unchanged. An explicit MTU
+// from the host takes precedence. Otherwise, the current MTU is reduced by the
+// encap overhead of the adapter's HNS network, so that encapsulated packets
+// still fit in the MTU of the host's network.
+func adapterMTU(adapter *guestresource.LCOWNetworkAdapter, linkMTU int) (int, error) {
+	if adapter.MTU != 0 {
+		return int(adapter.MTU), nil
+	}
+	if adapter.EncapOverhead == 0 {
+		return 0, nil
+	}
+	mtu := linkMTU - int(adapter.EncapOverhead)
+	if mtu < guestresource.MinNetworkMTU {
+		return 0, fmt.Errorf("encap overhead %d leaves an MTU of less than %d on an interface with MTU %d",
+			adapter.EncapOverhead, guestresource.MinNetworkMTU, linkMTU)
+	}
+	return mtu, nil
+}
+
+// setLinkMTU sets the effective MTU of `adapter` on its interface `link`, if it
+// differs from the interface's MTU.
+func setLinkMTU(link netlink.Link, adapter *guestresource.LCOWNetworkAdapter) error {
+	mtu, err := adapterMTU(adapter, link.Attrs().MTU)
+	if err != nil {
+		return err
+	}
+	if mtu == 0 || mtu == link.Attrs().MTU {
+		return nil
+	}
+	if err := ValidateMTU(uint16(mtu)); err != nil {
+		return err
+	}
+	if err := netlinkLinkSetMTU(link, mtu); err != nil {
+		return errors.Wrapf(err, "netlink.LinkSetMTU(%#v, %d) failed", link, mtu)
+	}
+	return nil
+}
+
 // MoveInterfaceToNS moves the adapter with interface name `ifStr` to the network namespace
 // of `pid`.
 func MoveInterfaceToNS(ifStr string, pid int) error {
@@ -108,13 +352,38 @@ func NetNSConfig(ctx context.Context, ifStr string, nsPid int, adapter *guestres
 		return errors.Wrapf(err, "netlink.LinkByName(%s) failed", ifStr)
 	}
 
-	// User requested non-default MTU size
-	if adapter.EncapOverhead != 0 {
-		mtu := link.Attrs().MTU - int(adapter.EncapOverhead)
-		entry.WithField("mtu", mtu).Debug("EncapOverhead non-zero, will set MTU")
-		if err = netlink.LinkSetMTU(link, mtu); err != nil {
-			return errors.Wrapf(err, "netlink.LinkSetMTU(%#v, %d) failed", link, mtu)
+	// Set the MTU from the host, or the one left by the encap overhead
+	if err := setLinkMTU(link, adapter); err != nil {
+		return err
+	}
+
+	// Shape the traffic of the adapter, including any VLAN link on top of it
+	if bps := AdapterEgressBandwidth(adapter); bps != 0 {
+		entry.WithField("bandwidth", bps).Debug("will shape egress traffic")
+		if err := SetEgressBandwidth(link, bps); err != nil {
+			return err
+		}
+	}
+	if adapter.IngressBandwidthMbps != 0 {
+		bps := uint64(adapter.IngressBandwidthMbps) * 1_000_000
+		entry.WithField("bandwidth", bps).Debug("will shape ingress traffic")
+		if err := SetIngressBandwidth(link, bps); err != nil {
+			return err
+		}
+	}
+
+	// Tag the adapter's traffic by configuring the interface on a VLAN link on
+	// top of the adapter
+	if adapter.VLANTag != 0 {
+		if err := ValidateVLANTag(adapter.VLANTag); err != nil {
+			return err
+		}
+		entry.WithField("vlan", adapter.VLANTag).Debug("VLANTag non-zero, will add VLAN link")
+		link, err = addVLANLink(link, adapter.VLANTag)
+		if err != nil {
+			return err
 		}
+		ifStr = link.Attrs().Name
 	}
 
 	// Configure the interface
@@ -167,6 +436,10 @@ func NetNSConfig(ctx context.Context, ifStr string, nsPid int, adapter *guestres
 			cos = string(co)
 		}
 		entry.Debugf("udhcpc succeeded: %s", cos)
+
+		if err := applyClasslessStaticRoutes(link, adapter.ClasslessStaticRoutes); err != nil {
+			return err
+		}
 	}
 
 	// Add some debug logging
@@ -188,6 +461,33 @@ func NetNSConfig(ctx context.Context, ifStr string, nsPid int, adapter *guestres
 	return nil
 }
 
+// addVLANLink adds a VLAN link with ID `tag` on top of `parent`, named
+// `<parent>.<tag>`, and brings `parent` up.
+func addVLANLink(parent netlink.Link, tag uint16) (netlink.Link, error) {
+	// the VLAN link only passes traffic if its parent is up
+	if err := netlink.LinkSetUp(parent); err != nil {
+		return nil, fmt.Errorf("netlink.LinkSetUp(%#v) failed: %w", parent, err)
+	}
+
+	name := fmt.Sprintf("%s.%d", parent.Attrs().Name, tag)
+	vlan := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        name,
+			ParentIndex: parent.Attrs().Index,
+		},
+		VlanId: int(tag),
+	}
+	if err := netlink.LinkAdd(vlan); err != nil {
+		return nil, fmt.Errorf("netlink.LinkAdd(%#v) failed: %w", vlan, err)
+	}
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "netlink.LinkByName(%s) failed", name)
+	}
+	return link, nil
+}
+
 func configureLink(ctx context.Context,
 	link netlink.Link,
 	adapter *guestresource.LCOWNetworkAdapter,
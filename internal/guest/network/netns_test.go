@@ -828,3 +828,264 @@ func Test_configureLink_Bad_Route_IPv4(t *testing.T) {
 		t.Fatal("configureLink expected error due to badly formed route")
 	}
 }
+
+func Test_ValidateVLANTag(t *testing.T) {
+	for _, tc := range []struct {
+		tag   uint16
+		valid bool
+	}{
+		{tag: 0, valid: true},
+		{tag: 1, valid: true},
+		{tag: 100, valid: true},
+		{tag: 4094, valid: true},
+		{tag: 4095, valid: false},
+		{tag: 65535, valid: false},
+	} {
+		t.Run(fmt.Sprint(tc.tag), func(t *testing.T) {
+			err := ValidateVLANTag(tc.tag)
+			if tc.valid && err != nil {
+				t.Fatalf("expected VLAN tag %d to be valid, got: %v", tc.tag, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected VLAN tag %d to be invalid", tc.tag)
+			}
+		})
+	}
+}
+
+func Test_ValidateMTU(t *testing.T) {
+	for _, tc := range []struct {
+		mtu   uint16
+		valid bool
+	}{
+		{mtu: 0, valid: true},
+		{mtu: 67, valid: false},
+		{mtu: 68, valid: true},
+		{mtu: 1450, valid: true},
+		{mtu: 9000, valid: true},
+	} {
+		t.Run(fmt.Sprint(tc.mtu), func(t *testing.T) {
+			err := ValidateMTU(tc.mtu)
+			if tc.valid && err != nil {
+				t.Fatalf("expected MTU %d to be valid, got: %v", tc.mtu, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected MTU %d to be invalid", tc.mtu)
+			}
+		})
+	}
+}
+
+func Test_setLinkMTU(t *testing.T) {
+	orig := netlinkLinkSetMTU
+	t.Cleanup(func() { netlinkLinkSetMTU = orig })
+
+	for _, tc := range []struct {
+		name     string
+		adapter  guestresource.LCOWNetworkAdapter
+		linkMTU  int
+		expected int // 0 if the MTU should be left unchanged
+		err      bool
+	}{
+		{
+			name:    "Unchanged",
+			linkMTU: 1500,
+		},
+		{
+			name:     "EncapOverhead",
+			adapter:  guestresource.LCOWNetworkAdapter{EncapOverhead: 50},
+			linkMTU:  1500,
+			expected: 1450,
+		},
+		{
+			name:     "EncapOverheadJumboFrames",
+			adapter:  guestresource.LCOWNetworkAdapter{EncapOverhead: 50},
+			linkMTU:  9000,
+			expected: 8950,
+		},
+		{
+			name:    "EncapOverheadTooLarge",
+			adapter: guestresource.LCOWNetworkAdapter{EncapOverhead: 1450},
+			linkMTU: 1500,
+			err:     true,
+		},
+		{
+			name:     "MTU",
+			adapter:  guestresource.LCOWNetworkAdapter{MTU: 1400},
+			linkMTU:  1500,
+			expected: 1400,
+		},
+		{
+			name:     "MTUOverridesEncapOverhead",
+			adapter:  guestresource.LCOWNetworkAdapter{MTU: 1450, EncapOverhead: 50},
+			linkMTU:  1500,
+			expected: 1450,
+		},
+		{
+			name:    "MTUAlreadySet",
+			adapter: guestresource.LCOWNetworkAdapter{MTU: 1400},
+			linkMTU: 1400,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			link := newFakeLink("eth0", 2)
+			link.attr.MTU = tc.linkMTU
+			set := 0
+			netlinkLinkSetMTU = func(_ netlink.Link, mtu int) error {
+				set = mtu
+				return nil
+			}
+
+			err := setLinkMTU(link, &tc.adapter)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to set MTU: %v", err)
+			}
+			if set != tc.expected {
+				t.Fatalf("expected MTU %d to be set, got %d", tc.expected, set)
+			}
+		})
+	}
+}
+
+func Test_ValidateEgressBandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		bps   uint64
+		valid bool
+	}{
+		{bps: 0, valid: true},
+		{bps: 999_999, valid: false},
+		{bps: 1_000_000, valid: true},
+		{bps: 1_000_000_000, valid: true},
+	} {
+		t.Run(fmt.Sprint(tc.bps), func(t *testing.T) {
+			err := ValidateEgressBandwidth(tc.bps)
+			if tc.valid && err != nil {
+				t.Fatalf("expected egress bandwidth %d to be valid, got: %v", tc.bps, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected egress bandwidth %d to be invalid", tc.bps)
+			}
+		})
+	}
+}
+
+func Test_egressTbf(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		bps   uint64
+		rate  uint64
+		limit uint32
+	}{
+		{
+			name:  "MinimumBurst",
+			bps:   10_000_000,
+			rate:  1_250_000,
+			limit: 125_000 + minEgressBurst,
+		},
+		{
+			name:  "RateBurst",
+			bps:   1_000_000_000,
+			rate:  125_000_000,
+			limit: 12_500_000 + 1_250_000,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tbf := egressTbf(3, tc.bps)
+			if tbf.LinkIndex != 3 || tbf.Parent != netlink.HANDLE_ROOT {
+				t.Fatalf("expected root qdisc on link 3, got %+v", tbf.QdiscAttrs)
+			}
+			if tbf.Rate != tc.rate {
+				t.Fatalf("expected rate %d, got %d", tc.rate, tbf.Rate)
+			}
+			if tbf.Limit != tc.limit {
+				t.Fatalf("expected limit %d, got %d", tc.limit, tbf.Limit)
+			}
+			if tbf.Buffer == 0 {
+				t.Fatal("expected non-zero buffer")
+			}
+		})
+	}
+}
+
+func Test_ValidateIngressBandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		bps   uint64
+		valid bool
+	}{
+		{bps: 0, valid: true},
+		{bps: 999_999, valid: false},
+		{bps: 1_000_000, valid: true},
+		{bps: 1_000_000_000, valid: true},
+	} {
+		t.Run(fmt.Sprint(tc.bps), func(t *testing.T) {
+			err := ValidateIngressBandwidth(tc.bps)
+			if tc.valid && err != nil {
+				t.Fatalf("expected ingress bandwidth %d to be valid, got: %v", tc.bps, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected ingress bandwidth %d to be invalid", tc.bps)
+			}
+		})
+	}
+}
+
+func Test_AdapterEgressBandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		adapter  guestresource.LCOWNetworkAdapter
+		expected uint64
+	}{
+		{
+			name: "None",
+		},
+		{
+			name:     "Bandwidth",
+			adapter:  guestresource.LCOWNetworkAdapter{EgressBandwidth: 5_000_000},
+			expected: 5_000_000,
+		},
+		{
+			name:     "Mbps",
+			adapter:  guestresource.LCOWNetworkAdapter{EgressBandwidthMbps: 10},
+			expected: 10_000_000,
+		},
+		{
+			name:     "LowerBandwidth",
+			adapter:  guestresource.LCOWNetworkAdapter{EgressBandwidth: 5_000_000, EgressBandwidthMbps: 10},
+			expected: 5_000_000,
+		},
+		{
+			name:     "LowerMbps",
+			adapter:  guestresource.LCOWNetworkAdapter{EgressBandwidth: 50_000_000, EgressBandwidthMbps: 10},
+			expected: 10_000_000,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if bps := AdapterEgressBandwidth(&tc.adapter); bps != tc.expected {
+				t.Fatalf("expected egress bandwidth %d, got %d", tc.expected, bps)
+			}
+		})
+	}
+}
+
+func Test_ingressRedirect(t *testing.T) {
+	f := ingressRedirect(3, 7)
+	if q := ingressQdisc(3); f.LinkIndex != 3 || f.Parent != q.Handle {
+		t.Fatalf("expected filter on the ingress qdisc of link 3, got %+v", f.FilterAttrs)
+	}
+	if f.Sel != nil {
+		t.Fatalf("expected filter to match all packets, got %+v", f.Sel)
+	}
+	if len(f.Actions) != 1 {
+		t.Fatalf("expected a single action, got %+v", f.Actions)
+	}
+	m, ok := f.Actions[0].(*netlink.MirredAction)
+	if !ok || m.MirredAction != netlink.TCA_EGRESS_REDIR || m.Ifindex != 7 {
+		t.Fatalf("expected traffic to be redirected to link 7, got %+v", f.Actions[0])
+	}
+}
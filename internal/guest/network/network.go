@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/guest/storage/vmbus"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 )
@@ -56,6 +58,20 @@ func GenerateEtcHostsContent(ctx context.Context, hostname string) string {
 	return buf.String()
 }
 
+// GenerateEtcHostsAliasesContent generates the /etc/hosts entries for
+// `aliases`, to be appended to the content from [GenerateEtcHostsContent].
+func GenerateEtcHostsAliasesContent(aliases []guestresource.HostAlias) string {
+	if len(aliases) == 0 {
+		return ""
+	}
+	buf := bytes.Buffer{}
+	buf.WriteString("\n# Entries added by HostAliases.\n")
+	for _, a := range aliases {
+		buf.WriteString(fmt.Sprintf("%s\t%s\n", a.IP, strings.Join(a.Hostnames, "\t")))
+	}
+	return buf.String()
+}
+
 // GenerateResolvConfContent generates the resolv.conf file content based on
 // `searches`, `servers`, and `options`.
 func GenerateResolvConfContent(ctx context.Context, searches, servers, options []string) (_ string, err error) {
@@ -85,6 +101,68 @@ func GenerateResolvConfContent(ctx context.Context, searches, servers, options [
 	return content, nil
 }
 
+// ValidateDNSDomain returns an error if `domain` is not a valid DNS domain name,
+// such as "svc.cluster.local". A trailing dot is allowed.
+func ValidateDNSDomain(domain string) error {
+	name := strings.TrimSuffix(domain, ".")
+	if name == "" || len(name) > 253 {
+		return errors.Errorf("invalid DNS domain %q: must be 1 to 253 characters", domain)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return errors.Errorf("invalid DNS domain %q: labels must be 1 to 63 characters", domain)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return errors.Errorf("invalid DNS domain %q: labels cannot start or end with '-'", domain)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return errors.Errorf("invalid DNS domain %q: invalid character %q", domain, c)
+			}
+		}
+	}
+	return nil
+}
+
+// AddResolvConfSearch returns the resolv.conf file `content` with `domain`
+// appended to its search domains, if it is not already one of them.
+//
+// The search domains are set by the last "search" or "domain" line, as the
+// resolver does, and are written back as a single "search" line in place of the
+// first of those lines.
+func AddResolvConfSearch(content, domain string) (string, error) {
+	if err := ValidateDNSDomain(domain); err != nil {
+		return "", err
+	}
+
+	var (
+		lines    []string
+		searches []string
+		at       int
+		found    bool
+	)
+	if content != "" {
+		for _, l := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+			fields := strings.Fields(l)
+			if len(fields) > 0 && (fields[0] == "search" || fields[0] == "domain") {
+				searches = fields[1:]
+				if !found {
+					at, found = len(lines), true
+				}
+				continue
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	searches = MergeValues(searches, []string{domain})
+	if len(searches) > maxDNSSearches {
+		return "", errors.Errorf("searches has more than %d domains", maxDNSSearches)
+	}
+	lines = slices.Insert(lines, at, "search "+strings.Join(searches, " "))
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
 // MergeValues merges `first` and `second` maintaining order `first, second`.
 func MergeValues(first, second []string) []string {
 	if len(first) == 0 {
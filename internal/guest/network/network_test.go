@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 )
 
 func Test_GenerateResolvConfContent(t *testing.T) {
@@ -70,6 +72,93 @@ func Test_GenerateResolvConfContent(t *testing.T) {
 	}
 }
 
+func Test_AddResolvConfSearch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		content  string
+		domain   string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "Empty",
+			domain:   "svc.cluster.local",
+			expected: "search svc.cluster.local\n",
+		},
+		{
+			name:     "NoSearch",
+			content:  "nameserver 8.8.8.8\n",
+			domain:   "svc.cluster.local",
+			expected: "search svc.cluster.local\nnameserver 8.8.8.8\n",
+		},
+		{
+			name:     "Append",
+			content:  "search a.com b.com\nnameserver 8.8.8.8\n",
+			domain:   "svc.cluster.local",
+			expected: "search a.com b.com svc.cluster.local\nnameserver 8.8.8.8\n",
+		},
+		{
+			name:     "AlreadyPresent",
+			content:  "search a.com svc.cluster.local\nnameserver 8.8.8.8\n",
+			domain:   "svc.cluster.local",
+			expected: "search a.com svc.cluster.local\nnameserver 8.8.8.8\n",
+		},
+		{
+			name:     "LastSearchWins",
+			content:  "nameserver 8.8.8.8\nsearch a.com\ndomain b.com\noptions ndots:5\n",
+			domain:   "svc.cluster.local",
+			expected: "nameserver 8.8.8.8\nsearch b.com svc.cluster.local\noptions ndots:5\n",
+		},
+		{
+			name:     "TrailingDot",
+			content:  "search a.com\n",
+			domain:   "svc.cluster.local.",
+			expected: "search a.com svc.cluster.local.\n",
+		},
+		{
+			name:    "MaxSearches",
+			content: "search 1 2 3 4 5 6\n",
+			domain:  "svc.cluster.local",
+			err:     true,
+		},
+		{
+			name:   "Whitespace",
+			domain: "a.com b.com",
+			err:    true,
+		},
+		{
+			name:   "EmptyLabel",
+			domain: "a..com",
+			err:    true,
+		},
+		{
+			name:   "HyphenLabel",
+			domain: "-a.com",
+			err:    true,
+		},
+		{
+			name: "EmptyDomain",
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := AddResolvConfSearch(tc.content, tc.domain)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error, got content %q", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error got %v:", err)
+			}
+			if c != tc.expected {
+				t.Fatalf("expected content: %q got: %q", tc.expected, c)
+			}
+		})
+	}
+}
+
 func Test_MergeValues(t *testing.T) {
 	type testcase struct {
 		name string
@@ -170,6 +259,25 @@ ff02::2 ip6-allrouters
 	}
 }
 
+func Test_GenerateEtcHostsAliasesContent(t *testing.T) {
+	if c := GenerateEtcHostsAliasesContent(nil); c != "" {
+		t.Fatalf("expected empty content, got: %q", c)
+	}
+
+	expectedContent := `
+# Entries added by HostAliases.
+10.0.0.4	foo.local	bar.local
+fd00::4	baz
+`
+	c := GenerateEtcHostsAliasesContent([]guestresource.HostAlias{
+		{IP: "10.0.0.4", Hostnames: []string{"foo.local", "bar.local"}},
+		{IP: "fd00::4", Hostnames: []string{"baz"}},
+	})
+	if c != expectedContent {
+		t.Fatalf("expected content: %q got: %q", expectedContent, c)
+	}
+}
+
 // create a test os.DirEntry so we can return back a value to ReadDir
 type testDirEntry struct {
 	FileName    string
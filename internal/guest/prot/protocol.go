@@ -5,6 +5,7 @@ package prot
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 
 	v1 "github.com/containerd/cgroups/v3/cgroup1/stats"
@@ -12,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/commonutils"
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
@@ -107,6 +109,8 @@ const (
 	ComputeSystemDumpStacksV1 = 0x10100c01
 	// ComputeSystemDeleteContainerStateV1 is the delete container request.
 	ComputeSystemDeleteContainerStateV1 = 0x10100d01
+	// ComputeSystemPingV1 is the bridge liveness check request.
+	ComputeSystemPingV1 = 0x10101001
 
 	// ComputeSystemResponseCreateV1 is the create container response.
 	ComputeSystemResponseCreateV1 = 0x20100101
@@ -137,6 +141,8 @@ const (
 	ComputeSystemResponseNegotiateProtocolV1 = 0x20100b01
 	// ComputeSystemResponseDumpStacksV1 is the dump stack response
 	ComputeSystemResponseDumpStacksV1 = 0x20100c01
+	// ComputeSystemResponsePingV1 is the bridge liveness check response.
+	ComputeSystemResponsePingV1 = 0x20101001
 
 	// ComputeSystemNotificationV1 is the notification identifier.
 	ComputeSystemNotificationV1 = 0x30100101
@@ -173,6 +179,8 @@ func (mi MessageIdentifier) String() string {
 		return "ComputeSystemDumpStacksV1"
 	case ComputeSystemDeleteContainerStateV1:
 		return "ComputeSystemDeleteContainerStateV1"
+	case ComputeSystemPingV1:
+		return "ComputeSystemPingV1"
 	case ComputeSystemResponseCreateV1:
 		return "ComputeSystemResponseCreateV1"
 	case ComputeSystemResponseStartV1:
@@ -197,6 +205,8 @@ func (mi MessageIdentifier) String() string {
 		return "ComputeSystemResponseNegotiateProtocolV1"
 	case ComputeSystemResponseDumpStacksV1:
 		return "ComputeSystemResponseDumpStacksV1"
+	case ComputeSystemResponsePingV1:
+		return "ComputeSystemResponsePingV1"
 	case ComputeSystemNotificationV1:
 		return "ComputeSystemNotificationV1"
 	default:
@@ -258,13 +268,24 @@ type GcsCapabilities struct {
 	// ModifySettings request would be to configure the local and parent
 	// Hyper-V socket addresses of the VM, and would have a RequestType of
 	// Update.
-	HVSocketConfigOnStartup bool            `json:"HvSocketConfigOnStartup,omitempty"`
-	SupportedSchemaVersions []SchemaVersion `json:",omitempty"`
-	RuntimeOsType           OsType          `json:",omitempty"`
+	HVSocketConfigOnStartup bool `json:"HvSocketConfigOnStartup,omitempty"`
+	// SupportedSchemaVersions are encoded in the object format of
+	// [hcsschema.Version] that HCS and the host decode them from, rather than
+	// the string format of [SchemaVersion].
+	SupportedSchemaVersions []hcsschema.Version `json:",omitempty"`
+	RuntimeOsType           OsType              `json:",omitempty"`
 	// GuestDefinedCapabilities define any JSON object that will be directly
 	// passed to a client of the HCS. This can be useful to pass runtime
 	// specific capabilities not tied to the platform itself.
 	GuestDefinedCapabilities GcsGuestCapabilities `json:",omitempty"`
+	// MaxConcurrentProcesses is the maximum number of processes the GCS runs at
+	// once, beyond which requests to start a process fail with E_OUTOFMEMORY.
+	// Zero is unlimited.
+	MaxConcurrentProcesses uint32 `json:",omitempty"`
+	// SupportedResourceTypes are the resource types of the modify settings
+	// requests this GCS handles. The host fails requests for any other resource
+	// type with E_NOTIMPL, without sending them to the GCS.
+	SupportedResourceTypes []guestrequest.ResourceType `json:",omitempty"`
 }
 
 // GcsGuestCapabilities represents the customized guest capabilities supported
@@ -274,6 +295,20 @@ type GcsGuestCapabilities struct {
 	SignalProcessSupported        bool `json:",omitempty"`
 	DumpStacksSupported           bool `json:",omitempty"`
 	DeleteContainerStateSupported bool `json:",omitempty"`
+	// SeccompFilterActive is set if the GCS runs with a seccomp filter applied,
+	// which also applies to every process it launches.
+	SeccompFilterActive bool `json:",omitempty"`
+	// SeccompProfilesSupported is set if the seccomp profile in the spec of a
+	// container is applied to its init process and every process exec'd in it.
+	SeccompProfilesSupported bool `json:",omitempty"`
+	// ExternalProcessUserSupported is set if the User of a process created in
+	// the uVM, rather than in a container, is honored, as is
+	// ExecuteProcessSettings.DenyRootUser.
+	ExternalProcessUserSupported bool `json:",omitempty"`
+	// MultiContainerPropertiesSupported is set if the properties of several
+	// containers can be queried in one request, with
+	// [PropertyQuery.ContainerIDs].
+	MultiContainerPropertiesSupported bool `json:",omitempty"`
 }
 
 // ocspancontext is the internal JSON representation of the OpenCensus
@@ -321,6 +356,14 @@ type NegotiateProtocol struct {
 	MaximumVersion uint32
 }
 
+// Ping is the message from the HCS used to check that the bridge is alive.
+type Ping struct {
+	MessageBase
+	// Timestamp is an opaque value set by the HCS that is echoed back in the
+	// response.
+	Timestamp int64
+}
+
 // ContainerCreate is the message from the HCS specifying to create a container
 // in the utility VM. This message won't actually create a Linux container
 // inside the utility VM, but will set up the infrustructure needed to start one
@@ -329,6 +372,10 @@ type ContainerCreate struct {
 	MessageBase
 	ContainerConfig   string
 	SupportedVersions ProtocolSupport `json:",omitempty"`
+	// HostnameSuffix is the DNS domain of the container, such as the domain of
+	// its pod, which the GCS adds to the search domains of the container's
+	// /etc/resolv.conf, so that short names resolve within it.
+	HostnameSuffix string `json:",omitempty"`
 }
 
 // NotificationType defines a type of notification to be sent back to the HCS.
@@ -391,6 +438,28 @@ type ContainerNotification struct {
 	ResultInfo string `json:",omitempty"`
 }
 
+// ContainerExitResultInfo is sent as the JSON-encoded ResultInfo of a container
+// exit ContainerNotification when processes had to be killed after the
+// container's init process exited.
+type ContainerExitResultInfo struct {
+	// StragglerCount is the number of processes that were killed.
+	StragglerCount int
+	// Stragglers are the processes that were killed. It may be truncated to
+	// the first MaxReportedStragglers processes.
+	Stragglers []ContainerStraggler `json:",omitempty"`
+}
+
+// ContainerStraggler is a process that was still running in a container when
+// the grace period after its init process exited ended.
+type ContainerStraggler struct {
+	Pid     int
+	Command []string `json:",omitempty"`
+}
+
+// MaxReportedStragglers is the maximum number of processes listed in
+// ContainerExitResultInfo.Stragglers.
+const MaxReportedStragglers = 32
+
 // ExecuteProcessVsockStdioRelaySettings defines the port numbers for each
 // stdio socket for a process.
 type ExecuteProcessVsockStdioRelaySettings struct {
@@ -399,11 +468,31 @@ type ExecuteProcessVsockStdioRelaySettings struct {
 	StdErr uint32 `json:",omitempty"`
 }
 
+// ExecuteProcessStdioFileSettings defines a file that the stdout or stderr of a
+// process is written to instead of being relayed to the host.
+type ExecuteProcessStdioFileSettings struct {
+	// Path is the absolute path of the file in the container, or in the UVM
+	// for external processes.
+	Path string
+	// MaxSizeInBytes is the size past which the file is rotated. Zero
+	// disables rotation.
+	MaxSizeInBytes uint64 `json:",omitempty"`
+	// MaxBackups is the number of rotated files kept.
+	MaxBackups uint32 `json:",omitempty"`
+}
+
 // ExecuteProcessSettings defines the settings for a single process to be
 // executed either inside or outside the container namespace.
 type ExecuteProcessSettings struct {
 	ProcessParameters       string
 	VsockStdioRelaySettings ExecuteProcessVsockStdioRelaySettings
+	// StdOutFile and StdErrFile redirect stdout and stderr to files in the
+	// guest, in which case no relay is connected for them.
+	StdOutFile *ExecuteProcessStdioFileSettings `json:",omitempty"`
+	StdErrFile *ExecuteProcessStdioFileSettings `json:",omitempty"`
+	// DenyRootUser fails to create a process in the uVM, rather than in a
+	// container, that would run as root.
+	DenyRootUser bool `json:",omitempty"`
 }
 
 // ContainerExecuteProcess is the message from the HCS specifying to execute a
@@ -594,8 +683,14 @@ func UnmarshalContainerModifySettings(b []byte) (*containerModifySettings, error
 			return &request, errors.Wrap(err, "failed to unmarshal settings as SecurityPolicyFragment")
 		}
 		msr.Settings = fragment
+	case guestresource.ResourceTypeHostAliases:
+		ha := &guestresource.LCOWHostAliases{}
+		if err := commonutils.UnmarshalJSONWithHresult(msrRawSettings, ha); err != nil {
+			return &request, errors.Wrap(err, "failed to unmarshal settings as LCOWHostAliases")
+		}
+		msr.Settings = ha
 	default:
-		return &request, errors.Errorf("invalid ResourceType '%s'", msr.ResourceType)
+		return &request, gcserr.WrapHresult(errors.Errorf("invalid ResourceType '%s'", msr.ResourceType), gcserr.HrNotImpl)
 	}
 	request.Request = &msr
 	return &request, nil
@@ -608,6 +703,9 @@ type MessageResponseBase struct {
 	ActivityID   string                    `json:"ActivityId,omitempty"`
 	ErrorMessage string                    `json:",omitempty"` // Only used by hcsshim external bridge
 	ErrorRecords []commonutils.ErrorRecord `json:",omitempty"`
+	// Warnings are caveats of a request that succeeded, such as a requested
+	// value being adjusted to one the guest supports.
+	Warnings []string `json:",omitempty"`
 }
 
 // Base returns the response base by reference.
@@ -622,6 +720,11 @@ type NegotiateProtocolResponse struct {
 	MessageResponseBase
 	Version      uint32
 	Capabilities GcsCapabilities
+	// GcsRunTime is how long, in nanoseconds, the GCS has been running for. It
+	// is measured with the monotonic clock, since the wall clock of the uVM may
+	// not be synchronized with the host's yet, and the host converts it to the
+	// time the GCS started using its own clock.
+	GcsRunTime int64 `json:",omitempty"`
 }
 
 type DumpStacksResponse struct {
@@ -629,6 +732,12 @@ type DumpStacksResponse struct {
 	GuestStacks string
 }
 
+// PingResponse is the message to the HCS responding to a Ping request.
+type PingResponse struct {
+	MessageResponseBase
+	Timestamp int64
+}
+
 // ContainerCreateResponse is the message to the HCS responding to a
 // ContainerCreate message. It serves a protocol negotiation function as well
 // for protocol versions 3 and lower, returning protocol version information to
@@ -650,7 +759,17 @@ type ContainerExecuteProcessResponse struct {
 // ContainerWaitForProcess message. It is only sent when the process has exited.
 type ContainerWaitForProcessResponse struct {
 	MessageResponseBase
+	// ExitCode is the exit code of the process. If the process was terminated
+	// by a signal, it is 128 plus the signal number.
 	ExitCode uint32
+	// Signal is set if the process was terminated by a signal.
+	Signal *ProcessExitSignal `json:",omitempty"`
+}
+
+// ProcessExitSignal describes the signal that terminated a process.
+type ProcessExitSignal struct {
+	Signal     int32
+	CoreDumped bool `json:",omitempty"`
 }
 
 // ContainerGetPropertiesResponse is the message to the HCS responding to a
@@ -714,11 +833,38 @@ type VMHostedContainerSettings struct {
 }
 
 // SchemaVersion defines the version of the schema that should be deserialized.
+//
+// It is encoded in JSON as the string "Major.Minor", such as "2.1", and decoded
+// from either that string or the object {"Major":2,"Minor":1} that HCS and
+// older hosts send.
 type SchemaVersion struct {
 	Major uint32 `json:",omitempty"`
 	Minor uint32 `json:",omitempty"`
 }
 
+func (s SchemaVersion) String() string {
+	return fmt.Sprintf("%d.%d", s.Major, s.Minor)
+}
+
+func (s SchemaVersion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *SchemaVersion) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err == nil {
+		hv, err := hcsschema.ParseVersion(v)
+		if err != nil {
+			return err
+		}
+		*s = SchemaVersion{Major: uint32(hv.Major), Minor: uint32(hv.Minor)}
+		return nil
+	}
+	// the object format, with a distinct type to not recurse into this method
+	type schemaVersion SchemaVersion
+	return json.Unmarshal(b, (*schemaVersion)(s))
+}
+
 // Cmp compares s and v and returns:
 //
 //	-1 if s <  v
@@ -751,6 +897,14 @@ type VMHostedContainerSettingsV2 struct {
 	// VHD is mounted inside the UVM. But in case of scratch sharing this is a
 	// directory under the UVM scratch directory.
 	ScratchDirPath string
+	// ScratchQuotaBytes limits the disk space the container may use under
+	// ScratchDirPath. This is used to isolate containers that share the UVM
+	// scratch. Zero means no limit. A limit is only accepted when
+	// ScratchDirPath is the scratch of the container's root filesystem.
+	ScratchQuotaBytes uint64 `json:",omitempty"`
+	// HostAliases are additional entries for the /etc/hosts file of a pod.
+	// Only used for sandbox containers.
+	HostAliases []guestresource.HostAlias `json:",omitempty"`
 }
 
 // ProcessParameters represents any process which may be started in the utility
@@ -783,12 +937,39 @@ type ProcessParameters struct {
 	// useful if, for example, you want to start up a shell in the utility VM
 	// for debugging/diagnostic purposes.
 	IsExternal bool `json:"CreateInUtilityVM,omitempty"`
+	// User is the user to run an external process as, in the form user[:group]
+	// where each is a name or numeric ID resolved with the uVM's /etc/passwd
+	// and /etc/group. If empty, the process runs as root. Processes in a
+	// container use the user in OCIProcess instead.
+	User string `json:",omitempty"`
+	// DenyRootUser is set from ExecuteProcessSettings.DenyRootUser.
+	DenyRootUser bool `json:"-"`
 	// If this is the first process created for this container, this field must
 	// be specified. Otherwise, it must be left blank and the other fields must
 	// be specified.
 	OCISpecification *oci.Spec `json:"OciSpecification,omitempty"`
 
 	OCIProcess *oci.Process `json:"OciProcess,omitempty"`
+
+	// ExtraCapabilities are capabilities to grant an exec'd process in addition
+	// to those in OCIProcess, for example to run a privileged debugging tool
+	// in an unprivileged container. Capabilities outside of the container's
+	// bounding set are only granted if the container has CAP_SETPCAP.
+	ExtraCapabilities []string `json:",omitempty"`
+	// DropCapabilities are capabilities to remove from an exec'd process.
+	// They take precedence over ExtraCapabilities.
+	DropCapabilities []string `json:",omitempty"`
+	// ShellCandidates are the shells to try, in order, if OCIProcess is a
+	// terminal process that requests one of them and it does not exist in the
+	// container's rootfs. If set, an exec whose executable does not exist fails
+	// with the paths that were tried.
+	ShellCandidates []string `json:",omitempty"`
+	// WorkingDirectoryCreateIfMissing creates the working directory of the
+	// process, and any missing parents, if it does not exist, rather than
+	// failing to start the process. For a process in a container, this is
+	// OCIProcess.Cwd in the container's rootfs, or the cwd of the container's
+	// process in its spec if OCIProcess is not set.
+	WorkingDirectoryCreateIfMissing bool `json:",omitempty"`
 }
 
 // SignalProcessOptions represents the options for signaling a process.
@@ -804,6 +985,10 @@ type ProcessDetails struct {
 // PropertyQuery is a query to specify which properties are requested.
 type PropertyQuery struct {
 	PropertyTypes []PropertyType `json:",omitempty"`
+	// ContainerIDs, if set, queries the properties of each of these containers
+	// in a single request, sent for the UVM rather than a container. Requires
+	// [GcsGuestCapabilities.MultiContainerPropertiesSupported].
+	ContainerIDs []string `json:"LCOWContainerIds,omitempty"`
 }
 
 // Properties represents the properties of a compute system.
@@ -814,4 +999,20 @@ type Properties struct {
 type PropertiesV2 struct {
 	ProcessList []ProcessDetails `json:"ProcessList,omitempty"`
 	Metrics     *v1.Metrics      `json:"LCOWMetrics,omitempty"`
+	// ScratchUsageBytes is the disk space used by the container's scratch, if
+	// the container was created with a scratch quota.
+	ScratchUsageBytes uint64 `json:"LCOWScratchUsageBytes,omitempty"`
+	// Containers are the properties of the containers of a query with
+	// [PropertyQuery.ContainerIDs], by container ID.
+	Containers map[string]*PropertiesV2 `json:"LCOWContainers,omitempty"`
+	// ContainerErrors are the errors of the containers of a query with
+	// [PropertyQuery.ContainerIDs] whose properties could not be queried, by
+	// container ID.
+	ContainerErrors map[string]PropertiesError `json:"LCOWContainerErrors,omitempty"`
+}
+
+// PropertiesError is why the properties of a container could not be queried.
+type PropertiesError struct {
+	Result  int32  // HResult
+	Message string `json:",omitempty"`
 }
@@ -0,0 +1,133 @@
+package prot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+)
+
+// modifySettingsExamples are valid settings for each resource type handled by
+// [UnmarshalContainerModifySettings].
+var modifySettingsExamples = map[guestrequest.ResourceType]string{
+	guestresource.ResourceTypeSCSIDevice:           `{"Controller":1,"Lun":2}`,
+	guestresource.ResourceTypeMappedVirtualDisk:    `{"MountPath":"/run/mounts/scsi/m-1","Lun":1,"Controller":0,"ReadOnly":true,"Options":["noatime"]}`,
+	guestresource.ResourceTypeMappedDirectory:      `{"MountPath":"/run/mounts/plan9/m1","Port":50001,"ShareName":"share","ReadOnly":true}`,
+	guestresource.ResourceTypeVPMemDevice:          `{"DeviceNumber":1,"MountPath":"/run/layers/p1","MappingInfo":{"DeviceOffsetInBytes":4096,"DeviceSizeInBytes":8192}}`,
+	guestresource.ResourceTypeCombinedLayers:       `{"ContainerID":"c1","ContainerRootPath":"/run/gcs/c/c1/rootfs","Layers":[{"Id":"l1","Path":"/run/layers/p0"}],"ScratchPath":"/run/gcs/c/c1/scratch"}`,
+	guestresource.ResourceTypeNetwork:              `{"NamespaceID":"ns","ID":"eth0","MacAddress":"00-15-5D-00-00-01","IPConfigs":[{"IPAddress":"10.0.0.2","PrefixLength":24}],"Routes":[{"NextHop":"10.0.0.1","DestinationPrefix":"0.0.0.0/0"}]}`,
+	guestresource.ResourceTypeVPCIDevice:           `{"VMBusGUID":"6b1f8c8a-3b7d-4f0a-9a1e-1d2f3c4b5a69","Functions":[0,1]}`,
+	guestresource.ResourceTypeContainerConstraints: `{"Linux":{"CPU":{"Shares":1024,"Quota":50000},"Memory":{"Limit":1073741824}},"PinnedCPUs":"0-1"}`,
+	guestresource.ResourceTypeSecurityPolicy:       `{"EnforcerType":"rego","EncodedSecurityPolicy":"cGFja2FnZSBwb2xpY3k="}`,
+	guestresource.ResourceTypePolicyFragment:       `{"Fragment":"ZnJhZ21lbnQ="}`,
+	guestresource.ResourceTypeHostAliases:          `{"HostAliases":[{"IP":"10.0.0.5","Hostnames":["db","db.local"]}]}`,
+}
+
+func FuzzUnmarshalContainerModifySettings(f *testing.F) {
+	for rt, settings := range modifySettingsExamples {
+		for _, reqType := range []guestrequest.RequestType{guestrequest.RequestTypeAdd, guestrequest.RequestTypeRemove, guestrequest.RequestTypeUpdate} {
+			f.Add([]byte(`{"ContainerId":"c1","ActivityId":"a1","Request":{"ResourceType":"` + string(rt) +
+				`","RequestType":"` + string(reqType) + `","Settings":` + settings + `}}`))
+		}
+	}
+	f.Add([]byte(`{"ContainerId":"c1","Request":{"ResourceType":"Unknown","Settings":{}}}`))
+	f.Add([]byte(`{"ContainerId":"c1","Request":null}`))
+	f.Add([]byte(`{"ContainerId":"c1","Request":{"ResourceType":"Network","Settings":null}}`))
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		req, err := UnmarshalContainerModifySettings(b)
+		if err != nil {
+			// errors are returned to the HCS, which expects an HRESULT
+			if _, herr := gcserr.GetHresult(err); herr != nil {
+				t.Fatalf("error without an HRESULT: %v", err)
+			}
+			return
+		}
+		msr, ok := req.Request.(*guestrequest.ModificationRequest)
+		if !ok {
+			t.Fatalf("unexpected request type %T", req.Request)
+		}
+		if msr.RequestType == "" {
+			t.Fatal("expected the request type to default to add")
+		}
+		if _, err := json.Marshal(req); err != nil {
+			t.Fatalf("failed to marshal unmarshaled request: %v", err)
+		}
+	})
+}
+
+func TestUnmarshalContainerModifySettings_Examples(t *testing.T) {
+	for rt, settings := range modifySettingsExamples {
+		b := []byte(`{"ContainerId":"c1","Request":{"ResourceType":"` + string(rt) + `","Settings":` + settings + `}}`)
+		req, err := UnmarshalContainerModifySettings(b)
+		if err != nil {
+			t.Fatalf("failed to unmarshal %s settings: %v", rt, err)
+		}
+		msr := req.Request.(*guestrequest.ModificationRequest)
+		if msr.ResourceType != rt || msr.RequestType != guestrequest.RequestTypeAdd {
+			t.Fatalf("unexpected request %+v", msr)
+		}
+		if _, ok := msr.Settings.(*json.RawMessage); ok {
+			t.Fatalf("expected %s settings to be unmarshaled", rt)
+		}
+	}
+}
+
+func TestSchemaVersion_JSON(t *testing.T) {
+	b, err := json.Marshal(SchemaVersion{Major: 2, Minor: 1})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if string(b) != `"2.1"` {
+		t.Fatalf("expected schema version to be marshaled as %q, got %s", "2.1", b)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		json     string
+		expected SchemaVersion
+		valid    bool
+	}{
+		{name: "String", json: `"2.1"`, expected: SchemaVersion{Major: 2, Minor: 1}, valid: true},
+		{name: "Object", json: `{"Major":2,"Minor":1}`, expected: SchemaVersion{Major: 2, Minor: 1}, valid: true},
+		{name: "ObjectOmittedMinor", json: `{"Major":2}`, expected: SchemaVersion{Major: 2}, valid: true},
+		{name: "NoMinor", json: `"2"`},
+		{name: "Negative", json: `"-2.1"`},
+		{name: "Extra", json: `"2.1.0"`},
+		{name: "Number", json: `2.1`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v SchemaVersion
+			err := json.Unmarshal([]byte(tc.json), &v)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected %s to be invalid, got %v", tc.json, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to unmarshal %s: %v", tc.json, err)
+			}
+			if v != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestGcsCapabilities_SchemaVersionsObjects(t *testing.T) {
+	b, err := json.Marshal(GcsCapabilities{SupportedSchemaVersions: []hcsschema.Version{{Major: 2, Minor: 1}}})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	// the host decodes the supported versions as objects
+	if !strings.Contains(string(b), `"SupportedSchemaVersions":[{"Major":2,"Minor":1}]`) {
+		t.Fatalf("expected supported schema versions to be marshaled as objects, got %s", b)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -17,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"go.opencensus.io/trace"
+	"golang.org/x/sys/unix"
 
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/guest/prot"
@@ -24,6 +28,7 @@ import (
 	specGuest "github.com/Microsoft/hcsshim/internal/guest/spec"
 	"github.com/Microsoft/hcsshim/internal/guest/stdio"
 	"github.com/Microsoft/hcsshim/internal/guest/storage"
+	"github.com/Microsoft/hcsshim/internal/guest/storage/quota"
 	"github.com/Microsoft/hcsshim/internal/guest/transport"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/logfields"
@@ -31,6 +36,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
+	"github.com/Microsoft/hcsshim/pkg/securitypolicy"
 )
 
 // containerStatus has been introduced to enable parallel container creation
@@ -77,6 +83,33 @@ type Container struct {
 	// of this container is located. Usually, this is either `/run/gcs/c/<containerID>` or
 	// `/run/gcs/c/<UVMID>/container_<containerID>` if scratch is shared with UVM scratch.
 	scratchDirPath string
+	// scratchProjectID is the ext4 project ID used to limit the disk space used
+	// under scratchDirPath. Zero if the container has no scratch quota.
+	scratchProjectID uint32
+
+	// hostAliases are the additional entries in the hosts file shared by the
+	// containers in the sandbox, protected by hostsMu. Only used for sandbox
+	// containers.
+	hostsMu     sync.Mutex
+	hostAliases []guestresource.HostAlias
+}
+
+// runningProcesses returns the number of processes in the container, including
+// its init process, that have not exited.
+func (c *Container) runningProcesses() int {
+	n := 0
+	// initProcess is only safe to read once the container is created
+	if c.getStatus() == containerCreated && !c.initProcess.exited.Load() {
+		n++
+	}
+	c.processesMutex.Lock()
+	defer c.processesMutex.Unlock()
+	for _, p := range c.processes {
+		if !p.exited.Load() {
+			n++
+		}
+	}
+	return n
 }
 
 func (c *Container) Start(ctx context.Context, conSettings stdio.ConnectionSettings) (_ int, err error) {
@@ -132,6 +165,131 @@ func (c *Container) Start(ctx context.Context, conSettings stdio.ConnectionSetti
 	return int(c.initProcess.pid), err
 }
 
+// setExecUser sets the user that the exec `process` runs as in the container
+// described by `spec`.
+//
+// If the exec's spec provides a user string, it is resolved against the container's
+// filesystem, keeping any additional GIDs the exec asked for. If the exec's spec
+// does not set a user at all (neither a user string nor any uid, gid, or additional
+// gids) but the client provided a user for the container to run as, the exec
+// runs as the container's user. If the Username field is filled in on the container's
+// spec, at this point that means the work to find a uid:gid pairing for this username
+// has already been done, so simply assign the user from the container.
+// Otherwise, the uid, gid, and additional gids from the exec's spec are used as is.
+func setExecUser(spec *oci.Spec, process *oci.Process) error {
+	if process.User.Username != "" {
+		// The exec provided a user string of it's own. Grab the uid:gid pairing for the string (if one exists).
+		if err := specGuest.SetUserStr(&oci.Spec{Root: spec.Root, Process: process}, process.User.Username); err != nil {
+			return err
+		}
+		// Runc doesn't care about this, and just to be safe clear it.
+		process.User.Username = ""
+		return nil
+	}
+	if spec.Process != nil && spec.Process.User.Username != "" && isEmptyUser(process.User) {
+		process.User = spec.Process.User
+	}
+	return nil
+}
+
+// isEmptyUser returns if `u` does not specify a user.
+func isEmptyUser(u oci.User) bool {
+	return u.Username == "" && u.UID == 0 && u.GID == 0 && len(u.AdditionalGids) == 0
+}
+
+// applyExecCapabilities grants the capabilities in `extra` to, and removes the
+// capabilities in `drop` from, the exec'd process `process` of the container
+// with spec `spec`.
+//
+// An exec may only be granted capabilities outside of the container's bounding
+// set if the container itself has CAP_SETPCAP, and may only be granted
+// CAP_SETPCAP or CAP_SYS_ADMIN if the container is `privileged`; otherwise EPERM
+// is returned. If `process` does not specify capabilities, the container's are
+// used as the starting point.
+//
+// The user of `process` must already be resolved, since the capabilities of a
+// non-root user are granted differently.
+func applyExecCapabilities(spec *oci.Spec, process *oci.Process, privileged bool, extra, drop []string) error {
+	if len(extra) == 0 && len(drop) == 0 {
+		return nil
+	}
+
+	var containerCaps oci.LinuxCapabilities
+	if spec.Process != nil && spec.Process.Capabilities != nil {
+		containerCaps = *spec.Process.Capabilities
+	}
+	if process.Capabilities == nil {
+		caps := containerCaps
+		process.Capabilities = &caps
+	}
+	caps := process.Capabilities
+
+	extra, err := normalizeCapabilities(extra)
+	if err != nil {
+		return err
+	}
+	drop, err = normalizeCapabilities(drop)
+	if err != nil {
+		return err
+	}
+
+	canExceedBounding := slices.Contains(containerCaps.Effective, "CAP_SETPCAP")
+	for _, c := range extra {
+		if (c == "CAP_SETPCAP" || c == "CAP_SYS_ADMIN") && !privileged {
+			return gcserr.WrapHresult(
+				errors.Wrapf(unix.EPERM, "capability %s can only be granted in a privileged container", c),
+				gcserr.HrErrAccessDenied)
+		}
+		if !slices.Contains(containerCaps.Bounding, c) && !canExceedBounding {
+			return gcserr.WrapHresult(
+				errors.Wrapf(unix.EPERM, "capability %s is not in the container's bounding set", c),
+				gcserr.HrErrAccessDenied)
+		}
+	}
+
+	add := func(set []string) []string {
+		for _, c := range extra {
+			if !slices.Contains(set, c) {
+				set = append(set, c)
+			}
+		}
+		return slices.DeleteFunc(set, func(c string) bool { return slices.Contains(drop, c) })
+	}
+	remove := func(set []string) []string {
+		return slices.DeleteFunc(set, func(c string) bool { return slices.Contains(drop, c) })
+	}
+	caps.Bounding = add(slices.Clone(caps.Bounding))
+	caps.Effective = add(slices.Clone(caps.Effective))
+	caps.Permitted = add(slices.Clone(caps.Permitted))
+	caps.Inheritable = add(slices.Clone(caps.Inheritable))
+	// non-root users only keep their capabilities across exec through the
+	// ambient set
+	if process.User.UID != 0 {
+		caps.Ambient = add(slices.Clone(caps.Ambient))
+	} else {
+		caps.Ambient = remove(slices.Clone(caps.Ambient))
+	}
+	return nil
+}
+
+// normalizeCapabilities converts capability names to the `CAP_`-prefixed upper
+// case form used in OCI specs, and returns an error for unknown capabilities.
+func normalizeCapabilities(caps []string) ([]string, error) {
+	known := securitypolicy.DefaultPrivilegedCapabilities()
+	out := make([]string, 0, len(caps))
+	for _, c := range caps {
+		n := strings.ToUpper(c)
+		if !strings.HasPrefix(n, "CAP_") {
+			n = "CAP_" + n
+		}
+		if !slices.Contains(known, n) {
+			return nil, gcserr.WrapHresult(errors.Errorf("unknown capability %q", c), gcserr.HrErrInvalidArg)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
 func (c *Container) ExecProcess(ctx context.Context, process *oci.Process, conSettings stdio.ConnectionSettings) (int, error) {
 	log.G(ctx).WithField(logfields.ContainerID, c.id).Info("opengcs::Container::ExecProcess")
 	stdioSet, err := stdio.Connect(c.vsock, conSettings)
@@ -143,19 +301,9 @@ func (c *Container) ExecProcess(ctx context.Context, process *oci.Process, conSe
 	// core dumps.
 	process.Rlimits = c.spec.Process.Rlimits
 
-	// If the client provided a user for the container to run as, we want to have the exec run as this user as well
-	// unless the exec's spec was explicitly set to a different user. If the Username field is filled in on the containers
-	// spec, at this point that means the work to find a uid:gid pairing for this username has already been done, so simply
-	// assign the uid:gid from the container.
-	if process.User.Username != "" {
-		// The exec provided a user string of it's own. Grab the uid:gid pairing for the string (if one exists).
-		if err := specGuest.SetUserStr(&oci.Spec{Root: c.spec.Root, Process: process}, process.User.Username); err != nil {
-			return -1, err
-		}
-		// Runc doesn't care about this, and just to be safe clear it.
-		process.User.Username = ""
-	} else if c.spec.Process.User.Username != "" {
-		process.User = c.spec.Process.User
+	if err := setExecUser(c.spec, process); err != nil {
+		stdioSet.Close()
+		return -1, err
 	}
 
 	p, err := c.container.ExecProcess(process, stdioSet)
@@ -265,6 +413,13 @@ func (c *Container) Delete(ctx context.Context) error {
 		}
 	}
 
+	if c.scratchProjectID != 0 {
+		// the scratch directory is gone, so clear the limit from its parent
+		if err := quota.ClearProjectQuota(filepath.Dir(c.scratchDirPath), c.scratchProjectID); err != nil {
+			entity.WithError(err).Warn("failed to clear scratch quota")
+		}
+	}
+
 	if err := os.RemoveAll(c.ociBundlePath); err != nil {
 		if retErr != nil {
 			retErr = fmt.Errorf("errors deleting container oci bundle dir: %w; %w", retErr, err)
@@ -293,6 +448,26 @@ func (c *Container) Wait() prot.NotificationType {
 	return c.exitType
 }
 
+// ExitResultInfo returns the information to report with the container's exit
+// notification, or nil if there is none. It is only valid after Wait returns.
+func (c *Container) ExitResultInfo() *prot.ContainerExitResultInfo {
+	stragglers := c.container.Stragglers()
+	if len(stragglers) == 0 {
+		return nil
+	}
+	info := &prot.ContainerExitResultInfo{StragglerCount: len(stragglers)}
+	for _, p := range stragglers {
+		if len(info.Stragglers) == prot.MaxReportedStragglers {
+			break
+		}
+		info.Stragglers = append(info.Stragglers, prot.ContainerStraggler{
+			Pid:     p.Pid,
+			Command: p.Command,
+		})
+	}
+	return info
+}
+
 // setExitType sets `c.exitType` to the appropriate value based on `signal` if
 // `signal` will take down the container.
 func (c *Container) setExitType(signal syscall.Signal) {
@@ -322,8 +497,47 @@ func (c *Container) GetStats(ctx context.Context) (*v1.Metrics, error) {
 	return cg.Stat(cgroups.IgnoreNotExist)
 }
 
+// GetScratchUsage returns the disk space used by the container's scratch. It
+// returns zero if the container has no scratch quota.
+func (c *Container) GetScratchUsage(ctx context.Context) (uint64, error) {
+	_, span := oc.StartSpan(ctx, "opengcs::Container::GetScratchUsage")
+	defer span.End()
+	span.AddAttributes(trace.StringAttribute("cid", c.id))
+
+	if c.scratchProjectID == 0 {
+		return 0, nil
+	}
+	return quota.GetProjectUsage(c.scratchDirPath, c.scratchProjectID)
+}
+
 func (c *Container) modifyContainerConstraints(ctx context.Context, _ guestrequest.RequestType, cc *guestresource.LCOWContainerConstraints) (err error) {
-	return c.Update(ctx, cc.Linux)
+	resources := cc.Linux
+	if cc.PinnedCPUs != "" {
+		cpus, mems, err := pinCPUs(cc.PinnedCPUs)
+		if err != nil {
+			return errors.Wrap(err, "invalid pinned CPUs")
+		}
+		cpu := oci.LinuxCPU{}
+		if resources.CPU != nil {
+			cpu = *resources.CPU
+		}
+		cpu.Cpus = cpus
+		if cpu.Mems == "" {
+			cpu.Mems = mems
+		}
+		resources.CPU = &cpu
+	}
+	if cc.IOWeight != 0 {
+		if err := setIOWeight(&resources, cc.IOWeight, cgroupsUnified()); err != nil {
+			return err
+		}
+	}
+	if cc.MemorySwappiness != nil {
+		if err := setSwappiness(&resources, *cc.MemorySwappiness, cgroupsUnified()); err != nil {
+			return err
+		}
+	}
+	return c.Update(ctx, resources)
 }
 
 func (c *Container) getStatus() containerStatus {
@@ -334,6 +548,19 @@ func (c *Container) setStatus(st containerStatus) {
 	c.status.Store(uint32(st))
 }
 
+// state returns the state of the container reported to the host: "creating"
+// until its runtime container and init process are created, then "created",
+// or "exited" once its init process has exited.
+func (c *Container) state() string {
+	if c.getStatus() == containerCreating {
+		return "creating"
+	}
+	if c.initProcess != nil && c.initProcess.exited.Load() {
+		return "exited"
+	}
+	return "created"
+}
+
 func (c *Container) ID() string {
 	return c.id
 }
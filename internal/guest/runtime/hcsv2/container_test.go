@@ -0,0 +1,351 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/guest/runtime"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+)
+
+func Test_setExecUser(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatalf("failed to create /etc: %v", err)
+	}
+	for f, c := range map[string]string{
+		"passwd": "root:x:0:0:root:/root:/bin/sh\napp:x:1000:1000:app:/home/app:/bin/sh\n",
+		"group":  "root:x:0:\napp:x:1000:\nstaff:x:50:\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, "etc", f), []byte(c), 0644); err != nil {
+			t.Fatalf("failed to write /etc/%s: %v", f, err)
+		}
+	}
+
+	containerUser := oci.User{UID: 1000, GID: 1000, AdditionalGids: []uint32{50}, Username: "app"}
+	for _, tc := range []struct {
+		name          string
+		containerUser oci.User
+		execUser      oci.User
+		expected      oci.User
+	}{
+		{
+			name:          "InheritContainerUser",
+			containerUser: containerUser,
+			expected:      containerUser,
+		},
+		{
+			name:          "ExecUsername",
+			containerUser: containerUser,
+			execUser:      oci.User{Username: "root"},
+			expected:      oci.User{UID: 0, GID: 0},
+		},
+		{
+			name:          "ExecUsernameAndGroup",
+			containerUser: containerUser,
+			execUser:      oci.User{Username: "app:staff", AdditionalGids: []uint32{0}},
+			expected:      oci.User{UID: 1000, GID: 50, AdditionalGids: []uint32{0}},
+		},
+		{
+			name:          "ExecUIDAndGID",
+			containerUser: containerUser,
+			execUser:      oci.User{UID: 1000, GID: 50},
+			expected:      oci.User{UID: 1000, GID: 50},
+		},
+		{
+			name:          "ExecAdditionalGids",
+			containerUser: containerUser,
+			execUser:      oci.User{AdditionalGids: []uint32{50}},
+			expected:      oci.User{AdditionalGids: []uint32{50}},
+		},
+		{
+			name:     "NoContainerUsername",
+			execUser: oci.User{UID: 1000, GID: 1000},
+			expected: oci.User{UID: 1000, GID: 1000},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &oci.Spec{
+				Root:    &oci.Root{Path: root},
+				Process: &oci.Process{User: tc.containerUser},
+			}
+			process := &oci.Process{User: tc.execUser}
+			if err := setExecUser(spec, process); err != nil {
+				t.Fatalf("failed to set exec user: %v", err)
+			}
+			if !reflect.DeepEqual(process.User, tc.expected) {
+				t.Fatalf("expected user %+v, got %+v", tc.expected, process.User)
+			}
+		})
+	}
+}
+
+func Test_setExecUser_UnknownUser(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatalf("failed to create /etc: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("root:x:0:0:root:/root:/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("failed to write /etc/passwd: %v", err)
+	}
+
+	spec := &oci.Spec{
+		Root:    &oci.Root{Path: root},
+		Process: &oci.Process{},
+	}
+	process := &oci.Process{User: oci.User{Username: "nobody"}}
+	if err := setExecUser(spec, process); err == nil {
+		t.Fatal("expected error for user that does not exist in the container")
+	}
+}
+
+// stragglerContainer is a [runtime.Container] that only reports stragglers.
+type stragglerContainer struct {
+	runtime.Container
+	stragglers []runtime.ContainerProcessState
+}
+
+func (c *stragglerContainer) Stragglers() []runtime.ContainerProcessState {
+	return c.stragglers
+}
+
+func TestContainer_ExitResultInfo(t *testing.T) {
+	c := &Container{container: &stragglerContainer{}}
+	if info := c.ExitResultInfo(); info != nil {
+		t.Fatalf("expected no exit result info, got %+v", info)
+	}
+
+	var stragglers []runtime.ContainerProcessState
+	for i := 0; i < prot.MaxReportedStragglers+1; i++ {
+		stragglers = append(stragglers, runtime.ContainerProcessState{
+			Pid:     100 + i,
+			Command: []string{"sleep", "infinity"},
+		})
+	}
+	c = &Container{container: &stragglerContainer{stragglers: stragglers}}
+	info := c.ExitResultInfo()
+	if info == nil {
+		t.Fatal("expected exit result info")
+	}
+	if info.StragglerCount != len(stragglers) {
+		t.Fatalf("expected straggler count %d, got %d", len(stragglers), info.StragglerCount)
+	}
+	if len(info.Stragglers) != prot.MaxReportedStragglers {
+		t.Fatalf("expected %d stragglers, got %d", prot.MaxReportedStragglers, len(info.Stragglers))
+	}
+	expected := prot.ContainerStraggler{Pid: 100, Command: []string{"sleep", "infinity"}}
+	if !reflect.DeepEqual(info.Stragglers[0], expected) {
+		t.Fatalf("expected straggler %+v, got %+v", expected, info.Stragglers[0])
+	}
+}
+
+func Test_applyExecCapabilities(t *testing.T) {
+	containerCaps := &oci.LinuxCapabilities{
+		Bounding:  []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+		Effective: []string{"CAP_CHOWN", "CAP_KILL"},
+		Permitted: []string{"CAP_CHOWN", "CAP_KILL"},
+	}
+	setpcapCaps := &oci.LinuxCapabilities{
+		Bounding:  []string{"CAP_CHOWN", "CAP_SETPCAP"},
+		Effective: []string{"CAP_CHOWN", "CAP_SETPCAP"},
+		Permitted: []string{"CAP_CHOWN", "CAP_SETPCAP"},
+	}
+
+	for _, tc := range []struct {
+		name          string
+		containerCaps *oci.LinuxCapabilities
+		user          oci.User
+		privileged    bool
+		extra         []string
+		drop          []string
+		expected      *oci.LinuxCapabilities
+	}{
+		{
+			name:          "NoChanges",
+			containerCaps: containerCaps,
+			expected:      nil,
+		},
+		{
+			name:          "ExtraInBoundingSet",
+			containerCaps: containerCaps,
+			extra:         []string{"net_raw"},
+			expected: &oci.LinuxCapabilities{
+				Bounding:    []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+				Effective:   []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+				Permitted:   []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+				Inheritable: []string{"CAP_NET_RAW"},
+			},
+		},
+		{
+			name:          "ExtraNonRoot",
+			containerCaps: containerCaps,
+			user:          oci.User{UID: 1000},
+			extra:         []string{"CAP_NET_RAW"},
+			expected: &oci.LinuxCapabilities{
+				Bounding:    []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+				Effective:   []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+				Permitted:   []string{"CAP_CHOWN", "CAP_KILL", "CAP_NET_RAW"},
+				Inheritable: []string{"CAP_NET_RAW"},
+				Ambient:     []string{"CAP_NET_RAW"},
+			},
+		},
+		{
+			name:          "Drop",
+			containerCaps: containerCaps,
+			drop:          []string{"CAP_KILL"},
+			expected: &oci.LinuxCapabilities{
+				Bounding:  []string{"CAP_CHOWN", "CAP_NET_RAW"},
+				Effective: []string{"CAP_CHOWN"},
+				Permitted: []string{"CAP_CHOWN"},
+			},
+		},
+		{
+			name:          "DropOverridesExtra",
+			containerCaps: containerCaps,
+			extra:         []string{"CAP_NET_RAW"},
+			drop:          []string{"CAP_NET_RAW"},
+			expected: &oci.LinuxCapabilities{
+				Bounding:    []string{"CAP_CHOWN", "CAP_KILL"},
+				Effective:   []string{"CAP_CHOWN", "CAP_KILL"},
+				Permitted:   []string{"CAP_CHOWN", "CAP_KILL"},
+				Inheritable: []string{},
+			},
+		},
+		{
+			name:          "ExtraOutsideBoundingSetWithSETPCAP",
+			containerCaps: setpcapCaps,
+			privileged:    true,
+			extra:         []string{"CAP_SYS_ADMIN"},
+			expected: &oci.LinuxCapabilities{
+				Bounding:    []string{"CAP_CHOWN", "CAP_SETPCAP", "CAP_SYS_ADMIN"},
+				Effective:   []string{"CAP_CHOWN", "CAP_SETPCAP", "CAP_SYS_ADMIN"},
+				Permitted:   []string{"CAP_CHOWN", "CAP_SETPCAP", "CAP_SYS_ADMIN"},
+				Inheritable: []string{"CAP_SYS_ADMIN"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &oci.Spec{Process: &oci.Process{Capabilities: tc.containerCaps}}
+			process := &oci.Process{User: tc.user}
+			if err := applyExecCapabilities(spec, process, tc.privileged, tc.extra, tc.drop); err != nil {
+				t.Fatalf("failed to apply exec capabilities: %v", err)
+			}
+			if tc.expected == nil {
+				if process.Capabilities != nil {
+					t.Fatalf("expected no capabilities, got %+v", process.Capabilities)
+				}
+				return
+			}
+			if !reflect.DeepEqual(process.Capabilities, tc.expected) {
+				t.Fatalf("expected capabilities %+v, got %+v", tc.expected, process.Capabilities)
+			}
+			// the container's capabilities must not be modified
+			if spec.Process.Capabilities != tc.containerCaps || len(tc.containerCaps.Effective) != 2 {
+				t.Fatalf("container capabilities were modified: %+v", spec.Process.Capabilities)
+			}
+		})
+	}
+}
+
+func Test_applyExecCapabilities_Denied(t *testing.T) {
+	spec := &oci.Spec{Process: &oci.Process{Capabilities: &oci.LinuxCapabilities{
+		Bounding:  []string{"CAP_CHOWN"},
+		Effective: []string{"CAP_CHOWN"},
+	}}}
+
+	err := applyExecCapabilities(spec, &oci.Process{}, true, []string{"CAP_SYS_ADMIN"}, nil)
+	if errors.Cause(err) != unix.EPERM { //nolint:errorlint // gcserr only supports Cause
+		t.Fatalf("expected EPERM, got %v", err)
+	}
+
+	// CAP_SETPCAP lets an exec exceed the bounding set, but only a privileged
+	// container may grant CAP_SETPCAP or CAP_SYS_ADMIN
+	setpcap := &oci.Spec{Process: &oci.Process{Capabilities: &oci.LinuxCapabilities{
+		Bounding:  []string{"CAP_SETPCAP", "CAP_SYS_ADMIN"},
+		Effective: []string{"CAP_SETPCAP"},
+	}}}
+	for _, c := range []string{"CAP_SYS_ADMIN", "CAP_SETPCAP"} {
+		err = applyExecCapabilities(setpcap, &oci.Process{}, false, []string{c}, nil)
+		if errors.Cause(err) != unix.EPERM { //nolint:errorlint // gcserr only supports Cause
+			t.Fatalf("expected EPERM for %s in an unprivileged container, got %v", c, err)
+		}
+	}
+
+	err = applyExecCapabilities(spec, &oci.Process{}, false, []string{"CAP_NOT_A_CAPABILITY"}, nil)
+	if err == nil {
+		t.Fatal("expected error for unknown capability")
+	}
+}
+
+func Test_AddContainer_Exists(t *testing.T) {
+	h := &Host{containers: make(map[string]*Container)}
+	c := &Container{id: "c1", initProcess: &containerProcess{}}
+	c.setStatus(containerCreating)
+	if err := h.AddContainer(c.id, c); err != nil {
+		t.Fatalf("failed to add container: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		update func()
+		state  string
+	}{
+		{name: "Creating", update: func() {}, state: "creating"},
+		{name: "Created", update: func() { c.setStatus(containerCreated) }, state: "created"},
+		{name: "Exited", update: func() { c.initProcess.exited.Store(true) }, state: "exited"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.update()
+			err := h.AddContainer(c.id, &Container{id: c.id})
+			var existsErr *ContainerExistsError
+			if !errors.As(err, &existsErr) {
+				t.Fatalf("expected a ContainerExistsError, got: %v", err)
+			}
+			if existsErr.ID != c.id || existsErr.State != tc.state {
+				t.Fatalf("expected container %s in state %q, got: %+v", c.id, tc.state, existsErr)
+			}
+			if hr, herr := gcserr.GetHresult(err); herr != nil || hr != gcserr.HrVmcomputeSystemAlreadyExists {
+				t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrVmcomputeSystemAlreadyExists, hr, herr)
+			}
+		})
+	}
+	if h.containers[c.id] != c {
+		t.Fatal("the existing container should not have been replaced")
+	}
+}
+
+func Test_ModifySettings_UnsupportedResourceType(t *testing.T) {
+	h := &Host{containers: make(map[string]*Container)}
+	c := &Container{id: "c1", initProcess: &containerProcess{}}
+	c.setStatus(containerCreated)
+	if err := h.AddContainer(c.id, c); err != nil {
+		t.Fatalf("failed to add container: %v", err)
+	}
+
+	for _, id := range []string{UVMContainerID, c.id} {
+		err := h.ModifySettings(context.Background(), id, &guestrequest.ModificationRequest{
+			ResourceType: "NotAResourceType",
+			RequestType:  guestrequest.RequestTypeAdd,
+		})
+		if hr, hrErr := gcserr.GetHresult(err); hrErr != nil || hr != gcserr.HrNotImpl {
+			t.Fatalf("expected E_NOTIMPL modifying %s, got: %v", id, err)
+		}
+	}
+	if !slices.Contains(SupportedResourceTypes(), guestresource.ResourceTypeHostAliases) {
+		t.Fatalf("expected container resource types to be supported, got %v", SupportedResourceTypes())
+	}
+}
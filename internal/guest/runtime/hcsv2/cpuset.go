@@ -0,0 +1,109 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sysCPUPath is where the guest's CPUs and their NUMA nodes are exposed.
+const sysCPUPath = "/sys/devices/system/cpu"
+
+// parseCPUSet parses `s` in the Linux cpuset list format (for example
+// "0-3,7") and returns the sorted CPUs it lists. Every CPU must be less than
+// `cpuCount`.
+func parseCPUSet(s string, cpuCount int) ([]int, error) {
+	var cpus []int
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 0 {
+			return nil, errors.Errorf("invalid cpu %q in cpuset %q", lo, s)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(hi)
+			if err != nil || last < first {
+				return nil, errors.Errorf("invalid cpu range %q in cpuset %q", r, s)
+			}
+		}
+		if last >= cpuCount {
+			return nil, errors.Errorf("cpu %d in cpuset %q exceeds the %d CPUs of the uvm", last, s, cpuCount)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	slices.Sort(cpus)
+	return slices.Compact(cpus), nil
+}
+
+// formatCPUSet returns `ids`, which must be sorted and unique, in the Linux
+// cpuset list format.
+func formatCPUSet(ids []int) string {
+	var b strings.Builder
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(ids[i]))
+		if j > i {
+			b.WriteByte('-')
+			b.WriteString(strconv.Itoa(ids[j]))
+		}
+		i = j + 1
+	}
+	return b.String()
+}
+
+// cpuNUMANodes returns the NUMA nodes of `cpus` as read from `root`, which is
+// normally sysCPUPath. CPUs that report no node are on node 0.
+func cpuNUMANodes(root string, cpus []int) ([]int, error) {
+	var nodes []int
+	for _, cpu := range cpus {
+		entries, err := os.ReadDir(filepath.Join(root, "cpu"+strconv.Itoa(cpu)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read cpu %d", cpu)
+		}
+		node := 0
+		for _, e := range entries {
+			if n, ok := strings.CutPrefix(e.Name(), "node"); ok {
+				if node, err = strconv.Atoi(n); err != nil {
+					return nil, errors.Wrapf(err, "invalid numa node %q of cpu %d", e.Name(), cpu)
+				}
+				break
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	return slices.Compact(nodes), nil
+}
+
+// pinCPUs validates `pinnedCPUs` against the CPUs of the uvm and returns the
+// cpuset CPUs and memory nodes to set for it. The memory nodes are those of the
+// pinned CPUs, so the container does not fault in pages from another NUMA node.
+func pinCPUs(pinnedCPUs string) (cpus, mems string, err error) {
+	ids, err := parseCPUSet(pinnedCPUs, runtime.NumCPU())
+	if err != nil {
+		return "", "", err
+	}
+	nodes, err := cpuNUMANodes(sysCPUPath, ids)
+	if err != nil {
+		return "", "", err
+	}
+	return formatCPUSet(ids), formatCPUSet(nodes), nil
+}
@@ -0,0 +1,90 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func Test_parseCPUSet(t *testing.T) {
+	for _, tc := range []struct {
+		s       string
+		want    []int
+		wantErr bool
+	}{
+		{s: "0", want: []int{0}},
+		{s: "0-3,7", want: []int{0, 1, 2, 3, 7}},
+		{s: "7, 2-3,3", want: []int{2, 3, 7}},
+		{s: "8", wantErr: true},
+		{s: "6-8", wantErr: true},
+		{s: "3-1", wantErr: true},
+		{s: "-1", wantErr: true},
+		{s: "a", wantErr: true},
+		{s: "", wantErr: true},
+		{s: "0,", wantErr: true},
+	} {
+		t.Run(tc.s, func(t *testing.T) {
+			got, err := parseCPUSet(tc.s, 8)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			if s := formatCPUSet(got); s != formatCPUSet(tc.want) {
+				t.Fatalf("expected %q, got %q", formatCPUSet(tc.want), s)
+			}
+		})
+	}
+
+	if s := formatCPUSet([]int{0, 1, 2, 3, 7, 9, 10}); s != "0-3,7,9-10" {
+		t.Fatalf("expected 0-3,7,9-10, got %q", s)
+	}
+}
+
+func Test_cpuNUMANodes(t *testing.T) {
+	root := t.TempDir()
+	for cpu, node := range map[string]string{"cpu0": "node0", "cpu1": "node1", "cpu2": "node1", "cpu3": ""} {
+		p := filepath.Join(root, cpu)
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if node != "" {
+			if err := os.Mkdir(filepath.Join(p, node), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		cpus []int
+		want []int
+	}{
+		{cpus: []int{0}, want: []int{0}},
+		{cpus: []int{1, 2}, want: []int{1}},
+		{cpus: []int{0, 1, 2, 3}, want: []int{0, 1}},
+		{cpus: []int{3}, want: []int{0}},
+	} {
+		got, err := cpuNUMANodes(root, tc.cpus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Fatalf("expected nodes %v for cpus %v, got %v", tc.want, tc.cpus, got)
+		}
+	}
+
+	if _, err := cpuNUMANodes(root, []int{4}); err == nil {
+		t.Fatal("expected error for missing cpu")
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guestpath"
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+// resolveExecutable checks that the executable of `process` exists in the
+// container rootfs at `rootfs`, so that a missing executable is reported with
+// [gcserr.HrErrFileNotFound] and the paths that were tried, rather than as a
+// generic exec failure.
+//
+// If the executable does not exist and `process` is a terminal process that
+// requests one of `shells`, either by path or by name, the first of `shells`
+// that exists is run instead.
+func resolveExecutable(ctx context.Context, rootfs string, process *oci.Process, shells []string) error {
+	if len(process.Args) == 0 {
+		// leave it to runc to reject the process
+		return nil
+	}
+	name := process.Args[0]
+	pathEnv, ok := envValue(process.Env, "PATH")
+	if !ok {
+		pathEnv = guestpath.LCOWDefaultPathEnv
+	}
+
+	found, tried := lookupExecutable(rootfs, name, process.Cwd, pathEnv)
+	if found {
+		return nil
+	}
+	if process.Terminal && isShell(name, shells) {
+		for _, sh := range shells {
+			if sh == name {
+				continue
+			}
+			ok, t := lookupExecutable(rootfs, sh, process.Cwd, pathEnv)
+			for _, p := range t {
+				if !slices.Contains(tried, p) {
+					tried = append(tried, p)
+				}
+			}
+			if ok {
+				log.G(ctx).WithFields(logrus.Fields{
+					"requested": name,
+					"shell":     sh,
+				}).Info("requested shell not found, using fallback shell")
+				process.Args[0] = sh
+				return nil
+			}
+		}
+	}
+	return gcserr.WrapHresult(
+		errors.Errorf("executable %q not found in container, tried: %s", name, strings.Join(tried, ", ")),
+		gcserr.HrErrFileNotFound)
+}
+
+// lookupExecutable searches the rootfs at `rootfs` for the executable `name`,
+// in the same way as exec.LookPath, and returns if it was found and the paths,
+// relative to the rootfs, that were tried.
+//
+// Paths that cannot be checked are assumed to exist.
+func lookupExecutable(rootfs, name, cwd, pathEnv string) (bool, []string) {
+	if cwd == "" {
+		cwd = "/"
+	}
+	var candidates []string
+	if strings.Contains(name, "/") {
+		candidates = append(candidates, name)
+	} else {
+		for _, dir := range filepath.SplitList(pathEnv) {
+			if dir == "" {
+				dir = "."
+			}
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+
+	var tried []string
+	for _, c := range candidates {
+		if !filepath.IsAbs(c) {
+			c = filepath.Join(cwd, c)
+		}
+		tried = append(tried, c)
+		p, err := securejoin.SecureJoin(rootfs, c)
+		if err != nil {
+			return true, tried
+		}
+		fi, err := os.Stat(p)
+		if err == nil && !fi.IsDir() || err != nil && !os.IsNotExist(err) {
+			return true, tried
+		}
+	}
+	return false, tried
+}
+
+// isShell returns if `name` is one of `shells`, or the name of one of them.
+func isShell(name string, shells []string) bool {
+	if strings.Contains(name, "/") {
+		return slices.Contains(shells, name)
+	}
+	return slices.ContainsFunc(shells, func(sh string) bool {
+		return filepath.Base(sh) == name
+	})
+}
+
+// envValue returns the value of `key` in the environment `env`.
+func envValue(env []string, key string) (string, bool) {
+	for _, e := range env {
+		if k, v, ok := strings.Cut(e, "="); ok && k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,141 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+func newTestRootfs(t *testing.T) string {
+	t.Helper()
+	rootfs := t.TempDir()
+	for _, d := range []string{"usr/bin", "busybox", "work"} {
+		if err := os.MkdirAll(filepath.Join(rootfs, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"usr/bin/app", "busybox/sh", "work/tool"} {
+		if err := os.WriteFile(filepath.Join(rootfs, f), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// an absolute link must be resolved within the rootfs, not the uvm's root
+	if err := os.Symlink("/usr/bin", filepath.Join(rootfs, "bin")); err != nil {
+		t.Fatal(err)
+	}
+	return rootfs
+}
+
+func TestResolveExecutable(t *testing.T) {
+	ctx := context.Background()
+	rootfs := newTestRootfs(t)
+	shells := []string{"/bin/sh", "/bin/bash", "/busybox/sh"}
+
+	for _, tc := range []struct {
+		name     string
+		process  oci.Process
+		expected string
+	}{
+		{
+			name:     "absolute",
+			process:  oci.Process{Args: []string{"/bin/app"}},
+			expected: "/bin/app",
+		},
+		{
+			name:     "default path",
+			process:  oci.Process{Args: []string{"app"}},
+			expected: "app",
+		},
+		{
+			name:     "relative to cwd",
+			process:  oci.Process{Args: []string{"./tool"}, Cwd: "/work"},
+			expected: "./tool",
+		},
+		{
+			name:     "path env",
+			process:  oci.Process{Args: []string{"tool"}, Env: []string{"PATH=/work"}},
+			expected: "tool",
+		},
+		{
+			name:     "fallback shell",
+			process:  oci.Process{Args: []string{"sh"}, Terminal: true},
+			expected: "/busybox/sh",
+		},
+		{
+			name:     "fallback shell by path",
+			process:  oci.Process{Args: []string{"/bin/bash", "-l"}, Terminal: true},
+			expected: "/busybox/sh",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.process
+			if err := resolveExecutable(ctx, rootfs, &p, shells); err != nil {
+				t.Fatalf("failed to resolve executable: %v", err)
+			}
+			if p.Args[0] != tc.expected {
+				t.Fatalf("expected executable %q, got %q", tc.expected, p.Args[0])
+			}
+		})
+	}
+}
+
+func TestResolveExecutable_NotFound(t *testing.T) {
+	ctx := context.Background()
+	rootfs := newTestRootfs(t)
+
+	for _, tc := range []struct {
+		name    string
+		process oci.Process
+		shells  []string
+		tried   []string
+	}{
+		{
+			name:    "missing",
+			process: oci.Process{Args: []string{"missing"}, Env: []string{"PATH=/bin:/work"}},
+			tried:   []string{"/bin/missing", "/work/missing"},
+		},
+		{
+			name:    "directory",
+			process: oci.Process{Args: []string{"/work"}},
+			tried:   []string{"/work"},
+		},
+		{
+			name:    "shell without terminal",
+			process: oci.Process{Args: []string{"sh"}, Env: []string{"PATH=/bin"}},
+			shells:  []string{"/busybox/sh"},
+			tried:   []string{"/bin/sh"},
+		},
+		{
+			name:    "no fallback shell",
+			process: oci.Process{Args: []string{"sh"}, Env: []string{"PATH=/bin"}, Terminal: true},
+			shells:  []string{"/bin/sh", "/bin/bash"},
+			tried:   []string{"/bin/sh", "/bin/bash"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.process
+			err := resolveExecutable(ctx, rootfs, &p, tc.shells)
+			if err == nil {
+				t.Fatal("expected executable to not be found")
+			}
+			if hr, hErr := gcserr.GetHresult(err); hErr != nil || hr != gcserr.HrErrFileNotFound {
+				t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrErrFileNotFound, hr, err)
+			}
+			if !strings.HasSuffix(err.Error(), "tried: "+strings.Join(tc.tried, ", ")) {
+				t.Fatalf("expected error to list tried paths %v, got: %v", tc.tried, err)
+			}
+			if p.Args[0] != tc.process.Args[0] {
+				t.Fatalf("expected executable to be unchanged, got %q", p.Args[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+
+	"github.com/moby/sys/user"
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/spec"
+)
+
+// externalProcessCredential returns the credential to run an external process
+// as `userStr`, in the form user[:group], resolved with the /etc/passwd and
+// /etc/group files under `rootPath`. The supplementary groups of the GCS are
+// replaced by the groups in /etc/group that list the user as a member.
+//
+// A nil credential, for an empty `userStr`, runs the process as root, as the
+// GCS does. If `denyRoot` is set, a process that would run as root is denied.
+func externalProcessCredential(rootPath, userStr string, denyRoot bool) (*syscall.Credential, error) {
+	if userStr == "" {
+		if denyRoot {
+			return nil, gcserr.WrapHresult(errors.New("running an external process as root is denied"), gcserr.HrErrAccessDenied)
+		}
+		return nil, nil
+	}
+
+	res, err := spec.ParseUserStr(rootPath, userStr)
+	if err != nil {
+		return nil, gcserr.WrapHresult(errors.Wrapf(err, "failed to resolve external process user %q", userStr), gcserr.HrErrInvalidArg)
+	}
+	if res.UID == 0 && denyRoot {
+		return nil, gcserr.WrapHresult(errors.Errorf("running an external process as root user %q is denied", userStr), gcserr.HrErrAccessDenied)
+	}
+
+	// drop the groups of the GCS, even if the user is not in /etc/passwd
+	groups := []uint32{}
+	if res.Username != "" {
+		gs, err := user.ParseGroupFileFilter(filepath.Join(rootPath, "/etc/group"), func(g user.Group) bool {
+			return slices.Contains(g.List, res.Username)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to get supplementary groups of external process user %q", userStr)
+		}
+		for _, g := range gs {
+			if spec.OutOfUint32Bounds(g.Gid) {
+				return nil, errors.Errorf("GID (%d) exceeds uint32 bounds", g.Gid)
+			}
+			if uint32(g.Gid) != res.GID {
+				groups = append(groups, uint32(g.Gid))
+			}
+		}
+	}
+	return &syscall.Credential{
+		Uid:    res.UID,
+		Gid:    res.GID,
+		Groups: groups,
+	}, nil
+}
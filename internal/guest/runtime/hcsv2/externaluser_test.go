@@ -0,0 +1,105 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+func TestExternalProcessCredential(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	passwd := "root:x:0:0:root:/root:/bin/sh\n" +
+		"diag:x:1000:1000::/home/diag:/bin/sh\n" +
+		"toor:x:0:0::/root:/bin/sh\n"
+	group := "root:x:0:root\n" +
+		"diag:x:1000:diag\n" +
+		"adm:x:4:diag,root\n" +
+		"video:x:44:root\n"
+	if err := os.WriteFile(filepath.Join(root, "etc", "passwd"), []byte(passwd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "group"), []byte(group), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		user     string
+		denyRoot bool
+		want     *syscall.Credential
+		hr       gcserr.Hresult
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "default deny root",
+			denyRoot: true,
+			hr:       gcserr.HrErrAccessDenied,
+		},
+		{
+			name:     "username",
+			user:     "diag",
+			denyRoot: true,
+			want:     &syscall.Credential{Uid: 1000, Gid: 1000, Groups: []uint32{4}},
+		},
+		{
+			name: "uid and group",
+			user: "1000:video",
+			want: &syscall.Credential{Uid: 1000, Gid: 44, Groups: []uint32{1000, 4}},
+		},
+		{
+			name: "unknown uid",
+			user: "2000",
+			want: &syscall.Credential{Uid: 2000, Gid: 0, Groups: []uint32{}},
+		},
+		{
+			name: "unknown username",
+			user: "nobody",
+			hr:   gcserr.HrErrInvalidArg,
+		},
+		{
+			name: "root",
+			user: "root",
+			want: &syscall.Credential{Uid: 0, Gid: 0, Groups: []uint32{4, 44}},
+		},
+		{
+			name:     "root deny root",
+			user:     "0",
+			denyRoot: true,
+			hr:       gcserr.HrErrAccessDenied,
+		},
+		{
+			name:     "root alias deny root",
+			user:     "toor",
+			denyRoot: true,
+			hr:       gcserr.HrErrAccessDenied,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cred, err := externalProcessCredential(root, tc.user, tc.denyRoot)
+			if tc.hr != 0 {
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != tc.hr {
+					t.Fatalf("expected HRESULT %v, got %v: %v", tc.hr, hr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get credential: %v", err)
+			}
+			if !reflect.DeepEqual(cred, tc.want) {
+				t.Fatalf("expected credential %+v, got %+v", tc.want, cred)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"maps"
+	"strconv"
+
+	cgroupsv3 "github.com/containerd/cgroups/v3"
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+// The range of the cgroup v2 io.weight of a container.
+const (
+	minIOWeight = guestresource.MinIOWeight
+	maxIOWeight = guestresource.MaxIOWeight
+)
+
+// setIOWeight sets the proportional I/O weight of a container to `weight` in
+// `resources`. With cgroup v2 (`unified`), runc writes it to the io.weight file
+// of the container's cgroup. With cgroup v1, it is converted to a blkio weight,
+// from 10 to 1000, with the inverse of the conversion runc does the other way.
+func setIOWeight(resources *oci.LinuxResources, weight uint16, unified bool) error {
+	if weight < minIOWeight || weight > maxIOWeight {
+		return gcserr.WrapHresult(errors.Errorf("I/O weight %d is out of range [%d, %d]", weight, minIOWeight, maxIOWeight), gcserr.HrErrInvalidArg)
+	}
+
+	if unified {
+		setUnified(resources, "io.weight", strconv.Itoa(int(weight)))
+		return nil
+	}
+	blkio := oci.LinuxBlockIO{}
+	if resources.BlockIO != nil {
+		blkio = *resources.BlockIO
+	}
+	w := uint16(10 + (uint32(weight)-minIOWeight)*990/(maxIOWeight-minIOWeight))
+	blkio.Weight = &w
+	resources.BlockIO = &blkio
+	return nil
+}
+
+// cgroupsUnified returns if the uVM only has cgroup v2 mounted.
+func cgroupsUnified() bool {
+	return cgroupsv3.Mode() == cgroupsv3.Unified
+}
+
+// setUnified sets the cgroup v2 file `key` to `value` in the unified resources
+// of `resources`. The map is copied first, so that the map of the request the
+// resources were copied from is not modified.
+func setUnified(resources *oci.LinuxResources, key, value string) {
+	u := make(map[string]string, len(resources.Unified)+1)
+	maps.Copy(u, resources.Unified)
+	u[key] = value
+	resources.Unified = u
+}
+
+// applyIOWeightAnnotation sets the I/O weight requested by `spec` with the
+// [annotations.ContainerStorageIOWeight] annotation, if any, in the resources
+// of `spec`.
+func applyIOWeightAnnotation(spec *oci.Spec) error {
+	v, ok := spec.Annotations[annotations.ContainerStorageIOWeight]
+	if !ok {
+		return nil
+	}
+	weight, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		return gcserr.WrapHresult(errors.Wrapf(err, "invalid I/O weight %q", v), gcserr.HrErrInvalidArg)
+	}
+	if spec.Linux == nil {
+		spec.Linux = &oci.Linux{}
+	}
+	if spec.Linux.Resources == nil {
+		spec.Linux.Resources = &oci.LinuxResources{}
+	}
+	return setIOWeight(spec.Linux.Resources, uint16(weight), cgroupsUnified())
+}
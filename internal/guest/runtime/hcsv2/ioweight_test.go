@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+func TestSetIOWeight(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		weight    uint16
+		unified   bool
+		wantIO    string
+		wantBlkio uint16
+	}{
+		{name: "unified", weight: 1000, unified: true, wantIO: "1000"},
+		{name: "unified max", weight: 10000, unified: true, wantIO: "10000"},
+		{name: "blkio min", weight: 1, wantBlkio: 10},
+		{name: "blkio default", weight: 100, wantBlkio: 19},
+		{name: "blkio max", weight: 10000, wantBlkio: 1000},
+		{name: "zero", weight: 0, unified: true},
+		{name: "too large", weight: 10001},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := map[string]string{"memory.high": "max"}
+			resources := oci.LinuxResources{Unified: request}
+			err := setIOWeight(&resources, tc.weight, tc.unified)
+			if tc.wantIO == "" && tc.wantBlkio == 0 {
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != gcserr.HrErrInvalidArg {
+					t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrErrInvalidArg, hr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to set I/O weight: %v", err)
+			}
+			if got := resources.Unified["io.weight"]; got != tc.wantIO {
+				t.Fatalf("expected io.weight %q, got %q", tc.wantIO, got)
+			}
+			if _, ok := request["io.weight"]; ok {
+				t.Fatal("expected the unified resources of the request not to be modified")
+			}
+			if tc.wantBlkio == 0 {
+				if resources.BlockIO != nil {
+					t.Fatalf("expected no blkio resources, got %+v", resources.BlockIO)
+				}
+				return
+			}
+			if resources.BlockIO == nil || resources.BlockIO.Weight == nil || *resources.BlockIO.Weight != tc.wantBlkio {
+				t.Fatalf("expected blkio weight %d, got %+v", tc.wantBlkio, resources.BlockIO)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"strings"
+
+	"github.com/moby/sys/mountinfo"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+// propagationOptions are the OCI mount propagation options supported for container
+// bind mounts, mapped to whether the mount receives mounts created under its source.
+var propagationOptions = map[string]bool{
+	"private":  false,
+	"rprivate": false,
+	"slave":    true,
+	"rslave":   true,
+	"shared":   true,
+	"rshared":  true,
+}
+
+// mountPropagation returns the propagation option in `options`, or "" if there is
+// none. As with runc, the last propagation option wins.
+func mountPropagation(options []string) (p string) {
+	for _, o := range options {
+		if _, ok := propagationOptions[o]; ok {
+			p = o
+		}
+	}
+	return p
+}
+
+func isSharedPropagation(p string) bool {
+	return p == "shared" || p == "rshared"
+}
+
+func isBindMount(m specs.Mount) bool {
+	if m.Type == "bind" {
+		return true
+	}
+	for _, o := range m.Options {
+		if o == "bind" || o == "rbind" {
+			return true
+		}
+	}
+	return false
+}
+
+// setupMountPropagation validates the propagation of the bind mounts in `spec`, and
+// sets the propagation of the container's root filesystem so that it does not
+// prevent mounts from propagating to or from them, as the CRI plugin does.
+//
+// Shared propagation lets the container create mounts in the uVM, so it must be
+// allowed with the [annotations.LCOWAllowRSharedMounts] annotation.
+//
+// It returns the sources of the bind mounts that must be shared in the uVM for their
+// propagation to take effect.
+func setupMountPropagation(ctx context.Context, id string, spec *specs.Spec) ([]string, error) {
+	allowShared := oci.ParseAnnotationsBool(ctx, spec.Annotations, annotations.LCOWAllowRSharedMounts, false)
+
+	rootfs := ""
+	if spec.Linux != nil {
+		rootfs = spec.Linux.RootfsPropagation
+	}
+	if isSharedPropagation(rootfs) && !allowShared {
+		return nil, errors.Errorf("container %s root filesystem cannot use %q propagation unless allowed with the %q annotation",
+			id, rootfs, annotations.LCOWAllowRSharedMounts)
+	}
+
+	var sources []string
+	for _, m := range spec.Mounts {
+		p := mountPropagation(m.Options)
+		if !propagationOptions[p] || !isBindMount(m) {
+			continue
+		}
+		if isSharedPropagation(p) {
+			if !allowShared {
+				return nil, errors.Errorf("mount %s of container %s cannot use %q propagation unless allowed with the %q annotation",
+					m.Destination, id, p, annotations.LCOWAllowRSharedMounts)
+			}
+			rootfs = "rshared"
+		} else if !propagationOptions[rootfs] {
+			rootfs = "rslave"
+		}
+		sources = append(sources, m.Source)
+	}
+
+	if rootfs != "" {
+		if spec.Linux == nil {
+			spec.Linux = &specs.Linux{}
+		}
+		spec.Linux.RootfsPropagation = rootfs
+	}
+	return sources, nil
+}
+
+// containingMount returns the mount in `mounts` that contains `path`, or nil if there
+// is none. If several mounts are stacked on the same mount point, the last is returned,
+// since it is the one that is visible.
+func containingMount(path string, mounts []*mountinfo.Info) (c *mountinfo.Info) {
+	for _, m := range mounts {
+		if m.Mountpoint != "/" && path != m.Mountpoint && !strings.HasPrefix(path, m.Mountpoint+"/") {
+			continue
+		}
+		if c == nil || len(m.Mountpoint) >= len(c.Mountpoint) {
+			c = m
+		}
+	}
+	return c
+}
+
+// makeMountShared makes the mount in the uVM that contains `path` shared, so that the
+// containers' bind mounts of `path` join its peer group.
+//
+// Unless it is already on a shared mount, such as the sandbox mounts directory, `path`
+// must be a mount point: sharing a parent mount would also expose the mounts under it
+// that are unrelated to the container.
+func makeMountShared(path string) error {
+	mounts, err := mountinfo.GetMounts(mountinfo.ParentsFilter(path))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the mounts of %s", path)
+	}
+	m := containingMount(path, mounts)
+	if m == nil {
+		return errors.Errorf("could not find the mount of %s", path)
+	}
+	if strings.Contains(m.Optional, "shared:") {
+		return nil
+	}
+	if m.Mountpoint != path {
+		return errors.Errorf("%s must be a mount point in the uVM to propagate mounts", path)
+	}
+	if err := unix.Mount("", path, "", unix.MS_SHARED|unix.MS_REC, ""); err != nil {
+		return errors.Wrapf(err, "failed to make %s rshared", path)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/moby/sys/mountinfo"
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+func Test_setupMountPropagation(t *testing.T) {
+	allowShared := map[string]string{annotations.LCOWAllowRSharedMounts: "true"}
+
+	for _, tc := range []struct {
+		name        string
+		mounts      []specs.Mount
+		rootfs      string
+		annotations map[string]string
+		sources     []string
+		expected    string
+		valid       bool
+	}{
+		{
+			name:   "Private",
+			mounts: []specs.Mount{{Type: "bind", Source: "/a", Destination: "/a", Options: []string{"rbind", "rprivate"}}},
+			valid:  true,
+		},
+		{
+			name:     "Slave",
+			mounts:   []specs.Mount{{Type: "bind", Source: "/a", Destination: "/a", Options: []string{"rbind", "rslave"}}},
+			sources:  []string{"/a"},
+			expected: "rslave",
+			valid:    true,
+		},
+		{
+			name:        "SlaveKeepsSharedRootfs",
+			mounts:      []specs.Mount{{Type: "bind", Source: "/a", Destination: "/a", Options: []string{"rbind", "rslave"}}},
+			rootfs:      "rshared",
+			annotations: allowShared,
+			sources:     []string{"/a"},
+			expected:    "rshared",
+			valid:       true,
+		},
+		{
+			name:        "SharedRootfsNotAllowed",
+			rootfs:      "rshared",
+			annotations: map[string]string{},
+			valid:       false,
+		},
+		{
+			name:   "SharedNotAllowed",
+			mounts: []specs.Mount{{Type: "bind", Source: "/a", Destination: "/a", Options: []string{"rbind", "rshared"}}},
+			valid:  false,
+		},
+		{
+			name: "Shared",
+			mounts: []specs.Mount{
+				{Type: "none", Source: "/a", Destination: "/a", Options: []string{"rbind", "rslave"}},
+				{Type: "bind", Source: "/b", Destination: "/b", Options: []string{"rbind", "rshared"}},
+			},
+			annotations: allowShared,
+			sources:     []string{"/a", "/b"},
+			expected:    "rshared",
+			valid:       true,
+		},
+		{
+			name:        "LastOptionWins",
+			mounts:      []specs.Mount{{Type: "bind", Source: "/a", Destination: "/a", Options: []string{"rshared", "rprivate"}}},
+			annotations: map[string]string{},
+			valid:       true,
+		},
+		{
+			name:   "NotBind",
+			mounts: []specs.Mount{{Type: "tmpfs", Source: "tmpfs", Destination: "/a", Options: []string{"rshared"}}},
+			valid:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{
+				Annotations: tc.annotations,
+				Mounts:      tc.mounts,
+				Linux:       &specs.Linux{RootfsPropagation: tc.rootfs},
+			}
+			sources, err := setupMountPropagation(context.Background(), t.Name(), spec)
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid %t, got error %v", tc.valid, err)
+			}
+			if !tc.valid {
+				return
+			}
+			if !reflect.DeepEqual(sources, tc.sources) {
+				t.Fatalf("expected sources %v, got %v", tc.sources, sources)
+			}
+			if spec.Linux.RootfsPropagation != tc.expected {
+				t.Fatalf("expected rootfs propagation %q, got %q", tc.expected, spec.Linux.RootfsPropagation)
+			}
+		})
+	}
+}
+
+func Test_containingMount(t *testing.T) {
+	mounts := []*mountinfo.Info{
+		{Mountpoint: "/"},
+		{Mountpoint: "/run"},
+		{Mountpoint: "/run/gcs/c/abc/mounts/m0", Optional: "first"},
+		{Mountpoint: "/run/gcs/c/abc/mounts/m0", Optional: "second"},
+	}
+
+	for _, tc := range []struct {
+		path     string
+		expected *mountinfo.Info
+	}{
+		{path: "/etc", expected: mounts[0]},
+		{path: "/run/gcs/c/ab", expected: mounts[1]},
+		{path: "/run/gcs/c/abc/mounts/m0", expected: mounts[3]},
+		{path: "/run/gcs/c/abc/mounts/m0/file", expected: mounts[3]},
+		{path: "/run/gcs/c/abc/mounts/m01", expected: mounts[1]},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			if m := containingMount(tc.path, mounts); m != tc.expected {
+				t.Fatalf("expected mount %+v, got %+v", tc.expected, m)
+			}
+		})
+	}
+}
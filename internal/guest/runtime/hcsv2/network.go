@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 	"go.opencensus.io/trace"
 
@@ -162,6 +163,25 @@ func (n *namespace) AddAdapter(ctx context.Context, adp *guestresource.LCOWNetwo
 		}
 	}
 
+	if err := network.ValidateVLANTag(adp.VLANTag); err != nil {
+		return err
+	}
+	if err := network.ValidateClasslessStaticRoutes(adp.ClasslessStaticRoutes); err != nil {
+		return err
+	}
+	if err := network.ValidateMTU(adp.MTU); err != nil {
+		return err
+	}
+	if err := network.ValidateEgressBandwidth(adp.EgressBandwidth); err != nil {
+		return err
+	}
+	if err := network.ValidateEgressBandwidth(uint64(adp.EgressBandwidthMbps) * 1_000_000); err != nil {
+		return err
+	}
+	if err := network.ValidateIngressBandwidth(uint64(adp.IngressBandwidthMbps) * 1_000_000); err != nil {
+		return err
+	}
+
 	resolveCtx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 	ifname, err := networkInstanceIDToName(resolveCtx, adp.ID, adp.VPCIAssigned)
@@ -203,6 +223,42 @@ func (n *namespace) RemoveAdapter(ctx context.Context, id string) (err error) {
 	return nil
 }
 
+// UpdateAdapterEgressBandwidth sets the egress bandwidth of the adapter matching
+// `id` in `n` to `bps` bits per second, and reshapes the traffic of its
+// interface if it was already moved into the network namespace of `n`. The
+// adapter's own EgressBandwidthMbps still applies if it is lower.
+func (n *namespace) UpdateAdapterEgressBandwidth(ctx context.Context, id string, bps uint64) (err error) {
+	ctx, span := oc.StartSpan(ctx, "namespace::UpdateAdapterEgressBandwidth")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("namespace", n.id),
+		trace.StringAttribute("adapterID", id),
+		trace.Int64Attribute("bandwidth", int64(bps)))
+
+	if err := network.ValidateEgressBandwidth(bps); err != nil {
+		return gcserr.WrapHresult(err, gcserr.HrErrInvalidArg)
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	for _, nic := range n.nics {
+		if strings.EqualFold(nic.adapter.ID, id) {
+			adapter := *nic.adapter
+			adapter.EgressBandwidth = bps
+			if nic.assignedPid != 0 {
+				if err := nic.setEgressBandwidth(ctx, network.AdapterEgressBandwidth(&adapter)); err != nil {
+					return err
+				}
+			}
+			nic.adapter.EgressBandwidth = bps
+			return nil
+		}
+	}
+	return gcserr.WrapHresult(errors.Errorf("adapter with id: '%s' not found in namespace", id), gcserr.HrErrNotFound)
+}
+
 // Sync moves all adapters to the network namespace of `n` if assigned.
 func (n *namespace) Sync(ctx context.Context) (err error) {
 	ctx, span := oc.StartSpan(ctx, "namespace::Sync")
@@ -270,3 +326,29 @@ func (nin *nicInNamespace) assignToPid(ctx context.Context, pid int) (err error)
 	nin.assignedPid = pid
 	return nil
 }
+
+// setEgressBandwidth shapes the traffic sent on `nin.ifname`, in the network
+// namespace of the pid it was assigned to, to `bps` bits per second.
+func (nin *nicInNamespace) setEgressBandwidth(ctx context.Context, bps uint64) (err error) {
+	_, span := oc.StartSpan(ctx, "nicInNamespace::setEgressBandwidth")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("adapterID", nin.adapter.ID),
+		trace.StringAttribute("ifname", nin.ifname),
+		trace.Int64Attribute("pid", int64(nin.assignedPid)))
+
+	ns, err := netns.GetFromPid(nin.assignedPid)
+	if err != nil {
+		return errors.Wrapf(err, "netns.GetFromPid(%d) failed", nin.assignedPid)
+	}
+	defer ns.Close()
+
+	return network.DoInNetNS(ns, func() error {
+		link, err := netlink.LinkByName(nin.ifname)
+		if err != nil {
+			return errors.Wrapf(err, "netlink.LinkByName(%s) failed", nin.ifname)
+		}
+		return network.SetEgressBandwidth(link, bps)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os/exec"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
@@ -32,9 +33,9 @@ type Process interface {
 	// ResizeConsole resizes the tty to `height`x`width` for the process.
 	ResizeConsole(ctx context.Context, height, width uint16) error
 	// Wait returns a channel that can be used to wait for the process to exit
-	// and gather the exit code. The second channel must be signaled from the
+	// and gather the exit status. The second channel must be signaled from the
 	// caller when the caller has completed its use of this call to Wait.
-	Wait() (<-chan int, chan<- bool)
+	Wait() (<-chan runtime.ExitStatus, chan<- bool)
 }
 
 // Process is a struct that defines the lifetime and operations associated with
@@ -52,9 +53,11 @@ type containerProcess struct {
 	init bool
 
 	// This is only valid post the exitWg
-	exitCode int
+	exitStatus runtime.ExitStatus
+	// exited is set once the process has exited, at the same time as exitWg.
+	exited atomic.Bool
 	// exitWg is marked as done as soon as the underlying
-	// (runtime.Process).Wait() call returns, and exitCode has been updated.
+	// (runtime.Process).Wait() call returns, and exitStatus has been updated.
 	exitWg sync.WaitGroup
 
 	// Used to allow addition/removal to the writersWg after an initial wait has
@@ -94,12 +97,17 @@ func newProcess(c *Container, spec *oci.Process, process runtime.Process, pid ui
 			trace.Int64Attribute(logfields.ProcessID, int64(p.pid)))
 
 		// Wait for the process to exit
-		exitCode, err := p.process.Wait()
+		exitStatus, err := p.process.Wait()
 		if err != nil {
 			log.G(ctx).WithError(err).Error("failed to wait for runc process")
 		}
-		p.exitCode = exitCode
-		log.G(ctx).WithField("exitCode", p.exitCode).Debug("process exited")
+		p.exitStatus = exitStatus
+		p.exited.Store(true)
+		log.G(ctx).WithFields(logrus.Fields{
+			"exitCode":   exitStatus.Code,
+			"signal":     exitStatus.Signal,
+			"coreDumped": exitStatus.CoreDumped,
+		}).Debug("process exited")
 
 		// Free any process waiters
 		p.exitWg.Done()
@@ -162,15 +170,15 @@ func (p *containerProcess) ResizeConsole(_ context.Context, height, width uint16
 }
 
 // Wait returns a channel that can be used to wait for the process to exit and
-// gather the exit code. The second channel must be signaled from the caller
+// gather the exit status. The second channel must be signaled from the caller
 // when the caller has completed its use of this call to Wait.
-func (p *containerProcess) Wait() (<-chan int, chan<- bool) {
+func (p *containerProcess) Wait() (<-chan runtime.ExitStatus, chan<- bool) {
 	ctx, span := oc.StartSpan(context.Background(), "opengcs::containerProcess::Wait")
 	span.AddAttributes(
 		trace.StringAttribute("cid", p.cid),
 		trace.Int64Attribute("pid", int64(p.pid)))
 
-	exitCodeChan := make(chan int, 1)
+	exitCodeChan := make(chan runtime.ExitStatus, 1)
 	doneChan := make(chan bool)
 
 	// Increment our waiters for this waiter
@@ -179,10 +187,10 @@ func (p *containerProcess) Wait() (<-chan int, chan<- bool) {
 	p.writersSyncRoot.Unlock()
 
 	go func() {
-		bgExitCodeChan := make(chan int, 1)
+		bgExitCodeChan := make(chan runtime.ExitStatus, 1)
 		go func() {
 			p.exitWg.Wait()
-			bgExitCodeChan <- p.exitCode
+			bgExitCodeChan <- p.exitStatus
 		}()
 
 		// Wait for the exit code or the caller to stop waiting.
@@ -240,10 +248,18 @@ func newExternalProcess(ctx context.Context, cmd *exec.Cmd, tty *stdio.TtyRelay,
 	}
 	go func() {
 		_ = cmd.Wait()
-		ep.exitCode = cmd.ProcessState.ExitCode()
+		// (*os.ProcessState).ExitCode returns -1 if the process was terminated
+		// by a signal, so use the wait status instead
+		if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			ep.exitStatus = runtime.NewExitStatus(ws)
+		} else {
+			ep.exitStatus = runtime.ExitStatus{Code: cmd.ProcessState.ExitCode()}
+		}
 		log.G(ctx).WithFields(logrus.Fields{
-			"pid":      cmd.Process.Pid,
-			"exitCode": ep.exitCode,
+			"pid":        cmd.Process.Pid,
+			"exitCode":   ep.exitStatus.Code,
+			"signal":     ep.exitStatus.Signal,
+			"coreDumped": ep.exitStatus.CoreDumped,
 		}).Debug("external process exited")
 		if ep.tty != nil {
 			ep.tty.Wait()
@@ -257,8 +273,8 @@ type externalProcess struct {
 	cmd *exec.Cmd
 	tty *stdio.TtyRelay
 
-	waitBlock chan struct{}
-	exitCode  int
+	waitBlock  chan struct{}
+	exitStatus runtime.ExitStatus
 
 	removeOnce sync.Once
 	remove     func(pid int)
@@ -276,6 +292,16 @@ func (ep *externalProcess) Kill(_ context.Context, signal syscall.Signal) error
 	return nil
 }
 
+// hasExited returns if the process has exited.
+func (ep *externalProcess) hasExited() bool {
+	select {
+	case <-ep.waitBlock:
+		return true
+	default:
+		return false
+	}
+}
+
 func (ep *externalProcess) Pid() int {
 	return ep.cmd.Process.Pid
 }
@@ -287,11 +313,11 @@ func (ep *externalProcess) ResizeConsole(_ context.Context, height, width uint16
 	return ep.tty.ResizeConsole(height, width)
 }
 
-func (ep *externalProcess) Wait() (<-chan int, chan<- bool) {
+func (ep *externalProcess) Wait() (<-chan runtime.ExitStatus, chan<- bool) {
 	_, span := oc.StartSpan(context.Background(), "opengcs::externalProcess::Wait")
 	span.AddAttributes(trace.Int64Attribute("pid", int64(ep.cmd.Process.Pid)))
 
-	exitCodeChan := make(chan int, 1)
+	exitCodeChan := make(chan runtime.ExitStatus, 1)
 	doneChan := make(chan bool)
 
 	go func() {
@@ -301,7 +327,7 @@ func (ep *externalProcess) Wait() (<-chan int, chan<- bool) {
 		select {
 		case <-ep.waitBlock:
 			// Process exited send the exit code and wait for caller to close.
-			exitCodeChan <- ep.exitCode
+			exitCodeChan <- ep.exitStatus
 			<-doneChan
 			// At least one waiter was successful, remove this external process.
 			ep.removeOnce.Do(func() {
@@ -0,0 +1,200 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+// resctrlPath is where the resctrl filesystem, which exposes Intel RDT, is
+// mounted in the guest.
+const resctrlPath = "/sys/fs/resctrl"
+
+// rdtClassQuarters are the quarters of the cache ways and memory bandwidth
+// allowed by each RDT class.
+var rdtClassQuarters = map[string]uint{
+	annotations.RDTClassCATHigh:   4,
+	annotations.RDTClassCATMedium: 2,
+	annotations.RDTClassCATLow:    1,
+}
+
+// parseRDTClass returns the RDT class requested by `spec` with the
+// [annotations.ContainerRDTClass] annotation, if any.
+func parseRDTClass(spec *oci.Spec) (string, error) {
+	class := spec.Annotations[annotations.ContainerRDTClass]
+	if class == "" {
+		return "", nil
+	}
+	if _, ok := rdtClassQuarters[class]; !ok {
+		return "", errors.Errorf("unsupported RDT class %q: must be one of %q, %q, or %q", class,
+			annotations.RDTClassCATHigh, annotations.RDTClassCATMedium, annotations.RDTClassCATLow)
+	}
+	return class, nil
+}
+
+// mountResctrl mounts the resctrl filesystem at [resctrlPath], if it is not
+// already mounted.
+func mountResctrl() error {
+	if _, err := os.Stat(filepath.Join(resctrlPath, "info")); err == nil {
+		return nil
+	}
+	if err := unix.Mount("resctrl", resctrlPath, "resctrl", 0, ""); err != nil && !errors.Is(err, unix.EBUSY) {
+		return errors.Wrapf(err, "failed to mount resctrl filesystem, RDT may not be supported by the uvm")
+	}
+	return nil
+}
+
+// setRDTClass sets the Intel RDT class of service requested by `spec` with the
+// [annotations.ContainerRDTClass] annotation, if any, in the spec's Linux.IntelRdt.
+// runc then assigns every process of the container to the class, exec'd
+// processes included, before the process runs, and fails to start it otherwise.
+func setRDTClass(ctx context.Context, spec *oci.Spec) error {
+	class, err := parseRDTClass(spec)
+	if err != nil || class == "" {
+		return err
+	}
+	if err := mountResctrl(); err != nil {
+		return err
+	}
+	rdt, err := rdtConfig(resctrlPath, class)
+	if err != nil {
+		return err
+	}
+	log.G(ctx).WithFields(logrus.Fields{
+		"class": class,
+		"l3":    rdt.L3CacheSchema,
+		"mb":    rdt.MemBwSchema,
+	}).Debug("setting container RDT class")
+	if spec.Linux == nil {
+		spec.Linux = &oci.Linux{}
+	}
+	spec.Linux.IntelRdt = rdt
+	return nil
+}
+
+// rdtConfig returns the RDT configuration of `class`, with a schema for each L3
+// cache and memory bandwidth domain listed in the default group of the resctrl
+// filesystem at `root`.
+func rdtConfig(root, class string) (*oci.LinuxIntelRdt, error) {
+	quarters, ok := rdtClassQuarters[class]
+	if !ok {
+		return nil, errors.Errorf("unsupported RDT class %q", class)
+	}
+	b, err := os.ReadFile(filepath.Join(root, "schemata"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read default resctrl schemata")
+	}
+
+	rdt := &oci.LinuxIntelRdt{ClosID: class}
+	for _, line := range strings.Split(string(b), "\n") {
+		resource, domains, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		var value string
+		switch resource {
+		case "L3":
+			mask, err := rdtCacheMask(filepath.Join(root, "info", resource), quarters)
+			if err != nil {
+				return nil, err
+			}
+			value = strconv.FormatUint(mask, 16)
+		case "MB":
+			mb, err := rdtMemBandwidth(filepath.Join(root, "info", resource), quarters)
+			if err != nil {
+				return nil, err
+			}
+			value = strconv.FormatUint(mb, 10)
+		default:
+			continue
+		}
+		var ds []string
+		for _, d := range strings.Split(domains, ";") {
+			id, _, ok := strings.Cut(strings.TrimSpace(d), "=")
+			if !ok {
+				return nil, errors.Errorf("invalid resctrl schemata %q", line)
+			}
+			ds = append(ds, id+"="+value)
+		}
+		schema := resource + ":" + strings.Join(ds, ";")
+		if resource == "L3" {
+			rdt.L3CacheSchema = schema
+		} else {
+			rdt.MemBwSchema = schema
+		}
+	}
+	if rdt.L3CacheSchema == "" && rdt.MemBwSchema == "" {
+		return nil, errors.New("neither L3 cache nor memory bandwidth allocation is supported by the uvm")
+	}
+	return rdt, nil
+}
+
+// rdtCacheMask returns the capacity bitmask for `quarters` of the cache ways
+// described in `infoDir`, rounded up to the minimum number of ways.
+func rdtCacheMask(infoDir string, quarters uint) (uint64, error) {
+	full, err := readResctrlUint(filepath.Join(infoDir, "cbm_mask"), 16, 0)
+	if err != nil {
+		return 0, err
+	}
+	if full == 0 {
+		return 0, errors.Errorf("no cache ways reported in %s", infoDir)
+	}
+	minBits, err := readResctrlUint(filepath.Join(infoDir, "min_cbm_bits"), 10, 1)
+	if err != nil {
+		return 0, err
+	}
+	ways := uint64(bits.OnesCount64(full))
+	n := max((ways*uint64(quarters)+3)/4, minBits, 1)
+	if n >= ways {
+		return full, nil
+	}
+	return 1<<n - 1, nil
+}
+
+// rdtMemBandwidth returns the percentage for `quarters` of the memory bandwidth
+// described in `infoDir`, rounded up to the bandwidth granularity.
+func rdtMemBandwidth(infoDir string, quarters uint) (uint64, error) {
+	minBW, err := readResctrlUint(filepath.Join(infoDir, "min_bandwidth"), 10, 10)
+	if err != nil {
+		return 0, err
+	}
+	gran, err := readResctrlUint(filepath.Join(infoDir, "bandwidth_gran"), 10, 10)
+	if err != nil {
+		return 0, err
+	}
+	bw := 100 * uint64(quarters) / 4
+	if gran > 0 {
+		bw = (bw + gran - 1) / gran * gran
+	}
+	return min(max(bw, minBW), 100), nil
+}
+
+// readResctrlUint reads the unsigned integer in base `base` from the resctrl info
+// file `p`, returning `def` if the file does not exist.
+func readResctrlUint(p string, base int, def uint64) (uint64, error) {
+	b, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return def, nil
+	} else if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(b)), base, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid value in %s", p)
+	}
+	return v, nil
+}
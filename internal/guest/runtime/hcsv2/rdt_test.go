@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+func newTestResctrl(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for f, c := range files {
+		p := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestParseRDTClass(t *testing.T) {
+	for _, tc := range []struct {
+		class   string
+		wantErr bool
+	}{
+		{class: ""},
+		{class: annotations.RDTClassCATHigh},
+		{class: annotations.RDTClassCATLow},
+		{class: "CATNone", wantErr: true},
+	} {
+		spec := &oci.Spec{Annotations: map[string]string{annotations.ContainerRDTClass: tc.class}}
+		class, err := parseRDTClass(spec)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("class %q: expected error %t, got: %v", tc.class, tc.wantErr, err)
+		}
+		if err == nil && class != tc.class {
+			t.Fatalf("expected class %q, got %q", tc.class, class)
+		}
+	}
+}
+
+func TestRDTConfig(t *testing.T) {
+	root := newTestResctrl(t, map[string]string{
+		"schemata":               "    L3:0=fff;1=fff\n    MB:0=100;1=100\n",
+		"info/L3/cbm_mask":       "fff\n",
+		"info/L3/min_cbm_bits":   "1\n",
+		"info/MB/min_bandwidth":  "10\n",
+		"info/MB/bandwidth_gran": "10\n",
+	})
+
+	for class, expected := range map[string]oci.LinuxIntelRdt{
+		annotations.RDTClassCATHigh:   {L3CacheSchema: "L3:0=fff;1=fff", MemBwSchema: "MB:0=100;1=100"},
+		annotations.RDTClassCATMedium: {L3CacheSchema: "L3:0=3f;1=3f", MemBwSchema: "MB:0=50;1=50"},
+		annotations.RDTClassCATLow:    {L3CacheSchema: "L3:0=7;1=7", MemBwSchema: "MB:0=30;1=30"},
+	} {
+		expected.ClosID = class
+		rdt, err := rdtConfig(root, class)
+		if err != nil {
+			t.Fatalf("failed to get RDT config of %s: %v", class, err)
+		}
+		if *rdt != expected {
+			t.Fatalf("expected RDT config of %s to be %+v, got %+v", class, expected, *rdt)
+		}
+	}
+}
+
+func TestRDTConfig_MinCacheWays(t *testing.T) {
+	root := newTestResctrl(t, map[string]string{
+		"schemata":             "L3:0=f\n",
+		"info/L3/cbm_mask":     "f\n",
+		"info/L3/min_cbm_bits": "2\n",
+	})
+
+	rdt, err := rdtConfig(root, annotations.RDTClassCATLow)
+	if err != nil {
+		t.Fatalf("failed to get RDT config: %v", err)
+	}
+	if expected := "L3:0=3"; rdt.L3CacheSchema != expected || rdt.MemBwSchema != "" {
+		t.Fatalf("expected L3 schema %q only, got %+v", expected, *rdt)
+	}
+}
+
+func TestRDTConfig_Unsupported(t *testing.T) {
+	root := newTestResctrl(t, map[string]string{
+		"schemata": "L2:0=ff\n",
+	})
+
+	if _, err := rdtConfig(root, annotations.RDTClassCATLow); err == nil {
+		t.Fatal("expected error without L3 cache or memory bandwidth allocation")
+	}
+}
+
+func TestSetRDTClass_None(t *testing.T) {
+	spec := &oci.Spec{Linux: &oci.Linux{}}
+	if err := setRDTClass(context.Background(), spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Linux.IntelRdt != nil {
+		t.Fatalf("expected no RDT config, got %+v", *spec.Linux.IntelRdt)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/sys/mountinfo"
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+// isReadonlyMount returns if the mount `options` make it read-only. As with
+// runc, the last of "ro" and "rw" wins.
+func isReadonlyMount(options []string) (ro bool) {
+	for _, o := range options {
+		switch o {
+		case "ro":
+			ro = true
+		case "rw":
+			ro = false
+		}
+	}
+	return ro
+}
+
+// validateReadonlyRootfs returns an error if `spec`, of a container with a
+// read-only root filesystem, has mounts that would make the root filesystem,
+// or part of it, writable again.
+//
+// Writable mounts of other filesystems, such as the tmpfs mounts of /tmp and
+// /run that containerd adds, are allowed.
+func validateReadonlyRootfs(spec *oci.Spec) error {
+	rootfs := filepath.Clean(spec.Root.Path)
+	for _, m := range spec.Mounts {
+		if filepath.Clean(m.Destination) == "/" {
+			return gcserr.WrapHresult(errors.Errorf("mount of %q over / conflicts with the read-only root filesystem", m.Source), gcserr.HrErrInvalidArg)
+		}
+		if !isBindMount(m) || isReadonlyMount(m.Options) {
+			continue
+		}
+		source := filepath.Clean(m.Source)
+		if source == rootfs || strings.HasPrefix(source, rootfs+"/") {
+			return gcserr.WrapHresult(errors.Errorf("writable bind mount of %q to %q conflicts with the read-only root filesystem", m.Source, m.Destination), gcserr.HrErrInvalidArg)
+		}
+	}
+	return nil
+}
+
+// remountRootfsReadonly remounts the filesystem mounted at `rootfs`, the
+// overlay of a container's layers, read-only. This changes the filesystem
+// rather than the mount, so that it is read-only in the container's mount
+// namespace too, and must be done once the container is created, as the
+// runtime creates the mount points of the container's mounts in it.
+//
+// A `rootfs` that is not a mount point, which would remount the filesystem
+// of the uVM itself, is an error.
+func remountRootfsReadonly(rootfs string) error {
+	mounted, err := mountinfo.Mounted(rootfs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check root filesystem mount %q", rootfs)
+	}
+	if !mounted {
+		return errors.Errorf("root filesystem %q is not a mount point", rootfs)
+	}
+	if err := unix.Mount("", rootfs, "", unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+		return errors.Wrapf(err, "failed to remount root filesystem %q read-only", rootfs)
+	}
+	return nil
+}
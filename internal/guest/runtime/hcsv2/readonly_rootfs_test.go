@@ -0,0 +1,70 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+func TestValidateReadonlyRootfs(t *testing.T) {
+	const rootfs = "/run/gcs/c/c1/rootfs"
+	for _, tc := range []struct {
+		name  string
+		mount oci.Mount
+		err   bool
+	}{
+		{
+			name:  "tmpfs",
+			mount: oci.Mount{Destination: "/tmp", Type: "tmpfs", Source: "tmpfs", Options: []string{"nosuid", "nodev"}},
+		},
+		{
+			name:  "writable bind",
+			mount: oci.Mount{Destination: "/data", Type: "bind", Source: "/run/mounts/m0", Options: []string{"rbind", "rw"}},
+		},
+		{
+			name:  "read-only bind of rootfs",
+			mount: oci.Mount{Destination: "/etc2", Source: rootfs + "/etc", Options: []string{"rbind", "rw", "ro"}},
+		},
+		{
+			name:  "writable bind of rootfs",
+			mount: oci.Mount{Destination: "/etc2", Source: rootfs + "/etc", Options: []string{"rbind", "ro", "rw"}},
+			err:   true,
+		},
+		{
+			name:  "writable bind of rootfs root",
+			mount: oci.Mount{Destination: "/root2", Type: "bind", Source: rootfs + "/"},
+			err:   true,
+		},
+		{
+			name:  "writable bind of sibling",
+			mount: oci.Mount{Destination: "/data", Type: "bind", Source: rootfs + "2/data"},
+		},
+		{
+			name:  "mount over root",
+			mount: oci.Mount{Destination: "/", Type: "tmpfs", Source: "tmpfs"},
+			err:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &oci.Spec{
+				Root:   &oci.Root{Path: rootfs, Readonly: true},
+				Mounts: []oci.Mount{tc.mount},
+			}
+			err := validateReadonlyRootfs(spec)
+			if tc.err {
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != gcserr.HrErrInvalidArg {
+					t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrErrInvalidArg, hr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected mount to be allowed, got: %v", err)
+			}
+		})
+	}
+}
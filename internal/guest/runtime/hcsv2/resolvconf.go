@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"os"
+	"sync"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/network"
+)
+
+// managedResolvPath returns `path`, a resolv.conf file written by the GCS, if it is
+// the /etc/resolv.conf of a container with `spec`. An error is returned for a
+// container that mounts its own /etc/resolv.conf, which the GCS must not change.
+func managedResolvPath(spec *oci.Spec, path string) (string, error) {
+	for _, m := range spec.Mounts {
+		if m.Destination == "/etc/resolv.conf" {
+			if m.Source == path {
+				return path, nil
+			}
+			break
+		}
+	}
+	return "", gcserr.WrapHresult(errors.New("cannot add the hostname suffix to a /etc/resolv.conf not written by the GCS"), gcserr.HrErrInvalidArg)
+}
+
+// resolvConfMu serializes the rewrites of the resolv.conf files written by the
+// GCS, since the containers of a pod share the pod's file and can be created
+// concurrently.
+var resolvConfMu sync.Mutex
+
+// addResolvSearch adds `domain` to the search domains of the resolv.conf file at
+// `path`. The file is overwritten in place, rather than replaced, so that the bind
+// mounts of it in running containers see the new content.
+func addResolvSearch(path, domain string) error {
+	resolvConfMu.Lock()
+	defer resolvConfMu.Unlock()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read resolv.conf %q", path)
+	}
+	newContent, err := network.AddResolvConfSearch(string(content), domain)
+	if err != nil {
+		return gcserr.WrapHresult(errors.Wrapf(err, "failed to add hostname suffix to resolv.conf %q", path), gcserr.HrErrInvalidArg)
+	}
+	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write resolv.conf %q", path)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+func TestManagedResolvPath(t *testing.T) {
+	const path = "/run/gcs/c/sandbox/resolv.conf"
+	for _, tc := range []struct {
+		name   string
+		mounts []oci.Mount
+		err    bool
+	}{
+		{
+			name:   "managed",
+			mounts: []oci.Mount{{Destination: "/etc/hosts", Source: "/run/gcs/c/sandbox/hosts"}, {Destination: "/etc/resolv.conf", Source: path}},
+		},
+		{
+			name:   "user mount",
+			mounts: []oci.Mount{{Destination: "/etc/resolv.conf", Source: "/run/mounts/m0"}, {Destination: "/etc/resolv.conf", Source: path}},
+			err:    true,
+		},
+		{
+			name: "no mount",
+			err:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := managedResolvPath(&oci.Spec{Mounts: tc.mounts}, path)
+			if tc.err {
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != gcserr.HrErrInvalidArg {
+					t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrErrInvalidArg, hr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get resolv.conf path: %v", err)
+			}
+			if p != path {
+				t.Fatalf("expected path %q, got %q", path, p)
+			}
+		})
+	}
+}
+
+func TestAddResolvSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte("search a.com\nnameserver 8.8.8.8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// adding the suffix twice, as for each container in a pod, adds it once
+	for i := 0; i < 2; i++ {
+		if err := addResolvSearch(path, "svc.cluster.local"); err != nil {
+			t.Fatalf("failed to add search domain: %v", err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "search a.com svc.cluster.local\nnameserver 8.8.8.8\n"; string(b) != expected {
+		t.Fatalf("expected content %q, got %q", expected, b)
+	}
+
+	// the file must be the same one that is bind mounted into the containers
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Fatal("resolv.conf was replaced rather than overwritten")
+	}
+
+	if err := addResolvSearch(path, "bad domain"); err == nil {
+		t.Fatal("expected error adding invalid search domain")
+	}
+}
@@ -5,8 +5,10 @@ package hcsv2
 
 import (
 	"context"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	oci "github.com/opencontainers/runtime-spec/specs-go"
@@ -17,6 +19,7 @@ import (
 	specGuest "github.com/Microsoft/hcsshim/internal/guest/spec"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
@@ -32,7 +35,75 @@ func getSandboxResolvPath(id, virtualSandboxID string) string {
 	return filepath.Join(specGuest.VirtualPodAwareSandboxRootDir(id, virtualSandboxID), "resolv.conf")
 }
 
-func setupSandboxContainerSpec(ctx context.Context, id string, spec *oci.Spec) (err error) {
+// writeSandboxHosts writes the hosts file shared by the containers in a sandbox.
+// The file is overwritten in place, rather than replaced, so that the bind mounts
+// of it in running containers see the new content.
+func writeSandboxHosts(ctx context.Context, path, hostname string, hostAliases []guestresource.HostAlias) error {
+	content := network.GenerateEtcHostsContent(ctx, hostname) + network.GenerateEtcHostsAliasesContent(hostAliases)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return errors.Wrapf(err, "failed to write sandbox hosts to %q", path)
+	}
+	return nil
+}
+
+// validateHostAliases checks that `hostAliases` can be written to a hosts file.
+func validateHostAliases(hostAliases []guestresource.HostAlias) error {
+	for _, ha := range hostAliases {
+		if net.ParseIP(ha.IP) == nil {
+			return errors.Errorf("invalid host alias IP address %q", ha.IP)
+		}
+		if len(ha.Hostnames) == 0 {
+			return errors.Errorf("no hostnames specified for host alias %s", ha.IP)
+		}
+		for _, h := range ha.Hostnames {
+			if h == "" || strings.ContainsAny(h, " \t\r\n#") {
+				return errors.Errorf("invalid host alias hostname %q", h)
+			}
+		}
+	}
+	return nil
+}
+
+// addHostAliases adds `hostAliases` to the hosts file shared by the containers
+// in the sandbox. Entries that are already present are skipped.
+func (c *Container) addHostAliases(ctx context.Context, hostAliases []guestresource.HostAlias) (err error) {
+	ctx, span := oc.StartSpan(ctx, "hcsv2::Container::addHostAliases")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute("cid", c.id))
+
+	if !c.isSandbox {
+		return errors.Errorf("cannot add host aliases to container %s: not a sandbox container", c.id)
+	}
+	if err := validateHostAliases(hostAliases); err != nil {
+		return err
+	}
+
+	c.hostsMu.Lock()
+	defer c.hostsMu.Unlock()
+
+	aliases := slices.Clone(c.hostAliases)
+	for _, ha := range hostAliases {
+		if !slices.ContainsFunc(aliases, func(o guestresource.HostAlias) bool {
+			return o.IP == ha.IP && slices.Equal(o.Hostnames, ha.Hostnames)
+		}) {
+			aliases = append(aliases, ha)
+		}
+	}
+
+	virtualSandboxID := c.spec.Annotations[annotations.VirtualPodID]
+	hostname, err := os.ReadFile(getSandboxHostnamePath(c.id, virtualSandboxID))
+	if err != nil {
+		return errors.Wrap(err, "failed to read sandbox hostname")
+	}
+	if err := writeSandboxHosts(ctx, getSandboxHostsPath(c.id, virtualSandboxID), strings.TrimSpace(string(hostname)), aliases); err != nil {
+		return err
+	}
+	c.hostAliases = aliases
+	return nil
+}
+
+func setupSandboxContainerSpec(ctx context.Context, id string, spec *oci.Spec, hostAliases []guestresource.HostAlias) (err error) {
 	ctx, span := oc.StartSpan(ctx, "hcsv2::setupSandboxContainerSpec")
 	defer span.End()
 	defer func() { oc.SetSpanStatus(span, err) }()
@@ -68,10 +139,11 @@ func setupSandboxContainerSpec(ctx context.Context, id string, spec *oci.Spec) (
 	}
 
 	// Write the hosts
-	sandboxHostsContent := network.GenerateEtcHostsContent(ctx, hostname)
-	sandboxHostsPath := getSandboxHostsPath(id, virtualSandboxID)
-	if err := os.WriteFile(sandboxHostsPath, []byte(sandboxHostsContent), 0644); err != nil {
-		return errors.Wrapf(err, "failed to write sandbox hosts to %q", sandboxHostsPath)
+	if err := validateHostAliases(hostAliases); err != nil {
+		return err
+	}
+	if err := writeSandboxHosts(ctx, getSandboxHostsPath(id, virtualSandboxID), hostname, hostAliases); err != nil {
+		return err
 	}
 
 	// Check if this is a virtual pod sandbox container by comparing container ID with virtual pod ID
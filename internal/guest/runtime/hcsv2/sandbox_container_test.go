@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+)
+
+func Test_validateHostAliases(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		aliases []guestresource.HostAlias
+		valid   bool
+	}{
+		{
+			name:  "Empty",
+			valid: true,
+		},
+		{
+			name: "Valid",
+			aliases: []guestresource.HostAlias{
+				{IP: "10.0.0.4", Hostnames: []string{"foo.local", "bar.local"}},
+				{IP: "fd00::4", Hostnames: []string{"baz"}},
+			},
+			valid: true,
+		},
+		{
+			name:    "InvalidIP",
+			aliases: []guestresource.HostAlias{{IP: "foo", Hostnames: []string{"bar"}}},
+		},
+		{
+			name:    "NoHostnames",
+			aliases: []guestresource.HostAlias{{IP: "10.0.0.4"}},
+		},
+		{
+			name:    "NewlineInHostname",
+			aliases: []guestresource.HostAlias{{IP: "10.0.0.4", Hostnames: []string{"foo\n10.0.0.5 bar"}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateHostAliases(tc.aliases)
+			if tc.valid && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/sys/mountinfo"
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+// checkContainerScratchPath returns an error unless `dir` has the form of the
+// scratch directory of the container `id`, `<scratch mount>/scratch/<id>`, as
+// the host creates it for the container's overlay.
+func checkContainerScratchPath(dir, id string) error {
+	if !filepath.IsAbs(dir) || filepath.Clean(dir) != dir ||
+		filepath.Base(dir) != id || filepath.Base(filepath.Dir(dir)) != "scratch" {
+		return gcserr.WrapHresult(errors.Errorf("%q is not the scratch directory of container %s", dir, id), gcserr.HrErrInvalidArg)
+	}
+	return nil
+}
+
+// overlayUpperDir returns the upper directory of the overlay mount `m`, or ""
+// if `m` is not a writable overlay.
+func overlayUpperDir(m *mountinfo.Info) string {
+	if m.FSType != "overlay" {
+		return ""
+	}
+	for _, o := range strings.Split(m.VFSOptions, ",") {
+		if dir, ok := strings.CutPrefix(o, "upperdir="); ok {
+			return dir
+		}
+	}
+	return ""
+}
+
+// checkContainerScratch returns an error unless `dir` is the scratch directory
+// of the container `id`: the directory holding the upper directory of the
+// overlay mounted at the container's root filesystem `rootfs`.
+//
+// A scratch quota assigns a project to everything under the directory, so it
+// must only be applied to the container's own scratch, and never to another
+// path in the uVM.
+func checkContainerScratch(dir, id, rootfs string) error {
+	if err := checkContainerScratchPath(dir, id); err != nil {
+		return err
+	}
+	mounts, err := mountinfo.GetMounts(mountinfo.SingleEntryFilter(rootfs))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the mount of root filesystem %q", rootfs)
+	}
+	if len(mounts) == 0 {
+		return errors.Errorf("root filesystem %q is not a mount point", rootfs)
+	}
+	// the last of several stacked mounts is the one that is visible
+	if upper := overlayUpperDir(mounts[len(mounts)-1]); upper != filepath.Join(dir, "upper") {
+		return gcserr.WrapHresult(errors.Errorf("%q is not the scratch of the root filesystem %q of container %s", dir, rootfs, id), gcserr.HrErrInvalidArg)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"testing"
+
+	"github.com/moby/sys/mountinfo"
+)
+
+func Test_checkContainerScratchPath(t *testing.T) {
+	for _, tc := range []struct {
+		dir   string
+		valid bool
+	}{
+		{dir: "/run/mounts/scsi/m0-1/scratch/c1", valid: true},
+		{dir: "/run/mounts/scsi/m0-1/scratch/c2"},
+		{dir: "/run/mounts/scsi/m0-1/scratch/c1/"},
+		{dir: "/run/mounts/scsi/m0-1/scratch/../scratch/c1"},
+		{dir: "/run/mounts/scsi/m0-1/c1"},
+		{dir: "scratch/c1"},
+		{dir: "/"},
+		{dir: ""},
+	} {
+		err := checkContainerScratchPath(tc.dir, "c1")
+		if tc.valid && err != nil {
+			t.Errorf("expected %q to be the scratch of c1: %v", tc.dir, err)
+		} else if !tc.valid && err == nil {
+			t.Errorf("expected %q not to be the scratch of c1", tc.dir)
+		}
+	}
+}
+
+func Test_overlayUpperDir(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		mount    mountinfo.Info
+		expected string
+	}{
+		{
+			name: "Overlay",
+			mount: mountinfo.Info{
+				FSType:     "overlay",
+				VFSOptions: "rw,lowerdir=/run/layers/p0:/run/layers/p1,upperdir=/run/mounts/scsi/m0-1/scratch/c1/upper,workdir=/run/mounts/scsi/m0-1/scratch/c1/work",
+			},
+			expected: "/run/mounts/scsi/m0-1/scratch/c1/upper",
+		},
+		{
+			name:  "ReadonlyOverlay",
+			mount: mountinfo.Info{FSType: "overlay", VFSOptions: "ro,lowerdir=/run/layers/p0:/run/layers/p1"},
+		},
+		{
+			name:  "NotOverlay",
+			mount: mountinfo.Info{FSType: "ext4", VFSOptions: "rw,upperdir=/run/mounts/scsi/m0-1/scratch/c1/upper"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if upper := overlayUpperDir(&tc.mount); upper != tc.expected {
+				t.Fatalf("expected upper directory %q, got %q", tc.expected, upper)
+			}
+		})
+	}
+}
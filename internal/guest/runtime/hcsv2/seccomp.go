@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"slices"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+// SeccompProfilesSupported returns if the container runtime applies the seccomp
+// profile in the spec of a container to its init process and every process
+// exec'd in it.
+func (h *Host) SeccompProfilesSupported(ctx context.Context) bool {
+	if h.rtime == nil {
+		return false
+	}
+	f, err := h.rtime.Features()
+	if err != nil {
+		log.G(ctx).WithError(err).Warn("failed to get container runtime features")
+		return false
+	}
+	return seccompFeatures(f) != nil
+}
+
+// seccompFeatures returns the seccomp features in `f`, or nil if seccomp is not
+// supported.
+func seccompFeatures(f *features.Features) *features.Seccomp {
+	if f == nil || f.Linux == nil || f.Linux.Seccomp == nil {
+		return nil
+	}
+	if s := f.Linux.Seccomp; s.Enabled != nil && *s.Enabled {
+		return s
+	}
+	return nil
+}
+
+// validateSeccomp checks that the container runtime with features `f` can apply
+// the seccomp profile `s`, so that a container whose profile would not be
+// enforced as written fails to be created.
+//
+// Unknown features, such as the actions of a runtime that does not report them,
+// are assumed to be supported.
+func validateSeccomp(s *oci.LinuxSeccomp, f *features.Features) error {
+	if s == nil {
+		return nil
+	}
+	sf := seccompFeatures(f)
+	if sf == nil {
+		return errors.New("seccomp profiles are not supported by the container runtime")
+	}
+
+	supported := func(kind, v string, known []string) error {
+		if known != nil && !slices.Contains(known, v) {
+			return errors.Errorf("seccomp %s %q is not supported by the container runtime", kind, v)
+		}
+		return nil
+	}
+	checkAction := func(a oci.LinuxSeccompAction, errnoRet *uint) error {
+		if err := supported("action", string(a), sf.Actions); err != nil {
+			return err
+		}
+		if errnoRet != nil && a != oci.ActErrno && a != oci.ActTrace {
+			return errors.Errorf("seccomp errnoRet is only valid with actions %q and %q, not %q", oci.ActErrno, oci.ActTrace, a)
+		}
+		return nil
+	}
+
+	if err := checkAction(s.DefaultAction, s.DefaultErrnoRet); err != nil {
+		return err
+	}
+	for _, a := range s.Architectures {
+		if err := supported("architecture", string(a), sf.Archs); err != nil {
+			return err
+		}
+	}
+	for _, fl := range s.Flags {
+		if err := supported("flag", string(fl), sf.SupportedFlags); err != nil {
+			return err
+		}
+	}
+	for _, sc := range s.Syscalls {
+		if len(sc.Names) == 0 {
+			return errors.New("seccomp syscall rule has no syscall names")
+		}
+		if err := checkAction(sc.Action, sc.ErrnoRet); err != nil {
+			return errors.Wrapf(err, "syscalls %q", sc.Names)
+		}
+		for _, arg := range sc.Args {
+			if err := supported("operator", string(arg.Op), sf.Operators); err != nil {
+				return errors.Wrapf(err, "syscalls %q", sc.Names)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+)
+
+func seccompTestFeatures(enabled bool) *features.Features {
+	return &features.Features{
+		Linux: &features.Linux{
+			Seccomp: &features.Seccomp{
+				Enabled:        &enabled,
+				Actions:        []string{string(oci.ActAllow), string(oci.ActErrno), string(oci.ActKillProcess)},
+				Operators:      []string{string(oci.OpEqualTo), string(oci.OpMaskedEqual)},
+				Archs:          []string{string(oci.ArchX86_64), string(oci.ArchX86)},
+				SupportedFlags: []string{string(oci.LinuxSeccompFlagLog)},
+			},
+		},
+	}
+}
+
+func TestValidateSeccomp(t *testing.T) {
+	errno := uint(1)
+	for _, tc := range []struct {
+		name     string
+		seccomp  *oci.LinuxSeccomp
+		features *features.Features
+		wantErr  bool
+	}{
+		{
+			name:     "none",
+			features: seccompTestFeatures(false),
+		},
+		{
+			name: "supported",
+			seccomp: &oci.LinuxSeccomp{
+				DefaultAction:   oci.ActErrno,
+				DefaultErrnoRet: &errno,
+				Architectures:   []oci.Arch{oci.ArchX86_64, oci.ArchX86},
+				Flags:           []oci.LinuxSeccompFlag{oci.LinuxSeccompFlagLog},
+				Syscalls: []oci.LinuxSyscall{
+					{
+						Names:  []string{"personality"},
+						Action: oci.ActAllow,
+						Args:   []oci.LinuxSeccompArg{{Index: 0, Value: 8, Op: oci.OpEqualTo}},
+					},
+				},
+			},
+			features: seccompTestFeatures(true),
+		},
+		{
+			name:     "unknown features",
+			seccomp:  &oci.LinuxSeccomp{DefaultAction: oci.ActNotify},
+			features: &features.Features{Linux: &features.Linux{Seccomp: &features.Seccomp{Enabled: &[]bool{true}[0]}}},
+		},
+		{
+			name:     "disabled",
+			seccomp:  &oci.LinuxSeccomp{DefaultAction: oci.ActAllow},
+			features: seccompTestFeatures(false),
+			wantErr:  true,
+		},
+		{
+			name:     "no features",
+			seccomp:  &oci.LinuxSeccomp{DefaultAction: oci.ActAllow},
+			features: &features.Features{},
+			wantErr:  true,
+		},
+		{
+			name:     "action",
+			seccomp:  &oci.LinuxSeccomp{DefaultAction: oci.ActNotify},
+			features: seccompTestFeatures(true),
+			wantErr:  true,
+		},
+		{
+			name:     "errnoRet",
+			seccomp:  &oci.LinuxSeccomp{DefaultAction: oci.ActKillProcess, DefaultErrnoRet: &errno},
+			features: seccompTestFeatures(true),
+			wantErr:  true,
+		},
+		{
+			name: "architecture",
+			seccomp: &oci.LinuxSeccomp{
+				DefaultAction: oci.ActAllow,
+				Architectures: []oci.Arch{oci.ArchAARCH64},
+			},
+			features: seccompTestFeatures(true),
+			wantErr:  true,
+		},
+		{
+			name: "flag",
+			seccomp: &oci.LinuxSeccomp{
+				DefaultAction: oci.ActAllow,
+				Flags:         []oci.LinuxSeccompFlag{oci.LinuxSeccompFlagSpecAllow},
+			},
+			features: seccompTestFeatures(true),
+			wantErr:  true,
+		},
+		{
+			name: "operator",
+			seccomp: &oci.LinuxSeccomp{
+				DefaultAction: oci.ActAllow,
+				Syscalls: []oci.LinuxSyscall{
+					{
+						Names:  []string{"personality"},
+						Action: oci.ActErrno,
+						Args:   []oci.LinuxSeccompArg{{Index: 0, Value: 8, Op: oci.OpGreaterThan}},
+					},
+				},
+			},
+			features: seccompTestFeatures(true),
+			wantErr:  true,
+		},
+		{
+			name: "no names",
+			seccomp: &oci.LinuxSeccomp{
+				DefaultAction: oci.ActAllow,
+				Syscalls:      []oci.LinuxSyscall{{Action: oci.ActErrno}},
+			},
+			features: seccompTestFeatures(true),
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSeccomp(tc.seccomp, tc.features)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %t, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -141,6 +141,10 @@ func setupStandaloneContainerSpec(ctx context.Context, id string, spec *oci.Spec
 		spec.Mounts = append(spec.Mounts, mt)
 	}
 
+	if err := updateBlockDeviceMounts(spec); err != nil {
+		return errors.Wrapf(err, "failed to update block device mounts for container %v", id)
+	}
+
 	// Set cgroup path - check if this is part of a virtual pod (unlikely for standalone)
 	if virtualSandboxID != "" {
 		// Standalone container in virtual pod goes under /containers/virtual-pods/{virtualSandboxID}/{containerID}
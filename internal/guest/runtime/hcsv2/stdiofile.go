@@ -0,0 +1,139 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pathrs "github.com/cyphar/filepath-securejoin/pathrs-lite"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/guest/stdio"
+)
+
+// maxStdioFileBackups is the most rotated files that can be kept for a stdio
+// stream redirected to a file.
+const maxStdioFileBackups = 32
+
+// stdioFileDeniedPrefixes are the pseudo filesystems of the uVM that the stdio
+// of external processes cannot be redirected to.
+var stdioFileDeniedPrefixes = []string{"/proc", "/sys", "/dev"}
+
+// resolveStdioFiles validates the files that the stdout and stderr in
+// `conSettings` are redirected to, and opens the directories they are in,
+// resolved in `rootfs`, the root filesystem of the container running the
+// process, without escaping it. The paths of external processes, with an empty
+// `rootfs`, are paths in the uVM.
+//
+// The caller must close the directories with
+// [stdio.ConnectionSettings.CloseFiles] once the process is started.
+func (h *Host) resolveStdioFiles(rootfs string, conSettings *stdio.ConnectionSettings) (err error) {
+	if conSettings.StdOutFile == nil && conSettings.StdErrFile == nil {
+		return nil
+	}
+	// The security policy only allows or denies access to stdio as a whole,
+	// and files written in the guest are not covered by it.
+	if len(h.securityOptions.PolicyEnforcer.EncodedSecurityPolicy()) > 0 {
+		return gcserr.WrapHresult(errors.New("redirecting stdio to files is denied due to policy"), gcserr.HrErrAccessDenied)
+	}
+
+	if conSettings.StdOutFile != nil && conSettings.StdErrFile != nil &&
+		filepath.Clean(conSettings.StdOutFile.Path) == filepath.Clean(conSettings.StdErrFile.Path) {
+		// each would rotate the file without the other knowing
+		return gcserr.WrapHresult(errors.Errorf("stdout and stderr are redirected to the same file %s", conSettings.StdOutFile.Path), gcserr.HrErrInvalidArg)
+	}
+
+	defer func() {
+		if err != nil {
+			conSettings.CloseFiles()
+		}
+	}()
+	for _, f := range []*stdio.FileSettings{conSettings.StdOutFile, conSettings.StdErrFile} {
+		if f == nil {
+			continue
+		}
+		if err := resolveStdioFile(rootfs, f); err != nil {
+			return gcserr.WrapHresult(err, gcserr.HrErrInvalidArg)
+		}
+	}
+	return nil
+}
+
+func resolveStdioFile(rootfs string, f *stdio.FileSettings) error {
+	if f.MaxSize < 0 {
+		return errors.Errorf("stdio file %s maximum size is out of range", f.Path)
+	}
+	if f.MaxBackups < 0 || f.MaxBackups > maxStdioFileBackups {
+		return errors.Errorf("stdio file %s keeps %d backups, more than the limit of %d", f.Path, f.MaxBackups, maxStdioFileBackups)
+	}
+	if f.MaxBackups > 0 && f.MaxSize == 0 {
+		return errors.Errorf("stdio file %s keeps backups but has no maximum size", f.Path)
+	}
+	if !filepath.IsAbs(f.Path) || strings.ContainsRune(f.Path, 0) {
+		return errors.Errorf("stdio file path %q must be absolute", f.Path)
+	}
+	p := filepath.Clean(f.Path)
+	if p == "/" || strings.HasSuffix(f.Path, "/") {
+		return errors.Errorf("stdio file path %q is a directory", f.Path)
+	}
+
+	// The file is opened, rotated and removed relative to its directory, which
+	// is opened once here, so that a process cannot swap a directory in the
+	// path for a symlink after it was resolved.
+	dir, name := filepath.Split(p)
+	var (
+		d   *os.File
+		err error
+	)
+	if rootfs == "" {
+		var fd int
+		fd, err = unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err == nil {
+			d = os.NewFile(uintptr(fd), dir)
+		}
+	} else {
+		d, err = pathrs.OpenInRoot(rootfs, dir)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to open the directory of stdio file %q", f.Path)
+	}
+	if err := checkStdioFileDir(d, rootfs); err != nil {
+		d.Close()
+		return errors.Wrapf(err, "stdio file path %q", f.Path)
+	}
+	f.Dir = d
+	f.Name = name
+	return nil
+}
+
+// checkStdioFileDir checks that `d` is a directory and, for external processes
+// with an empty `rootfs`, that it is not in one of the pseudo filesystems of
+// the uVM once symlinks are resolved.
+func checkStdioFileDir(d *os.File, rootfs string) error {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(d.Fd()), &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return errors.New("is not in a directory")
+	}
+	if rootfs != "" {
+		return nil
+	}
+	real, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", d.Fd()))
+	if err != nil {
+		return err
+	}
+	for _, prefix := range stdioFileDeniedPrefixes {
+		if real == prefix || strings.HasPrefix(real, prefix+"/") {
+			return errors.Errorf("is in %s", prefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/guest/stdio"
+)
+
+func TestResolveStdioFile(t *testing.T) {
+	rootfs := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(rootfs, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(rootfs, outside), 0755); err != nil {
+		t.Fatal(err)
+	}
+	procLink := filepath.Join(t.TempDir(), "proc")
+	if err := os.Symlink("/proc/self", procLink); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		rootfs string
+		f      stdio.FileSettings
+		want   string
+		fail   bool
+	}{
+		{
+			name:   "container",
+			rootfs: rootfs,
+			f:      stdio.FileSettings{Path: "/logs/../out.log", MaxSize: 1024, MaxBackups: 2},
+			want:   filepath.Join(rootfs, "out.log"),
+		},
+		{
+			name:   "container missing directory",
+			rootfs: rootfs,
+			f:      stdio.FileSettings{Path: "/missing/out.log"},
+			fail:   true,
+		},
+		{
+			name:   "container symlink",
+			rootfs: rootfs,
+			f:      stdio.FileSettings{Path: "/escape/out.log"},
+			// the absolute symlink is resolved in the rootfs
+			want: filepath.Join(rootfs, outside, "out.log"),
+		},
+		{
+			name: "external",
+			f:    stdio.FileSettings{Path: "/run/out.log"},
+			want: "/run/out.log",
+		},
+		{
+			name: "external in proc",
+			f:    stdio.FileSettings{Path: "/proc/1/fd/1"},
+			fail: true,
+		},
+		{
+			name: "external symlink to proc",
+			f:    stdio.FileSettings{Path: filepath.Join(procLink, "out.log")},
+			fail: true,
+		},
+		{
+			name: "relative",
+			f:    stdio.FileSettings{Path: "out.log"},
+			fail: true,
+		},
+		{
+			name: "directory",
+			f:    stdio.FileSettings{Path: "/run/"},
+			fail: true,
+		},
+		{
+			name: "root",
+			f:    stdio.FileSettings{Path: "/"},
+			fail: true,
+		},
+		{
+			name: "backups without size",
+			f:    stdio.FileSettings{Path: "/run/out.log", MaxBackups: 1},
+			fail: true,
+		},
+		{
+			name: "too many backups",
+			f:    stdio.FileSettings{Path: "/run/out.log", MaxSize: 1024, MaxBackups: maxStdioFileBackups + 1},
+			fail: true,
+		},
+		{
+			name: "size overflow",
+			f:    stdio.FileSettings{Path: "/run/out.log", MaxSize: -1},
+			fail: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := tc.f
+			err := resolveStdioFile(tc.rootfs, &f)
+			if tc.fail {
+				if err == nil {
+					t.Fatalf("expected an error for %+v", tc.f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to resolve %+v: %v", tc.f, err)
+			}
+			defer f.Dir.Close()
+			dir, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Dir.Fd()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filepath.Join(dir, f.Name); got != tc.want {
+				t.Fatalf("resolved %q to %q, expected %q", tc.f.Path, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+// maxSwappiness is the largest swappiness of a container's memory cgroup.
+const maxSwappiness = 100
+
+// setSwappiness sets the swappiness of a container's memory to `swappiness` in
+// `resources`. With cgroup v1, runc writes it to the memory.swappiness file of
+// the container's cgroup.
+//
+// Cgroup v2 (`unified`) has no swappiness of its own, so only a swappiness of
+// 0, which keeps the container's memory from being swapped, is supported, by
+// setting the memory.swap.max of the container's cgroup to 0.
+func setSwappiness(resources *oci.LinuxResources, swappiness uint8, unified bool) error {
+	if swappiness > maxSwappiness {
+		return gcserr.WrapHresult(errors.Errorf("memory swappiness %d is out of range [0, %d]", swappiness, maxSwappiness), gcserr.HrErrInvalidArg)
+	}
+
+	if unified {
+		if swappiness != 0 {
+			return gcserr.WrapHresult(errors.Errorf("memory swappiness %d is not supported with cgroup v2, only 0 is", swappiness), gcserr.HrNotImpl)
+		}
+		setUnified(resources, "memory.swap.max", "0")
+		return nil
+	}
+	memory := oci.LinuxMemory{}
+	if resources.Memory != nil {
+		memory = *resources.Memory
+	}
+	s := uint64(swappiness)
+	memory.Swappiness = &s
+	resources.Memory = &memory
+	return nil
+}
@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+)
+
+func TestSetSwappiness(t *testing.T) {
+	limit := int64(1 << 30)
+	for _, tc := range []struct {
+		name       string
+		swappiness uint8
+		unified    bool
+		wantSwap   string
+		wantV1     bool
+		wantHr     gcserr.Hresult
+	}{
+		{name: "v1 zero", swappiness: 0, wantV1: true},
+		{name: "v1 default", swappiness: 60, wantV1: true},
+		{name: "v1 max", swappiness: 100, wantV1: true},
+		{name: "v1 too large", swappiness: 101, wantHr: gcserr.HrErrInvalidArg},
+		{name: "unified zero", swappiness: 0, unified: true, wantSwap: "0"},
+		{name: "unified non-zero", swappiness: 60, unified: true, wantHr: gcserr.HrNotImpl},
+		{name: "unified too large", swappiness: 200, unified: true, wantHr: gcserr.HrErrInvalidArg},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			request := map[string]string{"memory.high": "max"}
+			resources := oci.LinuxResources{Unified: request, Memory: &oci.LinuxMemory{Limit: &limit}}
+			err := setSwappiness(&resources, tc.swappiness, tc.unified)
+			if tc.wantHr != 0 {
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != tc.wantHr {
+					t.Fatalf("expected HRESULT %v, got %v: %v", tc.wantHr, hr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to set swappiness: %v", err)
+			}
+			if got := resources.Unified["memory.swap.max"]; got != tc.wantSwap {
+				t.Fatalf("expected memory.swap.max %q, got %q", tc.wantSwap, got)
+			}
+			if _, ok := request["memory.swap.max"]; ok {
+				t.Fatal("expected the unified resources of the request not to be modified")
+			}
+			if *resources.Memory.Limit != limit {
+				t.Fatalf("expected memory limit to be kept, got %d", *resources.Memory.Limit)
+			}
+			s := resources.Memory.Swappiness
+			if !tc.wantV1 {
+				if s != nil {
+					t.Fatalf("expected no swappiness, got %d", *s)
+				}
+				return
+			}
+			if s == nil || *s != uint64(tc.swappiness) {
+				t.Fatalf("expected swappiness %d, got %v", tc.swappiness, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+// procSysPath is where sysctls are exposed in the guest.
+const procSysPath = "/proc/sys"
+
+// isNamespacedSysctl returns if `name` is a sysctl that is namespaced by the
+// kernel, so setting it only affects the container it is set for.
+func isNamespacedSysctl(name string) bool {
+	return strings.HasPrefix(name, "net.") ||
+		strings.HasPrefix(name, "kernel.msg") ||
+		name == "kernel.sem" ||
+		strings.HasPrefix(name, "fs.mqueue.")
+}
+
+// matchesSysctlPatterns returns if `name` matches any of `patterns`, which are
+// either sysctl names or prefixes ending in `*`.
+func matchesSysctlPatterns(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedUnsafeSysctls returns the sysctl patterns that `spec` may set in
+// addition to namespaced ones. Only privileged containers may set them.
+func allowedUnsafeSysctls(ctx context.Context, spec *oci.Spec) []string {
+	v := spec.Annotations[annotations.LCOWAllowedUnsafeSysctls]
+	if v == "" {
+		return nil
+	}
+	if !isPrivilegedContainerCreationRequest(ctx, spec) {
+		log.G(ctx).WithField(logfields.OCIAnnotation, annotations.LCOWAllowedUnsafeSysctls).
+			Warn("ignoring allowed unsafe sysctls for unprivileged container")
+		return nil
+	}
+	var patterns []string
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// partitionSysctls validates the sysctls in `spec` and removes the ones that
+// are not namespaced from it, returning them.
+//
+// Namespaced sysctls are left in `spec` for runc to apply in the container's
+// namespaces, but the others would be rejected by runc, and instead must be
+// applied to the whole UVM.
+func partitionSysctls(ctx context.Context, id string, spec *oci.Spec) (map[string]string, error) {
+	if spec.Linux == nil || len(spec.Linux.Sysctl) == 0 {
+		return nil, nil
+	}
+
+	allowed := allowedUnsafeSysctls(ctx, spec)
+	var unsafe map[string]string
+	for name, value := range spec.Linux.Sysctl {
+		if isNamespacedSysctl(name) {
+			continue
+		}
+		if !matchesSysctlPatterns(name, allowed) {
+			return nil, errors.Errorf("sysctl %q is not namespaced and cannot be set for container %s: "+
+				"it must be allowed with the %q annotation on a privileged container",
+				name, id, annotations.LCOWAllowedUnsafeSysctls)
+		}
+		if unsafe == nil {
+			unsafe = make(map[string]string)
+		}
+		unsafe[name] = value
+	}
+	for name := range unsafe {
+		delete(spec.Linux.Sysctl, name)
+	}
+	return unsafe, nil
+}
+
+// applySysctls writes `sysctls` under `root`, which is normally [procSysPath].
+func applySysctls(ctx context.Context, root string, sysctls map[string]string) error {
+	for name, value := range sysctls {
+		// sysctl names use '/' or '.' as separators
+		p := filepath.Join(root, strings.ReplaceAll(name, ".", "/"))
+		if !strings.HasPrefix(p, root+"/") {
+			return errors.Errorf("invalid sysctl %q", name)
+		}
+		log.G(ctx).WithFields(logrus.Fields{
+			"sysctl": name,
+			"value":  value,
+		}).Debug("applying sysctl to uVM")
+		// never create files, so unknown sysctls are an error
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set sysctl %q", name)
+		}
+		_, err = f.WriteString(value)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to set sysctl %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+func Test_partitionSysctls(t *testing.T) {
+	ctx := context.Background()
+	namespaced := map[string]string{
+		"net.core.somaxconn":                  "1024",
+		"net.ipv4.ip_unprivileged_port_start": "0",
+		"kernel.msgmax":                       "16384",
+		"kernel.sem":                          "250 32000 32 128",
+		"fs.mqueue.msg_max":                   "20",
+	}
+
+	for _, tc := range []struct {
+		name        string
+		sysctls     map[string]string
+		annotations map[string]string
+		unsafe      map[string]string
+		valid       bool
+	}{
+		{
+			name:  "None",
+			valid: true,
+		},
+		{
+			name:    "Namespaced",
+			sysctls: namespaced,
+			valid:   true,
+		},
+		{
+			name:    "NotNamespaced",
+			sysctls: map[string]string{"vm.max_map_count": "262144"},
+			valid:   false,
+		},
+		{
+			name:    "AllowedUnprivileged",
+			sysctls: map[string]string{"vm.max_map_count": "262144"},
+			annotations: map[string]string{
+				annotations.LCOWAllowedUnsafeSysctls: "vm.max_map_count",
+			},
+			valid: false,
+		},
+		{
+			name:    "AllowedPrivileged",
+			sysctls: map[string]string{"vm.max_map_count": "262144", "kernel.sched_rt_runtime_us": "-1", "net.core.somaxconn": "1024"},
+			annotations: map[string]string{
+				annotations.LCOWPrivileged:           "true",
+				annotations.LCOWAllowedUnsafeSysctls: "vm.max_map_count, kernel.sched_*",
+			},
+			unsafe: map[string]string{"vm.max_map_count": "262144", "kernel.sched_rt_runtime_us": "-1"},
+			valid:  true,
+		},
+		{
+			name:    "NotAllowedPrivileged",
+			sysctls: map[string]string{"vm.swappiness": "0"},
+			annotations: map[string]string{
+				annotations.LCOWPrivileged:           "true",
+				annotations.LCOWAllowedUnsafeSysctls: "vm.max_map_count",
+			},
+			valid: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sysctls := make(map[string]string)
+			for k, v := range tc.sysctls {
+				sysctls[k] = v
+			}
+			spec := &oci.Spec{
+				Annotations: tc.annotations,
+				Linux:       &oci.Linux{Sysctl: sysctls},
+			}
+
+			unsafe, err := partitionSysctls(ctx, "c1", spec)
+			if !tc.valid {
+				if err == nil {
+					t.Fatal("expected sysctls to be invalid")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected sysctls to be valid: %v", err)
+			}
+			if !reflect.DeepEqual(unsafe, tc.unsafe) {
+				t.Fatalf("expected unsafe sysctls %v, got %v", tc.unsafe, unsafe)
+			}
+			for name := range spec.Linux.Sysctl {
+				if !isNamespacedSysctl(name) {
+					t.Fatalf("sysctl %q was not removed from the spec", name)
+				}
+			}
+		})
+	}
+}
+
+func Test_applySysctls(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "vm"), 0755); err != nil {
+		t.Fatalf("failed to create sysctl directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "vm", "max_map_count"), []byte("65530"), 0644); err != nil {
+		t.Fatalf("failed to create sysctl: %v", err)
+	}
+
+	if err := applySysctls(ctx, root, map[string]string{"vm.max_map_count": "262144"}); err != nil {
+		t.Fatalf("failed to apply sysctls: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(root, "vm", "max_map_count"))
+	if err != nil {
+		t.Fatalf("failed to read sysctl: %v", err)
+	}
+	if string(b) != "262144" {
+		t.Fatalf("expected sysctl value 262144, got %q", b)
+	}
+
+	if err := applySysctls(ctx, root, map[string]string{"vm.does_not_exist": "1"}); err == nil {
+		t.Fatal("expected error for unknown sysctl")
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/debug"
+	"github.com/Microsoft/hcsshim/internal/guest/network"
 	"github.com/Microsoft/hcsshim/internal/guest/prot"
 	"github.com/Microsoft/hcsshim/internal/guest/runtime"
 	specGuest "github.com/Microsoft/hcsshim/internal/guest/spec"
@@ -38,6 +41,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/guest/storage/pci"
 	"github.com/Microsoft/hcsshim/internal/guest/storage/plan9"
 	"github.com/Microsoft/hcsshim/internal/guest/storage/pmem"
+	"github.com/Microsoft/hcsshim/internal/guest/storage/quota"
 	"github.com/Microsoft/hcsshim/internal/guest/storage/scsi"
 	"github.com/Microsoft/hcsshim/internal/guest/transport"
 	"github.com/Microsoft/hcsshim/internal/log"
@@ -169,12 +173,32 @@ func (h *Host) GetCreatedContainer(id string) (*Container, error) {
 	return c, nil
 }
 
+// ContainerExistsError is returned when creating a container with the id of a
+// container the guest already has, such as one left behind by a create the host
+// gave up on. The host can delete its state with a DeleteContainerState request
+// before creating it again.
+type ContainerExistsError struct {
+	// ID is the id of the container.
+	ID string
+	// State is the state of the existing container.
+	State string
+}
+
+func (e *ContainerExistsError) Error() string {
+	return fmt.Sprintf("container %s already exists in state %q", e.ID, e.State)
+}
+
+// Hresult returns the HRESULT the error is reported to the host with.
+func (e *ContainerExistsError) Hresult() gcserr.Hresult {
+	return gcserr.HrVmcomputeSystemAlreadyExists
+}
+
 func (h *Host) AddContainer(id string, c *Container) error {
 	h.containersMutex.Lock()
 	defer h.containersMutex.Unlock()
 
-	if _, ok := h.containers[id]; ok {
-		return gcserr.NewHresultError(gcserr.HrVmcomputeSystemAlreadyExists)
+	if existing, ok := h.containers[id]; ok {
+		return &ContainerExistsError{ID: id, State: existing.state()}
 	}
 	h.containers[id] = c
 	return nil
@@ -245,7 +269,16 @@ func setupSandboxLogDir(sandboxID, virtualSandboxID string) error {
 // TODO: unify workload and standalone logic for non-sandbox features (e.g., block devices, huge pages, uVM mounts)
 // TODO(go1.24): use [os.Root] instead of `!strings.HasPrefix(<path>, <root>)`
 
-func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VMHostedContainerSettingsV2) (_ *Container, err error) {
+// CreateContainer creates the container `id` with `settings`. If `hostnameSuffix`
+// is set, it is added to the search domains of the container's /etc/resolv.conf;
+// for a container in a pod, that is the /etc/resolv.conf of the whole pod.
+func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VMHostedContainerSettingsV2, hostnameSuffix string) (_ *Container, err error) {
+	if hostnameSuffix != "" {
+		if err := network.ValidateDNSDomain(hostnameSuffix); err != nil {
+			return nil, gcserr.WrapHresult(errors.Wrap(err, "invalid hostname suffix"), gcserr.HrErrInvalidArg)
+		}
+	}
+
 	criType, isCRI := settings.OCISpecification.Annotations[annotations.KubernetesContainerType]
 
 	// Check for virtual pod annotation
@@ -344,16 +377,21 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 	var namespaceID string
 	// for sandbox container sandboxID is same as container id
 	sandboxID := id
+	// resolvPath is the resolv.conf file written by the GCS for the container
+	var resolvPath string
 	if isCRI {
 		switch criType {
 		case "sandbox":
 			// Capture namespaceID if any because setupSandboxContainerSpec clears the Windows section.
 			namespaceID = specGuest.GetNetworkNamespaceID(settings.OCISpecification)
 
-			err = setupSandboxContainerSpec(ctx, id, settings.OCISpecification)
+			err = setupSandboxContainerSpec(ctx, id, settings.OCISpecification, settings.HostAliases)
 			if err != nil {
 				return nil, err
 			}
+			c.hostAliases = settings.HostAliases
+			// the sandbox container does not mount the pod's resolv.conf itself
+			resolvPath = getSandboxResolvPath(id, virtualPodID)
 			defer func() {
 				if err != nil {
 					_ = os.RemoveAll(settings.OCIBundlePath)
@@ -399,6 +437,11 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 			if err = setupWorkloadContainerSpec(ctx, sid, id, settings.OCISpecification, settings.OCIBundlePath); err != nil {
 				return nil, err
 			}
+			if hostnameSuffix != "" {
+				if resolvPath, err = managedResolvPath(settings.OCISpecification, filepath.Join(specGuest.SandboxRootDir(sid), "resolv.conf")); err != nil {
+					return nil, err
+				}
+			}
 
 			// Add SEV device when security policy is not empty, except when privileged annotation is
 			// set to "true", in which case all UVMs devices are added.
@@ -408,6 +451,9 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 					log.G(ctx).WithError(err).Debug("failed to add SEV device")
 				}
 			}
+			if err := addTPMDevice(ctx, h.securityOptions.PolicyEnforcer, id, settings.OCISpecification); err != nil {
+				return nil, err
+			}
 
 			defer func() {
 				if err != nil {
@@ -431,10 +477,18 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 				_ = os.RemoveAll(settings.OCIBundlePath)
 			}
 		}()
+		if hostnameSuffix != "" {
+			if resolvPath, err = managedResolvPath(settings.OCISpecification, getStandaloneResolvPath(id, virtualPodID)); err != nil {
+				return nil, err
+			}
+		}
 		if err := securitypolicy.ExtendPolicyWithNetworkingMounts(id, h.securityOptions.PolicyEnforcer,
 			settings.OCISpecification); err != nil {
 			return nil, err
 		}
+		if err := addTPMDevice(ctx, h.securityOptions.PolicyEnforcer, id, settings.OCISpecification); err != nil {
+			return nil, err
+		}
 	}
 
 	// don't specialize tee logs (both files and mounts) just for workload containers
@@ -458,26 +512,39 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 		return nil, err
 	}
 
-	envToKeep, capsToKeep, allowStdio, err := h.securityOptions.PolicyEnforcer.EnforceCreateContainerPolicy(
+	privileged := isPrivilegedContainerCreationRequest(ctx, settings.OCISpecification)
+	envToKeep, capsToKeep, allowStdio, err := h.securityOptions.PolicyEnforcer.EnforceCreateContainerPolicyV2(
 		ctx,
-		sandboxID,
 		id,
 		settings.OCISpecification.Process.Args,
 		settings.OCISpecification.Process.Env,
 		settings.OCISpecification.Process.Cwd,
 		settings.OCISpecification.Mounts,
-		isPrivilegedContainerCreationRequest(ctx, settings.OCISpecification),
-		settings.OCISpecification.Process.NoNewPrivileges,
 		user,
-		groups,
-		umask,
-		settings.OCISpecification.Process.Capabilities,
-		seccomp,
+		&securitypolicy.CreateContainerOptions{
+			SandboxID:            sandboxID,
+			Privileged:           &privileged,
+			NoNewPrivileges:      &settings.OCISpecification.Process.NoNewPrivileges,
+			Groups:               groups,
+			Umask:                umask,
+			Capabilities:         settings.OCISpecification.Process.Capabilities,
+			SeccompProfileSHA256: seccomp,
+			Sysctls:              settings.OCISpecification.Linux.Sysctl,
+			HostnameSuffix:       hostnameSuffix,
+		},
 	)
 	if err != nil {
 		return nil, errors.Wrapf(err, "container creation denied due to policy")
 	}
 
+	// only change the resolv.conf, which can be shared by the pod, once policy
+	// allows the suffix
+	if hostnameSuffix != "" {
+		if err = addResolvSearch(resolvPath, hostnameSuffix); err != nil {
+			return nil, err
+		}
+	}
+
 	if !allowStdio {
 		// stdio access isn't allow for this container. Switch to the /dev/null
 		// transport that will eat all input/ouput.
@@ -516,12 +583,72 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 		settings.OCISpecification.Process.Capabilities = capsToKeep
 	}
 
+	if linux := settings.OCISpecification.Linux; linux != nil && linux.Seccomp != nil {
+		// leave the profile to runc if it cannot report its features
+		if f, err := h.rtime.Features(); err != nil {
+			log.G(ctx).WithError(err).Warn("failed to get container runtime features, not validating seccomp profile")
+		} else if err := validateSeccomp(linux.Seccomp, f); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := setRDTClass(ctx, settings.OCISpecification); err != nil {
+		return nil, err
+	}
+
+	if err := applyIOWeightAnnotation(settings.OCISpecification); err != nil {
+		return nil, err
+	}
+
+	readonlyRootfs := specGuest.IsRootReadonly(settings.OCISpecification)
+	if readonlyRootfs {
+		if err := validateReadonlyRootfs(settings.OCISpecification); err != nil {
+			return nil, err
+		}
+	}
+
+	uvmSysctls, err := partitionSysctls(ctx, id, settings.OCISpecification)
+	if err != nil {
+		return nil, err
+	}
+	if err := applySysctls(ctx, procSysPath, uvmSysctls); err != nil {
+		return nil, errors.Wrapf(err, "failed to apply sysctls for container %s", id)
+	}
+
+	propagatedSources, err := setupMountPropagation(ctx, id, settings.OCISpecification)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range propagatedSources {
+		if err := makeMountShared(source); err != nil {
+			return nil, errors.Wrapf(err, "failed to set up mount propagation for container %s", id)
+		}
+	}
+
 	if oci.ParseAnnotationsBool(ctx, settings.OCISpecification.Annotations, annotations.LCOWSecurityPolicyEnv, true) {
 		if err := h.securityOptions.WriteSecurityContextDir(settings.OCISpecification); err != nil {
 			return nil, fmt.Errorf("failed to write security context dir: %w", err)
 		}
 	}
 
+	// Limit the container's scratch usage, so that containers sharing the UVM
+	// scratch cannot fill it for each other.
+	if settings.ScratchQuotaBytes > 0 {
+		if err := checkContainerScratch(settings.ScratchDirPath, id, settings.OCISpecification.Root.Path); err != nil {
+			return nil, errors.Wrapf(err, "failed to set scratch quota for container %s", id)
+		}
+		projectID := quota.ProjectID(id)
+		if err := quota.SetProjectQuota(ctx, settings.ScratchDirPath, projectID, settings.ScratchQuotaBytes); err != nil {
+			return nil, errors.Wrapf(err, "failed to set scratch quota for container %s", id)
+		}
+		c.scratchProjectID = projectID
+		defer func() {
+			if err != nil {
+				_ = quota.ClearProjectQuota(settings.ScratchDirPath, projectID)
+			}
+		}()
+	}
+
 	// Create the BundlePath
 	if err := os.MkdirAll(settings.OCIBundlePath, 0700); err != nil {
 		return nil, errors.Wrapf(err, "failed to create OCIBundlePath: '%s'", settings.OCIBundlePath)
@@ -543,6 +670,15 @@ func (h *Host) CreateContainer(ctx context.Context, id string, settings *prot.VM
 	c.container = con
 	c.initProcess = newProcess(c, settings.OCISpecification.Process, init, uint32(c.container.Pid()), true)
 
+	// The runtime only makes the container's mount of the root filesystem
+	// read-only, so make the overlay itself read-only, now that the mount
+	// points of the container's mounts have been created in it.
+	if readonlyRootfs {
+		if err := remountRootfsReadonly(settings.OCISpecification.Root.Path); err != nil {
+			return nil, errors.Wrapf(err, "failed to make root filesystem of container %s read-only", id)
+		}
+	}
+
 	// Sandbox or standalone, move the networks to the container namespace
 	if criType == "sandbox" || !isCRI {
 		ns, err := getNetworkNamespace(namespaceID)
@@ -604,71 +740,47 @@ func writeSpecToFile(ctx context.Context, configFile string, spec *specs.Spec) e
 	return nil
 }
 
-func (h *Host) modifyHostSettings(ctx context.Context, containerID string, req *guestrequest.ModificationRequest) (retErr error) {
-	switch req.ResourceType {
-	case guestresource.ResourceTypeSCSIDevice:
+// hostSettingsHandler handles a modify settings request for the UVM, of the
+// resource type it is registered for in [hostSettingsHandlers].
+type hostSettingsHandler func(ctx context.Context, h *Host, containerID string, req *guestrequest.ModificationRequest) error
+
+// hostSettingsHandlers are the handlers of the modify settings requests for the
+// UVM, by resource type.
+var hostSettingsHandlers = map[guestrequest.ResourceType]hostSettingsHandler{
+	guestresource.ResourceTypeSCSIDevice: func(ctx context.Context, _ *Host, _ string, req *guestrequest.ModificationRequest) error {
 		return modifySCSIDevice(ctx, req.RequestType, req.Settings.(*guestresource.SCSIDevice))
-	case guestresource.ResourceTypeMappedVirtualDisk:
-		mvd := req.Settings.(*guestresource.LCOWMappedVirtualDisk)
-		// find the actual controller number on the bus and update the incoming request.
-		var cNum uint8
-		cNum, err := scsi.ActualControllerNumber(ctx, mvd.Controller)
-		if err != nil {
-			return err
-		}
-		mvd.Controller = cNum
-		// first we try to update the internal state for read-write attachments.
-		if !mvd.ReadOnly {
-			localCtx, cancel := context.WithTimeout(ctx, time.Second*5)
-			defer cancel()
-			source, err := scsi.GetDevicePath(localCtx, mvd.Controller, mvd.Lun, mvd.Partition)
-			if err != nil {
-				return err
-			}
-			switch req.RequestType {
-			case guestrequest.RequestTypeAdd:
-				if err := h.hostMounts.AddRWDevice(mvd.MountPath, source, mvd.Encrypted); err != nil {
-					return err
-				}
-				defer func() {
-					if retErr != nil {
-						_ = h.hostMounts.RemoveRWDevice(mvd.MountPath, source)
-					}
-				}()
-			case guestrequest.RequestTypeRemove:
-				if err := h.hostMounts.RemoveRWDevice(mvd.MountPath, source); err != nil {
-					return err
-				}
-				defer func() {
-					if retErr != nil {
-						_ = h.hostMounts.AddRWDevice(mvd.MountPath, source, mvd.Encrypted)
-					}
-				}()
-			}
-		}
-		return modifyMappedVirtualDisk(ctx, req.RequestType, mvd, h.securityOptions.PolicyEnforcer)
-	case guestresource.ResourceTypeMappedDirectory:
+	},
+	guestresource.ResourceTypeMappedVirtualDisk: func(ctx context.Context, h *Host, _ string, req *guestrequest.ModificationRequest) error {
+		return h.modifyHostMappedVirtualDisk(ctx, req)
+	},
+	guestresource.ResourceTypeMappedDirectory: func(ctx context.Context, h *Host, _ string, req *guestrequest.ModificationRequest) error {
 		return modifyMappedDirectory(ctx, h.vsock, req.RequestType, req.Settings.(*guestresource.LCOWMappedDirectory), h.securityOptions.PolicyEnforcer)
-	case guestresource.ResourceTypeVPMemDevice:
+	},
+	guestresource.ResourceTypeVPMemDevice: func(ctx context.Context, h *Host, _ string, req *guestrequest.ModificationRequest) error {
 		return modifyMappedVPMemDevice(ctx, req.RequestType, req.Settings.(*guestresource.LCOWMappedVPMemDevice), h.securityOptions.PolicyEnforcer)
-	case guestresource.ResourceTypeCombinedLayers:
+	},
+	guestresource.ResourceTypeCombinedLayers: func(ctx context.Context, h *Host, _ string, req *guestrequest.ModificationRequest) error {
 		cl := req.Settings.(*guestresource.LCOWCombinedLayers)
 		// when cl.ScratchPath == "", we mount overlay as read-only, in which case
 		// we don't really care about scratch encryption, since the host already
 		// knows about the layers and the overlayfs.
 		encryptedScratch := cl.ScratchPath != "" && h.hostMounts.IsEncrypted(cl.ScratchPath)
-		return modifyCombinedLayers(ctx, req.RequestType, req.Settings.(*guestresource.LCOWCombinedLayers), encryptedScratch, h.securityOptions.PolicyEnforcer)
-	case guestresource.ResourceTypeNetwork:
+		return modifyCombinedLayers(ctx, req.RequestType, cl, encryptedScratch, h.securityOptions.PolicyEnforcer)
+	},
+	guestresource.ResourceTypeNetwork: func(ctx context.Context, _ *Host, _ string, req *guestrequest.ModificationRequest) error {
 		return modifyNetwork(ctx, req.RequestType, req.Settings.(*guestresource.LCOWNetworkAdapter))
-	case guestresource.ResourceTypeVPCIDevice:
+	},
+	guestresource.ResourceTypeVPCIDevice: func(ctx context.Context, _ *Host, _ string, req *guestrequest.ModificationRequest) error {
 		return modifyMappedVPCIDevice(ctx, req.RequestType, req.Settings.(*guestresource.LCOWMappedVPCIDevice))
-	case guestresource.ResourceTypeContainerConstraints:
+	},
+	guestresource.ResourceTypeContainerConstraints: func(ctx context.Context, h *Host, containerID string, req *guestrequest.ModificationRequest) error {
 		c, err := h.GetCreatedContainer(containerID)
 		if err != nil {
 			return err
 		}
 		return c.modifyContainerConstraints(ctx, req.RequestType, req.Settings.(*guestresource.LCOWContainerConstraints))
-	case guestresource.ResourceTypeSecurityPolicy:
+	},
+	guestresource.ResourceTypeSecurityPolicy: func(ctx context.Context, h *Host, _ string, req *guestrequest.ModificationRequest) error {
 		r, ok := req.Settings.(*guestresource.ConfidentialOptions)
 		if !ok {
 			return errors.New("the request's settings are not of type ConfidentialOptions")
@@ -677,15 +789,94 @@ func (h *Host) modifyHostSettings(ctx context.Context, containerID string, req *
 			r.EnforcerType,
 			r.EncodedSecurityPolicy,
 			r.EncodedUVMReference)
-	case guestresource.ResourceTypePolicyFragment:
+	},
+	guestresource.ResourceTypePolicyFragment: func(ctx context.Context, h *Host, _ string, req *guestrequest.ModificationRequest) error {
 		r, ok := req.Settings.(*guestresource.SecurityPolicyFragment)
 		if !ok {
 			return errors.New("the request settings are not of type SecurityPolicyFragment")
 		}
 		return h.securityOptions.InjectFragment(ctx, r)
-	default:
-		return errors.Errorf("the ResourceType %q is not supported for UVM", req.ResourceType)
+	},
+}
+
+// containerSettingsHandler handles a modify settings request for container `c`,
+// of the resource type it is registered for in [containerSettingsHandlers].
+type containerSettingsHandler func(ctx context.Context, c *Container, req *guestrequest.ModificationRequest) error
+
+// containerSettingsHandlers are the handlers of the modify settings requests for
+// a container, by resource type.
+var containerSettingsHandlers = map[guestrequest.ResourceType]containerSettingsHandler{
+	guestresource.ResourceTypeContainerConstraints: func(ctx context.Context, c *Container, req *guestrequest.ModificationRequest) error {
+		return c.modifyContainerConstraints(ctx, req.RequestType, req.Settings.(*guestresource.LCOWContainerConstraints))
+	},
+	guestresource.ResourceTypeHostAliases: func(ctx context.Context, c *Container, req *guestrequest.ModificationRequest) error {
+		if req.RequestType != guestrequest.RequestTypeAdd {
+			return errors.Errorf("request type %q is not supported for host aliases", req.RequestType)
+		}
+		return c.addHostAliases(ctx, req.Settings.(*guestresource.LCOWHostAliases).HostAliases)
+	},
+}
+
+// SupportedResourceTypes returns the resource types of the modify settings
+// requests that are handled, for the UVM or for its containers, in order.
+func SupportedResourceTypes() []guestrequest.ResourceType {
+	types := slices.Collect(maps.Keys(hostSettingsHandlers))
+	for rt := range containerSettingsHandlers {
+		if !slices.Contains(types, rt) {
+			types = append(types, rt)
+		}
+	}
+	slices.Sort(types)
+	return types
+}
+
+func (h *Host) modifyHostMappedVirtualDisk(ctx context.Context, req *guestrequest.ModificationRequest) (retErr error) {
+	mvd := req.Settings.(*guestresource.LCOWMappedVirtualDisk)
+	// find the actual controller number on the bus and update the incoming request.
+	var cNum uint8
+	cNum, err := scsi.ActualControllerNumber(ctx, mvd.Controller)
+	if err != nil {
+		return err
+	}
+	mvd.Controller = cNum
+	// first we try to update the internal state for read-write attachments.
+	if !mvd.ReadOnly {
+		localCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+		defer cancel()
+		source, err := scsi.GetDevicePath(localCtx, mvd.Controller, mvd.Lun, mvd.Partition)
+		if err != nil {
+			return err
+		}
+		switch req.RequestType {
+		case guestrequest.RequestTypeAdd:
+			if err := h.hostMounts.AddRWDevice(mvd.MountPath, source, mvd.Encrypted); err != nil {
+				return err
+			}
+			defer func() {
+				if retErr != nil {
+					_ = h.hostMounts.RemoveRWDevice(mvd.MountPath, source)
+				}
+			}()
+		case guestrequest.RequestTypeRemove:
+			if err := h.hostMounts.RemoveRWDevice(mvd.MountPath, source); err != nil {
+				return err
+			}
+			defer func() {
+				if retErr != nil {
+					_ = h.hostMounts.AddRWDevice(mvd.MountPath, source, mvd.Encrypted)
+				}
+			}()
+		}
 	}
+	return modifyMappedVirtualDisk(ctx, req.RequestType, mvd, h.securityOptions.PolicyEnforcer)
+}
+
+func (h *Host) modifyHostSettings(ctx context.Context, containerID string, req *guestrequest.ModificationRequest) error {
+	handler, ok := hostSettingsHandlers[req.ResourceType]
+	if !ok {
+		return gcserr.WrapHresult(errors.Errorf("the ResourceType %q is not supported for UVM", req.ResourceType), gcserr.HrNotImpl)
+	}
+	return handler(ctx, h, containerID, req)
 }
 
 func (h *Host) modifyContainerSettings(ctx context.Context, containerID string, req *guestrequest.ModificationRequest) error {
@@ -694,12 +885,11 @@ func (h *Host) modifyContainerSettings(ctx context.Context, containerID string,
 		return err
 	}
 
-	switch req.ResourceType {
-	case guestresource.ResourceTypeContainerConstraints:
-		return c.modifyContainerConstraints(ctx, req.RequestType, req.Settings.(*guestresource.LCOWContainerConstraints))
-	default:
-		return errors.Errorf("the ResourceType \"%s\" is not supported for containers", req.ResourceType)
+	handler, ok := containerSettingsHandlers[req.ResourceType]
+	if !ok {
+		return gcserr.WrapHresult(errors.Errorf("the ResourceType \"%s\" is not supported for containers", req.ResourceType), gcserr.HrNotImpl)
 	}
+	return handler(ctx, c, req)
 }
 
 func (h *Host) ModifySettings(ctx context.Context, containerID string, req *guestrequest.ModificationRequest) error {
@@ -784,61 +974,122 @@ func (h *Host) ExecProcess(ctx context.Context, containerID string, params prot.
 			params.Environment = processOCIEnvToParam(envToKeep)
 		}
 
+		if params.WorkingDirectoryCreateIfMissing {
+			if err := createExternalWorkingDirectory(ctx, params.WorkingDirectory); err != nil {
+				return pid, err
+			}
+		}
+
+		if err := h.resolveStdioFiles("", &conSettings); err != nil {
+			return pid, err
+		}
+		defer conSettings.CloseFiles()
+
+		var cred *syscall.Credential
+		cred, err = externalProcessCredential("/", params.User, params.DenyRootUser)
+		if err != nil {
+			return pid, err
+		}
+
 		var tport = h.vsock
 		if !allowStdioAccess {
 			tport = h.devNullTransport
 		}
-		pid, err = h.runExternalProcess(ctx, params, conSettings, tport)
+		pid, err = h.runExternalProcess(ctx, params, cred, conSettings, tport)
 	} else if c, err = h.GetCreatedContainer(containerID); err == nil {
 		// We found a V2 container. Treat this as a V2 process.
+		if err := h.resolveStdioFiles(c.spec.Root.Path, &conSettings); err != nil {
+			return pid, err
+		}
+		defer conSettings.CloseFiles()
 		if params.OCIProcess == nil {
 			// We've already done policy enforcement for creating a container so
 			// there's no policy enforcement to do for starting
+			if params.WorkingDirectoryCreateIfMissing {
+				if err := createWorkingDirectory(ctx, c.spec.Root.Path, c.spec.Process.Cwd); err != nil {
+					return pid, err
+				}
+			}
 			pid, err = c.Start(ctx, conSettings)
 		} else {
 			// Windows uses a different field for command, there's no enforcement
 			// around this yet for Windows so this is Linux specific at the moment.
 
-			var envToKeep securitypolicy.EnvList
-			var capsToKeep *specs.LinuxCapabilities
-			var user securitypolicy.IDName
-			var groups []securitypolicy.IDName
-			var umask string
-			var allowStdioAccess bool
-
-			user, groups, umask, err = h.securityOptions.PolicyEnforcer.GetUserInfo(params.OCIProcess, c.spec.Root.Path)
-			if err != nil {
-				return 0, err
+			// Apply the requested capability changes before enforcing policy, so
+			// the policy sees the capabilities the process will run with. The
+			// user is resolved first, since it decides how they are granted.
+			if len(params.ExtraCapabilities) > 0 || len(params.DropCapabilities) > 0 {
+				if err := setExecUser(c.spec, params.OCIProcess); err != nil {
+					return pid, err
+				}
+				privileged := isPrivilegedContainerCreationRequest(ctx, c.spec)
+				if err := applyExecCapabilities(c.spec, params.OCIProcess, privileged, params.ExtraCapabilities, params.DropCapabilities); err != nil {
+					return pid, err
+				}
 			}
 
-			envToKeep, capsToKeep, allowStdioAccess, err = h.securityOptions.PolicyEnforcer.EnforceExecInContainerPolicy(
-				ctx,
-				containerID,
-				params.OCIProcess.Args,
-				params.OCIProcess.Env,
-				params.OCIProcess.Cwd,
-				params.OCIProcess.NoNewPrivileges,
-				user,
-				groups,
-				umask,
-				params.OCIProcess.Capabilities,
-			)
-			if err != nil {
-				return pid, errors.Wrapf(err, "exec in container denied due to policy")
-			}
+			enforcePolicy := func() error {
+				user, groups, umask, err := h.securityOptions.PolicyEnforcer.GetUserInfo(params.OCIProcess, c.spec.Root.Path)
+				if err != nil {
+					return err
+				}
+
+				envToKeep, capsToKeep, allowStdioAccess, err := h.securityOptions.PolicyEnforcer.EnforceExecInContainerPolicy(
+					ctx,
+					containerID,
+					params.OCIProcess.Args,
+					params.OCIProcess.Env,
+					params.OCIProcess.Cwd,
+					params.OCIProcess.NoNewPrivileges,
+					user,
+					groups,
+					umask,
+					params.OCIProcess.Capabilities,
+				)
+				if err != nil {
+					return errors.Wrapf(err, "exec in container denied due to policy")
+				}
 
-			// It makes no sense to allow access if stdio access is denied and the
-			// process requires a terminal.
-			if params.OCIProcess.Terminal && !allowStdioAccess {
-				return pid, errors.New("exec in container of process that requires terminal access denied due to policy not allowing stdio access")
+				// It makes no sense to allow access if stdio access is denied and the
+				// process requires a terminal.
+				if params.OCIProcess.Terminal && !allowStdioAccess {
+					return errors.New("exec in container of process that requires terminal access denied due to policy not allowing stdio access")
+				}
+
+				if envToKeep != nil {
+					params.OCIProcess.Env = envToKeep
+				}
+
+				if capsToKeep != nil {
+					params.OCIProcess.Capabilities = capsToKeep
+				}
+				return nil
+			}
+			if err := enforcePolicy(); err != nil {
+				return pid, err
 			}
 
-			if envToKeep != nil {
-				params.OCIProcess.Env = envToKeep
+			// Look for the executable only once the exec is allowed by policy, and
+			// only if the host asked for a fallback shell. If one is used instead of
+			// the requested executable, the policy is enforced again on it.
+			if len(params.ShellCandidates) > 0 && len(params.OCIProcess.Args) > 0 {
+				requested := params.OCIProcess.Args[0]
+				if err := resolveExecutable(ctx, c.spec.Root.Path, params.OCIProcess, params.ShellCandidates); err != nil {
+					return pid, err
+				}
+				if params.OCIProcess.Args[0] != requested {
+					if err := enforcePolicy(); err != nil {
+						return pid, err
+					}
+				}
 			}
 
-			if capsToKeep != nil {
-				params.OCIProcess.Capabilities = capsToKeep
+			// Create the working directory only once the exec is allowed by
+			// policy, so that a denied exec does not modify the container.
+			if params.WorkingDirectoryCreateIfMissing {
+				if err := createWorkingDirectory(ctx, c.spec.Root.Path, params.OCIProcess.Cwd); err != nil {
+					return pid, err
+				}
 			}
 
 			pid, err = c.ExecProcess(ctx, params.OCIProcess, conSettings)
@@ -848,6 +1099,31 @@ func (h *Host) ExecProcess(ctx context.Context, containerID string, params prot.
 	return pid, err
 }
 
+// RunningProcesses returns the number of processes started by the GCS, in
+// containers or in the uVM, that have not exited.
+func (h *Host) RunningProcesses() int {
+	h.containersMutex.Lock()
+	containers := make([]*Container, 0, len(h.containers))
+	for _, c := range h.containers {
+		containers = append(containers, c)
+	}
+	h.containersMutex.Unlock()
+
+	n := 0
+	for _, c := range containers {
+		n += c.runningProcesses()
+	}
+
+	h.externalProcessesMutex.Lock()
+	defer h.externalProcessesMutex.Unlock()
+	for _, p := range h.externalProcesses {
+		if !p.hasExited() {
+			n++
+		}
+	}
+	return n
+}
+
 func (h *Host) GetExternalProcess(pid int) (Process, error) {
 	h.externalProcessesMutex.Lock()
 	defer h.externalProcessesMutex.Unlock()
@@ -907,6 +1183,12 @@ func (h *Host) GetProperties(ctx context.Context, containerID string, query prot
 				log.G(ctx).WithField("stats", log.Format(ctx, cgroupMetrics)).Trace("queried cgroup statistics")
 			}
 			properties.Metrics = cgroupMetrics
+
+			scratchUsage, err := c.GetScratchUsage(ctx)
+			if err != nil {
+				log.G(ctx).WithError(err).Warn("failed to get scratch usage")
+			}
+			properties.ScratchUsageBytes = scratchUsage
 		default:
 			log.G(ctx).WithField("propertyType", requestedProperty).Warn("unknown or empty property type")
 		}
@@ -915,6 +1197,31 @@ func (h *Host) GetProperties(ctx context.Context, containerID string, query prot
 	return properties, nil
 }
 
+// GetContainersProperties queries the properties `query.PropertyTypes` of each
+// of the containers `query.ContainerIDs`. A container whose properties cannot
+// be queried, such as one that was deleted, is reported in the
+// ContainerErrors of the result rather than failing the query for the others.
+func (h *Host) GetContainersProperties(ctx context.Context, query prot.PropertyQuery) (*prot.PropertiesV2, error) {
+	properties := &prot.PropertiesV2{
+		Containers:      make(map[string]*prot.PropertiesV2, len(query.ContainerIDs)),
+		ContainerErrors: make(map[string]prot.PropertiesError),
+	}
+	containerQuery := prot.PropertyQuery{PropertyTypes: query.PropertyTypes}
+	for _, id := range query.ContainerIDs {
+		p, err := h.GetProperties(ctx, id, containerQuery)
+		if err != nil {
+			hr, herr := gcserr.GetHresult(err)
+			if herr != nil {
+				hr = gcserr.HrFail
+			}
+			properties.ContainerErrors[id] = prot.PropertiesError{Result: int32(hr), Message: err.Error()}
+			continue
+		}
+		properties.Containers[id] = p
+	}
+	return properties, nil
+}
+
 func (h *Host) GetStacks(ctx context.Context) (string, error) {
 	err := h.securityOptions.PolicyEnforcer.EnforceDumpStacksPolicy(ctx)
 	if err != nil {
@@ -928,6 +1235,7 @@ func (h *Host) GetStacks(ctx context.Context) (string, error) {
 func (h *Host) runExternalProcess(
 	ctx context.Context,
 	params prot.ProcessParameters,
+	cred *syscall.Credential,
 	conSettings stdio.ConnectionSettings,
 	tport transport.Transport,
 ) (_ int, err error) {
@@ -1001,6 +1309,12 @@ func (h *Host) runExternalProcess(
 		cmd.Stdout = fileSet.Out
 		cmd.Stderr = fileSet.Err
 	}
+	if cred != nil {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Credential = cred
+	}
 
 	onRemove := func(pid int) {
 		h.externalProcessesMutex.Lock()
@@ -1083,6 +1397,12 @@ func modifyMappedVirtualDisk(
 				EnsureFilesystem: mvd.EnsureFilesystem,
 				Filesystem:       mvd.Filesystem,
 				BlockDev:         mvd.BlockDev,
+				BlockSize:        mvd.BlockSize,
+				FormatOnAttach:   mvd.FormatOnAttach,
+				FormatFilesystem: mvd.FormatFilesystem,
+				ForceFormat:      mvd.ForceFormat,
+				InitializeDisk:   mvd.InitializeDisk,
+				RepairFilesystem: mvd.RepairFilesystem,
 			}
 			return scsi.Mount(mountCtx, mvd.Controller, mvd.Lun, mvd.Partition, mvd.MountPath,
 				mvd.ReadOnly, mvd.Options, config)
@@ -1179,6 +1499,9 @@ func modifyMappedVPMemDevice(ctx context.Context,
 func modifyMappedVPCIDevice(ctx context.Context, rt guestrequest.RequestType, vpciDev *guestresource.LCOWMappedVPCIDevice) error {
 	switch rt {
 	case guestrequest.RequestTypeAdd:
+		if len(vpciDev.Functions) > 0 {
+			return pci.WaitForPCIDeviceFunctionsFromVMBusGUID(ctx, vpciDev.VMBusGUID, vpciDev.Functions)
+		}
 		return pci.WaitForPCIDeviceFromVMBusGUID(ctx, vpciDev.VMBusGUID)
 	default:
 		return newInvalidRequestTypeError(rt)
@@ -1240,6 +1563,12 @@ func modifyNetwork(ctx context.Context, rt guestrequest.RequestType, na *guestre
 		// This code doesnt know if the namespace was already added to the
 		// container or not so it must always call `Sync`.
 		return ns.Sync(ctx)
+	case guestrequest.RequestTypeUpdate:
+		ns, err := getNetworkNamespace(na.NamespaceID)
+		if err != nil {
+			return err
+		}
+		return ns.UpdateAdapterEgressBandwidth(ctx, na.ID, na.EgressBandwidth)
 	case guestrequest.RequestTypeRemove:
 		ns := GetOrAddNetworkNamespace(na.ID)
 		if err := ns.RemoveAdapter(ctx, na.ID); err != nil {
@@ -1293,6 +1622,23 @@ func isPrivilegedContainerCreationRequest(ctx context.Context, spec *specs.Spec)
 	return oci.ParseAnnotationsBool(ctx, spec.Annotations, annotations.LCOWPrivileged, false)
 }
 
+// addTPMDevice adds the TPM devices of the UVM to the spec of a container
+// that requests them with the [annotations.LCOWTPMDevice] annotation, if
+// allowed by the policy. Privileged containers already get all the devices of
+// the UVM.
+func addTPMDevice(ctx context.Context, enforcer securitypolicy.SecurityPolicyEnforcer, id string, spec *specs.Spec) error {
+	if !oci.ParseAnnotationsBool(ctx, spec.Annotations, annotations.LCOWTPMDevice, false) {
+		return nil
+	}
+	if err := enforcer.EnforceTPMAccessPolicy(ctx, id); err != nil {
+		return errors.Wrapf(err, "TPM access denied for container %s", id)
+	}
+	if isPrivilegedContainerCreationRequest(ctx, spec) {
+		return nil
+	}
+	return specGuest.AddDevTPM(ctx, spec)
+}
+
 // Virtual Pod Management Methods
 
 // InitializeVirtualPodSupport sets up the parent cgroup for virtual pods
@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	pathrs "github.com/cyphar/filepath-securejoin/pathrs-lite"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+// createWorkingDirectory creates the working directory `cwd`, and any missing
+// parents, in the rootfs at `rootfs` if it does not exist, in the same way as
+// Docker creates a missing `WORKDIR`.
+//
+// `cwd` is resolved within `rootfs`, so symlinks in the container cannot be
+// used to create directories outside of it. Created directories are owned by
+// root.
+func createWorkingDirectory(ctx context.Context, rootfs, cwd string) error {
+	if cwd == "" {
+		return nil
+	}
+	if !filepath.IsAbs(cwd) {
+		return errors.Errorf("working directory %q is not an absolute path", cwd)
+	}
+	if err := pathrs.MkdirAll(rootfs, cwd, 0755); err != nil {
+		if errors.Is(err, unix.EROFS) {
+			return gcserr.WrapHresult(errors.Wrapf(err, "working directory %q does not exist in the read-only root filesystem", cwd), gcserr.HrErrInvalidArg)
+		}
+		return errors.Wrapf(err, "failed to create working directory %q", cwd)
+	}
+	log.G(ctx).WithFields(logrus.Fields{
+		"rootfs": rootfs,
+		"cwd":    cwd,
+	}).Debug("ensured working directory exists")
+	return nil
+}
+
+// createExternalWorkingDirectory creates the working directory `dir` of a
+// process run in the uVM, and any missing parents, if it does not exist.
+func createExternalWorkingDirectory(ctx context.Context, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create working directory %q", dir)
+	}
+	log.G(ctx).WithField("cwd", dir).Debug("ensured working directory exists")
+	return nil
+}
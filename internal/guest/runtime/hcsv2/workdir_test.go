@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package hcsv2
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateWorkingDirectory(t *testing.T) {
+	ctx := context.Background()
+	rootfs := t.TempDir()
+
+	if err := createWorkingDirectory(ctx, rootfs, "/app/data"); err != nil {
+		t.Fatalf("failed to create working directory: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(rootfs, "app", "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Fatal("expected working directory to be a directory")
+	}
+
+	// an existing working directory is left as is
+	if err := createWorkingDirectory(ctx, rootfs, "/app/data"); err != nil {
+		t.Fatalf("failed to create existing working directory: %v", err)
+	}
+	if err := createWorkingDirectory(ctx, rootfs, ""); err != nil {
+		t.Fatalf("expected no error without a working directory, got: %v", err)
+	}
+	if err := createWorkingDirectory(ctx, rootfs, "app"); err == nil {
+		t.Fatal("expected error for a relative working directory")
+	}
+}
+
+func TestCreateWorkingDirectory_Symlink(t *testing.T) {
+	ctx := context.Background()
+	rootfs := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.Symlink(outside, filepath.Join(rootfs, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	// the symlink resolves to a directory in the rootfs that does not exist
+	_ = createWorkingDirectory(ctx, rootfs, "/escape/work")
+	if _, err := os.Stat(filepath.Join(outside, "work")); !os.IsNotExist(err) {
+		t.Fatalf("expected working directory not to be created outside of the rootfs, got: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(rootfs, outside), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createWorkingDirectory(ctx, rootfs, "/escape/work"); err != nil {
+		t.Fatalf("failed to create working directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "work")); !os.IsNotExist(err) {
+		t.Fatalf("expected working directory not to be created outside of the rootfs, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(rootfs, outside, "work")); err != nil {
+		t.Fatalf("expected working directory to be created within the rootfs: %v", err)
+	}
+}
@@ -114,11 +114,19 @@ func updateHugePageMounts(sbid string, spec *oci.Spec) error {
 	return nil
 }
 
+// updateBlockDeviceMounts resolves `blockdev://` mounts to the raw device node
+// in the UVM and allows the container to access it via the device cgroup.
 func updateBlockDeviceMounts(spec *oci.Spec) error {
 	for i, m := range spec.Mounts {
 		if !strings.HasPrefix(m.Destination, guestpath.BlockDevMountPrefix) {
 			continue
 		}
+		if spec.Linux == nil {
+			spec.Linux = &oci.Linux{}
+		}
+		if spec.Linux.Resources == nil {
+			spec.Linux.Resources = &oci.LinuxResources{}
+		}
 		permissions := "rwm"
 		for _, o := range m.Options {
 			if o == "ro" {
@@ -10,7 +10,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	oci "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
@@ -29,8 +31,20 @@ type container struct {
 	// ownsPidNamespace indicates whether the container's init process is also
 	// the init process for its pid namespace.
 	ownsPidNamespace bool
+	// initExitGracePeriod is how long to wait for the other processes in the
+	// container to exit after the init process exits, before killing them.
+	initExitGracePeriod time.Duration
+
+	// stragglersMu protects stragglers
+	stragglersMu sync.Mutex
+	// stragglers are the processes killed after the init process exited
+	stragglers []runtime.ContainerProcessState
 }
 
+// stragglerPollInterval is how often the processes in a container are checked
+// during the init exit grace period.
+const stragglerPollInterval = 100 * time.Millisecond
+
 var _ runtime.Container = &container{}
 
 func (c *container) ID() string {
@@ -88,6 +102,66 @@ func (c *container) Kill(signal syscall.Signal) error {
 	return c.kill(signal, false)
 }
 
+// Stragglers returns the processes that were killed after the init process
+// exited.
+func (c *container) Stragglers() []runtime.ContainerProcessState {
+	c.stragglersMu.Lock()
+	defer c.stragglersMu.Unlock()
+	return c.stragglers
+}
+
+// reapStragglers waits up to c.initExitGracePeriod for the processes left in
+// the container after the init process exited to exit, and then kills any that
+// remain. The processes that are still running when the grace period ends are
+// recorded as stragglers.
+//
+// If there is no grace period, the remaining processes are killed without being
+// recorded.
+func (c *container) reapStragglers() error {
+	// without a grace period, there is no need to list the remaining processes
+	// (which runs `runc ps`): kill them right away, as the kernel would
+	if c.initExitGracePeriod <= 0 {
+		return c.killAll()
+	}
+
+	l := logrus.WithField(logfields.ContainerID, c.id)
+	deadline := time.Now().Add(c.initExitGracePeriod)
+	var remaining []runtime.ContainerProcessState
+	for {
+		ps, err := c.GetAllProcesses()
+		if err != nil {
+			// kill the container regardless
+			l.WithError(err).Warn("failed to get remaining container processes")
+			break
+		}
+		remaining = remaining[:0]
+		for _, p := range ps {
+			if !p.IsZombie {
+				remaining = append(remaining, p)
+			}
+		}
+		if len(remaining) == 0 {
+			break
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			break
+		}
+		time.Sleep(min(wait, stragglerPollInterval))
+	}
+
+	if len(remaining) > 0 {
+		l.WithFields(logrus.Fields{
+			"count":       len(remaining),
+			"gracePeriod": c.initExitGracePeriod,
+		}).Warn("killing processes remaining after init process exited")
+		c.stragglersMu.Lock()
+		c.stragglers = remaining
+		c.stragglersMu.Unlock()
+	}
+	return c.killAll()
+}
+
 // killAll terminates all processes started in the container.
 //
 // Note: [runc deprecated] the `kill --all` flag starting in v1.2, but, prior to that, it was required
@@ -312,13 +386,13 @@ func (c *container) GetInitProcess() (runtime.Process, error) {
 }
 
 // Wait waits on every non-init process in the container, and then performs a
-// final wait on the init process. The exit code returned is the exit code
+// final wait on the init process. The exit status returned is the exit status
 // acquired from waiting on the init process.
-func (c *container) Wait() (int, error) {
+func (c *container) Wait() (runtime.ExitStatus, error) {
 	entity := logrus.WithField(logfields.ContainerID, c.id)
 	processes, err := c.GetAllProcesses()
 	if err != nil {
-		return -1, err
+		return runtime.ExitStatus{Code: -1}, err
 	}
 	for _, process := range processes {
 		// Only wait on non-init processes that were created with exec.
@@ -331,12 +405,12 @@ func (c *container) Wait() (int, error) {
 			_, _ = c.r.waitOnProcess(process.Pid)
 		}
 	}
-	exitCode, err := c.init.Wait()
+	status, err := c.init.Wait()
 	entity.Debug("runc::container::init process wait completed")
 	if err != nil {
-		return -1, err
+		return runtime.ExitStatus{Code: -1}, err
 	}
-	return exitCode, nil
+	return status, nil
 }
 
 // runExecCommand sets up the arguments for calling runc exec.
@@ -42,15 +42,16 @@ func (p *process) Delete() error {
 	return nil
 }
 
-func (p *process) Wait() (int, error) {
-	exitCode, err := p.c.r.waitOnProcess(p.pid)
+func (p *process) Wait() (runtime.ExitStatus, error) {
+	status, err := p.c.r.waitOnProcess(p.pid)
 
 	l := logrus.WithField(logfields.ContainerID, p.c.id)
 	l.WithField(logfields.ContainerID, p.pid).Debug("process wait completed")
 
 	// If the init process for the container has exited, kill everything else in
-	// the container. Runc uses the devices cgroup of the container to determine
-	// what other processes to kill.
+	// the container, after waiting for the container's grace period. Runc uses
+	// the devices cgroup of the container to determine what other processes to
+	// kill.
 	//
 	// We don't issue the kill if the container owns its own pid namespace,
 	// because in that case the container kernel will kill everything in the pid
@@ -63,7 +64,7 @@ func (p *process) Wait() (int, error) {
 		// If the init process of a pid namespace terminates, the kernel
 		// terminates all other processes in the namespace with SIGKILL. We
 		// simulate the same behavior.
-		if err := p.c.killAll(); err != nil {
+		if err := p.c.reapStragglers(); err != nil {
 			l.WithError(err).Error("failed to terminate container after process wait")
 		}
 	}
@@ -88,5 +89,5 @@ func (p *process) Wait() (int, error) {
 
 	l.WithField(logfields.ProcessID, p.pid).Debug("relay wait completed")
 
-	return exitCode, err
+	return status, err
 }
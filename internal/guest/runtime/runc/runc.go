@@ -10,15 +10,21 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/commonutils"
 	"github.com/Microsoft/hcsshim/internal/guest/runtime"
 	"github.com/Microsoft/hcsshim/internal/guest/stdio"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
 const (
@@ -43,6 +49,10 @@ func NewRuntime(logBasePath string) (runtime.Runtime, error) {
 // the container runtime.
 type runcRuntime struct {
 	runcLogBasePath string
+
+	featuresOnce sync.Once
+	features     *features.Features
+	featuresErr  error
 }
 
 var _ runtime.Runtime = &runcRuntime{}
@@ -93,6 +103,26 @@ func (*runcRuntime) ListContainerStates() ([]runtime.ContainerState, error) {
 	return states, nil
 }
 
+// Features returns the features reported by `runc features`, which are only
+// queried once.
+func (r *runcRuntime) Features() (*features.Features, error) {
+	r.featuresOnce.Do(func() {
+		cmd := runcCommand("features")
+		out, err := cmd.Output()
+		if err != nil {
+			r.featuresErr = errors.Wrap(err, "runc features failed")
+			return
+		}
+		f := &features.Features{}
+		if err := json.Unmarshal(out, f); err != nil {
+			r.featuresErr = errors.Wrap(err, "failed to unmarshal runc features")
+			return
+		}
+		r.features = f
+	})
+	return r.features, r.featuresErr
+}
+
 // getRunningPids gets the pids of all processes which runC recognizes as
 // running.
 func (*runcRuntime) getRunningPids(id string) ([]int, error) {
@@ -135,22 +165,17 @@ func (*runcRuntime) pidMapToProcessStates(pidMap map[int]*runtime.ContainerProce
 	return processStates
 }
 
-// waitOnProcess waits for the process to exit, and returns its exit code.
-func (r *runcRuntime) waitOnProcess(pid int) (int, error) {
+// waitOnProcess waits for the process to exit, and returns its exit status.
+func (r *runcRuntime) waitOnProcess(pid int) (runtime.ExitStatus, error) {
 	process, err := os.FindProcess(pid)
 	if err != nil {
-		return -1, errors.Wrapf(err, "failed to find process %d", pid)
+		return runtime.ExitStatus{Code: -1}, errors.Wrapf(err, "failed to find process %d", pid)
 	}
 	state, err := process.Wait()
 	if err != nil {
-		return -1, errors.Wrapf(err, "failed waiting on process %d", pid)
-	}
-
-	status := state.Sys().(syscall.WaitStatus)
-	if status.Signaled() {
-		return 128 + int(status.Signal()), nil
+		return runtime.ExitStatus{Code: -1}, errors.Wrapf(err, "failed waiting on process %d", pid)
 	}
-	return status.ExitStatus(), nil
+	return runtime.NewExitStatus(state.Sys().(syscall.WaitStatus)), nil
 }
 
 // runCreateCommand sets up the arguments for calling runc create.
@@ -186,6 +211,8 @@ func (r *runcRuntime) runCreateCommand(id string, bundlePath string, stdioSet *s
 		}
 	}
 
+	c.initExitGracePeriod = initExitGracePeriod(spec)
+
 	if spec.Process.Cwd != "/" {
 		cwd := path.Join(bundlePath, "rootfs", spec.Process.Cwd)
 		// Intentionally ignore the error.
@@ -208,6 +235,26 @@ func (r *runcRuntime) runCreateCommand(id string, bundlePath string, stdioSet *s
 	return c, nil
 }
 
+// initExitGracePeriod returns the grace period set by the
+// [annotations.LCOWInitExitGracePeriod] annotation, or 0 if it is not set or
+// is invalid.
+func initExitGracePeriod(spec *oci.Spec) time.Duration {
+	val, ok := spec.Annotations[annotations.LCOWInitExitGracePeriod]
+	if !ok {
+		return 0
+	}
+	secs, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			logfields.OCIAnnotation: annotations.LCOWInitExitGracePeriod,
+			logfields.Value:         val,
+			logfields.ExpectedType:  logfields.Uint32,
+		}).WithError(err).Warning("annotation value could not be parsed")
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
 func ociSpecFromBundle(bundlePath string) (*oci.Spec, error) {
 	configPath := filepath.Join(bundlePath, "config.json")
 	configFile, err := os.Open(configPath)
@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+package runc
+
+import (
+	"testing"
+	"time"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+func Test_initExitGracePeriod(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		expected    time.Duration
+	}{
+		{
+			name:     "NotSet",
+			expected: 0,
+		},
+		{
+			name:        "Seconds",
+			annotations: map[string]string{annotations.LCOWInitExitGracePeriod: "5"},
+			expected:    5 * time.Second,
+		},
+		{
+			name:        "Negative",
+			annotations: map[string]string{annotations.LCOWInitExitGracePeriod: "-1"},
+			expected:    0,
+		},
+		{
+			name:        "Invalid",
+			annotations: map[string]string{annotations.LCOWInitExitGracePeriod: "5s"},
+			expected:    0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &oci.Spec{Annotations: tc.annotations}
+			if d := initExitGracePeriod(spec); d != tc.expected {
+				t.Fatalf("expected grace period %v, got %v", tc.expected, d)
+			}
+		})
+	}
+}
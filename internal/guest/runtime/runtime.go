@@ -11,6 +11,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
 	"github.com/Microsoft/hcsshim/internal/guest/stdio"
 	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
 )
 
 var (
@@ -50,9 +51,33 @@ type StdioPipes struct {
 	Err io.ReadCloser
 }
 
+// ExitStatus is the exit status of a process.
+type ExitStatus struct {
+	// Code is the exit code of the process. If the process was terminated by a
+	// signal, it is 128 plus the signal number, matching shells and runc.
+	Code int
+	// Signal is the signal that terminated the process, or 0 if the process
+	// exited normally.
+	Signal syscall.Signal
+	// CoreDumped is true if the process dumped core when it was terminated.
+	CoreDumped bool
+}
+
+// NewExitStatus returns the exit status of a process from its wait status.
+func NewExitStatus(ws syscall.WaitStatus) ExitStatus {
+	if ws.Signaled() {
+		return ExitStatus{
+			Code:       128 + int(ws.Signal()),
+			Signal:     ws.Signal(),
+			CoreDumped: ws.CoreDump(),
+		}
+	}
+	return ExitStatus{Code: ws.ExitStatus()}
+}
+
 // Process is an interface to manipulate process state.
 type Process interface {
-	Wait() (int, error)
+	Wait() (ExitStatus, error)
 	Pid() int
 	Delete() error
 	Tty() *stdio.TtyRelay
@@ -73,6 +98,11 @@ type Container interface {
 	GetRunningProcesses() ([]ContainerProcessState, error)
 	GetAllProcesses() ([]ContainerProcessState, error)
 	GetInitProcess() (Process, error)
+	// Stragglers returns the processes that were still running in the container
+	// when the grace period after its init process exited ended, and that were
+	// killed as a result. It is only valid after the init process has been waited on,
+	// and is empty if the container has no grace period.
+	Stragglers() []ContainerProcessState
 	Update(resources interface{}) error
 }
 
@@ -81,4 +111,7 @@ type Container interface {
 type Runtime interface {
 	CreateContainer(id string, bundlePath string, stdioSet *stdio.ConnectionSet) (c Container, err error)
 	ListContainerStates() ([]ContainerState, error)
+	// Features returns the optional features of the OCI spec the runtime
+	// supports.
+	Features() (*features.Features, error)
 }
@@ -0,0 +1,95 @@
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// waitStatus returns the wait status of a process that exited with `code`, or
+// that was terminated by `signal`.
+func waitStatus(code int, signal syscall.Signal, dumped bool) syscall.WaitStatus {
+	if signal == 0 {
+		return syscall.WaitStatus(code << 8)
+	}
+	ws := syscall.WaitStatus(signal)
+	if dumped {
+		ws |= 0x80
+	}
+	return ws
+}
+
+func TestNewExitStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		ws       syscall.WaitStatus
+		expected ExitStatus
+	}{
+		{
+			name:     "Success",
+			ws:       waitStatus(0, 0, false),
+			expected: ExitStatus{},
+		},
+		{
+			name:     "Exited",
+			ws:       waitStatus(3, 0, false),
+			expected: ExitStatus{Code: 3},
+		},
+		{
+			name:     "ExitedHighCode",
+			ws:       waitStatus(137, 0, false),
+			expected: ExitStatus{Code: 137},
+		},
+		{
+			name:     "SIGKILL",
+			ws:       waitStatus(0, syscall.SIGKILL, false),
+			expected: ExitStatus{Code: 137, Signal: syscall.SIGKILL},
+		},
+		{
+			name:     "SIGSEGVCoreDumped",
+			ws:       waitStatus(0, syscall.SIGSEGV, true),
+			expected: ExitStatus{Code: 139, Signal: syscall.SIGSEGV, CoreDumped: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if s := NewExitStatus(tc.ws); s != tc.expected {
+				t.Fatalf("expected exit status %+v, got %+v", tc.expected, s)
+			}
+		})
+	}
+}
+
+func TestNewExitStatus_Process(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skipf("could not find sh: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		script   string
+		expected ExitStatus
+	}{
+		{
+			name:     "Exited",
+			script:   "exit 3",
+			expected: ExitStatus{Code: 3},
+		},
+		{
+			name:     "SIGKILL",
+			script:   "kill -KILL $$",
+			expected: ExitStatus{Code: 137, Signal: syscall.SIGKILL},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := exec.Command("sh", "-c", tc.script)
+			_ = cmd.Run()
+			s := NewExitStatus(cmd.ProcessState.Sys().(syscall.WaitStatus))
+			if s != tc.expected {
+				t.Fatalf("expected exit status %+v, got %+v", tc.expected, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package seccomp contains the seccomp filter the GCS can apply to itself, and
+// so to every process it launches, to deny syscalls that nothing in the uVM
+// should make.
+package seccomp
@@ -0,0 +1,211 @@
+//go:build linux
+// +build linux
+
+package seccomp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Mode selects the syscalls the seccomp filter denies.
+//
+// A seccomp filter is inherited by every process forked from the process it is
+// applied to and cannot be removed, so processes the GCS launches, including
+// container workloads, are always constrained by the filter applied to the GCS.
+type Mode string
+
+const (
+	// ModeNone does not apply a filter.
+	ModeNone Mode = "none"
+	// ModeKernel denies syscalls that modify the uVM's kernel or hardware, which
+	// neither the GCS nor anything it launches needs.
+	ModeKernel Mode = "kernel"
+	// ModeAll additionally denies syscalls that inspect or modify other
+	// processes, such as ptrace, which also denies them to container workloads.
+	ModeAll Mode = "all"
+)
+
+// ParseMode returns the [Mode] named `s`. An empty string is [ModeNone].
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return ModeNone, nil
+	case ModeNone, ModeKernel, ModeAll:
+		return m, nil
+	default:
+		return "", errors.Errorf("unknown seccomp mode %q: must be one of %q, %q, or %q", s, ModeNone, ModeKernel, ModeAll)
+	}
+}
+
+// kernelDenied are the syscalls denied by [ModeKernel].
+//
+// Loading kernel modules (needed by install-drivers) and rebooting (needed to
+// shut down the uVM) are deliberately not denied.
+var kernelDenied = []string{
+	"acct",
+	"ioperm",
+	"iopl",
+	"kexec_file_load",
+	"kexec_load",
+	"open_by_handle_at",
+	"swapoff",
+	"swapon",
+	"uselib",
+}
+
+// allDenied are the syscalls denied by [ModeAll], in addition to [kernelDenied].
+var allDenied = []string{
+	"kcmp",
+	"perf_event_open",
+	"process_vm_readv",
+	"process_vm_writev",
+	"ptrace",
+	"userfaultfd",
+}
+
+// arch is the syscall table of an architecture the filter handles.
+type arch struct {
+	// audit is the AUDIT_ARCH_* value of the architecture.
+	audit uint32
+	// syscalls maps the names of denied syscalls to their numbers. Syscalls that
+	// do not exist on the architecture are omitted.
+	syscalls map[string]uint32
+	// x32 is set if syscalls with the x32 ABI bit set are also reported as this
+	// architecture. They are denied entirely.
+	x32 bool
+}
+
+const x32SyscallBit = 0x40000000
+
+// offsets of fields in struct seccomp_data.
+const (
+	offsetNr   = 0
+	offsetArch = 4
+)
+
+// Denied returns the names of the syscalls `mode` denies.
+func Denied(mode Mode) []string {
+	switch mode {
+	case ModeKernel:
+		return kernelDenied
+	case ModeAll:
+		return append(append([]string{}, kernelDenied...), allDenied...)
+	default:
+		return nil
+	}
+}
+
+// Filter returns the classic BPF program of the seccomp filter for `mode`.
+//
+// The filter returns EPERM for denied syscalls, and ENOSYS for any syscall made
+// with an architecture or ABI it does not handle.
+func Filter(mode Mode) ([]bpf.RawInstruction, error) {
+	denied := Denied(mode)
+	if len(denied) == 0 {
+		return nil, errors.Errorf("seccomp mode %q has no filter", mode)
+	}
+	if len(archs) == 0 {
+		return nil, errors.New("seccomp filter is not supported on this architecture")
+	}
+
+	prog := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: offsetArch, Size: 4},
+	}
+	for _, a := range archs {
+		block := archBlock(a, denied)
+		prog = append(prog, bpf.JumpIf{Cond: bpf.JumpEqual, Val: a.audit, SkipFalse: uint8(len(block))})
+		prog = append(prog, block...)
+	}
+	prog = append(prog, bpf.RetConstant{Val: unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)})
+	return bpf.Assemble(prog)
+}
+
+// archBlock returns the instructions that check the syscall number against the
+// denied syscalls of `a`. The block always returns.
+func archBlock(a arch, denied []string) []bpf.Instruction {
+	var nrs []uint32
+	for _, name := range denied {
+		if nr, ok := a.syscalls[name]; ok {
+			nrs = append(nrs, nr)
+		}
+	}
+
+	// layout: load nr, [x32 check], checks..., allow, EPERM, [ENOSYS]
+	block := []bpf.Instruction{bpf.LoadAbsolute{Off: offsetNr, Size: 4}}
+	eperm := 1 + len(nrs) + 1
+	if a.x32 {
+		eperm++
+		enosys := eperm + 1
+		block = append(block, bpf.JumpIf{Cond: bpf.JumpGreaterOrEqual, Val: x32SyscallBit, SkipTrue: uint8(enosys - len(block) - 1)})
+	}
+	for _, nr := range nrs {
+		block = append(block, bpf.JumpIf{Cond: bpf.JumpEqual, Val: nr, SkipTrue: uint8(eperm - len(block) - 1)})
+	}
+	block = append(block,
+		bpf.RetConstant{Val: unix.SECCOMP_RET_ALLOW},
+		bpf.RetConstant{Val: unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)},
+	)
+	if a.x32 {
+		block = append(block, bpf.RetConstant{Val: unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)})
+	}
+	return block
+}
+
+// Apply applies the seccomp filter for `mode` to all threads of the current
+// process. It does nothing for [ModeNone].
+//
+// The caller must have CAP_SYS_ADMIN or have set no_new_privs. The GCS does not
+// set no_new_privs, since it would be inherited by container workloads and
+// prevent them from gaining privileges through setuid binaries.
+func Apply(mode Mode) error {
+	if mode == ModeNone {
+		return nil
+	}
+	raw, err := Filter(mode)
+	if err != nil {
+		return err
+	}
+	filter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_SECCOMP,
+		unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_TSYNC,
+		uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return errors.Wrapf(errno, "failed to apply seccomp filter for mode %q", mode)
+	}
+	return nil
+}
+
+// Active returns if a seccomp filter is applied to the current process.
+func Active() (bool, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "Seccomp:"); ok {
+			return strings.TrimSpace(v) == fmt.Sprint(unix.SECCOMP_MODE_FILTER), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return false, err
+	}
+	return false, errors.New("seccomp status not found in /proc/self/status")
+}
@@ -0,0 +1,51 @@
+//go:build linux
+// +build linux
+
+package seccomp
+
+import "golang.org/x/sys/unix"
+
+var archs = []arch{
+	{
+		audit: unix.AUDIT_ARCH_X86_64,
+		x32:   true,
+		syscalls: map[string]uint32{
+			"acct":              unix.SYS_ACCT,
+			"ioperm":            unix.SYS_IOPERM,
+			"iopl":              unix.SYS_IOPL,
+			"kcmp":              unix.SYS_KCMP,
+			"kexec_file_load":   unix.SYS_KEXEC_FILE_LOAD,
+			"kexec_load":        unix.SYS_KEXEC_LOAD,
+			"open_by_handle_at": unix.SYS_OPEN_BY_HANDLE_AT,
+			"perf_event_open":   unix.SYS_PERF_EVENT_OPEN,
+			"process_vm_readv":  unix.SYS_PROCESS_VM_READV,
+			"process_vm_writev": unix.SYS_PROCESS_VM_WRITEV,
+			"ptrace":            unix.SYS_PTRACE,
+			"swapoff":           unix.SYS_SWAPOFF,
+			"swapon":            unix.SYS_SWAPON,
+			"uselib":            unix.SYS_USELIB,
+			"userfaultfd":       unix.SYS_USERFAULTFD,
+		},
+	},
+	{
+		// 32-bit processes, which use the i386 syscall table. The numbers are not
+		// available from x/sys/unix on amd64.
+		audit: unix.AUDIT_ARCH_I386,
+		syscalls: map[string]uint32{
+			"acct":              51,
+			"ioperm":            101,
+			"iopl":              110,
+			"kcmp":              349,
+			"kexec_load":        283,
+			"open_by_handle_at": 342,
+			"perf_event_open":   336,
+			"process_vm_readv":  347,
+			"process_vm_writev": 348,
+			"ptrace":            26,
+			"swapoff":           115,
+			"swapon":            87,
+			"uselib":            86,
+			"userfaultfd":       374,
+		},
+	},
+}
@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+package seccomp
+
+import "golang.org/x/sys/unix"
+
+var archs = []arch{
+	{
+		audit: unix.AUDIT_ARCH_AARCH64,
+		syscalls: map[string]uint32{
+			"acct":              unix.SYS_ACCT,
+			"kcmp":              unix.SYS_KCMP,
+			"kexec_file_load":   unix.SYS_KEXEC_FILE_LOAD,
+			"kexec_load":        unix.SYS_KEXEC_LOAD,
+			"open_by_handle_at": unix.SYS_OPEN_BY_HANDLE_AT,
+			"perf_event_open":   unix.SYS_PERF_EVENT_OPEN,
+			"process_vm_readv":  unix.SYS_PROCESS_VM_READV,
+			"process_vm_writev": unix.SYS_PROCESS_VM_WRITEV,
+			"ptrace":            unix.SYS_PTRACE,
+			"swapoff":           unix.SYS_SWAPOFF,
+			"swapon":            unix.SYS_SWAPON,
+			"userfaultfd":       unix.SYS_USERFAULTFD,
+		},
+	},
+}
@@ -0,0 +1,6 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package seccomp
+
+var archs []arch
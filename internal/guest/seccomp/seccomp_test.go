@@ -0,0 +1,145 @@
+//go:build linux
+// +build linux
+
+package seccomp
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseMode(t *testing.T) {
+	for s, expected := range map[string]Mode{
+		"":       ModeNone,
+		"none":   ModeNone,
+		"kernel": ModeKernel,
+		"all":    ModeAll,
+	} {
+		m, err := ParseMode(s)
+		if err != nil {
+			t.Fatalf("failed to parse mode %q: %v", s, err)
+		}
+		if m != expected {
+			t.Fatalf("expected mode %q, got %q", expected, m)
+		}
+	}
+	if _, err := ParseMode("strict"); err == nil {
+		t.Fatal("expected unknown mode to fail to parse")
+	}
+}
+
+// runFilter runs the filter for `mode` on a syscall, returning the seccomp
+// action. The bpf VM loads big endian words, so the seccomp_data is encoded as
+// big endian rather than in host order.
+func runFilter(t *testing.T, mode Mode, audit, nr uint32) uint32 {
+	t.Helper()
+	raw, err := Filter(mode)
+	if err != nil {
+		t.Fatalf("failed to build filter: %v", err)
+	}
+	prog, ok := bpf.Disassemble(raw)
+	if !ok {
+		t.Fatal("failed to disassemble filter")
+	}
+	vm, err := bpf.NewVM(prog)
+	if err != nil {
+		t.Fatalf("invalid filter: %v", err)
+	}
+	data := make([]byte, 64)
+	binary.BigEndian.PutUint32(data[offsetNr:], nr)
+	binary.BigEndian.PutUint32(data[offsetArch:], audit)
+	action, err := vm.Run(data)
+	if err != nil {
+		t.Fatalf("failed to run filter: %v", err)
+	}
+	return uint32(action)
+}
+
+func TestFilter(t *testing.T) {
+	if len(archs) == 0 {
+		t.Skip("seccomp filter is not supported on this architecture")
+	}
+	const (
+		allow  = unix.SECCOMP_RET_ALLOW
+		eperm  = unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+		enosys = unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)
+	)
+
+	for _, a := range archs {
+		for _, tc := range []struct {
+			syscall  string
+			mode     Mode
+			expected uint32
+		}{
+			{syscall: "kexec_load", mode: ModeKernel, expected: eperm},
+			{syscall: "kexec_load", mode: ModeAll, expected: eperm},
+			{syscall: "ptrace", mode: ModeKernel, expected: allow},
+			{syscall: "ptrace", mode: ModeAll, expected: eperm},
+		} {
+			if got := runFilter(t, tc.mode, a.audit, a.syscalls[tc.syscall]); got != tc.expected {
+				t.Errorf("arch %#x mode %q: expected action %#x for %s, got %#x", a.audit, tc.mode, tc.expected, tc.syscall, got)
+			}
+		}
+		if a.x32 {
+			if got := runFilter(t, ModeKernel, a.audit, x32SyscallBit|unix.SYS_MOUNT); got != enosys {
+				t.Errorf("expected x32 syscalls to be denied, got %#x", got)
+			}
+		}
+	}
+
+	// syscalls the GCS needs are allowed; the first arch is always the native one
+	for _, nr := range []uint32{unix.SYS_MOUNT, unix.SYS_INIT_MODULE, unix.SYS_REBOOT, unix.SYS_SOCKET} {
+		if got := runFilter(t, ModeAll, archs[0].audit, nr); got != allow {
+			t.Errorf("expected syscall %d to be allowed, got %#x", nr, got)
+		}
+	}
+	if got := runFilter(t, ModeKernel, 0xdeadbeef, unix.SYS_MOUNT); got != enosys {
+		t.Errorf("expected syscalls of unknown architectures to be denied, got %#x", got)
+	}
+}
+
+const applyTestEnv = "HCSSHIM_SECCOMP_APPLY_TEST"
+
+// TestApply applies the filter in a child process, since it cannot be removed.
+func TestApply(t *testing.T) {
+	if len(archs) == 0 {
+		t.Skip("seccomp filter is not supported on this architecture")
+	}
+	if os.Getenv(applyTestEnv) == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestApply$", "-test.v")
+		cmd.Env = append(os.Environ(), applyTestEnv+"=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("child failed: %v\n%s", err, out)
+		}
+		return
+	}
+
+	// an unprivileged process must set no_new_privs to apply a filter
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		t.Fatalf("failed to set no_new_privs: %v", err)
+	}
+	if err := Apply(ModeAll); err != nil {
+		t.Fatalf("failed to apply filter: %v", err)
+	}
+	if active, err := Active(); err != nil || !active {
+		t.Fatalf("expected seccomp filter to be active, got %t: %v", active, err)
+	}
+
+	pid := uintptr(os.Getpid())
+	if _, _, errno := unix.Syscall6(unix.SYS_KCMP, pid, pid, 0 /* KCMP_FILE */, 0, 0, 0); errno != unix.EPERM {
+		t.Fatalf("expected kcmp to be denied with EPERM, got %v", errno)
+	}
+	// the filter applies to all threads and child processes
+	if out, err := exec.Command("true").CombinedOutput(); err != nil {
+		t.Fatalf("failed to run child process under filter: %v: %s", err, out)
+	}
+	b, err := os.ReadFile("/proc/self/status")
+	if err != nil || len(b) == 0 {
+		t.Fatalf("failed to read status under filter: %v", err)
+	}
+}
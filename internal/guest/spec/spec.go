@@ -519,6 +519,23 @@ func AddDevSev(ctx context.Context, spec *oci.Spec) error {
 	return nil
 }
 
+// AddDevTPM adds the TPM devices of the UVM to the spec. /dev/tpm0 must be
+// present, which requires the UVM to have a virtual TPM, while the in-kernel
+// resource manager /dev/tpmrm0 is only added if present.
+func AddDevTPM(ctx context.Context, spec *oci.Spec) error {
+	devTPM, err := devices.DeviceFromPath("/dev/tpm0", "rwm")
+	if err != nil {
+		return errors.Wrap(err, "failed to add TPM device to spec")
+	}
+	AddLinuxDeviceToSpec(ctx, devTPM, spec, true)
+	if devTPMRM, err := devices.DeviceFromPath("/dev/tpmrm0", "rwm"); err == nil {
+		AddLinuxDeviceToSpec(ctx, devTPMRM, spec, true)
+	} else {
+		log.G(ctx).WithError(err).Debug("TPM resource manager device not present")
+	}
+	return nil
+}
+
 // devShmMountWithSize returns a /dev/shm device mount with size set to
 // `sizeString` if it represents a valid size in KB, returns error otherwise.
 func devShmMountWithSize(sizeString string) (*oci.Mount, error) {
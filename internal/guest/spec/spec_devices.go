@@ -46,13 +46,14 @@ func AddAssignedDevice(ctx context.Context, spec *oci.Spec) error {
 	for _, d := range spec.Windows.Devices {
 		switch d.IDType {
 		case vpciDeviceIDTypeLegacy, vpciDeviceIDType:
-			// validate that the device is available
-			fullPCIPath, err := pci.FindDeviceFullPath(ctx, d.ID)
+			// validate that the device is available, including each function of
+			// a multi-function device
+			fullPCIPaths, err := pci.FindDeviceFullPaths(ctx, d.ID)
 			if err != nil {
 				return errors.Wrapf(err, "failed to find device pci path for device %v", d)
 			}
-			// find the device nodes that link to the pci path we just got
-			devs, err := devicePathsFromPCIPath(ctx, fullPCIPath)
+			// find the device nodes that link to the pci paths we just got
+			devs, err := devicePathsFromPCIPaths(ctx, fullPCIPaths)
 			if err != nil {
 				return errors.Wrapf(err, "failed to find dev node for device %v", d)
 			}
@@ -71,13 +72,18 @@ func AddAssignedDevice(ctx context.Context, spec *oci.Spec) error {
 	return nil
 }
 
-// devicePathsFromPCIPath takes a sysfs bus path to the pci device assigned into the guest
-// and attempts to find the dev nodes in the guest that map to it.
-func devicePathsFromPCIPath(ctx context.Context, pciPath string) ([]*config.Device, error) {
-	// get the full pci path to make sure that it's the final path
-	pciFullPath, err := filepath.EvalSymlinks(pciPath)
-	if err != nil {
-		return nil, err
+// devicePathsFromPCIPaths takes the sysfs bus paths to the functions of the pci device
+// assigned into the guest and attempts to find the dev nodes in the guest that map to them.
+// Not every function of a multi-function device needs to have dev nodes.
+func devicePathsFromPCIPaths(ctx context.Context, pciPaths []string) ([]*config.Device, error) {
+	// get the full pci paths to make sure that they're the final paths
+	pciFullPaths := make([]string, 0, len(pciPaths))
+	for _, p := range pciPaths {
+		pciFullPath, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return nil, err
+		}
+		pciFullPaths = append(pciFullPaths, pciFullPath)
 	}
 
 	for {
@@ -121,8 +127,11 @@ func devicePathsFromPCIPath(ctx context.Context, pciPath string) ([]*config.Devi
 				log.G(ctx).WithError(err).Debugf("failed to find sysfs path for device %s", d.Path)
 				continue
 			}
-			if strings.HasPrefix(sysfsFullPath, pciFullPath) {
-				out = append(out, d)
+			for _, pciFullPath := range pciFullPaths {
+				if strings.HasPrefix(sysfsFullPath, pciFullPath) {
+					out = append(out, d)
+					break
+				}
 			}
 		}
 
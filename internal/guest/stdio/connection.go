@@ -4,6 +4,8 @@
 package stdio
 
 import (
+	"os"
+
 	"github.com/pkg/errors"
 
 	"github.com/Microsoft/hcsshim/internal/guest/transport"
@@ -15,11 +17,47 @@ type ConnectionSettings struct {
 	StdIn  *uint32
 	StdOut *uint32
 	StdErr *uint32
+	// StdOutFile and StdErrFile are files to write stdout and stderr to
+	// instead of connecting the transport. They take precedence over the
+	// StdOut and StdErr ports.
+	StdOutFile *FileSettings
+	StdErrFile *FileSettings
+}
+
+// FileSettings describe a file to write a stdio stream to.
+type FileSettings struct {
+	// Path is the path of the file, which is created if it does not exist and
+	// appended to otherwise.
+	Path string
+	// Dir is the directory the file is in, as resolved by the host, and Name
+	// is the name of the file in it. The file is only ever opened, rotated and
+	// removed relative to Dir.
+	Dir  *os.File
+	Name string
+	// MaxSize is the size in bytes past which the file is rotated. Zero
+	// disables rotation.
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept, with the suffixes ".1"
+	// (the newest) to ".MaxBackups" (the oldest).
+	MaxBackups int
+}
+
+// CloseFiles closes the directories of the files that stdout and stderr are
+// redirected to. The connections to the files keep their own references to
+// the directories.
+func (s *ConnectionSettings) CloseFiles() {
+	for _, f := range []*FileSettings{s.StdOutFile, s.StdErrFile} {
+		if f != nil && f.Dir != nil {
+			f.Dir.Close()
+			f.Dir = nil
+		}
+	}
 }
 
 // Connect returns new transport.Connection instances, one for each stdio pipe
 // to be used. If CreateStd*Pipe for a given pipe is false, the given Connection
-// is set to nil.
+// is set to nil. Streams redirected to files are written to the files rather
+// than the transport.
 func Connect(tport transport.Transport, settings ConnectionSettings) (_ *ConnectionSet, err error) {
 	connSet := &ConnectionSet{}
 	defer func() {
@@ -34,14 +72,26 @@ func Connect(tport transport.Transport, settings ConnectionSettings) (_ *Connect
 		}
 		connSet.In = transport.NewLogConnection(c, *settings.StdIn)
 	}
-	if settings.StdOut != nil {
+	if settings.StdOutFile != nil {
+		c, err := transport.NewFileConnection(settings.StdOutFile.Dir, settings.StdOutFile.Name, settings.StdOutFile.MaxSize, settings.StdOutFile.MaxBackups)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed creating stdout file Connection")
+		}
+		connSet.Out = c
+	} else if settings.StdOut != nil {
 		c, err := tport.Dial(*settings.StdOut)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed creating stdout Connection")
 		}
 		connSet.Out = transport.NewLogConnection(c, *settings.StdOut)
 	}
-	if settings.StdErr != nil {
+	if settings.StdErrFile != nil {
+		c, err := transport.NewFileConnection(settings.StdErrFile.Dir, settings.StdErrFile.Name, settings.StdErrFile.MaxSize, settings.StdErrFile.MaxBackups)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed creating stderr file Connection")
+		}
+		connSet.Err = c
+	} else if settings.StdErr != nil {
 		c, err := tport.Dial(*settings.StdErr)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed creating stderr Connection")
@@ -214,6 +214,14 @@ func (pr *PipeRelay) Start() {
 					logrus.ErrorKey: err,
 					"bytes":         n,
 				}).Error("opengcs::PipeRelay::Start - error copying stdin to pipe")
+				// The process closed its stdin. Shut down the write end of the
+				// socket, which is otherwise unused, so that the host reads EOF
+				// and stops writing stdin.
+				if err := pr.s.In.CloseWrite(); err != nil {
+					logrus.WithFields(logrus.Fields{
+						logrus.ErrorKey: err,
+					}).Error("opengcs::PipeRelay::Start - error shutting down stdin socket")
+				}
 			}
 			if err := pr.pipes[1].Close(); err != nil {
 				logrus.WithFields(logrus.Fields{
@@ -337,6 +345,14 @@ func (r *TtyRelay) Start() {
 				logrus.WithFields(logrus.Fields{
 					logrus.ErrorKey: err,
 				}).Error("opengcs::TtyRelay::Start - error copying stdin to pty")
+				// The pty can no longer take input. Shut down the write end of
+				// the socket, as PipeRelay does, so that the host reads EOF and
+				// stops writing stdin.
+				if err := r.s.In.CloseWrite(); err != nil {
+					logrus.WithFields(logrus.Fields{
+						logrus.ErrorKey: err,
+					}).Error("opengcs::TtyRelay::Start - error shutting down stdin socket")
+				}
 			}
 			r.wg.Done()
 		}()
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Microsoft/hcsshim/internal/guest/storage"
 	"github.com/Microsoft/hcsshim/internal/guest/storage/vmbus"
@@ -22,6 +23,56 @@ func WaitForPCIDeviceFromVMBusGUID(ctx context.Context, vmBusGUID string) error
 	return err
 }
 
+// maxFunction is the highest function number of a PCI device.
+const maxFunction = 7
+
+// ValidateFunctions checks that `functions` are valid PCI function numbers,
+// with no duplicates.
+func ValidateFunctions(functions []uint) error {
+	seen := make(map[uint]struct{}, len(functions))
+	for _, fn := range functions {
+		if fn > maxFunction {
+			return fmt.Errorf("invalid PCI function number %d", fn)
+		}
+		if _, ok := seen[fn]; ok {
+			return fmt.Errorf("duplicate PCI function number %d", fn)
+		}
+		seen[fn] = struct{}{}
+	}
+	return nil
+}
+
+// WaitForPCIDeviceFunctionsFromVMBusGUID waits for the bus location paths of
+// each of the `functions` of a multi-function device to be present.
+func WaitForPCIDeviceFunctionsFromVMBusGUID(ctx context.Context, vmBusGUID string, functions []uint) error {
+	_, err := FindDeviceFunctionsBusLocationsFromVMBusGUID(ctx, vmBusGUID, functions)
+	return err
+}
+
+// FindDeviceFunctionsBusLocationsFromVMBusGUID finds the bus locations of each
+// of the `functions` of a multi-function device, in the same order.
+func FindDeviceFunctionsBusLocationsFromVMBusGUID(ctx context.Context, vmBusGUID string, functions []uint) ([]string, error) {
+	if err := ValidateFunctions(functions); err != nil {
+		return nil, err
+	}
+
+	pciDir, err := findVMBusPCIDir(ctx, vmBusGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	busLocations := make([]string, 0, len(functions))
+	for _, fn := range functions {
+		fullPath, err := findVMBusPCIDeviceFunction(ctx, pciDir, fn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find PCI function %d: %w", fn, err)
+		}
+		_, busFile := filepath.Split(fullPath)
+		busLocations = append(busLocations, busFile)
+	}
+	return busLocations, nil
+}
+
 // FindDeviceBusLocationFromVMBusGUID finds device bus location by
 // reading /sys/bus/vmbus/devices/<vmBusGUID>/... for pci specific directories
 func FindDeviceBusLocationFromVMBusGUID(ctx context.Context, vmBusGUID string) (string, error) {
@@ -45,6 +96,33 @@ func FindDeviceFullPath(ctx context.Context, vmBusGUID string) (string, error) {
 	return findVMBusPCIDevice(ctx, pciDir)
 }
 
+// FindDeviceFullPaths is similar to FindDeviceFullPath, but returns the paths of all
+// of the functions of the device, which may have more than one.
+func FindDeviceFullPaths(ctx context.Context, vmBusGUID string) ([]string, error) {
+	pciDir, err := findVMBusPCIDir(ctx, vmBusGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, pciDirName := filepath.Split(pciDir)
+	busPrefix := strings.TrimPrefix(pciDirName, "pci")
+	busPathPattern := filepath.Join(pciDir, fmt.Sprintf("%s*", busPrefix))
+	for {
+		paths, err := filepath.Glob(busPathPattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) > 0 {
+			return paths, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for device matching pattern %s: %w", busPathPattern, ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 // findVMBusPCIDir waits for the pci bus directory matching pattern
 // /sys/bus/vmbus/devices/<vmBusGUID>/pci* to exist and returns
 // the full resulting path or an error
@@ -68,3 +146,13 @@ func findVMBusPCIDevice(ctx context.Context, pciDirFullPath string) (string, err
 
 	return busFileFullPath, nil
 }
+
+// findVMBusPCIDeviceFunction is similar to findVMBusPCIDevice, but waits for
+// the bus location directory of function `fn` of a multi-function device.
+func findVMBusPCIDeviceFunction(ctx context.Context, pciDirFullPath string, fn uint) (string, error) {
+	_, pciDirName := filepath.Split(pciDirFullPath)
+	busPrefix := strings.TrimPrefix(pciDirName, "pci")
+	// bus locations are in the form <domain>:<bus>:<slot>.<function>
+	busPathPattern := filepath.Join(pciDirFullPath, fmt.Sprintf("%s:*.%d", busPrefix, fn))
+	return storageWaitForFileMatchingPattern(ctx, busPathPattern)
+}
@@ -5,9 +5,14 @@ package pci
 
 import (
 	"context"
+	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/Microsoft/hcsshim/internal/guest/storage"
+	"github.com/Microsoft/hcsshim/internal/guest/storage/vmbus"
 )
 
 func Test_WaitForPCIDeviceFromVMBusGUID_Success(t *testing.T) {
@@ -43,3 +48,83 @@ func Test_WaitForPCIDeviceFromVMBusGUID_Success(t *testing.T) {
 		t.Fatalf("result %s does not match expected result %s", resultBusLocation, busLocation)
 	}
 }
+
+func Test_FindDeviceFunctionsBusLocationsFromVMBusGUID_MultiFunction(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// mock the sysfs layout of a device with two functions under one VMBus channel
+	vmBusGUID := "1111-2222-3333-4444"
+	pciDir := filepath.Join(t.TempDir(), vmBusGUID, "pci1234:00")
+	for _, busLocation := range []string{"1234:00:00.0", "1234:00:00.1"} {
+		if err := os.MkdirAll(filepath.Join(pciDir, busLocation), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func() {
+		vmbusWaitForDevicePath = vmbus.WaitForDevicePath
+		storageWaitForFileMatchingPattern = storage.WaitForFileMatchingPattern
+	}()
+	vmbusWaitForDevicePath = func(ctx context.Context, vmbusGUIDPattern string) (string, error) {
+		if vmbusGUIDPattern != filepath.Join(vmBusGUID, "pci*") {
+			t.Fatalf("unexpected VMBus pattern %q", vmbusGUIDPattern)
+		}
+		return pciDir, nil
+	}
+	storageWaitForFileMatchingPattern = storage.WaitForFileMatchingPattern
+
+	busLocations, err := FindDeviceFunctionsBusLocationsFromVMBusGUID(ctx, vmBusGUID, []uint{1, 0})
+	if err != nil {
+		t.Fatalf("expected to succeed, instead got: %v", err)
+	}
+	expected := []string{"1234:00:00.1", "1234:00:00.0"}
+	if !slices.Equal(busLocations, expected) {
+		t.Fatalf("result %v does not match expected result %v", busLocations, expected)
+	}
+
+	// the single function lookup cannot distinguish the functions
+	if _, err := FindDeviceBusLocationFromVMBusGUID(ctx, vmBusGUID); err == nil {
+		t.Fatal("expected single function lookup of a multi-function device to fail")
+	}
+
+	// the functions are found together
+	paths, err := FindDeviceFullPaths(ctx, vmBusGUID)
+	if err != nil {
+		t.Fatalf("expected to succeed, instead got: %v", err)
+	}
+	expected = []string{filepath.Join(pciDir, "1234:00:00.0"), filepath.Join(pciDir, "1234:00:00.1")}
+	if !slices.Equal(paths, expected) {
+		t.Fatalf("result %v does not match expected result %v", paths, expected)
+	}
+
+	// a missing function times out
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	if _, err := FindDeviceFunctionsBusLocationsFromVMBusGUID(shortCtx, vmBusGUID, []uint{2}); err == nil {
+		t.Fatal("expected missing function to fail")
+	}
+}
+
+func Test_ValidateFunctions(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		functions []uint
+		valid     bool
+	}{
+		{name: "Empty", valid: true},
+		{name: "Valid", functions: []uint{0, 1, 7}, valid: true},
+		{name: "Duplicate", functions: []uint{0, 1, 0}},
+		{name: "OutOfRange", functions: []uint{8}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateFunctions(tc.functions)
+			if tc.valid && err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package quota manages ext4 project quotas, which are used to limit the disk
+// space each container may use when a scratch filesystem is shared.
+package quota
@@ -0,0 +1,229 @@
+//go:build linux
+// +build linux
+
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/guest/linux"
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oc"
+)
+
+const (
+	// _IOR('X', 31, struct fsxattr) and _IOW('X', 32, struct fsxattr)
+	fsIocFSGetXAttr = 0x801c581f
+	fsIocFSSetXAttr = 0x401c5820
+
+	// fsXFlagProjInherit causes new files and directories to inherit the
+	// project ID of their parent directory.
+	fsXFlagProjInherit = 0x00000200
+
+	// quotactl(2) commands and arguments, see include/uapi/linux/quota.h
+	qSubCmdShift = 8
+	qQuotaOn     = 0x800002
+	qGetQuota    = 0x800007
+	qSetQuota    = 0x800008
+	qPrjQuota    = 2
+	qfmtVFSV1    = 4
+	qifBLimits   = 1
+
+	// qifDQBlkSize is the size of the blocks used by the quota limits.
+	qifDQBlkSize = 1024
+)
+
+// fsxattr is the Go representation of `struct fsxattr`.
+type fsxattr struct {
+	XFlags     uint32
+	ExtSize    uint32
+	NextExts   uint32
+	ProjID     uint32
+	CowExtSize uint32
+	_          [8]byte
+}
+
+// dqblk is the Go representation of `struct if_dqblk`.
+type dqblk struct {
+	BHardLimit uint64
+	BSoftLimit uint64
+	CurSpace   uint64
+	IHardLimit uint64
+	ISoftLimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+}
+
+// ErrNotSupported is returned if the filesystem does not support project
+// quotas. ext4 filesystems must be created with the `quota` and `project`
+// features.
+var ErrNotSupported = errors.New("filesystem does not support project quotas")
+
+// ProjectID returns a non-zero project ID derived from `id`.
+func ProjectID(id string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	// project 0 is the default project that all files belong to
+	if p := h.Sum32(); p != 0 {
+		return p
+	}
+	return 1
+}
+
+func quotactl(f *os.File, cmd, projectID uint32, addr unsafe.Pointer) error {
+	if _, _, err := unix.Syscall6(
+		unix.SYS_QUOTACTL_FD,
+		f.Fd(),
+		uintptr(cmd<<qSubCmdShift|qPrjQuota),
+		uintptr(projectID),
+		uintptr(addr),
+		0,
+		0,
+	); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// enableEnforcement turns on project quota enforcement for the filesystem
+// containing `f`. Usage is always tracked for filesystems with the quota
+// feature, but limits are only enforced once quotas are turned on.
+func enableEnforcement(f *os.File) error {
+	err := quotactl(f, qQuotaOn, qfmtVFSV1, nil)
+	switch {
+	case err == nil, errors.Is(err, unix.EEXIST), errors.Is(err, unix.EBUSY):
+		return nil
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EINVAL), errors.Is(err, unix.EOPNOTSUPP):
+		return fmt.Errorf("%w: %w", ErrNotSupported, err)
+	}
+	return err
+}
+
+// setProjectID assigns `projectID` to the file or directory at `path`.
+// Directories are also marked so that new entries inherit the project ID.
+func setProjectID(path string, isDir bool, projectID uint32) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var attr fsxattr
+	if err := linux.Ioctl(f, fsIocFSGetXAttr, unsafe.Pointer(&attr)); err != nil {
+		return fmt.Errorf("get attributes of %s: %w", path, err)
+	}
+	attr.ProjID = projectID
+	if isDir {
+		attr.XFlags |= fsXFlagProjInherit
+	}
+	if err := linux.Ioctl(f, fsIocFSSetXAttr, unsafe.Pointer(&attr)); err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			return fmt.Errorf("%w: %w", ErrNotSupported, err)
+		}
+		return fmt.Errorf("set project ID of %s: %w", path, err)
+	}
+	return nil
+}
+
+func setLimit(f *os.File, projectID uint32, limitBytes uint64) error {
+	q := dqblk{
+		BHardLimit: (limitBytes + qifDQBlkSize - 1) / qifDQBlkSize,
+		Valid:      qifBLimits,
+	}
+	return quotactl(f, qSetQuota, projectID, unsafe.Pointer(&q))
+}
+
+// SetProjectQuota assigns `projectID` to `dir` and everything under it, and
+// limits the disk space used by the project to `limitBytes`. Writes beyond the
+// limit fail as if the filesystem were full.
+//
+// Files created under `dir` afterwards inherit the project ID.
+func SetProjectQuota(ctx context.Context, dir string, projectID uint32, limitBytes uint64) (err error) {
+	_, span := oc.StartSpan(ctx, "quota::SetProjectQuota")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+
+	span.AddAttributes(
+		trace.StringAttribute("dir", dir),
+		trace.Int64Attribute("projectID", int64(projectID)),
+		trace.Int64Attribute("limitBytes", int64(limitBytes)))
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := enableEnforcement(f); err != nil {
+		return fmt.Errorf("enable project quota enforcement: %w", err)
+	}
+
+	// the overlay upper and work directories may already exist, so walk the
+	// tree instead of only marking the root
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// project IDs can only be set on regular files and directories
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		return setProjectID(path, d.IsDir(), projectID)
+	}); err != nil {
+		return err
+	}
+
+	if err := setLimit(f, projectID, limitBytes); err != nil {
+		return fmt.Errorf("set project %d quota limit: %w", projectID, err)
+	}
+	log.G(ctx).WithFields(logrus.Fields{
+		"dir":        dir,
+		"projectID":  projectID,
+		"limitBytes": limitBytes,
+	}).Debug("set project quota")
+	return nil
+}
+
+// ClearProjectQuota removes the disk space limit for `projectID` on the
+// filesystem containing `path`.
+func ClearProjectQuota(path string, projectID uint32) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := setLimit(f, projectID, 0); err != nil {
+		return fmt.Errorf("clear project %d quota limit: %w", projectID, err)
+	}
+	return nil
+}
+
+// GetProjectUsage returns the disk space, in bytes, used by `projectID` on the
+// filesystem containing `path`.
+func GetProjectUsage(path string, projectID uint32) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var q dqblk
+	if err := quotactl(f, qGetQuota, projectID, unsafe.Pointer(&q)); err != nil {
+		return 0, fmt.Errorf("get project %d quota: %w", projectID, err)
+	}
+	return q.CurSpace, nil
+}
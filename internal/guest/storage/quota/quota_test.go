@@ -0,0 +1,32 @@
+//go:build linux
+// +build linux
+
+package quota
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func Test_ProjectID_Deterministic(t *testing.T) {
+	a := ProjectID("container1")
+	if a == 0 {
+		t.Fatal("expected non-zero project ID")
+	}
+	if b := ProjectID("container1"); a != b {
+		t.Fatalf("expected project ID %d, got %d", a, b)
+	}
+	if b := ProjectID("container2"); a == b {
+		t.Fatalf("expected different project IDs for different containers, got %d", b)
+	}
+}
+
+func Test_Struct_Sizes(t *testing.T) {
+	// sizes must match the kernel's `struct fsxattr` and `struct if_dqblk`
+	if s := unsafe.Sizeof(fsxattr{}); s != 28 {
+		t.Fatalf("expected fsxattr size 28, got %d", s)
+	}
+	if s := unsafe.Sizeof(dqblk{}); s != 72 {
+		t.Fatalf("expected dqblk size 72, got %d", s)
+	}
+}
@@ -0,0 +1,270 @@
+//go:build linux
+// +build linux
+
+package scsi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+// The layout of the GPT written by [initializeDisk].
+const (
+	gptHeaderSize  = 92
+	gptEntryCount  = 128
+	gptEntrySize   = 128
+	gptEntriesSize = gptEntryCount * gptEntrySize
+	gptRevision    = 0x00010000
+	// gptPartitionOffset is the offset of the partition, aligned to 1MiB as
+	// partitioning tools do.
+	gptPartitionOffset = 1024 * 1024
+)
+
+// linuxFilesystemPartitionType is the GPT partition type of Linux filesystem
+// data, 0FC63DAF-8483-4772-8E79-3D69D8477DE4.
+var linuxFilesystemPartitionType = guid.GUID{
+	Data1: 0x0fc63daf,
+	Data2: 0x8483,
+	Data3: 0x4772,
+	Data4: [8]byte{0x8e, 0x79, 0x3d, 0x69, 0xd8, 0x47, 0x7d, 0xe4},
+}
+
+// initializeDisk creates a GPT with a single partition spanning the device at
+// `devicePath`, as requested by [Config.InitializeDisk], and has the kernel
+// read it so that the partition shows up as partition 1 of the device. It
+// returns if the device was initialized, in which case the partition is new and
+// has yet to be formatted.
+//
+// A device that already has the GPT written by an earlier initialization is
+// left as is, whether or not `force` is set, so that the data of a disk
+// mounted again is kept. A device with any other partition table or
+// filesystem on it is only initialized if `force` is set.
+func initializeDisk(ctx context.Context, devicePath string, force bool) (bool, error) {
+	f, err := os.OpenFile(devicePath, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	size, sectorSize, blockDev, err := deviceGeometry(f)
+	if err != nil {
+		return false, fmt.Errorf("getting the size of device %s: %w", devicePath, err)
+	}
+	signature, err := diskSignature(f)
+	if err != nil {
+		return false, fmt.Errorf("checking device %s for a partition table or filesystem: %w", devicePath, err)
+	}
+	if signature == "a GPT" {
+		initialized, err := hasInitializedGPT(f, sectorSize)
+		if err != nil {
+			return false, fmt.Errorf("reading the GPT of device %s: %w", devicePath, err)
+		}
+		if initialized {
+			log.G(ctx).WithField("device", devicePath).Debug("device already initialized")
+			return false, nil
+		}
+	}
+	if signature != "" && !force {
+		return false, fmt.Errorf("device %s already has %s on it, refusing to initialize it without ForceFormat", devicePath, signature)
+	}
+
+	diskID, err := guid.NewV4()
+	if err != nil {
+		return false, err
+	}
+	partitionID, err := guid.NewV4()
+	if err != nil {
+		return false, err
+	}
+
+	log.G(ctx).WithFields(logrus.Fields{
+		"device":    devicePath,
+		"size":      size,
+		"signature": signature,
+	}).Info("initializing device with a GPT")
+	if err := writeGPT(f, size, sectorSize, diskID, partitionID); err != nil {
+		return false, fmt.Errorf("writing GPT to device %s: %w", devicePath, err)
+	}
+	if err := f.Sync(); err != nil {
+		return false, err
+	}
+	if blockDev {
+		if err := unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0); err != nil {
+			return false, fmt.Errorf("rereading the partition table of device %s: %w", devicePath, err)
+		}
+	}
+	return true, nil
+}
+
+// hasInitializedGPT returns if the primary GPT of `r` is the one written by
+// [writeGPT]: a valid header whose entries hold a single Linux filesystem
+// partition starting at [gptPartitionOffset].
+func hasInitializedGPT(r io.ReaderAt, sectorSize int64) (bool, error) {
+	h := make([]byte, gptHeaderSize)
+	if _, err := r.ReadAt(h, sectorSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	if string(h[0:8]) != "EFI PART" ||
+		binary.LittleEndian.Uint32(h[80:84]) != gptEntryCount ||
+		binary.LittleEndian.Uint32(h[84:88]) != gptEntrySize {
+		return false, nil
+	}
+	crc := binary.LittleEndian.Uint32(h[16:20])
+	binary.LittleEndian.PutUint32(h[16:20], 0)
+	if crc32.ChecksumIEEE(h) != crc {
+		return false, nil
+	}
+
+	entries := make([]byte, gptEntriesSize)
+	if _, err := r.ReadAt(entries, int64(binary.LittleEndian.Uint64(h[72:80]))*sectorSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	if crc32.ChecksumIEEE(entries) != binary.LittleEndian.Uint32(h[88:92]) {
+		return false, nil
+	}
+	typeID := linuxFilesystemPartitionType.ToWindowsArray()
+	if !bytes.Equal(entries[0:16], typeID[:]) ||
+		binary.LittleEndian.Uint64(entries[32:40]) != uint64(gptPartitionOffset/sectorSize) {
+		return false, nil
+	}
+	return !bytes.ContainsFunc(entries[gptEntrySize:], func(r rune) bool { return r != 0 }), nil
+}
+
+// deviceGeometry returns the size and logical sector size of `f`, and if it is
+// a block device. Files other than block devices have 512 byte sectors.
+func deviceGeometry(f *os.File) (size, sectorSize int64, blockDev bool, err error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	// the size of a block device is only found by seeking to its end
+	size, err = f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if fi.Mode()&os.ModeDevice == 0 {
+		return size, 512, false, nil
+	}
+	ss, err := unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return size, int64(ss), true, nil
+}
+
+// diskSignature returns the partition table or filesystem found at the start
+// of `r`, "" if the start of `r` is zeroed, or "data" for anything else.
+func diskSignature(r io.ReaderAt) (string, error) {
+	buf := make([]byte, blankCheckSize)
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	buf = buf[:n]
+
+	at := func(offset int, magic string) bool {
+		return len(buf) >= offset+len(magic) && string(buf[offset:offset+len(magic)]) == magic
+	}
+	switch {
+	case at(512, "EFI PART") || at(4096, "EFI PART"):
+		return "a GPT", nil
+	case at(510, "\x55\xaa"):
+		return "an MBR partition table", nil
+	case at(1080, "\x53\xef"):
+		return "an ext4 filesystem", nil
+	case at(0, "XFSB"):
+		return "an xfs filesystem", nil
+	}
+	if bytes.ContainsFunc(buf, func(r rune) bool { return r != 0 }) {
+		return "data", nil
+	}
+	return "", nil
+}
+
+// writeGPT writes a GPT with a single Linux filesystem partition, from
+// [gptPartitionOffset] to the end of the device, to `w`. The primary GPT and
+// everything before the partition is overwritten, as is the backup GPT at the
+// end of the device.
+func writeGPT(w io.WriterAt, size, sectorSize int64, diskID, partitionID guid.GUID) error {
+	if sectorSize < 512 || sectorSize > gptEntriesSize || sectorSize&(sectorSize-1) != 0 {
+		return fmt.Errorf("unsupported sector size %d", sectorSize)
+	}
+	entrySectors := gptEntriesSize / sectorSize
+	lastLBA := size/sectorSize - 1
+	firstUsable := 2 + entrySectors
+	lastUsable := lastLBA - entrySectors - 1
+	first := int64(gptPartitionOffset) / sectorSize
+	if lastUsable <= first {
+		return fmt.Errorf("device of %d bytes is too small to partition", size)
+	}
+
+	entries := make([]byte, gptEntriesSize)
+	typeID := linuxFilesystemPartitionType.ToWindowsArray()
+	copy(entries[0:16], typeID[:])
+	id := partitionID.ToWindowsArray()
+	copy(entries[16:32], id[:])
+	binary.LittleEndian.PutUint64(entries[32:40], uint64(first))
+	binary.LittleEndian.PutUint64(entries[40:48], uint64(lastUsable))
+	entriesCRC := crc32.ChecksumIEEE(entries)
+
+	header := func(myLBA, alternateLBA, entriesLBA int64) []byte {
+		h := make([]byte, gptHeaderSize)
+		copy(h[0:8], "EFI PART")
+		binary.LittleEndian.PutUint32(h[8:12], gptRevision)
+		binary.LittleEndian.PutUint32(h[12:16], gptHeaderSize)
+		binary.LittleEndian.PutUint64(h[24:32], uint64(myLBA))
+		binary.LittleEndian.PutUint64(h[32:40], uint64(alternateLBA))
+		binary.LittleEndian.PutUint64(h[40:48], uint64(firstUsable))
+		binary.LittleEndian.PutUint64(h[48:56], uint64(lastUsable))
+		id := diskID.ToWindowsArray()
+		copy(h[56:72], id[:])
+		binary.LittleEndian.PutUint64(h[72:80], uint64(entriesLBA))
+		binary.LittleEndian.PutUint32(h[80:84], gptEntryCount)
+		binary.LittleEndian.PutUint32(h[84:88], gptEntrySize)
+		binary.LittleEndian.PutUint32(h[88:92], entriesCRC)
+		binary.LittleEndian.PutUint32(h[16:20], crc32.ChecksumIEEE(h))
+		return h
+	}
+
+	// the protective MBR, primary header and entries, and zeroes up to the
+	// partition, so that no signatures of what was on the device are left
+	primary := make([]byte, first*sectorSize)
+	mbr := primary[446:462]
+	copy(mbr[1:4], []byte{0x00, 0x02, 0x00}) // CHS of LBA 1
+	mbr[4] = 0xee                            // GPT protective
+	copy(mbr[5:8], []byte{0xff, 0xff, 0xff})
+	binary.LittleEndian.PutUint32(mbr[8:12], 1)
+	binary.LittleEndian.PutUint32(mbr[12:16], uint32(min(lastLBA, 0xffffffff)))
+	primary[510], primary[511] = 0x55, 0xaa
+	copy(primary[sectorSize:], header(1, lastLBA, 2))
+	copy(primary[2*sectorSize:], entries)
+	if _, err := w.WriteAt(primary, 0); err != nil {
+		return err
+	}
+
+	backup := make([]byte, (entrySectors+1)*sectorSize)
+	copy(backup, entries)
+	copy(backup[entrySectors*sectorSize:], header(lastLBA, 1, lastLBA-entrySectors))
+	if _, err := w.WriteAt(backup, (lastLBA-entrySectors)*sectorSize); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+//go:build linux
+// +build linux
+
+package scsi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testDiskSize = 8 * 1024 * 1024
+
+// writeTestDisk creates a file of testDiskSize bytes with `data` written at
+// `offset`.
+func writeTestDisk(t *testing.T, offset int64, data []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "disk")
+	b := make([]byte, testDiskSize)
+	copy(b[offset:], data)
+	if err := os.WriteFile(p, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// checkGPTHeader returns the partition entries of the GPT header in `disk` at
+// `lba`, after checking its checksums.
+func checkGPTHeader(t *testing.T, disk []byte, lba int64) []byte {
+	t.Helper()
+	h := make([]byte, gptHeaderSize)
+	copy(h, disk[lba*512:])
+	if string(h[0:8]) != "EFI PART" {
+		t.Fatalf("no GPT header at LBA %d", lba)
+	}
+	if got := int64(binary.LittleEndian.Uint64(h[24:32])); got != lba {
+		t.Fatalf("expected GPT header at LBA %d to be at its own LBA, got %d", lba, got)
+	}
+	crc := binary.LittleEndian.Uint32(h[16:20])
+	binary.LittleEndian.PutUint32(h[16:20], 0)
+	if crc32.ChecksumIEEE(h) != crc {
+		t.Fatalf("bad checksum of GPT header at LBA %d", lba)
+	}
+	entriesLBA := int64(binary.LittleEndian.Uint64(h[72:80]))
+	entries := disk[entriesLBA*512 : entriesLBA*512+gptEntriesSize]
+	if crc32.ChecksumIEEE(entries) != binary.LittleEndian.Uint32(h[88:92]) {
+		t.Fatalf("bad checksum of GPT entries of header at LBA %d", lba)
+	}
+	return entries
+}
+
+func Test_initializeDisk(t *testing.T) {
+	ext4 := make([]byte, 1082)
+	ext4[1080], ext4[1081] = 0x53, 0xef
+	mbr := make([]byte, 512)
+	mbr[450] = 0x83 // Linux partition
+	mbr[510], mbr[511] = 0x55, 0xaa
+
+	for _, tc := range []struct {
+		name   string
+		data   []byte
+		force  bool
+		refuse bool
+	}{
+		{name: "blank"},
+		{name: "already formatted", data: ext4, refuse: true},
+		{name: "already formatted forced", data: ext4, force: true},
+		{name: "foreign partitioned", data: mbr, refuse: true},
+		{name: "foreign partitioned forced", data: mbr, force: true},
+		{name: "unknown data", data: []byte("data"), refuse: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := writeTestDisk(t, 0, tc.data)
+			initialized, err := initializeDisk(context.Background(), p, tc.force)
+			disk, rerr := os.ReadFile(p)
+			if rerr != nil {
+				t.Fatal(rerr)
+			}
+			if tc.refuse {
+				if err == nil {
+					t.Fatal("expected an error initializing a device with data")
+				}
+				if string(disk[:len(tc.data)]) != string(tc.data) {
+					t.Fatal("expected the device not to be modified")
+				}
+				return
+			}
+			if err != nil || !initialized {
+				t.Fatalf("failed to initialize device: %v", err)
+			}
+
+			if disk[450] != 0xee || disk[510] != 0x55 || disk[511] != 0xaa {
+				t.Fatal("expected a protective MBR")
+			}
+			if disk[1080] != 0 {
+				t.Fatal("expected the previous filesystem signature to be cleared")
+			}
+			lastLBA := int64(testDiskSize/512 - 1)
+			primary := checkGPTHeader(t, disk, 1)
+			backup := checkGPTHeader(t, disk, lastLBA)
+			if string(primary) != string(backup) {
+				t.Fatal("expected the primary and backup partition entries to match")
+			}
+			typeID := linuxFilesystemPartitionType.ToWindowsArray()
+			if string(primary[0:16]) != string(typeID[:]) {
+				t.Fatal("expected a Linux filesystem partition")
+			}
+			first := binary.LittleEndian.Uint64(primary[32:40])
+			last := binary.LittleEndian.Uint64(primary[40:48])
+			if first != gptPartitionOffset/512 || last != uint64(lastLBA-gptEntriesSize/512-1) {
+				t.Fatalf("expected the partition to span the device, got LBAs %d to %d", first, last)
+			}
+			for i := gptEntrySize; i < gptEntriesSize; i++ {
+				if primary[i] != 0 {
+					t.Fatal("expected a single partition")
+				}
+			}
+			if s, err := diskSignature(bytes.NewReader(disk)); err != nil || s != "a GPT" {
+				t.Fatalf("expected the device to have a GPT, got %q: %v", s, err)
+			}
+		})
+	}
+}
+
+func Test_initializeDisk_Again(t *testing.T) {
+	p := writeTestDisk(t, 0, nil)
+	if _, err := initializeDisk(context.Background(), p, false); err != nil {
+		t.Fatalf("failed to initialize device: %v", err)
+	}
+	// stands in for the filesystem the partition is formatted with
+	f, err := os.OpenFile(p, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("data"), gptPartitionOffset); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	before, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, force := range []bool{false, true} {
+		initialized, err := initializeDisk(context.Background(), p, force)
+		if err != nil {
+			t.Fatalf("expected a device initialized before to be accepted with force %t: %v", force, err)
+		}
+		if initialized {
+			t.Fatalf("expected a device initialized before not to be initialized again with force %t", force)
+		}
+		after, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(before, after) {
+			t.Fatalf("expected a device initialized before not to be modified with force %t", force)
+		}
+	}
+}
+
+func Test_hasInitializedGPT_Foreign(t *testing.T) {
+	p := writeTestDisk(t, 0, nil)
+	if _, err := initializeDisk(context.Background(), p, false); err != nil {
+		t.Fatalf("failed to initialize device: %v", err)
+	}
+	disk, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// move the partition, keeping the checksums valid
+	entries := disk[2*512 : 2*512+gptEntriesSize]
+	binary.LittleEndian.PutUint64(entries[32:40], 4096)
+	h := disk[512 : 512+gptHeaderSize]
+	binary.LittleEndian.PutUint32(h[88:92], crc32.ChecksumIEEE(entries))
+	binary.LittleEndian.PutUint32(h[16:20], 0)
+	binary.LittleEndian.PutUint32(h[16:20], crc32.ChecksumIEEE(h))
+	initialized, err := hasInitializedGPT(bytes.NewReader(disk), 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if initialized {
+		t.Fatal("expected a GPT with a different partition not to be taken as initialized")
+	}
+	if err := os.WriteFile(p, disk, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := initializeDisk(context.Background(), p, false); err == nil {
+		t.Fatal("expected a device with a foreign GPT to be refused without force")
+	}
+}
+
+func Test_writeGPT_TooSmall(t *testing.T) {
+	p := writeTestDisk(t, 0, nil)
+	f, err := os.OpenFile(p, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := writeGPT(f, gptPartitionOffset, 512, linuxFilesystemPartitionType, linuxFilesystemPartitionType); err == nil {
+		t.Fatal("expected an error partitioning a device with no room for a partition")
+	}
+}
@@ -0,0 +1,188 @@
+//go:build linux
+// +build linux
+
+package scsi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+// maxKernelLogLines is the most lines of the kernel log about a device that
+// are reported in a [FilesystemError].
+const maxKernelLogLines = 20
+
+// errMountedReadOnly is returned for a filesystem the kernel mounted read-only
+// when it was asked to mount it read-write, because of errors found in it.
+var errMountedReadOnly = errors.New("filesystem was mounted read-only")
+
+// FilesystemError is returned by [Mount] when the filesystem on a device cannot
+// be mounted because it is corrupt, such as a scratch disk reused after an
+// unclean shutdown of the uVM. It is wrapped with the ERROR_DISK_CORRUPT
+// HRESULT, so that the host can tell it apart from other mount failures.
+type FilesystemError struct {
+	Source     string
+	Filesystem string
+	// Repaired is set if the filesystem was repaired before the last attempt
+	// to mount it failed.
+	Repaired bool
+	// RepairErr is the error repairing the filesystem, if it failed.
+	RepairErr error
+	// KernelLog is the latest lines of the kernel log about the device, which
+	// say what is wrong with the filesystem.
+	KernelLog []string
+	Err       error
+}
+
+func (e *FilesystemError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s filesystem on %s is corrupt", e.Filesystem, e.Source)
+	if e.Repaired {
+		b.WriteString(" after repair")
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	if e.RepairErr != nil {
+		fmt.Fprintf(&b, "; repair failed: %v", e.RepairErr)
+	}
+	if len(e.KernelLog) > 0 {
+		b.WriteString("; kernel log:")
+		for _, l := range e.KernelLog {
+			b.WriteString("\n" + l)
+		}
+	}
+	return b.String()
+}
+
+func (e *FilesystemError) Unwrap() error {
+	return e.Err
+}
+
+// isFilesystemCorrupt returns if `err`, from mounting a filesystem, is due to
+// errors in the filesystem rather than in the device or the mount options.
+// EUCLEAN and EBADMSG are EFSCORRUPTED and EFSBADCRC in the kernel.
+func isFilesystemCorrupt(err error) bool {
+	return errors.Is(err, unix.EUCLEAN) || errors.Is(err, unix.EBADMSG) || errors.Is(err, errMountedReadOnly)
+}
+
+// mountFilesystem mounts the `fsType` filesystem on `source` to `target`. If
+// the filesystem is corrupt, a [FilesystemError] is returned, unless `repair`
+// is set and the filesystem is repaired and then mounted.
+func mountFilesystem(ctx context.Context, source, target, fsType string, flags uintptr, data string, repair bool) error {
+	mountErr := mountChecked(source, target, fsType, flags, data, repair)
+	if mountErr == nil {
+		return nil
+	}
+	if !isFilesystemCorrupt(mountErr) {
+		return fmt.Errorf("mounting: %w", mountErr)
+	}
+
+	fsErr := &FilesystemError{
+		Source:     source,
+		Filesystem: fsType,
+		Err:        mountErr,
+	}
+	if repair {
+		log.G(ctx).WithFields(logrus.Fields{
+			"source":        source,
+			"filesystem":    fsType,
+			logrus.ErrorKey: mountErr,
+		}).Warn("filesystem is corrupt, repairing it")
+		if err := repairDevice(source, fsType); err != nil {
+			fsErr.RepairErr = err
+		} else {
+			fsErr.Repaired = true
+			mountErr = mountChecked(source, target, fsType, flags, data, repair)
+			if mountErr == nil {
+				log.G(ctx).WithField("source", source).Info("mounted repaired filesystem")
+				return nil
+			}
+			if !isFilesystemCorrupt(mountErr) {
+				return fmt.Errorf("mounting after repair: %w", mountErr)
+			}
+			fsErr.Err = mountErr
+		}
+	}
+	fsErr.KernelLog = kernelLogLines(filepath.Base(source))
+	return gcserr.WrapHresult(fsErr, gcserr.HrErrDiskCorrupt)
+}
+
+// mountChecked mounts `source` to `target`. If `checkReadOnly` is set and
+// the kernel mounted a read-write mount read-only, it is unmounted and
+// errMountedReadOnly is returned.
+func mountChecked(source, target, fsType string, flags uintptr, data string, checkReadOnly bool) error {
+	if err := unixMount(source, target, fsType, flags, data); err != nil {
+		return err
+	}
+	if !checkReadOnly || flags&unix.MS_RDONLY != 0 {
+		return nil
+	}
+	var st unix.Statfs_t
+	if err := unixStatfs(target, &st); err != nil {
+		return fmt.Errorf("checking mount %s: %w", target, err)
+	}
+	if st.Flags&unix.ST_RDONLY == 0 {
+		return nil
+	}
+	if err := unixUnmount(target, 0); err != nil {
+		return fmt.Errorf("unmounting read-only mount %s: %w", target, err)
+	}
+	return errMountedReadOnly
+}
+
+// repairFilesystem checks the `fsType` filesystem on `source` and fixes any
+// errors found in it. Only ext4 filesystems can be repaired.
+//
+// e2fsck is not run with a context, as killing it part way through a repair
+// could leave the filesystem in a worse state.
+func repairFilesystem(source, fsType string) error {
+	if fsType != "ext4" {
+		return fmt.Errorf("repairing %s filesystems is not supported", fsType)
+	}
+	cmd := exec.Command("e2fsck", "-f", "-y", source)
+	output, err := cmd.CombinedOutput()
+	// exit codes below 4 mean that there were no errors or that they were
+	// all fixed
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() < 4 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("e2fsck failed with %s: %w", bytes.TrimSpace(output), err)
+	}
+	return nil
+}
+
+// kernelLogLines returns the latest lines of the kernel log that mention
+// `device`, or nil if the kernel log cannot be read.
+func kernelLogLines(device string) []string {
+	size, err := klogctl(unix.SYSLOG_ACTION_SIZE_BUFFER, nil)
+	if err != nil || size <= 0 {
+		return nil
+	}
+	buf := make([]byte, size)
+	n, err := klogctl(unix.SYSLOG_ACTION_READ_ALL, buf)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, l := range strings.Split(string(buf[:n]), "\n") {
+		if strings.Contains(l, "("+device+")") || strings.Contains(l, " "+device+":") {
+			lines = append(lines, strings.TrimSpace(l))
+		}
+	}
+	if len(lines) > maxKernelLogLines {
+		lines = lines[len(lines)-maxKernelLogLines:]
+	}
+	return lines
+}
@@ -6,8 +6,10 @@ package scsi
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"strconv"
@@ -65,6 +67,21 @@ var (
 	// ext4Format is stubbed for unit testing the `EnsureFilesystem` and
 	// `Encrypt` flow in `mount`
 	xfsFormat = xfs.Format
+	// setDeviceBlockSize is stubbed for unit testing `mount`
+	setDeviceBlockSize = setBlockSize
+	// _deviceIsBlank and mkfsFormat are stubbed for unit testing the
+	// `FormatOnAttach` flow in `mount`
+	_deviceIsBlank = deviceIsBlank
+	mkfsFormat     = formatDevice
+	// _initializeDisk is stubbed for unit testing the `InitializeDisk` flow in
+	// `mount`
+	_initializeDisk = initializeDisk
+	// repairDevice, unixStatfs, unixUnmount and klogctl are stubbed for unit
+	// testing the `RepairFilesystem` flow in `mount`
+	repairDevice = repairFilesystem
+	unixStatfs   = unix.Statfs
+	unixUnmount  = unix.Unmount
+	klogctl      = unix.Klogctl
 )
 
 const (
@@ -116,6 +133,24 @@ type Config struct {
 	EnsureFilesystem bool
 	Filesystem       string
 	BlockDev         bool
+	// BlockSize is the logical block size to use for the device, if non-zero.
+	BlockSize uint32
+	// FormatOnAttach formats a blank device with FormatFilesystem (or
+	// Filesystem, or ext4 if neither is set) before it is mounted. A device
+	// with existing data is only formatted if ForceFormat is also set.
+	FormatOnAttach   bool
+	FormatFilesystem string
+	ForceFormat      bool
+	// InitializeDisk creates a GPT with a single partition spanning a blank
+	// device, and formats the partition as with FormatOnAttach. The partition
+	// is partition 1 of the device. A device with a partition table or
+	// filesystem is only initialized if ForceFormat is also set. A device
+	// initialized by an earlier mount is mounted as is, with ForceFormat or
+	// not, and its partition is only formatted if it is still blank.
+	InitializeDisk bool
+	// RepairFilesystem runs fsck on a device whose filesystem is corrupt, or is
+	// mounted read-only by the kernel because of errors, and mounts it again.
+	RepairFilesystem bool
 }
 
 // Mount creates a mount from the SCSI device on `controller` index `lun` to
@@ -145,6 +180,38 @@ func Mount(
 		trace.Int64Attribute("partition", int64(partition)),
 	)
 
+	if config.FormatOnAttach || config.InitializeDisk {
+		if readonly || config.Encrypted || config.BlockDev {
+			return errors.New("formatting or initializing on attach is not supported for read-only, encrypted or block device mounts")
+		}
+		if config.EnsureFilesystem {
+			return errors.New("formatting or initializing on attach and ensuring the filesystem are mutually exclusive")
+		}
+		if config.FormatFilesystem != "" && config.Filesystem != "" && config.FormatFilesystem != config.Filesystem {
+			return fmt.Errorf("cannot format device with %s and mount it as %s", config.FormatFilesystem, config.Filesystem)
+		}
+	}
+
+	if config.RepairFilesystem && (readonly || config.Encrypted || config.BlockDev) {
+		return errors.New("repairing the filesystem is not supported for read-only, encrypted or block device mounts")
+	}
+
+	// newPartition is set if the device was initialized by this mount, so that
+	// its partition is formatted regardless of what is left in it.
+	newPartition := false
+	if config.InitializeDisk {
+		if partition != 1 {
+			return fmt.Errorf("initializing a device creates partition 1, cannot mount partition %d", partition)
+		}
+		disk, err := getDevicePath(spnCtx, controller, lun, 0)
+		if err != nil {
+			return err
+		}
+		if newPartition, err = _initializeDisk(spnCtx, disk, config.ForceFormat); err != nil {
+			return err
+		}
+	}
+
 	source, err := getDevicePath(spnCtx, controller, lun, partition)
 	if err != nil {
 		return err
@@ -167,8 +234,18 @@ func Mount(
 		}
 	}
 
-	// create and symlink block device mount target
+	if config.BlockSize != 0 {
+		if err := setDeviceBlockSize(source, config.BlockSize); err != nil {
+			return err
+		}
+	}
+
+	// create and symlink block device mount target. Raw block devices are
+	// surfaced as-is, so there is no filesystem to format, encrypt or mount.
 	if config.BlockDev {
+		if config.Encrypted || config.EnsureFilesystem {
+			return errors.New("block device mounts do not support encryption or filesystem formatting")
+		}
 		parent := filepath.Dir(target)
 		if err := osMkdirAll(parent, 0700); err != nil {
 			return err
@@ -177,7 +254,10 @@ func Mount(
 			"source": source,
 			"target": target,
 		}).Trace("creating block device symlink")
-		return osSymlink(source, target)
+		if err := osSymlink(source, target); err != nil {
+			return fmt.Errorf("failed to create block device symlink %s: %w", target, err)
+		}
+		return nil
 	}
 
 	if err := osMkdirAll(target, 0700); err != nil {
@@ -211,6 +291,10 @@ func Mount(
 			}
 		}
 		source = encryptedSource
+	} else if config.FormatOnAttach || config.InitializeDisk {
+		if deviceFS, err = formatOnAttach(spnCtx, source, config, newPartition); err != nil {
+			return err
+		}
 	} else {
 		// Get the filesystem that is already on the device (if any) and use that
 		// as the mountType unless `Filesystem` was given.
@@ -259,9 +343,9 @@ func Mount(
 	}
 
 	// device should already be present under /dev, so we should not get an error
-	// unless the command has actually errored out
-	if err := unixMount(source, target, mountType, flags, data); err != nil {
-		return fmt.Errorf("mounting: %w", err)
+	// unless the command has actually errored out, or the filesystem is corrupt
+	if err := mountFilesystem(spnCtx, source, target, mountType, flags, data, config.RepairFilesystem); err != nil {
+		return err
 	}
 
 	// remount the target to account for propagation flags
@@ -297,18 +381,17 @@ func Unmount(
 		trace.Int64Attribute("partition", int64(partition)),
 		trace.StringAttribute("target", target))
 
-	// skip unmount logic for block devices, since they are just symlinks
 	if config.BlockDev {
+		// skip unmount logic for block devices, since they are just symlinks
 		log.G(ctx).WithField("target", target).Trace("removing block device symlink")
 		if err := osRemoveAll(target); err != nil {
 			return fmt.Errorf("failed to remove symlink: %w", err)
 		}
-		return nil
-	}
-
-	// unmount target
-	if err := storageUnmountPath(ctx, target, true); err != nil {
-		return errors.Wrapf(err, "unmount failed: %s", target)
+	} else {
+		// unmount target
+		if err := storageUnmountPath(ctx, target, true); err != nil {
+			return errors.Wrapf(err, "unmount failed: %s", target)
+		}
 	}
 
 	if config.VerityInfo != nil {
@@ -437,6 +520,25 @@ func GetDevicePath(ctx context.Context, controller, lun uint8, partition uint64)
 	return devicePath, nil
 }
 
+// setBlockSize sets the logical block size the kernel uses for I/O to the
+// block device at `devicePath`. This is equivalent to `blockdev --setbsz`.
+func setBlockSize(devicePath string, size uint32) error {
+	if size < 512 || size > uint32(os.Getpagesize()) || size&(size-1) != 0 {
+		return fmt.Errorf("invalid block size %d: must be a power of two between 512 and %d", size, os.Getpagesize())
+	}
+
+	f, err := os.Open(devicePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := unix.IoctlSetPointerInt(int(f.Fd()), unix.BLKBSZSET, int(size)); err != nil {
+		return fmt.Errorf("failed to set block size of %s to %d: %w", devicePath, size, err)
+	}
+	return nil
+}
+
 // UnplugDevice finds the SCSI device on `controller` index `lun` and issues a
 // guest initiated unplug.
 //
@@ -468,6 +570,118 @@ func UnplugDevice(ctx context.Context, controller, lun uint8) (err error) {
 
 var ErrUnknownFilesystem = errors.New("could not get device filesystem type")
 
+// blankCheckSize is the size of the start of a device, where filesystems keep
+// their superblocks, that must be zeroed for the device to be considered blank.
+const blankCheckSize = 1024 * 1024
+
+// formatOnAttach formats the device at `source` as requested by
+// [Config.FormatOnAttach] or [Config.InitializeDisk], and returns the
+// filesystem on it. `newPartition` is set if `source` is a partition that
+// [initializeDisk] just created.
+func formatOnAttach(ctx context.Context, source string, config *Config, newPartition bool) (string, error) {
+	fsType := config.FormatFilesystem
+	if fsType == "" {
+		fsType = config.Filesystem
+	}
+	if fsType == "" {
+		fsType = "ext4"
+	}
+	if !isFilesystemName(fsType) {
+		return "", fmt.Errorf("invalid filesystem %q to format device with", fsType)
+	}
+
+	// Only ext4 is recognized by getDeviceFsType, so any data at the start of
+	// the device is taken as filesystem metadata, so as not to format a disk
+	// with an unrecognized filesystem. A partition that was just created is
+	// formatted regardless, as any data in it is left over from before.
+	blank := newPartition
+	if !newPartition {
+		var err error
+		if blank, err = _deviceIsBlank(source); err != nil {
+			return "", fmt.Errorf("checking device %s for existing data: %w", source, err)
+		}
+	}
+	if !blank && config.InitializeDisk {
+		// The partition was created and formatted by an earlier mount, so keep
+		// what is on it. Filesystems other than ext4 are taken to be the one
+		// the partition was formatted with.
+		deviceFS, err := _getDeviceFsType(source)
+		if err != nil {
+			if !errors.Is(err, ErrUnknownFilesystem) {
+				return "", fmt.Errorf("getting device's filesystem: %w", err)
+			}
+			deviceFS = fsType
+		}
+		log.G(ctx).WithFields(logrus.Fields{
+			"source":     source,
+			"filesystem": deviceFS,
+		}).Debug("mounting partition of initialized device as is")
+		return deviceFS, nil
+	}
+	if !blank && !config.ForceFormat {
+		return "", fmt.Errorf("device %s already has data, refusing to format it without ForceFormat", source)
+	}
+
+	log.G(ctx).WithFields(logrus.Fields{
+		"source":     source,
+		"filesystem": fsType,
+		"blank":      blank,
+	}).Info("formatting device on attach")
+	if err := mkfsFormat(ctx, source, fsType); err != nil {
+		return "", fmt.Errorf("%s format: %w", fsType, err)
+	}
+	return fsType, nil
+}
+
+// isFilesystemName returns if `fsType` is a valid filesystem name: non-empty,
+// with only lowercase letters and digits, so that it can be appended to "mkfs."
+// to name the command that formats the filesystem.
+func isFilesystemName(fsType string) bool {
+	for _, c := range fsType {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return fsType != ""
+}
+
+// formatDevice formats the device at `source` with `fsType` by running
+// mkfs.<fsType>.
+func formatDevice(ctx context.Context, source, fsType string) error {
+	switch fsType {
+	case "ext4":
+		return ext4Format(ctx, source)
+	case "xfs":
+		return xfsFormat(source)
+	}
+	cmd := exec.CommandContext(ctx, "mkfs."+fsType, source)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("mkfs.%s failed with %s: %w", fsType, string(output), err)
+	}
+	return nil
+}
+
+// deviceIsBlank returns if the start of the device at `devicePath` is zeroed.
+func deviceIsBlank(devicePath string) (bool, error) {
+	f, err := os.Open(devicePath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, blankCheckSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	for _, b := range buf[:n] {
+		if b != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // getDeviceFsType finds a device's filesystem.
 // Right now we only support checking for ext4. In the future, this may
 // be expanded to support xfs or other fs types.
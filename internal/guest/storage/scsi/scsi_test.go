@@ -10,11 +10,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+	pkgerrors "github.com/pkg/errors"
 	"golang.org/x/sys/unix"
+
+	"github.com/Microsoft/hcsshim/internal/bridgeutils/gcserr"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 )
 
 func clearTestDependencies() {
@@ -23,6 +27,7 @@ func clearTestDependencies() {
 	osOpen = nil
 	osMkdirAll = nil
 	osRemoveAll = nil
+	osSymlink = nil
 	unixMount = nil
 	getDevicePath = nil
 	createVerityTarget = nil
@@ -33,6 +38,14 @@ func clearTestDependencies() {
 	_tar2ext4IsDeviceExt4 = nil
 	ext4Format = nil
 	xfsFormat = nil
+	setDeviceBlockSize = nil
+	_deviceIsBlank = nil
+	mkfsFormat = nil
+	_initializeDisk = nil
+	repairDevice = nil
+	unixStatfs = nil
+	unixUnmount = nil
+	klogctl = nil
 }
 
 // fakeFileInfo is a mock os.FileInfo that can be used to return
@@ -799,6 +812,533 @@ func Test_Mount_EnsureFilesystem_Unsupported(t *testing.T) {
 	}
 }
 
+func Test_Mount_FormatOnAttach(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		blank  bool
+		config Config
+		fsType string // the filesystem the device is formatted and mounted with
+	}{
+		{
+			name:   "blank",
+			blank:  true,
+			config: Config{FormatOnAttach: true, FormatFilesystem: "xfs"},
+			fsType: "xfs",
+		},
+		{
+			name:   "blank default",
+			blank:  true,
+			config: Config{FormatOnAttach: true},
+			fsType: "ext4",
+		},
+		{
+			name:   "existing data",
+			config: Config{FormatOnAttach: true, FormatFilesystem: "xfs"},
+		},
+		{
+			name:   "existing data forced",
+			config: Config{FormatOnAttach: true, FormatFilesystem: "xfs", ForceFormat: true},
+			fsType: "xfs",
+		},
+		{
+			name:   "invalid filesystem",
+			blank:  true,
+			config: Config{FormatOnAttach: true, FormatFilesystem: "../xfs"},
+		},
+		{
+			name:   "conflicting filesystem",
+			blank:  true,
+			config: Config{FormatOnAttach: true, FormatFilesystem: "xfs", Filesystem: "ext4"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearTestDependencies()
+
+			osMkdirAll = func(path string, perm os.FileMode) error {
+				return nil
+			}
+			osRemoveAll = func(string) error {
+				return nil
+			}
+			getDevicePath = func(ctx context.Context, controller, lun uint8, partition uint64) (string, error) {
+				return "/dev/sdb", nil
+			}
+			_deviceIsBlank = func(string) (bool, error) {
+				return tc.blank, nil
+			}
+			formatted := ""
+			mkfsFormat = func(_ context.Context, source, fsType string) error {
+				formatted = fsType
+				return nil
+			}
+			mounted := ""
+			unixMount = func(source string, target string, fstype string, flags uintptr, data string) error {
+				mounted = fstype
+				return nil
+			}
+
+			config := tc.config
+			err := Mount(context.Background(), 0, 0, 0, "/fake/path", false, nil, &config)
+			if tc.fsType == "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if formatted != "" {
+					t.Fatalf("expected the device not to be formatted, it was formatted with %s", formatted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil err, got: %v", err)
+			}
+			if formatted != tc.fsType || mounted != tc.fsType {
+				t.Fatalf("expected the device to be formatted and mounted with %s, got %q and %q", tc.fsType, formatted, mounted)
+			}
+		})
+	}
+}
+
+func Test_Mount_FormatOnAttach_ReadOnly(t *testing.T) {
+	clearTestDependencies()
+
+	// NOTE: Do NOT set getDevicePath or mkfsFormat because the config is
+	// rejected before the device is looked up. Expect them not to be called.
+	config := &Config{FormatOnAttach: true}
+	if err := Mount(context.Background(), 0, 0, 0, "/fake/path", true, nil, config); err == nil {
+		t.Fatal("expected an error formatting a read-only mount")
+	}
+}
+
+func Test_Mount_InitializeDisk(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		partition uint64
+		config    Config
+		initErr   error
+		existing  bool   // the device was initialized by an earlier mount
+		data      bool   // the partition of an existing device is not blank
+		fsType    string // the filesystem the partition is mounted with
+		format    bool   // the partition is expected to be formatted
+	}{
+		{
+			name:      "default",
+			partition: 1,
+			config:    Config{InitializeDisk: true},
+			fsType:    "ext4",
+			format:    true,
+		},
+		{
+			name:      "filesystem",
+			partition: 1,
+			config:    Config{InitializeDisk: true, Filesystem: "xfs"},
+			fsType:    "xfs",
+			format:    true,
+		},
+		{
+			name:      "mounted again",
+			partition: 1,
+			config:    Config{InitializeDisk: true},
+			existing:  true,
+			data:      true,
+			fsType:    "ext4",
+		},
+		{
+			name:      "mounted again forced",
+			partition: 1,
+			config:    Config{InitializeDisk: true, ForceFormat: true, FormatFilesystem: "xfs"},
+			existing:  true,
+			data:      true,
+			fsType:    "xfs",
+		},
+		{
+			name:      "mounted again blank",
+			partition: 1,
+			config:    Config{InitializeDisk: true},
+			existing:  true,
+			fsType:    "ext4",
+			format:    true,
+		},
+		{
+			name:      "refused",
+			partition: 1,
+			config:    Config{InitializeDisk: true},
+			initErr:   errors.New("device already has an ext4 filesystem on it"),
+		},
+		{
+			name:   "whole device",
+			config: Config{InitializeDisk: true},
+		},
+		{
+			name:      "ensure filesystem",
+			partition: 1,
+			config:    Config{InitializeDisk: true, EnsureFilesystem: true, Filesystem: "ext4"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearTestDependencies()
+
+			osMkdirAll = func(path string, perm os.FileMode) error {
+				return nil
+			}
+			osRemoveAll = func(string) error {
+				return nil
+			}
+			getDevicePath = func(ctx context.Context, controller, lun uint8, partition uint64) (string, error) {
+				if partition == 0 {
+					return "/dev/sdb", nil
+				}
+				return fmt.Sprintf("/dev/sdb%d", partition), nil
+			}
+			initialized := ""
+			_initializeDisk = func(_ context.Context, devicePath string, force bool) (bool, error) {
+				initialized = devicePath
+				return !tc.existing, tc.initErr
+			}
+			// NOTE: _deviceIsBlank is only set for an existing device, as a new
+			// partition is formatted regardless of what is left in it.
+			if tc.existing {
+				_deviceIsBlank = func(string) (bool, error) {
+					return !tc.data, nil
+				}
+				_getDeviceFsType = func(string) (string, error) {
+					if tc.fsType == "ext4" {
+						return "ext4", nil
+					}
+					return "", ErrUnknownFilesystem
+				}
+			}
+			formatted := ""
+			mkfsFormat = func(_ context.Context, source, fsType string) error {
+				formatted = source + " " + fsType
+				return nil
+			}
+			mounted := ""
+			unixMount = func(source string, target string, fstype string, flags uintptr, data string) error {
+				mounted = source + " " + fstype
+				return nil
+			}
+
+			config := tc.config
+			err := Mount(context.Background(), 0, 0, tc.partition, "/fake/path", false, nil, &config)
+			if tc.fsType == "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if formatted != "" {
+					t.Fatalf("expected the partition not to be formatted, got %s", formatted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil err, got: %v", err)
+			}
+			if initialized != "/dev/sdb" {
+				t.Fatalf("expected the whole device to be initialized, got %q", initialized)
+			}
+			expected := "/dev/sdb1 " + tc.fsType
+			if mounted != expected {
+				t.Fatalf("expected the partition to be mounted as %q, got %q", expected, mounted)
+			}
+			if !tc.format {
+				if formatted != "" {
+					t.Fatalf("expected the partition of an initialized device not to be formatted, got %s", formatted)
+				}
+				return
+			}
+			if formatted != expected {
+				t.Fatalf("expected the partition to be formatted as %q, got %q", expected, formatted)
+			}
+		})
+	}
+}
+
+func Test_Mount_RepairFilesystem(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		repair     bool
+		mountErrs  []error // the errors of each mount attempt
+		readOnly   []bool  // whether each successful mount attempt is read-only
+		repairErr  error
+		wantMounts int
+		wantRepair bool
+		wantErr    error // nil, unix.EIO, or a *FilesystemError
+	}{
+		{
+			name:       "corrupt repaired",
+			repair:     true,
+			mountErrs:  []error{unix.EUCLEAN, nil},
+			wantMounts: 2,
+			wantRepair: true,
+		},
+		{
+			name:       "read-only repaired",
+			repair:     true,
+			mountErrs:  []error{nil, nil},
+			readOnly:   []bool{true, false},
+			wantMounts: 2,
+			wantRepair: true,
+		},
+		{
+			name:       "corrupt after repair",
+			repair:     true,
+			mountErrs:  []error{unix.EUCLEAN, unix.EBADMSG},
+			wantMounts: 2,
+			wantRepair: true,
+			wantErr:    &FilesystemError{Repaired: true, Err: unix.EBADMSG},
+		},
+		{
+			name:       "repair fails",
+			repair:     true,
+			mountErrs:  []error{unix.EUCLEAN},
+			repairErr:  errors.New("e2fsck failed"),
+			wantMounts: 1,
+			wantRepair: true,
+			wantErr:    &FilesystemError{Err: unix.EUCLEAN},
+		},
+		{
+			name:       "corrupt without repair",
+			mountErrs:  []error{unix.EUCLEAN},
+			wantMounts: 1,
+			wantErr:    &FilesystemError{Err: unix.EUCLEAN},
+		},
+		{
+			name:       "not corrupt",
+			repair:     true,
+			mountErrs:  []error{unix.EIO},
+			wantMounts: 1,
+			wantErr:    unix.EIO,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clearTestDependencies()
+
+			osMkdirAll = func(path string, perm os.FileMode) error {
+				return nil
+			}
+			osRemoveAll = func(string) error {
+				return nil
+			}
+			getDevicePath = func(ctx context.Context, controller, lun uint8, partition uint64) (string, error) {
+				return "/dev/sdb", nil
+			}
+			_getDeviceFsType = func(string) (string, error) {
+				return "ext4", nil
+			}
+			mounts, mounted := 0, 0
+			unixMount = func(source string, target string, fstype string, flags uintptr, data string) error {
+				mounts++
+				return tc.mountErrs[mounts-1]
+			}
+			unixStatfs = func(path string, st *unix.Statfs_t) error {
+				mounted++
+				if tc.readOnly != nil && tc.readOnly[mounted-1] {
+					st.Flags |= unix.ST_RDONLY
+				}
+				return nil
+			}
+			unixUnmount = func(string, int) error {
+				return nil
+			}
+			repaired := false
+			repairDevice = func(source, fsType string) error {
+				repaired = true
+				return tc.repairErr
+			}
+			klogctl = func(typ int, buf []byte) (int, error) {
+				log := "<3>[   4.2] EXT4-fs (sda): mounted filesystem\n" +
+					"<3>[   4.3] EXT4-fs (sdb): bad geometry: block count exceeds size of device\n"
+				if typ == unix.SYSLOG_ACTION_SIZE_BUFFER {
+					return len(log), nil
+				}
+				return copy(buf, log), nil
+			}
+
+			config := &Config{RepairFilesystem: tc.repair}
+			err := Mount(context.Background(), 0, 0, 0, "/fake/path", false, nil, config)
+			if mounts != tc.wantMounts || repaired != tc.wantRepair {
+				t.Fatalf("expected %d mounts and repair %v, got %d and %v", tc.wantMounts, tc.wantRepair, mounts, repaired)
+			}
+			var fsErr *FilesystemError
+			switch want := tc.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("expected nil err, got: %v", err)
+				}
+			case *FilesystemError:
+				// the HRESULT error only implements Cause
+				if !errors.As(pkgerrors.Cause(err), &fsErr) {
+					t.Fatalf("expected a filesystem error, got: %v", err)
+				}
+				if fsErr.Repaired != want.Repaired || !errors.Is(fsErr.Err, want.Err) || (fsErr.RepairErr != nil) != (tc.repairErr != nil) {
+					t.Fatalf("unexpected filesystem error %+v", fsErr)
+				}
+				if len(fsErr.KernelLog) != 1 || !strings.Contains(fsErr.KernelLog[0], "bad geometry") {
+					t.Fatalf("unexpected kernel log %q", fsErr.KernelLog)
+				}
+				if hr, herr := gcserr.GetHresult(err); herr != nil || hr != gcserr.HrErrDiskCorrupt {
+					t.Fatalf("expected HRESULT %v, got %v: %v", gcserr.HrErrDiskCorrupt, hr, herr)
+				}
+			default:
+				if !errors.Is(err, want) || errors.As(pkgerrors.Cause(err), &fsErr) {
+					t.Fatalf("expected %v, got: %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func Test_Mount_RepairFilesystem_ReadOnly(t *testing.T) {
+	clearTestDependencies()
+
+	// NOTE: Do NOT set getDevicePath or repairDevice because the config is
+	// rejected before the device is looked up. Expect them not to be called.
+	config := &Config{RepairFilesystem: true}
+	if err := Mount(context.Background(), 0, 0, 0, "/fake/path", true, nil, config); err == nil {
+		t.Fatal("expected an error repairing a read-only mount")
+	}
+}
+
+func Test_deviceIsBlank(t *testing.T) {
+	dir := t.TempDir()
+	blank := filepath.Join(dir, "blank")
+	if err := os.WriteFile(blank, make([]byte, 4096), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(dir, "data")
+	b := make([]byte, 4096)
+	b[1080], b[1081] = 0x53, 0xef // ext4 superblock magic
+	if err := os.WriteFile(data, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := deviceIsBlank(blank); err != nil || !ok {
+		t.Fatalf("expected zeroed device to be blank, got %v: %v", ok, err)
+	}
+	if ok, err := deviceIsBlank(data); err != nil || ok {
+		t.Fatalf("expected device with a superblock not to be blank, got %v: %v", ok, err)
+	}
+}
+
+func Test_Mount_BlockDev_Creates_Symlink(t *testing.T) {
+	clearTestDependencies()
+
+	// NOTE: Do NOT set unixMount, _getDeviceFsType or ext4Format because block
+	// devices are not mounted. Expect them not to be called.
+
+	expectedSource := "/dev/sdz"
+	expectedTarget := "/fake/path/blockdev"
+	osMkdirAll = func(path string, perm os.FileMode) error {
+		if path != filepath.Dir(expectedTarget) {
+			t.Errorf("expected parent %q, got %q", filepath.Dir(expectedTarget), path)
+		}
+		return nil
+	}
+	getDevicePath = func(context.Context, uint8, uint8, uint64) (string, error) {
+		return expectedSource, nil
+	}
+	symlinkCalled := false
+	osSymlink = func(oldname, newname string) error {
+		symlinkCalled = true
+		if oldname != expectedSource {
+			t.Errorf("expected symlink source %q, got %q", expectedSource, oldname)
+		}
+		if newname != expectedTarget {
+			t.Errorf("expected symlink target %q, got %q", expectedTarget, newname)
+		}
+		return nil
+	}
+
+	config := &Config{
+		BlockDev: true,
+	}
+	if err := Mount(
+		context.Background(),
+		0,
+		0,
+		0,
+		expectedTarget,
+		true,
+		nil,
+		config,
+	); err != nil {
+		t.Fatalf("expected nil err, got: %v", err)
+	}
+	if !symlinkCalled {
+		t.Fatal("expected osSymlink to be called")
+	}
+}
+
+func Test_Mount_BlockDev_EnsureFilesystem_Error(t *testing.T) {
+	clearTestDependencies()
+
+	getDevicePath = func(context.Context, uint8, uint8, uint64) (string, error) {
+		return "/dev/sdz", nil
+	}
+
+	config := &Config{
+		BlockDev:         true,
+		EnsureFilesystem: true,
+		Filesystem:       "ext4",
+	}
+	if err := Mount(
+		context.Background(),
+		0,
+		0,
+		0,
+		"/fake/path",
+		false,
+		nil,
+		config,
+	); err == nil {
+		t.Fatal("expected error for block device with EnsureFilesystem")
+	}
+}
+
+func Test_Unmount_BlockDev_Removes_Symlink(t *testing.T) {
+	clearTestDependencies()
+
+	// NOTE: Do NOT set storageUnmountPath because block devices are not
+	// mounted. Expect it not to be called.
+
+	expectedTarget := "/fake/path/blockdev"
+	removeAllCalled := false
+	osRemoveAll = func(path string) error {
+		removeAllCalled = true
+		if path != expectedTarget {
+			t.Errorf("expected target %q, got %q", expectedTarget, path)
+		}
+		return nil
+	}
+	removeDeviceCalled := false
+	removeDevice = func(string) error {
+		removeDeviceCalled = true
+		return nil
+	}
+
+	config := &Config{
+		BlockDev: true,
+		VerityInfo: &guestresource.DeviceVerityInfo{
+			RootDigest: "hash",
+		},
+	}
+	if err := Unmount(
+		context.Background(),
+		0,
+		0,
+		0,
+		expectedTarget,
+		config,
+	); err != nil {
+		t.Fatalf("expected nil err, got: %v", err)
+	}
+	if !removeAllCalled {
+		t.Fatal("expected osRemoveAll to be called")
+	}
+	if !removeDeviceCalled {
+		t.Fatal("expected verity target to be removed")
+	}
+}
+
 // dm-verity tests
 
 func Test_CreateVerityTarget_And_Mount_Called_With_Correct_Parameters(t *testing.T) {
@@ -1308,3 +1848,90 @@ func Test_GetDeviceFsType_Error(t *testing.T) {
 		t.Fatalf("expected to return a failure from call to getDeviceFsType, instead got %s", fsType)
 	}
 }
+
+func Test_Mount_BlockSize_Sets_Device_Block_Size(t *testing.T) {
+	clearTestDependencies()
+
+	expectedSource := "/dev/sdz"
+	var expectedSize uint32 = 4096
+	osMkdirAll = func(string, os.FileMode) error {
+		return nil
+	}
+	getDevicePath = func(context.Context, uint8, uint8, uint64) (string, error) {
+		return expectedSource, nil
+	}
+	osSymlink = func(string, string) error {
+		return nil
+	}
+	setBlockSizeCalled := false
+	setDeviceBlockSize = func(devicePath string, size uint32) error {
+		setBlockSizeCalled = true
+		if devicePath != expectedSource {
+			t.Errorf("expected device %q, got %q", expectedSource, devicePath)
+		}
+		if size != expectedSize {
+			t.Errorf("expected block size %d, got %d", expectedSize, size)
+		}
+		return nil
+	}
+
+	config := &Config{
+		BlockDev:  true,
+		BlockSize: expectedSize,
+	}
+	if err := Mount(
+		context.Background(),
+		0,
+		0,
+		0,
+		"/fake/path/blockdev",
+		false,
+		nil,
+		config,
+	); err != nil {
+		t.Fatalf("expected nil err, got: %v", err)
+	}
+	if !setBlockSizeCalled {
+		t.Fatal("expected setDeviceBlockSize to be called")
+	}
+}
+
+func Test_Mount_BlockSize_Error(t *testing.T) {
+	clearTestDependencies()
+
+	// NOTE: Do NOT set osSymlink or unixMount because the mount should fail
+	// before the target is created.
+
+	getDevicePath = func(context.Context, uint8, uint8, uint64) (string, error) {
+		return "/dev/sdz", nil
+	}
+	expectedErr := errors.New("set block size error")
+	setDeviceBlockSize = func(string, uint32) error {
+		return expectedErr
+	}
+
+	config := &Config{
+		BlockDev:  true,
+		BlockSize: 512,
+	}
+	if err := Mount(
+		context.Background(),
+		0,
+		0,
+		0,
+		"/fake/path/blockdev",
+		false,
+		nil,
+		config,
+	); !errors.Is(err, expectedErr) {
+		t.Fatalf("expected err %v, got: %v", expectedErr, err)
+	}
+}
+
+func Test_SetBlockSize_Invalid(t *testing.T) {
+	for _, size := range []uint32{1, 256, 513, 3000, 1 << 20} {
+		if err := setBlockSize("/dev/null", size); err == nil {
+			t.Errorf("expected error for block size %d", size)
+		}
+	}
+}
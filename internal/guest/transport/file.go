@@ -0,0 +1,232 @@
+//go:build linux
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// fileConnection is a [Connection] that writes to a file instead of a socket,
+// rotating the file once it grows past a maximum size. Reads return EOF, as
+// there is no other end to read from.
+type fileConnection struct {
+	// dir is the directory the file is in, and name the name of the file in
+	// it. path is only used in errors and logs.
+	dir        *os.File
+	name       string
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	m    sync.Mutex
+	f    *os.File
+	size int64
+	// refs counts the connection itself and the pipes returned by File that are
+	// still copied to the file, which is closed once none are left.
+	refs   int
+	closed bool
+}
+
+var _ Connection = &fileConnection{}
+
+// NewFileConnection returns a [Connection] that appends the data written to it
+// to the file `name` in the directory `dir`, creating it if needed. The file is
+// only opened, renamed and removed relative to `dir`, which may be an O_PATH
+// handle. The connection uses its own duplicate of `dir`, so the caller keeps
+// ownership of it.
+//
+// If `maxSize` is non-zero, the file is rotated before a write would grow it
+// past `maxSize` bytes: the file is renamed with the suffix ".1", after the
+// previous backups are renamed from ".N" to ".N+1", keeping at most
+// `maxBackups` of them. Without backups, the file is truncated instead.
+func NewFileConnection(dir *os.File, name string, maxSize int64, maxBackups int) (Connection, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, fmt.Errorf("invalid stdio file name %q", name)
+	}
+	fd, err := unix.FcntlInt(dir.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate stdio file directory: %w", err)
+	}
+	c := &fileConnection{
+		dir:        os.NewFile(uintptr(fd), dir.Name()),
+		name:       name,
+		path:       filepath.Join(dir.Name(), name),
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		refs:       1,
+	}
+	if err := c.open(); err != nil {
+		c.dir.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// open opens the file for appending.
+//
+// Must be called with the connection's lock held, or before it is shared.
+func (c *fileConnection) open() error {
+	// do not follow a symlink, which could point out of the directory
+	fd, err := unix.Openat(int(c.dir.Fd()), c.name, unix.O_WRONLY|unix.O_CREAT|unix.O_APPEND|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open stdio file %s: %w", c.path, err)
+	}
+	f := os.NewFile(uintptr(fd), c.path)
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat stdio file: %w", err)
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		return fmt.Errorf("stdio file %s is not a regular file", c.path)
+	}
+	c.f = f
+	c.size = fi.Size()
+	return nil
+}
+
+// rotate closes the file and moves it to the newest backup, or removes it if
+// no backups are kept, then opens a new file in its place.
+//
+// Must be called with the connection's lock held.
+func (c *fileConnection) rotate() error {
+	if err := c.f.Close(); err != nil {
+		logrus.WithFields(logrus.Fields{
+			logrus.ErrorKey: err,
+			"file":          c.path,
+		}).Warn("opengcs::fileConnection::rotate - error closing stdio file")
+	}
+	c.f = nil
+
+	dirfd := int(c.dir.Fd())
+	if c.maxBackups == 0 {
+		if err := unix.Unlinkat(dirfd, c.name, 0); err != nil && !errors.Is(err, unix.ENOENT) {
+			return fmt.Errorf("failed to remove rotated stdio file: %w", err)
+		}
+		return c.open()
+	}
+	for i := c.maxBackups - 1; i > 0; i-- {
+		if err := unix.Renameat(dirfd, c.backup(i), dirfd, c.backup(i+1)); err != nil && !errors.Is(err, unix.ENOENT) {
+			return fmt.Errorf("failed to rotate stdio file backup: %w", err)
+		}
+	}
+	if err := unix.Renameat(dirfd, c.name, dirfd, c.backup(1)); err != nil && !errors.Is(err, unix.ENOENT) {
+		return fmt.Errorf("failed to rotate stdio file: %w", err)
+	}
+	return c.open()
+}
+
+// backup returns the name of the `i`th backup of the file.
+func (c *fileConnection) backup(i int) string {
+	return fmt.Sprintf("%s.%d", c.name, i)
+}
+
+// Write appends buf to the file, rotating it first if it would grow past the
+// maximum size. buf is not split across files, so a single write larger than
+// the maximum size is written in full to a new file.
+func (c *fileConnection) Write(buf []byte) (int, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.f == nil {
+		return 0, os.ErrClosed
+	}
+	if c.maxSize > 0 && c.size > 0 && c.size+int64(len(buf)) > c.maxSize {
+		if err := c.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.f.Write(buf)
+	c.size += int64(n)
+	return n, err
+}
+
+func (c *fileConnection) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (c *fileConnection) CloseRead() error {
+	return nil
+}
+
+func (c *fileConnection) CloseWrite() error {
+	return nil
+}
+
+// Close releases the connection. The file stays open until the pipes returned
+// by File are closed by all their writers.
+func (c *fileConnection) Close() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.release()
+}
+
+// release drops a reference to the file, closing it once none are left.
+//
+// Must be called with the connection's lock held.
+func (c *fileConnection) release() error {
+	c.refs--
+	if c.refs > 0 {
+		return nil
+	}
+	c.dir.Close()
+	if c.f == nil {
+		return nil
+	}
+	err := c.f.Close()
+	c.f = nil
+	return err
+}
+
+// File returns the write end of a pipe whose data is copied to the file, so
+// that the file is still rotated when written to by another process.
+func (c *fileConnection) File() (*os.File, error) {
+	c.m.Lock()
+	if c.f == nil {
+		c.m.Unlock()
+		return nil, os.ErrClosed
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		c.m.Unlock()
+		return nil, err
+	}
+	c.refs++
+	c.m.Unlock()
+
+	go func() {
+		if n, err := io.Copy(c, r); err != nil {
+			logrus.WithFields(logrus.Fields{
+				logrus.ErrorKey: err,
+				"bytes":         n,
+				"file":          c.path,
+			}).Error("opengcs::fileConnection::File - error copying from pipe")
+		}
+		r.Close()
+
+		c.m.Lock()
+		defer c.m.Unlock()
+		if err := c.release(); err != nil {
+			logrus.WithFields(logrus.Fields{
+				logrus.ErrorKey: err,
+				"file":          c.path,
+			}).Warn("opengcs::fileConnection::File - error closing stdio file")
+		}
+	}()
+	return w, nil
+}
@@ -0,0 +1,181 @@
+//go:build linux
+
+package transport
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// openFile returns a connection to the file at `path`, with its directory
+// opened as the GCS does.
+func openFile(t *testing.T, path string, maxSize int64, maxBackups int) (Connection, error) {
+	t.Helper()
+	fd, err := unix.Open(filepath.Dir(path), unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := os.NewFile(uintptr(fd), filepath.Dir(path))
+	defer dir.Close()
+	return NewFileConnection(dir, filepath.Base(path), maxSize, maxBackups)
+}
+
+func TestFileConnection_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := openFile(t, path, 8, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, s := range []string{"a\n", "bb\n", "ccc\n", "dddd\n", "eeeeeeeeee\n"} {
+		if _, err := c.Write([]byte(s)); err != nil {
+			t.Fatalf("failed to write %q: %v", s, err)
+		}
+	}
+
+	// the existing file is appended to, and the oldest backup is dropped
+	for p, want := range map[string]string{
+		path:        "eeeeeeeeee\n",
+		path + ".1": "dddd\n",
+		path + ".2": "bb\nccc\n",
+	} {
+		if got := readFile(t, p); got != want {
+			t.Errorf("%s has %q, expected %q", p, got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected no third backup, got: %v", err)
+	}
+
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF { //nolint:errorlint
+		t.Fatalf("expected EOF reading a file connection, got: %v", err)
+	}
+}
+
+func TestFileConnection_NoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	c, err := openFile(t, path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, s := range []string{"abc", "def"} {
+		if _, err := c.Write([]byte(s)); err != nil {
+			t.Fatalf("failed to write %q: %v", s, err)
+		}
+	}
+	if got := readFile(t, path); got != "def" {
+		t.Fatalf("%s has %q, expected %q", path, got, "def")
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Fatalf("expected no backup, got: %v", err)
+	}
+}
+
+func TestFileConnection_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	c, err := openFile(t, path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := c.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pipe outlives the connection, as it is handed to the process
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	// wait for the pipe to be copied and the file closed
+	fc := c.(*fileConnection)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		fc.m.Lock()
+		closed := fc.f == nil
+		fc.m.Unlock()
+		if closed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the file to be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := readFile(t, path); got != "hello\n" {
+		t.Fatalf("%s has %q, expected %q", path, got, "hello\n")
+	}
+}
+
+func TestFileConnection_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	if err := os.Symlink(target, filepath.Join(dir, "out.log")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openFile(t, filepath.Join(dir, "out.log"), 0, 0); err == nil {
+		t.Fatal("expected an error opening a symlink")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected the symlink target not to be created, got: %v", err)
+	}
+}
+
+func TestFileConnection_DirectorySwapped(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "logs")
+	outside := filepath.Join(root, "outside")
+	for _, d := range []string{dir, outside} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := openFile(t, filepath.Join(dir, "out.log"), 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// swap the directory for a symlink after the connection was created
+	if err := os.Rename(dir, filepath.Join(root, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"abc", "def"} {
+		if _, err := c.Write([]byte(s)); err != nil {
+			t.Fatalf("failed to write %q: %v", s, err)
+		}
+	}
+
+	// the file is still rotated in the original directory
+	if got := readFile(t, filepath.Join(root, "moved", "out.log")); got != "def" {
+		t.Fatalf("rotated file has %q, expected %q", got, "def")
+	}
+	if entries, err := os.ReadDir(outside); err != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing to be written through the symlink, got %v: %v", entries, err)
+	}
+}
@@ -38,4 +38,7 @@ const (
 	WCOWGlobalMountPrefixFmt = "C:\\mounts\\m%d"
 	// RootfsPath is part of the container's rootfs path
 	RootfsPath = "rootfs"
+	// LCOWDefaultPathEnv is the PATH of a process in the LCOW UVM, or in one of
+	// its containers, that does not set one
+	LCOWDefaultPathEnv = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
 )
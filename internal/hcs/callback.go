@@ -11,6 +11,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/vmcompute"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
 )
 
 var (
@@ -92,6 +93,9 @@ type notificationChannel chan error
 type notificationWatcherContext struct {
 	channels notificationChannels
 	handle   vmcompute.HcsCallback
+	// events receives all the notifications of a compute system, and is nil for
+	// processes.
+	events *eventStream
 
 	systemID  string
 	processID int
@@ -159,5 +163,20 @@ func notificationWatcher(notificationType hcsNotification, callbackNumber uintpt
 		channel <- result
 	}
 
+	if context.events != nil {
+		var data string
+		if notificationData != nil {
+			data = windows.UTF16PtrToString(notificationData)
+		}
+		context.events.send(ContainerEvent{
+			Notification: ContainerNotification(notificationType),
+			Err:          result,
+			Data:         data,
+		})
+		if notificationType == hcsNotificationSystemExited {
+			context.events.close()
+		}
+	}
+
 	return 0
 }
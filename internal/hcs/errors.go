@@ -17,6 +17,9 @@ var (
 	// ErrComputeSystemDoesNotExist is an error encountered when the container being operated on no longer exists
 	ErrComputeSystemDoesNotExist = syscall.Errno(0xc037010e)
 
+	// ErrComputeSystemAlreadyExists is an error encountered when creating a container with the id of one that already exists
+	ErrComputeSystemAlreadyExists = syscall.Errno(0xc037010f)
+
 	// ErrElementNotFound is an error encountered when the object being referenced does not exist
 	ErrElementNotFound = syscall.Errno(0x490)
 
@@ -33,6 +36,9 @@ var (
 	// ErrAlreadyClosed is an error encountered when using a handle that has been closed by the Close method
 	ErrAlreadyClosed = errors.New("hcsshim: the handle has already been closed")
 
+	// ErrNotRunning is an error encountered when querying the runtime state of a compute system that is not running
+	ErrNotRunning = errors.New("hcsshim: the compute system is not running")
+
 	// ErrInvalidNotificationType is an error encountered when an invalid notification type is used
 	ErrInvalidNotificationType = errors.New("hcsshim: invalid notification type")
 
@@ -53,9 +59,21 @@ var (
 	// ErrUnexpectedValue is an error encountered when hcs returns an invalid value
 	ErrUnexpectedValue = errors.New("unexpected value returned from hcs")
 
+	// ErrPropertyNotReported is an error encountered when hcs does not return a queried
+	// property, such as on OS builds that do not support it
+	ErrPropertyNotReported = errors.New("property not reported by hcs")
+
 	// ErrOperationDenied is an error when hcs attempts an operation that is explicitly denied
 	ErrOperationDenied = errors.New("operation denied")
 
+	// ErrIncompatibleSchema is an error encountered when a compute system cannot be used
+	// with a schema version in the range that was requested
+	ErrIncompatibleSchema = errors.New("hcsshim: the compute system does not support a compatible schema version")
+
+	// ErrInvalidConsoleSize is an error encountered when a console is resized to a zero
+	// width or height
+	ErrInvalidConsoleSize = errors.New("console width and height must be non-zero")
+
 	// ErrVmcomputeAlreadyStopped is an error encountered when a shutdown or terminate request is made on a stopped container
 	ErrVmcomputeAlreadyStopped = syscall.Errno(0xc0370110)
 
@@ -90,6 +108,14 @@ var (
 	// ErrInvalidHandle is an error that can be encountered when querying the properties of a compute system when the handle to that
 	// compute system has already been closed.
 	ErrInvalidHandle = syscall.Errno(0x6)
+
+	// ErrNoneMapped is an error encountered when an account name cannot be mapped to a security ID,
+	// such as when a process is created as a user that does not exist in the container.
+	// decimal -2147023564 / hex 0x80070534
+	ErrNoneMapped = syscall.Errno(0x534)
+
+	// ErrNoSuchUser is an error encountered when a specified user does not exist.
+	ErrNoSuchUser = syscall.Errno(0x525)
 )
 
 type ErrorEvent struct {
@@ -260,6 +286,13 @@ func IsNotExist(err error) bool {
 	return IsAny(err, ErrComputeSystemDoesNotExist, ErrElementNotFound)
 }
 
+// IsAlreadyExists checks if an error is caused by creating a Container with the
+// id of a Container that already exists, such as one left behind in a utility VM
+// by an earlier attempt to create it.
+func IsAlreadyExists(err error) bool {
+	return errors.Is(err, ErrComputeSystemAlreadyExists)
+}
+
 // IsErrorInvalidHandle checks whether the error is the result of an operation carried
 // out on a handle that is invalid/closed. This error popped up while trying to query
 // stats on a container in the process of being stopped.
@@ -325,6 +358,12 @@ func IsAccessIsDenied(err error) bool {
 	return errors.Is(err, ErrVmcomputeOperationAccessIsDenied)
 }
 
+// IsNoSuchUser returns true when err is caused by a user that does not exist,
+// either `ErrNoneMapped` or `ErrNoSuchUser`.
+func IsNoSuchUser(err error) bool {
+	return IsAny(err, ErrNoneMapped, ErrNoSuchUser)
+}
+
 // IsAny is a vectorized version of [errors.Is], it returns true if err is one of targets.
 func IsAny(err error, targets ...error) bool {
 	for _, e := range targets {
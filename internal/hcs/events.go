@@ -0,0 +1,105 @@
+//go:build windows
+
+package hcs
+
+import (
+	"sync"
+)
+
+// eventsBufferSize is the number of events buffered for [System.Events]. Once
+// the buffer is full the oldest event is dropped to make room for a new one.
+const eventsBufferSize = 16
+
+// ContainerNotification is the type of a notification HCS sends for a compute
+// system.
+type ContainerNotification uint32
+
+var (
+	// ContainerNotificationExited is sent when the compute system exits. It is
+	// the last event sent for a compute system.
+	ContainerNotificationExited = ContainerNotification(hcsNotificationSystemExited)
+	// ContainerNotificationCreateCompleted is sent when the compute system is created.
+	ContainerNotificationCreateCompleted = ContainerNotification(hcsNotificationSystemCreateCompleted)
+	// ContainerNotificationStartCompleted is sent when the compute system is started.
+	ContainerNotificationStartCompleted = ContainerNotification(hcsNotificationSystemStartCompleted)
+	// ContainerNotificationPauseCompleted is sent when the compute system is paused.
+	ContainerNotificationPauseCompleted = ContainerNotification(hcsNotificationSystemPauseCompleted)
+	// ContainerNotificationResumeCompleted is sent when the compute system is resumed.
+	ContainerNotificationResumeCompleted = ContainerNotification(hcsNotificationSystemResumeCompleted)
+	// ContainerNotificationSaveCompleted is sent when the compute system is saved.
+	ContainerNotificationSaveCompleted = ContainerNotification(hcsNotificationSystemSaveCompleted)
+	// ContainerNotificationCrashInitiated is sent when the compute system starts
+	// to crash.
+	ContainerNotificationCrashInitiated = ContainerNotification(hcsNotificationSystemCrashInitiated)
+	// ContainerNotificationCrashReport is sent with the crash report of the
+	// compute system.
+	ContainerNotificationCrashReport = ContainerNotification(hcsNotificationSystemCrashReport)
+	// ContainerNotificationShutdownFailed is sent if the compute system failed to
+	// shutdown.
+	ContainerNotificationShutdownFailed = ContainerNotification(hcsNotificationSystemShutdownFailed)
+	// ContainerNotificationGuestConnectionClosed is sent when the connection between
+	// HCS and the guest of the compute system is closed.
+	ContainerNotificationGuestConnectionClosed = ContainerNotification(hcsNotificationSystemGuestConnectionClosed)
+	// ContainerNotificationServiceDisconnect is sent if the connection to HCS is lost.
+	ContainerNotificationServiceDisconnect = ContainerNotification(hcsNotificationServiceDisconnect)
+)
+
+func (n ContainerNotification) String() string {
+	return hcsNotification(n).String()
+}
+
+// ContainerEvent is a notification received from HCS for a compute system.
+type ContainerEvent struct {
+	// Notification is the type of the notification.
+	Notification ContainerNotification
+	// Err is the error HCS reported with the notification, if any. For example,
+	// the exit notification of a compute system that exited unexpectedly has
+	// [ErrVmcomputeUnexpectedExit].
+	Err error
+	// Data is the JSON document HCS sent with the notification, if any.
+	Data string
+}
+
+// eventStream buffers the events of a compute system for [System.Events].
+type eventStream struct {
+	mu     sync.Mutex
+	ch     chan ContainerEvent
+	closed bool
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{ch: make(chan ContainerEvent, eventsBufferSize)}
+}
+
+// send adds `e` to the stream without blocking, dropping the oldest event if the
+// stream is full. Events sent after the stream is closed are dropped.
+func (s *eventStream) send(e ContainerEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	for {
+		select {
+		case s.ch <- e:
+			return
+		default:
+		}
+		select {
+		case <-s.ch:
+		default:
+		}
+	}
+}
+
+// close closes the stream's channel. It is safe to call multiple times.
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package hcs
+
+import (
+	"testing"
+)
+
+func TestEventStreamDropsOldest(t *testing.T) {
+	s := newEventStream()
+	n := eventsBufferSize + 3
+	for i := 0; i < n; i++ {
+		s.send(ContainerEvent{Notification: ContainerNotification(i)})
+	}
+	s.close()
+	// sends after close must not panic
+	s.send(ContainerEvent{Notification: ContainerNotificationExited})
+	s.close()
+
+	var got []ContainerNotification
+	for e := range s.ch {
+		got = append(got, e.Notification)
+	}
+	if len(got) != eventsBufferSize {
+		t.Fatalf("expected %d events, got %d: %v", eventsBufferSize, len(got), got)
+	}
+	for i, n := range got {
+		if want := ContainerNotification(i + 3); n != want {
+			t.Fatalf("expected event %d to be %d, got %d", i, want, n)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -280,13 +281,17 @@ func (process *Process) stopped() bool {
 	}
 }
 
-// ResizeConsole resizes the console of the process.
+// ResizeConsole resizes the console of the process. Both `width` and `height` must be
+// non-zero.
 func (process *Process) ResizeConsole(ctx context.Context, width, height uint16) error {
 	process.handleLock.RLock()
 	defer process.handleLock.RUnlock()
 
 	operation := "hcs::Process::ResizeConsole"
 
+	if width == 0 || height == 0 {
+		return makeProcessError(process, operation, fmt.Errorf("%w: got %dx%d", ErrInvalidConsoleSize, width, height), nil)
+	}
 	if process.handle == 0 {
 		return makeProcessError(process, operation, ErrAlreadyClosed, nil)
 	}
@@ -0,0 +1,138 @@
+//go:build windows
+
+package hcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"go.opencensus.io/trace"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oc"
+)
+
+// GuestConnection is the state of the connection between HCS and the guest of a compute
+// system.
+type GuestConnection struct {
+	// Connected is set if HCS reported a connection to the guest. It is never set for
+	// process isolated containers, or on OS builds that do not report the connection.
+	Connected bool
+	// Info describes the guest connection, and is nil if not Connected.
+	Info *hcsschema.GuestConnectionInfo
+}
+
+// queryProperty queries HCS for the property type `pt` and decodes only the top-level
+// property `key` of the result into v. Other properties in the result are not decoded,
+// so fields that an OS build reports in an unexpected form cannot fail the query.
+//
+// Returns false if HCS did not report `key`.
+func (computeSystem *System) queryProperty(
+	ctx context.Context,
+	operation string,
+	pt hcsschema.PropertyType,
+	key string,
+	v interface{},
+) (bool, error) {
+	computeSystem.handleLock.RLock()
+	defer computeSystem.handleLock.RUnlock()
+
+	raw := map[string]json.RawMessage{}
+	if err := computeSystem.hcsPropertiesV2QueryInto(ctx, operation, []hcsschema.PropertyType{pt}, &raw); err != nil {
+		return false, err
+	}
+	b, ok := raw[key]
+	if !ok || string(b) == "null" {
+		return false, nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, makeSystemError(computeSystem, operation, fmt.Errorf("decode %s: %w", key, err), nil)
+	}
+	return true, nil
+}
+
+// Statistics returns the runtime statistics of the compute system. The processor, memory
+// and storage statistics are nil if the compute system does not report them.
+//
+// The statistics of process isolated containers are queried from their silo directly if
+// possible, as in [System.PropertiesV2].
+func (computeSystem *System) Statistics(ctx context.Context) (_ *hcsschema.Statistics, err error) {
+	operation := "hcs::System::Statistics"
+
+	ctx, span := oc.StartSpan(ctx, operation)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute("cid", computeSystem.id))
+
+	if computeSystem.typ == "container" {
+		props := &hcsschema.Properties{}
+		fallback, err := computeSystem.queryInProc(ctx, props, []hcsschema.PropertyType{hcsschema.PTStatistics})
+		if err == nil && len(fallback) == 0 && props.Statistics != nil {
+			return props.Statistics, nil
+		}
+		logEntry := log.G(ctx)
+		if err != nil {
+			logEntry = logEntry.WithError(fmt.Errorf("failed to query statistics in-proc: %w", err))
+		}
+		logEntry.Debug("falling back to HCS for statistics query")
+	}
+
+	s := &hcsschema.Statistics{}
+	ok, err := computeSystem.queryProperty(ctx, operation, hcsschema.PTStatistics, "Statistics", s)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, makeSystemError(computeSystem, operation, ErrPropertyNotReported, nil)
+	}
+	return s, nil
+}
+
+// GuestConnectionInfo returns the state of the connection between HCS and the guest of
+// the compute system.
+func (computeSystem *System) GuestConnectionInfo(ctx context.Context) (_ *GuestConnection, err error) {
+	operation := "hcs::System::GuestConnectionInfo"
+
+	ctx, span := oc.StartSpan(ctx, operation)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute("cid", computeSystem.id))
+
+	info := &hcsschema.GuestConnectionInfo{}
+	ok, err := computeSystem.queryProperty(ctx, operation, hcsschema.PTGuestConnection, "GuestConnectionInfo", info)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &GuestConnection{}, nil
+	}
+	return &GuestConnection{Connected: true, Info: info}, nil
+}
+
+// SiloGUID returns the GUID of the silo backing a container compute system, which is used
+// to address the container over HvSocket.
+func (computeSystem *System) SiloGUID(ctx context.Context) (_ guid.GUID, err error) {
+	operation := "hcs::System::SiloGUID"
+
+	ctx, span := oc.StartSpan(ctx, operation)
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute("cid", computeSystem.id))
+
+	var s string
+	ok, err := computeSystem.queryProperty(ctx, operation, hcsschema.PTSystemGUID, "SystemGUID", &s)
+	if err != nil {
+		return guid.GUID{}, err
+	}
+	if !ok || s == "" {
+		return guid.GUID{}, makeSystemError(computeSystem, operation, ErrPropertyNotReported, nil)
+	}
+	g, err := guid.FromString(s)
+	if err != nil {
+		return guid.GUID{}, makeSystemError(computeSystem, operation, fmt.Errorf("parse silo GUID %q: %w", s, err), nil)
+	}
+	return g, nil
+}
@@ -0,0 +1,17 @@
+/*
+ * HCS API
+ *
+ * No description provided (generated by Swagger Codegen https://github.com/swagger-api/swagger-codegen)
+ *
+ * API version: 2.1
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+
+package hcsschema
+
+//  Basic information about the host compute service.
+type BasicInformation struct {
+
+	//  Each schema version x.y stands for the range of versions a.b where a==x  and b<=y.
+	SupportedSchemaVersions []Version `json:"SupportedSchemaVersions,omitempty"`
+}
@@ -43,4 +43,16 @@ type ProcessParameters struct {
 
 	//  if set, use the legacy console instead of conhost
 	UseLegacyConsole bool `json:"UseLegacyConsole,omitempty"`
+
+	//  capabilities to add to a process exec'd in a container, currently only supported by Linux GCS
+	ExtraCapabilities []string `json:"ExtraCapabilities,omitempty"`
+
+	//  capabilities to remove from a process exec'd in a container, currently only supported by Linux GCS
+	DropCapabilities []string `json:"DropCapabilities,omitempty"`
+
+	//  shells to try in order if a terminal process exec'd in a container requests one of them and it does not exist, currently only supported by Linux GCS
+	ShellCandidates []string `json:"ShellCandidates,omitempty"`
+
+	//  if set, create WorkingDirectory and any missing parents if it does not exist, currently only supported by Linux GCS
+	WorkingDirectoryCreateIfMissing bool `json:"WorkingDirectoryCreateIfMissing,omitempty"`
 }
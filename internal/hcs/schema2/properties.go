@@ -53,4 +53,26 @@ type Properties struct {
 	// Metrics is not part of the API for HCS but this is used for LCOW v2 to
 	// return the full cgroup metrics from the guest.
 	Metrics *v1.Metrics `json:"LCOWMetrics,omitempty"`
+
+	// ScratchUsageBytes is not part of the API for HCS but this is used for
+	// LCOW v2 to return the scratch usage of containers with a scratch quota.
+	ScratchUsageBytes uint64 `json:"LCOWScratchUsageBytes,omitempty"`
+
+	// Containers is not part of the API for HCS but this is used for LCOW v2
+	// to return the properties of the containers of a query with
+	// [PropertyQuery.ContainerIDs], by container ID.
+	Containers map[string]*Properties `json:"LCOWContainers,omitempty"`
+
+	// ContainerErrors is not part of the API for HCS but this is used for LCOW
+	// v2 to return why the properties of containers of a query with
+	// [PropertyQuery.ContainerIDs] could not be queried, by container ID.
+	ContainerErrors map[string]PropertiesError `json:"LCOWContainerErrors,omitempty"`
+}
+
+// PropertiesError is not part of the API for HCS but this is used for LCOW v2
+// to return why the properties of a container could not be queried.
+type PropertiesError struct {
+	Result int32 `json:"Result"`
+
+	Message string `json:"Message,omitempty"`
 }
@@ -12,4 +12,8 @@ package hcsschema
 //   By default the basic properties will be returned. This query provides a way to  request specific properties.
 type PropertyQuery struct {
 	PropertyTypes []PropertyType `json:"PropertyTypes,omitempty"`
+
+	// ContainerIDs is not part of the API for HCS but this is used for LCOW v2
+	// to query the properties of several containers in one request.
+	ContainerIDs []string `json:"LCOWContainerIds,omitempty"`
 }
@@ -24,4 +24,5 @@ const (
 	PTProcessorTopology           PropertyType = "ProcessorTopology"
 	PTCPUGroup                    PropertyType = "CpuGroup"
 	PTSystemGUID                  PropertyType = "SystemGUID"
+	PTBasic                       PropertyType = "Basic" // This field is not generated by swagger. This was added manually.
 )
@@ -0,0 +1,48 @@
+package hcsschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseVersion parses `v`, a schema version in the format "Major.Minor", such as
+// "2.1", which annotations and command line flags use.
+func ParseVersion(v string) (Version, error) {
+	major, minor, ok := strings.Cut(v, ".")
+	if !ok {
+		return Version{}, fmt.Errorf("invalid schema version %q: expected Major.Minor", v)
+	}
+	// the fields are signed, but a version is never negative
+	maj, err := strconv.ParseUint(major, 10, 31)
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid schema version %q major: %w", v, err)
+	}
+	mnr, err := strconv.ParseUint(minor, 10, 31)
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid schema version %q minor: %w", v, err)
+	}
+	return Version{Major: int32(maj), Minor: int32(mnr)}, nil
+}
+
+// String returns the version in the format "Major.Minor".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// UnmarshalJSON decodes the version from either the string "Major.Minor" of
+// [ParseVersion], or the object {"Major":2,"Minor":1}.
+//
+// There is no MarshalJSON: the version is always encoded as an object, since
+// that is the only format HCS and the GCS decode it from.
+func (v *Version) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v, err = ParseVersion(s)
+		return err
+	}
+	// the object format, with a distinct type to not recurse into this method
+	type version Version
+	return json.Unmarshal(b, (*version)(v))
+}
@@ -0,0 +1,59 @@
+package hcsschema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVersion_JSON(t *testing.T) {
+	b, err := json.Marshal(Version{Major: 2, Minor: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"Major":2,"Minor":1}` {
+		t.Fatalf("expected the object format that HCS decodes, got %s", b)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		json     string
+		expected Version
+		valid    bool
+	}{
+		{name: "String", json: `"2.1"`, expected: Version{Major: 2, Minor: 1}, valid: true},
+		{name: "Object", json: `{"Major":2,"Minor":1}`, expected: Version{Major: 2, Minor: 1}, valid: true},
+		{name: "ObjectOmittedMinor", json: `{"Major":2}`, expected: Version{Major: 2}, valid: true},
+		{name: "NoMinor", json: `"2"`},
+		{name: "Negative", json: `"-2.1"`},
+		{name: "NotANumber", json: `"2.x"`},
+		{name: "Extra", json: `"2.1.0"`},
+		{name: "Number", json: `2`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var v Version
+			err := json.Unmarshal([]byte(tc.json), &v)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected %s to be invalid, got %v", tc.json, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected %s to be valid, got: %v", tc.json, err)
+			}
+			if v != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (Version{Major: 2, Minor: 5}) || v.String() != "2.5" {
+		t.Fatalf("expected version 2.5, got %v", v)
+	}
+}
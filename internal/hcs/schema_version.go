@@ -0,0 +1,121 @@
+//go:build windows
+
+package hcs
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/schemaversion"
+	"github.com/Microsoft/hcsshim/osversion"
+)
+
+// SchemaVersion is a version of the HCS schema that the documents of compute systems and
+// the requests to them are in.
+type SchemaVersion = hcsschema.Version
+
+var (
+	// SchemaV10 is the first schema version, which is the only one before RS5.
+	SchemaV10 = *schemaversion.SchemaV10()
+	// SchemaV25 is the highest schema version that hcsshim creates compute systems with.
+	SchemaV25 = *schemaversion.SchemaV25()
+)
+
+// supportedSchemaVersions caches the schema versions that the host compute service
+// supports, which do not change while it runs. Only a successful query is cached.
+var supportedSchemaVersions struct {
+	sync.Mutex
+	versions []SchemaVersion
+}
+
+// SupportedSchemaVersions returns the schema versions that the host compute service
+// supports, and so that compute systems on this host can be used with. Each version x.y
+// stands for all the versions x.b with b <= y.
+//
+// HCS does not report the schema version that an individual compute system was created
+// with, and it accepts requests to any compute system in the versions it supports.
+func SupportedSchemaVersions(ctx context.Context) ([]SchemaVersion, error) {
+	// the Basic service property is only reported from RS5, before which only the first
+	// schema version was supported
+	if osversion.Build() < osversion.RS5 {
+		return []SchemaVersion{SchemaV10}, nil
+	}
+
+	supportedSchemaVersions.Lock()
+	defer supportedSchemaVersions.Unlock()
+	if supportedSchemaVersions.versions == nil {
+		versions, err := querySupportedSchemaVersions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		supportedSchemaVersions.versions = versions
+	}
+	return slices.Clone(supportedSchemaVersions.versions), nil
+}
+
+// querySupportedSchemaVersions queries the host compute service for the schema versions
+// that it supports.
+func querySupportedSchemaVersions(ctx context.Context) ([]SchemaVersion, error) {
+
+	q := hcsschema.PropertyQuery{
+		PropertyTypes: []hcsschema.PropertyType{hcsschema.PTBasic},
+	}
+	serviceProps, err := GetServiceProperties(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve host compute service basic information: %w", err)
+	}
+	if len(serviceProps.Properties) != 1 {
+		return nil, errors.New("wrong number of service properties present")
+	}
+	info := &hcsschema.BasicInformation{}
+	if err := json.Unmarshal(serviceProps.Properties[0], info); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal host compute service basic information: %w", err)
+	}
+	if len(info.SupportedSchemaVersions) == 0 {
+		return nil, ErrPropertyNotReported
+	}
+	return info.SupportedSchemaVersions, nil
+}
+
+// compareSchemaVersions returns -1, 0, or 1 if a is lower than, equal to, or higher than b.
+func compareSchemaVersions(a, b SchemaVersion) int {
+	if c := cmp.Compare(a.Major, b.Major); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Minor, b.Minor)
+}
+
+// schemaVersionInRange returns if any of the versions in `supported`, each of which stands
+// for a range of versions as described by [SupportedSchemaVersions], is from `minVersion`
+// to `maxVersion`.
+func schemaVersionInRange(supported []SchemaVersion, minVersion, maxVersion SchemaVersion) bool {
+	for _, v := range supported {
+		// the highest version that v stands for that is not above maxVersion
+		highest := v
+		if compareSchemaVersions(v, maxVersion) > 0 {
+			if v.Major != maxVersion.Major {
+				continue
+			}
+			highest.Minor = maxVersion.Minor
+		}
+		if compareSchemaVersions(highest, minVersion) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func formatSchemaVersions(vs []SchemaVersion) string {
+	s := make([]string, 0, len(vs))
+	for _, v := range vs {
+		s = append(s, v.String())
+	}
+	return strings.Join(s, ", ")
+}
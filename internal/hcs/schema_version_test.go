@@ -0,0 +1,35 @@
+//go:build windows
+
+package hcs
+
+import (
+	"testing"
+)
+
+func TestSchemaVersionInRange(t *testing.T) {
+	v := func(major, minor int32) SchemaVersion {
+		return SchemaVersion{Major: major, Minor: minor}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		supported []SchemaVersion
+		min, max  SchemaVersion
+		want      bool
+	}{
+		{name: "none supported", min: v(1, 0), max: v(2, 5)},
+		{name: "exact", supported: []SchemaVersion{v(2, 1)}, min: v(2, 1), max: v(2, 1), want: true},
+		{name: "lower minor supported", supported: []SchemaVersion{v(2, 5)}, min: v(2, 0), max: v(2, 1), want: true},
+		{name: "below min", supported: []SchemaVersion{v(2, 1)}, min: v(2, 5), max: v(2, 5)},
+		{name: "above max", supported: []SchemaVersion{v(3, 0)}, min: v(1, 0), max: v(2, 5)},
+		{name: "first version", supported: []SchemaVersion{v(1, 0)}, min: SchemaV10, max: SchemaV25, want: true},
+		{name: "any of several", supported: []SchemaVersion{v(1, 0), v(2, 1)}, min: v(2, 0), max: v(2, 5), want: true},
+		{name: "inverted range", supported: []SchemaVersion{v(2, 5)}, min: v(2, 5), max: v(2, 1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := schemaVersionInRange(tc.supported, tc.min, tc.max); got != tc.want {
+				t.Fatalf("got %t, expected %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -12,13 +12,17 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Microsoft/go-winio/pkg/guid"
 	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/hcs/resourcepaths"
 	"github.com/Microsoft/hcsshim/internal/hcs/schema1"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/jobobject"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/oplimit"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/timeout"
 	"github.com/Microsoft/hcsshim/internal/vmcompute"
 	"github.com/sirupsen/logrus"
@@ -30,6 +34,7 @@ type System struct {
 	handle         vmcompute.HcsSystem
 	id             string
 	callbackNumber uintptr
+	events         *eventStream
 
 	closedWaitOnce sync.Once
 	waitBlock      chan struct{}
@@ -37,6 +42,10 @@ type System struct {
 	exitError      error
 	os, typ, owner string
 	startTime      time.Time
+
+	// runtimeID is the cached result of GetRuntimeID, protected by runtimeIDLock.
+	runtimeIDLock sync.Mutex
+	runtimeID     string
 }
 
 var _ cow.Container = &System{}
@@ -45,6 +54,7 @@ var _ cow.ProcessHost = &System{}
 func newSystem(id string) *System {
 	return &System{
 		id:        id,
+		events:    newEventStream(),
 		waitBlock: make(chan struct{}),
 	}
 }
@@ -65,6 +75,12 @@ func CreateComputeSystem(ctx context.Context, id string, hcsDocumentInterface in
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("cid", id))
 
+	release, err := oplimit.Acquire(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	computeSystem := newSystem(id)
 
 	hcsDocumentB, err := json.Marshal(hcsDocumentInterface)
@@ -137,6 +153,35 @@ func OpenComputeSystem(ctx context.Context, id string) (*System, error) {
 	return computeSystem, nil
 }
 
+// OpenComputeSystemWithVersion opens an existing compute system by ID, and checks that
+// it can be used with a schema version from `minVersion` to `maxVersion`, as reported by
+// [SupportedSchemaVersions].
+//
+// Returns [ErrIncompatibleSchema] if no schema version in the range is supported.
+func OpenComputeSystemWithVersion(ctx context.Context, id string, minVersion, maxVersion SchemaVersion) (_ *System, err error) {
+	operation := "hcs::OpenComputeSystem"
+
+	computeSystem, err := OpenComputeSystem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			computeSystem.Close()
+		}
+	}()
+	versions, err := SupportedSchemaVersions(ctx)
+	if err != nil {
+		return nil, makeSystemError(computeSystem, operation, err, nil)
+	}
+	if !schemaVersionInRange(versions, minVersion, maxVersion) {
+		err = fmt.Errorf("%w: supported versions %s, requested %s to %s", ErrIncompatibleSchema,
+			formatSchemaVersions(versions), minVersion, maxVersion)
+		return nil, makeSystemError(computeSystem, operation, err, nil)
+	}
+	return computeSystem, nil
+}
+
 func (computeSystem *System) getCachedProperties(ctx context.Context) error {
 	props, err := computeSystem.Properties(ctx)
 	if err != nil {
@@ -201,6 +246,12 @@ func (computeSystem *System) Start(ctx context.Context) (err error) {
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("cid", computeSystem.id))
 
+	release, err := oplimit.Acquire(ctx, operation)
+	if err != nil {
+		return makeSystemError(computeSystem, operation, err, nil)
+	}
+	defer release()
+
 	computeSystem.handleLock.RLock()
 	defer computeSystem.handleLock.RUnlock()
 
@@ -297,6 +348,16 @@ func (computeSystem *System) waitBackground() {
 	oc.SetSpanStatus(span, err)
 }
 
+// Events returns a channel of the notifications HCS sends for the compute system,
+// such as its start and exit, so callers need not poll its properties.
+//
+// The channel buffers a limited number of events, and drops the oldest if it is
+// full. It is closed after the exit event, or when the compute system is closed.
+// Events are not duplicated across callers, so there should be a single reader.
+func (computeSystem *System) Events() <-chan ContainerEvent {
+	return computeSystem.events.ch
+}
+
 func (computeSystem *System) WaitChannel() <-chan struct{} {
 	return computeSystem.waitBlock
 }
@@ -377,6 +438,36 @@ func (computeSystem *System) Properties(ctx context.Context, types ...schema1.Pr
 	return properties, nil
 }
 
+// GetRuntimeID returns the runtime ID of the compute system, which for a
+// utility VM is the ID of its Hyper-V VM. It is queried from HCS on the first
+// call and cached after that.
+//
+// HCS assigns the runtime ID when the compute system is created, so it can be
+// queried before the compute system is started, but not once it has stopped:
+// that fails with [ErrNotRunning].
+func (computeSystem *System) GetRuntimeID(ctx context.Context) (string, error) {
+	computeSystem.runtimeIDLock.Lock()
+	defer computeSystem.runtimeIDLock.Unlock()
+
+	if computeSystem.runtimeID != "" {
+		return computeSystem.runtimeID, nil
+	}
+
+	operation := "hcs::System::GetRuntimeID"
+	if computeSystem.stopped() {
+		return "", makeSystemError(computeSystem, operation, ErrNotRunning, nil)
+	}
+	props, err := computeSystem.Properties(ctx)
+	if err != nil {
+		return "", err
+	}
+	if props.Stopped || props.RuntimeID == (guid.GUID{}) {
+		return "", makeSystemError(computeSystem, operation, ErrNotRunning, nil)
+	}
+	computeSystem.runtimeID = props.RuntimeID.String()
+	return computeSystem.runtimeID, nil
+}
+
 // queryInProc handles querying for container properties without reaching out to HCS. `props`
 // will be updated to contain any data returned from the queries present in `types`. If any properties
 // failed to be queried they will be tallied up and returned in as the first return value. Failures on
@@ -490,32 +581,38 @@ func (computeSystem *System) statisticsInProc(job *jobobject.JobObject) (*hcssch
 
 // hcsPropertiesV2Query is a helper to make a HcsGetComputeSystemProperties call using the V2 schema property types.
 func (computeSystem *System) hcsPropertiesV2Query(ctx context.Context, types []hcsschema.PropertyType) (*hcsschema.Properties, error) {
-	operation := "hcs::System::PropertiesV2"
+	props := &hcsschema.Properties{}
+	if err := computeSystem.hcsPropertiesV2QueryInto(ctx, "hcs::System::PropertiesV2", types, props); err != nil {
+		return nil, err
+	}
+	return props, nil
+}
 
+// hcsPropertiesV2QueryInto makes a HcsGetComputeSystemProperties call using the V2 schema
+// property types and decodes the result into v.
+func (computeSystem *System) hcsPropertiesV2QueryInto(ctx context.Context, operation string, types []hcsschema.PropertyType, v interface{}) error {
 	if computeSystem.handle == 0 {
-		return nil, makeSystemError(computeSystem, operation, ErrAlreadyClosed, nil)
+		return makeSystemError(computeSystem, operation, ErrAlreadyClosed, nil)
 	}
 
 	queryBytes, err := json.Marshal(hcsschema.PropertyQuery{PropertyTypes: types})
 	if err != nil {
-		return nil, makeSystemError(computeSystem, operation, err, nil)
+		return makeSystemError(computeSystem, operation, err, nil)
 	}
 
 	propertiesJSON, resultJSON, err := vmcompute.HcsGetComputeSystemProperties(ctx, computeSystem.handle, string(queryBytes))
 	events := processHcsResult(ctx, resultJSON)
 	if err != nil {
-		return nil, makeSystemError(computeSystem, operation, err, events)
+		return makeSystemError(computeSystem, operation, err, events)
 	}
 
 	if propertiesJSON == "" {
-		return nil, ErrUnexpectedValue
+		return ErrUnexpectedValue
 	}
-	props := &hcsschema.Properties{}
-	if err := json.Unmarshal([]byte(propertiesJSON), props); err != nil {
-		return nil, makeSystemError(computeSystem, operation, err, nil)
+	if err := json.Unmarshal([]byte(propertiesJSON), v); err != nil {
+		return makeSystemError(computeSystem, operation, err, nil)
 	}
-
-	return props, nil
+	return nil
 }
 
 // PropertiesV2 returns the requested compute systems properties targeting a V2 schema compute system.
@@ -678,10 +775,16 @@ func (computeSystem *System) createProcess(ctx context.Context, operation string
 	processInfo, processHandle, resultJSON, err := vmcompute.HcsCreateProcess(ctx, computeSystem.handle, configuration)
 	events := processHcsResult(ctx, resultJSON)
 	if err != nil {
+		user := ""
 		if v2, ok := c.(*hcsschema.ProcessParameters); ok {
 			operation += ": " + v2.CommandLine
+			user = v2.User
 		} else if v1, ok := c.(*schema1.ProcessConfig); ok {
 			operation += ": " + v1.CommandLine
+			user = v1.User
+		}
+		if user != "" && IsNoSuchUser(err) {
+			err = fmt.Errorf("user %q does not exist in the container: %w", user, err)
 		}
 		return nil, nil, makeSystemError(computeSystem, operation, err, events)
 	}
@@ -773,6 +876,7 @@ func (computeSystem *System) CloseCtx(ctx context.Context) (err error) {
 	if err = computeSystem.unregisterCallback(ctx); err != nil {
 		return makeSystemError(computeSystem, operation, err, nil)
 	}
+	computeSystem.events.close()
 
 	err = vmcompute.HcsCloseComputeSystem(ctx, computeSystem.handle)
 	if err != nil {
@@ -791,6 +895,7 @@ func (computeSystem *System) CloseCtx(ctx context.Context) (err error) {
 func (computeSystem *System) registerCallback(ctx context.Context) error {
 	callbackContext := &notificationWatcherContext{
 		channels: newSystemChannels(),
+		events:   computeSystem.events,
 		systemID: computeSystem.id,
 	}
 
@@ -871,3 +976,34 @@ func (computeSystem *System) Modify(ctx context.Context, config interface{}) err
 
 	return nil
 }
+
+// ModifyMappedDirectory adds, or removes if `add` is false, the host directory
+// `hostPath` mapped into the running process-isolated container at
+// `containerPath`.
+//
+// If the platform does not support changing the mapped directories of a running
+// container, its error is returned, for which [IsNotSupported] is true.
+func (computeSystem *System) ModifyMappedDirectory(ctx context.Context, add bool, hostPath, containerPath string, readOnly bool) (err error) {
+	ctx, span := oc.StartSpan(ctx, "hcs::System::ModifyMappedDirectory")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("cid", computeSystem.id),
+		trace.BoolAttribute("add", add),
+		trace.StringAttribute("hostPath", hostPath),
+		trace.StringAttribute("containerPath", containerPath))
+
+	requestType := guestrequest.RequestTypeAdd
+	if !add {
+		requestType = guestrequest.RequestTypeRemove
+	}
+	return computeSystem.Modify(ctx, &hcsschema.ModifySettingRequest{
+		ResourcePath: resourcepaths.SiloMappedDirectoryResourcePath,
+		RequestType:  requestType,
+		Settings: hcsschema.MappedDirectory{
+			HostPath:      hostPath,
+			ContainerPath: containerPath,
+			ReadOnly:      readOnly,
+		},
+	})
+}
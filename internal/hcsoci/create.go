@@ -16,17 +16,20 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcs"
 	"github.com/Microsoft/hcsshim/internal/guestpath"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/hvsocket"
 	"github.com/Microsoft/hcsshim/internal/layers"
 	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/memory"
 	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
 	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
@@ -51,6 +54,10 @@ type CreateOptions struct {
 	LCOWLayers       *layers.LCOWLayers
 	WCOWLayers       layers.WCOWLayers
 
+	// NetworkNamespacePrewarm is a network namespace set up ahead of time for the
+	// container, used instead of creating one if the spec does not specify a namespace.
+	NetworkNamespacePrewarm *NetworkNamespacePrewarm
+
 	// This is an advanced debugging parameter. It allows for diagnosability by leaving a containers
 	// resources allocated in case of a failure. Thus you would be able to use tools such as hcsdiag
 	// to look at the state of a utility VM to see what resources were allocated. Obviously the caller
@@ -94,9 +101,84 @@ func validateContainerConfig(ctx context.Context, coi *createOptionsInternal) er
 		}
 	}
 
+	// fail before any resources are allocated in the uVM for a label that would
+	// otherwise only fail when the guest starts the container
+	if coi.Spec.Linux != nil {
+		if err := oci.ValidateLCOWLSMLabels(ctx, coi.Spec.Annotations, coi.Spec.Process, coi.Spec.Linux.MountLabel); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// checkSCSICapacity fails if the SCSI disk mounts of the container cannot all
+// be attached to its uVM, as requested by the
+// [annotations.ContainerSCSICheckCapacity] annotation.
+func checkSCSICapacity(ctx context.Context, coi *createOptionsInternal) error {
+	if coi.HostingSystem == nil || coi.HostingSystem.SCSIManager == nil ||
+		!oci.ParseAnnotationsBool(ctx, coi.Spec.Annotations, annotations.ContainerSCSICheckCapacity, false) {
+		return nil
+	}
+	var paths []string
+	for _, m := range coi.Spec.Mounts {
+		switch m.Type {
+		case MountTypePhysicalDisk, MountTypeVirtualDisk, MountTypeExtensibleVirtualDisk:
+			paths = append(paths, m.Source)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return coi.HostingSystem.SCSIManager.CheckCapacity(scsi.PoolData, paths)
+}
+
+// containerUVMResources returns the memory and processors that the container
+// with `spec` requests from the uVM it is created in.
+func containerUVMResources(ctx context.Context, spec *specs.Spec) uvm.ContainerResources {
+	if spec.Linux == nil {
+		return uvm.ContainerResources{
+			MemoryInMB:     oci.ParseAnnotationsMemory(ctx, spec, annotations.ContainerMemorySizeInMB, 0),
+			ProcessorCount: oci.ParseAnnotationsCPUCount(ctx, spec, annotations.ContainerProcessorCount, 0),
+		}
+	}
+
+	return LinuxUVMResources(spec.Linux.Resources)
+}
+
+// LinuxUVMResources returns the memory and processors that a Linux container
+// with the resources `r` requests from its UVM: its memory limit, and its CPU
+// quota rounded up to whole processors.
+func LinuxUVMResources(r *specs.LinuxResources) uvm.ContainerResources {
+	var res uvm.ContainerResources
+	if r == nil {
+		return res
+	}
+	if m := r.Memory; m != nil && m.Limit != nil && *m.Limit > 0 {
+		res.MemoryInMB = (uint64(*m.Limit) + memory.MiB - 1) / memory.MiB
+	}
+	if c := r.CPU; c != nil && c.Quota != nil && *c.Quota > 0 && c.Period != nil && *c.Period > 0 {
+		res.ProcessorCount = int32((uint64(*c.Quota) + *c.Period - 1) / *c.Period)
+	}
+	return res
+}
+
+// WindowsUVMResources returns the memory and processors that a Windows
+// container with the resources `r` requests from its UVM.
+func WindowsUVMResources(r *specs.WindowsResources) uvm.ContainerResources {
+	var res uvm.ContainerResources
+	if r == nil {
+		return res
+	}
+	if m := r.Memory; m != nil && m.Limit != nil {
+		res.MemoryInMB = (*m.Limit + memory.MiB - 1) / memory.MiB
+	}
+	if c := r.CPU; c != nil && c.Count != nil {
+		res.ProcessorCount = int32(*c.Count)
+	}
+	return res
+}
+
 func initializeCreateOptions(ctx context.Context, createOptions *CreateOptions) (*createOptionsInternal, error) {
 	coi := &createOptionsInternal{
 		CreateOptions: createOptions,
@@ -157,6 +239,15 @@ func configureSandboxNetwork(ctx context.Context, coi *createOptionsInternal, r
 		// container but not a workload container in a sandbox that inherits
 		// the namespace.
 		if ct == oci.KubernetesContainerTypeNone || ct == oci.KubernetesContainerTypeSandbox || isVirtualPodFirstContainer {
+			if coi.HostingSystem.OS() == "linux" {
+				b, err := oci.ParseLCOWNetworkBandwidth(coi.Spec.Annotations)
+				if err != nil {
+					return err
+				}
+				if err := coi.HostingSystem.SetNetNSBandwidth(coi.actualNetworkNamespace, b); err != nil {
+					return err
+				}
+			}
 			if err := coi.HostingSystem.ConfigureNetworking(ctx, coi.actualNetworkNamespace); err != nil {
 				// No network setup type was specified for this UVM. Create and assign one here unless
 				// we received a different error.
@@ -226,6 +317,20 @@ func CreateContainer(ctx context.Context, createOptions *CreateOptions) (_ cow.C
 	}
 	isSandbox := ct == oci.KubernetesContainerTypeSandbox
 
+	// The resources of the sandbox container describe the pod as a whole,
+	// which the uVM was sized for, so only the other containers are admitted.
+	if coi.HostingSystem != nil && coi.HostingSystem.AdmitsContainers() && !isSandbox {
+		admission, err := coi.HostingSystem.AdmitContainer(ctx, coi.actualID, containerUVMResources(ctx, coi.Spec))
+		if err != nil {
+			return nil, r, err
+		}
+		r.Add(admission)
+	}
+
+	if err := checkSCSICapacity(ctx, coi); err != nil {
+		return nil, r, fmt.Errorf("container SCSI mounts cannot be attached: %w", err)
+	}
+
 	// Create a network namespace if necessary.
 	if coi.Spec.Windows != nil &&
 		coi.Spec.Windows.Network != nil &&
@@ -237,6 +342,7 @@ func CreateContainer(ctx context.Context, createOptions *CreateOptions) (_ cow.C
 	}
 
 	var hcsDocument, gcsDocument interface{}
+	var gcsOpts []gcs.CreateContainerOpt
 	log.G(ctx).Debug("hcsshim::CreateContainer allocating resources")
 	if coi.Spec.Linux != nil {
 		if schemaversion.IsV10(coi.actualSchemaVersion) {
@@ -253,6 +359,10 @@ func CreateContainer(ctx context.Context, createOptions *CreateOptions) (_ cow.C
 			log.G(ctx).WithError(err).Debug("failed createHCSContainerDocument")
 			return nil, r, err
 		}
+		// the guest validates the suffix, and checks it against the security policy
+		if suffix := coi.Spec.Annotations[annotations.LCOWHostnameSuffix]; suffix != "" {
+			gcsOpts = append(gcsOpts, gcs.WithHostnameSuffix(suffix))
+		}
 	} else {
 		err = allocateWindowsResources(ctx, coi, r, isSandbox)
 		if err != nil {
@@ -299,7 +409,7 @@ func CreateContainer(ctx context.Context, createOptions *CreateOptions) (_ cow.C
 
 	log.G(ctx).Debug("hcsshim::CreateContainer creating compute system")
 	if gcsDocument != nil {
-		c, err := coi.HostingSystem.CreateContainer(ctx, coi.actualID, gcsDocument)
+		c, err := coi.HostingSystem.CreateContainer(ctx, coi.actualID, gcsDocument, gcsOpts...)
 		if err != nil {
 			return nil, r, err
 		}
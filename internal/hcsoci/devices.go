@@ -178,10 +178,13 @@ func handleAssignedDevicesLCOW(
 			return resultDevs, closers, errors.Errorf("specified device %s has unsupported type %s", d.ID, d.IDType)
 		}
 
-		pciID, index := devices.GetDeviceInfoFromPath(d.ID)
-		vpci, err := vm.AssignDevice(ctx, pciID, index, "")
+		pciID, indexes, err := devices.GetDeviceFunctionsFromPath(d.ID)
+		if err != nil {
+			return resultDevs, closers, err
+		}
+		vpci, err := vm.AssignDeviceFunctions(ctx, pciID, indexes, "")
 		if err != nil {
-			return resultDevs, closers, errors.Wrapf(err, "failed to assign device %s, function %d to pod %s", pciID, index, vm.ID())
+			return resultDevs, closers, errors.Wrapf(err, "failed to assign device %s, functions %v to pod %s", pciID, indexes, vm.ID())
 		}
 		closers = append(closers, vpci)
 
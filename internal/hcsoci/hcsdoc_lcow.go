@@ -6,10 +6,15 @@ package hcsoci
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
 
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/memory"
 	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -38,6 +43,10 @@ func createLCOWSpec(ctx context.Context, coi *createOptionsInternal) (*specs.Spe
 	// Hooks are not supported (they should be run in the host)
 	spec.Hooks = nil
 
+	// The guest has no LSM to apply labels with, and any that are requested are
+	// ignored, as checked by validateContainerConfig.
+	oci.ClearLCOWLSMLabels(spec.Process, spec.Linux)
+
 	// Clear unsupported features
 	spec.Linux.CgroupsPath = "" // GCS controls its cgroups hierarchy on its own.
 	if spec.Linux.Resources != nil {
@@ -52,6 +61,36 @@ func createLCOWSpec(ctx context.Context, coi *createOptionsInternal) (*specs.Spe
 		spec.Linux.Seccomp = nil
 	}
 
+	// the guest applies the weight from the annotation, so only check it here
+	if _, err := oci.ParseContainerIOWeight(spec.Annotations); err != nil {
+		return nil, err
+	}
+
+	tmpfs, err := oci.ParseContainerTmpfsMount(spec.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if tmpfs != nil {
+		for _, m := range spec.Mounts {
+			if path.Clean(m.Destination) == tmpfs.Destination {
+				return nil, fmt.Errorf("%w: a mount already exists at %q", oci.ErrInvalidTmpfs, tmpfs.Destination)
+			}
+		}
+		spec.Mounts = append(spec.Mounts, *tmpfs)
+	}
+
+	if spec.Linux.Seccomp != nil && coi.HostingSystem != nil && !coi.HostingSystem.SeccompProfilesSupported() {
+		if oci.ParseAnnotationsBool(ctx, spec.Annotations, annotations.LCOWRequireSeccompProfileSupport, false) {
+			return nil, errors.New("container has a seccomp profile, but the guest does not report support for seccomp profiles")
+		}
+		log.G(ctx).Warn("guest does not report support for seccomp profiles, relying on it to apply the container's profile")
+	}
+
+	if oci.ParseAnnotationsBool(ctx, spec.Annotations, annotations.LCOWTPMDevice, false) &&
+		(coi.HostingSystem == nil || !coi.HostingSystem.TPMEnabled()) {
+		return nil, errors.New("container requests the TPM device, but its uVM has no virtual TPM")
+	}
+
 	return spec, nil
 }
 
@@ -86,6 +125,14 @@ type linuxHostedSystem struct {
 	// VHD is mounted inside the UVM. But in case of scratch sharing this is a
 	// directory under the UVM scratch directory.
 	ScratchDirPath string
+
+	// ScratchQuotaBytes limits the disk space the container may use under
+	// ScratchDirPath, which keeps containers sharing a scratch from starving
+	// each other.
+	ScratchQuotaBytes uint64 `json:",omitempty"`
+
+	// HostAliases are additional entries for the /etc/hosts file of a pod.
+	HostAliases []guestresource.HostAlias `json:",omitempty"`
 }
 
 func createLinuxContainerDocument(ctx context.Context, coi *createOptionsInternal, guestRoot, scratchPath string) (*linuxHostedSystem, error) {
@@ -94,11 +141,27 @@ func createLinuxContainerDocument(ctx context.Context, coi *createOptionsInterna
 		return nil, err
 	}
 
+	// host aliases apply to the whole pod, so are only read from the sandbox
+	var hostAliases []guestresource.HostAlias
+	if coi.Spec.Annotations[annotations.KubernetesContainerType] == string(oci.KubernetesContainerTypeSandbox) {
+		if hostAliases, err = oci.ParseHostAliases(coi.Spec.Annotations); err != nil {
+			return nil, err
+		}
+	}
+
+	// the quota only applies to a scratch of the container's own
+	var scratchQuota uint64
+	if scratchPath != "" {
+		scratchQuota = oci.ParseAnnotationsUint64(ctx, coi.Spec.Annotations, annotations.ContainerRootFSSizeInGB, 0) * memory.GiB
+	}
+
 	log.G(ctx).WithField("guestRoot", guestRoot).Debug("hcsshim::createLinuxContainerDoc")
 	return &linuxHostedSystem{
-		SchemaVersion:    schemaversion.SchemaV21(),
-		OciBundlePath:    guestRoot,
-		OciSpecification: spec,
-		ScratchDirPath:   scratchPath,
+		SchemaVersion:     schemaversion.SchemaV21(),
+		OciBundlePath:     guestRoot,
+		OciSpecification:  spec,
+		ScratchDirPath:    scratchPath,
+		ScratchQuotaBytes: scratchQuota,
+		HostAliases:       hostAliases,
 	}, nil
 }
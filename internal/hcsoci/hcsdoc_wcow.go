@@ -487,6 +487,14 @@ func createWindowsContainerDocument(ctx context.Context, coi *createOptionsInter
 		}...)
 	}
 
+	// User-specified registry values. For hypervisor-isolated containers, the
+	// container document is passed to the UVM, and the values are set by the guest.
+	userRegistryAdd, err := oci.ParseContainerRegistryValues(ctx, coi.Spec.Annotations)
+	if err != nil {
+		return nil, nil, err
+	}
+	registryAdd = append(registryAdd, userRegistryAdd...)
+
 	v2Container.RegistryChanges = &hcsschema.RegistryChanges{
 		AddValues: registryAdd,
 	}
@@ -4,6 +4,9 @@ package hcsoci
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/Microsoft/hcsshim/hcn"
 	"github.com/Microsoft/hcsshim/internal/log"
@@ -21,31 +24,125 @@ func createNetworkNamespace(ctx context.Context, coi *createOptionsInternal, r *
 		l.Debug(op + " - End")
 	}()
 
+	var endpoints *uvm.NetworkEndpoints
+	if coi.NetworkNamespacePrewarm != nil {
+		var err error
+		if endpoints, err = coi.NetworkNamespacePrewarm.take(ctx); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if endpoints, err = setupNetworkNamespace(ctx, coi.ID, coi.Spec.Windows.Network.EndpointList); err != nil {
+			return err
+		}
+	}
+
+	r.SetNetNS(endpoints.Namespace)
+	r.SetCreatedNetNS(true)
+	r.Add(endpoints)
+	return nil
+}
+
+// setupNetworkNamespace creates a network namespace for container `id` and
+// adds the endpoints in `endpointIDs` to it. On failure, the namespace is
+// removed.
+func setupNetworkNamespace(ctx context.Context, id string, endpointIDs []string) (_ *uvm.NetworkEndpoints, err error) {
+	start := time.Now()
 	ns, err := hcn.NewNamespace("").Create()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			if dErr := ns.Delete(); dErr != nil {
+				log.G(ctx).WithError(dErr).WithField("netID", ns.Id).Warn("failed to delete network namespace on cleanup")
+			}
+		}
+	}()
 
 	log.G(ctx).WithFields(logrus.Fields{
 		"netID":               ns.Id,
-		logfields.ContainerID: coi.ID,
+		logfields.ContainerID: id,
+		"duration":            time.Since(start),
 	}).Info("created network namespace for container")
 
-	r.SetNetNS(ns.Id)
-	r.SetCreatedNetNS(true)
+	start = time.Now()
+	if err := hcn.AddNamespaceEndpoints(ns.Id, endpointIDs); err != nil {
+		return nil, fmt.Errorf("failed to add endpoints to network namespace %s: %w", ns.Id, err)
+	}
+	log.G(ctx).WithFields(logrus.Fields{
+		"netID":     ns.Id,
+		"endpoints": endpointIDs,
+		"duration":  time.Since(start),
+	}).Info("added network endpoints to namespace")
 
-	endpoints := make([]string, 0)
-	for _, endpointID := range coi.Spec.Windows.Network.EndpointList {
-		err = hcn.AddNamespaceEndpoint(ns.Id, endpointID)
-		if err != nil {
-			return err
-		}
-		log.G(ctx).WithFields(logrus.Fields{
-			"netID":      ns.Id,
-			"endpointID": endpointID,
-		}).Info("added network endpoint to namespace")
-		endpoints = append(endpoints, endpointID)
+	return &uvm.NetworkEndpoints{EndpointIDs: endpointIDs, Namespace: ns.Id}, nil
+}
+
+// NetworkNamespacePrewarm is a network namespace that is set up in the
+// background, so that it can be created while the utility VM for a pod boots.
+//
+// The namespace is used by passing it to [CreateContainer] in
+// [CreateOptions.NetworkNamespacePrewarm], after which it is released along
+// with the container's resources. Otherwise, it must be released with
+// [NetworkNamespacePrewarm.Release].
+type NetworkNamespacePrewarm struct {
+	done      chan struct{}
+	endpoints *uvm.NetworkEndpoints
+	err       error
+
+	mu    sync.Mutex
+	taken bool
+}
+
+// PrewarmNetworkNamespace starts creating a network namespace for container
+// `id` with the endpoints in `endpointIDs`. The endpoints must match the
+// endpoint list in the spec of the container the namespace is used for.
+func PrewarmNetworkNamespace(ctx context.Context, id string, endpointIDs []string) *NetworkNamespacePrewarm {
+	p := &NetworkNamespacePrewarm{done: make(chan struct{})}
+	// don't tie the setup to the lifetime of the request context, since
+	// cancellation is handled by releasing the namespace.
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer close(p.done)
+		p.endpoints, p.err = setupNetworkNamespace(ctx, id, endpointIDs)
+	}()
+	return p
+}
+
+// take waits for the namespace to be set up and transfers ownership of it to
+// the caller.
+func (p *NetworkNamespacePrewarm) take(ctx context.Context) (*uvm.NetworkEndpoints, error) {
+	start := time.Now()
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	r.Add(&uvm.NetworkEndpoints{EndpointIDs: endpoints, Namespace: ns.Id})
-	return nil
+	log.G(ctx).WithField("duration", time.Since(start)).Debug("waited for prewarmed network namespace")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.taken {
+		return nil, fmt.Errorf("prewarmed network namespace is already in use")
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("failed to prewarm network namespace: %w", p.err)
+	}
+	p.taken = true
+	return p.endpoints, nil
+}
+
+// Release waits for the namespace to be set up and removes it, unless it is
+// in use by a container.
+func (p *NetworkNamespacePrewarm) Release(ctx context.Context) error {
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.taken || p.err != nil {
+		return nil
+	}
+	p.taken = true
+	return p.endpoints.Release(ctx)
 }
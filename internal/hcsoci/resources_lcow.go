@@ -21,8 +21,24 @@ import (
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
+// receivingPropagation returns the last mount propagation option in `options` if it
+// lets the mount receive mounts from its source (`rslave` or `rshared`, for example),
+// or "" otherwise.
+func receivingPropagation(options []string) (p string) {
+	for _, o := range options {
+		switch o {
+		case "slave", "rslave", "shared", "rshared":
+			p = o
+		case "private", "rprivate":
+			p = ""
+		}
+	}
+	return p
+}
+
 func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, r *resources.Resources, isSandbox bool) error {
 	if coi.Spec.Root == nil {
 		coi.Spec.Root = &specs.Root{}
@@ -36,8 +52,10 @@ func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, r *
 		}
 		coi.Spec.Root.Path = rootPath
 		// If this is the pause container in a hypervisor-isolated pod, we can skip cleanup of
-		// layers, as that happens automatically when the UVM is terminated.
-		if !isSandbox || coi.HostingSystem == nil {
+		// layers, as that happens automatically when the UVM is terminated. The pods of a
+		// UVM sharing group share the UVM, which outlives them, so their layers are cleaned
+		// up with the pod.
+		if !isSandbox || coi.HostingSystem == nil || coi.Spec.Annotations[annotations.UVMSharingGroup] != "" {
 			r.SetLayers(closer)
 		}
 		r.SetLcowScratchPath(scratchPath)
@@ -94,7 +112,7 @@ func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, r *
 					readOnly,
 					coi.HostingSystem.ID(),
 					"",
-					&scsi.MountConfig{Options: mount.Options, BlockDev: isBlockDev},
+					&scsi.MountConfig{Options: mount.Options, BlockDev: isBlockDev, PathTag: coi.actualID, Pool: scsi.PoolData},
 				)
 				if err != nil {
 					return errors.Wrapf(err, "adding SCSI physical disk mount %+v", mount)
@@ -117,7 +135,7 @@ func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, r *
 					readOnly,
 					coi.HostingSystem.ID(),
 					"",
-					&scsi.MountConfig{Options: mount.Options, BlockDev: isBlockDev},
+					&scsi.MountConfig{Options: mount.Options, BlockDev: isBlockDev, PathTag: coi.actualID, Pool: scsi.PoolData},
 				)
 				if err != nil {
 					return errors.Wrapf(err, "adding SCSI virtual disk mount %+v", mount)
@@ -137,7 +155,7 @@ func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, r *
 					hostPath,
 					readOnly,
 					"",
-					&scsi.MountConfig{Options: mount.Options, BlockDev: isBlockDev},
+					&scsi.MountConfig{Options: mount.Options, BlockDev: isBlockDev, PathTag: coi.actualID, Pool: scsi.PoolData},
 				)
 				if err != nil {
 					return fmt.Errorf("adding Extensible virtual disk mount %+v: %w", mount, err)
@@ -183,6 +201,11 @@ func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, r *
 				restrictAccess := false
 				var allowedNames []string
 				if !st.IsDir() {
+					// Nothing can be mounted under a single file, so propagation would
+					// silently have no effect.
+					if p := receivingPropagation(mount.Options); p != "" {
+						return fmt.Errorf("mount propagation %q is not supported for file mount %+v", p, mount)
+					}
 					// Map the containing directory in, but restrict the share to a single
 					// file.
 					var fileName string
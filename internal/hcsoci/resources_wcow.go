@@ -9,11 +9,13 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/Microsoft/hcsshim/internal/cmd"
 	"github.com/Microsoft/hcsshim/internal/credentials"
@@ -21,6 +23,7 @@ import (
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/layers"
 	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
 	"github.com/Microsoft/hcsshim/internal/uvm"
@@ -49,6 +52,10 @@ func allocateWindowsResources(ctx context.Context, coi *createOptionsInternal, r
 		}
 	}
 
+	if err := setupImageVolumes(ctx, coi, r); err != nil {
+		return err
+	}
+
 	if err := setupMounts(ctx, coi, r); err != nil {
 		return err
 	}
@@ -103,6 +110,50 @@ func allocateWindowsResources(ctx context.Context, coi *createOptionsInternal, r
 	return nil
 }
 
+// setupImageVolumes handles the volumes declared by the image of the container that are
+// not covered by a mount, as parsed by [oci.ParseImageVolumes].
+//
+// The image config is not read here: only the volumes the caller lists in the
+// annotations.WCOWImageVolumes annotation are known.
+//
+// For the "emptydir" mode, a mount of a new directory is added to the spec for each volume,
+// so it must be called before setupMounts.
+func setupImageVolumes(ctx context.Context, coi *createOptionsInternal, r *resources.Resources) error {
+	mode, volumes, err := oci.ParseImageVolumes(coi.Spec)
+	if err != nil {
+		return err
+	}
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	switch mode {
+	case oci.ImageVolumeModeError:
+		return fmt.Errorf("%w: image declares volumes that are not mounted: %s",
+			oci.ErrInvalidImageVolume, strings.Join(volumes, ", "))
+	case oci.ImageVolumeModeEmptyDir:
+		for _, v := range volumes {
+			dir, err := os.MkdirTemp("", "hcsshim-image-volume-")
+			if err != nil {
+				return errors.Wrapf(err, "failed to create directory for image volume %s", v)
+			}
+			// added before the mount's resources, so the directory is removed after them
+			r.Add(resources.ResourceCloserFunc(func(context.Context) error {
+				return os.RemoveAll(dir)
+			}))
+			log.G(ctx).WithFields(logrus.Fields{
+				"volume": v,
+				"source": dir,
+			}).Debug("backing image volume with empty directory")
+			coi.Spec.Mounts = append(coi.Spec.Mounts, specs.Mount{
+				Source:      dir,
+				Destination: v,
+			})
+		}
+	}
+	return nil
+}
+
 // setupMounts adds the custom mounts requested in the container configuration of this
 // request.
 func setupMounts(ctx context.Context, coi *createOptionsInternal, r *resources.Resources) error {
@@ -153,7 +204,7 @@ func setupMounts(ctx context.Context, coi *createOptionsInternal, r *resources.R
 					readOnly,
 					coi.HostingSystem.ID(),
 					"",
-					&scsi.MountConfig{},
+					&scsi.MountConfig{PathTag: coi.actualID, Pool: scsi.PoolData},
 				)
 			case MountTypeVirtualDisk:
 				l.Debug("hcsshim::allocateWindowsResources Hot-adding SCSI virtual disk for OCI mount")
@@ -163,7 +214,7 @@ func setupMounts(ctx context.Context, coi *createOptionsInternal, r *resources.R
 					readOnly,
 					coi.HostingSystem.ID(),
 					"",
-					&scsi.MountConfig{},
+					&scsi.MountConfig{PathTag: coi.actualID, Pool: scsi.PoolData},
 				)
 			case MountTypeExtensibleVirtualDisk:
 				l.Debug("hcsshim::allocateWindowsResource Hot-adding ExtensibleVirtualDisk")
@@ -172,7 +223,7 @@ func setupMounts(ctx context.Context, coi *createOptionsInternal, r *resources.R
 					mount.Source,
 					readOnly,
 					"",
-					&scsi.MountConfig{},
+					&scsi.MountConfig{PathTag: coi.actualID, Pool: scsi.PoolData},
 				)
 			}
 			if err != nil {
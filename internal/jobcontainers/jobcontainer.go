@@ -23,6 +23,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/jobobject"
 	"github.com/Microsoft/hcsshim/internal/layers"
 	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/processorinfo"
 	"github.com/Microsoft/hcsshim/internal/queue"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/winapi"
@@ -425,6 +426,23 @@ func (c *JobContainer) Modify(ctx context.Context, config interface{}) (err erro
 	return errors.New("modify not supported for job containers")
 }
 
+// Update changes the resource limits of the container's job object to those in
+// `resources`. Limits that are not present in `resources` are left as they are.
+func (c *JobContainer) Update(ctx context.Context, resources *specs.WindowsResources) error {
+	limits := resourcesToLimits(ctx, c.id, resources, processorinfo.ProcessorCount())
+	log.G(ctx).WithField("limits", fmt.Sprintf("%+v", *limits)).Debug("updating job container resource limits")
+
+	if err := c.job.SetResourceLimits(limits); err != nil {
+		return fmt.Errorf("failed to update resource limits: %w", err)
+	}
+	return nil
+}
+
+// ResourceLimits returns the resource limits in effect on the container's job object.
+func (c *JobContainer) ResourceLimits() (*jobobject.JobLimits, error) {
+	return c.job.GetResourceLimits()
+}
+
 // Start starts the container. There's nothing to "start" for job containers, so this just
 // sets the start timestamp.
 func (c *JobContainer) Start(ctx context.Context) error {
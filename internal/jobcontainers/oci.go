@@ -67,6 +67,36 @@ func specToLimits(ctx context.Context, cid string, s *specs.Spec) (*jobobject.Jo
 	}, nil
 }
 
+// resourcesToLimits converts an update to the resources of a job container into the job
+// object limits to apply. Limits that are not present in `r` are left as zero so that they
+// are not changed on the job.
+func resourcesToLimits(ctx context.Context, cid string, r *specs.WindowsResources, hostCPUCount int32) *jobobject.JobLimits {
+	limits := &jobobject.JobLimits{}
+	if r.CPU != nil {
+		switch {
+		case r.CPU.Count != nil:
+			cpuCount := hcsoci.NormalizeProcessorCount(ctx, cid, int32(*r.CPU.Count), hostCPUCount)
+			limits.CPULimit = calculateJobCPURate(uint32(hostCPUCount), uint32(cpuCount))
+		case r.CPU.Maximum != nil:
+			limits.CPULimit = uint32(*r.CPU.Maximum)
+		case r.CPU.Shares != nil:
+			limits.CPUWeight = calculateJobCPUWeight(uint32(*r.CPU.Shares))
+		}
+	}
+	if r.Memory != nil && r.Memory.Limit != nil {
+		limits.MemoryLimitInBytes = *r.Memory.Limit
+	}
+	if r.Storage != nil {
+		if r.Storage.Iops != nil {
+			limits.MaxIOPS = int64(*r.Storage.Iops)
+		}
+		if r.Storage.Bps != nil {
+			limits.MaxBandwidth = int64(*r.Storage.Bps)
+		}
+	}
+	return limits
+}
+
 // calculateJobCPUWeight converts processor cpu weight to job object cpu weight.
 //
 // `processorWeight` is the processor cpu weight to convert.
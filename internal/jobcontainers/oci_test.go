@@ -0,0 +1,66 @@
+//go:build windows
+
+package jobcontainers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/jobobject"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestResourcesToLimits(t *testing.T) {
+	u16 := func(v uint16) *uint16 { return &v }
+	u64 := func(v uint64) *uint64 { return &v }
+
+	for _, tc := range []struct {
+		name      string
+		resources *specs.WindowsResources
+		expected  jobobject.JobLimits
+	}{
+		{
+			name:      "Empty",
+			resources: &specs.WindowsResources{},
+		},
+		{
+			name:      "CPUCount",
+			resources: &specs.WindowsResources{CPU: &specs.WindowsCPUResources{Count: u64(2)}},
+			expected:  jobobject.JobLimits{CPULimit: 2500},
+		},
+		{
+			name:      "CPUCountAboveHost",
+			resources: &specs.WindowsResources{CPU: &specs.WindowsCPUResources{Count: u64(16)}},
+			expected:  jobobject.JobLimits{CPULimit: 10000},
+		},
+		{
+			name:      "CPUMaximum",
+			resources: &specs.WindowsResources{CPU: &specs.WindowsCPUResources{Maximum: u16(5000)}},
+			expected:  jobobject.JobLimits{CPULimit: 5000},
+		},
+		{
+			name:      "CPUShares",
+			resources: &specs.WindowsResources{CPU: &specs.WindowsCPUResources{Shares: u16(5000)}},
+			expected:  jobobject.JobLimits{CPUWeight: 5},
+		},
+		{
+			name: "MemoryAndStorage",
+			resources: &specs.WindowsResources{
+				Memory:  &specs.WindowsMemoryResources{Limit: u64(512 * 1024 * 1024)},
+				Storage: &specs.WindowsStorageResources{Iops: u64(100), Bps: u64(1024)},
+			},
+			expected: jobobject.JobLimits{
+				MemoryLimitInBytes: 512 * 1024 * 1024,
+				MaxIOPS:            100,
+				MaxBandwidth:       1024,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			limits := resourcesToLimits(context.Background(), t.Name(), tc.resources, 8)
+			if *limits != tc.expected {
+				t.Fatalf("expected limits %+v, got %+v", tc.expected, *limits)
+			}
+		})
+	}
+}
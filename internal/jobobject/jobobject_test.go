@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/hcsshim/internal/winapi"
 )
 
 func TestJobNilOptions(t *testing.T) {
@@ -252,6 +254,97 @@ func TestSetMultipleExtendedLimits(t *testing.T) {
 	}
 }
 
+func TestSetCPULimitTransitions(t *testing.T) {
+	job, err := Create(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer job.Close()
+
+	// Flip between weight based and hard cap rate control a few times, as an update
+	// to the job's resources would, and check what the job object reports each time.
+	limits := []struct {
+		typ   CPURateControlType
+		value uint32
+		flag  uint32
+	}{
+		{WeightBased, 5, winapi.JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED},
+		{RateBased, 2000, winapi.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP},
+		{RateBased, 5000, winapi.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP},
+		{WeightBased, 9, winapi.JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED},
+		{WeightBased, 1, winapi.JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED},
+		{RateBased, 10000, winapi.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP},
+	}
+	for i := 0; i < 3; i++ {
+		for _, l := range limits {
+			if err := job.SetCPULimit(l.typ, l.value); err != nil {
+				t.Fatalf("failed to set cpu limit type %d to %d: %v", l.typ, l.value, err)
+			}
+
+			info, err := job.getCPURateControlInformation()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedFlags := winapi.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE | l.flag
+			if info.ControlFlags != expectedFlags {
+				t.Fatalf("expected cpu rate control flags %#x, got %#x", expectedFlags, info.ControlFlags)
+			}
+			if info.Value != l.value {
+				t.Fatalf("expected cpu rate control value %d, got %d", l.value, info.Value)
+			}
+
+			v, err := job.GetCPULimit(l.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != l.value {
+				t.Fatalf("expected cpu limit %d, got %d", l.value, v)
+			}
+		}
+	}
+}
+
+func TestGetResourceLimits(t *testing.T) {
+	job, err := Create(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer job.Close()
+
+	limits, err := job.GetResourceLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *limits != (JobLimits{}) {
+		t.Fatalf("expected no limits on a new job, got %+v", limits)
+	}
+
+	expected := JobLimits{
+		CPUWeight:          3,
+		MemoryLimitInBytes: 512 * 1024 * 1024,
+	}
+	if err := job.SetResourceLimits(&expected); err != nil {
+		t.Fatal(err)
+	}
+	// Updating the limits must replace the cpu weight with the rate and the memory
+	// limit with the new value.
+	expected = JobLimits{
+		CPULimit:           4000,
+		MemoryLimitInBytes: 256 * 1024 * 1024,
+	}
+	if err := job.SetResourceLimits(&expected); err != nil {
+		t.Fatal(err)
+	}
+
+	limits, err = job.GetResourceLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *limits != expected {
+		t.Fatalf("expected limits %+v, got %+v", expected, limits)
+	}
+}
+
 func TestNoMoreProcessesMessageKill(t *testing.T) {
 	// Test that we receive the no more processes in job message after killing all of
 	// the processes in the job.
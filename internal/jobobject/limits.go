@@ -46,6 +46,40 @@ func (job *JobObject) SetResourceLimits(limits *JobLimits) error {
 	return nil
 }
 
+// GetResourceLimits returns the limits currently in effect on the job object. Limits that
+// are not set on the job are left as zero.
+func (job *JobObject) GetResourceLimits() (*JobLimits, error) {
+	limits := &JobLimits{}
+
+	cpuInfo, err := job.getCPURateControlInformation()
+	if err != nil {
+		return nil, err
+	}
+	if isFlagSet(winapi.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE, cpuInfo.ControlFlags) {
+		switch {
+		case isFlagSet(winapi.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP, cpuInfo.ControlFlags):
+			limits.CPULimit = cpuInfo.Value
+		case isFlagSet(winapi.JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED, cpuInfo.ControlFlags):
+			limits.CPUWeight = cpuInfo.Value
+		}
+	}
+
+	info, err := job.getExtendedInformation()
+	if err != nil {
+		return nil, err
+	}
+	if isFlagSet(windows.JOB_OBJECT_LIMIT_JOB_MEMORY, info.BasicLimitInformation.LimitFlags) {
+		limits.MemoryLimitInBytes = uint64(info.JobMemoryLimit)
+	}
+
+	// IO limits can only be queried from a job that has IO rate control enabled.
+	if ioInfo, err := job.getIOLimit(); err == nil {
+		limits.MaxBandwidth = ioInfo.MaxBandwidth
+		limits.MaxIOPS = ioInfo.MaxIops
+	}
+	return limits, nil
+}
+
 // SetTerminateOnLastHandleClose sets the job object flag that specifies that the job should terminate
 // all processes in the job on the last open handle being closed.
 func (job *JobObject) SetTerminateOnLastHandleClose() error {
@@ -84,27 +118,38 @@ func (job *JobObject) GetMemoryLimit() (uint64, error) {
 
 // SetCPULimit sets the CPU limit depending on the specified `CPURateControlType` to
 // `rateControlValue` for the job object.
+//
+// The job object rejects switching directly between weight based and hard cap rate
+// control, so if the job currently uses the other type, rate control is turned off before
+// the new limit is set.
 func (job *JobObject) SetCPULimit(rateControlType CPURateControlType, rateControlValue uint32) error {
-	cpuInfo, err := job.getCPURateControlInformation()
-	if err != nil {
-		return err
-	}
+	var typeFlag uint32
 	switch rateControlType {
 	case WeightBased:
 		if rateControlValue < cpuWeightMin || rateControlValue > cpuWeightMax {
 			return fmt.Errorf("processor weight value of `%d` is invalid", rateControlValue)
 		}
-		cpuInfo.ControlFlags |= winapi.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE | winapi.JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED
-		cpuInfo.Value = rateControlValue
+		typeFlag = winapi.JOB_OBJECT_CPU_RATE_CONTROL_WEIGHT_BASED
 	case RateBased:
 		if rateControlValue < cpuLimitMin || rateControlValue > cpuLimitMax {
 			return fmt.Errorf("processor rate of `%d` is invalid", rateControlValue)
 		}
-		cpuInfo.ControlFlags |= winapi.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE | winapi.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP
-		cpuInfo.Value = rateControlValue
+		typeFlag = winapi.JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP
 	default:
 		return errors.New("invalid job object cpu rate control type")
 	}
+
+	cpuInfo, err := job.getCPURateControlInformation()
+	if err != nil {
+		return err
+	}
+	if isFlagSet(winapi.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE, cpuInfo.ControlFlags) && !isFlagSet(typeFlag, cpuInfo.ControlFlags) {
+		if err := job.setCPURateControlInfo(&winapi.JOBOBJECT_CPU_RATE_CONTROL_INFORMATION{}); err != nil {
+			return fmt.Errorf("failed to clear cpu rate control: %w", err)
+		}
+	}
+	cpuInfo.ControlFlags = winapi.JOB_OBJECT_CPU_RATE_CONTROL_ENABLE | typeFlag
+	cpuInfo.Value = rateControlValue
 	return job.setCPURateControlInfo(cpuInfo)
 }
 
@@ -317,7 +362,7 @@ func (job *JobObject) setCPURateControlInfo(cpuInfo *winapi.JOBOBJECT_CPU_RATE_C
 		job.handle,
 		windows.JobObjectCpuRateControlInformation,
 		uintptr(unsafe.Pointer(cpuInfo)),
-		uint32(unsafe.Sizeof(cpuInfo)),
+		uint32(unsafe.Sizeof(*cpuInfo)),
 	); err != nil {
 		return fmt.Errorf("failed to set cpu limit info %v on job object: %w", cpuInfo, err)
 	}
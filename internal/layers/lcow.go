@@ -7,16 +7,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/Microsoft/go-winio/pkg/fs"
 	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/errdefs"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/Microsoft/hcsshim/internal/guestpath"
 	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oplimit"
 	"github.com/Microsoft/hcsshim/internal/ospath"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/uvm"
@@ -39,6 +42,75 @@ type LCOWLayers struct {
 	ScratchVHDPath string
 }
 
+// LCOWLayerOption configures how the layers of an LCOW container are parsed.
+type LCOWLayerOption func(*lcowLayerOptions)
+
+type lcowLayerOptions struct {
+	extraLayerRoot string
+	extraLayers    []string
+}
+
+// WithExtraLCOWLayers adds the read-only layer VHDs at `paths` directly below the scratch
+// layer, above the image's layers, with the first being the top-most. The VHDs must be
+// within the directory `root`, and none can be added if it is empty.
+func WithExtraLCOWLayers(root string, paths []string) LCOWLayerOption {
+	return func(o *lcowLayerOptions) {
+		o.extraLayerRoot = root
+		o.extraLayers = append(o.extraLayers, paths...)
+	}
+}
+
+// checkExtraLCOWLayers verifies that the layer VHDs in `paths` are files within `root`,
+// after resolving any links, and are read-only, and returns them as layers.
+func checkExtraLCOWLayers(root string, paths []string) ([]*LCOWLayer, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	if root == "" {
+		return nil, fmt.Errorf("extra layers are not allowed without an extra layer root: %w", errdefs.ErrFailedPrecondition)
+	}
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("extra layer root %q must be absolute: %w", root, errdefs.ErrFailedPrecondition)
+	}
+	// resolve the root and the layers, so that neither a link in a layer's path nor one
+	// to the root can escape it
+	resolvedRoot, err := fs.ResolvePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve extra layer root: %w", err)
+	}
+
+	out := make([]*LCOWLayer, 0, len(paths))
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			return nil, fmt.Errorf("extra layer path %q must be absolute: %w", p, errdefs.ErrInvalidArgument)
+		}
+		resolved, err := fs.ResolvePath(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra layer: %w", err)
+		}
+		if rel, err := filepath.Rel(resolvedRoot, resolved); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("extra layer %s is not within %s: %w", p, root, errdefs.ErrInvalidArgument)
+		}
+		fi, err := os.Stat(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra layer: %w", err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("extra layer %s is not a file: %w", p, errdefs.ErrInvalidArgument)
+		}
+		// the read-only file attribute clears the write permission bits
+		if fi.Mode().Perm()&0222 != 0 {
+			return nil, fmt.Errorf("extra layer %s is not read-only: %w", p, errdefs.ErrInvalidArgument)
+		}
+		out = append(out, &LCOWLayer{VHDPath: resolved})
+	}
+	return out, nil
+}
+
 type lcowLayersCloser struct {
 	uvm                     *uvm.UtilityVM
 	guestCombinedLayersPath string
@@ -78,6 +150,7 @@ func (lc *lcowLayersCloser) Release(ctx context.Context) (retErr error) {
 // Returns the path at which the `rootfs` of the container can be accessed. Also, returns the path inside the
 // UVM at which container scratch directory is located. Usually, this path is the path at which the container
 // scratch VHD is mounted. However, in case of scratch sharing this is a directory under the UVM scratch.
+// If the filesystem on the scratch VHD is corrupt, the error returned wraps [scsi.ErrFilesystemCorrupt].
 func MountLCOWLayers(
 	ctx context.Context,
 	containerID string,
@@ -93,6 +166,12 @@ func MountLCOWLayers(
 		return "", "", nil, errors.New("MountLCOWLayers should only be called for LCOW")
 	}
 
+	release, err := oplimit.Acquire(ctx, "MountLCOWLayers")
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer release()
+
 	// V2 UVM
 	log.G(ctx).WithField("os", vm.OS()).Debug("hcsshim::MountLCOWLayers V2 UVM")
 
@@ -139,10 +218,17 @@ func MountLCOWLayers(
 		// formatted.
 		EnsureFilesystem: true,
 		Filesystem:       "ext4",
+		// The guest path is given, so the tag only attributes the scratch to
+		// the container in diagnostics.
+		PathTag: containerID,
 	}
 	if vm.ScratchEncryptionEnabled() {
 		// Encrypted scratch devices are formatted with xfs
 		mConfig.Filesystem = "xfs"
+	} else {
+		// Encrypted scratch devices are formatted anew on every mount, so
+		// only unencrypted ones can be left corrupt.
+		mConfig.RepairFilesystem = vm.ScratchRepairEnabled()
 	}
 	scsiMount, err := vm.SCSIManager.AddVirtualDisk(
 		ctx,
@@ -212,6 +298,7 @@ func addLCOWLayer(ctx context.Context, vm *uvm.UtilityVM, layer *LCOWLayer) (uvm
 		&scsi.MountConfig{
 			Partition: layer.Partition,
 			Options:   []string{"ro"},
+			PathTag:   "layer",
 		},
 	)
 	if err != nil {
@@ -228,7 +315,25 @@ func addLCOWLayer(ctx context.Context, vm *uvm.UtilityVM, layer *LCOWLayer) (uvm
 // ParseLCOWLayers returns a layers.LCOWLayers describing the rootfs that should be set up
 // for an LCOW container. It takes as input the set of rootfs mounts and the layer folders
 // from the OCI spec.
-func ParseLCOWLayers(rootfs []*types.Mount, layerFolders []string) (*LCOWLayers, error) {
+func ParseLCOWLayers(rootfs []*types.Mount, layerFolders []string, opts ...LCOWLayerOption) (*LCOWLayers, error) {
+	o := &lcowLayerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	extraLayers, err := checkExtraLCOWLayers(o.extraLayerRoot, o.extraLayers)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := parseLCOWLayers(rootfs, layerFolders)
+	if err != nil {
+		return nil, err
+	}
+	l.Layers = append(extraLayers, l.Layers...)
+	return l, nil
+}
+
+func parseLCOWLayers(rootfs []*types.Mount, layerFolders []string) (*LCOWLayers, error) {
 	if err := validateRootfsAndLayers(rootfs, layerFolders); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,84 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/fs"
+	"github.com/containerd/errdefs"
+)
+
+func TestParseLCOWLayers_ExtraLayers(t *testing.T) {
+	dir := t.TempDir()
+	newVHD := func(dir, name string, readOnly bool) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if readOnly {
+			if err := os.Chmod(p, 0444); err != nil {
+				t.Fatal(err)
+			}
+			// clear the read-only attribute so the temp dir can be removed
+			t.Cleanup(func() { _ = os.Chmod(p, 0644) })
+		}
+		return p
+	}
+	agent := newVHD(dir, "agent.vhd", true)
+	tools := newVHD(dir, "tools.vhd", true)
+	writable := newVHD(dir, "writable.vhd", false)
+	outside := newVHD(t.TempDir(), "outside.vhd", true)
+	layerFolders := []string{`C:\layers\top`, `C:\layers\base`, `C:\scratch`}
+
+	l, err := ParseLCOWLayers(nil, layerFolders, WithExtraLCOWLayers(dir, []string{agent, tools}))
+	if err != nil {
+		t.Fatalf("failed to parse layers: %v", err)
+	}
+	var paths []string
+	for _, layer := range l.Layers {
+		paths = append(paths, layer.VHDPath)
+	}
+	// the extra layers are attached by their resolved paths
+	var expected []string
+	for _, p := range []string{agent, tools} {
+		resolved, err := fs.ResolvePath(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, resolved)
+	}
+	expected = append(expected, `C:\layers\top\layer.vhd`, `C:\layers\base\layer.vhd`)
+	if !slices.Equal(paths, expected) {
+		t.Fatalf("expected layer paths %v, got %v", expected, paths)
+	}
+	if l.ScratchVHDPath != `C:\scratch\sandbox.vhdx` {
+		t.Fatalf("unexpected scratch path %s", l.ScratchVHDPath)
+	}
+
+	_, err = ParseLCOWLayers(nil, layerFolders, WithExtraLCOWLayers(dir, []string{filepath.Join(dir, "missing.vhd")}))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error for missing extra layer, got %v", err)
+	}
+
+	// a path that is only within the root before it is cleaned
+	escaped := dir + `\..\` + filepath.Base(filepath.Dir(outside)) + `\outside.vhd`
+	for _, p := range []string{writable, dir, outside, escaped, `layers\agent.vhd`} {
+		_, err = ParseLCOWLayers(nil, layerFolders, WithExtraLCOWLayers(dir, []string{p}))
+		if !errors.Is(err, errdefs.ErrInvalidArgument) {
+			t.Fatalf("expected %v for extra layer %s, got %v", errdefs.ErrInvalidArgument, p, err)
+		}
+	}
+
+	for _, root := range []string{"", `layers`} {
+		_, err = ParseLCOWLayers(nil, layerFolders, WithExtraLCOWLayers(root, []string{agent}))
+		if !errors.Is(err, errdefs.ErrFailedPrecondition) {
+			t.Fatalf("expected %v for extra layer root %q, got %v", errdefs.ErrFailedPrecondition, root, err)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/Microsoft/hcsshim/internal/hcserror"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/oplimit"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
@@ -28,11 +29,21 @@ import (
 )
 
 func MountWCOWLayers(ctx context.Context, containerID string, vm *uvm.UtilityVM, wl WCOWLayers) (_ *MountedWCOWLayers, _ resources.ResourceCloser, err error) {
+	release, err := oplimit.Acquire(ctx, "MountWCOWLayers")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
 	switch l := wl.(type) {
 	case *wcowWCIFSLayers:
 		if vm == nil {
 			return mountProcessIsolatedWCIFSLayers(ctx, l)
 		}
+		// remote layers would need to be shared into the UVM over VSMB from the share
+		if hasRemoteLayers(l.layerPaths) {
+			return nil, nil, fmt.Errorf("hyperv isolated containers: %w", ErrRemoteLayersUnsupported)
+		}
 		return mountHypervIsolatedWCIFSLayers(ctx, l, vm)
 	case *wcowForkedCIMLayers:
 		if vm == nil {
@@ -88,6 +99,15 @@ func (l *wcowHostWCIFSLayerCloser) Release(ctx context.Context) error {
 }
 
 func mountProcessIsolatedWCIFSLayers(ctx context.Context, l *wcowWCIFSLayers) (_ *MountedWCOWLayers, _ resources.ResourceCloser, err error) {
+	// Check that any remote layers are accessible up front, so that share permission
+	// issues are not reported as generic layer activation failures.
+	remote := hasRemoteLayers(l.layerPaths)
+	if remote {
+		if err := checkRemoteWCIFSLayers(ctx, l.layerPaths); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// In some legacy layer use cases the scratch VHD might not be already created by the client
 	// continue to support those scenarios.
 	if err = ensureScratchVHD(ctx, l.scratchLayerPath, l.layerPaths); err != nil {
@@ -133,6 +153,9 @@ func mountProcessIsolatedWCIFSLayers(ctx context.Context, l *wcowWCIFSLayers) (_
 				}
 			}
 			// This was a failure case outside of the commonly known error conditions, don't retry here.
+			if remote && isAccessDenied(lErr) {
+				return nil, nil, fmt.Errorf("%w: %w", ErrLayerAccessDenied, lErr)
+			}
 			return nil, nil, lErr
 		}
 
@@ -329,6 +352,38 @@ func mountProcessIsolatedBlockCIMLayers(ctx context.Context, containerID string,
 	return mountedLayers, rcl, nil
 }
 
+// vsmbLayerParents returns the parent directories of the layers in `layerPaths` that
+// can be shared into a uVM through a single VSMB share of the parent, by layer path.
+//
+// Layers are only consolidated if another layer has the same parent, so that a share
+// is not added for a directory that is only used by one layer, and never through the
+// share of a volume's root directory. Since the uVM can read all of the share,
+// [uvm.UtilityVM.AddVSMBSubPath] also checks that the parent holds nothing but the
+// layers shared through it each time it is referenced: for example, the snapshots
+// directory of containerd also holds the layers of every other image, so those layers
+// are always shared individually.
+func vsmbLayerParents(layerPaths []string) map[string]string {
+	byParent := make(map[string][]string)
+	for _, p := range layerPaths {
+		p = filepath.Clean(p)
+		parent := filepath.Dir(p)
+		if parent == p || parent == filepath.VolumeName(p)+string(filepath.Separator) {
+			continue
+		}
+		byParent[parent] = append(byParent[parent], p)
+	}
+	parents := make(map[string]string)
+	for parent, layers := range byParent {
+		if len(layers) < 2 {
+			continue
+		}
+		for _, p := range layers {
+			parents[p] = parent
+		}
+	}
+	return parents
+}
+
 type wcowIsolatedWCIFSLayerCloser struct {
 	uvm                     *uvm.UtilityVM
 	guestCombinedLayersPath string
@@ -386,11 +441,24 @@ func mountHypervIsolatedWCIFSLayers(ctx context.Context, l *wcowWCIFSLayers, vm
 		}
 	}()
 
+	var parents map[string]string
+	if vm.VSMBConsolidateLayers() {
+		parents = vsmbLayerParents(l.layerPaths)
+	}
 	for _, layerPath := range l.layerPaths {
 		log.G(ctx).WithField("layerPath", layerPath).Debug("mounting layer")
 		options := vm.DefaultVSMBOptions(true)
 		options.TakeBackupPrivilege = true
-		mount, err := vm.AddVSMB(ctx, layerPath, options)
+		var mount *uvm.VSMBShare
+		if parent, ok := parents[filepath.Clean(layerPath)]; ok {
+			mount, err = vm.AddVSMBSubPath(ctx, parent, layerPath, l.layerPaths, options)
+			if errors.Is(err, uvm.ErrVSMBParentNotExclusive) {
+				log.G(ctx).WithError(err).Debug("sharing layer on its own")
+				mount, err = vm.AddVSMB(ctx, layerPath, options)
+			}
+		} else {
+			mount, err = vm.AddVSMB(ctx, layerPath, options)
+		}
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to add VSMB layer: %w", err)
 		}
@@ -401,7 +469,7 @@ func mountHypervIsolatedWCIFSLayers(ctx context.Context, l *wcowWCIFSLayers, vm
 	hostPath := filepath.Join(l.scratchLayerPath, "sandbox.vhdx")
 	log.G(ctx).WithField("hostPath", hostPath).Debug("mounting scratch VHD")
 
-	scsiMount, err := vm.SCSIManager.AddVirtualDisk(ctx, hostPath, false, vm.ID(), "", &scsi.MountConfig{})
+	scsiMount, err := vm.SCSIManager.AddVirtualDisk(ctx, hostPath, false, vm.ID(), "", &scsi.MountConfig{PathTag: "scratch"})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to add SCSI scratch VHD: %w", err)
 	}
@@ -422,10 +490,7 @@ func mountHypervIsolatedWCIFSLayers(ctx context.Context, l *wcowWCIFSLayers, vm
 	// sending to GCS
 	hcsLayers := []hcsschema.Layer{}
 	for _, a := range layersAdded {
-		uvmPath, err := vm.GetVSMBUvmPath(ctx, a.HostPath, true)
-		if err != nil {
-			return nil, nil, err
-		}
+		uvmPath := a.GuestPath()
 		layerID, err := wclayer.LayerID(ctx, a.HostPath)
 		if err != nil {
 			return nil, nil, err
@@ -492,6 +557,7 @@ func mountHypervIsolatedBlockCIMLayers(ctx context.Context, l *wcowBlockCIMLayer
 	scsiMount, err := vm.SCSIManager.AddVirtualDisk(ctx, hostPath, false, vm.ID(), "",
 		&scsi.MountConfig{
 			FormatWithRefs: vm.HasConfidentialPolicy(),
+			PathTag:        "scratch",
 		})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to add SCSI scratch VHD: %w", err)
@@ -0,0 +1,56 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"maps"
+	"path/filepath"
+	"testing"
+)
+
+func TestVSMBLayerParents(t *testing.T) {
+	root := t.TempDir()
+	snapshots := filepath.Join(root, "snapshots")
+	for _, tc := range []struct {
+		name     string
+		layers   []string
+		expected map[string]string
+	}{
+		{
+			name:   "shared parent",
+			layers: []string{filepath.Join(snapshots, "3"), filepath.Join(snapshots, "2") + `\`, filepath.Join(snapshots, "1")},
+			expected: map[string]string{
+				filepath.Join(snapshots, "3"): snapshots,
+				filepath.Join(snapshots, "2"): snapshots,
+				filepath.Join(snapshots, "1"): snapshots,
+			},
+		},
+		{
+			name:   "mixed parents",
+			layers: []string{filepath.Join(root, `extra\agent`), filepath.Join(snapshots, "3"), filepath.Join(snapshots, "2"), filepath.Join(snapshots, "1")},
+			expected: map[string]string{
+				filepath.Join(snapshots, "3"): snapshots,
+				filepath.Join(snapshots, "2"): snapshots,
+				filepath.Join(snapshots, "1"): snapshots,
+			},
+		},
+		{
+			name:     "volume root",
+			layers:   []string{`C:\2`, `C:\1`},
+			expected: map[string]string{},
+		},
+		{
+			name:     "single layer",
+			layers:   []string{filepath.Join(root, `extra\agent`)},
+			expected: map[string]string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parents := vsmbLayerParents(tc.layers)
+			if !maps.Equal(parents, tc.expected) {
+				t.Fatalf("expected parents %v, got %v", tc.expected, parents)
+			}
+		})
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Microsoft/go-winio/pkg/fs"
 	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/errdefs"
 
 	"github.com/Microsoft/hcsshim/internal/copyfile"
 	"github.com/Microsoft/hcsshim/internal/uvm"
@@ -179,23 +181,116 @@ func parseBlockCIMMount(m *types.Mount) (*wcowBlockCIMLayers, error) {
 	}, nil
 }
 
+// WithExtraLayers adds the read-only WCIFS layers at `paths` above the image's layers,
+// with the first being the top-most. The layers must be within the directory or share
+// `root`, and none can be added if it is empty.
+func WithExtraLayers(root string, paths []string) WCOWLayerOption {
+	return func(o *wcowLayerOptions) {
+		o.extraLayerRoot = root
+		o.extraLayers = append(o.extraLayers, paths...)
+	}
+}
+
+// checkExtraLayers verifies that the layers in `paths` are within `root`, and that the
+// local ones exist and are WCIFS layers, and returns the resolved paths. Remote layers
+// are otherwise checked when they are mounted.
+func checkExtraLayers(root string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	if root == "" {
+		return nil, fmt.Errorf("extra layers are not allowed without an extra layer root: %w", errdefs.ErrFailedPrecondition)
+	}
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("extra layer root %q must be absolute: %w", root, errdefs.ErrFailedPrecondition)
+	}
+	// resolve the root and the layers, so that neither a link in a layer's path nor one
+	// to the root can escape it
+	resolvedRoot, err := fs.ResolvePath(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve extra layer root: %w", err)
+	}
+
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			return nil, fmt.Errorf("extra layer path %q must be absolute: %w", p, errdefs.ErrInvalidArgument)
+		}
+		resolved, err := fs.ResolvePath(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra layer: %w", layerAccessError(p, err))
+		}
+		if rel, err := filepath.Rel(resolvedRoot, resolved); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("extra layer %s is not within %s: %w", p, root, errdefs.ErrInvalidArgument)
+		}
+		if !isRemotePath(resolved) {
+			if err := checkWCIFSLayer(resolved); err != nil {
+				return nil, fmt.Errorf("invalid extra layer: %w", err)
+			}
+		}
+		out = append(out, normalizeLayerPath(resolved))
+	}
+	return out, nil
+}
+
+func scratchLayerPathOf(rootfs []*types.Mount, layerFolders []string) string {
+	if len(layerFolders) > 0 {
+		return layerFolders[len(layerFolders)-1]
+	}
+	return rootfs[0].Source
+}
+
+// WCOWScratchLayerPath returns the path to the scratch layer from the layers provided by
+// containerd.
+func WCOWScratchLayerPath(rootfs []*types.Mount, layerFolders []string) (string, error) {
+	if err := validateRootfsAndLayers(rootfs, layerFolders); err != nil {
+		return "", err
+	}
+	return scratchLayerPathOf(rootfs, layerFolders), nil
+}
+
 // ParseWCOWLayers parses the layers provided by containerd into the format understood by
 // hcsshim and prepares them for mounting.
-func ParseWCOWLayers(rootfs []*types.Mount, layerFolders []string) (WCOWLayers, error) {
+//
+// The read-only WCIFS layers of process isolated containers may be stored on a remote
+// (SMB) share and be referred to by UNC paths. The scratch layer must always be local.
+func ParseWCOWLayers(rootfs []*types.Mount, layerFolders []string, opts ...WCOWLayerOption) (WCOWLayers, error) {
 	if err := validateRootfsAndLayers(rootfs, layerFolders); err != nil {
 		return nil, err
 	}
 
+	o := &wcowLayerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	scratchLayerPath := scratchLayerPathOf(rootfs, layerFolders)
+	if isRemotePath(scratchLayerPath) {
+		return nil, fmt.Errorf("scratch layer %s must be local: %w", scratchLayerPath, ErrRemoteLayersUnsupported)
+	}
+
+	extraLayers, err := checkExtraLayers(o.extraLayerRoot, o.extraLayers)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(layerFolders) > 0 {
 		return &wcowWCIFSLayers{
 			scratchLayerData: scratchLayerData{
 				scratchLayerPath: layerFolders[len(layerFolders)-1],
 			},
-			layerPaths: layerFolders[:len(layerFolders)-1],
+			layerPaths: append(extraLayers, normalizeLayerPaths(layerFolders[:len(layerFolders)-1])...),
 		}, nil
 	}
 
 	m := rootfs[0]
+	if len(extraLayers) > 0 && m.Type != legacyMountType {
+		return nil, fmt.Errorf("extra layers are not supported for %s mounts: %w", m.Type, errdefs.ErrNotImplemented)
+	}
 	switch m.Type {
 	case legacyMountType:
 		parentLayers, err := getOptionAsArray(m, parentLayerPathsFlag)
@@ -206,7 +301,7 @@ func ParseWCOWLayers(rootfs []*types.Mount, layerFolders []string) (WCOWLayers,
 			scratchLayerData: scratchLayerData{
 				scratchLayerPath: m.Source,
 			},
-			layerPaths: parentLayers,
+			layerPaths: append(extraLayers, normalizeLayerPaths(parentLayers)...),
 		}, nil
 	case forkedCIMMountType:
 		return parseForkedCimMount(m)
@@ -281,6 +376,9 @@ func GetWCOWUVMBootFilesFromLayers(ctx context.Context, rootfs []*types.Mount, l
 
 	switch wl := parsedWCOWLayers.(type) {
 	case *wcowWCIFSLayers:
+		if hasRemoteLayers(wl.layerPaths) {
+			return nil, fmt.Errorf("utility VM boot files: %w", ErrRemoteLayersUnsupported)
+		}
 		return getVmbFSBootFiles(ctx, wl.scratchLayerPath, wl.layerPaths)
 	case *wcowBlockCIMLayers:
 		return getBlockCIMBootFiles(ctx, wl)
@@ -0,0 +1,72 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/fs"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/errdefs"
+)
+
+func TestParseWCOWLayers_ExtraLayers(t *testing.T) {
+	root := t.TempDir()
+	extra := filepath.Join(root, "extra")
+	if err := os.MkdirAll(filepath.Join(extra, wcifsLayerFilesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := fs.ResolvePath(extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "outside")
+	if err := os.MkdirAll(filepath.Join(outside, wcifsLayerFilesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	layerFolders := []string{`C:\layers\top`, `C:\layers\base`, `C:\scratch`}
+
+	wl, err := ParseWCOWLayers(nil, layerFolders, WithExtraLayers(root, []string{extra + `\`}))
+	if err != nil {
+		t.Fatalf("failed to parse layers: %v", err)
+	}
+	l, ok := wl.(*wcowWCIFSLayers)
+	if !ok {
+		t.Fatalf("expected WCIFS layers, got %T", wl)
+	}
+	expected := []string{resolved, `C:\layers\top`, `C:\layers\base`}
+	if !slices.Equal(l.layerPaths, expected) {
+		t.Fatalf("expected layer paths %v, got %v", expected, l.layerPaths)
+	}
+
+	_, err = ParseWCOWLayers(nil, layerFolders, WithExtraLayers("", []string{extra}))
+	if !errors.Is(err, errdefs.ErrFailedPrecondition) {
+		t.Fatalf("expected %v for extra layers without a root, got %v", errdefs.ErrFailedPrecondition, err)
+	}
+
+	_, err = ParseWCOWLayers(nil, layerFolders, WithExtraLayers(root, []string{outside}))
+	if !errors.Is(err, errdefs.ErrInvalidArgument) {
+		t.Fatalf("expected %v for extra layer outside of the root, got %v", errdefs.ErrInvalidArgument, err)
+	}
+
+	_, err = ParseWCOWLayers(nil, layerFolders, WithExtraLayers(root, []string{filepath.Join(root, "missing")}))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error for missing extra layer, got %v", err)
+	}
+
+	_, err = ParseWCOWLayers(nil, layerFolders, WithExtraLayers(root, []string{`layers\extra`}))
+	if !errors.Is(err, errdefs.ErrInvalidArgument) {
+		t.Fatalf("expected %v for relative extra layer, got %v", errdefs.ErrInvalidArgument, err)
+	}
+
+	rootfs := []*types.Mount{{Type: blockCIMMountType, Source: `C:\scratch`}}
+	_, err = ParseWCOWLayers(rootfs, nil, WithExtraLayers(root, []string{extra}))
+	if !errors.Is(err, errdefs.ErrNotImplemented) {
+		t.Fatalf("expected %v for extra layers with CIM layers, got %v", errdefs.ErrNotImplemented, err)
+	}
+}
@@ -0,0 +1,139 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/errdefs"
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/hcsshim/internal/hcserror"
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+var (
+	// ErrRemoteLayersUnsupported is returned when remote (UNC) layer paths are used in
+	// a configuration that does not support them, such as for hyper-v isolated
+	// containers, or for the scratch layer.
+	ErrRemoteLayersUnsupported = fmt.Errorf("remote layer paths are not supported: %w", errdefs.ErrNotImplemented)
+	// ErrLayerAccessDenied is returned when a layer cannot be accessed, for example because
+	// the caller is not allowed to read the share the layer is stored on.
+	ErrLayerAccessDenied = fmt.Errorf("access denied to layer: %w", errdefs.ErrPermissionDenied)
+	// ErrLayerCorrupt is returned when a layer can be accessed, but is missing required
+	// contents.
+	ErrLayerCorrupt = fmt.Errorf("layer is corrupt: %w", errdefs.ErrFailedPrecondition)
+)
+
+// uncPrefix is the prefix of extended-length UNC paths, eg `\\?\UNC\server\share`.
+const uncPrefix = `\\?\UNC\`
+
+// wcifsLayerFilesDir is the directory in a WCIFS layer that contains the layer's files.
+const wcifsLayerFilesDir = "Files"
+
+// WCOWLayerOption configures how WCOW layers are accessed.
+type WCOWLayerOption func(*wcowLayerOptions)
+
+type wcowLayerOptions struct {
+	extraLayerRoot string
+	extraLayers    []string
+}
+
+// isRemotePath returns if `p` is a UNC path, eg `\\server\share\dir` or
+// `\\?\UNC\server\share\dir`.
+func isRemotePath(p string) bool {
+	if strings.HasPrefix(strings.ToUpper(p), uncPrefix) {
+		return true
+	}
+	// `\\?\` and `\\.\` prefixes are local device paths
+	return len(p) > 2 && isSlash(p[0]) && isSlash(p[1]) && p[2] != '?' && p[2] != '.'
+}
+
+func isSlash(c byte) bool {
+	return c == '\\' || c == '/'
+}
+
+// normalizeLayerPath cleans the layer path `p`, and converts extended-length UNC paths to
+// regular UNC paths, since the layer ID is derived from the last element of the path and
+// HCS expects the paths in the regular form.
+func normalizeLayerPath(p string) string {
+	if strings.HasPrefix(strings.ToUpper(p), uncPrefix) {
+		p = `\\` + p[len(uncPrefix):]
+	}
+	return filepath.Clean(p)
+}
+
+func normalizeLayerPaths(paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		out = append(out, normalizeLayerPath(p))
+	}
+	return out
+}
+
+func hasRemoteLayers(paths []string) bool {
+	for _, p := range paths {
+		if isRemotePath(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRemoteWCIFSLayers verifies that the remote layers in `paths` can be accessed, as
+// the shim, which the layers are activated as, and contain the files directory.
+func checkRemoteWCIFSLayers(ctx context.Context, paths []string) error {
+	for _, p := range paths {
+		if !isRemotePath(p) {
+			continue
+		}
+		if err := checkWCIFSLayer(p); err != nil {
+			return err
+		}
+		log.G(ctx).WithField("path", p).Debug("remote layer is accessible")
+	}
+	return nil
+}
+
+func checkWCIFSLayer(p string) error {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return layerAccessError(p, err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrLayerCorrupt, p)
+	}
+	files := filepath.Join(p, wcifsLayerFilesDir)
+	fi, err = os.Stat(files)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && !fi.IsDir()) {
+		return fmt.Errorf("%w: %s is missing the %s directory", ErrLayerCorrupt, p, wcifsLayerFilesDir)
+	} else if err != nil {
+		return layerAccessError(files, err)
+	}
+	return nil
+}
+
+// layerAccessError wraps `err` from accessing `p` with ErrLayerAccessDenied if the access
+// was denied, either to the share or to the path itself.
+func layerAccessError(p string, err error) error {
+	if isAccessDenied(err) {
+		return fmt.Errorf("%w: %s: %w", ErrLayerAccessDenied, p, err)
+	}
+	return fmt.Errorf("failed to access layer %s: %w", p, err)
+}
+
+// isAccessDenied returns if `err` is, or is an [hcserror.HcsError] for, a Win32 error
+// denying access to a path or share.
+func isAccessDenied(err error) bool {
+	switch windows.Errno(hcserror.Win32FromError(err)) {
+	case windows.ERROR_ACCESS_DENIED, windows.ERROR_LOGON_FAILURE, windows.ERROR_NETWORK_ACCESS_DENIED:
+		return true
+	}
+	return false
+}
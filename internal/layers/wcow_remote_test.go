@@ -0,0 +1,94 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/errdefs"
+)
+
+func Test_isRemotePath(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		remote bool
+	}{
+		{path: `C:\layers\abc`},
+		{path: `\\?\C:\layers\abc`},
+		{path: `\\.\PhysicalDrive0`},
+		{path: `\\?\Volume{6a5b2f3c-0000-0000-0000-100000000000}\layers`},
+		{path: `\\server\share\layers\abc`, remote: true},
+		{path: `//server/share/layers/abc`, remote: true},
+		{path: `\\?\UNC\server\share\layers\abc`, remote: true},
+		{path: `\\?\unc\server\share\layers\abc`, remote: true},
+	} {
+		if r := isRemotePath(tc.path); r != tc.remote {
+			t.Errorf("isRemotePath(%q): expected %t, got %t", tc.path, tc.remote, r)
+		}
+	}
+}
+
+func Test_normalizeLayerPath(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{path: `C:\layers\abc\`, expected: `C:\layers\abc`},
+		{path: `\\server\share\layers\abc\`, expected: `\\server\share\layers\abc`},
+		{path: `\\?\UNC\server\share\layers\abc`, expected: `\\server\share\layers\abc`},
+		{path: `\\server\share\layers\..\layers\abc`, expected: `\\server\share\layers\abc`},
+	} {
+		if p := normalizeLayerPath(tc.path); p != tc.expected {
+			t.Errorf("normalizeLayerPath(%q): expected %q, got %q", tc.path, tc.expected, p)
+		}
+	}
+}
+
+func Test_checkWCIFSLayer(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid")
+	if err := os.MkdirAll(filepath.Join(valid, wcifsLayerFilesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWCIFSLayer(valid); err != nil {
+		t.Fatalf("expected layer %s to be valid: %v", valid, err)
+	}
+
+	noFiles := filepath.Join(dir, "nofiles")
+	if err := os.MkdirAll(noFiles, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWCIFSLayer(noFiles); !errors.Is(err, ErrLayerCorrupt) {
+		t.Fatalf("expected %v, got %v", ErrLayerCorrupt, err)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	err := checkWCIFSLayer(missing)
+	if !errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrLayerCorrupt) || errors.Is(err, ErrLayerAccessDenied) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestParseWCOWLayers_Remote(t *testing.T) {
+	wl, err := ParseWCOWLayers(nil, []string{`\\?\UNC\server\share\layers\base\`, `C:\scratch`})
+	if err != nil {
+		t.Fatalf("failed to parse layers: %v", err)
+	}
+	l, ok := wl.(*wcowWCIFSLayers)
+	if !ok {
+		t.Fatalf("expected WCIFS layers, got %T", wl)
+	}
+	if len(l.layerPaths) != 1 || l.layerPaths[0] != `\\server\share\layers\base` {
+		t.Fatalf("unexpected layer paths %v", l.layerPaths)
+	}
+
+	_, err = ParseWCOWLayers(nil, []string{`C:\layers\base`, `\\server\share\scratch`})
+	if !errors.Is(err, ErrRemoteLayersUnsupported) || !errors.Is(err, errdefs.ErrNotImplemented) {
+		t.Fatalf("expected %v for remote scratch, got %v", ErrRemoteLayersUnsupported, err)
+	}
+}
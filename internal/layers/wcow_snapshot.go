@@ -0,0 +1,114 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/Microsoft/go-winio/vhd"
+	"go.opencensus.io/trace"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/oc"
+)
+
+// SnapshotWCOWScratch copies the scratch layer at `scratchLayerPath` into a new layer
+// directory at `snapshotPath`, which must not already exist. The snapshot can be exported
+// with the layer export APIs like any other writable layer.
+//
+// The scratch VHD is copied rather than used as the parent of a differencing disk, as the
+// container keeps writing to it and that would invalidate the child. The copy is crash
+// consistent only if the container is paused for the duration of the call.
+func SnapshotWCOWScratch(ctx context.Context, scratchLayerPath, snapshotPath string) (err error) {
+	ctx, span := oc.StartSpan(ctx, "layers::SnapshotWCOWScratch")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(
+		trace.StringAttribute("scratchLayerPath", scratchLayerPath),
+		trace.StringAttribute("snapshotPath", snapshotPath))
+
+	if err := os.MkdirAll(filepath.Dir(snapshotPath), 0777); err != nil {
+		return fmt.Errorf("failed to create snapshot parent directory: %w", err)
+	}
+	if err := os.Mkdir(snapshotPath, 0777); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rErr := os.RemoveAll(snapshotPath); rErr != nil {
+				log.G(ctx).WithError(rErr).WithField("path", snapshotPath).Warn("failed to remove snapshot on cleanup")
+			}
+		}
+	}()
+
+	return filepath.WalkDir(scratchLayerPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(scratchLayerPath, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		target := filepath.Join(snapshotPath, rel)
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, 0777)
+		case d.Type().IsRegular():
+			if err := copyLayerFile(path, target); err != nil {
+				return err
+			}
+			if isVHDPath(path) {
+				// the copy must not share the identity of the VHD it was taken from, or
+				// it cannot be attached while the container's scratch is attached.
+				id, err := guid.NewV4()
+				if err != nil {
+					return err
+				}
+				if err := vhd.SetVirtualDiskIdentifier(target, id); err != nil {
+					return fmt.Errorf("failed to set identifier of snapshot VHD %s: %w", target, err)
+				}
+			}
+			return nil
+		default:
+			log.G(ctx).WithField("path", path).Debug("skipping non-regular file in scratch layer")
+			return nil
+		}
+	})
+}
+
+// copyLayerFile copies `src` to the new file `dst`. The scratch VHD is held open by the
+// running container, so `src` is opened allowing other readers and writers, which
+// CopyFileW does not.
+func copyLayerFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return out.Close()
+}
+
+func isVHDPath(p string) bool {
+	ext := strings.ToLower(filepath.Ext(p))
+	return ext == ".vhd" || ext == ".vhdx"
+}
@@ -0,0 +1,56 @@
+//go:build windows
+// +build windows
+
+package layers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotWCOWScratch(t *testing.T) {
+	ctx := context.Background()
+	scratch := t.TempDir()
+	files := map[string]string{
+		"layerchain.json":       `["C:\\layers\\base"]`,
+		filepath.Join("a", "b"): "contents",
+	}
+	for p, c := range files {
+		p = filepath.Join(scratch, p)
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(c), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := filepath.Join(t.TempDir(), "snapshots", "1")
+	if err := SnapshotWCOWScratch(ctx, scratch, snapshot); err != nil {
+		t.Fatalf("failed to snapshot scratch: %v", err)
+	}
+
+	// later writes to the scratch must not be visible in the snapshot
+	if err := os.WriteFile(filepath.Join(scratch, "a", "b"), []byte("changed"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for p, c := range files {
+		b, err := os.ReadFile(filepath.Join(snapshot, p))
+		if err != nil {
+			t.Fatalf("failed to read snapshot file: %v", err)
+		}
+		if string(b) != c {
+			t.Fatalf("expected snapshot file %s to contain %q, got %q", p, c, b)
+		}
+	}
+
+	if err := SnapshotWCOWScratch(ctx, scratch, snapshot); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected snapshot into an existing directory to fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshot, "layerchain.json")); err != nil {
+		t.Fatalf("existing snapshot must not be removed by a failed snapshot: %v", err)
+	}
+}
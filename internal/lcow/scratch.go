@@ -97,9 +97,10 @@ func CreateScratch(ctx context.Context, lcowUVM *uvm.UtilityVM, destFile string,
 		"blockdev":   scsiMount.GuestPath(),
 	}).Debug("lcow::CreateScratch device attached")
 
-	// Format block device mount as ext4
+	// Format block device mount as ext4, with project quotas enabled so that
+	// containers sharing the scratch can be limited individually.
 	mkfsCtx, cancel := context.WithTimeout(ctx, timeout.ExternalCommandToStart)
-	cmd := cmdpkg.CommandContext(mkfsCtx, lcowUVM, "mkfs.ext4", "-q", "-E", "lazy_itable_init=0,nodiscard", "-O", `^has_journal,sparse_super2,^resize_inode`, scsiMount.GuestPath())
+	cmd := cmdpkg.CommandContext(mkfsCtx, lcowUVM, "mkfs.ext4", "-q", "-E", "lazy_itable_init=0,nodiscard", "-O", `^has_journal,sparse_super2,^resize_inode,quota,project`, scsiMount.GuestPath())
 	var mkfsStderr bytes.Buffer
 	cmd.Stderr = &mkfsStderr
 	err = cmd.Run()
@@ -12,6 +12,7 @@ var (
 	bucketKeyNetwork      = []byte("network")
 	bucketKeyEndpoint     = []byte("endpoint")
 	bucketKeyComputeAgent = []byte("computeagent")
+	bucketKeyOwnedHCN     = []byte("ownedhcnendpoint")
 )
 
 // Below is the current database schema. This should be updated any time the schema is
@@ -20,6 +21,9 @@ var (
 //     └──computeagent							 - Compute agent bucket
 //			└──containerID : <string>            - Entry in compute agent bucket: Address to
 //												   the compute agent for containerID
+//     └──ownedhcnendpoint						 - Owned HNS endpoint bucket
+//			└──endpointName/created : <json>     - Entry in owned HNS endpoint bucket: The
+//												   HNS endpoint ncproxy created as endpointName
 
 // taken from containerd/containerd/metadata/buckets.go
 func getBucket(tx *bolt.Tx, keys ...[]byte) *bolt.Bucket {
@@ -75,3 +79,11 @@ func createComputeAgentBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
 func getComputeAgentBucket(tx *bolt.Tx) *bolt.Bucket {
 	return getBucket(tx, bucketKeyVersion, bucketKeyComputeAgent)
 }
+
+func createOwnedHCNEndpointBucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	return createBucketIfNotExists(tx, bucketKeyVersion, bucketKeyOwnedHCN)
+}
+
+func getOwnedHCNEndpointBucket(tx *bolt.Tx) *bolt.Bucket {
+	return getBucket(tx, bucketKeyVersion, bucketKeyOwnedHCN)
+}
@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"time"
 
 	ncproxynetworking "github.com/Microsoft/hcsshim/internal/ncproxy/networking"
 	"github.com/pkg/errors"
@@ -183,6 +185,72 @@ func (n *NetworkingStore) ListEndpoints(ctx context.Context) (results []*ncproxy
 	return results, nil
 }
 
+// OwnedEndpoint records that ncproxy created the HNS endpoint with name `Name`.
+// HNS endpoints have no labels, so this record is how ncproxy tells the endpoints
+// it may clean up from those created by other agents.
+type OwnedEndpoint struct {
+	Name string `json:"name"`
+	// ID is the ID of the HNS endpoint, or empty if ncproxy has not finished
+	// creating it.
+	ID        string    `json:"id,omitempty"`
+	NetworkID string    `json:"network_id"`
+	Created   time.Time `json:"created"`
+}
+
+// key returns the key of the record in the database. HNS endpoint names need not be
+// unique, so the key includes the time the endpoint was created.
+func (e *OwnedEndpoint) key() []byte {
+	return []byte(e.Name + "/" + strconv.FormatInt(e.Created.UnixNano(), 10))
+}
+
+// PutOwnedEndpoint updates or adds the record of ncproxy owning the HNS endpoint
+// `endpt`.
+func (n *NetworkingStore) PutOwnedEndpoint(ctx context.Context, endpt *OwnedEndpoint) error {
+	return n.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := createOwnedHCNEndpointBucket(tx)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(endpt)
+		if err != nil {
+			return err
+		}
+		return bkt.Put(endpt.key(), data)
+	})
+}
+
+// DeleteOwnedEndpoint deletes the record of ncproxy owning the HNS endpoint `endpt`.
+func (n *NetworkingStore) DeleteOwnedEndpoint(ctx context.Context, endpt *OwnedEndpoint) error {
+	return n.db.Update(func(tx *bolt.Tx) error {
+		bkt := getOwnedHCNEndpointBucket(tx)
+		if bkt == nil {
+			return errors.Wrapf(ErrBucketNotFound, "bucket %v", bucketKeyOwnedHCN)
+		}
+		return bkt.Delete(endpt.key())
+	})
+}
+
+// ListOwnedEndpoints returns the records of the HNS endpoints owned by ncproxy.
+func (n *NetworkingStore) ListOwnedEndpoints(ctx context.Context) (results []*OwnedEndpoint, err error) {
+	if err := n.db.View(func(tx *bolt.Tx) error {
+		bkt := getOwnedHCNEndpointBucket(tx)
+		if bkt == nil {
+			return errors.Wrapf(ErrBucketNotFound, "owned endpoint bucket %v", bucketKeyOwnedHCN)
+		}
+		return bkt.ForEach(func(k, v []byte) error {
+			endpt := &OwnedEndpoint{}
+			if err := json.Unmarshal(v, endpt); err != nil {
+				return errors.Wrapf(err, "data is %v", string(v))
+			}
+			results = append(results, endpt)
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 // ComputeAgentStore is a database that stores a key value pair of container id
 // to compute agent server address
 type ComputeAgentStore struct {
@@ -2,8 +2,10 @@ package store
 
 import (
 	"context"
+	"errors"
 	"path/filepath"
 	"testing"
+	"time"
 
 	ncproxynetworking "github.com/Microsoft/hcsshim/internal/ncproxy/networking"
 	bolt "go.etcd.io/bbolt"
@@ -172,6 +174,69 @@ func TestEndpointStore_GetAll(t *testing.T) {
 	}
 }
 
+func TestOwnedEndpointStore(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+
+	db, err := bolt.Open(filepath.Join(tempDir, "networkproxy.db.test"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := NewNetworkingStore(db)
+
+	if _, err := store.ListOwnedEndpoints(ctx); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("expected %v, instead got %v", ErrBucketNotFound, err)
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	endpoint := &OwnedEndpoint{
+		Name:      "test-endpoint-name",
+		NetworkID: "test-network-id",
+		Created:   created,
+	}
+	if err := store.PutOwnedEndpoint(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	endpoint.ID = "test-endpoint-id"
+	if err := store.PutOwnedEndpoint(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := store.ListOwnedEndpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 1 {
+		t.Fatalf("expected 1 owned endpoint, instead got %d", len(actual))
+	}
+	if *actual[0] != *endpoint {
+		t.Fatalf("owned endpoint is not equal, expected %+v but got %+v", endpoint, actual[0])
+	}
+
+	// endpoint names need not be unique
+	other := &OwnedEndpoint{
+		Name:      endpoint.Name,
+		ID:        "test-endpoint-id-2",
+		NetworkID: "test-network-id",
+		Created:   created.Add(time.Second),
+	}
+	if err := store.PutOwnedEndpoint(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteOwnedEndpoint(ctx, endpoint); err != nil {
+		t.Fatal(err)
+	}
+	actual, err = store.ListOwnedEndpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 1 || *actual[0] != *other {
+		t.Fatalf("expected only owned endpoint %+v, instead got %v", other, actual)
+	}
+}
+
 func TestNetworkStore(t *testing.T) {
 	ctx := context.Background()
 	tempDir := t.TempDir()
@@ -2,9 +2,12 @@ package oci
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"path"
 	"slices"
 	"strconv"
 	"strings"
@@ -18,6 +21,7 @@ import (
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 )
 
 var ErrAnnotationExpansionConflict = errors.New("annotation expansion conflict")
@@ -204,6 +208,154 @@ func parseAdditionalRegistryValues(ctx context.Context, a map[string]string) []h
 	return slices.Clip(rvs)
 }
 
+// ErrInvalidRegistryValue is returned if the registry values to set in a container are invalid.
+var ErrInvalidRegistryValue = errors.New("invalid registry value")
+
+const (
+	// maxContainerRegistryValues is the maximum number of registry values that can be
+	// set in a container.
+	maxContainerRegistryValues = 64
+	// maxContainerRegistryValuesSize is the maximum size, in bytes, of the
+	// [iannotations.ContainerRegistryValues] annotation.
+	maxContainerRegistryValuesSize = 16 * 1024
+)
+
+// unsafeRegistryKey is a registry key, and optionally a value under it, that can be
+// used to load or run programs.
+type unsafeRegistryKey struct {
+	hive hcsschema.RegistryHive
+	// path of the key, including all subkeys.
+	// For the System hive, this is relative to the control set.
+	path string
+	// name of the value, or empty for all values under the key.
+	name string
+}
+
+var unsafeRegistryKeys = []unsafeRegistryKey{
+	{hive: hcsschema.RegistryHive_SYSTEM, path: `Services`, name: "ImagePath"},
+	{hive: hcsschema.RegistryHive_SYSTEM, path: `Services`, name: "ServiceDll"},
+	{hive: hcsschema.RegistryHive_SYSTEM, path: `Services`, name: "FailureCommand"},
+	{hive: hcsschema.RegistryHive_SYSTEM, path: `Control\Session Manager`},
+	{hive: hcsschema.RegistryHive_SYSTEM, path: `Control\Lsa`},
+	{hive: hcsschema.RegistryHive_SOFTWARE, path: `Microsoft\Windows NT\CurrentVersion\Image File Execution Options`},
+	{hive: hcsschema.RegistryHive_SOFTWARE, path: `Microsoft\Windows NT\CurrentVersion\Winlogon`},
+	{hive: hcsschema.RegistryHive_SOFTWARE, path: `Microsoft\Windows NT\CurrentVersion\Windows`, name: "AppInit_DLLs"},
+	{hive: hcsschema.RegistryHive_SOFTWARE, path: `Microsoft\Windows\CurrentVersion\Run`},
+	{hive: hcsschema.RegistryHive_SOFTWARE, path: `Microsoft\Windows\CurrentVersion\RunOnce`},
+}
+
+// ParseContainerRegistryValues extracts the registry values to set in a WCOW container
+// from annotations.
+//
+// Unlike [parseAdditionalRegistryValues], invalid registry values are returned as an error
+// rather than ignored.
+func ParseContainerRegistryValues(ctx context.Context, a map[string]string) ([]hcsschema.RegistryValue, error) {
+	k := iannotations.ContainerRegistryValues
+	v := a[k]
+	if v == "" {
+		return nil, nil
+	}
+	if len(v) > maxContainerRegistryValuesSize {
+		return nil, fmt.Errorf("%w: annotation %q is larger than %d bytes", ErrInvalidRegistryValue, k, maxContainerRegistryValuesSize)
+	}
+
+	var rvs []hcsschema.RegistryValue
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rvs); err != nil {
+		return nil, fmt.Errorf("%w: parse annotation %q: %w", ErrInvalidRegistryValue, k, err)
+	}
+	if len(rvs) > maxContainerRegistryValues {
+		return nil, fmt.Errorf("%w: annotation %q has more than %d values", ErrInvalidRegistryValue, k, maxContainerRegistryValues)
+	}
+
+	allowUnsafe := ParseAnnotationsBool(ctx, a, iannotations.ContainerRegistryValuesAllowUnsafe, false)
+	for i, rv := range rvs {
+		sensitive, err := validateContainerRegistryValue(rv)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %w", ErrInvalidRegistryValue, i, err)
+		}
+		if sensitive {
+			if !allowUnsafe {
+				return nil, fmt.Errorf("%w %d: setting %s\\%s\\%s requires annotation %q",
+					ErrInvalidRegistryValue, i, rv.Key.Hive, rv.Key.Name, rv.Name, iannotations.ContainerRegistryValuesAllowUnsafe)
+			}
+			log.G(ctx).WithField("registry-value", log.Format(ctx, rv)).Warning("setting security-sensitive registry value")
+		}
+	}
+	return rvs, nil
+}
+
+// validateContainerRegistryValue checks that rv is a valid registry value, and returns
+// if it is under a security-sensitive key.
+func validateContainerRegistryValue(rv hcsschema.RegistryValue) (sensitive bool, _ error) {
+	if rv.Key == nil {
+		return false, errors.New("registry key is required")
+	}
+	if rv.Key.Hive != hcsschema.RegistryHive_SYSTEM && rv.Key.Hive != hcsschema.RegistryHive_SOFTWARE {
+		return false, fmt.Errorf("unsupported registry hive %q", rv.Key.Hive)
+	}
+	if strings.Trim(rv.Key.Name, `\`) == "" {
+		return false, errors.New("registry key name is required")
+	}
+	if rv.Name == "" {
+		return false, errors.New("registry value name is required")
+	}
+
+	// exactly the field for the value's type may be set
+	var set []string
+	if rv.StringValue != "" {
+		set = append(set, "StringValue")
+	}
+	if rv.BinaryValue != "" {
+		set = append(set, "BinaryValue")
+	}
+	if rv.DWordValue != 0 {
+		set = append(set, "DWordValue")
+	}
+	if rv.QWordValue != 0 {
+		set = append(set, "QWordValue")
+	}
+	if rv.CustomType != 0 {
+		set = append(set, "CustomType")
+	}
+	var field string
+	switch rv.Type_ {
+	case hcsschema.RegistryValueType_STRING,
+		hcsschema.RegistryValueType_EXPANDED_STRING,
+		hcsschema.RegistryValueType_MULTI_STRING:
+		field = "StringValue"
+	case hcsschema.RegistryValueType_BINARY:
+		field = "BinaryValue"
+		if _, err := base64.StdEncoding.DecodeString(rv.BinaryValue); err != nil {
+			return false, fmt.Errorf("invalid binary value: %w", err)
+		}
+	case hcsschema.RegistryValueType_D_WORD:
+		field = "DWordValue"
+	case hcsschema.RegistryValueType_Q_WORD:
+		field = "QWordValue"
+	default:
+		return false, fmt.Errorf("unsupported registry value type %q", rv.Type_)
+	}
+	if len(set) > 1 || (len(set) == 1 && set[0] != field) {
+		return false, fmt.Errorf("registry value type %q only supports %s, but %s set", rv.Type_, field, strings.Join(set, ", "))
+	}
+
+	path := strings.Trim(rv.Key.Name, `\`)
+	if rv.Key.Hive == hcsschema.RegistryHive_SYSTEM {
+		// make the path relative to the control set (e.g., `CurrentControlSet` or `ControlSet001`)
+		cs, rest, ok := strings.Cut(path, `\`)
+		if cs = strings.ToLower(cs); ok && (cs == "currentcontrolset" || strings.HasPrefix(cs, "controlset")) {
+			path = rest
+		}
+	}
+	return slices.ContainsFunc(unsafeRegistryKeys, func(k unsafeRegistryKey) bool {
+		return k.hive == rv.Key.Hive &&
+			(strings.EqualFold(path, k.path) || strings.HasPrefix(strings.ToLower(path), strings.ToLower(k.path)+`\`)) &&
+			(k.name == "" || strings.EqualFold(rv.Name, k.name))
+	}), nil
+}
+
 // parseHVSocketServiceTable extracts any additional Hyper-V socket service configurations from annotations.
 //
 // Like the [parseAnnotation*] functions, this logs errors but does not return them.
@@ -250,6 +402,86 @@ func parseHVSocketServiceTable(ctx context.Context, a map[string]string) map[str
 	return sc
 }
 
+// parseDHCPClasslessStaticRoutes extracts the static classless routes to add to guest network
+// adapters configured with DHCP from annotations, in the format of the payload of the classless
+// static route DHCP option.
+//
+// Like the [parseAnnotation*] functions, this logs errors but does not return them.
+func parseDHCPClasslessStaticRoutes(ctx context.Context, a map[string]string) []byte {
+	k := iannotations.NetworkingDHCPClasslessStaticRoutes
+	v := a[k]
+	if v == "" {
+		return nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		logAnnotationValueParseError(ctx, k, v, fmt.Sprintf("%T", b), err)
+		return nil
+	}
+	// the routes must fit in a single DHCP option; the guest validates the routes themselves
+	if len(b) > 255 {
+		log.G(ctx).WithField(logfields.OCIAnnotation, k).Warn("classless static routes are longer than 255 bytes")
+		return nil
+	}
+	return b
+}
+
+// parseNetworkMTU extracts the MTU to set on guest network adapters from annotations.
+// Zero means the MTU is derived from the network endpoint instead.
+//
+// Like the [parseAnnotation*] functions, this logs errors but does not return them.
+func parseNetworkMTU(ctx context.Context, a map[string]string) uint16 {
+	k := iannotations.NetworkingMTU
+	v, ok := a[k]
+	if !ok {
+		return 0
+	}
+
+	mtu, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		logAnnotationValueParseError(ctx, k, v, "uint16", err)
+		return 0
+	}
+	if mtu < guestresource.MinNetworkMTU {
+		log.G(ctx).WithFields(logrus.Fields{
+			logfields.OCIAnnotation: k,
+			logfields.Value:         v,
+		}).Warnf("MTU must be at least %d", guestresource.MinNetworkMTU)
+		return 0
+	}
+	return uint16(mtu)
+}
+
+// maxVLANTag is the largest valid VLAN ID; 4095 is reserved by 802.1Q.
+const maxVLANTag = 4094
+
+// parseNetworkVLANTag extracts the VLAN ID to tag guest network adapters' traffic with
+// from annotations. Zero means traffic is not tagged.
+//
+// Like the [parseAnnotation*] functions, this logs errors but does not return them.
+func parseNetworkVLANTag(ctx context.Context, a map[string]string) uint16 {
+	k := iannotations.NetworkingVLANTag
+	v, ok := a[k]
+	if !ok {
+		return 0
+	}
+
+	tag, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		logAnnotationValueParseError(ctx, k, v, "uint16", err)
+		return 0
+	}
+	if tag > maxVLANTag {
+		log.G(ctx).WithFields(logrus.Fields{
+			logfields.OCIAnnotation: k,
+			logfields.Value:         v,
+		}).Warnf("VLAN tag must be between 1 and %d", maxVLANTag)
+		return 0
+	}
+	return uint16(tag)
+}
+
 // general annotation parsing
 
 // ParseAnnotationsBool searches `a` for `key` and if found verifies that the
@@ -401,3 +633,193 @@ func logAnnotationValueParseError(ctx context.Context, k, v, et string, err erro
 	}
 	entry.Warning("annotation value could not be parsed")
 }
+
+// ErrInvalidTmpfs is returned if the tmpfs to mount in an LCOW container is invalid.
+var ErrInvalidTmpfs = errors.New("invalid tmpfs")
+
+// ParseContainerTmpfsMount extracts the tmpfs to mount in an LCOW container from the
+// [annotations.ContainerTmpfsPath] and [annotations.ContainerTmpfsSizeBytes] annotations.
+// It returns nil if no tmpfs is requested.
+func ParseContainerTmpfsMount(a map[string]string) (*specs.Mount, error) {
+	p := a[annotations.ContainerTmpfsPath]
+	size := a[annotations.ContainerTmpfsSizeBytes]
+	if p == "" {
+		if size != "" {
+			return nil, fmt.Errorf("%w: annotation %q requires annotation %q",
+				ErrInvalidTmpfs, annotations.ContainerTmpfsSizeBytes, annotations.ContainerTmpfsPath)
+		}
+		return nil, nil
+	}
+	if !path.IsAbs(p) {
+		return nil, fmt.Errorf("%w: path %q is not absolute", ErrInvalidTmpfs, p)
+	}
+
+	m := &specs.Mount{
+		Destination: path.Clean(p),
+		Type:        "tmpfs",
+		Source:      "tmpfs",
+		Options:     []string{"nosuid", "nodev"},
+	}
+	if size != "" {
+		n, err := strconv.ParseUint(size, 10, 64)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("%w: size %q is not a positive number of bytes", ErrInvalidTmpfs, size)
+		}
+		m.Options = append(m.Options, "size="+strconv.FormatUint(n, 10))
+	}
+	return m, nil
+}
+
+// ParseContainerIOWeight extracts the I/O weight of an LCOW container from the
+// [annotations.ContainerStorageIOWeight] annotation. It returns 0 if no weight is
+// requested.
+func ParseContainerIOWeight(a map[string]string) (uint16, error) {
+	v, ok := a[annotations.ContainerStorageIOWeight]
+	if !ok {
+		return 0, nil
+	}
+	w, err := strconv.ParseUint(v, 10, 16)
+	if err != nil || w < guestresource.MinIOWeight || w > guestresource.MaxIOWeight {
+		return 0, fmt.Errorf("invalid %s annotation value %q: must be from %d to %d",
+			annotations.ContainerStorageIOWeight, v, guestresource.MinIOWeight, guestresource.MaxIOWeight)
+	}
+	return uint16(w), nil
+}
+
+// ErrInvalidHostAlias is returned if the /etc/hosts entries to add to an LCOW pod are invalid.
+var ErrInvalidHostAlias = errors.New("invalid host alias")
+
+// maxHostAliasesSize is the maximum size, in bytes, of the [annotations.LCOWHostAliases]
+// annotation.
+const maxHostAliasesSize = 16 * 1024
+
+// ParseHostAliases extracts the /etc/hosts entries to add to an LCOW pod from annotations.
+func ParseHostAliases(a map[string]string) ([]guestresource.HostAlias, error) {
+	k := annotations.LCOWHostAliases
+	v := a[k]
+	if v == "" {
+		return nil, nil
+	}
+	if len(v) > maxHostAliasesSize {
+		return nil, fmt.Errorf("%w: annotation %q is larger than %d bytes", ErrInvalidHostAlias, k, maxHostAliasesSize)
+	}
+
+	var has []guestresource.HostAlias
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&has); err != nil {
+		return nil, fmt.Errorf("%w: parse annotation %q: %w", ErrInvalidHostAlias, k, err)
+	}
+
+	for i, ha := range has {
+		if net.ParseIP(ha.IP) == nil {
+			return nil, fmt.Errorf("%w %d: invalid IP address %q", ErrInvalidHostAlias, i, ha.IP)
+		}
+		if len(ha.Hostnames) == 0 {
+			return nil, fmt.Errorf("%w %d: no hostnames specified for %s", ErrInvalidHostAlias, i, ha.IP)
+		}
+		for _, h := range ha.Hostnames {
+			// hostnames are written as whitespace-separated fields, so they cannot contain
+			// whitespace or start a comment
+			if h == "" || strings.ContainsAny(h, " \t\r\n#") {
+				return nil, fmt.Errorf("%w %d: invalid hostname %q", ErrInvalidHostAlias, i, h)
+			}
+		}
+	}
+	return has, nil
+}
+
+// ErrInvalidReadonlyLayer is returned if the extra read-only layers to add to an LCOW
+// container are invalid.
+var ErrInvalidReadonlyLayer = errors.New("invalid read-only layer")
+
+// ParseContainerReadonlyLayers extracts the paths of the extra read-only layer VHDs to add
+// to an LCOW container from the [annotations.ContainerReadonlyLayers] annotation.
+// The layers themselves are checked when the container's layers are parsed.
+func ParseContainerReadonlyLayers(a map[string]string) ([]string, error) {
+	k := annotations.ContainerReadonlyLayers
+	v := a[k]
+	if v == "" {
+		return nil, nil
+	}
+
+	var paths []string
+	if err := json.Unmarshal([]byte(v), &paths); err != nil {
+		return nil, fmt.Errorf("%w: parse annotation %q: %w", ErrInvalidReadonlyLayer, k, err)
+	}
+	for i, p := range paths {
+		if strings.TrimSpace(p) == "" {
+			return nil, fmt.Errorf("%w %d: empty path", ErrInvalidReadonlyLayer, i)
+		}
+	}
+	return paths, nil
+}
+
+// ImageVolumeMode is how the volumes declared by the image of a WCOW container are
+// handled, as set by [annotations.WCOWImageVolumeMode].
+type ImageVolumeMode string
+
+const (
+	// ImageVolumeModeIgnore leaves the volumes to the container's writable layer.
+	ImageVolumeModeIgnore ImageVolumeMode = "ignore"
+	// ImageVolumeModeEmptyDir backs each volume with a new empty directory on the host.
+	ImageVolumeModeEmptyDir ImageVolumeMode = "emptydir"
+	// ImageVolumeModeError fails creating the container.
+	ImageVolumeModeError ImageVolumeMode = "error"
+)
+
+// ErrInvalidImageVolume is returned if the image volumes of a WCOW container, or how to
+// handle them, are invalid.
+var ErrInvalidImageVolume = errors.New("invalid image volume")
+
+// ParseImageVolumes extracts the volumes declared by the image of a WCOW container from
+// the [annotations.WCOWImageVolumes] annotation of `s`, and how to handle them from the
+// [annotations.WCOWImageVolumeMode] annotation.
+// Volumes that are the destination of a mount in `s` are not returned.
+func ParseImageVolumes(s *specs.Spec) (ImageVolumeMode, []string, error) {
+	mode := ImageVolumeMode(ParseAnnotationsString(s.Annotations, annotations.WCOWImageVolumeMode, string(ImageVolumeModeIgnore)))
+	switch mode {
+	case ImageVolumeModeIgnore, ImageVolumeModeEmptyDir, ImageVolumeModeError:
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported mode %q for annotation %q",
+			ErrInvalidImageVolume, mode, annotations.WCOWImageVolumeMode)
+	}
+
+	mounted := make(map[string]struct{}, len(s.Mounts))
+	for _, m := range s.Mounts {
+		mounted[imageVolumeKey(m.Destination)] = struct{}{}
+	}
+	var volumes []string
+	for _, v := range ParseAnnotationCommaSeparated(annotations.WCOWImageVolumes, s.Annotations) {
+		v = strings.TrimSpace(v)
+		if !isWindowsAbsPath(v) {
+			return "", nil, fmt.Errorf("%w: path %q is not absolute", ErrInvalidImageVolume, v)
+		}
+		k := imageVolumeKey(v)
+		if _, ok := mounted[k]; ok {
+			continue
+		}
+		mounted[k] = struct{}{}
+		volumes = append(volumes, v)
+	}
+	return mode, volumes, nil
+}
+
+// isWindowsAbsPath returns if `p` is an absolute Windows path, either with a drive letter
+// or rooted on the system drive.
+func isWindowsAbsPath(p string) bool {
+	if len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') {
+		return true
+	}
+	return len(p) >= 1 && (p[0] == '\\' || p[0] == '/')
+}
+
+// imageVolumeKey normalizes the Windows path `p` so that paths to the same directory
+// compare equal, which Windows does case-insensitively.
+func imageVolumeKey(p string) string {
+	p = strings.ToLower(strings.ReplaceAll(p, "/", `\`))
+	if strings.HasPrefix(p, `\`) {
+		p = "c:" + p
+	}
+	return strings.TrimRight(p, `\`)
+}
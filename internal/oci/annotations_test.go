@@ -3,6 +3,7 @@ package oci
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 
 	iannotations "github.com/Microsoft/hcsshim/internal/annotations"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
 )
 
@@ -364,6 +366,282 @@ func TestParseAdditionalRegistryValues(t *testing.T) {
 	}
 }
 
+func TestParseContainerRegistryValues(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name        string
+		give        string
+		allowUnsafe bool
+		want        []hcsschema.RegistryValue
+		wantErr     bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "invalid",
+			give:    "invalid",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			give:    `[{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "DWord", "DWordValue": 1, "Unknown": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "nil key",
+			give:    `[{"Name": "v", "Type": "String", "StringValue": "value"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "security hive",
+			give:    `[{"Key": {"Hive": "Security", "Name": "a\\key"}, "Name": "v", "Type": "String", "StringValue": "value"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "empty key name",
+			give:    `[{"Key": {"Hive": "Software", "Name": "\\"}, "Name": "v", "Type": "String", "StringValue": "value"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			give:    `[{"Key": {"Hive": "Software", "Name": "a\\key"}, "Type": "String", "StringValue": "value"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "custom type",
+			give:    `[{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "CustomType", "CustomType": 1, "BinaryValue": "AA=="}]`,
+			wantErr: true,
+		},
+		{
+			name:    "mismatched value",
+			give:    `[{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "DWord", "StringValue": "1"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid binary",
+			give:    `[{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "Binary", "BinaryValue": "not base64"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "too large",
+			give:    `[{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "String", "StringValue": "` + strings.Repeat("a", 16*1024) + `"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "too many",
+			give:    "[" + strings.Repeat(`{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "DWord", "DWordValue": 1},`, 64) + `{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "v", "Type": "DWord", "DWordValue": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "service image path",
+			give:    `[{"Key": {"Hive": "System", "Name": "CurrentControlSet\\Services\\svc"}, "Name": "ImagePath", "Type": "ExpandedString", "StringValue": "c:\\svc.exe"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "service dll under control set",
+			give:    `[{"Key": {"Hive": "System", "Name": "ControlSet001\\Services\\svc\\Parameters"}, "Name": "servicedll", "Type": "ExpandedString", "StringValue": "c:\\svc.dll"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "image file execution options",
+			give:    `[{"Key": {"Hive": "Software", "Name": "Microsoft\\Windows NT\\CurrentVersion\\Image File Execution Options\\app.exe"}, "Name": "Debugger", "Type": "String", "StringValue": "c:\\d.exe"}]`,
+			wantErr: true,
+		},
+		{
+			name:        "service image path allowed",
+			give:        `[{"Key": {"Hive": "System", "Name": "CurrentControlSet\\Services\\svc"}, "Name": "ImagePath", "Type": "ExpandedString", "StringValue": "c:\\svc.exe"}]`,
+			allowUnsafe: true,
+			want: []hcsschema.RegistryValue{
+				{
+					Key: &hcsschema.RegistryKey{
+						Hive: hcsschema.RegistryHive_SYSTEM,
+						Name: `CurrentControlSet\Services\svc`,
+					},
+					Name:        "ImagePath",
+					Type_:       hcsschema.RegistryValueType_EXPANDED_STRING,
+					StringValue: `c:\svc.exe`,
+				},
+			},
+		},
+		{
+			name: "valid",
+			give: `[
+{"Key": {"Hive": "System", "Name": "CurrentControlSet\\Control\\SecurityProviders\\SCHANNEL\\Ciphers\\AES 128/128"},
+	"Name": "Enabled", "Type": "DWord", "DWordValue": 0 },
+{"Key": {"Hive": "System", "Name": "CurrentControlSet\\Services\\svc"}, "Name": "Start", "Type": "DWord", "DWordValue": 4 },
+{"Key": {"Hive": "Software", "Name": "a\\key"}, "Name": "binary", "Type": "Binary", "BinaryValue": "AAE=" }
+]`,
+			want: []hcsschema.RegistryValue{
+				{
+					Key: &hcsschema.RegistryKey{
+						Hive: hcsschema.RegistryHive_SYSTEM,
+						Name: `CurrentControlSet\Control\SecurityProviders\SCHANNEL\Ciphers\AES 128/128`,
+					},
+					Name:  "Enabled",
+					Type_: hcsschema.RegistryValueType_D_WORD,
+				},
+				{
+					Key: &hcsschema.RegistryKey{
+						Hive: hcsschema.RegistryHive_SYSTEM,
+						Name: `CurrentControlSet\Services\svc`,
+					},
+					Name:       "Start",
+					Type_:      hcsschema.RegistryValueType_D_WORD,
+					DWordValue: 4,
+				},
+				{
+					Key: &hcsschema.RegistryKey{
+						Hive: hcsschema.RegistryHive_SOFTWARE,
+						Name: `a\key`,
+					},
+					Name:        "binary",
+					Type_:       hcsschema.RegistryValueType_BINARY,
+					BinaryValue: "AAE=",
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := map[string]string{
+				iannotations.ContainerRegistryValues: strings.ReplaceAll(tt.give, "\n", ""),
+			}
+			if tt.allowUnsafe {
+				a[iannotations.ContainerRegistryValuesAllowUnsafe] = "true"
+			}
+			rvs, err := ParseContainerRegistryValues(ctx, a)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRegistryValue) {
+					t.Fatalf("expected error %v, got: %v", ErrInvalidRegistryValue, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, rvs); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseHostAliases(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		give    string
+		want    []guestresource.HostAlias
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "invalid",
+			give:    "invalid",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			give:    `[{"IP": "10.0.0.4", "Hostnames": ["foo"], "Unknown": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid IP",
+			give:    `[{"IP": "10.0.0", "Hostnames": ["foo"]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "no hostnames",
+			give:    `[{"IP": "10.0.0.4"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "empty hostname",
+			give:    `[{"IP": "10.0.0.4", "Hostnames": [""]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "hostname with space",
+			give:    `[{"IP": "10.0.0.4", "Hostnames": ["foo bar"]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "hostname with comment",
+			give:    `[{"IP": "10.0.0.4", "Hostnames": ["foo#bar"]}]`,
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			give: `[{"IP": "10.0.0.4", "Hostnames": ["foo.local", "bar.local"]}, {"IP": "fd00::4", "Hostnames": ["baz"]}]`,
+			want: []guestresource.HostAlias{
+				{IP: "10.0.0.4", Hostnames: []string{"foo.local", "bar.local"}},
+				{IP: "fd00::4", Hostnames: []string{"baz"}},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			has, err := ParseHostAliases(map[string]string{annotations.LCOWHostAliases: tt.give})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidHostAlias) {
+					t.Fatalf("expected error %v, got: %v", ErrInvalidHostAlias, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, has); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseContainerReadonlyLayers(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		give    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "invalid",
+			give:    `C:\layers\agent\layer.vhd`,
+			wantErr: true,
+		},
+		{
+			name:    "empty path",
+			give:    `["C:\\layers\\agent\\layer.vhd", " "]`,
+			wantErr: true,
+		},
+		{
+			name: "valid",
+			give: `["C:\\layers\\agent\\layer.vhd", "C:\\layers\\tools\\layer.vhd"]`,
+			want: []string{`C:\layers\agent\layer.vhd`, `C:\layers\tools\layer.vhd`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := ParseContainerReadonlyLayers(map[string]string{annotations.ContainerReadonlyLayers: tt.give})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidReadonlyLayer) {
+					t.Fatalf("expected error %v, got: %v", ErrInvalidReadonlyLayer, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, paths); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 func TestParseHVSocketServiceTable(t *testing.T) {
 	ctx := context.Background()
 
@@ -472,3 +750,326 @@ func TestParseHVSocketServiceTable(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDHCPClasslessStaticRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name string
+		give string
+		want []byte
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "invalid base64",
+			give: "not base64!",
+		},
+		{
+			name: "single",
+			give: "EApjwKgAAQ==",
+			want: []byte{16, 10, 99, 192, 168, 0, 1},
+		},
+		{
+			name: "too long",
+			give: base64.StdEncoding.EncodeToString(make([]byte, 256)),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			annots := map[string]string{}
+			if tt.give != "" {
+				annots[iannotations.NetworkingDHCPClasslessStaticRoutes] = tt.give
+			}
+
+			routes := parseDHCPClasslessStaticRoutes(ctx, annots)
+			t.Logf("got %v", routes)
+			if diff := cmp.Diff(tt.want, routes); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseNetworkMTU(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name string
+		give string
+		want uint16
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "invalid",
+			give: "jumbo",
+		},
+		{
+			name: "too large",
+			give: "65536",
+		},
+		{
+			name: "too small",
+			give: "67",
+		},
+		{
+			name: "valid",
+			give: "1400",
+			want: 1400,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			annots := map[string]string{}
+			if tt.give != "" {
+				annots[iannotations.NetworkingMTU] = tt.give
+			}
+
+			if mtu := parseNetworkMTU(ctx, annots); mtu != tt.want {
+				t.Fatalf("expected MTU %d, got %d", tt.want, mtu)
+			}
+		})
+	}
+}
+
+func TestParseNetworkVLANTag(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name string
+		give string
+		want uint16
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "invalid",
+			give: "trunk",
+		},
+		{
+			name: "reserved",
+			give: "4095",
+		},
+		{
+			name: "untagged",
+			give: "0",
+		},
+		{
+			name: "min",
+			give: "1",
+			want: 1,
+		},
+		{
+			name: "max",
+			give: "4094",
+			want: 4094,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			annots := map[string]string{}
+			if tt.give != "" {
+				annots[iannotations.NetworkingVLANTag] = tt.give
+			}
+
+			if tag := parseNetworkVLANTag(ctx, annots); tag != tt.want {
+				t.Fatalf("expected VLAN tag %d, got %d", tt.want, tag)
+			}
+		})
+	}
+}
+
+func TestParseContainerTmpfsMount(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		path    string
+		size    string
+		want    *specs.Mount
+		wantErr bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "unlimited",
+			path: "/mytmpfs/",
+			want: &specs.Mount{
+				Destination: "/mytmpfs",
+				Type:        "tmpfs",
+				Source:      "tmpfs",
+				Options:     []string{"nosuid", "nodev"},
+			},
+		},
+		{
+			name: "size",
+			path: "/mytmpfs",
+			size: "67108864",
+			want: &specs.Mount{
+				Destination: "/mytmpfs",
+				Type:        "tmpfs",
+				Source:      "tmpfs",
+				Options:     []string{"nosuid", "nodev", "size=67108864"},
+			},
+		},
+		{
+			name:    "size without path",
+			size:    "67108864",
+			wantErr: true,
+		},
+		{
+			name:    "relative path",
+			path:    "mytmpfs",
+			wantErr: true,
+		},
+		{
+			name:    "zero size",
+			path:    "/mytmpfs",
+			size:    "0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			path:    "/mytmpfs",
+			size:    "64M",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := map[string]string{}
+			if tt.path != "" {
+				a[annotations.ContainerTmpfsPath] = tt.path
+			}
+			if tt.size != "" {
+				a[annotations.ContainerTmpfsSizeBytes] = tt.size
+			}
+			m, err := ParseContainerTmpfsMount(a)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTmpfs) {
+					t.Fatalf("expected error %v, got: %v", ErrInvalidTmpfs, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, m); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestParseContainerIOWeight(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		want    uint16
+		wantErr bool
+	}{
+		{value: "", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "1", want: 1},
+		{value: "10000", want: 10000},
+		{value: "10001", wantErr: true},
+		{value: "-1", wantErr: true},
+	} {
+		t.Run(tt.value, func(t *testing.T) {
+			w, err := ParseContainerIOWeight(map[string]string{annotations.ContainerStorageIOWeight: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, got: %v", tt.wantErr, err)
+			}
+			if w != tt.want {
+				t.Fatalf("expected weight %d, got %d", tt.want, w)
+			}
+		})
+	}
+
+	if w, err := ParseContainerIOWeight(nil); err != nil || w != 0 {
+		t.Fatalf("expected no weight without the annotation, got %d, %v", w, err)
+	}
+}
+
+func TestParseImageVolumes(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		volumes     string
+		mode        string
+		mounts      []string
+		wantMode    ImageVolumeMode
+		wantVolumes []string
+		wantErr     bool
+	}{
+		{
+			name:     "none",
+			wantMode: ImageVolumeModeIgnore,
+		},
+		{
+			name:        "default mode",
+			volumes:     `C:\data,C:\logs`,
+			wantMode:    ImageVolumeModeIgnore,
+			wantVolumes: []string{`C:\data`, `C:\logs`},
+		},
+		{
+			name:        "emptydir",
+			volumes:     `C:\data`,
+			mode:        "emptydir",
+			wantMode:    ImageVolumeModeEmptyDir,
+			wantVolumes: []string{`C:\data`},
+		},
+		{
+			name:        "skips mounted",
+			volumes:     `c:/Data/,C:\logs, \cache`,
+			mode:        "error",
+			mounts:      []string{`C:\data`, `C:\cache`},
+			wantMode:    ImageVolumeModeError,
+			wantVolumes: []string{`C:\logs`},
+		},
+		{
+			name:        "duplicates",
+			volumes:     `C:\data,c:\DATA\`,
+			mode:        "emptydir",
+			wantMode:    ImageVolumeModeEmptyDir,
+			wantVolumes: []string{`C:\data`},
+		},
+		{
+			name:    "relative path",
+			volumes: `data`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid mode",
+			volumes: `C:\data`,
+			mode:    "tmpfs",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &specs.Spec{Annotations: map[string]string{}}
+			if tt.volumes != "" {
+				s.Annotations[annotations.WCOWImageVolumes] = tt.volumes
+			}
+			if tt.mode != "" {
+				s.Annotations[annotations.WCOWImageVolumeMode] = tt.mode
+			}
+			for _, d := range tt.mounts {
+				s.Mounts = append(s.Mounts, specs.Mount{Source: `C:\host`, Destination: d})
+			}
+			mode, volumes, err := ParseImageVolumes(s)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidImageVolume) {
+					t.Fatalf("expected error %v, got: %v", ErrInvalidImageVolume, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if mode != tt.wantMode {
+				t.Fatalf("got mode %q, expected %q", mode, tt.wantMode)
+			}
+			if diff := cmp.Diff(tt.wantVolumes, volumes); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
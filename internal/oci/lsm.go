@@ -0,0 +1,72 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+// ErrLCOWLSMNotSupported is returned for an LCOW container or exec whose spec
+// requests an AppArmor profile or SELinux label, since the LCOW guest kernel
+// has neither Linux security module (LSM).
+var ErrLCOWLSMNotSupported = errors.New("the LCOW guest kernel does not support AppArmor or SELinux")
+
+// apparmorUnconfined is the AppArmor profile that does not confine a process,
+// which is the same as not having a profile.
+const apparmorUnconfined = "unconfined"
+
+// ValidateLCOWLSMLabels returns an error wrapping [ErrLCOWLSMNotSupported] if
+// the process `p`, or the SELinux mount label `mountLabel`, request AppArmor or
+// SELinux.
+//
+// If the [annotations.LCOWIgnoreLSMLabels] annotation is set in `a`, a warning
+// is logged instead, and the caller must clear the labels with
+// [ClearLCOWLSMLabels] so that they are not passed to the guest.
+func ValidateLCOWLSMLabels(ctx context.Context, a map[string]string, p *specs.Process, mountLabel string) error {
+	var labels []string
+	if p != nil {
+		if p.ApparmorProfile != "" && p.ApparmorProfile != apparmorUnconfined {
+			labels = append(labels, fmt.Sprintf("apparmorProfile %q", p.ApparmorProfile))
+		}
+		if p.SelinuxLabel != "" {
+			labels = append(labels, fmt.Sprintf("selinuxLabel %q", p.SelinuxLabel))
+		}
+	}
+	if mountLabel != "" {
+		labels = append(labels, fmt.Sprintf("mountLabel %q", mountLabel))
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+
+	requested := strings.Join(labels, ", ")
+	if ParseAnnotationsBool(ctx, a, annotations.LCOWIgnoreLSMLabels, false) {
+		log.G(ctx).WithFields(logrus.Fields{
+			"labels":   requested,
+			"reason":   ErrLCOWLSMNotSupported.Error(),
+			"override": annotations.LCOWIgnoreLSMLabels,
+		}).Warning("ignoring LSM labels requested by LCOW spec")
+		return nil
+	}
+	return fmt.Errorf("%w: spec requests %s, set the %s annotation to ignore them",
+		ErrLCOWLSMNotSupported, requested, annotations.LCOWIgnoreLSMLabels)
+}
+
+// ClearLCOWLSMLabels removes the AppArmor profile and SELinux label from the
+// process `p`, and the SELinux mount label from `l`, either of which may be nil.
+func ClearLCOWLSMLabels(p *specs.Process, l *specs.Linux) {
+	if p != nil {
+		p.ApparmorProfile = ""
+		p.SelinuxLabel = ""
+	}
+	if l != nil {
+		l.MountLabel = ""
+	}
+}
@@ -0,0 +1,83 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+func Test_ValidateLCOWLSMLabels(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		process     *specs.Process
+		mountLabel  string
+		wantErr     string
+	}{
+		{
+			name:    "none",
+			process: &specs.Process{},
+		},
+		{
+			name: "no process",
+		},
+		{
+			name:    "unconfined",
+			process: &specs.Process{ApparmorProfile: "unconfined"},
+		},
+		{
+			name:    "apparmor",
+			process: &specs.Process{ApparmorProfile: "runtime/default"},
+			wantErr: `apparmorProfile "runtime/default"`,
+		},
+		{
+			name:    "selinux",
+			process: &specs.Process{SelinuxLabel: "system_u:system_r:container_t:s0"},
+			wantErr: `selinuxLabel "system_u:system_r:container_t:s0"`,
+		},
+		{
+			name:       "mount label",
+			process:    &specs.Process{},
+			mountLabel: "system_u:object_r:container_file_t:s0",
+			wantErr:    `mountLabel "system_u:object_r:container_file_t:s0"`,
+		},
+		{
+			name:        "ignored",
+			annotations: map[string]string{annotations.LCOWIgnoreLSMLabels: "true"},
+			process:     &specs.Process{ApparmorProfile: "runtime/default", SelinuxLabel: "system_u:system_r:container_t:s0"},
+			mountLabel:  "system_u:object_r:container_file_t:s0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateLCOWLSMLabels(ctx, tc.annotations, tc.process, tc.mountLabel)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrLCOWLSMNotSupported) {
+				t.Fatalf("expected %v, got: %v", ErrLCOWLSMNotSupported, err)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error to contain %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_ClearLCOWLSMLabels(t *testing.T) {
+	p := &specs.Process{ApparmorProfile: "runtime/default", SelinuxLabel: "system_u:system_r:container_t:s0"}
+	l := &specs.Linux{MountLabel: "system_u:object_r:container_file_t:s0"}
+	ClearLCOWLSMLabels(p, l)
+	if p.ApparmorProfile != "" || p.SelinuxLabel != "" || l.MountLabel != "" {
+		t.Fatalf("expected labels to be cleared, got process %+v and mount label %q", p, l.MountLabel)
+	}
+	ClearLCOWLSMLabels(nil, nil)
+}
@@ -172,6 +172,24 @@ func handleAnnotationPreferredRootFSType(ctx context.Context, a map[string]strin
 	}
 }
 
+// handleAnnotationVPMem handles parsing the VPMem annotations for LCOW.
+// annotations.UVMVirtualPMEMCount and annotations.UVMVirtualPMEMMaxSizeBytes
+// take precedence over their legacy counterparts.
+func handleAnnotationVPMem(ctx context.Context, a map[string]string, lopts *uvm.OptionsLCOW) error {
+	lopts.VPMemDeviceCount = ParseAnnotationsUint32(ctx, a, annotations.VPMemCount, lopts.VPMemDeviceCount)
+	lopts.VPMemSizeBytes = ParseAnnotationsUint64(ctx, a, annotations.VPMemSize, lopts.VPMemSizeBytes)
+
+	if _, ok := a[annotations.UVMVirtualPMEMCount]; ok {
+		count := ParseAnnotationsUint32(ctx, a, annotations.UVMVirtualPMEMCount, 0)
+		if count < 1 || count > uvm.MaxVPMEMCount {
+			return fmt.Errorf("%s must be between 1 and %d: %q", annotations.UVMVirtualPMEMCount, uvm.MaxVPMEMCount, a[annotations.UVMVirtualPMEMCount])
+		}
+		lopts.VPMemDeviceCount = count
+	}
+	lopts.VPMemSizeBytes = ParseAnnotationsUint64(ctx, a, annotations.UVMVirtualPMEMMaxSizeBytes, lopts.VPMemSizeBytes)
+	return nil
+}
+
 // handleAnnotationFullyPhysicallyBacked handles parsing annotations.FullyPhysicallyBacked and setting
 // implied options from the result. For both LCOW and WCOW options.
 func handleAnnotationFullyPhysicallyBacked(ctx context.Context, a map[string]string, opts interface{}) {
@@ -319,7 +337,19 @@ func specToUVMCreateOptionsCommon(ctx context.Context, opts *uvm.Options, s *spe
 	opts.ProcessDumpLocation = ParseAnnotationsString(s.Annotations, annotations.ContainerProcessDumpLocation, opts.ProcessDumpLocation)
 	opts.NoWritableFileShares = ParseAnnotationsBool(ctx, s.Annotations, annotations.DisableWritableFileShares, opts.NoWritableFileShares)
 	opts.DumpDirectoryPath = ParseAnnotationsString(s.Annotations, annotations.DumpDirectoryPath, opts.DumpDirectoryPath)
+	opts.StdioPortsWarningThreshold = ParseAnnotationsUint32(ctx, s.Annotations, annotations.StdioPortsWarningThreshold,
+		opts.StdioPortsWarningThreshold)
+	opts.StatisticsIntervalSeconds = ParseAnnotationsInt32(ctx, s.Annotations, annotations.StatisticsInterval,
+		opts.StatisticsIntervalSeconds)
+	opts.AdmitContainers = ParseAnnotationsBool(ctx, s.Annotations, annotations.AdmitContainers, opts.AdmitContainers)
+	opts.GuestReservedMemoryInMB = ParseAnnotationsUint64(ctx, s.Annotations, annotations.GuestReservedMemoryInMB, opts.GuestReservedMemoryInMB)
+	opts.GrowToFitContainers = ParseAnnotationsBool(ctx, s.Annotations, annotations.GrowToFitContainers, opts.GrowToFitContainers)
 	opts.ConsolePipe = ParseAnnotationsString(s.Annotations, iannotations.UVMConsolePipe, opts.ConsolePipe)
+	opts.SCSIControllerCount = ParseAnnotationsUint32(ctx, s.Annotations, annotations.SCSIControllerCount, opts.SCSIControllerCount)
+	opts.SCSILayerControllerCount = ParseAnnotationsUint32(ctx, s.Annotations, annotations.SCSILayerControllerCount, opts.SCSILayerControllerCount)
+	opts.EnableTPM = ParseAnnotationsBool(ctx, s.Annotations, annotations.UVMEnableTPM, opts.EnableTPM)
+	opts.TPMStateFilePath = ParseAnnotationsString(s.Annotations, annotations.UVMTPMStateFilePath, opts.TPMStateFilePath)
+	opts.KeepTPMState = ParseAnnotationsBool(ctx, s.Annotations, annotations.UVMKeepTPMState, opts.KeepTPMState)
 
 	// NUMA settings
 	opts.MaxProcessorsPerNumaNode = ParseAnnotationsUint32(ctx, s.Annotations, annotations.NumaMaximumProcessorsPerNode, opts.MaxProcessorsPerNumaNode)
@@ -341,6 +371,17 @@ func specToUVMCreateOptionsCommon(ctx context.Context, opts *uvm.Options, s *spe
 	if err != nil {
 		return err
 	}
+	opts.GuestLogsTailLines = ParseAnnotationsUint32(ctx, s.Annotations, annotations.GuestLogsTailLines, opts.GuestLogsTailLines)
+	if opts.GuestLogsTailLines > uvm.MaxGuestLogsTailLines {
+		return fmt.Errorf("%s cannot be greater than %d: %q", annotations.GuestLogsTailLines, uvm.MaxGuestLogsTailLines,
+			s.Annotations[annotations.GuestLogsTailLines])
+	}
+	if v, ok := s.Annotations[annotations.NetworkQoSEgressBandwidthMaximum]; ok {
+		opts.EgressBandwidthMaximum, err = uvm.ParseEgressBandwidth(v)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -366,18 +407,24 @@ func SpecToUVMCreateOpts(ctx context.Context, s *specs.Spec, id, owner string) (
 		*/
 
 		lopts.EnableColdDiscardHint = ParseAnnotationsBool(ctx, s.Annotations, annotations.EnableColdDiscardHint, lopts.EnableColdDiscardHint)
-		lopts.VPMemDeviceCount = ParseAnnotationsUint32(ctx, s.Annotations, annotations.VPMemCount, lopts.VPMemDeviceCount)
-		lopts.VPMemSizeBytes = ParseAnnotationsUint64(ctx, s.Annotations, annotations.VPMemSize, lopts.VPMemSizeBytes)
+		if err := handleAnnotationVPMem(ctx, s.Annotations, lopts); err != nil {
+			return nil, err
+		}
 		lopts.VPMemNoMultiMapping = ParseAnnotationsBool(ctx, s.Annotations, annotations.VPMemNoMultiMapping, lopts.VPMemNoMultiMapping)
 		lopts.VPCIEnabled = ParseAnnotationsBool(ctx, s.Annotations, annotations.VPCIEnabled, lopts.VPCIEnabled)
+		lopts.NestedVirtualization = ParseAnnotationsBool(ctx, s.Annotations, annotations.UVMEnableNestedVirtualization, lopts.NestedVirtualization)
 		lopts.ExtraVSockPorts = ParseAnnotationCommaSeparatedUint32(ctx, s.Annotations, iannotations.ExtraVSockPorts, lopts.ExtraVSockPorts)
 		handleAnnotationBootFilesPath(ctx, s.Annotations, lopts)
 		lopts.EnableScratchEncryption = ParseAnnotationsBool(ctx, s.Annotations, annotations.LCOWEncryptedScratchDisk, lopts.EnableScratchEncryption)
+		lopts.EnableScratchRepair = ParseAnnotationsBool(ctx, s.Annotations, annotations.LCOWScratchRepair, lopts.EnableScratchRepair)
 		lopts.SecurityPolicy = ParseAnnotationsString(s.Annotations, annotations.LCOWSecurityPolicy, lopts.SecurityPolicy)
 		lopts.SecurityPolicyEnforcer = ParseAnnotationsString(s.Annotations, annotations.LCOWSecurityPolicyEnforcer, lopts.SecurityPolicyEnforcer)
 		lopts.UVMReferenceInfoFile = ParseAnnotationsString(s.Annotations, annotations.LCOWReferenceInfoFile, lopts.UVMReferenceInfoFile)
 		lopts.KernelBootOptions = ParseAnnotationsString(s.Annotations, annotations.KernelBootOptions, lopts.KernelBootOptions)
 		lopts.DisableTimeSyncService = ParseAnnotationsBool(ctx, s.Annotations, annotations.DisableLCOWTimeSyncService, lopts.DisableTimeSyncService)
+		lopts.GCSSeccompMode = ParseAnnotationsString(s.Annotations, annotations.LCOWGCSSeccompMode, lopts.GCSSeccompMode)
+		lopts.RequireGCSSeccomp = ParseAnnotationsBool(ctx, s.Annotations, annotations.LCOWRequireGCSSeccomp, lopts.RequireGCSSeccomp)
+		lopts.DenyRootProcesses = ParseAnnotationsBool(ctx, s.Annotations, annotations.LCOWDenyRootProcesses, lopts.DenyRootProcesses)
 		lopts.WritableOverlayDirs = ParseAnnotationsBool(ctx, s.Annotations, iannotations.WritableOverlayDirs, lopts.WritableOverlayDirs)
 		handleAnnotationPreferredRootFSType(ctx, s.Annotations, lopts)
 		handleAnnotationKernelDirectBoot(ctx, s.Annotations, lopts)
@@ -397,7 +444,18 @@ func SpecToUVMCreateOpts(ctx context.Context, s *specs.Spec, id, owner string) (
 
 		// Add devices on the spec to the UVM's options
 		lopts.AssignedDevices = parseDevices(ctx, s.Windows)
+		lopts.AutoSizeMMIOGaps = ParseAnnotationsBool(ctx, s.Annotations, annotations.MemoryAutoSizeMMIOGaps, lopts.AutoSizeMMIOGaps)
 		lopts.PolicyBasedRouting = ParseAnnotationsBool(ctx, s.Annotations, iannotations.NetworkingPolicyBasedRouting, lopts.PolicyBasedRouting)
+		if routes := parseDHCPClasslessStaticRoutes(ctx, s.Annotations); routes != nil {
+			lopts.ClasslessStaticRoutes = routes
+		}
+		if mtu := parseNetworkMTU(ctx, s.Annotations); mtu != 0 {
+			lopts.NetworkMTU = mtu
+		}
+		if tag := parseNetworkVLANTag(ctx, s.Annotations); tag != 0 {
+			lopts.NetworkVLANTag = tag
+		}
+		lopts.GuestEgressShaping = ParseAnnotationsBool(ctx, s.Annotations, annotations.LCOWNetworkQoSGuestShaping, lopts.GuestEgressShaping)
 		return lopts, nil
 	} else if IsWCOW(s) {
 		wopts := uvm.NewDefaultOptionsWCOW(id, owner)
@@ -461,3 +519,26 @@ func UpdateSpecFromOptions(s specs.Spec, opts *runhcsopts.Options) specs.Spec {
 
 	return s
 }
+
+// ParseLCOWNetworkBandwidth extracts the bandwidth that the guest shapes the
+// traffic of the network namespace of an LCOW pod sandbox or standalone
+// container to from the [annotations.LCOWNetworkIngressBandwidthMbps] and
+// [annotations.LCOWNetworkEgressBandwidthMbps] annotations.
+func ParseLCOWNetworkBandwidth(a map[string]string) (uvm.NetworkBandwidth, error) {
+	var b uvm.NetworkBandwidth
+	for key, mbps := range map[string]*uint32{
+		annotations.LCOWNetworkIngressBandwidthMbps: &b.IngressMbps,
+		annotations.LCOWNetworkEgressBandwidthMbps:  &b.EgressMbps,
+	} {
+		v, ok := a[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return uvm.NetworkBandwidth{}, fmt.Errorf("invalid %s annotation value %q: %w", key, v, err)
+		}
+		*mbps = uint32(n)
+	}
+	return b, nil
+}
@@ -101,6 +101,48 @@ func Test_SpecToUVMCreateOptions_Default_LCOW(t *testing.T) {
 	}
 }
 
+func Test_SpecToUVMCreateOptions_LCOW_VPMem(t *testing.T) {
+	s := &specs.Spec{
+		Linux: &specs.Linux{},
+		Annotations: map[string]string{
+			annotations.VPMemCount:                 "16",
+			annotations.VPMemSize:                  "4096",
+			annotations.UVMVirtualPMEMCount:        "32",
+			annotations.UVMVirtualPMEMMaxSizeBytes: "8192",
+		},
+	}
+
+	opts, err := SpecToUVMCreateOpts(context.Background(), s, t.Name(), "")
+	if err != nil {
+		t.Fatalf("could not generate creation options from spec: %v", err)
+	}
+
+	lopts := (opts).(*uvm.OptionsLCOW)
+	if lopts.VPMemDeviceCount != 32 {
+		t.Fatalf("expected VPMemDeviceCount=32, got %d", lopts.VPMemDeviceCount)
+	}
+	if lopts.VPMemSizeBytes != 8192 {
+		t.Fatalf("expected VPMemSizeBytes=8192, got %d", lopts.VPMemSizeBytes)
+	}
+}
+
+func Test_SpecToUVMCreateOptions_LCOW_VPMem_InvalidCount(t *testing.T) {
+	for _, count := range []string{"0", "129", "invalid"} {
+		t.Run(count, func(t *testing.T) {
+			s := &specs.Spec{
+				Linux: &specs.Linux{},
+				Annotations: map[string]string{
+					annotations.UVMVirtualPMEMCount: count,
+				},
+			}
+
+			if _, err := SpecToUVMCreateOpts(context.Background(), s, t.Name(), ""); err == nil {
+				t.Fatalf("expected error for VPMem count %q, got nil", count)
+			}
+		})
+	}
+}
+
 func Test_SpecToUVMCreateOptions_Default_WCOW(t *testing.T) {
 	s := &specs.Spec{
 		Windows: &specs.Windows{
@@ -222,6 +264,19 @@ func Test_SpecToUVMCreateOptions_WCOW_Confidential_ErrorOnLowMemory(t *testing.T
 	}
 }
 
+func Test_SpecToUVMCreateOptions_GuestLogsTailLinesTooLarge(t *testing.T) {
+	s := &specs.Spec{
+		Linux: &specs.Linux{},
+		Annotations: map[string]string{
+			annotations.GuestLogsTailLines: fmt.Sprint(uvm.MaxGuestLogsTailLines + 1),
+		},
+	}
+
+	if _, err := SpecToUVMCreateOpts(context.Background(), s, t.Name(), ""); err == nil {
+		t.Fatalf("expected error for %s greater than %d, got nil", annotations.GuestLogsTailLines, uvm.MaxGuestLogsTailLines)
+	}
+}
+
 func Test_SpecToUVMCreateOptions_WCOW_Confidential_Overrides(t *testing.T) {
 	s := &specs.Spec{
 		Windows: &specs.Windows{HyperV: &specs.WindowsHyperV{}},
@@ -327,3 +382,54 @@ func Test_SpecToUVMCreateOptions_Common(t *testing.T) {
 		})
 	}
 }
+
+func Test_ParseLCOWNetworkBandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		expected    uvm.NetworkBandwidth
+		valid       bool
+	}{
+		{
+			name:  "None",
+			valid: true,
+		},
+		{
+			name: "Both",
+			annotations: map[string]string{
+				annotations.LCOWNetworkIngressBandwidthMbps: "10",
+				annotations.LCOWNetworkEgressBandwidthMbps:  "20",
+			},
+			expected: uvm.NetworkBandwidth{IngressMbps: 10, EgressMbps: 20},
+			valid:    true,
+		},
+		{
+			name: "Unit",
+			annotations: map[string]string{
+				annotations.LCOWNetworkIngressBandwidthMbps: "10M",
+			},
+		},
+		{
+			name: "Negative",
+			annotations: map[string]string{
+				annotations.LCOWNetworkEgressBandwidthMbps: "-1",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := ParseLCOWNetworkBandwidth(tc.annotations)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected bandwidth to be invalid, got %+v", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected bandwidth to be valid, got: %v", err)
+			}
+			if b != tc.expected {
+				t.Fatalf("expected bandwidth %+v, got %+v", tc.expected, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+//go:build windows
+
+package oplimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+)
+
+// hostMutexPrefix is the prefix of the names of the mutexes shared by all
+// processes with a host limit. Slot `i` is the mutex named `<prefix>-<i>`.
+const hostMutexPrefix = `Global\hcsshim-operation-limit`
+
+// maxHostLimit is the largest supported host limit: a wait can be on at most
+// 64 handles (MAXIMUM_WAIT_OBJECTS), one of which is used for cancellation.
+const maxHostLimit = 64 - 1
+
+// hostSemaphore is a counting semaphore shared across processes, built from
+// named Windows mutexes (one per slot).
+//
+// A named semaphore cannot be used, since the slots held by a process that exits
+// without releasing them (e.g., because it crashed) are never given back. A mutex
+// owned by a thread that exits is abandoned instead, and the next wait on it
+// acquires it.
+type hostSemaphore struct {
+	mutexes []windows.Handle
+}
+
+// openHostSemaphore opens the first `limit` slots of the host semaphore,
+// creating them if they do not exist.
+func openHostSemaphore(limit int) (_ *hostSemaphore, err error) {
+	if limit > maxHostLimit {
+		return nil, fmt.Errorf("invalid host operation limit %d: must be at most %d", limit, maxHostLimit)
+	}
+
+	s := &hostSemaphore{}
+	defer func() {
+		if err != nil {
+			s.close() //nolint:errcheck
+		}
+	}()
+	for i := 0; i < limit; i++ {
+		name := fmt.Sprintf("%s-%d", hostMutexPrefix, i)
+		n, err := windows.UTF16PtrFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		h, err := windows.CreateMutex(nil, false, n)
+		if err != nil && !errors.Is(err, windows.ERROR_ALREADY_EXISTS) {
+			return nil, fmt.Errorf("failed to create mutex %s: %w", name, err)
+		}
+		s.mutexes = append(s.mutexes, h)
+	}
+	return s, nil
+}
+
+// acquire waits for a slot in the semaphore, or until `ctx` is done. If it
+// returns successfully, the returned function must be called to release the slot.
+func (s *hostSemaphore) acquire(ctx context.Context) (release func() error, err error) {
+	// mutexes are owned by a thread, so the slot is acquired and released on a
+	// dedicated OS thread
+	acquired := make(chan error, 1)
+	releasing := make(chan struct{})
+	released := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		h, err := s.wait(ctx)
+		acquired <- err
+		if err != nil {
+			return
+		}
+		<-releasing
+		released <- windows.ReleaseMutex(h)
+	}()
+
+	if err := <-acquired; err != nil {
+		return nil, err
+	}
+	return func() error {
+		close(releasing)
+		return <-released
+	}, nil
+}
+
+// wait waits for any of the mutexes, or until `ctx` is done, and returns the
+// acquired mutex. It must be called on a locked OS thread.
+func (s *hostSemaphore) wait(ctx context.Context) (windows.Handle, error) {
+	// signaled when ctx is done, so the wait below can be interrupted
+	done, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(done) //nolint:errcheck
+	stop := context.AfterFunc(ctx, func() {
+		_ = windows.SetEvent(done)
+	})
+	defer stop()
+
+	// if several are signaled, the lowest index is acquired, so a free slot is
+	// preferred over cancellation
+	handles := append(append([]windows.Handle{}, s.mutexes...), done)
+	n := uint32(len(s.mutexes))
+	i, err := windows.WaitForMultipleObjects(handles, false, windows.INFINITE)
+	switch {
+	case i >= windows.WAIT_OBJECT_0 && i < windows.WAIT_OBJECT_0+n:
+		return s.mutexes[i-windows.WAIT_OBJECT_0], nil
+	case i == windows.WAIT_OBJECT_0+n:
+		return 0, ctx.Err()
+	case i >= windows.WAIT_ABANDONED && i < windows.WAIT_ABANDONED+n:
+		// the process that held the slot exited without releasing it; the slot is
+		// still acquired
+		slot := i - windows.WAIT_ABANDONED
+		log.G(ctx).WithField("slot", slot).Warn("acquired host operation limit slot abandoned by another process")
+		return s.mutexes[slot], nil
+	}
+	if err == nil {
+		err = errors.New("unexpected wait result")
+	}
+	return 0, fmt.Errorf("failed to wait for host operation limit: %w", err)
+}
+
+func (s *hostSemaphore) close() error {
+	var errs []error
+	for _, h := range s.mutexes {
+		errs = append(errs, windows.CloseHandle(h))
+	}
+	s.mutexes = nil
+	return errors.Join(errs...)
+}
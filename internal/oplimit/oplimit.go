@@ -0,0 +1,192 @@
+//go:build windows
+
+// Package oplimit bounds how many expensive host operations, such as creating
+// and starting compute systems or mounting container layers, run at once.
+//
+// HCS slows down considerably when it is asked to do many of these at the same
+// time, to the point that operations time out. Operations are not limited by
+// default; a process can opt in with [SetDefault].
+package oplimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+)
+
+// DefaultWaitLogThreshold is how long an operation can wait for a [Limiter]
+// before it is logged, if [Config.WaitLogThreshold] is not set.
+const DefaultWaitLogThreshold = time.Second
+
+// Config is the configuration of a [Limiter].
+type Config struct {
+	// Limit is the maximum number of operations run concurrently by the process.
+	// Zero means there is no limit.
+	Limit int
+	// HostLimit is the maximum number of operations run concurrently by all of
+	// the processes on the host that set a host limit, at most 63. The limit is
+	// enforced with a named mutex per slot, and a process only uses the first
+	// HostLimit slots, so processes should set the same limit. A slot held by a
+	// process that exits without releasing it is freed.
+	// Zero means there is no limit.
+	HostLimit int
+	// WaitLogThreshold is how long an operation can wait for a slot before a
+	// log line is written when it starts. Zero means [DefaultWaitLogThreshold].
+	WaitLogThreshold time.Duration
+}
+
+// Limiter limits the number of operations that run concurrently.
+//
+// A nil *Limiter does not limit operations.
+type Limiter struct {
+	// local is a counting semaphore for the process limit, or nil if there is
+	// no process limit
+	local chan struct{}
+	// host is the semaphore for the host limit, or nil if there is no host limit
+	host *hostSemaphore
+
+	waitLogThreshold time.Duration
+
+	queued atomic.Int32
+	active atomic.Int32
+}
+
+// New returns a [Limiter] configured by `c`. The limiter must be closed to
+// release the host semaphore.
+func New(c Config) (*Limiter, error) {
+	if c.Limit < 0 || c.HostLimit < 0 {
+		return nil, fmt.Errorf("invalid operation limits (%d, %d): limits must not be negative", c.Limit, c.HostLimit)
+	}
+
+	l := &Limiter{waitLogThreshold: c.WaitLogThreshold}
+	if l.waitLogThreshold <= 0 {
+		l.waitLogThreshold = DefaultWaitLogThreshold
+	}
+	if c.Limit > 0 {
+		l.local = make(chan struct{}, c.Limit)
+	}
+	if c.HostLimit > 0 {
+		h, err := openHostSemaphore(c.HostLimit)
+		if err != nil {
+			return nil, err
+		}
+		l.host = h
+	}
+	return l, nil
+}
+
+// Close releases the host semaphore, if any.
+func (l *Limiter) Close() error {
+	if l == nil || l.host == nil {
+		return nil
+	}
+	return l.host.close()
+}
+
+// Acquire waits until `op` can run, or until `ctx` is done. If it returns
+// successfully, the returned function must be called once `op` is finished.
+func (l *Limiter) Acquire(ctx context.Context, op string) (release func(), err error) {
+	if l == nil || (l.local == nil && l.host == nil) {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	releaseHost, err := l.wait(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("waiting to run %s: %w", op, err)
+	}
+	l.active.Add(1)
+
+	if waited := time.Since(start); waited > l.waitLogThreshold {
+		log.G(ctx).WithFields(logrus.Fields{
+			logfields.Operation: op,
+			logfields.Duration:  waited,
+		}).Warn("operation waited for concurrency limit")
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.active.Add(-1)
+			if releaseHost != nil {
+				if err := releaseHost(); err != nil {
+					log.G(ctx).WithError(err).WithField(logfields.Operation, op).Error("failed to release host operation limit")
+				}
+			}
+			if l.local != nil {
+				<-l.local
+			}
+		})
+	}, nil
+}
+
+// wait acquires a slot from the process limit, then from the host limit. It
+// returns the function that releases the host slot, if any.
+func (l *Limiter) wait(ctx context.Context) (releaseHost func() error, err error) {
+	l.queued.Add(1)
+	defer l.queued.Add(-1)
+
+	if l.local != nil {
+		select {
+		case l.local <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if l.host != nil {
+		releaseHost, err = l.host.acquire(ctx)
+		if err != nil {
+			if l.local != nil {
+				<-l.local
+			}
+			return nil, err
+		}
+	}
+	return releaseHost, nil
+}
+
+// Queued returns the number of operations waiting to run.
+func (l *Limiter) Queued() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.queued.Load())
+}
+
+// Active returns the number of operations running.
+func (l *Limiter) Active() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.active.Load())
+}
+
+var defaultLimiter atomic.Pointer[Limiter]
+
+// SetDefault sets the [Limiter] used by [Acquire]. It can only be set once.
+func SetDefault(l *Limiter) error {
+	if !defaultLimiter.CompareAndSwap(nil, l) {
+		return errors.New("default operation limiter is already set")
+	}
+	return nil
+}
+
+// Default returns the [Limiter] used by [Acquire], which may be nil.
+func Default() *Limiter {
+	return defaultLimiter.Load()
+}
+
+// Acquire waits until `op` can run with the default [Limiter].
+//
+// See [Limiter.Acquire].
+func Acquire(ctx context.Context, op string) (release func(), err error) {
+	return Default().Acquire(ctx, op)
+}
@@ -0,0 +1,106 @@
+//go:build windows
+
+package oplimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *Limiter
+	release, err := l.Acquire(context.Background(), t.Name())
+	if err != nil {
+		t.Fatalf("expected nil err, got: %v", err)
+	}
+	release()
+	if l.Queued() != 0 || l.Active() != 0 {
+		t.Fatalf("expected no queued or active operations, got %d and %d", l.Queued(), l.Active())
+	}
+}
+
+func TestLimiter_Invalid(t *testing.T) {
+	if _, err := New(Config{Limit: -1}); err == nil {
+		t.Fatal("expected error for negative limit")
+	}
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	ctx := context.Background()
+	l, err := New(Config{Limit: 2})
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer l.Close()
+
+	r1, err := l.Acquire(ctx, t.Name())
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	r2, err := l.Acquire(ctx, t.Name())
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		r, err := l.Acquire(ctx, t.Name())
+		if err != nil {
+			t.Errorf("failed to acquire: %v", err)
+		}
+		acquired <- r
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired more than the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if l.Queued() != 1 || l.Active() != 2 {
+		t.Fatalf("expected 1 queued and 2 active operations, got %d and %d", l.Queued(), l.Active())
+	}
+
+	r1()
+	// releasing more than once should not free another slot
+	r1()
+	var r3 func()
+	select {
+	case r3 = <-acquired:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting to acquire")
+	}
+	if l.Queued() != 0 || l.Active() != 2 {
+		t.Fatalf("expected 0 queued and 2 active operations, got %d and %d", l.Queued(), l.Active())
+	}
+
+	r2()
+	r3()
+	if l.Active() != 0 {
+		t.Fatalf("expected 0 active operations, got %d", l.Active())
+	}
+}
+
+func TestLimiter_ContextDone(t *testing.T) {
+	l, err := New(Config{Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to create limiter: %v", err)
+	}
+	defer l.Close()
+
+	release, err := l.Acquire(context.Background(), t.Name())
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, t.Name()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if l.Queued() != 0 || l.Active() != 1 {
+		t.Fatalf("expected 0 queued and 1 active operations, got %d and %d", l.Queued(), l.Active())
+	}
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package processorinfo
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// Processor vendors returned by [HostVendor].
+const (
+	VendorIntel = "GenuineIntel"
+	VendorAMD   = "AuthenticAMD"
+)
+
+// HostVendor returns the vendor ID of the host's processors, as reported by
+// CPUID, such as [VendorIntel] or [VendorAMD].
+func HostVendor() (string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DESCRIPTION\System\CentralProcessor\0`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", fmt.Errorf("failed to open host processor registry key: %w", err)
+	}
+	defer k.Close()
+	vendor, _, err := k.GetStringValue("VendorIdentifier")
+	if err != nil {
+		return "", fmt.Errorf("failed to read host processor vendor: %w", err)
+	}
+	return vendor, nil
+}
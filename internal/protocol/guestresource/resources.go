@@ -55,6 +55,9 @@ const (
 	ResourceTypeSecurityPolicy guestrequest.ResourceType = "SecurityPolicy"
 	// ResourceTypePolicyFragment is the modify resource type for injecting policy fragments.
 	ResourceTypePolicyFragment guestrequest.ResourceType = "SecurityPolicyFragment"
+	// ResourceTypeHostAliases is the modify resource type for adding entries to
+	// the /etc/hosts file of a pod
+	ResourceTypeHostAliases guestrequest.ResourceType = "HostAliases"
 )
 
 // This class is used by a modify request to add or remove a combined layers
@@ -110,6 +113,25 @@ type LCOWMappedVirtualDisk struct {
 	VerityInfo       *DeviceVerityInfo `json:"VerityInfo,omitempty"`
 	EnsureFilesystem bool              `json:"EnsureFilesystem,omitempty"`
 	Filesystem       string            `json:"Filesystem,omitempty"`
+	// BlockSize is the logical block size to set on the device after it is
+	// attached, e.g. 4096 to emulate a 4K-native disk. Zero keeps the default.
+	BlockSize uint32 `json:"BlockSize,omitempty"`
+	// FormatOnAttach formats a blank device with FormatFilesystem (ext4 if
+	// unset) before it is mounted. The guest refuses to format a device that
+	// already has data on it, unless ForceFormat is also set.
+	FormatOnAttach   bool   `json:"FormatOnAttach,omitempty"`
+	FormatFilesystem string `json:"FormatFilesystem,omitempty"`
+	ForceFormat      bool   `json:"ForceFormat,omitempty"`
+	// InitializeDisk has the guest create a GPT with a single partition
+	// spanning a blank device, which must be mounted as Partition 1, and format
+	// the partition as with FormatOnAttach. The guest refuses to initialize a
+	// device with a partition table or filesystem, unless ForceFormat is set.
+	// A device initialized by an earlier mount is mounted with its data.
+	InitializeDisk bool `json:"InitializeDisk,omitempty"`
+	// RepairFilesystem has the guest run fsck on a device whose filesystem is
+	// corrupt, and mount it again. A filesystem that still cannot be mounted
+	// fails the request with ERROR_DISK_CORRUPT.
+	RepairFilesystem bool `json:"RepairFilesystem,omitempty"`
 }
 
 type BlockCIMDevice struct {
@@ -179,8 +201,21 @@ type LCOWMappedVPMemDevice struct {
 
 type LCOWMappedVPCIDevice struct {
 	VMBusGUID string `json:"VMBusGUID,omitempty"`
+	// Functions are the PCI function numbers of a multi-function device to wait
+	// for. If empty, the device is assumed to have a single function.
+	Functions []uint `json:"Functions,omitempty"`
 }
 
+// MinNetworkMTU is the smallest MTU an IPv4 link must support (RFC 791), and the
+// smallest LCOWNetworkAdapter.MTU the guest accepts.
+const MinNetworkMTU = 68
+
+// MinNetworkBandwidth is the smallest rate, in bits per second, that both the
+// host and the guest accept for LCOWNetworkAdapter.EgressBandwidth. It is 1
+// Mbps, the smallest non-zero LCOWNetworkAdapter.IngressBandwidthMbps and
+// LCOWNetworkAdapter.EgressBandwidthMbps.
+const MinNetworkBandwidth = 1_000_000
+
 // LCOWNetworkAdapter represents a network interface and its associated
 // configuration in a namespace.
 type LCOWNetworkAdapter struct {
@@ -199,6 +234,32 @@ type LCOWNetworkAdapter struct {
 	// EnableLowMetric is ONLY used by the guest when PolicyBasedRouting is set to
 	// indicate which endpoints should be added with a low metric (higher number).
 	EnableLowMetric bool `json:",omitempty"`
+	// VLANTag is the 802.1Q VLAN ID to tag the adapter's traffic with. If zero,
+	// traffic is not tagged.
+	VLANTag uint16 `json:",omitempty"`
+	// ClasslessStaticRoutes are static classless routes that the guest adds
+	// once the lease is acquired when the adapter is configured with DHCP (ie,
+	// when IPConfigs is empty), encoded as the payload of the classless static
+	// route DHCP option (121).
+	ClasslessStaticRoutes []byte `json:",omitempty"`
+	// MTU is the MTU to set on the adapter's interface. It must be zero or at
+	// least MinNetworkMTU. If zero, the guest falls back to reducing the
+	// interface's MTU by EncapOverhead.
+	MTU uint16 `json:",omitempty"`
+	// EgressBandwidth is the maximum rate, in bits per second, that the guest
+	// shapes the traffic sent on the adapter's interface to. It must be zero or
+	// at least MinNetworkBandwidth. If zero, traffic is not shaped in the guest.
+	EgressBandwidth uint64 `json:",omitempty"`
+	// IngressBandwidthMbps is the maximum rate, in megabits per second, that
+	// the guest shapes the traffic received on the adapter's interface to. It
+	// is only applied when the adapter is added. If zero, traffic is not shaped
+	// in the guest.
+	IngressBandwidthMbps uint32 `json:",omitempty"`
+	// EgressBandwidthMbps is the maximum rate, in megabits per second, that the
+	// guest shapes the traffic sent on the adapter's interface to. If
+	// EgressBandwidth is also set, the lower of the two is used. If zero,
+	// traffic is not shaped in the guest.
+	EgressBandwidthMbps uint32 `json:",omitempty"`
 }
 
 type LCOWIPConfig struct {
@@ -212,9 +273,52 @@ type LCOWRoute struct {
 	Metric            uint16 `json:",omitempty"`
 }
 
+// The range of LCOWContainerConstraints.IOWeight, which is that of the cgroup v2
+// io.weight.
+const (
+	MinIOWeight = 1
+	MaxIOWeight = 10000
+)
+
 type LCOWContainerConstraints struct {
 	Windows specs.WindowsResources `json:",omitempty"`
 	Linux   specs.LinuxResources   `json:",omitempty"`
+	// PinnedCPUs are the uVM CPUs to pin the container to, in the Linux cpuset
+	// list format (for example "0-3,7"). The container's cpuset memory nodes are
+	// set to the NUMA nodes of the pinned CPUs, unless Linux.CPU.Mems is set.
+	PinnedCPUs string `json:",omitempty"`
+	// IOWeight is the proportional weight, from MinIOWeight to MaxIOWeight, of
+	// the container's I/O on the devices it shares with other containers, as
+	// the cgroup v2 io.weight (100 by default). Zero leaves the weight
+	// unchanged.
+	IOWeight uint16 `json:",omitempty"`
+	// MemorySwappiness is the swappiness, from 0 to 100, of the container's
+	// memory. With cgroup v2 in the uVM, only 0, which keeps the container's
+	// memory from being swapped, is supported. Nil leaves it unchanged.
+	MemorySwappiness *uint8 `json:",omitempty"`
+}
+
+// HostAlias is an /etc/hosts entry mapping IP to Hostnames.
+type HostAlias struct {
+	IP        string   `json:"IP,omitempty"`
+	Hostnames []string `json:"Hostnames,omitempty"`
+}
+
+// LCOWHostAliases is used by a modify request to add entries to the /etc/hosts
+// file shared by the containers in a pod. The request must target the pod's
+// sandbox container.
+type LCOWHostAliases struct {
+	HostAliases []HostAlias `json:"HostAliases,omitempty"`
+}
+
+// LCOWExecNamespaceConfig is set on a process created through the UVM to run it
+// in all the Linux namespaces of the container with ContainerID. It is an alias
+// for exec'ing the process in that container: the host sends the process for the
+// container rather than the UVM, so it is subject to the container's exec policy,
+// capabilities, seccomp profile and cgroup, and the guest is never sent this
+// config.
+type LCOWExecNamespaceConfig struct {
+	ContainerID string `json:"ContainerID,omitempty"`
 }
 
 // SignalProcessOptionsLCOW is the options passed to LCOW to signal a given
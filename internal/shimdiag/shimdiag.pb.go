@@ -22,13 +22,16 @@ const (
 )
 
 type ExecProcessRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Args          []string               `protobuf:"bytes,1,rep,name=args,proto3" json:"args,omitempty"`
-	Workdir       string                 `protobuf:"bytes,2,opt,name=workdir,proto3" json:"workdir,omitempty"`
-	Terminal      bool                   `protobuf:"varint,3,opt,name=terminal,proto3" json:"terminal,omitempty"`
-	Stdin         string                 `protobuf:"bytes,4,opt,name=stdin,proto3" json:"stdin,omitempty"`
-	Stdout        string                 `protobuf:"bytes,5,opt,name=stdout,proto3" json:"stdout,omitempty"`
-	Stderr        string                 `protobuf:"bytes,6,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Args     []string               `protobuf:"bytes,1,rep,name=args,proto3" json:"args,omitempty"`
+	Workdir  string                 `protobuf:"bytes,2,opt,name=workdir,proto3" json:"workdir,omitempty"`
+	Terminal bool                   `protobuf:"varint,3,opt,name=terminal,proto3" json:"terminal,omitempty"`
+	Stdin    string                 `protobuf:"bytes,4,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Stdout   string                 `protobuf:"bytes,5,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr   string                 `protobuf:"bytes,6,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	// user is the user to run the process as in the utility VM, in the form
+	// user[:group] for Linux guests, where each is a name or numeric ID.
+	User          string `protobuf:"bytes,7,opt,name=user,proto3" json:"user,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -105,6 +108,13 @@ func (x *ExecProcessRequest) GetStderr() string {
 	return ""
 }
 
+func (x *ExecProcessRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
 type ExecProcessResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	ExitCode      int32                  `protobuf:"varint,1,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
@@ -605,18 +615,306 @@ func (x *TasksResponse) GetTasks() []*Task {
 	return nil
 }
 
+type StateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StateRequest) Reset() {
+	*x = StateRequest{}
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateRequest) ProtoMessage() {}
+
+func (x *StateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateRequest.ProtoReflect.Descriptor instead.
+func (*StateRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescGZIP(), []int{12}
+}
+
+type StateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// queued_operations is the number of expensive HCS operations waiting for
+	// a slot in the shim's operation limiter.
+	QueuedOperations int32 `protobuf:"varint,1,opt,name=queued_operations,json=queuedOperations,proto3" json:"queued_operations,omitempty"`
+	// active_operations is the number of expensive HCS operations that hold a
+	// slot in the shim's operation limiter.
+	ActiveOperations int32 `protobuf:"varint,2,opt,name=active_operations,json=activeOperations,proto3" json:"active_operations,omitempty"`
+	// uvm_create_ms is the time taken to create the compute system of the
+	// shim's UVM, in milliseconds.
+	UvmCreateMs int64 `protobuf:"varint,3,opt,name=uvm_create_ms,json=uvmCreateMs,proto3" json:"uvm_create_ms,omitempty"`
+	// uvm_boot_ms is the time from starting the UVM until the GCS started,
+	// which covers the firmware and kernel boot, in milliseconds.
+	UvmBootMs int64 `protobuf:"varint,4,opt,name=uvm_boot_ms,json=uvmBootMs,proto3" json:"uvm_boot_ms,omitempty"`
+	// uvm_gcs_start_ms is the time from the GCS starting until it connected
+	// to the shim, in milliseconds.
+	UvmGcsStartMs int64 `protobuf:"varint,5,opt,name=uvm_gcs_start_ms,json=uvmGcsStartMs,proto3" json:"uvm_gcs_start_ms,omitempty"`
+	// uvm_negotiate_ms is the time taken to negotiate the GCS protocol, in
+	// milliseconds.
+	UvmNegotiateMs int64 `protobuf:"varint,6,opt,name=uvm_negotiate_ms,json=uvmNegotiateMs,proto3" json:"uvm_negotiate_ms,omitempty"`
+	// uvm_first_modify_ms is the time taken by the first modify request sent
+	// to the GCS, in milliseconds.
+	UvmFirstModifyMs int64 `protobuf:"varint,7,opt,name=uvm_first_modify_ms,json=uvmFirstModifyMs,proto3" json:"uvm_first_modify_ms,omitempty"`
+	// uvm_stdio_ports_in_use is the number of vsock ports in use by the stdio
+	// relays of processes in the shim's UVM.
+	UvmStdioPortsInUse int32 `protobuf:"varint,8,opt,name=uvm_stdio_ports_in_use,json=uvmStdioPortsInUse,proto3" json:"uvm_stdio_ports_in_use,omitempty"`
+	// uvm_stdio_ports_allocated is the number of distinct vsock ports
+	// allocated for stdio relays in the shim's UVM. Released ports are reused,
+	// so it only grows if more ports are in use at once than ever before.
+	UvmStdioPortsAllocated int32 `protobuf:"varint,9,opt,name=uvm_stdio_ports_allocated,json=uvmStdioPortsAllocated,proto3" json:"uvm_stdio_ports_allocated,omitempty"`
+	// uvm_scsi_pools is the allocation state of each pool of SCSI controllers
+	// of the shim's UVM, such as "data: controllers [1 2 3], 2/192 slots in
+	// use".
+	UvmScsiPools []string `protobuf:"bytes,10,rep,name=uvm_scsi_pools,json=uvmScsiPools,proto3" json:"uvm_scsi_pools,omitempty"`
+	// pods are the resources of the UVM attributed to each pod of the shim's
+	// UVM sharing group, such as "pod-a: 3 tasks, 1024 MB and 2 processors
+	// admitted, 2 SCSI attachments". Empty if the UVM is not shared.
+	Pods          []string `protobuf:"bytes,11,rep,name=pods,proto3" json:"pods,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StateResponse) Reset() {
+	*x = StateResponse{}
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateResponse) ProtoMessage() {}
+
+func (x *StateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateResponse.ProtoReflect.Descriptor instead.
+func (*StateResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *StateResponse) GetQueuedOperations() int32 {
+	if x != nil {
+		return x.QueuedOperations
+	}
+	return 0
+}
+
+func (x *StateResponse) GetActiveOperations() int32 {
+	if x != nil {
+		return x.ActiveOperations
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmCreateMs() int64 {
+	if x != nil {
+		return x.UvmCreateMs
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmBootMs() int64 {
+	if x != nil {
+		return x.UvmBootMs
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmGcsStartMs() int64 {
+	if x != nil {
+		return x.UvmGcsStartMs
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmNegotiateMs() int64 {
+	if x != nil {
+		return x.UvmNegotiateMs
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmFirstModifyMs() int64 {
+	if x != nil {
+		return x.UvmFirstModifyMs
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmStdioPortsInUse() int32 {
+	if x != nil {
+		return x.UvmStdioPortsInUse
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmStdioPortsAllocated() int32 {
+	if x != nil {
+		return x.UvmStdioPortsAllocated
+	}
+	return 0
+}
+
+func (x *StateResponse) GetUvmScsiPools() []string {
+	if x != nil {
+		return x.UvmScsiPools
+	}
+	return nil
+}
+
+func (x *StateResponse) GetPods() []string {
+	if x != nil {
+		return x.Pods
+	}
+	return nil
+}
+
+type SnapshotRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is the task whose scratch layer is snapshotted. If empty the init
+	// task of the shim is used.
+	ID string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// path is the directory to create the snapshot layer in, which must not
+	// already exist. If empty a new directory is created in the shim's
+	// temporary directory.
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *SnapshotRequest) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *SnapshotRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type SnapshotResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// path is the directory containing the snapshot layer.
+	Path          string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotResponse) Reset() {
+	*x = SnapshotResponse{}
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotResponse) ProtoMessage() {}
+
+func (x *SnapshotResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SnapshotResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
 var File_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto protoreflect.FileDescriptor
 
 const file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDesc = "" +
 	"\n" +
-	"=github.com/Microsoft/hcsshim/internal/shimdiag/shimdiag.proto\x12\x19containerd.runhcs.v1.diag\"\xa4\x01\n" +
+	"=github.com/Microsoft/hcsshim/internal/shimdiag/shimdiag.proto\x12\x19containerd.runhcs.v1.diag\"\xb8\x01\n" +
 	"\x12ExecProcessRequest\x12\x12\n" +
 	"\x04args\x18\x01 \x03(\tR\x04args\x12\x18\n" +
 	"\aworkdir\x18\x02 \x01(\tR\aworkdir\x12\x1a\n" +
 	"\bterminal\x18\x03 \x01(\bR\bterminal\x12\x14\n" +
 	"\x05stdin\x18\x04 \x01(\tR\x05stdin\x12\x16\n" +
 	"\x06stdout\x18\x05 \x01(\tR\x06stdout\x12\x16\n" +
-	"\x06stderr\x18\x06 \x01(\tR\x06stderr\"2\n" +
+	"\x06stderr\x18\x06 \x01(\tR\x06stderr\x12\x12\n" +
+	"\x04user\x18\a \x01(\tR\x04user\"2\n" +
 	"\x13ExecProcessResponse\x12\x1b\n" +
 	"\texit_code\x18\x01 \x01(\x05R\bexitCode\"\x0f\n" +
 	"\rStacksRequest\"K\n" +
@@ -641,14 +939,35 @@ const file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDesc
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
 	"\x05state\x18\x02 \x01(\tR\x05state\"F\n" +
 	"\rTasksResponse\x125\n" +
-	"\x05tasks\x18\x01 \x03(\v2\x1f.containerd.runhcs.v1.diag.TaskR\x05tasks2\xf8\x03\n" +
+	"\x05tasks\x18\x01 \x03(\v2\x1f.containerd.runhcs.v1.diag.TaskR\x05tasks\"\x0e\n" +
+	"\fStateRequest\"\xd8\x03\n" +
+	"\rStateResponse\x12+\n" +
+	"\x11queued_operations\x18\x01 \x01(\x05R\x10queuedOperations\x12+\n" +
+	"\x11active_operations\x18\x02 \x01(\x05R\x10activeOperations\x12\"\n" +
+	"\ruvm_create_ms\x18\x03 \x01(\x03R\vuvmCreateMs\x12\x1e\n" +
+	"\vuvm_boot_ms\x18\x04 \x01(\x03R\tuvmBootMs\x12'\n" +
+	"\x10uvm_gcs_start_ms\x18\x05 \x01(\x03R\ruvmGcsStartMs\x12(\n" +
+	"\x10uvm_negotiate_ms\x18\x06 \x01(\x03R\x0euvmNegotiateMs\x12-\n" +
+	"\x13uvm_first_modify_ms\x18\a \x01(\x03R\x10uvmFirstModifyMs\x122\n" +
+	"\x16uvm_stdio_ports_in_use\x18\b \x01(\x05R\x12uvmStdioPortsInUse\x129\n" +
+	"\x19uvm_stdio_ports_allocated\x18\t \x01(\x05R\x16uvmStdioPortsAllocated\x12$\n" +
+	"\x0euvm_scsi_pools\x18\n" +
+	" \x03(\tR\fuvmScsiPools\x12\x12\n" +
+	"\x04pods\x18\v \x03(\tR\x04pods\"5\n" +
+	"\x0fSnapshotRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"&\n" +
+	"\x10SnapshotResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path2\xc1\x05\n" +
 	"\bShimDiag\x12o\n" +
 	"\x0eDiagExecInHost\x12-.containerd.runhcs.v1.diag.ExecProcessRequest\x1a..containerd.runhcs.v1.diag.ExecProcessResponse\x12a\n" +
 	"\n" +
 	"DiagStacks\x12(.containerd.runhcs.v1.diag.StacksRequest\x1a).containerd.runhcs.v1.diag.StacksResponse\x12^\n" +
 	"\tDiagTasks\x12'.containerd.runhcs.v1.diag.TasksRequest\x1a(.containerd.runhcs.v1.diag.TasksResponse\x12^\n" +
 	"\tDiagShare\x12'.containerd.runhcs.v1.diag.ShareRequest\x1a(.containerd.runhcs.v1.diag.ShareResponse\x12X\n" +
-	"\aDiagPid\x12%.containerd.runhcs.v1.diag.PidRequest\x1a&.containerd.runhcs.v1.diag.PidResponseB9Z7github.com/Microsoft/hcsshim/internal/shimdiag;shimdiagb\x06proto3"
+	"\aDiagPid\x12%.containerd.runhcs.v1.diag.PidRequest\x1a&.containerd.runhcs.v1.diag.PidResponse\x12^\n" +
+	"\tDiagState\x12'.containerd.runhcs.v1.diag.StateRequest\x1a(.containerd.runhcs.v1.diag.StateResponse\x12g\n" +
+	"\fDiagSnapshot\x12*.containerd.runhcs.v1.diag.SnapshotRequest\x1a+.containerd.runhcs.v1.diag.SnapshotResponseB9Z7github.com/Microsoft/hcsshim/internal/shimdiag;shimdiagb\x06proto3"
 
 var (
 	file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescOnce sync.Once
@@ -662,7 +981,7 @@ func file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescG
 	return file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDescData
 }
 
-var file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_goTypes = []any{
 	(*ExecProcessRequest)(nil),  // 0: containerd.runhcs.v1.diag.ExecProcessRequest
 	(*ExecProcessResponse)(nil), // 1: containerd.runhcs.v1.diag.ExecProcessResponse
@@ -676,6 +995,10 @@ var file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_goTypes =
 	(*Task)(nil),                // 9: containerd.runhcs.v1.diag.Task
 	(*Exec)(nil),                // 10: containerd.runhcs.v1.diag.Exec
 	(*TasksResponse)(nil),       // 11: containerd.runhcs.v1.diag.TasksResponse
+	(*StateRequest)(nil),        // 12: containerd.runhcs.v1.diag.StateRequest
+	(*StateResponse)(nil),       // 13: containerd.runhcs.v1.diag.StateResponse
+	(*SnapshotRequest)(nil),     // 14: containerd.runhcs.v1.diag.SnapshotRequest
+	(*SnapshotResponse)(nil),    // 15: containerd.runhcs.v1.diag.SnapshotResponse
 }
 var file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_depIdxs = []int32{
 	10, // 0: containerd.runhcs.v1.diag.Task.execs:type_name -> containerd.runhcs.v1.diag.Exec
@@ -685,13 +1008,17 @@ var file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_depIdxs =
 	8,  // 4: containerd.runhcs.v1.diag.ShimDiag.DiagTasks:input_type -> containerd.runhcs.v1.diag.TasksRequest
 	4,  // 5: containerd.runhcs.v1.diag.ShimDiag.DiagShare:input_type -> containerd.runhcs.v1.diag.ShareRequest
 	6,  // 6: containerd.runhcs.v1.diag.ShimDiag.DiagPid:input_type -> containerd.runhcs.v1.diag.PidRequest
-	1,  // 7: containerd.runhcs.v1.diag.ShimDiag.DiagExecInHost:output_type -> containerd.runhcs.v1.diag.ExecProcessResponse
-	3,  // 8: containerd.runhcs.v1.diag.ShimDiag.DiagStacks:output_type -> containerd.runhcs.v1.diag.StacksResponse
-	11, // 9: containerd.runhcs.v1.diag.ShimDiag.DiagTasks:output_type -> containerd.runhcs.v1.diag.TasksResponse
-	5,  // 10: containerd.runhcs.v1.diag.ShimDiag.DiagShare:output_type -> containerd.runhcs.v1.diag.ShareResponse
-	7,  // 11: containerd.runhcs.v1.diag.ShimDiag.DiagPid:output_type -> containerd.runhcs.v1.diag.PidResponse
-	7,  // [7:12] is the sub-list for method output_type
-	2,  // [2:7] is the sub-list for method input_type
+	12, // 7: containerd.runhcs.v1.diag.ShimDiag.DiagState:input_type -> containerd.runhcs.v1.diag.StateRequest
+	14, // 8: containerd.runhcs.v1.diag.ShimDiag.DiagSnapshot:input_type -> containerd.runhcs.v1.diag.SnapshotRequest
+	1,  // 9: containerd.runhcs.v1.diag.ShimDiag.DiagExecInHost:output_type -> containerd.runhcs.v1.diag.ExecProcessResponse
+	3,  // 10: containerd.runhcs.v1.diag.ShimDiag.DiagStacks:output_type -> containerd.runhcs.v1.diag.StacksResponse
+	11, // 11: containerd.runhcs.v1.diag.ShimDiag.DiagTasks:output_type -> containerd.runhcs.v1.diag.TasksResponse
+	5,  // 12: containerd.runhcs.v1.diag.ShimDiag.DiagShare:output_type -> containerd.runhcs.v1.diag.ShareResponse
+	7,  // 13: containerd.runhcs.v1.diag.ShimDiag.DiagPid:output_type -> containerd.runhcs.v1.diag.PidResponse
+	13, // 14: containerd.runhcs.v1.diag.ShimDiag.DiagState:output_type -> containerd.runhcs.v1.diag.StateResponse
+	15, // 15: containerd.runhcs.v1.diag.ShimDiag.DiagSnapshot:output_type -> containerd.runhcs.v1.diag.SnapshotResponse
+	9,  // [9:16] is the sub-list for method output_type
+	2,  // [2:9] is the sub-list for method input_type
 	2,  // [2:2] is the sub-list for extension type_name
 	2,  // [2:2] is the sub-list for extension extendee
 	0,  // [0:2] is the sub-list for field type_name
@@ -708,7 +1035,7 @@ func file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDesc), len(file_github_com_Microsoft_hcsshim_internal_shimdiag_shimdiag_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -13,6 +13,8 @@ type ShimDiagService interface {
 	DiagTasks(context.Context, *TasksRequest) (*TasksResponse, error)
 	DiagShare(context.Context, *ShareRequest) (*ShareResponse, error)
 	DiagPid(context.Context, *PidRequest) (*PidResponse, error)
+	DiagState(context.Context, *StateRequest) (*StateResponse, error)
+	DiagSnapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error)
 }
 
 func RegisterShimDiagService(srv *ttrpc.Server, svc ShimDiagService) {
@@ -53,6 +55,20 @@ func RegisterShimDiagService(srv *ttrpc.Server, svc ShimDiagService) {
 				}
 				return svc.DiagPid(ctx, &req)
 			},
+			"DiagState": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req StateRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.DiagState(ctx, &req)
+			},
+			"DiagSnapshot": func(ctx context.Context, unmarshal func(interface{}) error) (interface{}, error) {
+				var req SnapshotRequest
+				if err := unmarshal(&req); err != nil {
+					return nil, err
+				}
+				return svc.DiagSnapshot(ctx, &req)
+			},
 		},
 	})
 }
@@ -106,3 +122,19 @@ func (c *shimdiagClient) DiagPid(ctx context.Context, req *PidRequest) (*PidResp
 	}
 	return &resp, nil
 }
+
+func (c *shimdiagClient) DiagState(ctx context.Context, req *StateRequest) (*StateResponse, error) {
+	var resp StateResponse
+	if err := c.client.Call(ctx, "containerd.runhcs.v1.diag.ShimDiag", "DiagState", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *shimdiagClient) DiagSnapshot(ctx context.Context, req *SnapshotRequest) (*SnapshotResponse, error) {
+	var resp SnapshotResponse
+	if err := c.client.Call(ctx, "containerd.runhcs.v1.diag.ShimDiag", "DiagSnapshot", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,171 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+)
+
+// ErrInsufficientResources is returned by [UtilityVM.AdmitContainer] if a
+// container requests more memory or processors than the UVM has left for it.
+var ErrInsufficientResources = errors.New("insufficient utility VM resources")
+
+// The memory of a UVM kept for the guest OS when admitting containers, if
+// [Options.GuestReservedMemoryInMB] is not set. They cover the kernel, the GCS
+// and the per-container processes of an LCOW guest, and the services of a WCOW
+// guest, with no containers running; set the option to tune them for an image.
+const (
+	DefaultLCOWGuestReservedMemoryInMB uint64 = 128
+	DefaultWCOWGuestReservedMemoryInMB uint64 = 512
+)
+
+// ContainerResources are the memory and processors that a container requests
+// from the UVM it runs in. Zero values request nothing.
+type ContainerResources struct {
+	MemoryInMB     uint64
+	ProcessorCount int32
+}
+
+// ContainerAdmission is a container admitted by [UtilityVM.AdmitContainer],
+// whose resources are accounted for until it is released.
+type ContainerAdmission struct {
+	uvm *UtilityVM
+	id  string
+}
+
+// Release stops accounting for the resources of the container.
+func (a *ContainerAdmission) Release(context.Context) error {
+	a.uvm.admissionMu.Lock()
+	defer a.uvm.admissionMu.Unlock()
+	delete(a.uvm.admittedContainers, a.id)
+	return nil
+}
+
+// AdmitsContainers returns if the UVM was created with
+// [Options.AdmitContainers], so that containers must be admitted with
+// [UtilityVM.AdmitContainer] before they are created in it.
+func (uvm *UtilityVM) AdmitsContainers() bool {
+	return uvm.admitContainers
+}
+
+// AdmitContainer checks that the UVM can fit the container `id` requesting
+// `res`, alongside the containers already admitted. A container cannot request
+// more processors than the UVM has, and the memory requested by all admitted
+// containers cannot exceed the UVM's memory less the memory reserved for the
+// guest OS.
+//
+// If the memory would be exceeded and the UVM was created with
+// [Options.GrowToFitContainers], the UVM's memory is grown to fit the container.
+// Processors cannot be added to a running UVM, so that is never done for them.
+// Otherwise, the returned error wraps [ErrInsufficientResources].
+//
+// The resources are accounted for until the returned admission is released.
+func (uvm *UtilityVM) AdmitContainer(ctx context.Context, id string, res ContainerResources) (*ContainerAdmission, error) {
+	uvm.admissionMu.Lock()
+	defer uvm.admissionMu.Unlock()
+
+	if err := uvm.admit(ctx, id, res); err != nil {
+		return nil, err
+	}
+	return &ContainerAdmission{uvm: uvm, id: id}, nil
+}
+
+// UpdateContainerAdmission checks that the UVM can fit the container `id` once
+// its resources are updated with the non-zero fields of `update`, in the same
+// way as [UtilityVM.AdmitContainer], and accounts for the updated resources.
+// It returns a function that restores the resources the container was admitted
+// with before, if the update is not applied. Containers that were not admitted
+// are ignored.
+func (uvm *UtilityVM) UpdateContainerAdmission(ctx context.Context, id string, update ContainerResources) (restore func(), err error) {
+	uvm.admissionMu.Lock()
+	defer uvm.admissionMu.Unlock()
+
+	prev, ok := uvm.admittedContainers[id]
+	if !ok {
+		return func() {}, nil
+	}
+	res := prev
+	if update.MemoryInMB != 0 {
+		res.MemoryInMB = update.MemoryInMB
+	}
+	if update.ProcessorCount != 0 {
+		res.ProcessorCount = update.ProcessorCount
+	}
+	if err := uvm.admit(ctx, id, res); err != nil {
+		return nil, err
+	}
+	return func() {
+		uvm.admissionMu.Lock()
+		defer uvm.admissionMu.Unlock()
+		if _, ok := uvm.admittedContainers[id]; ok {
+			uvm.admittedContainers[id] = prev
+		}
+	}, nil
+}
+
+// admit accounts for the container `id` requesting `res` if it fits in the UVM.
+//
+// Must be called with admissionMu held.
+func (uvm *UtilityVM) admit(ctx context.Context, id string, res ContainerResources) error {
+	if res.ProcessorCount > uvm.processorCount {
+		return fmt.Errorf("container %s requests %d processors, but utility VM %s has %d: %w",
+			id, res.ProcessorCount, uvm.id, uvm.processorCount, ErrInsufficientResources)
+	}
+
+	var admitted uint64
+	for cid, r := range uvm.admittedContainers {
+		if cid != id {
+			admitted += r.MemoryInMB
+		}
+	}
+	if need := uvm.guestReservedMemoryInMB + admitted + res.MemoryInMB; res.MemoryInMB > 0 && need > uvm.memorySizeInMB {
+		if !uvm.growToFitContainers {
+			return fmt.Errorf("container %s requests %d MB of memory, but utility VM %s has %d MB with %d MB reserved for the guest and %d MB requested by other containers: %w",
+				id, res.MemoryInMB, uvm.id, uvm.memorySizeInMB, uvm.guestReservedMemoryInMB, admitted, ErrInsufficientResources)
+		}
+		// report the host memory the grown UVM is estimated to use
+		e := uvm.memoryEstimate.withGuestMemory(alignMemorySizeInMB(need))
+		log.G(ctx).WithFields(logrus.Fields{
+			logfields.UVMID:          uvm.id,
+			logfields.ContainerID:    id,
+			"oldMemorySizeInMB":      uvm.memorySizeInMB,
+			"newMemorySizeInMB":      need,
+			"estimatedCommitInMB":    e.CommitInMB(),
+			"estimatedMaxCommitInMB": e.MaxCommitInMB(),
+			"estimatedOverheadInMB":  e.OverheadInMB,
+		}).Info("growing utility VM memory to fit container")
+		if err := uvm.updateMemory(ctx, need); err != nil {
+			return fmt.Errorf("failed to grow utility VM %s memory to %d MB for container %s: %w", uvm.id, need, id, err)
+		}
+	}
+
+	if uvm.admittedContainers == nil {
+		uvm.admittedContainers = make(map[string]ContainerResources)
+	}
+	uvm.admittedContainers[id] = res
+	return nil
+}
+
+// guestReservedMemoryInMB returns `reserved`, or `def` if it is not set.
+func guestReservedMemoryInMB(reserved, def uint64) uint64 {
+	if reserved == 0 {
+		return def
+	}
+	return reserved
+}
+
+// AdmittedContainers returns the resources of the containers admitted to the
+// UVM, by container ID.
+func (uvm *UtilityVM) AdmittedContainers() map[string]ContainerResources {
+	uvm.admissionMu.Lock()
+	defer uvm.admissionMu.Unlock()
+	return maps.Clone(uvm.admittedContainers)
+}
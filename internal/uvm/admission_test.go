@@ -0,0 +1,112 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAdmitContainer(t *testing.T) {
+	ctx := context.Background()
+	vm := &UtilityVM{
+		id:             "uvm",
+		processorCount: 2,
+		memorySizeInMB: 1024,
+	}
+
+	a1, err := vm.AdmitContainer(ctx, "c1", ContainerResources{MemoryInMB: 512, ProcessorCount: 2})
+	if err != nil {
+		t.Fatalf("failed to admit container: %v", err)
+	}
+	if _, err := vm.AdmitContainer(ctx, "c2", ContainerResources{ProcessorCount: 4}); !errors.Is(err, ErrInsufficientResources) {
+		t.Fatalf("expected %v for too many processors, got: %v", ErrInsufficientResources, err)
+	}
+	// the memory of c1 is accounted for
+	if _, err := vm.AdmitContainer(ctx, "c2", ContainerResources{MemoryInMB: 768}); !errors.Is(err, ErrInsufficientResources) {
+		t.Fatalf("expected %v for too much memory, got: %v", ErrInsufficientResources, err)
+	}
+	if _, err := vm.AdmitContainer(ctx, "c2", ContainerResources{MemoryInMB: 512}); err != nil {
+		t.Fatalf("failed to admit container fitting in the remaining memory: %v", err)
+	}
+	// containers that request no memory always fit
+	if _, err := vm.AdmitContainer(ctx, "c3", ContainerResources{}); err != nil {
+		t.Fatalf("failed to admit container without requests: %v", err)
+	}
+
+	if got := vm.AdmittedContainers(); len(got) != 3 || got["c1"].MemoryInMB != 512 || got["c2"].MemoryInMB != 512 {
+		t.Fatalf("unexpected admitted containers: %v", got)
+	}
+
+	if err := a1.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vm.AdmittedContainers()["c1"]; ok {
+		t.Fatal("released container c1 is still admitted")
+	}
+	if _, err := vm.AdmitContainer(ctx, "c4", ContainerResources{MemoryInMB: 512}); err != nil {
+		t.Fatalf("failed to admit container after another was released: %v", err)
+	}
+}
+
+func TestAdmitContainerGuestReserved(t *testing.T) {
+	ctx := context.Background()
+	vm := &UtilityVM{
+		id:                      "uvm",
+		processorCount:          2,
+		memorySizeInMB:          1024,
+		guestReservedMemoryInMB: 256,
+	}
+
+	if _, err := vm.AdmitContainer(ctx, "c1", ContainerResources{MemoryInMB: 1024}); !errors.Is(err, ErrInsufficientResources) {
+		t.Fatalf("expected %v for memory reserved for the guest, got: %v", ErrInsufficientResources, err)
+	}
+	if _, err := vm.AdmitContainer(ctx, "c1", ContainerResources{MemoryInMB: 768}); err != nil {
+		t.Fatalf("failed to admit container fitting beside the guest: %v", err)
+	}
+}
+
+func TestUpdateContainerAdmission(t *testing.T) {
+	ctx := context.Background()
+	vm := &UtilityVM{
+		id:             "uvm",
+		processorCount: 2,
+		memorySizeInMB: 1024,
+	}
+
+	if _, err := vm.AdmitContainer(ctx, "c1", ContainerResources{MemoryInMB: 256, ProcessorCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.AdmitContainer(ctx, "c2", ContainerResources{MemoryInMB: 512}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.UpdateContainerAdmission(ctx, "c1", ContainerResources{MemoryInMB: 768}); !errors.Is(err, ErrInsufficientResources) {
+		t.Fatalf("expected %v for an update that does not fit, got: %v", ErrInsufficientResources, err)
+	}
+	if got := vm.AdmittedContainers()["c1"]; got.MemoryInMB != 256 {
+		t.Fatalf("a failed update changed the admitted resources to %+v", got)
+	}
+
+	// only the updated resources change
+	restore, err := vm.UpdateContainerAdmission(ctx, "c1", ContainerResources{MemoryInMB: 512})
+	if err != nil {
+		t.Fatalf("failed to update container fitting in the remaining memory: %v", err)
+	}
+	if got := vm.AdmittedContainers()["c1"]; got != (ContainerResources{MemoryInMB: 512, ProcessorCount: 1}) {
+		t.Fatalf("unexpected updated resources %+v", got)
+	}
+	restore()
+	if got := vm.AdmittedContainers()["c1"]; got != (ContainerResources{MemoryInMB: 256, ProcessorCount: 1}) {
+		t.Fatalf("unexpected restored resources %+v", got)
+	}
+
+	// containers that were not admitted are ignored
+	if _, err := vm.UpdateContainerAdmission(ctx, "c3", ContainerResources{MemoryInMB: 4096}); err != nil {
+		t.Fatalf("failed to update container that was not admitted: %v", err)
+	}
+	if _, ok := vm.AdmittedContainers()["c3"]; ok {
+		t.Fatal("updating a container admitted it")
+	}
+}
@@ -0,0 +1,101 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+)
+
+// BootTimes is the time taken by each phase of creating and booting a utility
+// VM, used to diagnose slow starts.
+type BootTimes struct {
+	// Create is the time taken to create the compute system.
+	Create time.Duration
+	// Boot is the time from starting the compute system until the GCS started,
+	// which covers the firmware and kernel boot. If the GCS does not report
+	// when it started, it is the time until the GCS connected to the host.
+	Boot time.Duration
+	// GCSStart is the time from the GCS starting until it connected to the
+	// host. Zero if the GCS does not report when it started.
+	GCSStart time.Duration
+	// Negotiate is the time taken to negotiate the GCS protocol.
+	Negotiate time.Duration
+	// FirstModify is the time taken by the first modify request sent to the
+	// GCS. Zero until it completes.
+	FirstModify time.Duration
+}
+
+// Total returns the time taken by all the phases.
+func (bt BootTimes) Total() time.Duration {
+	return bt.Create + bt.Boot + bt.GCSStart + bt.Negotiate + bt.FirstModify
+}
+
+func (bt BootTimes) fields() logrus.Fields {
+	return logrus.Fields{
+		"create-ms":       bt.Create.Milliseconds(),
+		"boot-ms":         bt.Boot.Milliseconds(),
+		"gcs-start-ms":    bt.GCSStart.Milliseconds(),
+		"negotiate-ms":    bt.Negotiate.Milliseconds(),
+		"first-modify-ms": bt.FirstModify.Milliseconds(),
+		"total-ms":        bt.Total().Milliseconds(),
+	}
+}
+
+func (bt BootTimes) attributes() []trace.Attribute {
+	return []trace.Attribute{
+		trace.Int64Attribute("boot.create-ms", bt.Create.Milliseconds()),
+		trace.Int64Attribute("boot.boot-ms", bt.Boot.Milliseconds()),
+		trace.Int64Attribute("boot.gcs-start-ms", bt.GCSStart.Milliseconds()),
+		trace.Int64Attribute("boot.negotiate-ms", bt.Negotiate.Milliseconds()),
+	}
+}
+
+// BootTimes returns the time taken by each phase of creating and booting the
+// utility VM.
+func (uvm *UtilityVM) BootTimes() BootTimes {
+	uvm.bootMu.Lock()
+	defer uvm.bootMu.Unlock()
+	return uvm.bootTimes
+}
+
+// recordStart records the phases of starting the utility VM, from starting the
+// compute system at `started` until the GCS connected at `connected` and the
+// protocol was negotiated at `negotiated`.
+func (uvm *UtilityVM) recordStart(started, connected, negotiated time.Time) BootTimes {
+	uvm.bootMu.Lock()
+	defer uvm.bootMu.Unlock()
+
+	bt := &uvm.bootTimes
+	bt.Boot = connected.Sub(started)
+	if uvm.gc != nil {
+		// ignore a start time outside of the boot, from a guest clock gone awry
+		if gcsStart := uvm.gc.GCSStartTime(); gcsStart.After(started) && gcsStart.Before(connected) {
+			bt.Boot = gcsStart.Sub(started)
+			bt.GCSStart = connected.Sub(gcsStart)
+		}
+		bt.Negotiate = negotiated.Sub(connected)
+	}
+	return *bt
+}
+
+// recordFirstModify records the time taken by the first modify request sent to
+// the GCS, and logs the time taken by every phase of booting the utility VM.
+func (uvm *UtilityVM) recordFirstModify(ctx context.Context, d time.Duration) {
+	uvm.bootMu.Lock()
+	uvm.bootTimes.FirstModify = d
+	bt := uvm.bootTimes
+	uvm.bootMu.Unlock()
+
+	uvm.logBootTimes(ctx, bt)
+}
+
+func (uvm *UtilityVM) logBootTimes(ctx context.Context, bt BootTimes) {
+	log.G(ctx).WithField(logfields.UVMID, uvm.id).WithFields(bt.fields()).Info("utility VM boot times")
+}
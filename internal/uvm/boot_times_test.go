@@ -0,0 +1,33 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBootTimes(t *testing.T) {
+	uvm := &UtilityVM{id: t.Name()}
+	uvm.bootTimes.Create = time.Second
+
+	started := time.Now()
+	bt := uvm.recordStart(started, started.Add(3*time.Second), started.Add(4*time.Second))
+	if bt.Create != time.Second {
+		t.Fatalf("expected create time of 1s, got %v", bt.Create)
+	}
+	// without a GCS connection the phases after the boot are not known
+	if bt.Boot != 3*time.Second || bt.GCSStart != 0 || bt.Negotiate != 0 {
+		t.Fatalf("unexpected boot times: %+v", bt)
+	}
+
+	uvm.recordFirstModify(context.Background(), 500*time.Millisecond)
+	bt = uvm.BootTimes()
+	if bt.FirstModify != 500*time.Millisecond {
+		t.Fatalf("expected first modify time of 500ms, got %v", bt.FirstModify)
+	}
+	if total := bt.Total(); total != 4500*time.Millisecond {
+		t.Fatalf("expected total time of 4.5s, got %v", total)
+	}
+}
@@ -21,6 +21,22 @@ func (uvm *UtilityVM) DeleteContainerStateSupported() bool {
 	return uvm.guestCaps.IsDeleteContainerStateSupported()
 }
 
+// SeccompProfilesSupported returns `true` if the guest reports that it applies
+// the seccomp profile in the spec of an LCOW container.
+func (uvm *UtilityVM) SeccompProfilesSupported() bool {
+	lc := gcs.GetLCOWCapabilities(uvm.guestCaps)
+	return lc != nil && lc.SeccompProfilesSupported
+}
+
+// StdioPorts returns the accounting of the vsock ports used to relay the stdio
+// of processes in the utility VM.
+func (uvm *UtilityVM) StdioPorts() gcs.StdioPortStats {
+	if uvm.gc == nil {
+		return gcs.StdioPortStats{}
+	}
+	return uvm.gc.StdioPorts()
+}
+
 // Capabilities returns the protocol version and the guest defined capabilities.
 // This should only be used for testing.
 func (uvm *UtilityVM) Capabilities() (uint32, gcs.GuestDefinedCapabilities) {
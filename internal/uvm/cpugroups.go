@@ -7,9 +7,12 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/Microsoft/go-winio/pkg/guid"
+
 	"github.com/Microsoft/hcsshim/internal/cpugroup"
 	"github.com/Microsoft/hcsshim/internal/hcs/resourcepaths"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 	"github.com/Microsoft/hcsshim/osversion"
 )
 
@@ -23,18 +26,33 @@ func (uvm *UtilityVM) ReleaseCPUGroup(ctx context.Context) error {
 	return nil
 }
 
-// SetCPUGroup setups up the cpugroup for the VM with the requested id
+// SetCPUGroup moves the VM to the cpugroup with the requested id, which must be
+// a GUID, whether or not the VM is already in a cpugroup.
 func (uvm *UtilityVM) SetCPUGroup(ctx context.Context, id string) error {
 	if id == "" {
 		return errors.New("must specify an ID to use when configuring a VM's cpugroup")
 	}
+	if _, err := guid.FromString(id); err != nil {
+		return fmt.Errorf("invalid cpugroup ID %q: %w", id, err)
+	}
 	return uvm.setCPUGroup(ctx, id)
 }
 
+// CPUGroupID returns the ID of the cpugroup the VM is in, or an empty string if
+// it is not in one.
+func (uvm *UtilityVM) CPUGroupID() string {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	return uvm.cpuGroupID
+}
+
 // setCPUGroup sets the VM's cpugroup
 func (uvm *UtilityVM) setCPUGroup(ctx context.Context, id string) error {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
 	req := &hcsschema.ModifySettingRequest{
 		ResourcePath: resourcepaths.CPUGroupResourcePath,
+		RequestType:  guestrequest.RequestTypeUpdate,
 		Settings: &hcsschema.CpuGroup{
 			Id: id,
 		},
@@ -42,6 +60,10 @@ func (uvm *UtilityVM) setCPUGroup(ctx context.Context, id string) error {
 	if err := uvm.modify(ctx, req); err != nil {
 		return err
 	}
+	if id == cpugroup.NullGroupID {
+		id = ""
+	}
+	uvm.cpuGroupID = id
 	return nil
 }
 
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
 	"github.com/sirupsen/logrus"
@@ -16,6 +17,7 @@ import (
 	"golang.org/x/sys/windows"
 
 	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcs"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
@@ -34,6 +36,9 @@ type Options struct {
 	// default.
 	MemorySizeInMB uint64
 
+	// LowMMIOGapInMB, HighMMIOBaseInMB and HighMMIOGapInMB set the MMIO gaps
+	// below and above 4GB that the BARs of assigned devices are mapped in. If
+	// `0` will default to platform default. See [MMIOGaps] for the limits.
 	LowMMIOGapInMB   uint64
 	HighMMIOBaseInMB uint64
 	HighMMIOGapInMB  uint64
@@ -105,9 +110,46 @@ type Options struct {
 	// The number of SCSI controllers. Defaults to 1 for WCOW and 4 for LCOW
 	SCSIControllerCount uint32
 
+	// SCSILayerControllerCount is the number of SCSI controllers, starting from controller 0,
+	// reserved for container layers and scratch disks. The remaining controllers are reserved
+	// for data disks mounted into containers. If `0`, all disks share all controllers.
+	// LCOW only.
+	SCSILayerControllerCount uint32
+
 	// DumpDirectoryPath is the path of the directory inside which all debug dumps etc are stored.
 	DumpDirectoryPath string
 
+	// GuestLogsTailLines limits the output of [UtilityVM.DumpGuestLogs] to the
+	// last N lines. If `0`, [DefaultGuestLogsTailLines] are written. At most
+	// [MaxGuestLogsTailLines] are written.
+	GuestLogsTailLines uint32
+
+	// StdioPortsWarningThreshold is the number of vsock ports in use by the
+	// stdio relays of processes past which a warning is logged. If `0`, the
+	// GCS connection's default is used.
+	StdioPortsWarningThreshold uint32
+
+	// StatisticsIntervalSeconds is the interval at which the statistics of the
+	// containers in the UVM are sampled together. If `0`, the GCS connection's
+	// default is used, and if negative, the statistics are not sampled.
+	StatisticsIntervalSeconds int32
+
+	// AdmitContainers checks the memory and processors requested by each
+	// container created in the UVM against what the UVM has left, and fails to
+	// create the containers that do not fit. See [UtilityVM.AdmitContainer].
+	AdmitContainers bool
+
+	// GuestReservedMemoryInMB is the memory of the UVM kept for the guest OS,
+	// and not available to the containers, when they are admitted. If `0`,
+	// [DefaultLCOWGuestReservedMemoryInMB] or [DefaultWCOWGuestReservedMemoryInMB]
+	// is used.
+	GuestReservedMemoryInMB uint64
+
+	// GrowToFitContainers grows the UVM's memory when a container requests
+	// more than is left for it, instead of failing to create the container.
+	// It only applies with AdmitContainers.
+	GrowToFitContainers bool
+
 	// 	AdditionalHyperVConfig are extra Hyper-V socket configurations to provide.
 	AdditionalHyperVConfig map[string]hcsschema.HvSocketServiceConfig
 
@@ -127,6 +169,33 @@ type Options struct {
 	// NumaMemoryBlocksCounts are the number of memory blocks per vNUMA node.
 	NumaMemoryBlocksCounts []uint64
 
+	// EgressBandwidthMaximum caps the bandwidth, in bits per second, of the traffic sent by each of the
+	// UVM's network endpoints, with an HNS QoS policy. If `0`, the bandwidth is not capped.
+	EgressBandwidthMaximum uint64
+
+	// EnableTPM adds a virtual TPM to the UVM. Not supported on confidential UVMs.
+	EnableTPM bool
+	// TPMStateFilePath is the name of the guest state file in TPMStateRoot that the state of
+	// the virtual TPM persists in, which is created if it does not exist. An existing file is
+	// only reused with KeepTPMState. If empty, the state is kept in memory, and lost when the
+	// UVM is closed. Requires EnableTPM and TPMStateRoot.
+	TPMStateFilePath string
+	// TPMStateRoot is the directory TPMStateFilePath is confined to, which must only be
+	// writable by the creator of the UVM, so that the files in it were all created for UVMs.
+	TPMStateRoot string
+	// KeepTPMState keeps the file at TPMStateFilePath when the UVM is closed, rather than
+	// removing it with the UVM, so that a later UVM can reuse the state.
+	KeepTPMState bool
+
+	// StateFile is the path of the file the state of the UVM is written to once it starts,
+	// and again whenever SCSI devices or VSMB shares are added or removed, so that
+	// [AttachExisting] can reattach to the UVM, such as after the shim restarts. If empty,
+	// no state is written. Only supported for WCOW.
+	//
+	// The shim does not set it, since it does not reattach to utility VMs yet, and VPCI
+	// devices and network namespaces are not restored by [AttachExisting].
+	StateFile string
+
 	EnableGraphicsConsole bool   // If true, enable a graphics console for the utility VM
 	ConsolePipe           string // The named pipe path to use for the serial console (COM1).  eg \\.\pipe\vmpipe
 }
@@ -160,6 +229,11 @@ func verifyOptions(_ context.Context, options interface{}) error {
 		if opts.SCSIControllerCount > MaxSCSIControllers {
 			return fmt.Errorf("SCSI controller count can't be more than %d", MaxSCSIControllers)
 		}
+		// The layer controller count is checked against the controller count by CreateLCOW,
+		// which can raise the controller count.
+		if opts.SCSILayerControllerCount >= MaxSCSIControllers {
+			return fmt.Errorf("SCSI layer controller count must be less than %d", MaxSCSIControllers)
+		}
 		if opts.VPMemDeviceCount > MaxVPMEMCount {
 			return fmt.Errorf("VPMem device count cannot be greater than %d", MaxVPMEMCount)
 		}
@@ -180,13 +254,46 @@ func verifyOptions(_ context.Context, options interface{}) error {
 				return errors.New("resource partition ID and CPU group ID cannot be set at the same time")
 			}
 		}
+		if opts.EgressBandwidthMaximum != 0 && opts.EgressBandwidthMaximum < MinEgressBandwidth {
+			return fmt.Errorf("EgressBandwidthMaximum can't be less than %d bits per second", MinEgressBandwidth)
+		}
+		if opts.GuestEgressShaping && opts.EgressBandwidthMaximum == 0 {
+			return errors.New("GuestEgressShaping requires EgressBandwidthMaximum")
+		}
+		if err := verifyTPMOptions(opts.Options, opts.SecurityPolicyEnabled); err != nil {
+			return err
+		}
+		if opts.StateFile != "" {
+			// the Linux GCS does not connect to the host again, so the UVM cannot be reattached to
+			return errors.New("StateFile is only supported for WCOW")
+		}
+		if opts.NestedVirtualization {
+			if opts.SecurityPolicyEnabled {
+				return errors.New("nested virtualization is not supported on confidential VMs")
+			}
+			if err := verifyNestedVirtualizationSupported(); err != nil {
+				return err
+			}
+		}
+		if err := validateMMIOGaps(opts.Options); err != nil {
+			return err
+		}
+		switch opts.GCSSeccompMode {
+		case "", "none", "kernel", "all":
+		default:
+			return fmt.Errorf("unknown GCS seccomp mode %q: must be one of \"none\", \"kernel\", or \"all\"", opts.GCSSeccompMode)
+		}
 	case *OptionsWCOW:
 		if opts.EnableDeferredCommit && !opts.AllowOvercommit {
 			return errors.New("EnableDeferredCommit is not supported on physically backed VMs")
 		}
+		// The WCOW guest only mounts disks on controller 0.
 		if opts.SCSIControllerCount != 1 {
 			return errors.New("exactly 1 SCSI controller is required for WCOW")
 		}
+		if opts.SCSILayerControllerCount != 0 {
+			return errors.New("SCSI layer controllers are not supported for WCOW")
+		}
 		if err := verifyWCOWBootFiles(opts.BootFiles); err != nil {
 			return err
 		}
@@ -201,6 +308,15 @@ func verifyOptions(_ context.Context, options interface{}) error {
 				return errors.New("resource partition ID and CPU group ID cannot be set at the same time")
 			}
 		}
+		if opts.EgressBandwidthMaximum != 0 && opts.EgressBandwidthMaximum < MinEgressBandwidth {
+			return fmt.Errorf("EgressBandwidthMaximum can't be less than %d bits per second", MinEgressBandwidth)
+		}
+		if err := verifyTPMOptions(opts.Options, opts.SecurityPolicyEnabled); err != nil {
+			return err
+		}
+		if err := validateMMIOGaps(opts.Options); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -250,10 +366,14 @@ func (uvm *UtilityVM) OS() string {
 
 func (uvm *UtilityVM) create(ctx context.Context, doc interface{}) error {
 	uvm.exitCh = make(chan struct{})
+	start := time.Now()
 	system, err := hcs.CreateComputeSystem(ctx, uvm.id, doc)
 	if err != nil {
 		return err
 	}
+	uvm.bootMu.Lock()
+	uvm.bootTimes.Create = time.Since(start)
+	uvm.bootMu.Unlock()
 	defer func() {
 		if system != nil {
 			_ = system.Terminate(ctx)
@@ -262,11 +382,13 @@ func (uvm *UtilityVM) create(ctx context.Context, doc interface{}) error {
 	}()
 
 	// Cache the VM ID of the utility VM.
-	properties, err := system.Properties(ctx)
+	runtimeID, err := system.GetRuntimeID(ctx)
 	if err != nil {
 		return err
 	}
-	uvm.runtimeID = properties.RuntimeID
+	if uvm.runtimeID, err = guid.FromString(runtimeID); err != nil {
+		return fmt.Errorf("parsing runtime ID %q: %w", runtimeID, err)
+	}
 	uvm.hcsSystem = system
 	system = nil
 
@@ -323,6 +445,12 @@ func (uvm *UtilityVM) CloseCtx(ctx context.Context) (err error) {
 		_ = uvm.WaitCtx(ctx)
 	}
 
+	if uvm.guestLogs != nil {
+		uvm.guestLogs.close()
+	}
+
+	uvm.removeTPMState(ctx)
+
 	if lopts, ok := uvm.createOpts.(*OptionsLCOW); ok && uvm.HasConfidentialPolicy() && lopts.GuestStateFile != "" {
 		vmgsFullPath := filepath.Join(lopts.BundleDirectory, lopts.GuestStateFile)
 		e := log.G(ctx).WithField("VMGS file", vmgsFullPath)
@@ -339,15 +467,19 @@ func (uvm *UtilityVM) CloseCtx(ctx context.Context) (err error) {
 	return nil
 }
 
-// CreateContainer creates a container in the utility VM.
-func (uvm *UtilityVM) CreateContainer(ctx context.Context, id string, settings interface{}) (cow.Container, error) {
+// CreateContainer creates a container in the utility VM. `opts` require the
+// utility VM to have a guest connection.
+func (uvm *UtilityVM) CreateContainer(ctx context.Context, id string, settings interface{}, opts ...gcs.CreateContainerOpt) (cow.Container, error) {
 	if uvm.gc != nil {
-		c, err := uvm.gc.CreateContainer(ctx, id, settings)
+		c, err := uvm.gc.CreateContainer(ctx, id, settings, opts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create container %s: %w", id, err)
 		}
 		return c, nil
 	}
+	if len(opts) != 0 {
+		return nil, fmt.Errorf("failed to create container %s: options require a guest connection", id)
+	}
 	doc := hcsschema.ComputeSystem{
 		HostingSystemId:                   uvm.id,
 		Owner:                             uvm.owner,
@@ -367,9 +499,20 @@ func (uvm *UtilityVM) CreateProcess(ctx context.Context, settings interface{}) (
 	if uvm.gc != nil {
 		return uvm.gc.CreateProcess(ctx, settings)
 	}
+	if uvm.DenyRootProcesses() {
+		return nil, fmt.Errorf("denying root processes without a guest connection is %w", errNotSupported)
+	}
 	return uvm.hcsSystem.CreateProcess(ctx, settings)
 }
 
+// DenyRootProcesses returns if the UVM was created with
+// [OptionsLCOW.DenyRootProcesses], so that the processes created in it, rather
+// than in a container, cannot run as root.
+func (uvm *UtilityVM) DenyRootProcesses() bool {
+	lopts, ok := uvm.createOpts.(*OptionsLCOW)
+	return ok && lopts.DenyRootProcesses
+}
+
 // IsOCI returns false, indicating the parameters to CreateProcess should not
 // include an OCI spec.
 func (*UtilityVM) IsOCI() bool {
@@ -434,7 +577,7 @@ func (uvm *UtilityVM) ProcessDumpLocation() string {
 }
 
 func (uvm *UtilityVM) normalizeMemorySize(ctx context.Context, requested uint64) uint64 {
-	actual := (requested + 1) &^ 1 // align up to an even number
+	actual := alignMemorySizeInMB(requested)
 	if requested != actual {
 		log.G(ctx).WithFields(logrus.Fields{
 			logfields.UVMID: uvm.id,
@@ -456,6 +599,12 @@ func (uvm *UtilityVM) VSMBNoDirectMap() bool {
 	return uvm.vsmbNoDirectMap
 }
 
+// VSMBConsolidateLayers returns if read-only layers in the same parent directory should be
+// added through a single VSMB share of the parent directory.
+func (uvm *UtilityVM) VSMBConsolidateLayers() bool {
+	return uvm.vsmbConsolidateLayers
+}
+
 func (uvm *UtilityVM) NoWritableFileShares() bool {
 	return uvm.noWritableFileShares
 }
@@ -119,7 +119,7 @@ type OptionsLCOW struct {
 	ExecCommandLine         string               // The command line to exec from init. Defaults to GCS
 	ForwardStdout           bool                 // Whether stdout will be forwarded from the executed program. Defaults to false
 	ForwardStderr           bool                 // Whether stderr will be forwarded from the executed program. Defaults to true
-	OutputHandlerCreator    OutputHandlerCreator `json:"-"` // Creates an [OutputHandler] that controls how output received over HVSocket from the UVM is handled. Defaults to parsing output as logrus messages
+	OutputHandlerCreator    OutputHandlerCreator `json:"-"` // Creates an [OutputHandler] that controls how output received over HVSocket from the UVM is handled. If nil, output is parsed as logrus messages, which are logged and can be subscribed to with [UtilityVM.SubscribeGuestLogs]
 	VPMemDeviceCount        uint32               // Number of VPMem devices. Defaults to `DefaultVPMEMCount`. Limit at 128. If booting UVM from VHD, device 0 is taken.
 	VPMemSizeBytes          uint64               // Size of the VPMem devices. Defaults to `DefaultVPMemSizeBytes`.
 	VPMemNoMultiMapping     bool                 // Disables LCOW layer multi mapping
@@ -127,12 +127,22 @@ type OptionsLCOW struct {
 	EnableColdDiscardHint   bool                 // Whether the HCS should use cold discard hints. Defaults to false
 	VPCIEnabled             bool                 // Whether the kernel should enable pci
 	EnableScratchEncryption bool                 // Whether the scratch should be encrypted
+	EnableScratchRepair     bool                 // Whether the guest should repair a scratch with a corrupt filesystem before mounting it
 	DisableTimeSyncService  bool                 // Disables the time synchronization service
 	HclEnabled              *bool                // Whether to enable the host compatibility layer
 	ExtraVSockPorts         []uint32             // Extra vsock ports to allow
 	AssignedDevices         []VPCIDeviceID       // AssignedDevices are devices to add on pod boot
+	AutoSizeMMIOGaps        bool                 // Whether to grow the MMIO gaps to fit the BARs of AssignedDevices
 	PolicyBasedRouting      bool                 // Whether we should use policy based routing when configuring net interfaces in guest
+	ClasslessStaticRoutes   []byte               // Static classless routes, as the payload of DHCP option 121, that the guest adds to net interfaces configured with DHCP
+	NetworkMTU              uint16               // MTU to set on the guest's net interfaces, overriding the one derived from the HNS network
+	NetworkVLANTag          uint16               // 802.1Q VLAN ID the guest tags its net interfaces' traffic with. If zero, traffic is not tagged
 	WritableOverlayDirs     bool                 // Whether init should create writable overlay mounts for /var and /etc
+	GCSSeccompMode          string               // Seccomp filter the GCS applies to itself and every process it launches: "none", "kernel", or "all". Defaults to none
+	RequireGCSSeccomp       bool                 // Fail the creation of the UVM if the GCS does not report an active seccomp filter
+	DenyRootProcesses       bool                 // Fail to create processes in the UVM, rather than in a container, that would run as root
+	GuestEgressShaping      bool                 // Also shape the traffic sent on the guest's net interfaces to EgressBandwidthMaximum
+	NestedVirtualization    bool                 // Whether to expose the host processor's virtualization extensions to the UVM
 }
 
 // defaultLCOWOSBootFilesPath returns the default path used to locate the LCOW
@@ -167,7 +177,6 @@ func NewDefaultOptionsLCOW(id, owner string) *OptionsLCOW {
 		ExecCommandLine:         fmt.Sprintf("/bin/gcs -v4 -log-format json -loglevel %s", logrus.StandardLogger().Level.String()),
 		ForwardStdout:           false,
 		ForwardStderr:           true,
-		OutputHandlerCreator:    parseLogrus,
 		VPMemDeviceCount:        DefaultVPMEMCount,
 		VPMemSizeBytes:          DefaultVPMemSizeBytes,
 		VPMemNoMultiMapping:     osversion.Get().Build < osversion.V19H1,
@@ -249,9 +258,10 @@ func fetchProcessor(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (*hc
 	uvm.processorCount = uvm.normalizeProcessorCount(ctx, opts.ProcessorCount, processorTopology)
 
 	processor := &hcsschema.VirtualMachineProcessor{
-		Count:  uint32(uvm.processorCount),
-		Limit:  uint64(opts.ProcessorLimit),
-		Weight: uint64(opts.ProcessorWeight),
+		Count:                          uint32(uvm.processorCount),
+		Limit:                          uint64(opts.ProcessorLimit),
+		Weight:                         uint64(opts.ProcessorWeight),
+		ExposeVirtualizationExtensions: opts.NestedVirtualization,
 	}
 	// We can set a cpu group for the VM at creation time in recent builds.
 	if opts.CPUGroupID != "" {
@@ -259,6 +269,7 @@ func fetchProcessor(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (*hc
 			return nil, errCPUGroupCreateNotSupported
 		}
 		processor.CpuGroup = &hcsschema.CpuGroup{Id: opts.CPUGroupID}
+		uvm.cpuGroupID = opts.CPUGroupID
 	}
 	return processor, nil
 }
@@ -398,6 +409,7 @@ func makeLCOWVMGSDoc(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (_
 
 	// Align the requested memory size.
 	memorySizeInMB := uvm.normalizeMemorySize(ctx, opts.MemorySizeInMB)
+	uvm.memorySizeInMB = memorySizeInMB
 
 	doc := &hcsschema.ComputeSystem{
 		Owner:                             uvm.owner,
@@ -606,6 +618,7 @@ func makeLCOWDoc(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (_ *hcs
 
 	// Align the requested memory size.
 	memorySizeInMB := uvm.normalizeMemorySize(ctx, opts.MemorySizeInMB)
+	uvm.memorySizeInMB = memorySizeInMB
 
 	if numa != nil {
 		if opts.AllowOvercommit {
@@ -703,6 +716,7 @@ func makeLCOWDoc(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (_ *hcs
 				VMBusGUID:            vmbusGUID.String(),
 				deviceInstanceID:     d.deviceInstanceID,
 				virtualFunctionIndex: d.virtualFunctionIndex,
+				key:                  d,
 				refCount:             1,
 			}
 			uvm.vpciDevices[d] = device
@@ -719,6 +733,10 @@ func makeLCOWDoc(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (_ *hcs
 		}
 	}
 
+	if err := uvm.addTPM(ctx, doc, opts.Options); err != nil {
+		return nil, err
+	}
+
 	if uvm.scsiControllerCount > 0 {
 		doc.VirtualMachine.Devices.Scsi = map[string]hcsschema.Scsi{}
 		for i := 0; i < int(uvm.scsiControllerCount); i++ {
@@ -865,6 +883,10 @@ func makeLCOWDoc(ctx context.Context, opts *OptionsLCOW, uvm *UtilityVM) (_ *hcs
 		opts.ExecCommandLine += " -scrub-logs"
 	}
 
+	if opts.GCSSeccompMode != "" {
+		opts.ExecCommandLine += " -seccomp " + opts.GCSSeccompMode
+	}
+
 	execCmdArgs += " " + opts.ExecCommandLine
 
 	if opts.ProcessDumpLocation != "" {
@@ -932,26 +954,34 @@ func CreateLCOW(ctx context.Context, opts *OptionsLCOW) (_ *UtilityVM, err error
 		log.G(ctx).WithField("options", log.Format(ctx, opts)).Debug("uvm::CreateLCOW options")
 	}
 
-	// We don't serialize OutputHandlerCreator so if it is missing we need to put it back to the default.
-	if opts.OutputHandlerCreator == nil {
-		opts.OutputHandlerCreator = parseLogrus
-	}
-
 	uvm := &UtilityVM{
-		id:                      opts.ID,
-		owner:                   opts.Owner,
-		operatingSystem:         "linux",
-		scsiControllerCount:     opts.SCSIControllerCount,
-		vpmemMaxCount:           opts.VPMemDeviceCount,
-		vpmemMaxSizeBytes:       opts.VPMemSizeBytes,
-		vpciDevices:             make(map[VPCIDeviceID]*VPCIDevice),
-		physicallyBacked:        !opts.AllowOvercommit,
-		devicesPhysicallyBacked: opts.FullyPhysicallyBacked,
-		createOpts:              opts,
-		vpmemMultiMapping:       !opts.VPMemNoMultiMapping,
-		encryptScratch:          opts.EnableScratchEncryption,
-		noWritableFileShares:    opts.NoWritableFileShares,
-		policyBasedRouting:      opts.PolicyBasedRouting,
+		id:                         opts.ID,
+		owner:                      opts.Owner,
+		operatingSystem:            "linux",
+		scsiControllerCount:        opts.SCSIControllerCount,
+		scsiLayerControllerCount:   opts.SCSILayerControllerCount,
+		vpmemMaxCount:              opts.VPMemDeviceCount,
+		vpmemMaxSizeBytes:          opts.VPMemSizeBytes,
+		vpciDevices:                make(map[VPCIDeviceID]*VPCIDevice),
+		physicallyBacked:           !opts.AllowOvercommit,
+		devicesPhysicallyBacked:    opts.FullyPhysicallyBacked,
+		createOpts:                 opts,
+		vpmemMultiMapping:          !opts.VPMemNoMultiMapping,
+		encryptScratch:             opts.EnableScratchEncryption,
+		repairScratch:              opts.EnableScratchRepair,
+		noWritableFileShares:       opts.NoWritableFileShares,
+		guestLogsTailLines:         opts.GuestLogsTailLines,
+		stdioPortsWarningThreshold: opts.StdioPortsWarningThreshold,
+		statisticsIntervalSeconds:  opts.StatisticsIntervalSeconds,
+		growToFitContainers:        opts.GrowToFitContainers,
+		admitContainers:            opts.AdmitContainers,
+		guestReservedMemoryInMB:    guestReservedMemoryInMB(opts.GuestReservedMemoryInMB, DefaultLCOWGuestReservedMemoryInMB),
+		policyBasedRouting:         opts.PolicyBasedRouting,
+		classlessStaticRoutes:      opts.ClasslessStaticRoutes,
+		networkMTU:                 opts.NetworkMTU,
+		networkVLANTag:             opts.NetworkVLANTag,
+		egressBandwidth:            opts.EgressBandwidthMaximum,
+		guestEgressShaping:         opts.GuestEgressShaping,
 	}
 
 	defer func() {
@@ -961,14 +991,26 @@ func CreateLCOW(ctx context.Context, opts *OptionsLCOW) (_ *UtilityVM, err error
 	}()
 
 	// vpmemMaxCount has been set to 0 which means we are going to need multiple SCSI controllers
-	// to support lots of layers.
-	if osversion.Build() >= osversion.RS5 && uvm.vpmemMaxCount == 0 {
+	// to support lots of layers, unless a controller count other than the default was requested.
+	if osversion.Build() >= osversion.RS5 && uvm.vpmemMaxCount == 0 && uvm.scsiControllerCount == 1 {
 		uvm.scsiControllerCount = 4
 	}
+	if uvm.scsiLayerControllerCount != 0 && uvm.scsiLayerControllerCount >= uvm.scsiControllerCount {
+		return nil, fmt.Errorf("SCSI layer controller count must be less than the SCSI controller count (%d): %w",
+			uvm.scsiControllerCount, errBadUVMOpts)
+	}
+
+	if opts.AutoSizeMMIOGaps {
+		if err = autoSizeMMIOGaps(ctx, opts); err != nil {
+			return nil, errors.Wrap(err, errBadUVMOpts.Error())
+		}
+	}
 
 	if err = verifyOptions(ctx, opts); err != nil {
 		return nil, errors.Wrap(err, errBadUVMOpts.Error())
 	}
+	uvm.mmioGaps = mmioGapsOf(opts.Options)
+	addMMIOGapsToSpan(span, uvm.mmioGaps)
 
 	// HCS config for SNP isolated vm is quite different to the usual case
 	var doc *hcsschema.ComputeSystem
@@ -993,6 +1035,9 @@ func CreateLCOW(ctx context.Context, opts *OptionsLCOW) (_ *UtilityVM, err error
 		return nil, err
 	}
 
+	uvm.memoryEstimate = estimateVirtualMachineMemory(doc.VirtualMachine)
+	logMemoryEstimate(ctx, span, uvm.id, uvm.memoryEstimate)
+
 	if err = uvm.create(ctx, doc); err != nil {
 		return nil, fmt.Errorf("error while creating the compute system: %w", err)
 	}
@@ -1009,7 +1054,7 @@ func CreateLCOW(ctx context.Context, opts *OptionsLCOW) (_ *UtilityVM, err error
 	// Create a socket that the executed program can send to. This is usually
 	// used by GCS to send log data.
 	if opts.ForwardStdout || opts.ForwardStderr {
-		uvm.outputHandler = opts.OutputHandlerCreator(opts.Options)
+		uvm.outputHandler = uvm.newOutputHandler(opts.Options, opts.OutputHandlerCreator)
 		uvm.outputProcessingDone = make(chan struct{})
 		uvm.outputListener, err = uvm.listenVsock(linuxLogVsockPort)
 		if err != nil {
@@ -4,6 +4,9 @@ package uvm
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -19,3 +22,86 @@ func TestCreateBadBootFilesPath(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestVerifyOptionsGCSSeccompMode(t *testing.T) {
+	ctx := context.Background()
+	lopts := NewDefaultOptionsLCOW(t.Name(), "")
+	for _, mode := range []string{"", "none", "kernel", "all"} {
+		lopts.GCSSeccompMode = mode
+		if err := verifyOptions(ctx, lopts); err != nil {
+			t.Fatalf("expected seccomp mode %q to be valid: %v", mode, err)
+		}
+	}
+
+	lopts.GCSSeccompMode = "strict"
+	if err := verifyOptions(ctx, lopts); err == nil {
+		t.Fatal("expected unknown seccomp mode to be invalid")
+	}
+}
+
+func TestVerifyOptionsTPM(t *testing.T) {
+	ctx := context.Background()
+	lopts := NewDefaultOptionsLCOW(t.Name(), "")
+	lopts.TPMStateFilePath = `C:\state\tpm.vmgs`
+	if err := verifyOptions(ctx, lopts); err == nil || err.Error() != "TPMStateFilePath requires EnableTPM" {
+		t.Fatalf("expected TPM state without TPM error, got: %v", err)
+	}
+
+	lopts.EnableTPM = true
+	lopts.TPMStateFilePath = ""
+	lopts.KeepTPMState = true
+	if err := verifyOptions(ctx, lopts); err == nil || err.Error() != "KeepTPMState requires TPMStateFilePath" {
+		t.Fatalf("expected keeping TPM state without state file error, got: %v", err)
+	}
+
+	lopts.KeepTPMState = false
+	lopts.TPMStateFilePath = "tpm.vmgs"
+	if err := verifyOptions(ctx, lopts); err == nil || err.Error() != "TPMStateFilePath requires TPMStateRoot" {
+		t.Fatalf("expected TPM state file without root error, got: %v", err)
+	}
+
+	lopts.TPMStateRoot = `C:\state`
+	for _, name := range []string{`..\tpm.vmgs`, `C:\other\tpm.vmgs`, `pod\tpm.vmgs`, "NUL"} {
+		lopts.TPMStateFilePath = name
+		if err := verifyOptions(ctx, lopts); err == nil {
+			t.Fatalf("expected TPM state file %q outside of the root to be invalid", name)
+		}
+	}
+
+	lopts.TPMStateFilePath = ""
+	lopts.SecurityPolicyEnabled = true
+	if err := verifyOptions(ctx, lopts); !errors.Is(err, ErrTPMNotSupported) {
+		t.Fatalf("expected TPM on confidential UVM to be unsupported, got: %v", err)
+	}
+}
+
+func TestTPMStateFile(t *testing.T) {
+	opts := &Options{
+		TPMStateRoot:     filepath.Join(t.TempDir(), "tpm"),
+		TPMStateFilePath: "tpm.vmgs",
+	}
+	path, err := tpmStateFile(opts)
+	if err != nil {
+		t.Fatalf("failed to create TPM state file: %v", err)
+	}
+	if want := filepath.Join(opts.TPMStateRoot, opts.TPMStateFilePath); path != want {
+		t.Fatalf("got TPM state file %s, expected %s", path, want)
+	}
+
+	if _, err := tpmStateFile(opts); err == nil {
+		t.Fatal("expected reusing a TPM state file that is not kept to fail")
+	}
+	opts.KeepTPMState = true
+	if _, err := tpmStateFile(opts); err != nil {
+		t.Fatalf("failed to reuse kept TPM state file: %v", err)
+	}
+
+	// a kept state file must not be a link to a file outside of the root
+	opts.TPMStateFilePath = "link.vmgs"
+	if err := os.Symlink(path, filepath.Join(opts.TPMStateRoot, opts.TPMStateFilePath)); err != nil {
+		t.Skipf("could not create symbolic link: %v", err)
+	}
+	if _, err := tpmStateFile(opts); err == nil {
+		t.Fatal("expected reusing a symbolic link as the TPM state file to fail")
+	}
+}
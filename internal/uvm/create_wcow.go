@@ -68,13 +68,19 @@ type OptionsWCOW struct {
 	// NoDirectMap specifies that no direct mapping should be used for any VSMBs added to the UVM
 	NoDirectMap bool
 
+	// ConsolidateVSMBLayers specifies that read-only layers in the same parent directory
+	// should be added through a single VSMB share of the parent directory, if it holds
+	// nothing else. It is set from the shim options rather than an annotation, since the
+	// UVM can read all of the parent directory.
+	ConsolidateVSMBLayers bool
+
 	// NoInheritHostTimezone specifies whether to not inherit the hosts timezone for the UVM. UTC will be set as the default for the VM instead.
 	NoInheritHostTimezone bool
 
 	// AdditionalRegistryKeys are Registry keys and their values to additionally add to the uVM.
 	AdditionalRegistryKeys []hcsschema.RegistryValue
 
-	OutputHandlerCreator OutputHandlerCreator // Creates an [OutputHandler] that controls how output received over HVSocket from the UVM is handled. Defaults to parsing output as ETW Log events
+	OutputHandlerCreator OutputHandlerCreator // Creates an [OutputHandler] that controls how output received over HVSocket from the UVM is handled. If nil, output is parsed as ETW Log events, which are logged and can be subscribed to with [UtilityVM.SubscribeGuestLogs]
 	LogSources           string               // ETW providers to be set for the logging service
 	ForwardLogs          bool                 // Whether to forward logs to the host or not
 }
@@ -113,9 +119,8 @@ func NewDefaultOptionsWCOW(id, owner string) *OptionsWCOW {
 		ConfidentialWCOWOptions: &ConfidentialWCOWOptions{
 			SecurityPolicyEnabled: false,
 		},
-		OutputHandlerCreator: parseLogrus,
-		ForwardLogs:          true, // Default to true for WCOW, and set to false for CWCOW in internal/oci/uvm.go SpecToUVMCreateOpts
-		LogSources:           "",
+		ForwardLogs: true, // Default to true for WCOW, and set to false for CWCOW in internal/oci/uvm.go SpecToUVMCreateOpts
+		LogSources:  "",
 	}
 }
 
@@ -138,6 +143,7 @@ func (uvm *UtilityVM) startExternalGcsListener(ctx context.Context, gcsServiceID
 		return err
 	}
 	uvm.gcListener = l
+	uvm.gcsServiceID = gcsServiceID
 	return nil
 }
 
@@ -153,6 +159,7 @@ func prepareCommonConfigDoc(ctx context.Context, uvm *UtilityVM, opts *OptionsWC
 
 	// Align the requested memory size.
 	memorySizeInMB := uvm.normalizeMemorySize(ctx, opts.MemorySizeInMB)
+	uvm.memorySizeInMB = memorySizeInMB
 
 	var registryChanges hcsschema.RegistryChanges
 	// We're getting asked to setup local dump collection for WCOW. We need to:
@@ -236,6 +243,7 @@ func prepareCommonConfigDoc(ctx context.Context, uvm *UtilityVM, opts *OptionsWC
 			return nil, errCPUGroupCreateNotSupported
 		}
 		processor.CpuGroup = &hcsschema.CpuGroup{Id: opts.CPUGroupID}
+		uvm.cpuGroupID = opts.CPUGroupID
 	}
 
 	doc := &hcsschema.ComputeSystem{
@@ -509,6 +517,10 @@ func prepareConfigDoc(ctx context.Context, uvm *UtilityVM, opts *OptionsWCOW) (*
 	}
 	uvm.reservedSCSISlots = append(uvm.reservedSCSISlots, scsi.Slot{Controller: 0, LUN: 0})
 
+	if err := uvm.addTPM(ctx, doc, opts.Options); err != nil {
+		return nil, err
+	}
+
 	return doc, nil
 }
 
@@ -533,22 +545,32 @@ func CreateWCOW(ctx context.Context, opts *OptionsWCOW) (_ *UtilityVM, err error
 	log.G(ctx).WithField("options", log.Format(ctx, opts)).Debug("uvm::CreateWCOW options")
 
 	uvm := &UtilityVM{
-		id:                      opts.ID,
-		owner:                   opts.Owner,
-		operatingSystem:         "windows",
-		scsiControllerCount:     opts.SCSIControllerCount,
-		vsmbDirShares:           make(map[string]*VSMBShare),
-		vsmbFileShares:          make(map[string]*VSMBShare),
-		vpciDevices:             make(map[VPCIDeviceID]*VPCIDevice),
-		noInheritHostTimezone:   opts.NoInheritHostTimezone,
-		physicallyBacked:        !opts.AllowOvercommit,
-		devicesPhysicallyBacked: opts.FullyPhysicallyBacked,
-		vsmbNoDirectMap:         opts.NoDirectMap,
-		noWritableFileShares:    opts.NoWritableFileShares,
-		createOpts:              opts,
-		blockCIMMounts:          make(map[string]*UVMMountedBlockCIMs),
-		logSources:              opts.LogSources,
-		forwardLogs:             opts.ForwardLogs,
+		id:                         opts.ID,
+		owner:                      opts.Owner,
+		operatingSystem:            "windows",
+		scsiControllerCount:        opts.SCSIControllerCount,
+		scsiLayerControllerCount:   opts.SCSILayerControllerCount,
+		vsmbDirShares:              make(map[string]*VSMBShare),
+		vsmbFileShares:             make(map[string]*VSMBShare),
+		vpciDevices:                make(map[VPCIDeviceID]*VPCIDevice),
+		noInheritHostTimezone:      opts.NoInheritHostTimezone,
+		physicallyBacked:           !opts.AllowOvercommit,
+		devicesPhysicallyBacked:    opts.FullyPhysicallyBacked,
+		vsmbNoDirectMap:            opts.NoDirectMap,
+		vsmbConsolidateLayers:      opts.ConsolidateVSMBLayers,
+		noWritableFileShares:       opts.NoWritableFileShares,
+		guestLogsTailLines:         opts.GuestLogsTailLines,
+		stdioPortsWarningThreshold: opts.StdioPortsWarningThreshold,
+		statisticsIntervalSeconds:  opts.StatisticsIntervalSeconds,
+		growToFitContainers:        opts.GrowToFitContainers,
+		admitContainers:            opts.AdmitContainers,
+		guestReservedMemoryInMB:    guestReservedMemoryInMB(opts.GuestReservedMemoryInMB, DefaultWCOWGuestReservedMemoryInMB),
+		createOpts:                 opts,
+		blockCIMMounts:             make(map[string]*UVMMountedBlockCIMs),
+		logSources:                 opts.LogSources,
+		forwardLogs:                opts.ForwardLogs,
+		egressBandwidth:            opts.EgressBandwidthMaximum,
+		stateFile:                  opts.StateFile,
 	}
 
 	defer func() {
@@ -560,6 +582,8 @@ func CreateWCOW(ctx context.Context, opts *OptionsWCOW) (_ *UtilityVM, err error
 	if err := verifyOptions(ctx, opts); err != nil {
 		return nil, errors.Wrap(err, errBadUVMOpts.Error())
 	}
+	uvm.mmioGaps = mmioGapsOf(opts.Options)
+	addMMIOGapsToSpan(span, uvm.mmioGaps)
 
 	var doc *hcsschema.ComputeSystem
 	if opts.SecurityPolicyEnabled {
@@ -583,6 +607,9 @@ func CreateWCOW(ctx context.Context, opts *OptionsWCOW) (_ *UtilityVM, err error
 		return nil, fmt.Errorf("error in preparing config doc: %w", err)
 	}
 
+	uvm.memoryEstimate = estimateVirtualMachineMemory(doc.VirtualMachine)
+	logMemoryEstimate(ctx, span, uvm.id, uvm.memoryEstimate)
+
 	err = uvm.create(ctx, doc)
 	if err != nil {
 		return nil, fmt.Errorf("error while creating the compute system: %w", err)
@@ -591,7 +618,7 @@ func CreateWCOW(ctx context.Context, opts *OptionsWCOW) (_ *UtilityVM, err error
 	if opts.ForwardLogs {
 		// Create a socket that the executed program can send to. This is usually
 		// used by Log Forward Service to send log data.
-		uvm.outputHandler = opts.OutputHandlerCreator(opts.Options)
+		uvm.outputHandler = uvm.newOutputHandler(opts.Options, opts.OutputHandlerCreator)
 		uvm.outputProcessingDone = make(chan struct{})
 		uvm.outputListener, err = winio.ListenHvsock(&winio.HvsockAddr{
 			VMID:      uvm.RuntimeID(),
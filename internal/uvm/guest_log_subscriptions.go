@@ -0,0 +1,315 @@
+//go:build windows
+
+package uvm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+)
+
+// guestLogBufferSize is the number of entries buffered for each subscriber to
+// the guest logs, past which entries are dropped for the subscriber, or, for
+// the subscriber that logs them on the host, the guest logs are not read until
+// it catches up.
+const guestLogBufferSize = 1024
+
+// defaultGuestLogSource is the [LogEntry.Source] of the entries that do not
+// name the component that logged them.
+const defaultGuestLogSource = "gcs"
+
+// LogEntry is an entry of the logs the guest sends to the host.
+type LogEntry struct {
+	// Time is when the guest logged the entry, or zero if it is [LogEntry.Raw].
+	Time time.Time
+	// Level is the level of the entry. Panic and fatal entries are reported at
+	// error level.
+	Level logrus.Level
+	// Message is the message of the entry, or the line of output if it is
+	// [LogEntry.Raw].
+	Message string
+	// Fields are the fields of the entry other than its time, level and
+	// message. The fields are shared between subscribers, and must not be
+	// modified.
+	Fields map[string]interface{}
+	// ContainerID is the ID of the container that the entry is about, if any.
+	ContainerID string
+	// Source is the component of the guest that logged the entry: its
+	// "Source" field if set, such as "ETW" for the events a WCOW guest
+	// forwards, or else the package of the span of the entry, such as "hcsv2"
+	// for "hcsv2::Host::CreateContainer", or else "gcs".
+	Source string
+	// Raw is set for the output that follows the first line that is not a
+	// structured entry, such as the stack of a GCS panic, which is reported
+	// whole at error level.
+	Raw bool
+	// Dropped is the number of entries dropped for the subscriber before this
+	// one, because it did not receive them fast enough.
+	Dropped uint64
+}
+
+// LogFilter selects the entries of the guest logs that a subscriber receives.
+// An empty selector selects all entries.
+type LogFilter struct {
+	// Levels are the levels of the entries to receive.
+	Levels []logrus.Level
+	// ContainerIDs are the IDs of the containers to receive the entries of.
+	ContainerIDs []string
+	// Sources are the components to receive the entries of, see
+	// [LogEntry.Source].
+	Sources []string
+}
+
+func (f *LogFilter) match(e *LogEntry) bool {
+	return (len(f.Levels) == 0 || slices.Contains(f.Levels, e.Level)) &&
+		(len(f.ContainerIDs) == 0 || slices.Contains(f.ContainerIDs, e.ContainerID)) &&
+		(len(f.Sources) == 0 || slices.Contains(f.Sources, e.Source))
+}
+
+// SubscribeGuestLogs returns a channel that receives the entries of the logs
+// the guest sends to the host that match `filter`, until `ctx` is done or the
+// UVM is closed, when the channel is closed.
+//
+// Each subscriber has its own buffer of entries; the entries that do not fit
+// in the buffer of a subscriber are dropped for that subscriber only, and
+// counted in the [LogEntry.Dropped] of its next entry.
+//
+// The logs can only be subscribed to if the UVM forwards them, and they are not
+// handled by an [Options.OutputHandlerCreator].
+func (uvm *UtilityVM) SubscribeGuestLogs(ctx context.Context, filter LogFilter) (<-chan LogEntry, error) {
+	if uvm.guestLogs == nil {
+		return nil, fmt.Errorf("subscribing to guest logs that are not forwarded to the host is %w", errNotSupported)
+	}
+	s, err := uvm.guestLogs.subscribe(filter, guestLogBufferSize, false)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			uvm.guestLogs.unsubscribe(s)
+		case <-s.done:
+		}
+	}()
+	return s.ch, nil
+}
+
+// newOutputHandler returns the handler of the output the UVM sends to the host
+// created with `create`, or, if nil, one that publishes the guest logs to the
+// subscribers to them, the first of which logs them on the host. That
+// subscriber never drops entries: reading the guest logs waits for it instead,
+// as it did before the logs could be subscribed to.
+func (uvm *UtilityVM) newOutputHandler(opts *Options, create OutputHandlerCreator) OutputHandler {
+	if create != nil {
+		return create(opts)
+	}
+	uvm.guestLogs = newGuestLogBroker()
+	// the broker cannot be closed yet
+	s, _ := uvm.guestLogs.subscribe(LogFilter{}, guestLogBufferSize, true)
+	go forwardGuestLogs(uvm.id, s.ch)
+	return func(r io.Reader) {
+		readGuestLogs(uvm.id, r, uvm.guestLogs.publish)
+	}
+}
+
+// guestLogBroker publishes the entries of the guest logs to its subscribers.
+type guestLogBroker struct {
+	mu          sync.Mutex
+	subscribers map[*guestLogSubscriber]struct{}
+	closed      bool
+}
+
+// guestLogSubscriber is a subscriber to the guest logs.
+type guestLogSubscriber struct {
+	filter LogFilter
+	ch     chan LogEntry
+	// done is closed with `ch`.
+	done chan struct{}
+	// block is set if entries are never dropped for the subscriber, and
+	// publishing waits for it to receive them instead.
+	block bool
+	// dropped is the number of entries dropped since the last one sent on
+	// `ch`, and is protected by the broker's lock.
+	dropped uint64
+}
+
+func newGuestLogBroker() *guestLogBroker {
+	return &guestLogBroker{
+		subscribers: make(map[*guestLogSubscriber]struct{}),
+	}
+}
+
+// subscribe adds a subscriber for the entries that match `filter`, with a
+// buffer of `size` entries. If `block` is set, the entries are never dropped
+// for the subscriber, and [guestLogBroker.publish] waits for the subscriber to
+// receive them, so the subscriber must keep receiving until its channel is
+// closed.
+func (b *guestLogBroker) subscribe(filter LogFilter, size int, block bool) (*guestLogSubscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, errors.New("the guest logs are closed")
+	}
+	s := &guestLogSubscriber{
+		filter: filter,
+		ch:     make(chan LogEntry, size),
+		done:   make(chan struct{}),
+		block:  block,
+	}
+	b.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+// unsubscribe removes `s` and closes its channel, if it was not already.
+func (b *guestLogBroker) unsubscribe(s *guestLogSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[s]; ok {
+		b.closeLocked(s)
+	}
+}
+
+// closeLocked removes `s` and closes its channel.
+//
+// b.mu must be held.
+func (b *guestLogBroker) closeLocked(s *guestLogSubscriber) {
+	delete(b.subscribers, s)
+	if s.dropped != 0 {
+		logrus.WithField("dropped", s.dropped).Warning("guest log entries dropped for a subscriber that was closed")
+	}
+	close(s.ch)
+	close(s.done)
+}
+
+// publish sends `e` to the subscribers it matches, without waiting for the
+// subscribers whose buffer is full, other than the blocking ones.
+func (b *guestLogBroker) publish(e LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		if !s.filter.match(&e) {
+			continue
+		}
+		se := e
+		se.Dropped = s.dropped
+		if s.block {
+			s.ch <- se
+			continue
+		}
+		select {
+		case s.ch <- se:
+			s.dropped = 0
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// close closes the channels of all subscribers, and fails any later
+// subscription.
+func (b *guestLogBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		b.closeLocked(s)
+	}
+}
+
+// readGuestLogs reads the logrus entries the guest of UVM `vmid` writes as JSON
+// to `r`, one per line, and passes each to `publish`. The first line that is not
+// a JSON entry and the rest of the output are passed as a single [LogEntry.Raw]
+// entry, since they are probably a GCS panic stack.
+func readGuestLogs(vmid string, r io.Reader, publish func(LogEntry)) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) != 0 {
+			e := parseGuestLogLine(line)
+			if e.Raw && err == nil {
+				rest, _ := io.ReadAll(br)
+				e.Message = string(bytes.TrimSpace(append(append(line, '\n'), rest...)))
+				publish(e)
+				return
+			}
+			publish(e)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !isDisconnectError(err) {
+				logrus.WithFields(logrus.Fields{
+					logfields.UVMID: vmid,
+					logrus.ErrorKey: err,
+				}).Error("gcs log read")
+			}
+			return
+		}
+	}
+}
+
+// parseGuestLogLine parses `line`, a line of the guest logs.
+func parseGuestLogLine(line []byte) LogEntry {
+	ge := gcsLogEntry{Fields: make(map[string]interface{})}
+	if line[0] != '{' || json.Unmarshal(line, &ge) != nil {
+		return LogEntry{
+			Level:   logrus.ErrorLevel,
+			Message: string(line),
+			Source:  defaultGuestLogSource,
+			Raw:     true,
+		}
+	}
+	e := LogEntry{
+		Time:    ge.Time,
+		Level:   ge.Level,
+		Message: ge.Message,
+		Fields:  ge.Fields,
+		Source:  defaultGuestLogSource,
+	}
+	if cid, ok := ge.Fields[logfields.ContainerID].(string); ok {
+		e.ContainerID = cid
+	}
+	if src, ok := ge.Fields["Source"].(string); ok && src != "" {
+		e.Source = src
+	} else if name, ok := ge.Fields[logfields.Name].(string); ok {
+		if pkg, _, ok := strings.Cut(name, "::"); ok && pkg != "" {
+			e.Source = pkg
+		}
+	}
+	return e
+}
+
+// forwardGuestLogs logs the entries of the guest logs of UVM `vmid` received
+// on `entries` on the host.
+func forwardGuestLogs(vmid string, entries <-chan LogEntry) {
+	for e := range entries {
+		if e.Raw {
+			// probably a GCS panic stack
+			logrus.WithFields(logrus.Fields{
+				logfields.UVMID: vmid,
+				"stderr":        e.Message,
+			}).Error("gcs terminated")
+			continue
+		}
+		le := log.L.Dup()
+		fields := make(logrus.Fields, len(e.Fields)+2)
+		for k, v := range e.Fields {
+			fields[k] = v
+		}
+		fields[logfields.UVMID] = vmid
+		fields["vm.time"] = e.Time
+		le.Data = fields
+		le.Log(e.Level, e.Message)
+	}
+}
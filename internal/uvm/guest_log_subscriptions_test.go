@@ -0,0 +1,200 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_readGuestLogs(t *testing.T) {
+	input := strings.Join([]string{
+		`{"time":"2024-01-02T03:04:05Z","level":"info","msg":"created","cid":"c1","name":"hcsv2::Host::CreateContainer","count":2}`,
+		`{"level":"panic","msg":"oh no"}`,
+		`{"level":"warning","msg":"event","Source":"ETW","cid":"c2"}`,
+		``,
+		`panic: oh no`,
+		`goroutine 1 [running]:`,
+		`	main.main()`,
+		`{"level":"info","msg":"after the panic"}`,
+	}, "\n")
+
+	var entries []LogEntry
+	readGuestLogs(t.Name(), strings.NewReader(input), func(e LogEntry) {
+		entries = append(entries, e)
+	})
+
+	expected := []LogEntry{
+		{
+			Time:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Level:       logrus.InfoLevel,
+			Message:     "created",
+			ContainerID: "c1",
+			Source:      "hcsv2",
+		},
+		{Level: logrus.ErrorLevel, Message: "oh no", Source: "gcs"},
+		{Level: logrus.WarnLevel, Message: "event", ContainerID: "c2", Source: "ETW"},
+		{Level: logrus.ErrorLevel, Message: "panic: oh no\ngoroutine 1 [running]:\n\tmain.main()\n" + `{"level":"info","msg":"after the panic"}`, Source: "gcs", Raw: true},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, e := range entries {
+		want := expected[i]
+		if !e.Time.Equal(want.Time) || e.Level != want.Level || e.Message != want.Message ||
+			e.ContainerID != want.ContainerID || e.Source != want.Source || e.Raw != want.Raw {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want, e)
+		}
+	}
+	if c := entries[0].Fields["count"]; c != int64(2) {
+		t.Errorf("expected the fields of the entry to be parsed, got count %v", c)
+	}
+	if _, ok := entries[0].Fields["msg"]; ok {
+		t.Errorf("expected the message to be removed from the fields, got %v", entries[0].Fields)
+	}
+}
+
+func Test_guestLogBroker_Filter(t *testing.T) {
+	b := newGuestLogBroker()
+	defer b.close()
+
+	all, err := b.subscribe(LogFilter{}, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := b.subscribe(LogFilter{Levels: []logrus.Level{logrus.ErrorLevel}}, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container, err := b.subscribe(LogFilter{ContainerIDs: []string{"c1"}, Sources: []string{"hcsv2"}}, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []LogEntry{
+		{Level: logrus.InfoLevel, Message: "1", ContainerID: "c1", Source: "hcsv2"},
+		{Level: logrus.ErrorLevel, Message: "2", ContainerID: "c1", Source: "gcs"},
+		{Level: logrus.ErrorLevel, Message: "3", ContainerID: "c2", Source: "hcsv2"},
+	} {
+		b.publish(e)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		s        *guestLogSubscriber
+		expected []string
+	}{
+		{name: "All", s: all, expected: []string{"1", "2", "3"}},
+		{name: "Level", s: errs, expected: []string{"2", "3"}},
+		{name: "ContainerAndSource", s: container, expected: []string{"1"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for len(tc.s.ch) > 0 {
+				got = append(got, (<-tc.s.ch).Message)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+				t.Fatalf("expected entries %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func Test_guestLogBroker_Dropped(t *testing.T) {
+	b := newGuestLogBroker()
+	defer b.close()
+
+	slow, err := b.subscribe(LogFilter{}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := b.subscribe(LogFilter{}, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range []string{"1", "2", "3"} {
+		b.publish(LogEntry{Message: m})
+	}
+	if e := <-slow.ch; e.Message != "1" || e.Dropped != 0 {
+		t.Fatalf("expected the first entry, got %+v", e)
+	}
+	b.publish(LogEntry{Message: "4"})
+	if e := <-slow.ch; e.Message != "4" || e.Dropped != 2 {
+		t.Fatalf("expected the last entry after 2 dropped, got %+v", e)
+	}
+
+	// entries are only dropped for the slow subscriber
+	if n := len(fast.ch); n != 4 {
+		t.Fatalf("expected 4 entries for the other subscriber, got %d", n)
+	}
+	for range len(fast.ch) {
+		if e := <-fast.ch; e.Dropped != 0 {
+			t.Fatalf("expected no dropped entries for the other subscriber, got %+v", e)
+		}
+	}
+}
+
+func Test_guestLogBroker_Blocking(t *testing.T) {
+	b := newGuestLogBroker()
+	defer b.close()
+
+	forward, err := b.subscribe(LogFilter{}, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for _, m := range []string{"1", "2", "3"} {
+			b.publish(LogEntry{Message: m})
+		}
+	}()
+	for _, m := range []string{"1", "2", "3"} {
+		if e := <-forward.ch; e.Message != m || e.Dropped != 0 {
+			t.Fatalf("expected entry %q and no dropped entries, got %+v", m, e)
+		}
+	}
+	<-published
+}
+
+func Test_SubscribeGuestLogs(t *testing.T) {
+	vm := &UtilityVM{guestLogs: newGuestLogBroker()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled, err := vm.SubscribeGuestLogs(ctx, LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := vm.SubscribeGuestLogs(context.Background(), LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-canceled:
+		if ok {
+			t.Fatal("expected no entries")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the subscription to be closed with its context")
+	}
+
+	vm.guestLogs.close()
+	if _, ok := <-closed; ok {
+		t.Fatal("expected the subscription to be closed with the uVM")
+	}
+	if _, err := vm.SubscribeGuestLogs(context.Background(), LogFilter{}); err == nil {
+		t.Fatal("expected subscribing to the logs of a closed uVM to fail")
+	}
+
+	if _, err := (&UtilityVM{}).SubscribeGuestLogs(context.Background(), LogFilter{}); err == nil {
+		t.Fatal("expected subscribing to logs that are not forwarded to fail")
+	}
+}
@@ -0,0 +1,129 @@
+//go:build windows
+
+package uvm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opencensus.io/trace"
+
+	"github.com/Microsoft/hcsshim/internal/guestpath"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/oc"
+)
+
+// DefaultGuestLogsTailLines is the number of lines written by
+// [UtilityVM.DumpGuestLogs] if [Options.GuestLogsTailLines] is not set.
+const DefaultGuestLogsTailLines = 200
+
+// MaxGuestLogsTailLines is the largest [Options.GuestLogsTailLines], which bounds
+// the lines kept in memory while the guest logs are read.
+const MaxGuestLogsTailLines = 10000
+
+// DumpGuestLogs writes the guest OS logs to `w`: the kernel ring buffer for
+// LCOW, and the Application event log for WCOW.
+//
+// The logs are collected by running a process in the uVM, so the guest must
+// still be running. Only the most recent [Options.GuestLogsTailLines] lines, or
+// [DefaultGuestLogsTailLines] if it is not set, are written.
+func (uvm *UtilityVM) DumpGuestLogs(ctx context.Context, w io.Writer) (err error) {
+	ctx, span := oc.StartSpan(ctx, "uvm::DumpGuestLogs")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute(logfields.UVMID, uvm.id))
+
+	if uvm.gc == nil {
+		return fmt.Errorf("dumping guest logs without a guest connection is %w", errNotSupported)
+	}
+
+	// processes created directly in the uVM (instead of in a container) are
+	// run by the GCS as external processes
+	params := &hcsschema.ProcessParameters{CreateStdOutPipe: true}
+	if uvm.operatingSystem == "windows" {
+		params.CommandLine = `wevtutil.exe qe Application /f:text`
+		params.WorkingDirectory = `C:\`
+	} else {
+		params.CommandArgs = []string{"dmesg", "-T"}
+		params.WorkingDirectory = "/"
+		params.Environment = map[string]string{"PATH": guestpath.LCOWDefaultPathEnv}
+	}
+
+	// dmesg needs CAP_SYSLOG to read the kernel ring buffer if the guest kernel
+	// restricts it, so it is exempt from [OptionsLCOW.DenyRootProcesses]: its
+	// command is fixed rather than chosen by the caller.
+	p, err := uvm.gc.CreateRootProcess(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to start guest log process: %w", err)
+	}
+	defer p.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_, _ = p.Kill(context.WithoutCancel(ctx))
+		case <-done:
+		}
+	}()
+
+	_, stdout, _ := p.Stdio()
+	n := uvm.guestLogsTailLines
+	if n == 0 {
+		n = DefaultGuestLogsTailLines
+	}
+	n = min(n, MaxGuestLogsTailLines)
+	if err := writeTail(w, stdout, int(n)); err != nil {
+		return fmt.Errorf("failed to copy guest logs: %w", err)
+	}
+
+	if err := p.Wait(); err != nil {
+		return fmt.Errorf("failed to wait for guest log process: %w", err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	code, err := p.ExitCode()
+	if err != nil {
+		return fmt.Errorf("failed to get guest log process exit code: %w", err)
+	}
+	if code != 0 {
+		return fmt.Errorf("guest log process exited with code %d", code)
+	}
+	return nil
+}
+
+// writeTail writes the last `n` lines read from `r` to `w`.
+func writeTail(w io.Writer, r io.Reader, n int) error {
+	lines := make([]string, n)
+	count := 0
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			lines[count%n] = line
+			count++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	start := 0
+	if count > n {
+		start = count - n
+	}
+	for i := start; i < count; i++ {
+		if _, err := io.WriteString(w, lines[i%n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
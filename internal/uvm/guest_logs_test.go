@@ -0,0 +1,58 @@
+//go:build windows
+
+package uvm
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_WriteTail(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		n        int
+		expected string
+	}{
+		{
+			name:     "Empty",
+			input:    "",
+			n:        2,
+			expected: "",
+		},
+		{
+			name:     "FewerLines",
+			input:    "a\nb\n",
+			n:        3,
+			expected: "a\nb\n",
+		},
+		{
+			name:     "MoreLines",
+			input:    "a\nb\nc\nd\n",
+			n:        2,
+			expected: "c\nd\n",
+		},
+		{
+			name:     "NoTrailingNewline",
+			input:    "a\nb\nc",
+			n:        2,
+			expected: "b\nc",
+		},
+		{
+			name:     "CRLF",
+			input:    "a\r\nb\r\nc\r\n",
+			n:        1,
+			expected: "c\r\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := writeTail(&b, strings.NewReader(tc.input), tc.n); err != nil {
+				t.Fatalf("expected nil err, got: %v", err)
+			}
+			if b.String() != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, b.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/memory"
+	"github.com/Microsoft/hcsshim/internal/processorinfo"
+)
+
+// The host memory used for each part of the HCS document a UVM is created with,
+// beyond its guest memory. The page table sizes follow from the page sizes. The
+// others are not measured values, but rough upper bounds of what the VM worker
+// process and the hypervisor use, which vary by host build; compare them against
+// the estimates logged when UVMs are created and grown before relying on them.
+//
+// They are only applied by [overheadBytes], to the document the create path
+// builds, so the estimates follow the processors, memory and devices a UVM is
+// actually created with.
+const (
+	// uvmBaseOverheadBytes is the memory used for a UVM with no processors,
+	// guest memory or devices.
+	uvmBaseOverheadBytes = 32 * memory.MiB
+	// processorOverheadBytes is the memory used for each of the UVM's vCPUs.
+	processorOverheadBytes = 2 * memory.MiB
+	// guestMemoryOverheadBytesPerGiB is the memory of the page tables mapping
+	// each GiB of guest memory, at 8 bytes for each 4KiB page.
+	guestMemoryOverheadBytesPerGiB = 2 * memory.MiB
+	// vpmemDeviceOverheadBytes is the memory used for each VPMem device.
+	vpmemDeviceOverheadBytes = 1 * memory.MiB
+	// vpmemOverheadBytesPerGiB is the memory of the page tables mapping each
+	// GiB of a VPMem device, which is mapped with 2MiB pages.
+	vpmemOverheadBytesPerGiB = 4096
+	// vsmbShareOverheadBytes is the memory used for each VSMB share.
+	vsmbShareOverheadBytes = 4 * memory.MiB
+)
+
+// MemoryEstimate is the host memory a UVM is expected to use, as returned by
+// [EstimateUVMOverhead].
+type MemoryEstimate struct {
+	// GuestMemoryInMB is the memory assigned to the UVM, aligned as it is
+	// when the UVM is created.
+	GuestMemoryInMB uint64
+	// GuestCommitInMB is the host commit for the guest memory when the UVM is
+	// created. It is zero with deferred commit, where the commit grows up to
+	// GuestMemoryInMB as the guest uses its memory.
+	GuestCommitInMB uint64
+	// OverheadInMB is the host memory used for the UVM's processors, page
+	// tables and devices, beyond its guest memory.
+	OverheadInMB uint64
+	// PhysicallyBacked is set if the guest memory is backed by physical
+	// memory, which is committed up front and never paged out.
+	PhysicallyBacked bool
+}
+
+// CommitInMB returns the host commit of the UVM when it is created.
+func (e MemoryEstimate) CommitInMB() uint64 {
+	return e.GuestCommitInMB + e.OverheadInMB
+}
+
+// MaxCommitInMB returns the host commit of the UVM once all of its guest
+// memory is in use.
+func (e MemoryEstimate) MaxCommitInMB() uint64 {
+	return e.GuestMemoryInMB + e.OverheadInMB
+}
+
+// withGuestMemory returns `e` for the same UVM once its guest memory is resized
+// to `sizeInMB`, which must already be aligned. Only the page tables for the
+// guest memory change with its size.
+func (e MemoryEstimate) withGuestMemory(sizeInMB uint64) MemoryEstimate {
+	if e.GuestMemoryInMB == 0 {
+		// the UVM's memory was not estimated
+		return e
+	}
+	// the page tables are whole MB, so they can be moved in and out of the
+	// rounded overhead
+	e.OverheadInMB -= pageTableBytes(e.GuestMemoryInMB*memory.MiB, guestMemoryOverheadBytesPerGiB) / memory.MiB
+	e.OverheadInMB += pageTableBytes(sizeInMB*memory.MiB, guestMemoryOverheadBytesPerGiB) / memory.MiB
+	if e.GuestCommitInMB != 0 {
+		e.GuestCommitInMB = sizeInMB
+	}
+	e.GuestMemoryInMB = sizeInMB
+	return e
+}
+
+// alignMemorySizeInMB aligns `requested` up to the 2MB that HCS assigns UVM
+// memory in.
+func alignMemorySizeInMB(requested uint64) uint64 {
+	return (requested + 1) &^ 1 // align up to an even number
+}
+
+// hostProcessorInfo is overridden in tests.
+var hostProcessorInfo = processorinfo.HostProcessorInfo
+
+// EstimateUVMOverhead returns the host memory that a UVM created with `opts`
+// is expected to use for its guest memory and processors. Use
+// [EstimateLCOWOverhead] or [EstimateWCOWOverhead] to include the devices
+// added when an LCOW or WCOW UVM is created.
+//
+// The processor count and memory size are normalized as they are when the UVM
+// is created, so the processor count is lowered to what the host has.
+func EstimateUVMOverhead(ctx context.Context, opts *Options) (MemoryEstimate, error) {
+	vm, err := estimateVirtualMachine(ctx, opts)
+	if err != nil {
+		return MemoryEstimate{}, err
+	}
+	return estimateVirtualMachineMemory(vm), nil
+}
+
+// EstimateLCOWOverhead returns the host memory that an LCOW UVM created with
+// `opts` is expected to use, including its VPMem devices. See
+// [EstimateUVMOverhead].
+func EstimateLCOWOverhead(ctx context.Context, opts *OptionsLCOW) (MemoryEstimate, error) {
+	if opts.VPMemDeviceCount > MaxVPMEMCount {
+		return MemoryEstimate{}, fmt.Errorf("VPMem device count cannot be greater than %d", MaxVPMEMCount)
+	}
+	vm, err := estimateVirtualMachine(ctx, opts.Options)
+	if err != nil {
+		return MemoryEstimate{}, err
+	}
+	// as added by makeLCOWDoc
+	if opts.VPMemDeviceCount > 0 {
+		vm.Devices.VirtualPMem = &hcsschema.VirtualPMemController{
+			MaximumCount:     opts.VPMemDeviceCount,
+			MaximumSizeBytes: opts.VPMemSizeBytes,
+		}
+	}
+	return estimateVirtualMachineMemory(vm), nil
+}
+
+// EstimateWCOWOverhead returns the host memory that a WCOW UVM created with
+// `opts` is expected to use, including the VSMB share of its OS layer. See
+// [EstimateUVMOverhead].
+func EstimateWCOWOverhead(ctx context.Context, opts *OptionsWCOW) (MemoryEstimate, error) {
+	vm, err := estimateVirtualMachine(ctx, opts.Options)
+	if err != nil {
+		return MemoryEstimate{}, err
+	}
+	// as added by prepareConfigDoc; confidential UVMs boot from SCSI instead
+	vm.Devices.VirtualSmb = &hcsschema.VirtualSmb{}
+	if !opts.SecurityPolicyEnabled {
+		vm.Devices.VirtualSmb.Shares = []hcsschema.VirtualSmbShare{{Name: "os"}}
+	}
+	return estimateVirtualMachineMemory(vm), nil
+}
+
+// estimateVirtualMachine returns the parts of the HCS document that the create
+// path builds from `opts` that [overheadBytes] prices, with the processor count
+// and memory size normalized as they are by the create path.
+func estimateVirtualMachine(ctx context.Context, opts *Options) (*hcsschema.VirtualMachine, error) {
+	if opts.MemorySizeInMB == 0 {
+		return nil, errors.New("MemorySizeInMB must be set to estimate the UVM memory")
+	}
+	if opts.ProcessorCount < 0 {
+		return nil, fmt.Errorf("invalid ProcessorCount %d", opts.ProcessorCount)
+	}
+	if opts.EnableDeferredCommit && !opts.AllowOvercommit {
+		return nil, errors.New("EnableDeferredCommit is not supported on physically backed VMs")
+	}
+
+	processorTopology, err := hostProcessorInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host processor information: %w", err)
+	}
+	vm := &UtilityVM{id: opts.ID}
+	return &hcsschema.VirtualMachine{
+		ComputeTopology: &hcsschema.Topology{
+			Memory: &hcsschema.VirtualMachineMemory{
+				SizeInMB:             alignMemorySizeInMB(opts.MemorySizeInMB),
+				AllowOvercommit:      opts.AllowOvercommit,
+				EnableDeferredCommit: opts.EnableDeferredCommit,
+			},
+			Processor: &hcsschema.VirtualMachineProcessor{
+				Count: uint32(vm.normalizeProcessorCount(ctx, opts.ProcessorCount, processorTopology)),
+			},
+		},
+		Devices: &hcsschema.Devices{},
+	}, nil
+}
+
+// estimateVirtualMachineMemory returns the host memory that the UVM created from
+// the HCS document `vm` is expected to use.
+func estimateVirtualMachineMemory(vm *hcsschema.VirtualMachine) MemoryEstimate {
+	mem := vm.ComputeTopology.Memory
+	e := MemoryEstimate{
+		GuestMemoryInMB:  mem.SizeInMB,
+		PhysicallyBacked: !mem.AllowOvercommit,
+		OverheadInMB:     bytesToMB(overheadBytes(vm)),
+	}
+	if !mem.EnableDeferredCommit {
+		e.GuestCommitInMB = mem.SizeInMB
+	}
+	return e
+}
+
+// overheadBytes returns the host memory used for the UVM created from the HCS
+// document `vm`, beyond its guest memory.
+func overheadBytes(vm *hcsschema.VirtualMachine) uint64 {
+	b := uint64(uvmBaseOverheadBytes)
+	if t := vm.ComputeTopology; t != nil {
+		if t.Processor != nil {
+			b += uint64(t.Processor.Count) * processorOverheadBytes
+		}
+		if t.Memory != nil {
+			b += pageTableBytes(t.Memory.SizeInMB*memory.MiB, guestMemoryOverheadBytesPerGiB)
+		}
+	}
+	if d := vm.Devices; d != nil {
+		if d.VirtualPMem != nil {
+			b += uint64(d.VirtualPMem.MaximumCount) *
+				(vpmemDeviceOverheadBytes + pageTableBytes(d.VirtualPMem.MaximumSizeBytes, vpmemOverheadBytesPerGiB))
+		}
+		if d.VirtualSmb != nil {
+			b += uint64(len(d.VirtualSmb.Shares)) * vsmbShareOverheadBytes
+		}
+	}
+	return b
+}
+
+// pageTableBytes returns the memory of the page tables mapping `size` bytes,
+// at `perGiB` bytes for each GiB, rounded up to a whole GiB.
+func pageTableBytes(size, perGiB uint64) uint64 {
+	return (size + memory.GiB - 1) / memory.GiB * perGiB
+}
+
+// bytesToMB converts `b` to MB, rounding up.
+func bytesToMB(b uint64) uint64 {
+	return (b + memory.MiB - 1) / memory.MiB
+}
+
+// logMemoryEstimate adds `e`, the estimated memory of the UVM `id`, to `span`
+// and the debug log, so that it can be compared against what the UVM uses.
+func logMemoryEstimate(ctx context.Context, span *trace.Span, id string, e MemoryEstimate) {
+	span.AddAttributes(
+		trace.Int64Attribute("estimatedCommitInMB", int64(e.CommitInMB())),
+		trace.Int64Attribute("estimatedMaxCommitInMB", int64(e.MaxCommitInMB())),
+		trace.Int64Attribute("estimatedOverheadInMB", int64(e.OverheadInMB)))
+	log.G(ctx).WithFields(logrus.Fields{
+		logfields.UVMID:          id,
+		"guestMemoryInMB":        e.GuestMemoryInMB,
+		"estimatedCommitInMB":    e.CommitInMB(),
+		"estimatedMaxCommitInMB": e.MaxCommitInMB(),
+		"estimatedOverheadInMB":  e.OverheadInMB,
+		"physicallyBacked":       e.PhysicallyBacked,
+	}).Debug("estimated utility VM host memory")
+}
@@ -0,0 +1,179 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"testing"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+)
+
+// stubHostProcessors makes the estimates see a host with `count` logical processors.
+func stubHostProcessors(t *testing.T, count uint32) {
+	t.Helper()
+	orig := hostProcessorInfo
+	t.Cleanup(func() { hostProcessorInfo = orig })
+	hostProcessorInfo = func(context.Context) (*hcsschema.ProcessorTopology, error) {
+		return &hcsschema.ProcessorTopology{LogicalProcessorCount: count}, nil
+	}
+}
+
+func TestEstimateOverhead(t *testing.T) {
+	ctx := context.Background()
+	stubHostProcessors(t, 8)
+	lcow := func(memoryInMB uint64, processors int32, overcommit, deferred bool, vpmemCount uint32) *OptionsLCOW {
+		return &OptionsLCOW{
+			Options: &Options{
+				MemorySizeInMB:       memoryInMB,
+				ProcessorCount:       processors,
+				AllowOvercommit:      overcommit,
+				EnableDeferredCommit: deferred,
+			},
+			VPMemDeviceCount: vpmemCount,
+			VPMemSizeBytes:   DefaultVPMemSizeBytes,
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		estimate func() (MemoryEstimate, error)
+		expected MemoryEstimate
+		err      bool
+	}{
+		{
+			name:     "virtual",
+			estimate: func() (MemoryEstimate, error) { return EstimateUVMOverhead(ctx, lcow(1024, 2, true, false, 0).Options) },
+			// 32 base + 2 processors * 2 + 1GiB of page tables * 2
+			expected: MemoryEstimate{GuestMemoryInMB: 1024, GuestCommitInMB: 1024, OverheadInMB: 38},
+		},
+		{
+			name:     "deferred commit aligned",
+			estimate: func() (MemoryEstimate, error) { return EstimateUVMOverhead(ctx, lcow(1023, 4, true, true, 0).Options) },
+			expected: MemoryEstimate{GuestMemoryInMB: 1024, OverheadInMB: 42},
+		},
+		{
+			name: "physical",
+			estimate: func() (MemoryEstimate, error) {
+				return EstimateUVMOverhead(ctx, lcow(2048, 1, false, false, 0).Options)
+			},
+			expected: MemoryEstimate{GuestMemoryInMB: 2048, GuestCommitInMB: 2048, OverheadInMB: 38, PhysicallyBacked: true},
+		},
+		{
+			name: "more processors than the host",
+			estimate: func() (MemoryEstimate, error) {
+				return EstimateUVMOverhead(ctx, lcow(1024, 16, true, false, 0).Options)
+			},
+			// the processor count is lowered to the host's 8
+			expected: MemoryEstimate{GuestMemoryInMB: 1024, GuestCommitInMB: 1024, OverheadInMB: 50},
+		},
+		{
+			name: "lcow vpmem",
+			estimate: func() (MemoryEstimate, error) {
+				return EstimateLCOWOverhead(ctx, lcow(1024, 2, true, false, DefaultVPMEMCount))
+			},
+			// 64 devices * (1MiB + 4GiB of page tables * 4KiB)
+			expected: MemoryEstimate{GuestMemoryInMB: 1024, GuestCommitInMB: 1024, OverheadInMB: 38 + 65},
+		},
+		{
+			name: "wcow",
+			estimate: func() (MemoryEstimate, error) {
+				return EstimateWCOWOverhead(ctx, &OptionsWCOW{Options: lcow(1024, 2, true, false, 0).Options})
+			},
+			expected: MemoryEstimate{GuestMemoryInMB: 1024, GuestCommitInMB: 1024, OverheadInMB: 42},
+		},
+		{
+			name: "confidential wcow",
+			estimate: func() (MemoryEstimate, error) {
+				opts := &OptionsWCOW{Options: lcow(1024, 2, true, false, 0).Options}
+				opts.SecurityPolicyEnabled = true
+				return EstimateWCOWOverhead(ctx, opts)
+			},
+			// no VSMB share for the OS layer
+			expected: MemoryEstimate{GuestMemoryInMB: 1024, GuestCommitInMB: 1024, OverheadInMB: 38},
+		},
+		{
+			name:     "no memory",
+			estimate: func() (MemoryEstimate, error) { return EstimateUVMOverhead(ctx, lcow(0, 2, true, false, 0).Options) },
+			err:      true,
+		},
+		{
+			name:     "physical deferred commit",
+			estimate: func() (MemoryEstimate, error) { return EstimateUVMOverhead(ctx, lcow(1024, 2, false, true, 0).Options) },
+			err:      true,
+		},
+		{
+			name: "too many vpmem devices",
+			estimate: func() (MemoryEstimate, error) {
+				return EstimateLCOWOverhead(ctx, lcow(1024, 2, true, false, MaxVPMEMCount+1))
+			},
+			err: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := tc.estimate()
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error, got estimate %+v", e)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to estimate memory: %v", err)
+			}
+			if e != tc.expected {
+				t.Fatalf("expected estimate %+v, got %+v", tc.expected, e)
+			}
+		})
+	}
+}
+
+func TestEstimateWithGuestMemory(t *testing.T) {
+	ctx := context.Background()
+	stubHostProcessors(t, 8)
+	for _, deferred := range []bool{false, true} {
+		opts := &OptionsLCOW{
+			Options: &Options{
+				MemorySizeInMB:       1024,
+				ProcessorCount:       2,
+				AllowOvercommit:      true,
+				EnableDeferredCommit: deferred,
+			},
+			VPMemDeviceCount: DefaultVPMEMCount,
+			VPMemSizeBytes:   DefaultVPMemSizeBytes,
+		}
+		e, err := EstimateLCOWOverhead(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a grown UVM is estimated as if it was created with the new size
+		opts.MemorySizeInMB = 3072
+		expected, err := EstimateLCOWOverhead(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if grown := e.withGuestMemory(3072); grown != expected {
+			t.Fatalf("deferred commit %t: expected grown estimate %+v, got %+v", deferred, expected, grown)
+		}
+	}
+
+	if e := (MemoryEstimate{}).withGuestMemory(2048); e != (MemoryEstimate{}) {
+		t.Fatalf("expected no estimate for a UVM that was not estimated, got %+v", e)
+	}
+}
+
+func TestEstimateOverheadMatchesCreate(t *testing.T) {
+	ctx := context.Background()
+	stubHostProcessors(t, 8)
+	// the estimate must use the memory size the UVM is created with
+	for _, requested := range []uint64{1, 511, 512, 4095} {
+		e, err := EstimateUVMOverhead(ctx, &Options{MemorySizeInMB: requested, AllowOvercommit: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm := &UtilityVM{}
+		if actual := vm.normalizeMemorySize(ctx, requested); e.GuestMemoryInMB != actual {
+			t.Fatalf("expected guest memory %d MB for %d MB requested, got %d MB", actual, requested, e.GuestMemoryInMB)
+		}
+	}
+}
@@ -17,13 +17,34 @@ const bytesPerPage = 4096
 // Internally, HCS will get the number of pages this corresponds to and attempt to assign
 // pages to numa nodes evenly
 func (uvm *UtilityVM) UpdateMemory(ctx context.Context, sizeInBytes uint64) error {
-	requestedSizeInMB := sizeInBytes / memory.MiB
+	uvm.admissionMu.Lock()
+	defer uvm.admissionMu.Unlock()
+	return uvm.updateMemory(ctx, sizeInBytes/memory.MiB)
+}
+
+// updateMemory updates the VM's size to `requestedSizeInMB`.
+//
+// Must be called with admissionMu held.
+func (uvm *UtilityVM) updateMemory(ctx context.Context, requestedSizeInMB uint64) error {
 	actual := uvm.normalizeMemorySize(ctx, requestedSizeInMB)
 	req := &hcsschema.ModifySettingRequest{
 		ResourcePath: resourcepaths.MemoryResourcePath,
 		Settings:     actual,
 	}
-	return uvm.modify(ctx, req)
+	if err := uvm.modify(ctx, req); err != nil {
+		return err
+	}
+	uvm.memorySizeInMB = actual
+	uvm.memoryEstimate = uvm.memoryEstimate.withGuestMemory(actual)
+	return nil
+}
+
+// MemorySizeInMB returns the memory assigned to the UVM when it was created,
+// or last updated by [UtilityVM.UpdateMemory].
+func (uvm *UtilityVM) MemorySizeInMB() uint64 {
+	uvm.admissionMu.Lock()
+	defer uvm.admissionMu.Unlock()
+	return uvm.memorySizeInMB
 }
 
 // GetAssignedMemoryInBytes returns the amount of assigned memory for the UVM in bytes
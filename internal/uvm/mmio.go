@@ -0,0 +1,202 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+
+	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
+
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/memory"
+	"github.com/Microsoft/hcsshim/internal/windevice"
+)
+
+// The limits of the MMIO gaps of a UVM.
+const (
+	// MinLowMMIOGapInMB and MaxLowMMIOGapInMB bound the MMIO gap below 4GB.
+	MinLowMMIOGapInMB = 128
+	MaxLowMMIOGapInMB = 3584
+	// MinHighMMIOBaseInMB is the lowest base of the MMIO gap above 4GB, which
+	// also defaults to the platform default if it is `0`.
+	MinHighMMIOBaseInMB = 4096
+	// MaxHighMMIOEndInMB is the end of the 46 bit guest physical address
+	// space that the MMIO gap above 4GB must fit in.
+	MaxHighMMIOEndInMB = 64 * 1024 * 1024
+)
+
+// defaultLowMMIOGapInMB and defaultHighMMIOGapInMB are kept free of assigned
+// devices when sizing the MMIO gaps, for the UVM's own devices.
+const (
+	defaultLowMMIOGapInMB  = 128
+	defaultHighMMIOGapInMB = 512
+)
+
+// MMIOGaps are the MMIO gaps a UVM was created with. A field that is `0` was
+// left to the platform default.
+type MMIOGaps struct {
+	LowGapInMB   uint64
+	HighBaseInMB uint64
+	HighGapInMB  uint64
+}
+
+func mmioGapsOf(opts *Options) MMIOGaps {
+	return MMIOGaps{
+		LowGapInMB:   opts.LowMMIOGapInMB,
+		HighBaseInMB: opts.HighMMIOBaseInMB,
+		HighGapInMB:  opts.HighMMIOGapInMB,
+	}
+}
+
+// MMIOGaps returns the MMIO gaps of the UVM, including those sized for the
+// devices assigned when it was created.
+func (uvm *UtilityVM) MMIOGaps() MMIOGaps {
+	return uvm.mmioGaps
+}
+
+// maxHighGapInMB returns the largest high MMIO gap that fits above the base
+// of `g`.
+func (g MMIOGaps) maxHighGapInMB() uint64 {
+	base := g.HighBaseInMB
+	if base < MinHighMMIOBaseInMB {
+		base = MinHighMMIOBaseInMB
+	}
+	if base >= MaxHighMMIOEndInMB {
+		return 0
+	}
+	return MaxHighMMIOEndInMB - base
+}
+
+// validateMMIOGaps returns an error if the MMIO gaps of `opts` are outside of
+// the platform limits.
+func validateMMIOGaps(opts *Options) error {
+	g := mmioGapsOf(opts)
+	if g.LowGapInMB != 0 && (g.LowGapInMB < MinLowMMIOGapInMB || g.LowGapInMB > MaxLowMMIOGapInMB) {
+		return fmt.Errorf("low MMIO gap of %d MB must be between %d and %d MB", g.LowGapInMB, MinLowMMIOGapInMB, MaxLowMMIOGapInMB)
+	}
+	if g.HighBaseInMB != 0 && (g.HighBaseInMB < MinHighMMIOBaseInMB || g.HighBaseInMB >= MaxHighMMIOEndInMB) {
+		return fmt.Errorf("high MMIO base of %d MB must be between %d and %d MB", g.HighBaseInMB, MinHighMMIOBaseInMB, MaxHighMMIOEndInMB)
+	}
+	if g.HighGapInMB > g.maxHighGapInMB() {
+		return fmt.Errorf("high MMIO gap of %d MB above a base of %d MB exceeds the %d MB guest address space", g.HighGapInMB, g.HighBaseInMB, MaxHighMMIOEndInMB)
+	}
+	return nil
+}
+
+// deviceBARs are the BARs of an assigned device.
+type deviceBARs struct {
+	id   string
+	bars []windevice.MemoryRange
+}
+
+// getDeviceMemoryRanges is windevice.GetDeviceMemoryRanges, replaced in tests.
+var getDeviceMemoryRanges = windevice.GetDeviceMemoryRanges
+
+// autoSizeMMIOGaps grows the MMIO gaps of `opts` to fit the BARs of its
+// assigned devices, as found on the host.
+func autoSizeMMIOGaps(ctx context.Context, opts *OptionsLCOW) error {
+	var devices []deviceBARs
+	seen := make(map[string]struct{})
+	for _, d := range opts.AssignedDevices {
+		// all the virtual functions of a device are sized by the device's BARs
+		if _, ok := seen[d.deviceInstanceID]; ok {
+			continue
+		}
+		seen[d.deviceInstanceID] = struct{}{}
+		bars, err := getDeviceMemoryRanges(d.deviceInstanceID)
+		if err != nil {
+			return fmt.Errorf("failed to get the BARs of assigned device %s: %w", d.deviceInstanceID, err)
+		}
+		devices = append(devices, deviceBARs{id: d.deviceInstanceID, bars: bars})
+	}
+
+	g, err := sizeMMIOGaps(mmioGapsOf(opts.Options), devices)
+	if err != nil {
+		return err
+	}
+	log.G(ctx).WithFields(logrus.Fields{
+		logfields.UVMID:    opts.ID,
+		"lowMMIOGapInMB":   g.LowGapInMB,
+		"highMMIOBaseInMB": g.HighBaseInMB,
+		"highMMIOGapInMB":  g.HighGapInMB,
+	}).Debug("sized utility VM MMIO gaps for assigned devices")
+	opts.LowMMIOGapInMB = g.LowGapInMB
+	opts.HighMMIOBaseInMB = g.HighBaseInMB
+	opts.HighMMIOGapInMB = g.HighGapInMB
+	return nil
+}
+
+// sizeMMIOGaps returns `g` with its gaps grown to fit the BARs of `devices`.
+//
+// A BAR the host mapped below 4GB is taken to be a 32 bit BAR, which must fit
+// in the low gap. BARs are naturally aligned, so the high gap is also aligned
+// to the largest BAR in it.
+func sizeMMIOGaps(g MMIOGaps, devices []deviceBARs) (MMIOGaps, error) {
+	maxLowBAR := uint64(MaxLowMMIOGapInMB - defaultLowMMIOGapInMB)
+	maxHighBAR := uint64(0)
+	if maxHigh := g.maxHighGapInMB(); maxHigh > defaultHighMMIOGapInMB {
+		maxHighBAR = maxHigh - defaultHighMMIOGapInMB
+	}
+
+	var lowInMB, highInMB, largestHighInMB uint64
+	for _, d := range devices {
+		for _, bar := range d.bars {
+			sizeInMB := bytesToMB(roundUpPowerOfTwo(bar.Size))
+			if bar.End() <= 4*memory.GiB {
+				if sizeInMB > maxLowBAR {
+					return g, fmt.Errorf("the %d MB 32 bit BAR of device %s cannot fit in the low MMIO gap of at most %d MB", sizeInMB, d.id, MaxLowMMIOGapInMB)
+				}
+				lowInMB += sizeInMB
+				continue
+			}
+			if sizeInMB > maxHighBAR {
+				return g, fmt.Errorf("the %d MB BAR of device %s cannot fit in the high MMIO gap of at most %d MB", sizeInMB, d.id, g.maxHighGapInMB())
+			}
+			highInMB += sizeInMB
+			if sizeInMB > largestHighInMB {
+				largestHighInMB = sizeInMB
+			}
+		}
+	}
+
+	if lowInMB > 0 {
+		low := defaultLowMMIOGapInMB + lowInMB
+		if low > MaxLowMMIOGapInMB {
+			return g, fmt.Errorf("the 32 bit BARs of the assigned devices need a low MMIO gap of %d MB, more than the maximum of %d MB", low, MaxLowMMIOGapInMB)
+		}
+		if low > g.LowGapInMB {
+			g.LowGapInMB = low
+		}
+	}
+	if highInMB > 0 {
+		high := defaultHighMMIOGapInMB + highInMB
+		high = (high + largestHighInMB - 1) / largestHighInMB * largestHighInMB
+		if high > g.maxHighGapInMB() {
+			return g, fmt.Errorf("the BARs of the assigned devices need a high MMIO gap of %d MB, more than the maximum of %d MB", high, g.maxHighGapInMB())
+		}
+		if high > g.HighGapInMB {
+			g.HighGapInMB = high
+		}
+	}
+	return g, nil
+}
+
+// roundUpPowerOfTwo returns the smallest power of two that is at least `n`.
+func roundUpPowerOfTwo(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(n-1)
+}
+
+// addMMIOGapsToSpan adds the MMIO gaps `g` of a UVM being created to `span`.
+func addMMIOGapsToSpan(span *trace.Span, g MMIOGaps) {
+	span.AddAttributes(
+		trace.Int64Attribute("lowMMIOGapInMB", int64(g.LowGapInMB)),
+		trace.Int64Attribute("highMMIOBaseInMB", int64(g.HighBaseInMB)),
+		trace.Int64Attribute("highMMIOGapInMB", int64(g.HighGapInMB)))
+}
@@ -0,0 +1,174 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/memory"
+	"github.com/Microsoft/hcsshim/internal/windevice"
+)
+
+func TestValidateMMIOGaps(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		gaps MMIOGaps
+		err  bool
+	}{
+		{name: "defaults"},
+		{name: "valid", gaps: MMIOGaps{LowGapInMB: 512, HighBaseInMB: 65536, HighGapInMB: 262144}},
+		{name: "high gap at the end", gaps: MMIOGaps{HighBaseInMB: MaxHighMMIOEndInMB - 1024, HighGapInMB: 1024}},
+		{name: "low gap too small", gaps: MMIOGaps{LowGapInMB: 64}, err: true},
+		{name: "low gap too large", gaps: MMIOGaps{LowGapInMB: 4096}, err: true},
+		{name: "high base below 4GB", gaps: MMIOGaps{HighBaseInMB: 2048}, err: true},
+		{name: "high gap past the end", gaps: MMIOGaps{HighBaseInMB: MaxHighMMIOEndInMB - 1024, HighGapInMB: 2048}, err: true},
+		{name: "default high base gap too large", gaps: MMIOGaps{HighGapInMB: MaxHighMMIOEndInMB}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMMIOGaps(&Options{
+				LowMMIOGapInMB:   tc.gaps.LowGapInMB,
+				HighMMIOBaseInMB: tc.gaps.HighBaseInMB,
+				HighMMIOGapInMB:  tc.gaps.HighGapInMB,
+			})
+			if tc.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestSizeMMIOGaps(t *testing.T) {
+	const (
+		low  = 0xc000_0000
+		high = 0x38_0000_0000
+	)
+	bar := func(base, size uint64) windevice.MemoryRange {
+		return windevice.MemoryRange{Base: base, Size: size}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		gaps     MMIOGaps
+		devices  []deviceBARs
+		expected MMIOGaps
+		err      string
+	}{
+		{
+			name: "no devices",
+			gaps: MMIOGaps{LowGapInMB: 256},
+			// gaps that are set are kept
+			expected: MMIOGaps{LowGapInMB: 256},
+		},
+		{
+			name: "gpu",
+			devices: []deviceBARs{{id: "gpu0", bars: []windevice.MemoryRange{
+				bar(low, 16*memory.MiB),
+				bar(high, 32*memory.GiB),
+				bar(high+32*memory.GiB, 32*memory.MiB),
+			}}},
+			// the 512MB default and the 32MB BAR align up to a second 32GB
+			expected: MMIOGaps{LowGapInMB: 144, HighGapInMB: 64 * 1024},
+		},
+		{
+			name: "unaligned BAR",
+			devices: []deviceBARs{{id: "nic0", bars: []windevice.MemoryRange{
+				bar(low, 100*1024),
+				bar(high, 3*memory.GiB),
+			}}},
+			expected: MMIOGaps{LowGapInMB: 129, HighGapInMB: 8 * 1024},
+		},
+		{
+			name: "larger gaps are kept",
+			gaps: MMIOGaps{LowGapInMB: 1024, HighBaseInMB: 65536, HighGapInMB: 1024 * 1024},
+			devices: []deviceBARs{{id: "gpu0", bars: []windevice.MemoryRange{
+				bar(low, 16*memory.MiB),
+				bar(high, 32*memory.GiB),
+			}}},
+			expected: MMIOGaps{LowGapInMB: 1024, HighBaseInMB: 65536, HighGapInMB: 1024 * 1024},
+		},
+		{
+			name: "32 bit BAR too large",
+			devices: []deviceBARs{{id: "gpu0", bars: []windevice.MemoryRange{
+				bar(0, 4*memory.GiB),
+			}}},
+			err: "BAR of device gpu0 cannot fit",
+		},
+		{
+			name: "32 bit BARs too large together",
+			devices: []deviceBARs{
+				{id: "gpu0", bars: []windevice.MemoryRange{bar(low, 2*memory.GiB)}},
+				{id: "gpu1", bars: []windevice.MemoryRange{bar(low, 2*memory.GiB)}},
+			},
+			err: "need a low MMIO gap of 4224 MB",
+		},
+		{
+			name: "BAR above the high base limit",
+			gaps: MMIOGaps{HighBaseInMB: MaxHighMMIOEndInMB - 64*1024},
+			devices: []deviceBARs{{id: "gpu0", bars: []windevice.MemoryRange{
+				bar(high, 64*memory.GiB),
+			}}},
+			err: "BAR of device gpu0 cannot fit in the high MMIO gap",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := sizeMMIOGaps(tc.gaps, tc.devices)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to size MMIO gaps: %v", err)
+			}
+			if g != tc.expected {
+				t.Fatalf("expected MMIO gaps %+v, got %+v", tc.expected, g)
+			}
+			if err := validateMMIOGaps(&Options{
+				LowMMIOGapInMB:   g.LowGapInMB,
+				HighMMIOBaseInMB: g.HighBaseInMB,
+				HighMMIOGapInMB:  g.HighGapInMB,
+			}); err != nil {
+				t.Fatalf("sized MMIO gaps are invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestAutoSizeMMIOGaps(t *testing.T) {
+	queried := make(map[string]int)
+	getDeviceMemoryRanges = func(id string) ([]windevice.MemoryRange, error) {
+		queried[id]++
+		if id == "missing" {
+			return nil, errors.New("device not found")
+		}
+		return []windevice.MemoryRange{{Base: 0x38_0000_0000, Size: 16 * memory.GiB}}, nil
+	}
+	t.Cleanup(func() { getDeviceMemoryRanges = windevice.GetDeviceMemoryRanges })
+
+	opts := &OptionsLCOW{
+		Options: &Options{ID: t.Name()},
+		AssignedDevices: []VPCIDeviceID{
+			NewVPCIDeviceID("gpu0", 0),
+			NewVPCIDeviceID("gpu0", 1),
+		},
+	}
+	if err := autoSizeMMIOGaps(context.Background(), opts); err != nil {
+		t.Fatalf("failed to size MMIO gaps: %v", err)
+	}
+	if opts.HighMMIOGapInMB != 32*1024 {
+		t.Fatalf("expected high MMIO gap of %d MB, got %d MB", 32*1024, opts.HighMMIOGapInMB)
+	}
+	// the virtual functions of a device are sized once
+	if queried["gpu0"] != 1 {
+		t.Fatalf("expected device to be queried once, got %d", queried["gpu0"])
+	}
+
+	opts.AssignedDevices = append(opts.AssignedDevices, NewVPCIDeviceID("missing", 0))
+	if err := autoSizeMMIOGaps(context.Background(), opts); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected error naming the missing device, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build windows
+
+package uvm
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/Microsoft/hcsshim/internal/processorinfo"
+	"github.com/Microsoft/hcsshim/osversion"
+)
+
+// ErrNestedVirtualizationNotSupported is returned when creating a UVM with
+// [OptionsLCOW.NestedVirtualization] on a host that cannot expose its
+// processor's virtualization extensions to a VM.
+var ErrNestedVirtualizationNotSupported = errors.New("nested virtualization is not supported on this host")
+
+// verifyNestedVirtualizationSupported returns an error wrapping
+// [ErrNestedVirtualizationNotSupported] if the host's hypervisor cannot expose
+// virtualization extensions to a VM. Hyper-V supports this on Intel processors,
+// and on AMD processors from Windows Server 2022 and Windows 11.
+//
+// This does not detect if the virtualization extensions are disabled in the
+// host's firmware, or if the host is itself a VM without them, in which case
+// the UVM fails to start.
+func verifyNestedVirtualizationSupported() error {
+	if runtime.GOARCH != "amd64" {
+		return fmt.Errorf("%w: not supported on %s hosts", ErrNestedVirtualizationNotSupported, runtime.GOARCH)
+	}
+	vendor, err := processorinfo.HostVendor()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNestedVirtualizationNotSupported, err)
+	}
+	switch vendor {
+	case processorinfo.VendorIntel:
+		return nil
+	case processorinfo.VendorAMD:
+		if osversion.Build() < osversion.V21H2Server {
+			return fmt.Errorf("%w: AMD processors require Windows build %d or later, host is build %d",
+				ErrNestedVirtualizationNotSupported, osversion.V21H2Server, osversion.Build())
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported processor vendor %q", ErrNestedVirtualizationNotSupported, vendor)
+	}
+}
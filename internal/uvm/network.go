@@ -171,11 +171,50 @@ func (uvm *UtilityVM) CreateAndAssignNetworkSetup(ctx context.Context, addr, con
 	return nil
 }
 
+// CreateAndAssignNamespaceNetworkSetup creates the network setup of the namespace `nsid`
+// of a pod that shares the utility VM with the pod it was created for, which is used
+// instead of the setup assigned by CreateAndAssignNetworkSetup to configure `nsid`.
+//
+// With an external network configuration proxy, the pod's own compute agent is served on
+// `addr` and registered with the proxy as `containerID` when the namespace is set up, so
+// that the proxy configures the pod's network, rather than that of the first pod. The
+// network is otherwise configured locally for every pod, so no setup is created.
+func (uvm *UtilityVM) CreateAndAssignNamespaceNetworkSetup(ctx context.Context, addr, containerID, nsid string) error {
+	if !uvm.NCProxyEnabled() {
+		return nil
+	}
+	if addr == "" || containerID == "" || nsid == "" {
+		return errors.New("received empty field(s) for external network setup")
+	}
+	setup, err := NewExternalNetworkSetup(ctx, uvm, addr, containerID)
+	if err != nil {
+		return err
+	}
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	if uvm.namespaceNetworkSetups == nil {
+		uvm.namespaceNetworkSetups = make(map[string]NetworkSetup)
+	}
+	uvm.namespaceNetworkSetups[nsid] = setup
+	return nil
+}
+
+// namespaceNetworkSetup returns the network setup to configure the namespace `nsid`
+// with, or nil if none was assigned.
+func (uvm *UtilityVM) namespaceNetworkSetup(nsid string) NetworkSetup {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	if setup, ok := uvm.namespaceNetworkSetups[nsid]; ok {
+		return setup
+	}
+	return uvm.networkSetup
+}
+
 // ConfigureNetworking configures the utility VMs networking setup using the namespace ID
 // `nsid`.
 func (uvm *UtilityVM) ConfigureNetworking(ctx context.Context, nsid string) error {
-	if uvm.networkSetup != nil {
-		return uvm.networkSetup.ConfigureNetworking(ctx, nsid, NetworkRequestSetup)
+	if setup := uvm.namespaceNetworkSetup(nsid); setup != nil {
+		return setup.ConfigureNetworking(ctx, nsid, NetworkRequestSetup)
 	}
 	return ErrNoNetworkSetup
 }
@@ -183,10 +222,17 @@ func (uvm *UtilityVM) ConfigureNetworking(ctx context.Context, nsid string) erro
 // TearDownNetworking tears down the utility VMs networking setup using the namespace ID
 // `nsid`.
 func (uvm *UtilityVM) TearDownNetworking(ctx context.Context, nsid string) error {
-	if uvm.networkSetup != nil {
-		return uvm.networkSetup.ConfigureNetworking(ctx, nsid, NetworkRequestTearDown)
+	setup := uvm.namespaceNetworkSetup(nsid)
+	if setup == nil {
+		return ErrNoNetworkSetup
 	}
-	return ErrNoNetworkSetup
+	if err := setup.ConfigureNetworking(ctx, nsid, NetworkRequestTearDown); err != nil {
+		return err
+	}
+	uvm.m.Lock()
+	delete(uvm.namespaceNetworkSetups, nsid)
+	uvm.m.Unlock()
+	return nil
 }
 
 // NetworkSetup is used to abstract away the details of setting up networking
@@ -402,7 +448,7 @@ func (uvm *UtilityVM) AddEndpointToNSWithID(ctx context.Context, nsID, nicID str
 			}
 			nicID = id.String()
 		}
-		if err := uvm.addNIC(ctx, nicID, endpointV2); err != nil {
+		if err := uvm.addNIC(ctx, nicID, endpointV2, uvm.netNSBandwidths[nsID]); err != nil {
 			return err
 		}
 		ns.nics[endpointV2.Id] = &nicInfo{
@@ -433,7 +479,7 @@ func (uvm *UtilityVM) AddEndpointsToNS(ctx context.Context, id string, endpoints
 			if err != nil {
 				return err
 			}
-			if err := uvm.addNIC(ctx, nicID.String(), endpoint); err != nil {
+			if err := uvm.addNIC(ctx, nicID.String(), endpoint, uvm.netNSBandwidths[id]); err != nil {
 				return err
 			}
 			ns.nics[endpoint.Id] = &nicInfo{
@@ -480,6 +526,7 @@ func (uvm *UtilityVM) RemoveNetNS(ctx context.Context, id string) error {
 		}
 		delete(uvm.namespaces, id)
 	}
+	delete(uvm.netNSBandwidths, id)
 	return nil
 }
 
@@ -599,8 +646,9 @@ func convertToLCOWReq(id string, endpoint *hcn.HostComputeEndpoint, policyBasedR
 	return req, nil
 }
 
-// addNIC adds a nic to the Utility VM.
-func (uvm *UtilityVM) addNIC(ctx context.Context, id string, endpoint *hcn.HostComputeEndpoint) error {
+// addNIC adds a nic to the Utility VM. For LCOW, the guest shapes the traffic of
+// the nic to `bandwidth`.
+func (uvm *UtilityVM) addNIC(ctx context.Context, id string, endpoint *hcn.HostComputeEndpoint, bandwidth NetworkBandwidth) error {
 	// First a pre-add. This is a guest-only request and is only done on Windows.
 	if uvm.operatingSystem == "windows" {
 		preAddRequest := hcsschema.ModifySettingRequest{
@@ -642,6 +690,16 @@ func (uvm *UtilityVM) addNIC(ctx context.Context, id string, endpoint *hcn.HostC
 		if err != nil {
 			return err
 		}
+		s.ClasslessStaticRoutes = uvm.classlessStaticRoutes
+		// if this is unset, the guest derives the effective MTU by reducing the
+		// MTU of the adapter's interface by EncapOverhead
+		s.MTU = uvm.networkMTU
+		s.VLANTag = uvm.networkVLANTag
+		if uvm.guestEgressShaping {
+			s.EgressBandwidth = uvm.egressBandwidth
+		}
+		s.IngressBandwidthMbps = bandwidth.IngressMbps
+		s.EgressBandwidthMbps = bandwidth.EgressMbps
 
 		// Verify this version of LCOW supports Network HotAdd
 		if uvm.isNetworkNamespaceSupported() {
@@ -653,7 +711,25 @@ func (uvm *UtilityVM) addNIC(ctx context.Context, id string, endpoint *hcn.HostC
 		}
 	}
 
+	// Cap the endpoint's bandwidth before the nic is added, so the traffic
+	// is never sent uncapped
+	if uvm.egressBandwidth != 0 {
+		if err := setEndpointEgressBandwidth(endpoint, hcn.RequestTypeAdd, uvm.egressBandwidth); err != nil {
+			return fmt.Errorf("failed to cap egress bandwidth of endpoint %s: %w", endpoint.Id, err)
+		}
+	}
+
 	if err := uvm.modify(ctx, &request); err != nil {
+		// Don't leave the cap on an endpoint that isn't attached, since the
+		// endpoint could be attached elsewhere afterwards
+		if uvm.egressBandwidth != 0 {
+			if rErr := setEndpointEgressBandwidth(endpoint, hcn.RequestTypeRemove, uvm.egressBandwidth); rErr != nil {
+				log.G(ctx).WithFields(logrus.Fields{
+					"endpoint":      endpoint.Id,
+					logrus.ErrorKey: rErr,
+				}).Warn("failed to remove egress bandwidth cap")
+			}
+		}
 		return err
 	}
 
@@ -0,0 +1,250 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+)
+
+// MinEgressBandwidth is the smallest cap, in bits per second, on the bandwidth
+// of an endpoint that the platform enforces with a QoS policy. The guest accepts
+// the same minimum when it shapes the traffic itself.
+const MinEgressBandwidth = guestresource.MinNetworkBandwidth
+
+// egressBandwidthUnits are the suffixes of a bandwidth and their multipliers,
+// with the binary suffixes before the decimal ones they start with.
+var egressBandwidthUnits = []struct {
+	suffix     string
+	multiplier uint64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"k", 1e3},
+	{"K", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+}
+
+// ParseEgressBandwidth parses `v`, a bandwidth in bits per second with an
+// optional decimal (k, M, G, T) or binary (Ki, Mi, Gi, Ti) suffix, such as
+// "100M" or "1Gi". Zero means the bandwidth is not capped; any other bandwidth
+// must be at least [MinEgressBandwidth].
+func ParseEgressBandwidth(v string) (uint64, error) {
+	num, multiplier := strings.TrimSpace(v), uint64(1)
+	for _, u := range egressBandwidthUnits {
+		if n, ok := strings.CutSuffix(num, u.suffix); ok {
+			num, multiplier = n, u.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid egress bandwidth %q: %w", v, err)
+	}
+	hi, bps := bits.Mul64(n, multiplier)
+	if hi != 0 {
+		return 0, fmt.Errorf("invalid egress bandwidth %q: value out of range", v)
+	}
+	if bps != 0 && bps < MinEgressBandwidth {
+		return 0, fmt.Errorf("invalid egress bandwidth %q: must be at least %d bits per second", v, MinEgressBandwidth)
+	}
+	return bps, nil
+}
+
+// setEndpointEgressBandwidth adds, updates, or removes, depending on `rt`, the
+// QoS policy on `endpoint` that caps the bandwidth of its outgoing traffic to
+// `bps` bits per second.
+func setEndpointEgressBandwidth(endpoint *hcn.HostComputeEndpoint, rt hcn.RequestType, bps uint64) error {
+	settings, err := json.Marshal(hcn.QosPolicySetting{
+		MaximumOutgoingBandwidthInBytes: bps / 8,
+	})
+	if err != nil {
+		return err
+	}
+	return endpoint.ApplyPolicy(rt, hcn.PolicyEndpointRequest{
+		Policies: []hcn.EndpointPolicy{
+			{
+				Type:     hcn.QOS,
+				Settings: settings,
+			},
+		},
+	})
+}
+
+// egressBandwidthRequest returns the request type, and the bandwidth in the QoS
+// policy, that change the cap on the bandwidth of an endpoint from `from` to
+// `to` bits per second.
+func egressBandwidthRequest(from, to uint64) (hcn.RequestType, uint64) {
+	switch {
+	case from == 0:
+		return hcn.RequestTypeAdd, to
+	case to == 0:
+		return hcn.RequestTypeRemove, from
+	}
+	return hcn.RequestTypeUpdate, to
+}
+
+// UpdateEndpointsEgressBandwidth changes the cap on the bandwidth of the traffic
+// sent by each of `endpoints` from `from` to `to` bits per second, where 0
+// means the bandwidth is not capped.
+//
+// On failure, the endpoints already changed are restored to `from`, so that the
+// cap is the same on all of them.
+func UpdateEndpointsEgressBandwidth(ctx context.Context, endpoints []*hcn.HostComputeEndpoint, from, to uint64) error {
+	if to != 0 && to < MinEgressBandwidth {
+		return fmt.Errorf("egress bandwidth can't be less than %d bits per second", MinEgressBandwidth)
+	}
+	if from == to {
+		return nil
+	}
+	rt, setting := egressBandwidthRequest(from, to)
+	for i, endpoint := range endpoints {
+		if err := setEndpointEgressBandwidth(endpoint, rt, setting); err != nil {
+			restoreEndpointsEgressBandwidth(ctx, endpoints[:i], to, from)
+			return fmt.Errorf("failed to update egress bandwidth of endpoint %s: %w", endpoint.Id, err)
+		}
+	}
+	return nil
+}
+
+// restoreEndpointsEgressBandwidth changes the cap on the bandwidth of `endpoints`
+// back from `from` to `to` bits per second after a failed update. Failures are
+// only logged, since the update has already failed.
+func restoreEndpointsEgressBandwidth(ctx context.Context, endpoints []*hcn.HostComputeEndpoint, from, to uint64) {
+	rt, setting := egressBandwidthRequest(from, to)
+	for _, endpoint := range endpoints {
+		if err := setEndpointEgressBandwidth(endpoint, rt, setting); err != nil {
+			log.G(ctx).WithFields(logrus.Fields{
+				"endpoint":      endpoint.Id,
+				logrus.ErrorKey: err,
+			}).Warn("failed to restore egress bandwidth cap")
+		}
+	}
+}
+
+// EgressBandwidth returns the cap, in bits per second, on the bandwidth of the
+// traffic sent by each of the UVM's network endpoints, or 0 if it is not capped.
+func (uvm *UtilityVM) EgressBandwidth() uint64 {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	return uvm.egressBandwidth
+}
+
+// UpdateEgressBandwidth changes the cap on the bandwidth of the traffic sent by
+// each of the UVM's network endpoints to `bps` bits per second, or removes it
+// if `bps` is 0. The endpoints added to the UVM afterwards are capped to `bps`.
+//
+// For LCOW, if the guest shapes the traffic sent on its net interfaces, the
+// guest is also updated.
+//
+// On failure, the endpoints and guest adapters already changed are restored to
+// the previous cap.
+func (uvm *UtilityVM) UpdateEgressBandwidth(ctx context.Context, bps uint64) error {
+	if bps != 0 && bps < MinEgressBandwidth {
+		return fmt.Errorf("egress bandwidth can't be less than %d bits per second", MinEgressBandwidth)
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	if bps == uvm.egressBandwidth {
+		return nil
+	}
+
+	var nics []*nicInfo
+	var endpoints []*hcn.HostComputeEndpoint
+	for _, ns := range uvm.namespaces {
+		for _, ninfo := range ns.nics {
+			nics = append(nics, ninfo)
+			endpoints = append(endpoints, ninfo.Endpoint)
+		}
+	}
+	if err := UpdateEndpointsEgressBandwidth(ctx, endpoints, uvm.egressBandwidth, bps); err != nil {
+		return err
+	}
+
+	if uvm.guestEgressShaping && uvm.isNetworkNamespaceSupported() {
+		for i, ninfo := range nics {
+			if err := uvm.updateGuestEgressBandwidth(ctx, ninfo, bps); err != nil {
+				for _, done := range nics[:i] {
+					if rErr := uvm.updateGuestEgressBandwidth(ctx, done, uvm.egressBandwidth); rErr != nil {
+						log.G(ctx).WithFields(logrus.Fields{
+							"adapter":       done.ID,
+							logrus.ErrorKey: rErr,
+						}).Warn("failed to restore guest egress bandwidth")
+					}
+				}
+				restoreEndpointsEgressBandwidth(ctx, endpoints, bps, uvm.egressBandwidth)
+				return fmt.Errorf("failed to update guest egress bandwidth of adapter %s: %w", ninfo.ID, err)
+			}
+		}
+	}
+
+	log.G(ctx).WithField("bandwidth", bps).Debug("updated uvm egress bandwidth")
+	uvm.egressBandwidth = bps
+	return nil
+}
+
+// updateGuestEgressBandwidth changes the bandwidth that the guest shapes the
+// traffic sent on the adapter of `ninfo` to `bps` bits per second.
+func (uvm *UtilityVM) updateGuestEgressBandwidth(ctx context.Context, ninfo *nicInfo, bps uint64) error {
+	request := hcsschema.ModifySettingRequest{
+		GuestRequest: guestrequest.ModificationRequest{
+			ResourceType: guestresource.ResourceTypeNetwork,
+			RequestType:  guestrequest.RequestTypeUpdate,
+			Settings: &guestresource.LCOWNetworkAdapter{
+				NamespaceID:     ninfo.Endpoint.HostComputeNamespace,
+				ID:              ninfo.ID,
+				EgressBandwidth: bps,
+			},
+		},
+	}
+	return uvm.modify(ctx, &request)
+}
+
+// NetworkBandwidth is the bandwidth, in megabits per second, that an LCOW guest
+// shapes the traffic of the adapters of a network namespace to. Zero means the
+// traffic in that direction is not shaped.
+type NetworkBandwidth struct {
+	IngressMbps uint32
+	EgressMbps  uint32
+}
+
+// SetNetNSBandwidth sets the bandwidth that the guest shapes the traffic of the
+// adapters added afterwards to the network namespace `nsid` to, so it must be
+// called before the namespace is configured. The bandwidth is forgotten when
+// the namespace is removed.
+//
+// This is only supported on LCOW.
+func (uvm *UtilityVM) SetNetNSBandwidth(nsid string, b NetworkBandwidth) error {
+	if uvm.operatingSystem == "windows" {
+		return errNotSupported
+	}
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	if b == (NetworkBandwidth{}) {
+		delete(uvm.netNSBandwidths, nsid)
+		return nil
+	}
+	if uvm.netNSBandwidths == nil {
+		uvm.netNSBandwidths = make(map[string]NetworkBandwidth)
+	}
+	uvm.netNSBandwidths[nsid] = b
+	return nil
+}
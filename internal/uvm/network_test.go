@@ -67,3 +67,45 @@ func Test_SortEndpoints(t *testing.T) {
 		})
 	}
 }
+
+func Test_ParseEgressBandwidth(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected uint64
+		valid    bool
+	}{
+		{value: "0", expected: 0, valid: true},
+		{value: "1000000", expected: 1_000_000, valid: true},
+		{value: "999999", valid: false},
+		{value: "100k", valid: false},
+		{value: "1M", expected: 1_000_000, valid: true},
+		{value: "1Mi", expected: 1 << 20, valid: true},
+		{value: "2500K", expected: 2_500_000, valid: true},
+		{value: "10G", expected: 10_000_000_000, valid: true},
+		{value: "1Gi", expected: 1 << 30, valid: true},
+		{value: " 1T ", expected: 1_000_000_000_000, valid: true},
+		{value: "1Ti", expected: 1 << 40, valid: true},
+		{value: "20000000T", valid: false},
+		{value: "", valid: false},
+		{value: "M", valid: false},
+		{value: "1.5G", valid: false},
+		{value: "-1M", valid: false},
+		{value: "1Mbps", valid: false},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			bps, err := ParseEgressBandwidth(tc.value)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected egress bandwidth %q to be invalid, got %d", tc.value, bps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected egress bandwidth %q to be valid, got: %v", tc.value, err)
+			}
+			if bps != tc.expected {
+				t.Fatalf("expected egress bandwidth %d, got %d", tc.expected, bps)
+			}
+		})
+	}
+}
@@ -15,10 +15,14 @@ type attachManager struct {
 	unplugger            unplugger
 	numControllers       int
 	numLUNsPerController int
-	slots                [][]*attachment
+	// layerControllers is the number of controllers, starting from 0, that
+	// [PoolLayers] is allocated from. The rest are for [PoolData]. If 0, both
+	// pools share all controllers.
+	layerControllers int
+	slots            [][]*attachment
 }
 
-func newAttachManager(attacher attacher, unplugger unplugger, numControllers, numLUNsPerController int, reservedSlots []Slot) *attachManager {
+func newAttachManager(attacher attacher, unplugger unplugger, numControllers, numLUNsPerController, layerControllers int, reservedSlots []Slot) *attachManager {
 	slots := make([][]*attachment, numControllers)
 	for i := range slots {
 		slots[i] = make([]*attachment, numLUNsPerController)
@@ -35,10 +39,59 @@ func newAttachManager(attacher attacher, unplugger unplugger, numControllers, nu
 		unplugger:            unplugger,
 		numControllers:       numControllers,
 		numLUNsPerController: numLUNsPerController,
+		layerControllers:     layerControllers,
 		slots:                slots,
 	}
 }
 
+// controllers returns the range of controllers, from start up to but not
+// including end, that the slots of `pool` are allocated from.
+func (am *attachManager) controllers(pool Pool) (start, end int) {
+	if am.layerControllers == 0 {
+		return 0, am.numControllers
+	}
+	if pool == PoolData {
+		return am.layerControllers, am.numControllers
+	}
+	return 0, am.layerControllers
+}
+
+// pools returns the allocation state of each pool of controllers.
+func (am *attachManager) pools() []PoolState {
+	am.m.Lock()
+	defer am.m.Unlock()
+
+	names := map[Pool]string{PoolLayers: "layers", PoolData: "data"}
+	pools := []Pool{PoolLayers, PoolData}
+	if am.layerControllers == 0 {
+		names[PoolLayers] = "shared"
+		pools = pools[:1]
+	}
+	states := make([]PoolState, 0, len(pools))
+	for _, pool := range pools {
+		s := PoolState{Name: names[pool]}
+		start, end := am.controllers(pool)
+		for controller := start; controller < end; controller++ {
+			s.Controllers = append(s.Controllers, uint(controller))
+			s.Slots += len(am.slots[controller])
+			for _, att := range am.slots[controller] {
+				if att == nil {
+					continue
+				}
+				s.SlotsInUse++
+				for owner := range att.owners {
+					if s.Owners == nil {
+						s.Owners = make(map[string]int)
+					}
+					s.Owners[owner]++
+				}
+			}
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
 type attachment struct {
 	controller uint
 	lun        uint
@@ -46,6 +99,30 @@ type attachment struct {
 	waitErr    error
 	waitCh     chan struct{}
 	refCount   uint
+	// owners counts the references to the attachment by the tag of their
+	// mounts, such as a container ID, for diagnostics. References without a
+	// tag are not counted.
+	owners map[string]uint
+}
+
+// addOwner adds a reference to `att` by `owner`.
+func (att *attachment) addOwner(owner string) {
+	if owner == "" {
+		return
+	}
+	if att.owners == nil {
+		att.owners = make(map[string]uint)
+	}
+	att.owners[owner]++
+}
+
+// removeOwner removes a reference to `att` by `owner`.
+func (att *attachment) removeOwner(owner string) {
+	if att.owners[owner] > 1 {
+		att.owners[owner]--
+		return
+	}
+	delete(att.owners, owner)
 }
 
 type attachConfig struct {
@@ -55,8 +132,8 @@ type attachConfig struct {
 	evdType  string
 }
 
-func (am *attachManager) attach(ctx context.Context, c *attachConfig) (controller uint, lun uint, err error) {
-	att, existed, err := am.trackAttachment(c)
+func (am *attachManager) attach(ctx context.Context, c *attachConfig, pool Pool, owner string) (controller uint, lun uint, err error) {
+	att, existed, err := am.trackAttachment(c, pool, owner)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -89,7 +166,7 @@ func (am *attachManager) attach(ctx context.Context, c *attachConfig) (controlle
 	return att.controller, att.lun, nil
 }
 
-func (am *attachManager) detach(ctx context.Context, controller, lun uint) (bool, error) {
+func (am *attachManager) detach(ctx context.Context, controller, lun uint, owner string) (bool, error) {
 	am.m.Lock()
 	defer am.m.Unlock()
 
@@ -99,6 +176,7 @@ func (am *attachManager) detach(ctx context.Context, controller, lun uint) (bool
 
 	att := am.slots[controller][lun]
 	att.refCount--
+	att.removeOwner(owner)
 	if att.refCount > 0 {
 		return false, nil
 	}
@@ -115,7 +193,9 @@ func (am *attachManager) detach(ctx context.Context, controller, lun uint) (bool
 	return true, nil
 }
 
-func (am *attachManager) trackAttachment(c *attachConfig) (*attachment, bool, error) {
+// trackAttachment returns the existing attachment of `c`, in any pool, or
+// tracks a new attachment in a free slot of `pool`, referenced by `owner`.
+func (am *attachManager) trackAttachment(c *attachConfig, pool Pool, owner string) (*attachment, bool, error) {
 	am.m.Lock()
 	defer am.m.Unlock()
 
@@ -123,11 +203,12 @@ func (am *attachManager) trackAttachment(c *attachConfig) (*attachment, bool, er
 		freeController = -1
 		freeLUN        = -1
 	)
+	start, end := am.controllers(pool)
 	for controller := range am.slots {
 		for lun := range am.slots[controller] {
 			attachment := am.slots[controller][lun]
 			if attachment == nil {
-				if freeController == -1 {
+				if freeController == -1 && controller >= start && controller < end {
 					freeController = controller
 					freeLUN = lun
 					// We don't break here, since we still might find an exact match for
@@ -135,13 +216,14 @@ func (am *attachManager) trackAttachment(c *attachConfig) (*attachment, bool, er
 				}
 			} else if reflect.DeepEqual(c, attachment.config) {
 				attachment.refCount++
+				attachment.addOwner(owner)
 				return attachment, true, nil
 			}
 		}
 	}
 
 	if freeController == -1 {
-		return nil, false, ErrNoAvailableLocation
+		return nil, false, am.noAvailableLocation(pool)
 	}
 
 	// New attachment.
@@ -152,6 +234,7 @@ func (am *attachManager) trackAttachment(c *attachConfig) (*attachment, bool, er
 		refCount:   1,
 		waitCh:     make(chan struct{}),
 	}
+	attachment.addOwner(owner)
 	am.slots[freeController][freeLUN] = attachment
 	return attachment, false, nil
 }
@@ -160,3 +243,64 @@ func (am *attachManager) trackAttachment(c *attachConfig) (*attachment, bool, er
 func (am *attachManager) untrackAttachment(attachment *attachment) {
 	am.slots[attachment.controller][attachment.lun] = nil
 }
+
+// noAvailableLocation returns the error for `pool` having no free slots, with
+// the use of its controllers.
+//
+// Caller must be holding am.m.
+func (am *attachManager) noAvailableLocation(pool Pool) *NoAvailableLocationError {
+	e := &NoAvailableLocationError{Pool: pool.String(), Needed: 1}
+	if am.layerControllers == 0 {
+		e.Pool = "shared"
+	}
+	start, end := am.controllers(pool)
+	for controller := start; controller < end; controller++ {
+		u := ControllerUsage{Controller: uint(controller), Slots: len(am.slots[controller])}
+		for _, att := range am.slots[controller] {
+			if att == nil {
+				continue
+			}
+			u.SlotsInUse++
+			for owner := range att.owners {
+				if u.Owners == nil {
+					u.Owners = make(map[string]int)
+				}
+				u.Owners[owner]++
+			}
+		}
+		e.Controllers = append(e.Controllers, u)
+	}
+	return e
+}
+
+// checkCapacity returns a [NoAvailableLocationError] if the devices at `paths`
+// cannot all be attached to the free slots of `pool`. Devices that are already
+// attached, in any pool, do not need a slot.
+func (am *attachManager) checkCapacity(pool Pool, paths []string) error {
+	am.m.Lock()
+	defer am.m.Unlock()
+
+	needed := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		needed[p] = struct{}{}
+	}
+	free := 0
+	start, end := am.controllers(pool)
+	for controller := range am.slots {
+		for _, att := range am.slots[controller] {
+			if att == nil {
+				if controller >= start && controller < end {
+					free++
+				}
+			} else if att.config != nil {
+				delete(needed, att.config.path)
+			}
+		}
+	}
+	if len(needed) > free {
+		e := am.noAvailableLocation(pool)
+		e.Needed = len(needed)
+		return e
+	}
+	return nil
+}
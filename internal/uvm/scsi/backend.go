@@ -101,7 +101,13 @@ func (bgb *bridgeGuestBackend) mount(ctx context.Context, controller, lun uint,
 	if err != nil {
 		return err
 	}
-	return bgb.gc.Modify(ctx, req)
+	if err := bgb.gc.Modify(ctx, req); err != nil {
+		if gcs.IsDiskCorrupt(err) {
+			return fmt.Errorf("%w: %w", ErrFilesystemCorrupt, err)
+		}
+		return err
+	}
+	return nil
 }
 
 func (bgb *bridgeGuestBackend) unmount(ctx context.Context, controller, lun uint, path string, config *mountConfig) error {
@@ -184,9 +190,9 @@ func mountRequest(controller, lun uint, path string, config *mountConfig, osType
 			return guestrequest.ModificationRequest{}, errors.New("WCOW only supports SCSI controller 0")
 		}
 		if config.encrypted || len(config.options) != 0 ||
-			config.ensureFilesystem || config.filesystem != "" || config.partition != 0 {
+			config.ensureFilesystem || config.filesystem != "" || config.partition != 0 || config.blockSize != 0 || config.formatOnAttach || config.initializeDisk || config.repairFilesystem {
 			return guestrequest.ModificationRequest{},
-				errors.New("WCOW does not support encrypted, verity, guest options, partitions, specifying mount filesystem, or ensuring filesystem on mounts")
+				errors.New("WCOW does not support encrypted, verity, guest options, partitions, block sizes, specifying mount filesystem, ensuring filesystem, formatting, initializing, or repairing filesystem on mounts")
 		}
 		req.Settings = guestresource.WCOWMappedVirtualDisk{
 			ContainerPath: path,
@@ -205,6 +211,12 @@ func mountRequest(controller, lun uint, path string, config *mountConfig, osType
 			EnsureFilesystem: config.ensureFilesystem,
 			Filesystem:       config.filesystem,
 			BlockDev:         config.blockDev,
+			BlockSize:        config.blockSize,
+			FormatOnAttach:   config.formatOnAttach,
+			FormatFilesystem: config.formatFilesystem,
+			ForceFormat:      config.forceFormat,
+			InitializeDisk:   config.initializeDisk,
+			RepairFilesystem: config.repairFilesystem,
 		}
 	default:
 		return guestrequest.ModificationRequest{}, fmt.Errorf("unsupported os type: %s", osType)
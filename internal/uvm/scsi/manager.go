@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"sync"
 
@@ -21,6 +23,11 @@ var (
 	// ErrAlreadyReleased is returned when [Mount.Release] is called on a Mount
 	// that had already been released.
 	ErrAlreadyReleased = errors.New("mount was already released")
+	// ErrFilesystemCorrupt is returned when a device cannot be mounted in the
+	// guest because its filesystem is corrupt, even after it was repaired if
+	// [MountConfig.RepairFilesystem] is set. The caller may recreate the
+	// device, such as a scratch disk, and try again.
+	ErrFilesystemCorrupt = errors.New("filesystem is corrupt")
 )
 
 // Manager is the primary entrypoint for managing SCSI devices on a VM.
@@ -29,6 +36,8 @@ var (
 type Manager struct {
 	attachManager *attachManager
 	mountManager  *mountManager
+	// onChange is called after devices are added or removed, if set.
+	onChange func(context.Context)
 }
 
 // Slot represents a single SCSI slot, consisting of a controller and LUN.
@@ -37,11 +46,133 @@ type Slot struct {
 	LUN        uint
 }
 
+// Pool is a set of SCSI controllers that the slots of new attachments are
+// allocated from. Pools only have separate controllers if the [Manager] was
+// created with layer controllers; otherwise all pools share all controllers.
+type Pool uint8
+
+const (
+	// PoolLayers is the pool for the disks of the VM and its containers, such
+	// as container layers and scratch disks. It is allocated from the first
+	// controllers, starting from controller 0.
+	PoolLayers Pool = iota
+	// PoolData is the pool for data disks mounted into containers. It is
+	// allocated from the controllers after those of [PoolLayers].
+	PoolData
+)
+
+func (p Pool) String() string {
+	switch p {
+	case PoolLayers:
+		return "layers"
+	case PoolData:
+		return "data"
+	default:
+		return fmt.Sprintf("Pool(%d)", uint8(p))
+	}
+}
+
+// PoolState is the allocation state of a pool of SCSI controllers, as
+// returned by [Manager.Pools].
+type PoolState struct {
+	// Name is the name of the pool, or "shared" if all pools share all
+	// controllers.
+	Name        string
+	Controllers []uint
+	// Slots is the number of slots on the controllers of the pool, of which
+	// SlotsInUse are attached or reserved.
+	Slots      int
+	SlotsInUse int
+	// Owners counts the attachments in the pool by the tag of their mounts,
+	// as in [ControllerUsage.Owners].
+	Owners map[string]int
+}
+
+// String formats `s` for diagnostics, such as "data: controllers [1 2 3], 2/192 slots in use".
+func (s PoolState) String() string {
+	return fmt.Sprintf("%s: controllers %v, %d/%d slots in use", s.Name, s.Controllers, s.SlotsInUse, s.Slots)
+}
+
+// NoAvailableLocationError is returned, wrapping [ErrNoAvailableLocation], when
+// new SCSI attachments fail because the slots of their pool are in use.
+type NoAvailableLocationError struct {
+	// Pool is the name of the exhausted pool, or "shared" if all pools share
+	// all controllers.
+	Pool string
+	// Needed is the number of free slots that were needed.
+	Needed int
+	// Controllers is the use of each controller of the pool.
+	Controllers []ControllerUsage
+}
+
+func (e *NoAvailableLocationError) Error() string {
+	var b strings.Builder
+	if e.Pool != "shared" && len(e.Controllers) > 0 {
+		fmt.Fprintf(&b, "%s pool (controllers %d to %d) is exhausted: ",
+			e.Pool, e.Controllers[0].Controller, e.Controllers[len(e.Controllers)-1].Controller)
+	}
+	b.WriteString(ErrNoAvailableLocation.Error())
+	if e.Needed > 1 {
+		fmt.Fprintf(&b, " for %d devices", e.Needed)
+	}
+	for i, c := range e.Controllers {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(c.String())
+	}
+	return b.String()
+}
+
+func (e *NoAvailableLocationError) Unwrap() error {
+	return ErrNoAvailableLocation
+}
+
+// ControllerUsage is the use of the slots of a SCSI controller, as reported by
+// [NoAvailableLocationError].
+type ControllerUsage struct {
+	Controller uint
+	// Slots is the number of slots on the controller, of which SlotsInUse are
+	// attached or reserved.
+	Slots      int
+	SlotsInUse int
+	// Owners counts the attachments on the controller by the tag of their
+	// mounts, such as a container ID (see [MountConfig.PathTag]). Attachments
+	// without a tag, such as those of the VM itself, are not counted.
+	Owners map[string]int
+}
+
+// String formats `c` for diagnostics, such as "controller 1: 64/64 slots in use (c1: 2, c2: 1)".
+func (c ControllerUsage) String() string {
+	s := fmt.Sprintf("controller %d: %d/%d slots in use", c.Controller, c.SlotsInUse, c.Slots)
+	if len(c.Owners) == 0 {
+		return s
+	}
+	owners := make([]string, 0, len(c.Owners))
+	for o := range c.Owners {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	for i, o := range owners {
+		owners[i] = fmt.Sprintf("%s: %d", o, c.Owners[o])
+	}
+	return s + " (" + strings.Join(owners, ", ") + ")"
+}
+
 // NewManager creates a new Manager using the provided host and guest backends,
 // as well as other configuration parameters.
 //
 // guestMountFmt is the format string to use for mounts of SCSI devices in
-// the guest OS. It should have a single %d format parameter.
+// the guest OS. It should have a %s format parameter for the mount's path tag
+// (see [MountConfig.PathTag]), followed by a %d format parameter for the value
+// of the mount counter, which is persisted by [Manager.State] and [Manager.Restore]
+// so that generated paths are never reused for the lifetime of the VM.
+//
+// layerControllers is the number of controllers, starting from controller 0,
+// reserved for [PoolLayers]. The rest of the controllers are reserved for
+// [PoolData]. If it is 0, both pools share all controllers.
 //
 // reservedSlots indicates which SCSI slots to treat as already used. They
 // will not be handed out again by the Manager.
@@ -50,15 +181,19 @@ func NewManager(
 	gb GuestBackend,
 	numControllers int,
 	numLUNsPerController int,
+	layerControllers int,
 	guestMountFmt string,
 	reservedSlots []Slot,
 ) (*Manager, error) {
 	if hb == nil || gb == nil {
 		return nil, errors.New("host and guest backend must not be nil")
 	}
-	am := newAttachManager(hb, gb, numControllers, numLUNsPerController, reservedSlots)
-	mm := newMountManager(gb, guestMountFmt)
-	return &Manager{am, mm}, nil
+	if layerControllers < 0 || (layerControllers > 0 && layerControllers >= numControllers) {
+		return nil, fmt.Errorf("layer controller count %d must be less than the controller count %d", layerControllers, numControllers)
+	}
+	am := newAttachManager(hb, gb, numControllers, numLUNsPerController, layerControllers, reservedSlots)
+	mm := newMountManager(gb, guestMountFmt, numControllers)
+	return &Manager{attachManager: am, mountManager: mm}, nil
 }
 
 // MountConfig specifies the options to apply for mounting a SCSI device in
@@ -86,9 +221,44 @@ type MountConfig struct {
 	// BlockDev indicates if the device should be mounted as a block device.
 	// This is only supported for LCOW.
 	BlockDev bool
+	// BlockSize is the logical block size to set on the device in the guest,
+	// if non-zero.
+	// This is only supported for LCOW.
+	BlockSize uint32
+	// FormatOnAttach indicates to format a blank device as `FormatFilesystem`
+	// (or `Filesystem`, or ext4) before mounting it. A device that already
+	// has data is only formatted if `ForceFormat` is also set.
+	// This is only supported for LCOW.
+	FormatOnAttach   bool
+	FormatFilesystem string
+	ForceFormat      bool
+	// InitializeDisk indicates to create a GPT with a single partition
+	// spanning a blank device, and format the partition as
+	// `FormatFilesystem` (or `Filesystem`, or ext4), before mounting it.
+	// `Partition` must be 1. A device that already has a partition table or
+	// filesystem is only initialized if `ForceFormat` is also set. A device
+	// initialized by an earlier mount keeps its data, so `ForceFormat` only
+	// applies to the first mount.
+	// This is only supported for LCOW.
+	InitializeDisk bool
+	// RepairFilesystem indicates to run fsck on a device whose filesystem is
+	// corrupt, and mount it again, instead of failing with
+	// [ErrFilesystemCorrupt].
+	// This is only supported for LCOW.
+	RepairFilesystem bool
 	// FormatWithRefs indicates to refs format the disk.
 	// This is only supported for CWCOW scratch disks.
 	FormatWithRefs bool
+	// Pool is the pool of SCSI controllers that the device is attached to, if
+	// it is not already attached. Devices attached without a MountConfig are
+	// attached to [PoolLayers].
+	Pool Pool
+	// PathTag is a short tag identifying the owner or purpose of the mount, such
+	// as a container ID, that is included in the guest path generated for the
+	// mount. It is ignored if a guest path is given, or if an existing mount is
+	// reused. Characters other than letters, digits, '-', and '_' are replaced,
+	// and it is truncated to 12 characters.
+	PathTag string
 }
 
 // Mount represents a SCSI device that has been attached to a VM, and potentially
@@ -98,6 +268,7 @@ type Mount struct {
 	controller  uint
 	lun         uint
 	guestPath   string
+	owner       string
 	releaseOnce sync.Once
 }
 
@@ -124,7 +295,7 @@ func (m *Mount) GuestPath() string {
 func (m *Mount) Release(ctx context.Context) (err error) {
 	err = ErrAlreadyReleased
 	m.releaseOnce.Do(func() {
-		err = m.mgr.remove(ctx, m.controller, m.lun, m.guestPath)
+		err = m.mgr.remove(ctx, m.controller, m.lun, m.guestPath, m.owner)
 	})
 	return
 }
@@ -165,6 +336,12 @@ func (m *Manager) AddVirtualDisk(
 			ensureFilesystem: mc.EnsureFilesystem,
 			filesystem:       mc.Filesystem,
 			blockDev:         mc.BlockDev,
+			blockSize:        mc.BlockSize,
+			formatOnAttach:   mc.FormatOnAttach,
+			formatFilesystem: mc.FormatFilesystem,
+			forceFormat:      mc.ForceFormat,
+			initializeDisk:   mc.InitializeDisk,
+			repairFilesystem: mc.RepairFilesystem,
 			formatWithRefs:   mc.FormatWithRefs,
 		}
 	}
@@ -175,6 +352,8 @@ func (m *Manager) AddVirtualDisk(
 			typ:      "VirtualDisk",
 		},
 		guestPath,
+		pathTag(mc),
+		pool(mc),
 		mcInternal)
 }
 
@@ -214,6 +393,12 @@ func (m *Manager) AddPhysicalDisk(
 			ensureFilesystem: mc.EnsureFilesystem,
 			filesystem:       mc.Filesystem,
 			blockDev:         mc.BlockDev,
+			blockSize:        mc.BlockSize,
+			formatOnAttach:   mc.FormatOnAttach,
+			formatFilesystem: mc.FormatFilesystem,
+			forceFormat:      mc.ForceFormat,
+			initializeDisk:   mc.InitializeDisk,
+			repairFilesystem: mc.RepairFilesystem,
 		}
 	}
 	return m.add(ctx,
@@ -223,6 +408,8 @@ func (m *Manager) AddPhysicalDisk(
 			typ:      "PassThru",
 		},
 		guestPath,
+		pathTag(mc),
+		pool(mc),
 		mcInternal)
 }
 
@@ -261,6 +448,12 @@ func (m *Manager) AddExtensibleVirtualDisk(
 			ensureFilesystem: mc.EnsureFilesystem,
 			filesystem:       mc.Filesystem,
 			blockDev:         mc.BlockDev,
+			blockSize:        mc.BlockSize,
+			formatOnAttach:   mc.FormatOnAttach,
+			formatFilesystem: mc.FormatFilesystem,
+			forceFormat:      mc.ForceFormat,
+			initializeDisk:   mc.InitializeDisk,
+			repairFilesystem: mc.RepairFilesystem,
 		}
 	}
 	return m.add(ctx,
@@ -271,44 +464,120 @@ func (m *Manager) AddExtensibleVirtualDisk(
 			evdType:  evdType,
 		},
 		guestPath,
+		pathTag(mc),
+		pool(mc),
 		mcInternal)
 }
 
-func (m *Manager) add(ctx context.Context, attachConfig *attachConfig, guestPath string, mountConfig *mountConfig) (_ *Mount, err error) {
-	controller, lun, err := m.attachManager.attach(ctx, attachConfig)
+// DumpState writes the guest OS mounts tracked by the Manager to w as JSON,
+// for diagnosing mounts that leaked or never completed.
+func (m *Manager) DumpState(w io.Writer) error {
+	if m == nil {
+		return ErrNotInitialized
+	}
+	return m.mountManager.DumpState(w)
+}
+
+// Pools returns the allocation state of the pools of SCSI controllers, for
+// diagnostics.
+func (m *Manager) Pools() ([]PoolState, error) {
+	if m == nil {
+		return nil, ErrNotInitialized
+	}
+	return m.attachManager.pools(), nil
+}
+
+// CheckCapacity returns a [NoAvailableLocationError] if the devices at
+// `hostPaths`, as passed to the Add methods, cannot all be attached to the
+// free slots of `pool`, so that a caller attaching several devices can fail
+// before attaching any of them. Devices that are already attached do not need
+// a slot. Attachments made concurrently can still exhaust the pool.
+func (m *Manager) CheckCapacity(pool Pool, hostPaths []string) error {
+	if m == nil {
+		return ErrNotInitialized
+	}
+	paths := make([]string, 0, len(hostPaths))
+	for _, p := range hostPaths {
+		if strings.HasPrefix(p, "evd://") {
+			_, mountPath, err := parseExtensibleVirtualDiskPath(p)
+			if err != nil {
+				return err
+			}
+			p = mountPath
+		}
+		paths = append(paths, p)
+	}
+	return m.attachManager.checkCapacity(pool, paths)
+}
+
+func (m *Manager) add(ctx context.Context, attachConfig *attachConfig, guestPath, tag string, pool Pool, mountConfig *mountConfig) (_ *Mount, err error) {
+	controller, lun, err := m.attachManager.attach(ctx, attachConfig, pool, tag)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
 		if err != nil {
-			_, _ = m.attachManager.detach(ctx, controller, lun)
+			_, _ = m.attachManager.detach(ctx, controller, lun, tag)
 		}
 	}()
 
 	if mountConfig != nil {
-		guestPath, err = m.mountManager.mount(ctx, controller, lun, guestPath, mountConfig)
+		guestPath, err = m.mountManager.mount(ctx, controller, lun, guestPath, tag, mountConfig)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return &Mount{mgr: m, controller: controller, lun: lun, guestPath: guestPath}, nil
+	m.changed(ctx)
+	return &Mount{mgr: m, controller: controller, lun: lun, guestPath: guestPath, owner: tag}, nil
 }
 
-func (m *Manager) remove(ctx context.Context, controller, lun uint, guestPath string) error {
+func (m *Manager) remove(ctx context.Context, controller, lun uint, guestPath, owner string) error {
+	// a failed removal may still have unmounted the device
+	defer m.changed(ctx)
+
 	if guestPath != "" {
 		if err := m.mountManager.unmount(ctx, guestPath); err != nil {
 			return err
 		}
 	}
 
-	if _, err := m.attachManager.detach(ctx, controller, lun); err != nil {
+	if _, err := m.attachManager.detach(ctx, controller, lun, owner); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// OnChange sets `f` to be called after devices are added to or removed from the VM,
+// such as to save the [Manager.State]. It is called without any locks of the Manager
+// held, and must be set before the Manager is used.
+func (m *Manager) OnChange(f func(context.Context)) {
+	m.onChange = f
+}
+
+func (m *Manager) changed(ctx context.Context) {
+	if m.onChange != nil {
+		m.onChange(ctx)
+	}
+}
+
+// pathTag returns the path tag of `mc`, if any.
+func pathTag(mc *MountConfig) string {
+	if mc == nil {
+		return ""
+	}
+	return mc.PathTag
+}
+
+// pool returns the pool of `mc`, or [PoolLayers] if it is nil.
+func pool(mc *MountConfig) Pool {
+	if mc == nil {
+		return PoolLayers
+	}
+	return mc.Pool
+}
+
 // parseExtensibleVirtualDiskPath parses the evd path provided in the config.
 // extensible virtual disk path has format "evd://<evdType>/<evd-mount-path>"
 // this function parses that and returns the `evdType` and `evd-mount-path`.
@@ -3,9 +3,16 @@
 package scsi
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func removeIndex[T any](a []T, i int) []T {
@@ -121,10 +128,12 @@ func TestAddAddRemoveRemove(t *testing.T) {
 
 	hb := &hostBackend{}
 	gb := &guestBackend{}
-	mgr, err := NewManager(hb, gb, 4, 64, "/var/run/scsi/%d", nil)
+	mgr, err := NewManager(hb, gb, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	changes := 0
+	mgr.OnChange(func(context.Context) { changes++ })
 	m1, err := mgr.AddVirtualDisk(ctx, "path", true, "", "", &MountConfig{})
 	if err != nil {
 		t.Fatal(err)
@@ -151,6 +160,9 @@ func TestAddAddRemoveRemove(t *testing.T) {
 	if !reflect.DeepEqual(hb.attachmentPaths(), []string{}) {
 		t.Errorf("wrong attachment paths after remove: %v", hb.attachmentPaths())
 	}
+	if changes != 4 {
+		t.Errorf("expected a change for each add and remove, got %d", changes)
+	}
 }
 
 func TestGuestPath(t *testing.T) {
@@ -158,7 +170,7 @@ func TestGuestPath(t *testing.T) {
 
 	hb := &hostBackend{}
 	gb := &guestBackend{}
-	mgr, err := NewManager(hb, gb, 4, 64, "/var/run/scsi/%d", nil)
+	mgr, err := NewManager(hb, gb, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -224,7 +236,7 @@ func TestConflictingGuestPath(t *testing.T) {
 
 	hb := &hostBackend{}
 	gb := &guestBackend{}
-	mgr, err := NewManager(hb, gb, 4, 64, "/var/run/scsi/%d", nil)
+	mgr, err := NewManager(hb, gb, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -237,3 +249,510 @@ func TestConflictingGuestPath(t *testing.T) {
 		t.Fatalf("expected error but got none")
 	}
 }
+
+func TestDumpState(t *testing.T) {
+	ctx := context.Background()
+
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m1, err := mgr.AddVirtualDisk(ctx, "path1", true, "", "", &MountConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.AddVirtualDisk(ctx, "path2", false, "", "", &MountConfig{Options: []string{"rw"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m1.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := mgr.DumpState(&b); err != nil {
+		t.Fatal(err)
+	}
+	var states []*mountState
+	if err := json.Unmarshal(b.Bytes(), &states); err != nil {
+		t.Fatalf("failed to unmarshal state %q: %v", b.String(), err)
+	}
+	expected := []*mountState{
+		nil,
+		{
+			Path:       "/var/run/scsi/m-2",
+			Controller: 0,
+			LUN:        1,
+			RefCount:   1,
+			Config:     &mountConfigState{Options: []string{"rw"}},
+		},
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Fatalf("expected state [null, %+v], got %s", *expected[1], b.String())
+	}
+
+	if err := (*Manager)(nil).DumpState(&b); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("expected %v, got %v", ErrNotInitialized, err)
+	}
+}
+
+func TestGeneratedGuestPath(t *testing.T) {
+	ctx := context.Background()
+
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m1, err := mgr.AddVirtualDisk(ctx, "path1", true, "", "", &MountConfig{PathTag: "0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1.GuestPath() != "/var/run/scsi/0123456789ab-1" {
+		t.Errorf("wrong guest path for m1: %s", m1.GuestPath())
+	}
+	if err := m1.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The counter is not reused after m1 is released.
+	m2, err := mgr.AddVirtualDisk(ctx, "path1", true, "", "", &MountConfig{PathTag: "../layer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m2.GuestPath() != "/var/run/scsi/___layer-2" {
+		t.Errorf("wrong guest path for m2: %s", m2.GuestPath())
+	}
+
+	// A generated path that is already in use is skipped.
+	if _, err := mgr.AddVirtualDisk(ctx, "path2", true, "", "/var/run/scsi/m-3", &MountConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	m3, err := mgr.AddVirtualDisk(ctx, "path3", true, "", "", &MountConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m3.GuestPath() != "/var/run/scsi/m-4" {
+		t.Errorf("wrong guest path for m3: %s", m3.GuestPath())
+	}
+}
+
+func TestRestoreMountCounter(t *testing.T) {
+	ctx := context.Background()
+
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Restore(&State{MountCounter: 16}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mgr.AddVirtualDisk(ctx, "path", true, "", "", &MountConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.GuestPath() != "/var/run/scsi/m-17" {
+		t.Errorf("wrong guest path: %s", m.GuestPath())
+	}
+	s, err := mgr.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.MountCounter != 17 {
+		t.Errorf("expected mount counter 17, got %d", s.MountCounter)
+	}
+}
+
+func TestPools(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := NewManager(&hostBackend{}, &guestBackend{}, 2, 2, 2, "/var/run/scsi/%s-%d", nil); err == nil {
+		t.Fatal("expected error for layer controllers without data controllers")
+	}
+
+	hb := &hostBackend{}
+	mgr, err := NewManager(hb, &guestBackend{}, 3, 2, 1, "/var/run/scsi/%s-%d", []Slot{{Controller: 0, LUN: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The layers pool has a single free slot.
+	layer, err := mgr.AddVirtualDisk(ctx, "layer1", true, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.Controller() != 0 || layer.LUN() != 1 {
+		t.Fatalf("expected layer at controller 0 lun 1, got controller %d lun %d", layer.Controller(), layer.LUN())
+	}
+	if _, err := mgr.AddVirtualDisk(ctx, "layer2", true, "", "", &MountConfig{}); !errors.Is(err, ErrNoAvailableLocation) {
+		t.Fatalf("expected %v, got %v", ErrNoAvailableLocation, err)
+	} else if !strings.Contains(err.Error(), "layers pool") {
+		t.Fatalf("expected error to name the layers pool, got %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		m, err := mgr.AddVirtualDisk(ctx, fmt.Sprintf("data%d", i), false, "", "", &MountConfig{Pool: PoolData})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Controller() != uint(1+i/2) || m.LUN() != uint(i%2) {
+			t.Fatalf("expected data%d at controller %d lun %d, got controller %d lun %d", i, 1+i/2, i%2, m.Controller(), m.LUN())
+		}
+	}
+	if _, err := mgr.AddPhysicalDisk(ctx, "data4", false, "", "", &MountConfig{Pool: PoolData}); !errors.Is(err, ErrNoAvailableLocation) {
+		t.Fatalf("expected %v, got %v", ErrNoAvailableLocation, err)
+	} else if !strings.Contains(err.Error(), "data pool (controllers 1 to 2)") {
+		t.Fatalf("expected error to name the data pool, got %v", err)
+	}
+	// An attachment is reused from another pool.
+	m, err := mgr.AddVirtualDisk(ctx, "layer1", true, "", "", &MountConfig{Pool: PoolData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Controller() != 0 || m.LUN() != 1 {
+		t.Fatalf("expected reused attachment at controller 0 lun 1, got controller %d lun %d", m.Controller(), m.LUN())
+	}
+	if len(hb.attachments) != 5 {
+		t.Fatalf("expected 5 attachments, got %v", hb.attachmentPaths())
+	}
+
+	pools, err := mgr.Pools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []PoolState{
+		{Name: "layers", Controllers: []uint{0}, Slots: 2, SlotsInUse: 2},
+		{Name: "data", Controllers: []uint{1, 2}, Slots: 4, SlotsInUse: 4},
+	}
+	if !reflect.DeepEqual(pools, expected) {
+		t.Fatalf("expected pools %+v, got %+v", expected, pools)
+	}
+	if s := pools[1].String(); s != "data: controllers [1 2], 4/4 slots in use" {
+		t.Fatalf("wrong pool string: %s", s)
+	}
+
+	shared, err := NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pools, err = shared.Pools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []PoolState{{Name: "shared", Controllers: []uint{0, 1, 2, 3}, Slots: 256}}
+	if !reflect.DeepEqual(pools, expected) {
+		t.Fatalf("expected pools %+v, got %+v", expected, pools)
+	}
+
+	if _, err := (*Manager)(nil).Pools(); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("expected %v, got %v", ErrNotInitialized, err)
+	}
+}
+
+func TestNoAvailableLocationError(t *testing.T) {
+	ctx := context.Background()
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 2, 2, 1, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data1, err := mgr.AddVirtualDisk(ctx, "data1", false, "", "", &MountConfig{Pool: PoolData, PathTag: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same disk mounted by another container shares the attachment
+	if _, err := mgr.AddVirtualDisk(ctx, "data1", false, "", "/shared", &MountConfig{Pool: PoolData, PathTag: "c2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.AddVirtualDisk(ctx, "data2", false, "", "", &MountConfig{Pool: PoolData, PathTag: "c2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mgr.AddVirtualDisk(ctx, "data3", false, "", "", &MountConfig{Pool: PoolData, PathTag: "c3"})
+	var e *NoAvailableLocationError
+	if !errors.As(err, &e) || !errors.Is(err, ErrNoAvailableLocation) {
+		t.Fatalf("expected %T, got %v", e, err)
+	}
+	expected := &NoAvailableLocationError{
+		Pool:   "data",
+		Needed: 1,
+		Controllers: []ControllerUsage{
+			{Controller: 1, Slots: 2, SlotsInUse: 2, Owners: map[string]int{"c1": 1, "c2": 2}},
+		},
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Fatalf("expected error %+v, got %+v", expected, e)
+	}
+	if s := err.Error(); s != "data pool (controllers 1 to 1) is exhausted: no available location: controller 1: 2/2 slots in use (c1: 1, c2: 2)" {
+		t.Fatalf("wrong error string: %s", s)
+	}
+	pools, err := mgr.Pools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pools[1].Owners, map[string]int{"c1": 1, "c2": 2}) {
+		t.Fatalf("unexpected owners of the data pool: %v", pools[1].Owners)
+	}
+
+	// owners are removed as their mounts are released
+	if err := data1.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = mgr.CheckCapacity(PoolData, []string{"data1", "data3", "data4"})
+	if !errors.As(err, &e) {
+		t.Fatalf("expected %T, got %v", e, err)
+	}
+	if e.Needed != 2 || !reflect.DeepEqual(e.Controllers[0].Owners, map[string]int{"c2": 2}) {
+		t.Fatalf("unexpected error %+v", e)
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	ctx := context.Background()
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 1, 3, 0, "/var/run/scsi/%s-%d", []Slot{{Controller: 0, LUN: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.AddVirtualDisk(ctx, "data1", false, "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name  string
+		paths []string
+		err   bool
+	}{
+		{name: "none"},
+		{name: "free slot", paths: []string{"data2"}},
+		{name: "attached", paths: []string{"data1", "data2"}},
+		{name: "duplicate", paths: []string{"data2", "data2"}},
+		{name: "extensible virtual disk", paths: []string{"evd://space/data1", "data2"}},
+		{name: "too many", paths: []string{"data2", "data3"}, err: true},
+		{name: "invalid extensible virtual disk", paths: []string{"evd://space"}, err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := mgr.CheckCapacity(PoolData, tc.paths); tc.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+		})
+	}
+	if err := (*Manager)(nil).CheckCapacity(PoolData, nil); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("expected %v, got %v", ErrNotInitialized, err)
+	}
+}
+
+// delayMounter is a thread-safe [mounter] that takes delay to complete each operation, to
+// simulate the latency of a guest request.
+type delayMounter struct {
+	delay time.Duration
+}
+
+func (dm *delayMounter) mount(ctx context.Context, controller uint, lun uint, path string, config *mountConfig) error {
+	time.Sleep(dm.delay)
+	return nil
+}
+
+func (dm *delayMounter) unmount(ctx context.Context, controller uint, lun uint, path string, config *mountConfig) error {
+	time.Sleep(dm.delay)
+	return nil
+}
+
+// BenchmarkConcurrentMountUnmount mounts and unmounts a device on each of 64 controllers
+// concurrently, which only contend on the global lock to allocate each mount.
+func BenchmarkConcurrentMountUnmount(b *testing.B) {
+	const numControllers = 64
+	ctx := context.Background()
+	mm := newMountManager(&delayMounter{delay: 50 * time.Microsecond}, "/var/run/scsi/%s-%d", numControllers)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		errs := make(chan error, numControllers)
+		for c := uint(0); c < numControllers; c++ {
+			wg.Add(1)
+			go func(c uint) {
+				defer wg.Done()
+				path, err := mm.mount(ctx, c, 0, "", fmt.Sprintf("c%d", c), &mountConfig{})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if err := mm.unmount(ctx, path); err != nil {
+					errs <- err
+				}
+			}(c)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			b.Fatal(err)
+		}
+	}
+}
+
+// stressMounter is a thread-safe [mounter] that fails to mount over, or unmount, a path
+// that is not in the expected state, and counts the guest requests.
+type stressMounter struct {
+	mu       sync.Mutex
+	mounted  map[string]bool
+	mounts   int
+	unmounts int
+}
+
+func (sm *stressMounter) mount(ctx context.Context, controller uint, lun uint, path string, config *mountConfig) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.mounted[path] {
+		return fmt.Errorf("%s is already mounted", path)
+	}
+	sm.mounted[path] = true
+	sm.mounts++
+	return nil
+}
+
+func (sm *stressMounter) unmount(ctx context.Context, controller uint, lun uint, path string, config *mountConfig) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !sm.mounted[path] {
+		return fmt.Errorf("%s is not mounted", path)
+	}
+	delete(sm.mounted, path)
+	sm.unmounts++
+	return nil
+}
+
+// checkNoMounts fails if mm still tracks any mounts.
+func checkNoMounts(t *testing.T, mm *mountManager) {
+	t.Helper()
+	for _, m := range mm.mounts {
+		if m != nil {
+			t.Errorf("mount %s is still tracked", m.path)
+		}
+	}
+	for i := range mm.controllers {
+		if n := len(mm.controllers[i].mounts); n != 0 {
+			t.Errorf("controller %d still tracks %d mounts", i, n)
+		}
+	}
+}
+
+const (
+	stressGoroutines = 32
+	stressCycles     = 100
+)
+
+// Test_UVM_SCSI_MountUnmount_ConcurrentStress mounts and unmounts partitions of the same
+// device at different paths concurrently. Run with -race to detect data races in
+// mountManager.
+func Test_UVM_SCSI_MountUnmount_ConcurrentStress(t *testing.T) {
+	ctx := context.Background()
+	sm := &stressMounter{mounted: make(map[string]bool)}
+	mm := newMountManager(sm, "/var/run/scsi/%s-%d", 4)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressGoroutines)
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			want := fmt.Sprintf("/run/stress/%d", g)
+			for i := 0; i < stressCycles; i++ {
+				path, err := mm.mount(ctx, 1, 2, want, "", &mountConfig{partition: uint64(g + 1)})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if path != want {
+					errs <- fmt.Errorf("mounted at %s, expected %s", path, want)
+					return
+				}
+				if err := mm.unmount(ctx, path); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if sm.mounts != stressGoroutines*stressCycles || sm.unmounts != sm.mounts {
+		t.Errorf("expected %d guest mounts and unmounts, got %d and %d", stressGoroutines*stressCycles, sm.mounts, sm.unmounts)
+	}
+	checkNoMounts(t, mm)
+}
+
+// Test_UVM_SCSI_MountUnmount_ConcurrentStress_SameMount mounts the same device with the same
+// config concurrently, which must be deduplicated into a single guest mount.
+func Test_UVM_SCSI_MountUnmount_ConcurrentStress_SameMount(t *testing.T) {
+	ctx := context.Background()
+	sm := &stressMounter{mounted: make(map[string]bool)}
+	mm := newMountManager(sm, "/var/run/scsi/%s-%d", 4)
+
+	var wg sync.WaitGroup
+	paths := make(chan string, stressGoroutines*stressCycles)
+	errs := make(chan error, stressGoroutines)
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < stressCycles; i++ {
+				path, err := mm.mount(ctx, 1, 2, "", "", &mountConfig{readOnly: true, options: []string{"ro", "noexec"}})
+				if err != nil {
+					errs <- err
+					return
+				}
+				paths <- path
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	close(paths)
+
+	var path string
+	for p := range paths {
+		if path == "" {
+			path = p
+		} else if p != path {
+			t.Fatalf("device mounted at both %s and %s", path, p)
+		}
+	}
+	if sm.mounts != 1 {
+		t.Fatalf("expected 1 guest mount, got %d", sm.mounts)
+	}
+	cm := &mm.controllers[1]
+	if len(cm.mounts) != 1 {
+		t.Fatalf("expected 1 tracked mount, got %d", len(cm.mounts))
+	}
+	if n := cm.mounts[0].refCount.Load(); n != stressGoroutines*stressCycles {
+		t.Fatalf("expected refcount %d, got %d", stressGoroutines*stressCycles, n)
+	}
+
+	errs = make(chan error, stressGoroutines)
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < stressCycles; i++ {
+				if err := mm.unmount(ctx, path); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if sm.unmounts != 1 {
+		t.Errorf("expected 1 guest unmount, got %d", sm.unmounts)
+	}
+	checkNoMounts(t, mm)
+}
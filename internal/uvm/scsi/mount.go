@@ -4,12 +4,32 @@ package scsi
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+const (
+	// defaultPathTag is the tag used in generated guest paths of mounts without a
+	// [MountConfig.PathTag].
+	defaultPathTag = "m"
+	// maxPathTagLength is the length path tags are truncated to, which is enough to
+	// hold the short form of a container ID.
+	maxPathTagLength = 12
+)
+
+// mountManager tracks the guest mounts of SCSI devices.
+//
+// Mounts are looked up, and mounted or unmounted in the guest, under the lock of the
+// device's controller, so operations on different controllers do not wait on each other.
+// The global lock m is only held to allocate mount indexes and guest paths, and is always
+// acquired after a controller lock.
 type mountManager struct {
 	m       sync.Mutex
 	mounter mounter
@@ -17,12 +37,24 @@ type mountManager struct {
 	// available for use.
 	mounts   []*mount
 	mountFmt string
+	// counter is the number of the last generated guest path. It is never decremented, so a
+	// generated path is not reused for the lifetime of the VM.
+	counter uint64
+	// controllers tracks the mounts of the devices on each controller, indexed by controller.
+	controllers []controllerMounts
+}
+
+// controllerMounts tracks the mounts of the devices on a single SCSI controller.
+type controllerMounts struct {
+	mu     sync.RWMutex
+	mounts []*mount
 }
 
-func newMountManager(mounter mounter, mountFmt string) *mountManager {
+func newMountManager(mounter mounter, mountFmt string, numControllers int) *mountManager {
 	return &mountManager{
-		mounter:  mounter,
-		mountFmt: mountFmt,
+		mounter:     mounter,
+		mountFmt:    mountFmt,
+		controllers: make([]controllerMounts, numControllers),
 	}
 }
 
@@ -34,7 +66,9 @@ type mount struct {
 	config     *mountConfig
 	waitErr    error
 	waitCh     chan struct{}
-	refCount   uint
+	// refCount is incremented while holding the read lock of the controller, and decremented
+	// while holding its write lock.
+	refCount atomic.Uint32
 }
 
 type mountConfig struct {
@@ -42,20 +76,75 @@ type mountConfig struct {
 	readOnly         bool
 	encrypted        bool
 	blockDev         bool
+	blockSize        uint32
 	options          []string
 	ensureFilesystem bool
 	filesystem       string
 	formatWithRefs   bool
+	formatOnAttach   bool
+	formatFilesystem string
+	forceFormat      bool
+	initializeDisk   bool
+	repairFilesystem bool
 }
 
-func (mm *mountManager) mount(ctx context.Context, controller, lun uint, path string, c *mountConfig) (_ string, err error) {
+// String returns a compact representation of the config for logs and errors,
+// such as `fs=ext4 ro=true enc=true partition=1 options=[noatime,lazytime]`.
+// The read-only and encryption settings are always included, other settings
+// only when they are set.
+func (c *mountConfig) String() string {
+	if c == nil {
+		return "<nil>"
+	}
+	var b strings.Builder
+	if c.filesystem != "" {
+		fmt.Fprintf(&b, "fs=%s ", c.filesystem)
+	}
+	fmt.Fprintf(&b, "ro=%t enc=%t", c.readOnly, c.encrypted)
+	if c.partition != 0 {
+		fmt.Fprintf(&b, " partition=%d", c.partition)
+	}
+	if c.blockDev {
+		b.WriteString(" blockdev=true")
+	}
+	if c.blockSize != 0 {
+		fmt.Fprintf(&b, " blocksize=%d", c.blockSize)
+	}
+	if c.ensureFilesystem {
+		b.WriteString(" ensurefs=true")
+	}
+	if c.formatOnAttach {
+		b.WriteString(" format=true")
+	}
+	if c.formatFilesystem != "" {
+		fmt.Fprintf(&b, " formatfs=%s", c.formatFilesystem)
+	}
+	if c.forceFormat {
+		b.WriteString(" forceformat=true")
+	}
+	if c.initializeDisk {
+		b.WriteString(" initdisk=true")
+	}
+	if c.formatWithRefs {
+		b.WriteString(" refs=true")
+	}
+	if c.repairFilesystem {
+		b.WriteString(" repair=true")
+	}
+	if len(c.options) != 0 {
+		fmt.Fprintf(&b, " options=[%s]", strings.Join(c.options, ","))
+	}
+	return b.String()
+}
+
+func (mm *mountManager) mount(ctx context.Context, controller, lun uint, path, tag string, c *mountConfig) (_ string, err error) {
 	// Normalize the mount config for comparison.
 	// Config equality relies on the options slices being compared element-wise. Sort the options
 	// slice first so that two slices with different ordering compare as equal. We assume that
 	// order will never matter for mount options.
 	sort.Strings(c.options)
 
-	mount, existed, err := mm.trackMount(controller, lun, path, c)
+	mount, existed, err := mm.trackMount(controller, lun, path, tag, c)
 	if err != nil {
 		return "", err
 	}
@@ -73,9 +162,10 @@ func (mm *mountManager) mount(ctx context.Context, controller, lun uint, path st
 
 	defer func() {
 		if err != nil {
-			mm.m.Lock()
+			cm := &mm.controllers[controller]
+			cm.mu.Lock()
 			mm.untrackMount(mount)
-			mm.m.Unlock()
+			cm.mu.Unlock()
 		}
 
 		mount.waitErr = err
@@ -83,36 +173,90 @@ func (mm *mountManager) mount(ctx context.Context, controller, lun uint, path st
 	}()
 
 	if err := mm.mounter.mount(ctx, controller, lun, mount.path, c); err != nil {
-		return "", fmt.Errorf("mount scsi controller %d lun %d at %s: %w", controller, lun, mount.path, err)
+		return "", fmt.Errorf("mount scsi controller %d lun %d at %s with %s: %w", controller, lun, mount.path, c, err)
 	}
 	return mount.path, nil
 }
 
 func (mm *mountManager) unmount(ctx context.Context, path string) error {
 	mm.m.Lock()
-	defer mm.m.Unlock()
-
 	var mount *mount
-	for _, mount = range mm.mounts {
-		if mount != nil && mount.path == path {
+	for _, m := range mm.mounts {
+		if m != nil && m.path == path {
+			mount = m
 			break
 		}
 	}
+	mm.m.Unlock()
+	if mount == nil {
+		return fmt.Errorf("unmount %s: no scsi mount at path", path)
+	}
+
+	// The caller holds a reference to the mount, so it cannot be untracked before the
+	// controller lock is acquired.
+	cm := &mm.controllers[mount.controller]
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
-	mount.refCount--
-	if mount.refCount > 0 {
+	if mount.refCount.Add(^uint32(0)) > 0 {
 		return nil
 	}
 
 	if err := mm.mounter.unmount(ctx, mount.controller, mount.lun, mount.path, mount.config); err != nil {
-		return fmt.Errorf("unmount scsi controller %d lun %d at path %s: %w", mount.controller, mount.lun, mount.path, err)
+		return fmt.Errorf("unmount scsi controller %d lun %d at path %s with %s: %w", mount.controller, mount.lun, mount.path, mount.config, err)
 	}
 	mm.untrackMount(mount)
 
 	return nil
 }
 
-func (mm *mountManager) trackMount(controller, lun uint, path string, c *mountConfig) (*mount, bool, error) {
+func (mm *mountManager) trackMount(controller, lun uint, path, tag string, c *mountConfig) (*mount, bool, error) {
+	cm := &mm.controllers[controller]
+
+	// Most mounts of a device reuse an existing mount, which only needs the read lock.
+	cm.mu.RLock()
+	mount := cm.find(lun, c)
+	if mount != nil {
+		mount.refCount.Add(1)
+	}
+	cm.mu.RUnlock()
+	if mount != nil {
+		return mount, true, nil
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	// The mount may have been tracked since the read lock was released.
+	if mount := cm.find(lun, c); mount != nil {
+		mount.refCount.Add(1)
+		return mount, true, nil
+	}
+	mount, err := mm.allocateMount(controller, lun, path, tag, c)
+	if err != nil {
+		return nil, false, err
+	}
+	cm.mounts = append(cm.mounts, mount)
+	return mount, false, nil
+}
+
+// find returns the mount of the device at `lun` with config `c`, if any.
+//
+// Caller must be holding cm.mu.
+func (cm *controllerMounts) find(lun uint, c *mountConfig) *mount {
+	for _, mount := range cm.mounts {
+		if lun == mount.lun && reflect.DeepEqual(c, mount.config) {
+			return mount
+		}
+	}
+	return nil
+}
+
+// allocateMount creates a new mount, allocating its index and, if `path` is empty,
+// its guest path.
+//
+// Caller must be holding the write lock of the mount's controller.
+func (mm *mountManager) allocateMount(controller, lun uint, path, tag string, c *mountConfig) (*mount, error) {
 	mm.m.Lock()
 	defer mm.m.Unlock()
 
@@ -122,14 +266,8 @@ func (mm *mountManager) trackMount(controller, lun uint, path string, c *mountCo
 			if freeIndex == -1 {
 				freeIndex = i
 			}
-		} else if controller == mount.controller &&
-			lun == mount.lun &&
-			reflect.DeepEqual(c, mount.config) {
-
-			mount.refCount++
-			return mount, true, nil
 		} else if path != "" && path == mount.path {
-			return nil, false, fmt.Errorf("cannot mount over an existing mountpoint: %s", path)
+			return nil, fmt.Errorf("cannot mount over an existing mountpoint: %s", path)
 		}
 	}
 
@@ -139,9 +277,9 @@ func (mm *mountManager) trackMount(controller, lun uint, path string, c *mountCo
 		controller: controller,
 		lun:        lun,
 		config:     c,
-		refCount:   1,
 		waitCh:     make(chan struct{}),
 	}
+	mount.refCount.Store(1)
 	if freeIndex == -1 {
 		mount.index = len(mm.mounts)
 		mm.mounts = append(mm.mounts, mount)
@@ -150,13 +288,187 @@ func (mm *mountManager) trackMount(controller, lun uint, path string, c *mountCo
 		mm.mounts[freeIndex] = mount
 	}
 	if mount.path == "" {
-		// Use the mount index to produce a unique guest path.
-		mount.path = fmt.Sprintf(mm.mountFmt, mount.index)
+		mount.path = mm.generatePath(tag)
+	}
+	return mount, nil
+}
+
+// generatePath returns a new guest path for a mount tagged with `tag`, from the
+// next value of the mount counter. Values whose path is already used by a
+// tracked mount, such as a mount made at an explicit path, are skipped.
+//
+// Caller must be holding mm.m.
+func (mm *mountManager) generatePath(tag string) string {
+	tag = sanitizePathTag(tag)
+	for {
+		mm.counter++
+		p := fmt.Sprintf(mm.mountFmt, tag, mm.counter)
+		if !mm.pathInUse(p) {
+			return p
+		}
 	}
-	return mount, false, nil
 }
 
 // Caller must be holding mm.m.
-func (mm *mountManager) untrackMount(mount *mount) {
-	mm.mounts[mount.index] = nil
+func (mm *mountManager) pathInUse(path string) bool {
+	for _, m := range mm.mounts {
+		if m != nil && m.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// setCounter raises the mount counter to at least `n`.
+func (mm *mountManager) setCounter(n uint64) {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	mm.counter = max(mm.counter, n)
+}
+
+func (mm *mountManager) getCounter() uint64 {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	return mm.counter
+}
+
+// sanitizePathTag returns `tag` with characters that are not safe in a guest path
+// on either Linux or Windows replaced, truncated to [maxPathTagLength].
+func sanitizePathTag(tag string) string {
+	tag = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, tag)
+	if len(tag) > maxPathTagLength {
+		tag = tag[:maxPathTagLength]
+	}
+	if tag == "" {
+		return defaultPathTag
+	}
+	return tag
+}
+
+// Caller must be holding the write lock of the mount's controller.
+func (mm *mountManager) untrackMount(m *mount) {
+	cm := &mm.controllers[m.controller]
+	cm.mounts = slices.DeleteFunc(cm.mounts, func(other *mount) bool { return other == m })
+
+	mm.m.Lock()
+	mm.mounts[m.index] = nil
+	mm.m.Unlock()
+}
+
+// mountState is the JSON representation of a [mount], as written by [mountManager.DumpState].
+type mountState struct {
+	Path       string
+	Controller uint
+	LUN        uint
+	RefCount   uint
+	// Pending is set if the guest has not finished mounting the device.
+	Pending bool
+	// Error is the error the guest failed to mount the device with, if any.
+	Error  string `json:",omitempty"`
+	Config *mountConfigState
+}
+
+type mountConfigState struct {
+	Partition        uint64
+	ReadOnly         bool
+	Encrypted        bool
+	BlockDev         bool
+	BlockSize        uint32
+	Options          []string
+	EnsureFilesystem bool
+	Filesystem       string
+	FormatWithRefs   bool
+	FormatOnAttach   bool
+	FormatFilesystem string
+	ForceFormat      bool
+	InitializeDisk   bool
+	RepairFilesystem bool
+}
+
+// newMountConfigState returns the JSON representation of `c`, or nil if it is nil.
+func newMountConfigState(c *mountConfig) *mountConfigState {
+	if c == nil {
+		return nil
+	}
+	return &mountConfigState{
+		Partition:        c.partition,
+		ReadOnly:         c.readOnly,
+		Encrypted:        c.encrypted,
+		BlockDev:         c.blockDev,
+		BlockSize:        c.blockSize,
+		Options:          c.options,
+		EnsureFilesystem: c.ensureFilesystem,
+		Filesystem:       c.filesystem,
+		FormatWithRefs:   c.formatWithRefs,
+		FormatOnAttach:   c.formatOnAttach,
+		FormatFilesystem: c.formatFilesystem,
+		ForceFormat:      c.forceFormat,
+		InitializeDisk:   c.initializeDisk,
+		RepairFilesystem: c.repairFilesystem,
+	}
+}
+
+// mountConfig returns the config that `s` represents.
+func (s *mountConfigState) mountConfig() *mountConfig {
+	return &mountConfig{
+		partition:        s.Partition,
+		readOnly:         s.ReadOnly,
+		encrypted:        s.Encrypted,
+		blockDev:         s.BlockDev,
+		blockSize:        s.BlockSize,
+		options:          s.Options,
+		ensureFilesystem: s.EnsureFilesystem,
+		filesystem:       s.Filesystem,
+		formatWithRefs:   s.FormatWithRefs,
+		formatOnAttach:   s.FormatOnAttach,
+		formatFilesystem: s.FormatFilesystem,
+		forceFormat:      s.ForceFormat,
+		initializeDisk:   s.InitializeDisk,
+		repairFilesystem: s.RepairFilesystem,
+	}
+}
+
+// DumpState writes the current mounts to w as a JSON array, indexed by mount index. Unused
+// indices are written as null.
+func (mm *mountManager) DumpState(w io.Writer) error {
+	mm.m.Lock()
+	states := make([]*mountState, len(mm.mounts))
+	for i, m := range mm.mounts {
+		if m == nil {
+			continue
+		}
+		s := &mountState{
+			Path:       m.path,
+			Controller: m.controller,
+			LUN:        m.lun,
+			RefCount:   uint(m.refCount.Load()),
+		}
+		select {
+		case <-m.waitCh:
+			if m.waitErr != nil {
+				s.Error = m.waitErr.Error()
+			}
+		default:
+			s.Pending = true
+		}
+		s.Config = newMountConfigState(m.config)
+		states[i] = s
+	}
+	mm.m.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(states); err != nil {
+		return fmt.Errorf("encode scsi mount state: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,62 @@
+//go:build windows
+
+package scsi
+
+import "testing"
+
+func TestMountConfigString(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   *mountConfig
+		expected string
+	}{
+		{
+			name:     "nil",
+			expected: "<nil>",
+		},
+		{
+			name:     "default",
+			config:   &mountConfig{},
+			expected: "ro=false enc=false",
+		},
+		{
+			name: "layer",
+			config: &mountConfig{
+				filesystem: "ext4",
+				readOnly:   true,
+				encrypted:  true,
+				partition:  1,
+				options:    []string{"noatime", "lazytime"},
+			},
+			expected: "fs=ext4 ro=true enc=true partition=1 options=[noatime,lazytime]",
+		},
+		{
+			name: "scratch",
+			config: &mountConfig{
+				ensureFilesystem: true,
+				filesystem:       "xfs",
+				formatOnAttach:   true,
+				formatFilesystem: "xfs",
+				initializeDisk:   true,
+				repairFilesystem: true,
+			},
+			expected: "fs=xfs ro=false enc=false ensurefs=true format=true formatfs=xfs initdisk=true repair=true",
+		},
+		{
+			name: "block device",
+			config: &mountConfig{
+				blockDev:       true,
+				blockSize:      4096,
+				forceFormat:    true,
+				formatWithRefs: true,
+			},
+			expected: "ro=false enc=false blockdev=true blocksize=4096 forceformat=true refs=true",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if s := tc.config.String(); s != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, s)
+			}
+		})
+	}
+}
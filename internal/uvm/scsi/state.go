@@ -0,0 +1,257 @@
+//go:build windows
+
+package scsi
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+)
+
+// State is the state of the SCSI devices tracked by a [Manager], as returned by
+// [Manager.State]. It can be persisted as JSON, and passed to [Manager.Restore] to track
+// the same devices after the shim restarts.
+type State struct {
+	Attachments  []*attachmentState
+	Mounts       []*mountState
+	MountCounter uint64
+}
+
+// attachmentState is the JSON representation of an [attachment], as returned by
+// [Manager.State].
+type attachmentState struct {
+	Controller uint
+	LUN        uint
+	Path       string
+	ReadOnly   bool
+	Type       string
+	EVDType    string `json:",omitempty"`
+	RefCount   uint
+	Owners     map[string]uint `json:",omitempty"`
+}
+
+// State returns the devices that are attached to the VM and mounted in the guest OS.
+// Attachments and mounts that have not completed are not included, nor are the reserved
+// slots, which are passed to [NewManager] instead.
+//
+// Devices should not be added or released concurrently, as the attachments and mounts
+// are not read atomically.
+func (m *Manager) State() (*State, error) {
+	if m == nil {
+		return nil, ErrNotInitialized
+	}
+	return &State{
+		Attachments:  m.attachManager.state(),
+		Mounts:       m.mountManager.state(),
+		MountCounter: m.mountManager.getCounter(),
+	}, nil
+}
+
+// Restore tracks the devices in `s`, as returned by [Manager.State] for the same VM,
+// without attaching or mounting them again. It must be called on a new Manager, before
+// any devices are added, and the Manager must be discarded if it fails.
+//
+// The references to the restored devices are not released unless the handles for them are
+// looked up with [Manager.LookupMount].
+func (m *Manager) Restore(s *State) error {
+	if m == nil {
+		return ErrNotInitialized
+	}
+	if err := m.attachManager.restore(s.Attachments); err != nil {
+		return err
+	}
+	for _, ms := range s.Mounts {
+		if !m.attachManager.attached(ms.Controller, ms.LUN) {
+			return fmt.Errorf("restore mount at %s: controller %d lun %d is not attached", ms.Path, ms.Controller, ms.LUN)
+		}
+	}
+	if err := m.mountManager.restore(s.Mounts); err != nil {
+		return err
+	}
+	m.mountManager.setCounter(s.MountCounter)
+	return nil
+}
+
+// LookupMount returns a handle for one of the references, restored by [Manager.Restore],
+// to the device attached at `controller` and `lun` by `owner`, and mounted at `guestPath`
+// unless it is empty. Releasing the handle releases the reference.
+//
+// The lookup does not add a reference, so each restored reference must be looked up at
+// most once.
+func (m *Manager) LookupMount(controller, lun uint, guestPath, owner string) (*Mount, error) {
+	if m == nil {
+		return nil, ErrNotInitialized
+	}
+	if !m.attachManager.attached(controller, lun) {
+		return nil, fmt.Errorf("controller %d lun %d is not attached", controller, lun)
+	}
+	if guestPath != "" && !m.mountManager.mounted(controller, lun, guestPath) {
+		return nil, fmt.Errorf("controller %d lun %d is not mounted at %s", controller, lun, guestPath)
+	}
+	return &Mount{mgr: m, controller: controller, lun: lun, guestPath: guestPath, owner: owner}, nil
+}
+
+// done returns if `ch` is closed.
+func done(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// closedChan returns a closed channel, for the wait channel of a restored device.
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (am *attachManager) state() []*attachmentState {
+	am.m.Lock()
+	defer am.m.Unlock()
+
+	var states []*attachmentState
+	for _, luns := range am.slots {
+		for _, att := range luns {
+			// reserved slots have no config
+			if att == nil || att.config == nil || !done(att.waitCh) || att.waitErr != nil {
+				continue
+			}
+			states = append(states, &attachmentState{
+				Controller: att.controller,
+				LUN:        att.lun,
+				Path:       att.config.path,
+				ReadOnly:   att.config.readOnly,
+				Type:       att.config.typ,
+				EVDType:    att.config.evdType,
+				RefCount:   att.refCount,
+				Owners:     maps.Clone(att.owners),
+			})
+		}
+	}
+	return states
+}
+
+func (am *attachManager) restore(states []*attachmentState) error {
+	am.m.Lock()
+	defer am.m.Unlock()
+
+	for _, s := range states {
+		if s.Controller >= uint(am.numControllers) || s.LUN >= uint(am.numLUNsPerController) {
+			return fmt.Errorf("restore attachment of %s: controller %d lun %d out of range", s.Path, s.Controller, s.LUN)
+		}
+		if am.slots[s.Controller][s.LUN] != nil {
+			return fmt.Errorf("restore attachment of %s: controller %d lun %d is already in use", s.Path, s.Controller, s.LUN)
+		}
+		if s.RefCount == 0 {
+			return fmt.Errorf("restore attachment of %s: no references", s.Path)
+		}
+		am.slots[s.Controller][s.LUN] = &attachment{
+			controller: s.Controller,
+			lun:        s.LUN,
+			config: &attachConfig{
+				path:     s.Path,
+				readOnly: s.ReadOnly,
+				typ:      s.Type,
+				evdType:  s.EVDType,
+			},
+			waitCh:   closedChan(),
+			refCount: s.RefCount,
+			owners:   maps.Clone(s.Owners),
+		}
+	}
+	return nil
+}
+
+// attached returns if a device is attached at `controller` and `lun`.
+func (am *attachManager) attached(controller, lun uint) bool {
+	am.m.Lock()
+	defer am.m.Unlock()
+
+	if controller >= uint(am.numControllers) || lun >= uint(am.numLUNsPerController) {
+		return false
+	}
+	att := am.slots[controller][lun]
+	return att != nil && att.config != nil
+}
+
+func (mm *mountManager) state() []*mountState {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	var states []*mountState
+	for _, m := range mm.mounts {
+		if m == nil || !done(m.waitCh) || m.waitErr != nil {
+			continue
+		}
+		states = append(states, &mountState{
+			Path:       m.path,
+			Controller: m.controller,
+			LUN:        m.lun,
+			RefCount:   uint(m.refCount.Load()),
+			Config:     newMountConfigState(m.config),
+		})
+	}
+	return states
+}
+
+func (mm *mountManager) restore(states []*mountState) error {
+	for _, s := range states {
+		if err := mm.restoreMount(s); err != nil {
+			return fmt.Errorf("restore mount at %s: %w", s.Path, err)
+		}
+	}
+	return nil
+}
+
+func (mm *mountManager) restoreMount(s *mountState) error {
+	if s.Config == nil {
+		return fmt.Errorf("no mount config")
+	}
+	if s.RefCount == 0 {
+		return fmt.Errorf("no references")
+	}
+	if s.Controller >= uint(len(mm.controllers)) {
+		return fmt.Errorf("controller %d out of range", s.Controller)
+	}
+
+	cm := &mm.controllers[s.Controller]
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	if mm.pathInUse(s.Path) {
+		return fmt.Errorf("path is already mounted")
+	}
+	c := s.Config.mountConfig()
+	// as in mountManager.mount, so the config compares equal to those of new mounts
+	sort.Strings(c.options)
+	m := &mount{
+		path:       s.Path,
+		index:      len(mm.mounts),
+		controller: s.Controller,
+		lun:        s.LUN,
+		config:     c,
+		waitCh:     closedChan(),
+	}
+	m.refCount.Store(uint32(s.RefCount))
+	mm.mounts = append(mm.mounts, m)
+	cm.mounts = append(cm.mounts, m)
+	return nil
+}
+
+// mounted returns if the device at `controller` and `lun` is mounted at `path`.
+func (mm *mountManager) mounted(controller, lun uint, path string) bool {
+	mm.m.Lock()
+	defer mm.m.Unlock()
+
+	for _, m := range mm.mounts {
+		if m != nil && m.path == path {
+			return m.controller == controller && m.lun == lun
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+//go:build windows
+
+package scsi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestStateRestore(t *testing.T) {
+	ctx := context.Background()
+
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m1, err := mgr.AddVirtualDisk(ctx, "path1", true, "", "", &MountConfig{Options: []string{"ro", "noatime"}, PathTag: "c1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.AddVirtualDisk(ctx, "path1", true, "", "", &MountConfig{Options: []string{"noatime", "ro"}, PathTag: "c2"}); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := mgr.AddVirtualDisk(ctx, "path2", false, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := mgr.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored State
+	if err := json.Unmarshal(b, &restored); err != nil {
+		t.Fatal(err)
+	}
+
+	hb := &hostBackend{}
+	gb := &guestBackend{}
+	mgr2, err := NewManager(hb, gb, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr2.Restore(&restored); err != nil {
+		t.Fatal(err)
+	}
+	if len(hb.attachments) != 0 || len(gb.mounts) != 0 {
+		t.Fatalf("expected restored devices not to be attached or mounted again, got %v and %v", hb.attachmentPaths(), gb.mountPaths())
+	}
+
+	// the same mount is reused
+	m3, err := mgr2.AddVirtualDisk(ctx, "path1", true, "", "", &MountConfig{Options: []string{"ro", "noatime"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m3.Controller() != m1.Controller() || m3.LUN() != m1.LUN() || m3.GuestPath() != m1.GuestPath() {
+		t.Fatalf("expected the restored mount at %d/%d %s, got %d/%d %s",
+			m1.Controller(), m1.LUN(), m1.GuestPath(), m3.Controller(), m3.LUN(), m3.GuestPath())
+	}
+	if len(hb.attachments) != 0 || len(gb.mounts) != 0 {
+		t.Fatalf("expected the restored mount to be reused, got %v and %v", hb.attachmentPaths(), gb.mountPaths())
+	}
+
+	// new devices do not collide with the restored ones
+	m4, err := mgr2.AddVirtualDisk(ctx, "path3", true, "", "", &MountConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m4.LUN() == m1.LUN() || m4.LUN() == m2.LUN() || m4.GuestPath() == m1.GuestPath() {
+		t.Fatalf("new mount at lun %d %s collides with a restored one", m4.LUN(), m4.GuestPath())
+	}
+
+	// a restored reference is released through a looked up handle
+	h, err := mgr2.LookupMount(m2.Controller(), m2.LUN(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s2, err := mgr2.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range s2.Attachments {
+		if a.Path == "path2" {
+			t.Fatal("expected path2 to be detached after releasing its restored reference")
+		}
+	}
+	if _, err := mgr2.LookupMount(m2.Controller(), m2.LUN(), "", ""); err == nil {
+		t.Fatal("expected looking up a detached device to fail")
+	}
+	if _, err := mgr2.LookupMount(m1.Controller(), m1.LUN(), "/var/run/scsi/missing", ""); err == nil {
+		t.Fatal("expected looking up a missing mount to fail")
+	}
+}
+
+func TestRestoreConflict(t *testing.T) {
+	s := &State{
+		Attachments: []*attachmentState{{Controller: 0, LUN: 0, Path: "path", Type: "VirtualDisk", RefCount: 1}},
+	}
+	mgr, err := NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", []Slot{{Controller: 0, LUN: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Restore(s); err == nil {
+		t.Fatal("expected restoring an attachment in a reserved slot to fail")
+	}
+
+	s = &State{
+		Mounts: []*mountState{{Path: "/mnt", Controller: 1, LUN: 0, RefCount: 1, Config: &mountConfigState{}}},
+	}
+	mgr, err = NewManager(&hostBackend{}, &guestBackend{}, 4, 64, 0, "/var/run/scsi/%s-%d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Restore(s); err == nil {
+		t.Fatal("expected restoring a mount of a device that is not attached to fail")
+	}
+
+	if err := (*Manager)(nil).Restore(s); !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("expected %v, got %v", ErrNotInitialized, err)
+	}
+}
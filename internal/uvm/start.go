@@ -3,7 +3,6 @@
 package uvm
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/json"
@@ -15,6 +14,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opencensus.io/trace"
 	"golang.org/x/net/netutil"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/windows"
@@ -26,6 +26,7 @@ import (
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/log"
 	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/oc"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
 	"github.com/Microsoft/hcsshim/internal/timeout"
@@ -95,47 +96,6 @@ func isDisconnectError(err error) bool {
 	return hcs.IsAny(err, windows.WSAECONNABORTED, windows.WSAECONNRESET)
 }
 
-func parseLogrus(o *Options) OutputHandler {
-	vmid := ""
-	if o != nil {
-		vmid = o.ID
-	}
-	return func(r io.Reader) {
-		j := json.NewDecoder(r)
-		e := log.L.Dup()
-		fields := e.Data
-		for {
-			for k := range fields {
-				delete(fields, k)
-			}
-			gcsEntry := gcsLogEntry{Fields: e.Data}
-			err := j.Decode(&gcsEntry)
-			if err != nil {
-				// Something went wrong. Read the rest of the data as a single
-				// string and log it at once -- it's probably a GCS panic stack.
-				if !errors.Is(err, io.EOF) && !isDisconnectError(err) {
-					logrus.WithFields(logrus.Fields{
-						logfields.UVMID: vmid,
-						logrus.ErrorKey: err,
-					}).Error("gcs log read")
-				}
-				rest, _ := io.ReadAll(io.MultiReader(j.Buffered(), r))
-				rest = bytes.TrimSpace(rest)
-				if len(rest) != 0 {
-					logrus.WithFields(logrus.Fields{
-						logfields.UVMID: vmid,
-						"stderr":        string(rest),
-					}).Error("gcs terminated")
-				}
-				break
-			}
-			fields[logfields.UVMID] = vmid
-			fields["vm.time"] = gcsEntry.Time
-			e.Log(gcsEntry.Level, gcsEntry.Message)
-		}
-	}
-}
-
 // When using an external GCS connection it is necessary to send a ModifySettings request
 // for HvSocket so that the GCS can setup some registry keys that are required for running
 // containers inside the UVM. In non external GCS connection scenarios this is done by the
@@ -169,6 +129,11 @@ func (uvm *UtilityVM) configureHvSocketForGCS(ctx context.Context) (err error) {
 
 // Start synchronously starts the utility VM.
 func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
+	ctx, span := oc.StartSpan(ctx, "uvm::Start")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute(logfields.UVMID, uvm.id))
+
 	// save parent context, without timeout to use in terminate
 	pCtx := ctx
 	ctx, cancel := context.WithTimeout(pCtx, timeout.GCSConnectionTimeout)
@@ -214,35 +179,7 @@ func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
 	if uvm.outputListener != nil {
 		switch uvm.operatingSystem {
 		case "windows":
-			// Windows specific handling
-			// For windows, the Listener can recieve a connection later, so we
-			// start the output handler in a goroutine with a non-timeout context.
-			// This allows the output handler to run independently of the UVM Create's
-			// lifecycle. The approach potentially allows to wait for reconnections too,
-			// while limiting the number of concurrent connections to 1.
-			// This is useful for the case when logging service is restarted.
-			go func() {
-				var wg sync.WaitGroup
-				uvm.outputListener = netutil.LimitListener(uvm.outputListener, 1)
-				for {
-					conn, err := uvm.accept(context.WithoutCancel(ctx), uvm.outputListener, false)
-					if err != nil {
-						e.WithError(err).Error("failed to connect to log socket")
-						break
-					}
-					wg.Add(1)
-					go func() {
-						defer wg.Done()
-						e.Info("uvm output handler starting")
-						uvm.outputHandler(conn)
-					}()
-					e.Info("uvm output handler finished")
-				}
-				wg.Wait()
-				if _, ok := <-uvm.outputProcessingDone; ok {
-					close(uvm.outputProcessingDone)
-				}
-			}()
+			uvm.serveWCOWOutput(ctx, e)
 		default:
 			// Default handling
 			g.Go(func() error {
@@ -264,10 +201,12 @@ func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
 		}
 	}
 
+	started := time.Now()
 	err = uvm.hcsSystem.Start(ctx)
 	if err != nil {
 		return err
 	}
+	hcsStarted := time.Now()
 	defer func() {
 		if err != nil {
 			// use parent context, to prevent 2 minute timout (set above) from overridding terminate operation's
@@ -303,6 +242,7 @@ func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
 		if err != nil {
 			return fmt.Errorf("failed to connect to GCS: %w", err)
 		}
+		connected := time.Now()
 
 		var initGuestState *gcs.InitialGuestState
 		if uvm.OS() == "windows" {
@@ -328,14 +268,28 @@ func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
 			Log:            e,
 			IoListen:       gcs.HvsockIoListen(uvm.runtimeID),
 			InitGuestState: initGuestState,
+			OnFirstModify:  uvm.recordFirstModify,
+
+			StdioPortsWarningThreshold: int(uvm.stdioPortsWarningThreshold),
+			StatisticsInterval:         time.Duration(uvm.statisticsIntervalSeconds) * time.Second,
+		}
+		if lopts, ok := uvm.createOpts.(*OptionsLCOW); ok {
+			gcc.DenyRootProcesses = lopts.DenyRootProcesses
 		}
 		uvm.gc, err = gcc.Connect(ctx, true)
 		if err != nil {
 			return err
 		}
+		span.AddAttributes(uvm.recordStart(started, connected, time.Now()).attributes()...)
 		uvm.guestCaps = uvm.gc.Capabilities()
 		uvm.protocol = uvm.gc.Protocol()
 
+		if lopts, ok := uvm.createOpts.(*OptionsLCOW); ok && lopts.RequireGCSSeccomp {
+			if lc := gcs.GetLCOWCapabilities(uvm.guestCaps); lc == nil || !lc.SeccompFilterActive {
+				return errors.New("GCS does not report an active seccomp filter, which the UVM requires")
+			}
+		}
+
 		// initial setup required for external GCS connection
 		if err = uvm.configureHvSocketForGCS(ctx); err != nil {
 			return fmt.Errorf("failed to do initial GCS setup: %w", err)
@@ -348,30 +302,18 @@ func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
 		}
 		uvm.guestCaps = &gcs.WCOWGuestDefinedCapabilities{GuestDefinedCapabilities: properties.GuestConnectionInfo.GuestDefinedCapabilities}
 		uvm.protocol = properties.GuestConnectionInfo.ProtocolVersion
+
+		// the GCS is connected once the compute system starts, and modify
+		// requests are not sent to it directly
+		bt := uvm.recordStart(started, hcsStarted, hcsStarted)
+		span.AddAttributes(bt.attributes()...)
+		uvm.logBootTimes(ctx, bt)
 	}
 
 	// Initialize the SCSIManager.
-	var gb scsi.GuestBackend
-	if uvm.gc != nil {
-		gb = scsi.NewBridgeGuestBackend(uvm.gc, uvm.OS())
-	} else {
-		gb = scsi.NewHCSGuestBackend(uvm.hcsSystem, uvm.OS())
-	}
-	guestMountFmt := `c:\mounts\scsi\m%d`
-	if uvm.OS() == "linux" {
-		guestMountFmt = "/run/mounts/scsi/m%d"
-	}
-	mgr, err := scsi.NewManager(
-		scsi.NewHCSHostBackend(uvm.hcsSystem),
-		gb,
-		int(uvm.scsiControllerCount),
-		64, // LUNs per controller, fixed by Hyper-V.
-		guestMountFmt,
-		uvm.reservedSCSISlots)
-	if err != nil {
-		return fmt.Errorf("creating scsi manager: %w", err)
+	if uvm.SCSIManager, err = uvm.newSCSIManager(); err != nil {
+		return err
 	}
-	uvm.SCSIManager = mgr
 
 	if uvm.HasConfidentialPolicy() {
 		var policy, enforcer, referenceInfoFileRoot, referenceInfoFilePath string
@@ -406,9 +348,75 @@ func (uvm *UtilityVM) Start(ctx context.Context) (err error) {
 		}
 	}
 
+	if uvm.stateFile != "" {
+		if err := uvm.SaveState(ctx); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// serveWCOWOutput handles the connections to the output listener of a Windows utility VM.
+func (uvm *UtilityVM) serveWCOWOutput(ctx context.Context, e *logrus.Entry) {
+	// For windows, the Listener can recieve a connection later, so we
+	// start the output handler in a goroutine with a non-timeout context.
+	// This allows the output handler to run independently of the UVM Create's
+	// lifecycle. The approach potentially allows to wait for reconnections too,
+	// while limiting the number of concurrent connections to 1.
+	// This is useful for the case when logging service is restarted.
+	go func() {
+		var wg sync.WaitGroup
+		uvm.outputListener = netutil.LimitListener(uvm.outputListener, 1)
+		for {
+			conn, err := uvm.accept(context.WithoutCancel(ctx), uvm.outputListener, false)
+			if err != nil {
+				e.WithError(err).Error("failed to connect to log socket")
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.Info("uvm output handler starting")
+				uvm.outputHandler(conn)
+			}()
+			e.Info("uvm output handler finished")
+		}
+		wg.Wait()
+		if _, ok := <-uvm.outputProcessingDone; ok {
+			close(uvm.outputProcessingDone)
+		}
+	}()
+}
+
+// newSCSIManager creates the manager of the SCSI devices of the utility VM, which
+// mounts them in the guest over the GCS connection if there is one.
+func (uvm *UtilityVM) newSCSIManager() (*scsi.Manager, error) {
+	var gb scsi.GuestBackend
+	if uvm.gc != nil {
+		gb = scsi.NewBridgeGuestBackend(uvm.gc, uvm.OS())
+	} else {
+		gb = scsi.NewHCSGuestBackend(uvm.hcsSystem, uvm.OS())
+	}
+	guestMountFmt := `c:\mounts\scsi\%s-%d`
+	if uvm.OS() == "linux" {
+		guestMountFmt = "/run/mounts/scsi/%s-%d"
+	}
+	mgr, err := scsi.NewManager(
+		scsi.NewHCSHostBackend(uvm.hcsSystem),
+		gb,
+		int(uvm.scsiControllerCount),
+		64, // LUNs per controller, fixed by Hyper-V.
+		int(uvm.scsiLayerControllerCount),
+		guestMountFmt,
+		uvm.reservedSCSISlots)
+	if err != nil {
+		return nil, fmt.Errorf("creating scsi manager: %w", err)
+	}
+	mgr.OnChange(uvm.stateChanged)
+	return mgr, nil
+}
+
 // accept accepts a connection and then closes a listener. If the
 // context becomes done or the utility VM terminates, the operation will be
 // cancelled (but the listener will still be closed).
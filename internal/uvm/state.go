@@ -0,0 +1,464 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"go.opencensus.io/trace"
+
+	"github.com/Microsoft/hcsshim/internal/gcs"
+	"github.com/Microsoft/hcsshim/internal/gcs/prot"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/hcs/schema1"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/oc"
+	"github.com/Microsoft/hcsshim/internal/schemaversion"
+	"github.com/Microsoft/hcsshim/internal/timeout"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
+)
+
+// stateVersion is the version of the format of the state file written by
+// [UtilityVM.SaveState]. It must be incremented on incompatible changes.
+const stateVersion = 1
+
+var (
+	// ErrStateVersion is returned by [AttachExisting] if the state file was written in an
+	// incompatible format, such as by a different version of the shim.
+	ErrStateVersion = errors.New("incompatible utility VM state file version")
+	// ErrSystemNotFound is returned by [AttachExisting] if the compute system of the
+	// utility VM does not exist.
+	ErrSystemNotFound = errors.New("utility VM compute system not found")
+	// ErrStateMismatch is returned by [AttachExisting] if the compute system does not
+	// match the state file, such as if it was created again with the same ID.
+	ErrStateMismatch = errors.New("utility VM compute system does not match state file")
+	// ErrGCSNotReconnected is returned by [AttachExisting] if the GCS of the utility VM
+	// did not connect to the host again.
+	ErrGCSNotReconnected = errors.New("GCS of utility VM did not reconnect")
+	// ErrAttachNotSupported is returned by [AttachExisting] if the utility VM cannot be
+	// reattached to, such as a Linux utility VM, whose GCS does not connect to the host
+	// again.
+	ErrAttachNotSupported = errors.New("reattaching to utility VM is not supported")
+)
+
+// savedState is the state of a utility VM, as written to its state file.
+type savedState struct {
+	Version   int
+	ID        string
+	RuntimeID guid.GUID
+	Owner     string
+	OS        string
+
+	// GCSServiceID is the HvSocket service ID the GCS connects to, or nil if the GCS
+	// is reached through HCS.
+	GCSServiceID *guid.GUID `json:",omitempty"`
+	Confidential bool
+	ForwardLogs  bool
+	LogSources   string `json:",omitempty"`
+
+	ProcessorCount             int32
+	MemorySizeInMB             uint64
+	PhysicallyBacked           bool
+	DevicesPhysicallyBacked    bool
+	NoWritableFileShares       bool
+	EncryptScratch             bool
+	RepairScratch              bool
+	TPMEnabled                 bool
+	TPMStateFilePath           string `json:",omitempty"`
+	StdioPortsWarningThreshold uint32
+	StatisticsIntervalSeconds  int32
+
+	SCSIControllerCount      uint32
+	SCSILayerControllerCount uint32
+	ReservedSCSISlots        []scsi.Slot `json:",omitempty"`
+	SCSI                     *scsi.State `json:",omitempty"`
+
+	VSMBShares            []*vsmbShareState `json:",omitempty"`
+	VSMBCounter           uint64
+	VSMBNoDirectMap       bool
+	VSMBConsolidateLayers bool
+
+	MountCounter     uint64
+	ContainerCounter uint64
+}
+
+// vsmbShareState is the state of a [VSMBShare], as written to the state file.
+type vsmbShareState struct {
+	HostPath     string
+	Name         string
+	GuestPath    string
+	AllowedFiles []string `json:",omitempty"`
+	Options      hcsschema.VirtualSmbShareOptions
+	RefCount     uint32
+	DirShare     bool
+	SubRefs      map[string]uint32 `json:",omitempty"`
+}
+
+// SaveState writes the state of the utility VM to the file set by [Options.StateFile],
+// for [AttachExisting]. It is written once the utility VM starts, and again whenever SCSI
+// devices or VSMB shares are added or removed.
+func (uvm *UtilityVM) SaveState(ctx context.Context) (err error) {
+	ctx, span := oc.StartSpan(ctx, "uvm::SaveState")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute(logfields.UVMID, uvm.id))
+
+	if uvm.stateFile == "" {
+		return errors.New("utility VM has no state file")
+	}
+	// the state is read under the locks of the devices, so a later change may otherwise
+	// have its state overwritten by an earlier one
+	uvm.stateMu.Lock()
+	defer uvm.stateMu.Unlock()
+	s, err := uvm.state()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal utility VM state: %w", err)
+	}
+	// write to a temporary file first, so that the state file is never partially written
+	tmp := uvm.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write utility VM state: %w", err)
+	}
+	if err := os.Rename(tmp, uvm.stateFile); err != nil {
+		return fmt.Errorf("failed to write utility VM state: %w", err)
+	}
+	log.G(ctx).WithField("path", uvm.stateFile).Debug("saved utility VM state")
+	return nil
+}
+
+// stateChanged saves the state of the utility VM after devices were added or removed, if
+// it has a state file. It must be called without uvm.m held.
+func (uvm *UtilityVM) stateChanged(ctx context.Context) {
+	if uvm.stateFile == "" {
+		return
+	}
+	// the change itself succeeded, so only a later reattach is affected
+	if err := uvm.SaveState(ctx); err != nil {
+		log.G(ctx).WithError(err).Warning("failed to save utility VM state")
+	}
+}
+
+func (uvm *UtilityVM) state() (*savedState, error) {
+	s := &savedState{
+		Version:                    stateVersion,
+		ID:                         uvm.id,
+		RuntimeID:                  uvm.runtimeID,
+		Owner:                      uvm.owner,
+		OS:                         uvm.operatingSystem,
+		Confidential:               uvm.HasConfidentialPolicy(),
+		ForwardLogs:                uvm.forwardLogs,
+		LogSources:                 uvm.logSources,
+		ProcessorCount:             uvm.processorCount,
+		PhysicallyBacked:           uvm.physicallyBacked,
+		DevicesPhysicallyBacked:    uvm.devicesPhysicallyBacked,
+		NoWritableFileShares:       uvm.noWritableFileShares,
+		EncryptScratch:             uvm.encryptScratch,
+		RepairScratch:              uvm.repairScratch,
+		TPMEnabled:                 uvm.tpmEnabled,
+		TPMStateFilePath:           uvm.tpmStateFilePath,
+		StdioPortsWarningThreshold: uvm.stdioPortsWarningThreshold,
+		StatisticsIntervalSeconds:  uvm.statisticsIntervalSeconds,
+		SCSIControllerCount:        uvm.scsiControllerCount,
+		SCSILayerControllerCount:   uvm.scsiLayerControllerCount,
+		ReservedSCSISlots:          uvm.reservedSCSISlots,
+		VSMBNoDirectMap:            uvm.vsmbNoDirectMap,
+		VSMBConsolidateLayers:      uvm.vsmbConsolidateLayers,
+		MountCounter:               uvm.mountCounter.Load(),
+		ContainerCounter:           uvm.containerCounter.Load(),
+	}
+	if uvm.gc != nil {
+		id := uvm.gcsServiceID
+		s.GCSServiceID = &id
+	}
+
+	uvm.admissionMu.Lock()
+	s.MemorySizeInMB = uvm.memorySizeInMB
+	uvm.admissionMu.Unlock()
+
+	if uvm.SCSIManager != nil {
+		var err error
+		if s.SCSI, err = uvm.SCSIManager.State(); err != nil {
+			return nil, err
+		}
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	for _, m := range []map[string]*VSMBShare{uvm.vsmbDirShares, uvm.vsmbFileShares} {
+		for _, share := range m {
+			s.VSMBShares = append(s.VSMBShares, &vsmbShareState{
+				HostPath:     share.HostPath,
+				Name:         share.name,
+				GuestPath:    share.guestPath,
+				AllowedFiles: share.allowedFiles,
+				Options:      share.options,
+				RefCount:     share.refCount,
+				DirShare:     share.isDirShare,
+				SubRefs:      share.subRefs,
+			})
+		}
+	}
+	sort.Slice(s.VSMBShares, func(i, j int) bool { return s.VSMBShares[i].Name < s.VSMBShares[j].Name })
+	s.VSMBCounter = uvm.vsmbCounter
+	return s, nil
+}
+
+// readState reads the state file at `path`, as written by [UtilityVM.SaveState].
+func readState(path string) (*savedState, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read utility VM state: %w", err)
+	}
+	// check the version before decoding the rest, whose format may have changed
+	var v struct{ Version int }
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal utility VM state: %w", err)
+	}
+	if v.Version != stateVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrStateVersion, v.Version, stateVersion)
+	}
+	s := &savedState{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal utility VM state: %w", err)
+	}
+	return s, nil
+}
+
+// AttachExisting reattaches to the running utility VM with ID `id`, such as after the
+// shim restarted, from the state file written by [UtilityVM.SaveState].
+//
+// The compute system is opened, the GCS connection is established again, the SCSI devices
+// and VSMB shares are restored, so that they can be released and new ones do not collide
+// with them, and log forwarding is started again, with the output of the utility VM
+// handled by the default [OutputHandler]. VPCI devices and network namespaces are not
+// restored.
+//
+// Only Windows utility VMs can be reattached to: the Linux GCS does not connect to the
+// host again once it loses its connection.
+//
+// Returns [ErrStateVersion], [ErrAttachNotSupported], [ErrSystemNotFound],
+// [ErrStateMismatch], or [ErrGCSNotReconnected] if the utility VM cannot be reattached
+// to, so that the caller can clean it up instead.
+func AttachExisting(ctx context.Context, id string, stateFile string) (_ *UtilityVM, err error) {
+	ctx, span := oc.StartSpan(ctx, "uvm::AttachExisting")
+	defer span.End()
+	defer func() { oc.SetSpanStatus(span, err) }()
+	span.AddAttributes(trace.StringAttribute(logfields.UVMID, id))
+
+	s, err := readState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	if s.ID != id {
+		return nil, fmt.Errorf("%w: state file is for utility VM %s", ErrStateMismatch, s.ID)
+	}
+	if s.OS != "windows" {
+		return nil, fmt.Errorf("%w: %s utility VM", ErrAttachNotSupported, s.OS)
+	}
+
+	// utility VMs are only created with the second schema
+	system, err := hcs.OpenComputeSystemWithVersion(ctx, id, *schemaversion.SchemaV21(), hcs.SchemaV25)
+	if err != nil {
+		if hcs.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %w", ErrSystemNotFound, err)
+		}
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			// only close the handle, the caller decides whether to terminate the utility VM
+			system.Close()
+		}
+	}()
+	runtimeID, err := system.GetRuntimeID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(runtimeID, s.RuntimeID.String()) {
+		return nil, fmt.Errorf("%w: runtime ID %s, expected %s", ErrStateMismatch, runtimeID, s.RuntimeID)
+	}
+
+	uvm := newAttachedUVM(s, system)
+	uvm.stateFile = stateFile
+
+	// Start waiting on the utility VM, as in Start.
+	uvm.exitCh = make(chan struct{})
+	go func() {
+		err := uvm.hcsSystem.WaitCtx(context.WithoutCancel(ctx))
+		if err == nil {
+			err = uvm.hcsSystem.ExitError()
+		}
+		uvm.exitErr = err
+		close(uvm.exitCh)
+	}()
+
+	if s.GCSServiceID != nil {
+		if err := uvm.reconnectGCS(ctx, *s.GCSServiceID); err != nil {
+			return nil, err
+		}
+	} else {
+		properties, err := uvm.hcsSystem.Properties(ctx, schema1.PropertyTypeGuestConnection)
+		if err != nil {
+			return nil, err
+		}
+		uvm.guestCaps = &gcs.WCOWGuestDefinedCapabilities{GuestDefinedCapabilities: properties.GuestConnectionInfo.GuestDefinedCapabilities}
+		uvm.protocol = properties.GuestConnectionInfo.ProtocolVersion
+	}
+
+	if uvm.SCSIManager, err = uvm.newSCSIManager(); err != nil {
+		return nil, err
+	}
+	if s.SCSI != nil {
+		if err := uvm.SCSIManager.Restore(s.SCSI); err != nil {
+			return nil, fmt.Errorf("failed to restore SCSI devices: %w", err)
+		}
+	}
+
+	if uvm.forwardLogs {
+		if err := uvm.restartLogForwarding(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	log.G(ctx).WithFields(map[string]interface{}{
+		logfields.UVMID: uvm.id,
+		"runtime-id":    uvm.runtimeID.String(),
+	}).Debug("attached to existing utility VM")
+	return uvm, nil
+}
+
+// newAttachedUVM returns the Windows utility VM of the compute system `system`, with
+// state `s`.
+func newAttachedUVM(s *savedState, system *hcs.System) *UtilityVM {
+	opts := newDefaultOptions(s.ID, s.Owner)
+	uvm := &UtilityVM{
+		id:                         s.ID,
+		runtimeID:                  s.RuntimeID,
+		owner:                      s.Owner,
+		operatingSystem:            s.OS,
+		hcsSystem:                  system,
+		processorCount:             s.ProcessorCount,
+		memorySizeInMB:             s.MemorySizeInMB,
+		physicallyBacked:           s.PhysicallyBacked,
+		devicesPhysicallyBacked:    s.DevicesPhysicallyBacked,
+		noWritableFileShares:       s.NoWritableFileShares,
+		encryptScratch:             s.EncryptScratch,
+		repairScratch:              s.RepairScratch,
+		tpmEnabled:                 s.TPMEnabled,
+		tpmStateFilePath:           s.TPMStateFilePath,
+		stdioPortsWarningThreshold: s.StdioPortsWarningThreshold,
+		statisticsIntervalSeconds:  s.StatisticsIntervalSeconds,
+		scsiControllerCount:        s.SCSIControllerCount,
+		scsiLayerControllerCount:   s.SCSILayerControllerCount,
+		reservedSCSISlots:          s.ReservedSCSISlots,
+		vsmbDirShares:              make(map[string]*VSMBShare),
+		vsmbFileShares:             make(map[string]*VSMBShare),
+		vsmbCounter:                s.VSMBCounter,
+		vsmbNoDirectMap:            s.VSMBNoDirectMap,
+		vsmbConsolidateLayers:      s.VSMBConsolidateLayers,
+		vpciDevices:                make(map[VPCIDeviceID]*VPCIDevice),
+		blockCIMMounts:             make(map[string]*UVMMountedBlockCIMs),
+		forwardLogs:                s.ForwardLogs,
+		logSources:                 s.LogSources,
+		createOpts: &OptionsWCOW{
+			Options:                 opts,
+			ConfidentialWCOWOptions: &ConfidentialWCOWOptions{SecurityPolicyEnabled: s.Confidential},
+		},
+	}
+	if s.GCSServiceID != nil {
+		uvm.gcsServiceID = *s.GCSServiceID
+	}
+	uvm.mountCounter.Store(s.MountCounter)
+	uvm.containerCounter.Store(s.ContainerCounter)
+	for _, vs := range s.VSMBShares {
+		share := &VSMBShare{
+			vm:           uvm,
+			HostPath:     vs.HostPath,
+			refCount:     vs.RefCount,
+			name:         vs.Name,
+			allowedFiles: vs.AllowedFiles,
+			guestPath:    vs.GuestPath,
+			options:      vs.Options,
+			isDirShare:   vs.DirShare,
+			subRefs:      vs.SubRefs,
+		}
+		m := uvm.vsmbFileShares
+		if share.isDirShare {
+			m = uvm.vsmbDirShares
+		}
+		m[getVSMBShareKey(share.HostPath, share.options.ReadOnly)] = share
+	}
+	return uvm
+}
+
+// reconnectGCS establishes the external GCS connection of an attached utility VM again,
+// waiting for the GCS to connect to the HvSocket service `serviceID`.
+func (uvm *UtilityVM) reconnectGCS(ctx context.Context, serviceID guid.GUID) error {
+	l, err := winio.ListenHvsock(&winio.HvsockAddr{
+		VMID:      uvm.runtimeID,
+		ServiceID: serviceID,
+	})
+	if err != nil {
+		return err
+	}
+	actx, cancel := context.WithTimeout(ctx, timeout.GCSConnectionTimeout)
+	defer cancel()
+	conn, err := uvm.accept(actx, l, true)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrGCSNotReconnected, err)
+	}
+
+	gcc := &gcs.GuestConnectionConfig{
+		Conn:                       conn,
+		Log:                        log.G(ctx).WithField(logfields.UVMID, uvm.id),
+		IoListen:                   gcs.HvsockIoListen(uvm.runtimeID),
+		StdioPortsWarningThreshold: int(uvm.stdioPortsWarningThreshold),
+		StatisticsInterval:         time.Duration(uvm.statisticsIntervalSeconds) * time.Second,
+	}
+	if uvm.gc, err = gcc.Connect(ctx, false); err != nil {
+		return err
+	}
+	uvm.guestCaps = uvm.gc.Capabilities()
+	uvm.protocol = uvm.gc.Protocol()
+	return nil
+}
+
+// restartLogForwarding listens for the output of an attached utility VM again, and starts
+// log forwarding in the guest again, as [UtilityVM.Start] does.
+func (uvm *UtilityVM) restartLogForwarding(ctx context.Context) (err error) {
+	e := log.G(ctx).WithField(logfields.UVMID, uvm.id)
+	uvm.outputHandler = uvm.newOutputHandler(nil, nil)
+	uvm.outputProcessingDone = make(chan struct{})
+	uvm.outputListener, err = winio.ListenHvsock(&winio.HvsockAddr{
+		VMID:      uvm.runtimeID,
+		ServiceID: prot.WindowsLoggingHvsockServiceID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to listen for utility VM output: %w", err)
+	}
+	uvm.serveWCOWOutput(ctx, e)
+
+	// the logging service may have stopped with the previous connection
+	if err := uvm.SetLogSources(ctx); err != nil {
+		e.WithError(err).Error("failed to set log sources")
+	}
+	if err := uvm.StartLogForwarding(ctx); err != nil {
+		e.WithError(err).Error("failed to start log forwarding")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func TestSaveState(t *testing.T) {
+	ctx := context.Background()
+	runtimeID, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	vm := &UtilityVM{
+		id:              "uvm",
+		runtimeID:       runtimeID,
+		owner:           "owner",
+		operatingSystem: "windows",
+		processorCount:  2,
+		memorySizeInMB:  1024,
+		vsmbDirShares:   make(map[string]*VSMBShare),
+		vsmbFileShares:  make(map[string]*VSMBShare),
+		vsmbCounter:     3,
+		createOpts:      NewDefaultOptionsWCOW("uvm", "owner"),
+		forwardLogs:     true,
+		logSources:      "sources",
+		stateFile:       stateFile,
+	}
+	vm.vsmbDirShares[getVSMBShareKey(`C:\share`, true)] = &VSMBShare{
+		vm:         vm,
+		HostPath:   `C:\share`,
+		name:       "s1",
+		guestPath:  `\\?\VMSMB\VSMB-{dcc079ae-60ba-4d07-847c-3493609c0870}\s1`,
+		refCount:   2,
+		isDirShare: true,
+	}
+	vm.mountCounter.Store(5)
+
+	if err := vm.SaveState(ctx); err != nil {
+		t.Fatalf("failed to save state: %v", err)
+	}
+	s, err := readState(stateFile)
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if s.ID != vm.id || s.RuntimeID != runtimeID || s.GCSServiceID != nil {
+		t.Fatalf("unexpected state: %+v", s)
+	}
+
+	restored := newAttachedUVM(s, nil)
+	if restored.MemorySizeInMB() != 1024 || restored.vsmbCounter != 3 || restored.mountCounter.Load() != 5 ||
+		!restored.forwardLogs || restored.logSources != "sources" {
+		t.Fatalf("unexpected restored utility VM: %+v", restored)
+	}
+	share, ok := restored.vsmbDirShares[getVSMBShareKey(`C:\share`, true)]
+	if !ok || share.refCount != 2 || share.vm != restored {
+		t.Fatalf("unexpected restored VSMB share: %+v", share)
+	}
+	if restored.HasConfidentialPolicy() {
+		t.Fatal("restored utility VM unexpectedly has a confidential policy")
+	}
+}
+
+func TestReadStateVersion(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(stateFile, []byte(`{"Version":0,"ID":"uvm"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readState(stateFile); !errors.Is(err, ErrStateVersion) {
+		t.Fatalf("expected %v, got: %v", ErrStateVersion, err)
+	}
+	if _, err := AttachExisting(context.Background(), "uvm", stateFile); !errors.Is(err, ErrStateVersion) {
+		t.Fatalf("expected %v, got: %v", ErrStateVersion, err)
+	}
+}
+
+func TestAttachExistingLCOW(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	b := fmt.Sprintf(`{"Version":%d,"ID":"uvm","OS":"linux"}`, stateVersion)
+	if err := os.WriteFile(stateFile, []byte(b), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AttachExisting(context.Background(), "uvm", stateFile); !errors.Is(err, ErrAttachNotSupported) {
+		t.Fatalf("expected %v, got: %v", ErrAttachNotSupported, err)
+	}
+}
@@ -117,7 +117,18 @@ func (uvm *UtilityVM) Stats(ctx context.Context) (*stats.VirtualMachineStatistic
 		return nil, err
 	}
 	s.Processor = &stats.VirtualMachineProcessorStatistics{}
-	s.Processor.TotalRuntimeNS = uint64(props.Statistics.Processor.TotalRuntime100ns * 100)
+	if props.Statistics != nil && props.Statistics.Processor != nil {
+		s.Processor.TotalRuntimeNS = uint64(props.Statistics.Processor.TotalRuntime100ns * 100)
+	}
+	// Older builds do not report storage statistics for the VM.
+	if props.Statistics != nil && props.Statistics.Storage != nil {
+		s.Storage = &stats.VirtualMachineStorageStatistics{
+			ReadCountNormalized:  props.Statistics.Storage.ReadCountNormalized,
+			ReadSizeBytes:        props.Statistics.Storage.ReadSizeBytes,
+			WriteCountNormalized: props.Statistics.Storage.WriteCountNormalized,
+			WriteSizeBytes:       props.Statistics.Storage.WriteSizeBytes,
+		}
+	}
 
 	s.Memory = &stats.VirtualMachineMemoryStatistics{}
 	if uvm.physicallyBacked {
@@ -154,5 +165,6 @@ func (uvm *UtilityVM) Stats(ctx context.Context) (*stats.VirtualMachineStatistic
 		s.Memory.VmMemory.BalancingEnabled = props.Memory.VirtualMachineMemory.BalancingEnabled
 		s.Memory.VmMemory.DmOperationInProgress = props.Memory.VirtualMachineMemory.DmOperationInProgress
 	}
+	s.NetworkEgressBandwidthMaximum = uvm.EgressBandwidth()
 	return s, nil
 }
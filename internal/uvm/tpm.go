@@ -0,0 +1,140 @@
+//go:build windows
+
+package uvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/log"
+	"github.com/Microsoft/hcsshim/internal/wclayer"
+	"github.com/Microsoft/hcsshim/osversion"
+)
+
+// ErrTPMNotSupported is returned when creating a UVM with [Options.EnableTPM]
+// on a host that cannot add a virtual TPM to it, or for a confidential UVM.
+var ErrTPMNotSupported = errors.New("virtual TPM is not supported")
+
+// verifyTPMOptions verifies the virtual TPM options of a UVM, which is
+// confidential if `confidential` is set.
+func verifyTPMOptions(opts *Options, confidential bool) error {
+	if !opts.EnableTPM {
+		if opts.TPMStateFilePath != "" {
+			return errors.New("TPMStateFilePath requires EnableTPM")
+		}
+		return nil
+	}
+	if opts.KeepTPMState && opts.TPMStateFilePath == "" {
+		return errors.New("KeepTPMState requires TPMStateFilePath")
+	}
+	if opts.TPMStateFilePath != "" {
+		if opts.TPMStateRoot == "" {
+			return errors.New("TPMStateFilePath requires TPMStateRoot")
+		}
+		if !filepath.IsAbs(opts.TPMStateRoot) {
+			return fmt.Errorf("TPMStateRoot %q must be an absolute path", opts.TPMStateRoot)
+		}
+		// IsLocal rejects reserved names such as NUL, and a single name can't
+		// escape the root through a directory
+		if !filepath.IsLocal(opts.TPMStateFilePath) || filepath.Base(opts.TPMStateFilePath) != opts.TPMStateFilePath {
+			return fmt.Errorf("TPMStateFilePath %q must be the name of a file in TPMStateRoot", opts.TPMStateFilePath)
+		}
+	}
+	if confidential {
+		// the TPM of a confidential UVM must remain disabled as per the design
+		return fmt.Errorf("%w on confidential UVMs", ErrTPMNotSupported)
+	}
+	if osversion.Build() < osversion.V21H2Server {
+		return fmt.Errorf("%w: requires Windows build %d or later, host is build %d",
+			ErrTPMNotSupported, osversion.V21H2Server, osversion.Build())
+	}
+	return nil
+}
+
+// addTPM adds the virtual TPM of [Options.EnableTPM] to `doc`, with its state
+// persisted in [Options.TPMStateFilePath] within [Options.TPMStateRoot], or else
+// in memory.
+//
+// The state file is created if it does not exist. An existing file is only
+// reused if the state is kept with [Options.KeepTPMState], and if it is a
+// regular file, rather than a link to a file outside of the root.
+func (uvm *UtilityVM) addTPM(ctx context.Context, doc *hcsschema.ComputeSystem, opts *Options) error {
+	if !opts.EnableTPM {
+		return nil
+	}
+	doc.VirtualMachine.SecuritySettings = &hcsschema.SecuritySettings{
+		EnableTpm: true,
+	}
+	uvm.tpmEnabled = true
+	if opts.TPMStateFilePath == "" {
+		return nil
+	}
+
+	path, err := tpmStateFile(opts)
+	if err != nil {
+		return err
+	}
+	if !opts.KeepTPMState {
+		uvm.tpmStateFilePath = path
+	}
+	if err := wclayer.GrantVmAccess(ctx, uvm.id, path); err != nil {
+		return fmt.Errorf("failed to grant vm access to TPM state file: %w", err)
+	}
+	doc.VirtualMachine.GuestState = &hcsschema.GuestState{
+		GuestStateFilePath: path,
+		GuestStateFileType: "FileMode",
+	}
+	return nil
+}
+
+// tpmStateFile creates the TPM state file of `opts` in its root, or checks that
+// the existing one can be reused, and returns its path.
+func tpmStateFile(opts *Options) (string, error) {
+	if err := os.MkdirAll(opts.TPMStateRoot, 0700); err != nil {
+		return "", fmt.Errorf("failed to create TPM state root: %w", err)
+	}
+	path := filepath.Join(opts.TPMStateRoot, opts.TPMStateFilePath)
+	// HCS initializes an empty guest state file
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err == nil {
+		f.Close()
+		return path, nil
+	}
+	if !os.IsExist(err) {
+		return "", fmt.Errorf("failed to create TPM state file: %w", err)
+	}
+	if !opts.KeepTPMState {
+		return "", fmt.Errorf("TPM state file %s already exists, and is only reused with KeepTPMState", path)
+	}
+	// Lstat reports symbolic links and other reparse points as irregular
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat TPM state file: %w", err)
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("TPM state file %s is not a regular file", path)
+	}
+	return path, nil
+}
+
+// TPMEnabled returns if the UVM has a virtual TPM.
+func (uvm *UtilityVM) TPMEnabled() bool {
+	return uvm.tpmEnabled
+}
+
+// removeTPMState removes the file the state of the UVM's virtual TPM persists
+// in, unless it is kept with [Options.KeepTPMState].
+func (uvm *UtilityVM) removeTPMState(ctx context.Context) {
+	if uvm.tpmStateFilePath == "" {
+		return
+	}
+	e := log.G(ctx).WithField("path", uvm.tpmStateFilePath)
+	e.Debug("removing TPM state file")
+	if err := os.Remove(uvm.tpmStateFilePath); err != nil && !os.IsNotExist(err) {
+		e.WithError(err).Error("failed to remove TPM state file")
+	}
+}
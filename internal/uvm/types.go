@@ -40,11 +40,16 @@ type UtilityVM struct {
 	operatingSystem  string               // "windows" or "linux"
 	hcsSystem        *hcs.System          // The handle to the compute system
 	gcListener       net.Listener         // The GCS connection listener
+	gcsServiceID     guid.GUID            // The HvSocket service ID the GCS connects to, if the connection is external
 	gc               *gcs.GuestConnection // The GCS connection
 	processorCount   int32
 	physicallyBacked bool       // If the uvm is backed by physical memory and not virtual memory
 	m                sync.Mutex // Lock for adding/removing devices
 
+	// cpuGroupID is the ID of the host cpugroup the UVM is in, or empty if it
+	// is not in one. Protected by `m`.
+	cpuGroupID string
+
 	exitErr error
 	exitCh  chan struct{}
 
@@ -56,6 +61,11 @@ type UtilityVM struct {
 	protocol  uint32
 	guestCaps gcs.GuestDefinedCapabilities
 
+	// bootTimes is the time taken by each phase of creating and booting the
+	// uVM, protected by bootMu.
+	bootMu    sync.Mutex
+	bootTimes BootTimes
+
 	// containerCounter is the current number of containers that have been created.
 	// This is never decremented in the life of the UVM.
 	containerCounter atomic.Uint64
@@ -77,6 +87,8 @@ type UtilityVM struct {
 	vsmbFileShares  map[string]*VSMBShare
 	vsmbCounter     uint64 // Counter to generate a unique share name for each VSMB share.
 	vsmbNoDirectMap bool   // indicates if VSMB devices should be added with the `NoDirectMap` option
+	// indicates if read-only layers in the same directory should share a VSMB share of the directory
+	vsmbConsolidateLayers bool
 
 	// VPMEM devices that are mapped into a Linux UVM. These are used for read-only layers, or for
 	// booting from VHD.
@@ -87,11 +99,13 @@ type UtilityVM struct {
 	vpmemDevicesMultiMapped [MaxVPMEMCount]*vPMemInfoMulti
 
 	// SCSI devices that are mapped into a Windows or Linux utility VM
-	SCSIManager         *scsi.Manager
-	scsiControllerCount uint32 // Number of SCSI controllers in the utility VM
-	reservedSCSISlots   []scsi.Slot
+	SCSIManager              *scsi.Manager
+	scsiControllerCount      uint32 // Number of SCSI controllers in the utility VM
+	scsiLayerControllerCount uint32 // Number of SCSI controllers, from controller 0, reserved for layers and scratch disks
+	reservedSCSISlots        []scsi.Slot
 
 	encryptScratch bool                         // Enable scratch encryption
+	repairScratch  bool                         // Enable repairing scratch filesystems
 	vpciDevices    map[VPCIDeviceID]*VPCIDevice // map of device instance id to vpci device
 
 	// Plan9 are directories mapped into a Linux utility VM
@@ -102,6 +116,9 @@ type UtilityVM struct {
 	outputListener       net.Listener
 	outputProcessingDone chan struct{}
 	outputHandler        OutputHandler
+	// guestLogs publishes the guest logs parsed from the output, or is nil if
+	// the output is handled by an [OutputHandlerCreator].
+	guestLogs *guestLogBroker
 
 	entropyListener net.Listener
 
@@ -121,6 +138,38 @@ type UtilityVM struct {
 	// Location that container process dumps will get written too.
 	processDumpLocation string
 
+	// guestLogsTailLines is the maximum number of lines written by DumpGuestLogs,
+	// or 0 for [DefaultGuestLogsTailLines].
+	guestLogsTailLines uint32
+
+	// stdioPortsWarningThreshold is passed to the GCS connection, see
+	// [gcs.GuestConnectionConfig.StdioPortsWarningThreshold].
+	stdioPortsWarningThreshold uint32
+
+	// statisticsIntervalSeconds is passed to the GCS connection, see
+	// [gcs.GuestConnectionConfig.StatisticsInterval].
+	statisticsIntervalSeconds int32
+
+	// mmioGaps are the MMIO gaps the UVM was created with.
+	mmioGaps MMIOGaps
+
+	// admitContainers is set if containers are admitted by AdmitContainer
+	// before they are created, with guestReservedMemoryInMB of the memory kept
+	// for the guest OS.
+	admitContainers         bool
+	guestReservedMemoryInMB uint64
+
+	// memorySizeInMB is the memory assigned to the UVM, updated by UpdateMemory,
+	// and memoryEstimate the host memory the UVM is estimated to use with it.
+	// growToFitContainers grows the memory to fit the containers admitted by
+	// AdmitContainer, rather than rejecting them. All are protected by
+	// admissionMu, along with the resources of the admitted containers.
+	admissionMu         sync.Mutex
+	memorySizeInMB      uint64
+	memoryEstimate      MemoryEstimate
+	growToFitContainers bool
+	admittedContainers  map[string]ContainerResources
+
 	// The CreateOpts used to create this uvm. These can be either of type
 	// uvm.OptionsLCOW or uvm.OptionsWCOW
 	createOpts interface{}
@@ -132,6 +181,14 @@ type UtilityVM struct {
 	// networkSetup handles the logic for setting up and tearing down any network configuration
 	// for the Utility VM.
 	networkSetup NetworkSetup
+	// namespaceNetworkSetups are the network setups of the namespaces of pods that share
+	// the Utility VM, by namespace ID, which are used instead of `networkSetup` for those
+	// namespaces. Access is protected by `m`.
+	namespaceNetworkSetups map[string]NetworkSetup
+	// netNSBandwidths are the bandwidths that the guest shapes the traffic of the
+	// adapters of network namespaces to, by namespace ID. LCOW only. Access is
+	// protected by `m`.
+	netNSBandwidths map[string]NetworkBandwidth
 
 	// noInheritHostTimezone specifies whether to not inherit the hosts timezone for the UVM. UTC will be set as the default instead.
 	// This only applies for WCOW.
@@ -140,18 +197,55 @@ type UtilityVM struct {
 	// LCOW only. Indicates whether to use policy based routing when configuring net interfaces in the guest.
 	policyBasedRouting bool
 
+	// LCOW only. Static classless routes, as the payload of the classless static route DHCP
+	// option, that the guest adds to its net interfaces configured with DHCP.
+	classlessStaticRoutes []byte
+
+	// LCOW only. MTU to set on the guest's net interfaces. If zero, it is derived from the
+	// encap overhead of the endpoint's HNS network.
+	networkMTU uint16
+
+	// LCOW only. 802.1Q VLAN ID the guest tags the traffic of its net interfaces with.
+	// If zero, traffic is not tagged.
+	networkVLANTag uint16
+
+	// Cap, in bits per second, on the bandwidth of the traffic sent by each of the UVM's network
+	// endpoints. If zero, the bandwidth is not capped. Protected by `m`.
+	egressBandwidth uint64
+
+	// LCOW only. Indicates whether the guest also shapes the traffic sent on its net interfaces to
+	// `egressBandwidth`.
+	guestEgressShaping bool
+
+	// tpmEnabled indicates whether the UVM has a virtual TPM.
+	tpmEnabled bool
+	// tpmStateFilePath is the file the state of the virtual TPM persists in, to
+	// remove when the UVM is closed, if any.
+	tpmStateFilePath string
+
 	// ref counting for block CIMs
 	blockCIMMounts    map[string]*UVMMountedBlockCIMs
 	blockCIMMountLock sync.Mutex
 
 	forwardLogs bool   // Indicates whether to forward logs from the UVM to the host
 	logSources  string // ETW providers to enable for log forwarding
+
+	// stateFile is the file the state of the UVM is written to, for AttachExisting, if any.
+	stateFile string
+	// stateMu serializes writes of the state file.
+	stateMu sync.Mutex
 }
 
 func (uvm *UtilityVM) ScratchEncryptionEnabled() bool {
 	return uvm.encryptScratch
 }
 
+// ScratchRepairEnabled returns if the guest repairs the filesystem of a
+// scratch disk that is corrupt.
+func (uvm *UtilityVM) ScratchRepairEnabled() bool {
+	return uvm.repairScratch
+}
+
 // OutputHandler is used to process the output from the program run in the UVM.
 type OutputHandler func(io.Reader)
 
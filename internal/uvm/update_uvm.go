@@ -68,5 +68,16 @@ func (uvm *UtilityVM) Update(ctx context.Context, data interface{}, annots map[s
 		}
 	}
 
+	// Check if an annotation was sent to update the egress bandwidth cap
+	if v, ok := annots[annotations.NetworkQoSEgressBandwidthMaximum]; ok {
+		bps, err := ParseEgressBandwidth(v)
+		if err != nil {
+			return err
+		}
+		if err := uvm.UpdateEgressBandwidth(ctx, bps); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
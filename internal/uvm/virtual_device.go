@@ -5,6 +5,9 @@ package uvm
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
 
@@ -31,8 +34,14 @@ const assignedDeviceEnumerator = "VMBUS"
 type VPCIDeviceID struct {
 	deviceInstanceID     string
 	virtualFunctionIndex uint16
+	// additionalFunctions are the virtual function indexes of the other functions of
+	// a multi-function device, as a comma separated list, so that the ID is comparable.
+	additionalFunctions string
 }
 
+// maxVPCIFunctions is the maximum number of functions of a PCI device.
+const maxVPCIFunctions = 8
+
 func NewVPCIDeviceID(deviceInstanceID string, virtualFunctionIndex uint16) VPCIDeviceID {
 	return VPCIDeviceID{
 		deviceInstanceID:     deviceInstanceID,
@@ -51,6 +60,8 @@ type VPCIDevice struct {
 	deviceInstanceID string
 	// virtualFunctionIndex is the function index for the pci device to assign
 	virtualFunctionIndex uint16
+	// key is the key of the device in the UVM's vpciDevices.
+	key VPCIDeviceID
 	// refCount stores the number of references to this device in the UVM
 	refCount uint32
 }
@@ -74,7 +85,7 @@ func (uvm *UtilityVM) GetAssignedDeviceVMBUSInstanceID(vmBusChannelGUID string)
 
 // Release frees the resources of the corresponding vpci device
 func (vpci *VPCIDevice) Release(ctx context.Context) error {
-	if err := vpci.vm.RemoveDevice(ctx, vpci.deviceInstanceID, vpci.virtualFunctionIndex); err != nil {
+	if err := vpci.vm.removeDevice(ctx, vpci.key); err != nil {
 		return fmt.Errorf("failed to remove VPCI device: %w", err)
 	}
 	return nil
@@ -94,6 +105,22 @@ func IsValidDeviceType(deviceType string) bool {
 // to the caller.
 // Allow callers to specify the vmbus guid they want the device to show up with.
 func (uvm *UtilityVM) AssignDevice(ctx context.Context, deviceID string, index uint16, vmBusGUID string) (*VPCIDevice, error) {
+	return uvm.AssignDeviceFunctions(ctx, deviceID, []uint16{index}, vmBusGUID)
+}
+
+// AssignDeviceFunctions assigns the virtual functions `indexes` of a vpci device to a
+// uvm as a single multi-function device, whose functions are numbered in the order of
+// `indexes`, each of which may only appear once. Otherwise, it behaves like
+// [UtilityVM.AssignDevice].
+func (uvm *UtilityVM) AssignDeviceFunctions(ctx context.Context, deviceID string, indexes []uint16, vmBusGUID string) (*VPCIDevice, error) {
+	if len(indexes) == 0 || len(indexes) > maxVPCIFunctions {
+		return nil, fmt.Errorf("device %s must have between 1 and %d functions, got %d", deviceID, maxVPCIFunctions, len(indexes))
+	}
+	for i, index := range indexes {
+		if slices.Contains(indexes[:i], index) {
+			return nil, fmt.Errorf("duplicate virtual function index %d in device %s", index, deviceID)
+		}
+	}
 	if vmBusGUID == "" {
 		guid, err := guid.NewV4()
 		if err != nil {
@@ -102,10 +129,7 @@ func (uvm *UtilityVM) AssignDevice(ctx context.Context, deviceID string, index u
 		vmBusGUID = guid.String()
 	}
 
-	key := VPCIDeviceID{
-		deviceInstanceID:     deviceID,
-		virtualFunctionIndex: index,
-	}
+	key := newMultiFunctionVPCIDeviceID(deviceID, indexes)
 
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
@@ -123,14 +147,14 @@ func (uvm *UtilityVM) AssignDevice(ctx context.Context, deviceID string, index u
 	}
 
 	targetDevice := hcsschema.VirtualPciDevice{
-		Functions: []hcsschema.VirtualPciFunction{
-			{
-				DeviceInstancePath: deviceID,
-				VirtualFunction:    index,
-			},
-		},
 		PropagateNumaAffinity: propagateAffinity,
 	}
+	for _, index := range indexes {
+		targetDevice.Functions = append(targetDevice.Functions, hcsschema.VirtualPciFunction{
+			DeviceInstancePath: deviceID,
+			VirtualFunction:    index,
+		})
+	}
 
 	request := &hcsschema.ModifySettingRequest{
 		ResourcePath: fmt.Sprintf(resourcepaths.VirtualPCIResourceFormat, vmBusGUID),
@@ -144,12 +168,19 @@ func (uvm *UtilityVM) AssignDevice(ctx context.Context, deviceID string, index u
 		// for LCOW, we need to make sure that specific paths relating to the
 		// device exist so they are ready to be used by later
 		// work in openGCS
+		mapped := guestresource.LCOWMappedVPCIDevice{
+			VMBusGUID: vmBusGUID,
+		}
+		if len(indexes) > 1 {
+			// the functions are exposed to the guest in the order they are assigned
+			for i := range indexes {
+				mapped.Functions = append(mapped.Functions, uint(i))
+			}
+		}
 		request.GuestRequest = guestrequest.ModificationRequest{
 			ResourceType: guestresource.ResourceTypeVPCIDevice,
 			RequestType:  guestrequest.RequestTypeAdd,
-			Settings: guestresource.LCOWMappedVPCIDevice{
-				VMBusGUID: vmBusGUID,
-			},
+			Settings:     mapped,
 		}
 	}
 
@@ -161,27 +192,39 @@ func (uvm *UtilityVM) AssignDevice(ctx context.Context, deviceID string, index u
 		VMBusGUID:            vmBusGUID,
 		deviceInstanceID:     key.deviceInstanceID,
 		virtualFunctionIndex: key.virtualFunctionIndex,
+		key:                  key,
 		refCount:             1,
 	}
 	uvm.vpciDevices[key] = device
 	return device, nil
 }
 
+// newMultiFunctionVPCIDeviceID returns the ID of the device with instance ID `deviceID`
+// and virtual functions `indexes`.
+func newMultiFunctionVPCIDeviceID(deviceID string, indexes []uint16) VPCIDeviceID {
+	key := NewVPCIDeviceID(deviceID, indexes[0])
+	var additional []string
+	for _, index := range indexes[1:] {
+		additional = append(additional, strconv.FormatUint(uint64(index), 10))
+	}
+	key.additionalFunctions = strings.Join(additional, ",")
+	return key
+}
+
 // RemoveDevice removes a vpci device from a uvm when there are
 // no more references to a given VPCIDevice. Otherwise, decrements
 // the reference count of the stored VPCIDevice and returns nil.
 func (uvm *UtilityVM) RemoveDevice(ctx context.Context, deviceInstanceID string, index uint16) error {
-	key := VPCIDeviceID{
-		deviceInstanceID:     deviceInstanceID,
-		virtualFunctionIndex: index,
-	}
+	return uvm.removeDevice(ctx, NewVPCIDeviceID(deviceInstanceID, index))
+}
 
+func (uvm *UtilityVM) removeDevice(ctx context.Context, key VPCIDeviceID) error {
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
 
 	vpci := uvm.vpciDevices[key]
 	if vpci == nil {
-		return fmt.Errorf("no device with ID %s and index %d is present on the uvm %s", deviceInstanceID, index, uvm.ID())
+		return fmt.Errorf("no device with ID %s and index %d is present on the uvm %s", key.deviceInstanceID, key.virtualFunctionIndex, uvm.ID())
 	}
 
 	vpci.refCount--
@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"unsafe"
 
 	"github.com/sirupsen/logrus"
@@ -40,16 +42,35 @@ type VSMBShare struct {
 	// whether the share is mapping an entire directory.
 	// ie, if the share is stored in [vm.vsmbDirShares] or [vm.vsmbFileShares].
 	isDirShare bool
+	// subRefs counts the references to subdirectories of the share added with
+	// [UtilityVM.AddVSMBSubPath], by their path relative to HostPath. They are
+	// included in refCount.
+	subRefs map[string]uint32
+	// parentPath is set if the VSMBShare is a reference to the subdirectory HostPath
+	// of the share of parentPath, rather than a share of its own.
+	parentPath string
 }
 
 // Release frees the resources of the corresponding vsmb Mount
 func (vsmb *VSMBShare) Release(ctx context.Context) error {
-	if err := vsmb.vm.removeVSMB(ctx, vsmb.HostPath, vsmb.options.ReadOnly, vsmb.isDirShare); err != nil {
+	var err error
+	if vsmb.parentPath != "" {
+		err = vsmb.vm.removeVSMBSubPath(ctx, vsmb.parentPath, vsmb.HostPath, vsmb.options.ReadOnly)
+	} else {
+		err = vsmb.vm.removeVSMB(ctx, vsmb.HostPath, vsmb.options.ReadOnly, vsmb.isDirShare)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to remove VSMB share: %w", err)
 	}
 	return nil
 }
 
+// GuestPath returns the path of the share, or of the subdirectory of the share,
+// in the utility VM.
+func (vsmb *VSMBShare) GuestPath() string {
+	return vsmb.guestPath
+}
+
 // DefaultVSMBOptions returns the default VSMB options. If readOnly is specified,
 // returns the default VSMB options for a readonly share.
 func (uvm *UtilityVM) DefaultVSMBOptions(readOnly bool) *hcsschema.VirtualSmbShareOptions {
@@ -156,6 +177,8 @@ func (uvm *UtilityVM) AddVSMB(ctx context.Context, hostPath string, options *hcs
 		return nil, fmt.Errorf("adding writable shares is denied: %w", hcs.ErrOperationDenied)
 	}
 
+	// deferred before locking, so that the state is saved once uvm.m is unlocked
+	defer uvm.stateChanged(ctx)
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
 
@@ -241,6 +264,134 @@ func (uvm *UtilityVM) AddVSMB(ctx context.Context, hostPath string, options *hcs
 	return share, nil
 }
 
+// ErrVSMBParentNotExclusive is returned by [UtilityVM.AddVSMBSubPath] if the parent
+// directory holds anything other than the directories that may be shared through it.
+var ErrVSMBParentNotExclusive = errors.New("VSMB share parent directory holds other entries")
+
+// AddVSMBSubPath adds a reference to the directory `hostPath` through a VSMB share of
+// its ancestor directory `parentPath`, adding the share if it isn't already. This allows
+// multiple directories, such as read-only layers, to be shared into the utility VM
+// without using a share for each.
+//
+// The utility VM can read all of the share, so `parentPath` must hold nothing but
+// `hostPath`, the directories in `siblings`, and the directories already referenced
+// through the share. This is checked every time the share is referenced, and
+// [ErrVSMBParentNotExclusive] is returned otherwise.
+//
+// The returned VSMBShare is for `hostPath`, and its guest path is within the share of
+// `parentPath`. Releasing it only removes the share of `parentPath` once there are no
+// other references to the share or its subdirectories.
+func (uvm *UtilityVM) AddVSMBSubPath(ctx context.Context, parentPath, hostPath string, siblings []string, options *hcsschema.VirtualSmbShareOptions) (*VSMBShare, error) {
+	parentPath = filepath.Clean(parentPath)
+	hostPath = filepath.Clean(hostPath)
+	rel, err := filepath.Rel(parentPath, hostPath)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is not a subdirectory of %s", hostPath, parentPath)
+	}
+	if st, err := os.Stat(hostPath); err != nil {
+		return nil, err
+	} else if !st.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", hostPath)
+	}
+
+	parent, err := uvm.AddVSMB(ctx, parentPath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := uvm.addVSMBSubRef(ctx, parent, parentPath, hostPath, rel, siblings)
+	if err != nil {
+		if rErr := uvm.removeVSMB(ctx, parentPath, parent.options.ReadOnly, true); rErr != nil {
+			log.G(ctx).WithError(rErr).Warn("failed to remove VSMB share on cleanup")
+		}
+		return nil, err
+	}
+	return share, nil
+}
+
+// addVSMBSubRef adds a reference to the subdirectory `rel` of the share `parent` of
+// `parentPath`, if `parentPath` holds nothing but the directories that may be shared
+// through it.
+func (uvm *UtilityVM) addVSMBSubRef(ctx context.Context, parent *VSMBShare, parentPath, hostPath, rel string, siblings []string) (*VSMBShare, error) {
+	// deferred before locking, so that the state is saved once uvm.m is unlocked
+	defer uvm.stateChanged(ctx)
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	allowed := []string{hostPath}
+	for _, p := range siblings {
+		allowed = append(allowed, filepath.Clean(p))
+	}
+	for r := range parent.subRefs {
+		allowed = append(allowed, filepath.Join(parentPath, r))
+	}
+	if !onlyHolds(parentPath, allowed) {
+		return nil, fmt.Errorf("%s: %w", parentPath, ErrVSMBParentNotExclusive)
+	}
+
+	if parent.subRefs == nil {
+		parent.subRefs = make(map[string]uint32)
+	}
+	parent.subRefs[rel]++
+	log.G(ctx).WithFields(logrus.Fields{
+		"name":    parent.name,
+		"path":    parentPath,
+		"subPath": rel,
+		"refs":    parent.refCount,
+	}).Debug("added reference to VSMB share subdirectory")
+
+	return &VSMBShare{
+		vm:         uvm,
+		HostPath:   hostPath,
+		name:       parent.name,
+		guestPath:  filepath.Join(parent.guestPath, rel),
+		options:    parent.options,
+		isDirShare: true,
+		parentPath: parentPath,
+	}, nil
+}
+
+// onlyHolds returns if the directory `dir` holds nothing but `paths`.
+func onlyHolds(dir string, paths []string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if !slices.Contains(paths, filepath.Join(dir, e.Name())) {
+			return false
+		}
+	}
+	return true
+}
+
+// removeVSMBSubPath removes a reference to the subdirectory `hostPath` of the share of
+// `parentPath`, which is removed if there are no other references to it.
+func (uvm *UtilityVM) removeVSMBSubPath(ctx context.Context, parentPath, hostPath string, readOnly bool) error {
+	rel, err := filepath.Rel(parentPath, hostPath)
+	if err != nil {
+		return err
+	}
+
+	uvm.m.Lock()
+	share, err := uvm.findVSMBShare(ctx, uvm.vsmbDirShares, getVSMBShareKey(parentPath, readOnly))
+	if err == nil {
+		if share.subRefs[rel] == 0 {
+			err = fmt.Errorf("%s is not referenced through VSMB share %s in %s", hostPath, parentPath, uvm.id)
+		} else if share.subRefs[rel]--; share.subRefs[rel] == 0 {
+			delete(share.subRefs, rel)
+		}
+	}
+	uvm.m.Unlock()
+	if err != nil {
+		return err
+	}
+	return uvm.removeVSMB(ctx, parentPath, readOnly, true)
+}
+
 // RemoveVSMB removes a VSMB share from a utility VM. Each VSMB share is ref-counted
 // and only actually removed when the ref-count drops to zero.
 func (uvm *UtilityVM) RemoveVSMB(ctx context.Context, hostPath string, readOnly bool) error {
@@ -268,6 +419,8 @@ func (uvm *UtilityVM) removeVSMB(ctx context.Context, hostPath string, readOnly,
 		return errNotSupported
 	}
 
+	// deferred before locking, so that the state is saved once uvm.m is unlocked
+	defer uvm.stateChanged(ctx)
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
 
@@ -0,0 +1,63 @@
+//go:build windows
+
+package uvm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_onlyHolds(t *testing.T) {
+	// root/layers only holds layers, root/shared also holds another directory
+	root := t.TempDir()
+	for _, d := range [][]string{{"layers", "1"}, {"layers", "2"}, {"shared", "1"}, {"shared", "other"}} {
+		if err := os.MkdirAll(filepath.Join(root, d[0], d[1]), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	layers := filepath.Join(root, "layers")
+	shared := filepath.Join(root, "shared")
+	for _, tc := range []struct {
+		name     string
+		dir      string
+		paths    []string
+		expected bool
+	}{
+		{
+			name:     "only paths",
+			dir:      layers,
+			paths:    []string{filepath.Join(layers, "1"), filepath.Join(layers, "2")},
+			expected: true,
+		},
+		{
+			name:     "more paths than entries",
+			dir:      layers,
+			paths:    []string{filepath.Join(layers, "1"), filepath.Join(layers, "2"), filepath.Join(layers, "3")},
+			expected: true,
+		},
+		{
+			name:     "other paths",
+			dir:      layers,
+			paths:    []string{filepath.Join(layers, "1")},
+			expected: false,
+		},
+		{
+			name:     "other directory",
+			dir:      shared,
+			paths:    []string{filepath.Join(shared, "1")},
+			expected: false,
+		},
+		{
+			name:     "missing directory",
+			dir:      filepath.Join(root, "missing"),
+			expected: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := onlyHolds(tc.dir, tc.paths); got != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ package hcs
 import (
 	"context"
 
+	"github.com/Microsoft/go-winio/pkg/guid"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
@@ -87,10 +88,12 @@ func (uvmb *utilityVMBuilder) Create(ctx context.Context) (_ vm.UVM, err error)
 		backingType: backingType,
 	}
 
-	properties, err := cs.Properties(ctx)
+	runtimeID, err := cs.GetRuntimeID(ctx)
 	if err != nil {
 		return nil, err
 	}
-	uvm.vmID = properties.RuntimeID
+	if uvm.vmID, err = guid.FromString(runtimeID); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse runtime ID %q", runtimeID)
+	}
 	return uvm, nil
 }
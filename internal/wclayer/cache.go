@@ -0,0 +1,96 @@
+//go:build windows
+
+package wclayer
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// guidCacheSize is the maximum number of GUIDs of names that are cached.
+const guidCacheSize = 256
+
+// cacheDisabled disables the cache of GUIDs of names.
+var cacheDisabled atomic.Bool
+
+// SetCacheEnabled enables or disables caching the GUIDs returned by
+// [NameToGuid], to debug the calls to the Host Compute Service. Disabling the
+// cache clears it. The cache is enabled by default.
+//
+// Only the GUIDs are cached, since they are derived from the names alone. The
+// mount paths of layers are not: they change when a layer is deactivated, which
+// other processes, such as another shim or the snapshotter, can do without this
+// process knowing.
+func SetCacheEnabled(enabled bool) {
+	cacheDisabled.Store(!enabled)
+	if !enabled {
+		guids.clear()
+	}
+}
+
+var guids = newLRU[guid.GUID](guidCacheSize)
+
+// lru is a cache of at most `size` values by key, which evicts the least
+// recently used value.
+type lru[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+	size    int
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRU[V any](size int) *lru[V] {
+	return &lru[V]{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		size:    size,
+	}
+}
+
+func (c *lru[V]) get(key string) (v V, ok bool) {
+	if cacheDisabled.Load() {
+		return v, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return v, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry[V]).value, true
+}
+
+func (c *lru[V]) put(key string, v V) {
+	if cacheDisabled.Load() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*lruEntry[V]).value = v
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: v})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lru[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.order.Init()
+}
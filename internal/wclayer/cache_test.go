@@ -0,0 +1,87 @@
+//go:build windows
+
+package wclayer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func TestLRU(t *testing.T) {
+	c := newLRU[guid.GUID](2)
+	ids := []guid.GUID{{Data1: 1}, {Data1: 2}, {Data1: 3}}
+
+	c.put("a", ids[0])
+	c.put("b", ids[1])
+	if id, ok := c.get("a"); !ok || id != ids[0] {
+		t.Fatalf("got %v, %t; expected %v", id, ok, ids[0])
+	}
+	// evicts b, the least recently used
+	c.put("c", ids[2])
+	if _, ok := c.get("b"); ok {
+		t.Fatal("got evicted entry b")
+	}
+	for i, k := range []string{"a", "c"} {
+		if id, ok := c.get(k); !ok || id != ids[2*i] {
+			t.Fatalf("got %v, %t for %s; expected %v", id, ok, k, ids[2*i])
+		}
+	}
+	c.clear()
+	if _, ok := c.get("a"); ok {
+		t.Fatal("got entry a after clearing")
+	}
+}
+
+func TestLRU_Disabled(t *testing.T) {
+	defer cacheDisabled.Store(cacheDisabled.Load())
+	c := newLRU[guid.GUID](2)
+
+	cacheDisabled.Store(true)
+	c.put("a", guid.GUID{Data1: 1})
+	if _, ok := c.get("a"); ok {
+		t.Fatal("got entry a from a disabled cache")
+	}
+	cacheDisabled.Store(false)
+	if _, ok := c.get("a"); ok {
+		t.Fatal("got entry a that was put while the cache was disabled")
+	}
+}
+
+// benchmarkNameToGuid resolves the GUIDs of the layers of an image for each of
+// `containers` containers created from it, as creating a container does, with
+// calls to the Host Compute Service.
+func benchmarkNameToGuid(b *testing.B, enabled bool) {
+	const (
+		containers = 20
+		layers     = 8
+	)
+	defer SetCacheEnabled(!cacheDisabled.Load())
+	SetCacheEnabled(enabled)
+
+	ctx := context.Background()
+	names := make([]string, layers)
+	for i := range names {
+		names[i] = fmt.Sprintf("%064x", i)
+	}
+	if _, err := NameToGuid(ctx, names[0]); err != nil {
+		b.Skipf("the Host Compute Service is not available: %v", err)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for range containers {
+			for _, name := range names {
+				if _, err := NameToGuid(ctx, name); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkNameToGuid_CreateContainersFromSameImage(b *testing.B) {
+	b.Run("Cached", func(b *testing.B) { benchmarkNameToGuid(b, true) })
+	b.Run("Uncached", func(b *testing.B) { benchmarkNameToGuid(b, false) })
+}
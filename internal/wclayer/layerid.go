@@ -19,6 +19,8 @@ func LayerID(ctx context.Context, path string) (_ guid.GUID, err error) {
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("path", path))
 
-	_, file := filepath.Split(path)
+	// Clean the path so trailing separators (eg, `\\server\share\layer\`) do not
+	// result in an empty layer name.
+	_, file := filepath.Split(filepath.Clean(path))
 	return NameToGuid(ctx, file)
 }
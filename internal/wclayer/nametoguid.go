@@ -13,7 +13,7 @@ import (
 
 // NameToGuid converts the given string into a GUID using the algorithm in the
 // Host Compute Service, ensuring GUIDs generated with the same string are common
-// across all clients.
+// across all clients. The GUIDs are cached, see SetCacheEnabled.
 func NameToGuid(ctx context.Context, name string) (_ guid.GUID, err error) {
 	title := "hcsshim::NameToGuid"
 	ctx, span := oc.StartSpan(ctx, title) //nolint:ineffassign,staticcheck
@@ -21,10 +21,13 @@ func NameToGuid(ctx context.Context, name string) (_ guid.GUID, err error) {
 	defer func() { oc.SetSpanStatus(span, err) }()
 	span.AddAttributes(trace.StringAttribute("objectName", name))
 
-	var id guid.GUID
-	err = nameToGuid(name, &id)
-	if err != nil {
-		return guid.GUID{}, hcserror.New(err, title, "")
+	id, ok := guids.get(name)
+	if !ok {
+		err = nameToGuid(name, &id)
+		if err != nil {
+			return guid.GUID{}, hcserror.New(err, title, "")
+		}
+		guids.put(name, id)
 	}
 	span.AddAttributes(trace.StringAttribute("guid", id.String()))
 	return id, nil
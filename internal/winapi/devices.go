@@ -15,3 +15,13 @@ type DevPropKey struct {
 	Fmtid guid.GUID
 	Pid   uint32
 }
+
+// The following return the CONFIGRET of the call, which is not an HRESULT, so that callers
+// can tell the CR_NO_MORE_* results apart from failures.
+//
+//sys CMGetFirstLogConf(plcLogConf *uintptr, dnDevInst uint32, ulFlags uint32) (cr uint32) = cfgmgr32.CM_Get_First_Log_Conf
+//sys CMGetNextResDes(prdResDes *uintptr, rdResDes uintptr, forResource uint32, pResourceID *uint32, ulFlags uint32) (cr uint32) = cfgmgr32.CM_Get_Next_Res_Des
+//sys CMGetResDesDataSize(pulSize *uint32, rdResDes uintptr, ulFlags uint32) (cr uint32) = cfgmgr32.CM_Get_Res_Des_Data_Size
+//sys CMGetResDesData(rdResDes uintptr, buffer *byte, bufferLen uint32, ulFlags uint32) (cr uint32) = cfgmgr32.CM_Get_Res_Des_Data
+//sys CMFreeResDesHandle(rdResDes uintptr) (cr uint32) = cfgmgr32.CM_Free_Res_Des_Handle
+//sys CMFreeLogConfHandle(lcLogConf uintptr) (cr uint32) = cfgmgr32.CM_Free_Log_Conf_Handle
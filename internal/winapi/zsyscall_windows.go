@@ -53,11 +53,17 @@ var (
 	procSnpPspFetchAttestationReport           = modamdsnppspapi.NewProc("SnpPspFetchAttestationReport")
 	procSnpPspIsSnpMode                        = modamdsnppspapi.NewProc("SnpPspIsSnpMode")
 	procBfSetupFilter                          = modbindfltapi.NewProc("BfSetupFilter")
+	procCM_Free_Log_Conf_Handle                = modcfgmgr32.NewProc("CM_Free_Log_Conf_Handle")
+	procCM_Free_Res_Des_Handle                 = modcfgmgr32.NewProc("CM_Free_Res_Des_Handle")
 	procCM_Get_DevNode_PropertyW               = modcfgmgr32.NewProc("CM_Get_DevNode_PropertyW")
 	procCM_Get_Device_ID_ListA                 = modcfgmgr32.NewProc("CM_Get_Device_ID_ListA")
 	procCM_Get_Device_ID_List_SizeA            = modcfgmgr32.NewProc("CM_Get_Device_ID_List_SizeA")
 	procCM_Get_Device_Interface_ListW          = modcfgmgr32.NewProc("CM_Get_Device_Interface_ListW")
 	procCM_Get_Device_Interface_List_SizeW     = modcfgmgr32.NewProc("CM_Get_Device_Interface_List_SizeW")
+	procCM_Get_First_Log_Conf                  = modcfgmgr32.NewProc("CM_Get_First_Log_Conf")
+	procCM_Get_Next_Res_Des                    = modcfgmgr32.NewProc("CM_Get_Next_Res_Des")
+	procCM_Get_Res_Des_Data                    = modcfgmgr32.NewProc("CM_Get_Res_Des_Data")
+	procCM_Get_Res_Des_Data_Size               = modcfgmgr32.NewProc("CM_Get_Res_Des_Data_Size")
 	procCM_Locate_DevNodeW                     = modcfgmgr32.NewProc("CM_Locate_DevNodeW")
 	procCimDismountImage                       = modcimfs.NewProc("CimDismountImage")
 	procCimGetVerificationInformation          = modcimfs.NewProc("CimGetVerificationInformation")
@@ -171,6 +177,18 @@ func BfSetupFilter(jobHandle windows.Handle, flags uint32, virtRootPath *uint16,
 	return
 }
 
+func CMFreeLogConfHandle(lcLogConf uintptr) (cr uint32) {
+	r0, _, _ := syscall.SyscallN(procCM_Free_Log_Conf_Handle.Addr(), uintptr(lcLogConf))
+	cr = uint32(r0)
+	return
+}
+
+func CMFreeResDesHandle(rdResDes uintptr) (cr uint32) {
+	r0, _, _ := syscall.SyscallN(procCM_Free_Res_Des_Handle.Addr(), uintptr(rdResDes))
+	cr = uint32(r0)
+	return
+}
+
 func CMGetDevNodeProperty(dnDevInst uint32, propertyKey *DevPropKey, propertyType *uint32, propertyBuffer *uint16, propertyBufferSize *uint32, uFlags uint32) (hr error) {
 	r0, _, _ := syscall.SyscallN(procCM_Get_DevNode_PropertyW.Addr(), uintptr(dnDevInst), uintptr(unsafe.Pointer(propertyKey)), uintptr(unsafe.Pointer(propertyType)), uintptr(unsafe.Pointer(propertyBuffer)), uintptr(unsafe.Pointer(propertyBufferSize)), uintptr(uFlags))
 	if int32(r0) < 0 {
@@ -226,6 +244,30 @@ func CMGetDeviceInterfaceListSize(listlen *uint32, classGUID *g, deviceID *uint1
 	return
 }
 
+func CMGetFirstLogConf(plcLogConf *uintptr, dnDevInst uint32, ulFlags uint32) (cr uint32) {
+	r0, _, _ := syscall.SyscallN(procCM_Get_First_Log_Conf.Addr(), uintptr(unsafe.Pointer(plcLogConf)), uintptr(dnDevInst), uintptr(ulFlags))
+	cr = uint32(r0)
+	return
+}
+
+func CMGetNextResDes(prdResDes *uintptr, rdResDes uintptr, forResource uint32, pResourceID *uint32, ulFlags uint32) (cr uint32) {
+	r0, _, _ := syscall.SyscallN(procCM_Get_Next_Res_Des.Addr(), uintptr(unsafe.Pointer(prdResDes)), uintptr(rdResDes), uintptr(forResource), uintptr(unsafe.Pointer(pResourceID)), uintptr(ulFlags))
+	cr = uint32(r0)
+	return
+}
+
+func CMGetResDesData(rdResDes uintptr, buffer *byte, bufferLen uint32, ulFlags uint32) (cr uint32) {
+	r0, _, _ := syscall.SyscallN(procCM_Get_Res_Des_Data.Addr(), uintptr(rdResDes), uintptr(unsafe.Pointer(buffer)), uintptr(bufferLen), uintptr(ulFlags))
+	cr = uint32(r0)
+	return
+}
+
+func CMGetResDesDataSize(pulSize *uint32, rdResDes uintptr, ulFlags uint32) (cr uint32) {
+	r0, _, _ := syscall.SyscallN(procCM_Get_Res_Des_Data_Size.Addr(), uintptr(unsafe.Pointer(pulSize)), uintptr(rdResDes), uintptr(ulFlags))
+	cr = uint32(r0)
+	return
+}
+
 func CMLocateDevNode(pdnDevInst *uint32, pDeviceID string, uFlags uint32) (hr error) {
 	var _p0 *uint16
 	_p0, hr = syscall.UTF16PtrFromString(pDeviceID)
@@ -0,0 +1,133 @@
+//go:build windows
+
+package windevice
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/Microsoft/hcsshim/internal/winapi"
+)
+
+const (
+	_ALLOC_LOG_CONF uint32 = 0x00000002
+	_BOOT_LOG_CONF  uint32 = 0x00000003
+
+	_ResType_Mem      uint32 = 0x00000001
+	_ResType_MemLarge uint32 = 0x00000007
+
+	_CR_SUCCESS          uint32 = 0x00000000
+	_CR_NO_MORE_LOG_CONF uint32 = 0x0000000E
+	_CR_NO_MORE_RES_DES  uint32 = 0x0000000F
+
+	// size of MEM_DES and MEM_LARGE_DES, which share the layout:
+	// Count, Type uint32; Alloc_Base, Alloc_End uint64; Flags, Reserved uint32
+	memDesSize = 32
+)
+
+// MemoryRange is a range of memory-mapped I/O space used by a device, such as
+// one of the BARs of a PCI device.
+type MemoryRange struct {
+	Base uint64
+	Size uint64
+}
+
+// End returns the address after the last byte of the range.
+func (r MemoryRange) End() uint64 {
+	return r.Base + r.Size
+}
+
+// GetDeviceMemoryRanges returns the memory ranges of the device with instance
+// ID `id`. The ranges are those allocated to the device, or if the device has
+// no allocated resources, such as when it is disabled to be assigned to a VM,
+// the ranges the firmware assigned it at boot.
+func GetDeviceMemoryRanges(id string) ([]MemoryRange, error) {
+	var devNodeInst uint32
+	if err := winapi.CMLocateDevNode(&devNodeInst, id, _CM_LOCATE_DEVNODE_NORMAL); err != nil {
+		return nil, errors.Wrapf(err, "failed to locate device node for %s", id)
+	}
+	for _, flags := range []uint32{_ALLOC_LOG_CONF, _BOOT_LOG_CONF} {
+		ranges, err := logConfMemoryRanges(devNodeInst, flags)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get memory resources of device %s", id)
+		}
+		if len(ranges) > 0 {
+			return ranges, nil
+		}
+	}
+	return nil, nil
+}
+
+// logConfMemoryRanges returns the memory ranges of the `flags` logical
+// configuration of the device node `devNodeInst`.
+func logConfMemoryRanges(devNodeInst, flags uint32) (_ []MemoryRange, err error) {
+	var logConf uintptr
+	switch cr := winapi.CMGetFirstLogConf(&logConf, devNodeInst, flags); cr {
+	case _CR_SUCCESS:
+	case _CR_NO_MORE_LOG_CONF:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("CM_Get_First_Log_Conf failed with CONFIGRET %#x", cr)
+	}
+	defer winapi.CMFreeLogConfHandle(logConf)
+
+	var ranges []MemoryRange
+	for _, resType := range []uint32{_ResType_Mem, _ResType_MemLarge} {
+		// the first resource descriptor is found from the logical configuration
+		current := logConf
+		for {
+			var resDes uintptr
+			cr := winapi.CMGetNextResDes(&resDes, current, resType, nil, 0)
+			if current != logConf {
+				winapi.CMFreeResDesHandle(current)
+			}
+			if cr == _CR_NO_MORE_RES_DES {
+				break
+			}
+			if cr != _CR_SUCCESS {
+				return nil, fmt.Errorf("CM_Get_Next_Res_Des failed with CONFIGRET %#x", cr)
+			}
+			current = resDes
+
+			r, err := resDesMemoryRange(resDes)
+			if err != nil {
+				winapi.CMFreeResDesHandle(resDes)
+				return nil, err
+			}
+			if r.Size != 0 {
+				ranges = append(ranges, r)
+			}
+		}
+	}
+	return ranges, nil
+}
+
+// resDesMemoryRange returns the range of the memory resource descriptor
+// `resDes`, from the MEM_DES or MEM_LARGE_DES header of its data.
+func resDesMemoryRange(resDes uintptr) (MemoryRange, error) {
+	var size uint32
+	if cr := winapi.CMGetResDesDataSize(&size, resDes, 0); cr != _CR_SUCCESS {
+		return MemoryRange{}, fmt.Errorf("CM_Get_Res_Des_Data_Size failed with CONFIGRET %#x", cr)
+	}
+	if size < memDesSize {
+		return MemoryRange{}, fmt.Errorf("memory resource descriptor is too small: %d bytes", size)
+	}
+	buf := make([]byte, size)
+	if cr := winapi.CMGetResDesData(resDes, &buf[0], size, 0); cr != _CR_SUCCESS {
+		return MemoryRange{}, fmt.Errorf("CM_Get_Res_Des_Data failed with CONFIGRET %#x", cr)
+	}
+	return parseMemDes(buf), nil
+}
+
+// parseMemDes returns the range in the MEM_DES or MEM_LARGE_DES header `b`,
+// which must be at least memDesSize bytes. An unallocated range has size 0.
+func parseMemDes(b []byte) MemoryRange {
+	base := binary.LittleEndian.Uint64(b[8:16])
+	end := binary.LittleEndian.Uint64(b[16:24])
+	if end < base {
+		return MemoryRange{}
+	}
+	return MemoryRange{Base: base, Size: end - base + 1}
+}
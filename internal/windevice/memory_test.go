@@ -0,0 +1,53 @@
+//go:build windows
+
+package windevice
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseMemDes(t *testing.T) {
+	memDes := func(base, end uint64) []byte {
+		b := make([]byte, memDesSize)
+		binary.LittleEndian.PutUint32(b[0:4], 1)
+		binary.LittleEndian.PutUint32(b[4:8], 1)
+		binary.LittleEndian.PutUint64(b[8:16], base)
+		binary.LittleEndian.PutUint64(b[16:24], end)
+		return b
+	}
+
+	for _, tc := range []struct {
+		name      string
+		b         []byte
+		expected  MemoryRange
+		expectEnd uint64
+	}{
+		{
+			name:      "32 bit BAR",
+			b:         memDes(0xf000_0000, 0xf0ff_ffff),
+			expected:  MemoryRange{Base: 0xf000_0000, Size: 16 << 20},
+			expectEnd: 0xf100_0000,
+		},
+		{
+			name:      "large BAR",
+			b:         memDes(0x38_0000_0000, 0x3b_ffff_ffff),
+			expected:  MemoryRange{Base: 0x38_0000_0000, Size: 16 << 30},
+			expectEnd: 0x3c_0000_0000,
+		},
+		{
+			name: "unallocated",
+			b:    memDes(0xffff_ffff, 0),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := parseMemDes(tc.b)
+			if r != tc.expected {
+				t.Fatalf("expected range %+v, got %+v", tc.expected, r)
+			}
+			if r.Size != 0 && r.End() != tc.expectEnd {
+				t.Fatalf("expected end %#x, got %#x", tc.expectEnd, r.End())
+			}
+		})
+	}
+}
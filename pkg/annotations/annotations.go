@@ -70,6 +70,27 @@ const (
 	// `WindowsPodSandboxConfig` for setting this correctly. It should not be
 	// used via OCI runtimes and rather use `spec.Windows.Resources.CPU.Shares`.
 	ContainerProcessorWeight = "io.microsoft.container.processor.weight"
+
+	// ContainerRDTClass assigns an LCOW container to an Intel RDT (Resource Director Technology)
+	// class of service, which limits the share of the uVM's last level cache (LLC) ways and memory
+	// bandwidth that the container's processes may use. The supported classes are [RDTClassCATHigh],
+	// [RDTClassCATMedium], and [RDTClassCATLow].
+	//
+	// Containers with the same class share the class's allocation, rather than each receiving
+	// their own. Requires a uVM kernel, processor and runc with RDT support.
+	ContainerRDTClass = "io.microsoft.container.rdtclass"
+)
+
+// Intel RDT classes of service for the [ContainerRDTClass] annotation.
+const (
+	// RDTClassCATHigh allows the whole cache and memory bandwidth.
+	RDTClassCATHigh = "CATHigh"
+
+	// RDTClassCATMedium allows half of the cache ways and memory bandwidth.
+	RDTClassCATMedium = "CATMedium"
+
+	// RDTClassCATLow allows a quarter of the cache ways and memory bandwidth.
+	RDTClassCATLow = "CATLow"
 )
 
 // Container storage (Quality of Service) annotations.
@@ -91,6 +112,45 @@ const (
 	// used via OCI runtimes and rather use
 	// `spec.Windows.Resources.Storage.Iops`.
 	ContainerStorageQoSIopsMaximum = "io.microsoft.container.storage.qos.iopsmaximum"
+
+	// ContainerStorageIOWeight sets the proportional weight, from 1 to 10000
+	// (100 by default), of an LCOW container's I/O on the devices it shares
+	// with other containers in the UVM, as the cgroup v2 io.weight. It is
+	// applied when the container is created or updated.
+	//
+	// Note: With cgroup v1 in the UVM, the weight is converted to the
+	// equivalent blkio weight.
+	ContainerStorageIOWeight = "io.microsoft.container.storage.io-weight"
+
+	// ContainerRootFSSizeInGB limits the disk space, in GB, that an LCOW
+	// container may use in its scratch. This is enforced with a project quota
+	// in the guest, so that containers sharing the UVM scratch cannot exhaust
+	// it for each other. Writes beyond the limit fail as if the scratch were
+	// full. The scratch usage is reported in the container's statistics.
+	//
+	// Note: This is only supported for LCOW, and requires the scratch to be
+	// formatted with the ext4 `quota` and `project` features.
+	ContainerRootFSSizeInGB = "io.microsoft.container.storage.rootfs.size-gb"
+
+	// ContainerSCSICheckCapacity fails the creation of a hypervisor-isolated container
+	// whose SCSI disk mounts (physical-disk, virtual-disk and extensible-virtual-disk)
+	// cannot all fit in the free SCSI slots of the UVM, before any of them are attached,
+	// rather than after attaching the disks that fit. Disks that are already attached to
+	// the UVM do not need a slot.
+	ContainerSCSICheckCapacity = "io.microsoft.container.storage.scsi.check-capacity"
+
+	// ContainerTmpfsPath specifies the absolute path in an LCOW container at which to mount a
+	// tmpfs, in addition to the mounts in the container spec.
+	//
+	// Note: This is only supported for LCOW.
+	ContainerTmpfsPath = "io.microsoft.container.tmpfs.path"
+
+	// ContainerTmpfsSizeBytes limits the size, in bytes, of the tmpfs mounted at
+	// [ContainerTmpfsPath]. Writes beyond the limit fail with ENOSPC. The default is the
+	// default size of a tmpfs, which is half of the memory of the UVM.
+	//
+	// Note: This is only supported for LCOW, and requires [ContainerTmpfsPath] to be set.
+	ContainerTmpfsSizeBytes = "io.microsoft.container.tmpfs.sizebytes"
 )
 
 // LCOW container annotations.
@@ -106,6 +166,80 @@ const (
 	// LCOWPrivileged is used to specify that the container should be run in privileged mode.
 	LCOWPrivileged = "io.microsoft.virtualmachine.lcow.privileged"
 
+	// LCOWRequireSeccompProfileSupport fails the creation of an LCOW container with a seccomp
+	// profile if the guest does not report that it applies seccomp profiles, rather than relying
+	// on older guests to apply it. A confidential policy that needs the profile applied can
+	// require this annotation; the guest already checks the profile itself against the policy.
+	// The default is false.
+	LCOWRequireSeccompProfileSupport = "io.microsoft.container.lcow.seccomp.require-support"
+
+	// LCOWIgnoreLSMLabels ignores the AppArmor profile and SELinux labels requested by the spec of
+	// an LCOW container or exec, logging a warning, rather than failing to create it. The LCOW
+	// guest kernel has neither Linux security module (LSM), so the labels are never applied.
+	// This is intended for clusters that set default labels that cannot be removed.
+	// The default is false.
+	LCOWIgnoreLSMLabels = "io.microsoft.container.lcow.ignore-lsm-labels"
+
+	// LCOWTPMDevice gives an LCOW container access to the virtual TPM of its uVM, added with
+	// [UVMEnableTPM], through the `/dev/tpm0` and `/dev/tpmrm0` devices. For a uVM with a
+	// security policy, the policy must allow it.
+	// The default is false.
+	LCOWTPMDevice = "io.microsoft.container.lcow.tpm-device"
+
+	// ContainerReadonlyLayers specifies additional read-only layers to add to an LCOW
+	// container's image layers, such as a layer containing an observability agent, as a
+	// JSON array of full paths to layer VHDs on the host. For example:
+	//
+	// 	["C:\\layers\\agent\\layer.vhd"]
+	//
+	// The extra layers are placed directly below the scratch layer, above the image's
+	// layers, with the first being the top-most, so their files take precedence over the
+	// image's. The VHDs must be marked read-only on the host, and are removed from the uVM
+	// with the container's other layers.
+	//
+	// The VHDs must be within the `lcow_extra_layer_root` directory set in the shim options,
+	// after resolving any links, and extra layers are rejected if it is not set.
+	//
+	// If a security policy is set, it must allow the extra layers.
+	ContainerReadonlyLayers = "io.microsoft.container.lcow.extralayers"
+
+	// LCOWHostnameSuffix is a DNS domain, such as "svc.cluster.local", that the guest adds to the
+	// search domains of an LCOW container's /etc/resolv.conf when the container is created. For a
+	// container in a pod, that is the /etc/resolv.conf shared by the pod. Creating the container
+	// fails if it mounts its own /etc/resolv.conf.
+	//
+	// If a security policy is set, it must allow the suffix.
+	LCOWHostnameSuffix = "io.microsoft.container.lcow.hostname-suffix"
+
+	// LCOWHostAliases specifies additional entries for the /etc/hosts file shared by the containers
+	// in an LCOW pod, as a JSON array of objects with `IP` and `Hostnames` fields. For example:
+	//
+	// 	[{"IP":"10.0.0.4","Hostnames":["foo.local","bar.local"]}]
+	//
+	// The annotation is read from the pod sandbox container, and can be passed to task updates
+	// on the sandbox to add entries to a running pod.
+	LCOWHostAliases = "io.microsoft.virtualmachine.lcow.host-aliases"
+
+	// LCOWAllowedUnsafeSysctls extends the sysctls that can be set via the OCI spec of a privileged
+	// LCOW container beyond the namespaced ones (`net.*`, `kernel.msg*`, `kernel.sem`, and
+	// `fs.mqueue.*`), as a comma-separated list of sysctl names or prefixes ending in `*`.
+	// For example:
+	//
+	// 	vm.max_map_count,kernel.sched_*
+	//
+	// Since these sysctls are not namespaced, they are applied to the whole uVM.
+	// The annotation is ignored unless [LCOWPrivileged] is also set.
+	LCOWAllowedUnsafeSysctls = "io.microsoft.container.lcow.allowed-unsafe-sysctls"
+
+	// LCOWAllowRSharedMounts allows the bind mounts of an LCOW container to use `rshared` (or
+	// `shared`) propagation, so that mounts the container creates under them propagate back
+	// to the uVM, and from there to other containers that mount the same source with `rslave`
+	// or `rshared` propagation.
+	//
+	// Since this lets the container change the uVM's mounts, the mounts must also be allowed
+	// by the security policy, if one is set.
+	LCOWAllowRSharedMounts = "io.microsoft.container.lcow.allow-rshared-mounts"
+
 	// LCOWTeeLogPath specifies a path in the Linux uVM to write container's stdio to,
 	// in addition to the usual vsock pipes.
 	//
@@ -132,6 +266,64 @@ const (
 	//
 	// 	/var/logs/containers/dir/a.logs
 	LCOWTeeLogDirMount = "io.microsoft.container.lcow.tee-log-dir-mount"
+
+	// LCOWInitExitGracePeriod is the number of seconds to wait, after a container's init process
+	// exits, for the other processes in the container to exit before they are killed and the
+	// container exit is reported. The processes that are killed are reported in the container's
+	// exit notification.
+	//
+	// The default is 0, which kills any remaining processes as soon as the init process exits,
+	// without reporting them.
+	// The grace period does not apply to containers that own their PID namespace, since the
+	// kernel kills the processes in the namespace when its init process exits.
+	LCOWInitExitGracePeriod = "io.microsoft.container.lcow.init-exit-grace-period-seconds"
+
+	// LCOWExecDefaultTerm specifies the `TERM` environment variable set for terminal (TTY)
+	// execs in an LCOW container that do not set one. The default is `xterm`.
+	LCOWExecDefaultTerm = "io.microsoft.container.lcow.exec.default-term"
+
+	// LCOWExecShells contains a comma separated list of shells to try, in order, when a terminal
+	// (TTY) exec in an LCOW container requests one of the shells in the list and it does not
+	// exist in the container. This allows `kubectl exec -it <pod> -- sh` to succeed against
+	// images that only ship, for example, `/busybox/sh`.
+	//
+	// The default is `/bin/sh,/bin/bash,/bin/ash,/busybox/sh`. Set it to an empty string to
+	// disable the fallback.
+	LCOWExecShells = "io.microsoft.container.lcow.exec.shells"
+
+	// LCOWExecCreateWorkingDirectory specifies if the working directory of the process of an
+	// LCOW container, or of an exec in it, and any missing parents, is created if it does not
+	// exist in the container, rather than failing to start the process. Created directories are
+	// owned by root. The default is false.
+	LCOWExecCreateWorkingDirectory = "io.microsoft.container.lcow.exec.create-working-directory"
+
+	// LCOWExecExtraCapabilities contains a comma separated list of capabilities, such as
+	// `CAP_NET_RAW` or `net_raw`, to grant an exec in an LCOW container in addition to the
+	// container's, so that a debug tool can be exec'd without giving them to the container.
+	// A capability outside of the container's bounding set may only be granted if the
+	// container has `CAP_SETPCAP`, and `CAP_SETPCAP` and `CAP_SYS_ADMIN` may only be granted
+	// in a privileged container. Otherwise, the exec fails with EPERM.
+	//
+	// Since exec process specs do not have annotations, this is read from an "annotations"
+	// field added to the JSON exec process spec, which CRI never sets.
+	LCOWExecExtraCapabilities = "io.microsoft.container.lcow.exec.extra-capabilities"
+
+	// LCOWExecDropCapabilities contains a comma separated list of capabilities to remove from
+	// an exec in an LCOW container. They take precedence over [LCOWExecExtraCapabilities].
+	//
+	// Like [LCOWExecExtraCapabilities], this is read from the exec process spec.
+	LCOWExecDropCapabilities = "io.microsoft.container.lcow.exec.drop-capabilities"
+
+	// LCOWNetworkIngressBandwidthMbps shapes the bandwidth, in megabits per second, of the traffic
+	// received on each of the network adapters of the network namespace of an LCOW pod sandbox or
+	// standalone container, with a token bucket filter in the guest. It is ignored on the
+	// workload containers of a pod, which share the sandbox's namespace. If `0`, the traffic is not
+	// shaped.
+	LCOWNetworkIngressBandwidthMbps = "io.microsoft.container.lcow.network.ingress-bandwidth-mbps"
+
+	// LCOWNetworkEgressBandwidthMbps is [LCOWNetworkIngressBandwidthMbps] for the traffic sent on
+	// the adapters. If [LCOWNetworkQoSGuestShaping] also shapes it, the lower bandwidth is used.
+	LCOWNetworkEgressBandwidthMbps = "io.microsoft.container.lcow.network.egress-bandwidth-mbps"
 )
 
 // LCOW multipod annotations enables multipod and warmpooling.
@@ -147,6 +339,16 @@ const (
 	// VirtualPodID is the annotation to specify the pod ID not associated with a shim
 	// that a container should be placed in. This is used for multipod scenarios. String.
 	VirtualPodID = "io.microsoft.cri.virtual-pod-id"
+
+	// UVMSharingGroup is the annotation to place a hypervisor isolated LCOW pod in the utility VM
+	// shared by the pods of the same group, rather than in a utility VM of its own. The first pod
+	// of the group creates the utility VM, sized by its own annotations, and the utility VM is
+	// closed once the last pod of the group is removed. Each pod is a virtual pod (see
+	// [VirtualPodID]) in the shared utility VM, with its own network namespace, cgroup and scratch.
+	//
+	// Pods in a group are not isolated from each other by a utility VM boundary, so a pod can only
+	// join a group listed in the `uvm_sharing_groups` shim option of its runtime. String.
+	UVMSharingGroup = "io.microsoft.cri.uvm-sharing-group"
 )
 
 // LCOW integrity protection and confidential container annotations.
@@ -171,6 +373,14 @@ const (
 	// Deprecated: use [LCOWEncryptedScratchDisk] instead.
 	EncryptedScratchDisk = LCOWEncryptedScratchDisk
 
+	// LCOWScratchRepair indicates whether the guest should run fsck on a container
+	// scratch disk whose filesystem is corrupt, such as a scratch disk reused after
+	// an unclean shutdown of the UVM, and mount it again. Encrypted scratch disks
+	// are never repaired.
+	//
+	// LCOW only.
+	LCOWScratchRepair = "io.microsoft.virtualmachine.storage.scratch.repair"
+
 	// LCOWGuestStateFile specifies the path of the vmgs file to use if required. Only applies in SNP mode.
 	LCOWGuestStateFile = "io.microsoft.virtualmachine.lcow.gueststatefile"
 	// Deprecated: use [LCOWGuestStateFile] instead.
@@ -232,6 +442,46 @@ const (
 	// a WCOW container.
 	WCOWDisableGMSA = "io.microsoft.container.wcow.gmsa.disable"
 
+	// WCOWExecCredentialSpec is the gMSA credential spec an exec process should run with.
+	//
+	// Since exec process specs do not have annotations, this is read from an "annotations"
+	// field added to the JSON exec process spec. containerd passes the exec spec to the shim
+	// unchanged, so a client that sets the field reaches the shim, but CRI never sets it.
+	// A container's Container Credential Guard instance is created with the container, and an
+	// exec cannot use a different instance, so the credential spec must match the container's.
+	WCOWExecCredentialSpec = "io.microsoft.container.wcow.exec.credentialspec"
+
+	// WCOWExtraLayerFolders contains a comma separated list of full paths to additional
+	// read-only WCIFS layer folders to add to a WCOW container's image layers, such as
+	// a layer containing an observability agent.
+	// The extra layers are placed above the image's layers, with the first being the
+	// top-most, so their files take precedence over the image's.
+	//
+	// The layer folders must be within the `wcow_extra_layer_root` directory or share set in
+	// the shim options, after resolving any links, and extra layers are rejected if it is not
+	// set.
+	//
+	// The annotation is not supported for CimFS layers.
+	WCOWExtraLayerFolders = "io.microsoft.container.wcow.layerFolders.extra"
+
+	// WCOWImageVolumes contains a comma separated list of the absolute paths of the volumes
+	// declared by the image of a WCOW container, such as with a VOLUME directive.
+	// Volumes that a mount in the container spec already covers are skipped.
+	// How the remaining volumes are handled is set by [WCOWImageVolumeMode].
+	//
+	// The shim does not read the image config, so it only knows of the volumes listed here:
+	// the caller must set this from the image config for any mode to apply to them.
+	WCOWImageVolumes = "io.microsoft.container.wcow.image-volumes"
+
+	// WCOWImageVolumeMode specifies how the [WCOWImageVolumes] of a WCOW container are
+	// handled. Volumes of the image that are not listed in [WCOWImageVolumes] are always
+	// left to the container's writable layer. The supported options are:
+	//   - "ignore": the volumes are left to the container's writable layer (the default).
+	//   - "emptydir": each volume is backed by a new empty directory on the host, which is
+	//     mounted in the container and removed with it.
+	//   - "error": creating the container fails, listing the volumes.
+	WCOWImageVolumeMode = "io.microsoft.container.wcow.image-volume-mode"
+
 	// WCOWProcessDumpType specifies the type of dump to create when generating a local user mode
 	// process dump for Windows containers. The supported options are "mini", and "full".
 	// See DumpType: https://docs.microsoft.com/en-us/windows/win32/wer/collecting-user-mode-dumps
@@ -301,6 +551,23 @@ const (
 	// case the UVM crashes.
 	DumpDirectoryPath = "io.microsoft.virtualmachine.dump-directory-path"
 
+	// GuestLogsTailLines limits the guest logs (the kernel ring buffer for LCOW and the Application
+	// event log for WCOW) captured when a UVM is closed after its workload exits unexpectedly to the
+	// last N lines. If unset or 0, the last 200 lines are captured. At most 10000 lines can be
+	// captured, and a larger value fails the creation of the UVM.
+	GuestLogsTailLines = "io.microsoft.virtualmachine.guest-logs.tail-lines"
+
+	// StdioPortsWarningThreshold is the number of vsock ports in use by the stdio relays of
+	// processes in a UVM past which a warning is logged, to catch processes whose IO is never
+	// closed. Defaults to 1024.
+	StdioPortsWarningThreshold = "io.microsoft.virtualmachine.stdio-ports.warning-threshold"
+
+	// StatisticsInterval is the interval, in seconds, at which the statistics of the containers in
+	// a UVM are sampled together, and for which the statistics of a container are served from the
+	// latest sample. Defaults to 10. If negative, the statistics of a container are queried from
+	// the guest every time they are requested.
+	StatisticsInterval = "io.microsoft.virtualmachine.statistics.interval-seconds"
+
 	// DisableWritableFileShares disables adding any writable fileshares to the UVM.
 	DisableWritableFileShares = "io.microsoft.virtualmachine.fileshares.disablewritable"
 
@@ -343,6 +610,12 @@ const (
 	// Note: Unlike Windows process isolated container QoS Count/Limt/Weight on
 	// the UVM are not mutually exclusive and can be set together.
 	ProcessorWeight = "io.microsoft.virtualmachine.computetopology.processor.weight"
+
+	// UVMEnableNestedVirtualization exposes the host processor's virtualization extensions
+	// (Intel VT-x or AMD-V) to an LCOW UVM, so that a hypervisor such as KVM can run inside it.
+	// Creating the UVM fails if the host does not support nested virtualization.
+	// This cannot be used with confidential UVMs.
+	UVMEnableNestedVirtualization = "io.microsoft.virtualmachine.nestedvirtualization.enabled"
 )
 
 // uVM memory annotations.
@@ -370,6 +643,22 @@ const (
 	// the caller MUST use MB or sizing will be wrong.
 	MemorySizeInMB = "io.microsoft.virtualmachine.computetopology.memory.sizeinmb"
 
+	// AdmitContainers fails to create or update a container in the UVM whose memory request does
+	// not fit in what is left after the requests of the other containers and the memory reserved
+	// for the guest OS, or which requests more processors than the UVM has. The sandbox container,
+	// whose resources describe the pod as a whole, is not admitted. The default is false.
+	AdmitContainers = "io.microsoft.virtualmachine.computetopology.admit-containers"
+
+	// GuestReservedMemoryInMB is the memory of the UVM, in MB, that is kept for the guest OS when
+	// containers are admitted with [AdmitContainers]. The default is 128 for LCOW and 512 for WCOW.
+	GuestReservedMemoryInMB = "io.microsoft.virtualmachine.computetopology.memory.guest-reserved-mb"
+
+	// GrowToFitContainers grows the memory of the UVM when a container requests more memory than
+	// is left after the requests of the other containers in the UVM, instead of failing to create
+	// the container. Containers still cannot request more processors than the UVM has. It only
+	// applies with [AdmitContainers].
+	GrowToFitContainers = "io.microsoft.virtualmachine.computetopology.memory.grow-to-fit-containers"
+
 	// MemoryLowMMIOGapInMB indicates the low MMIO gap in MB.
 	MemoryLowMMIOGapInMB = "io.microsoft.virtualmachine.computetopology.memory.lowmmiogapinmb"
 
@@ -378,6 +667,11 @@ const (
 
 	// MemoryHighMMIOGapInMB indicates the high MMIO gap in MB.
 	MemoryHighMMIOGapInMB = "io.microsoft.virtualmachine.computetopology.memory.highmmiogapinmb"
+
+	// MemoryAutoSizeMMIOGaps grows the low and high MMIO gaps of an LCOW UVM to fit the BARs of
+	// the devices assigned to it when it is created, such as GPUs with large BARs. Gaps set with
+	// the MMIO annotations above are only ever grown.
+	MemoryAutoSizeMMIOGaps = "io.microsoft.virtualmachine.computetopology.memory.autosizemmiogaps"
 )
 
 // uVM NUMA annotations.
@@ -430,6 +724,63 @@ const (
 	StorageQoSIopsMaximum = "io.microsoft.virtualmachine.storageqos.iopsmaximum"
 )
 
+// uVM SCSI annotations.
+const (
+	// SCSIControllerCount is the number of SCSI controllers to add to the uVM, up to 4 for LCOW.
+	// WCOW uVMs must have exactly 1. Defaults to 1, or for LCOW to 4 if the uVM has no VPMem
+	// devices.
+	SCSIControllerCount = "io.microsoft.virtualmachine.devices.scsi.controllercount"
+
+	// SCSILayerControllerCount reserves the first SCSI controllers of the uVM, starting from
+	// controller 0, for container layers and scratch disks, and the remaining controllers for
+	// data disks mounted into containers, so that neither can exhaust the LUNs of the other.
+	// Must be less than [SCSIControllerCount], after it defaults. If `0`, all disks share all
+	// controllers. LCOW only, since WCOW uVMs have a single SCSI controller.
+	SCSILayerControllerCount = "io.microsoft.virtualmachine.devices.scsi.layercontrollercount"
+)
+
+// uVM network (Quality of Service) annotations.
+const (
+	// NetworkQoSEgressBandwidthMaximum caps the bandwidth, in bits per second, of the traffic sent
+	// by each of the uVM's network endpoints, with an HNS QoS policy. The value is an integer with
+	// an optional decimal (k, M, G, T) or binary (Ki, Mi, Gi, Ti) suffix, such as "100M" or "1Gi",
+	// and must be at least 1M. If `0`, the bandwidth is not capped.
+	//
+	// The cap is set when the uVM is created, and applies to every endpoint added to it afterwards.
+	// A task update of the pod sandbox that carries resources also applies a changed value of the
+	// annotation, but CRI never updates the sandbox, so the cap of a CRI pod can't be changed.
+	//
+	// A process isolated WCOW pod has no uVM, so the cap is instead set on the endpoints in the
+	// pod's network namespace when the pod sandbox is created, and updated the same way.
+	NetworkQoSEgressBandwidthMaximum = "io.microsoft.virtualmachine.networkqos.egress-bandwidthmaximum"
+
+	// LCOWNetworkQoSGuestShaping additionally shapes the traffic sent on the LCOW uVM's network
+	// adapters to [NetworkQoSEgressBandwidthMaximum] in the guest, with a token bucket filter.
+	LCOWNetworkQoSGuestShaping = "io.microsoft.virtualmachine.lcow.networkqos.guest-shaping"
+)
+
+// uVM virtual TPM annotations.
+const (
+	// UVMEnableTPM adds a virtual TPM to the uVM, for measured boot and for sealing keys in the
+	// guest. Creating the uVM fails if the host does not support it.
+	// This cannot be used with confidential UVMs.
+	UVMEnableTPM = "io.microsoft.virtualmachine.tpm.enabled"
+
+	// UVMTPMStateFilePath is the name of the file on the host that the state of the uVM's virtual
+	// TPM persists in, in the directory of the `tpm_state_root` runtime option of the shim. The
+	// file is created if it does not exist, and is removed with the uVM unless [UVMKeepTPMState]
+	// is set. An existing file is only reused if [UVMKeepTPMState] is set. If empty, the state is
+	// lost when the uVM is removed.
+	//
+	// Requires [UVMEnableTPM], and the shim's `tpm_state_root`.
+	UVMTPMStateFilePath = "io.microsoft.virtualmachine.tpm.state-file-path"
+
+	// UVMKeepTPMState keeps the file at [UVMTPMStateFilePath] when the uVM is removed, so that
+	// the state of its virtual TPM can be reused by a later uVM.
+	// The default is false.
+	UVMKeepTPMState = "io.microsoft.virtualmachine.tpm.keep-state"
+)
+
 // WCOW uVM annotations.
 const (
 	// DisableCompartmentNamespace sets whether to disable namespacing the network compartment in the UVM
@@ -483,6 +834,26 @@ const (
 	// synchronization service inside the LCOW UVM.
 	DisableLCOWTimeSyncService = "io.microsoft.virtualmachine.lcow.timesync.disable"
 
+	// LCOWGCSSeccompMode sets the seccomp filter the GCS applies to itself, and so to every process
+	// it launches, including containers. Valid values are "none", "kernel", which denies syscalls that
+	// modify the uVM's kernel or hardware, and "all", which also denies syscalls that inspect or
+	// modify other processes, such as ptrace.
+	//
+	// It has no effect on uVMs booted from a guest state file, whose kernel command line is fixed;
+	// use [LCOWRequireGCSSeccomp] to require that their GCS applies a filter.
+	LCOWGCSSeccompMode = "io.microsoft.virtualmachine.lcow.gcs.seccomp.mode"
+
+	// LCOWRequireGCSSeccomp fails the creation of the LCOW uVM if its GCS does not report that
+	// it runs with a seccomp filter applied.
+	LCOWRequireGCSSeccomp = "io.microsoft.virtualmachine.lcow.gcs.seccomp.require"
+
+	// LCOWDenyRootProcesses fails to create processes in the LCOW uVM, rather than in a container,
+	// that would run as root, such as the processes run by `shimdiag exec` without a user. Processes
+	// must be created as another user, which fails if the GCS does not support it. The processes
+	// the host runs with fixed commands that need root, dmesg to dump the guest logs and criu to
+	// checkpoint containers, are exempt.
+	LCOWDenyRootProcesses = "io.microsoft.virtualmachine.lcow.processes.deny-root"
+
 	// KernelBootOptions is used to specify kernel options used while booting a linux kernel.
 	KernelBootOptions = "io.microsoft.virtualmachine.lcow.kernelbootoptions"
 
@@ -504,6 +875,15 @@ const (
 
 	// VPMemSize indicates the size of the VPMem devices.
 	VPMemSize = "io.microsoft.virtualmachine.devices.virtualpmem.maximumsizebytes"
+
+	// UVMVirtualPMEMCount is the number of VPMem devices to configure on the LCOW UVM.
+	// Unlike [VPMemCount], the value must be between 1 and 128 (the hardware limit).
+	// If set, it takes precedence over [VPMemCount].
+	UVMVirtualPMEMCount = "io.microsoft.virtualmachine.vpmem.count"
+
+	// UVMVirtualPMEMMaxSizeBytes is the maximum size, in bytes, of each VPMem device on the LCOW UVM.
+	// If set, it takes precedence over [VPMemSize].
+	UVMVirtualPMEMMaxSizeBytes = "io.microsoft.virtualmachine.vpmem.maxsizebytes"
 )
 
 // Networking annotations.
@@ -22,4 +22,7 @@ type ContainerMount struct {
 	ContainerPath string
 	ReadOnly      bool
 	Type          string
+	// Remove removes the mount at ContainerPath that was added to the running
+	// container by an earlier update, rather than adding a mount.
+	Remove bool `json:",omitempty"`
 }
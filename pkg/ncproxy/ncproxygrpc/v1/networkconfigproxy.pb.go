@@ -2143,6 +2143,210 @@ func (x *GetNetworksResponse) GetNetworks() []*GetNetworkResponse {
 	return nil
 }
 
+type ReconcileEndpointsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DryRun        bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileEndpointsRequest) Reset() {
+	*x = ReconcileEndpointsRequest{}
+	mi := &file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileEndpointsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileEndpointsRequest) ProtoMessage() {}
+
+func (x *ReconcileEndpointsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileEndpointsRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileEndpointsRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ReconcileEndpointsRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ReconciledEndpoint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ID            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	NetworkID     string                 `protobuf:"bytes,3,opt,name=network_id,json=networkId,proto3" json:"network_id,omitempty"`
+	Namespace     string                 `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Reason        string                 `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconciledEndpoint) Reset() {
+	*x = ReconciledEndpoint{}
+	mi := &file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconciledEndpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconciledEndpoint) ProtoMessage() {}
+
+func (x *ReconciledEndpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconciledEndpoint.ProtoReflect.Descriptor instead.
+func (*ReconciledEndpoint) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *ReconciledEndpoint) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ReconciledEndpoint) GetID() string {
+	if x != nil {
+		return x.ID
+	}
+	return ""
+}
+
+func (x *ReconciledEndpoint) GetNetworkID() string {
+	if x != nil {
+		return x.NetworkID
+	}
+	return ""
+}
+
+func (x *ReconciledEndpoint) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *ReconciledEndpoint) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ReconcileEndpointsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DryRun        bool                   `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	Deleted       []*ReconciledEndpoint  `protobuf:"bytes,2,rep,name=deleted,proto3" json:"deleted,omitempty"`
+	Adopted       []*ReconciledEndpoint  `protobuf:"bytes,3,rep,name=adopted,proto3" json:"adopted,omitempty"`
+	Forgotten     []*ReconciledEndpoint  `protobuf:"bytes,4,rep,name=forgotten,proto3" json:"forgotten,omitempty"`
+	Unknown       []*ReconciledEndpoint  `protobuf:"bytes,5,rep,name=unknown,proto3" json:"unknown,omitempty"`
+	Failed        []*ReconciledEndpoint  `protobuf:"bytes,6,rep,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileEndpointsResponse) Reset() {
+	*x = ReconcileEndpointsResponse{}
+	mi := &file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileEndpointsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileEndpointsResponse) ProtoMessage() {}
+
+func (x *ReconcileEndpointsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileEndpointsResponse.ProtoReflect.Descriptor instead.
+func (*ReconcileEndpointsResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *ReconcileEndpointsResponse) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ReconcileEndpointsResponse) GetDeleted() []*ReconciledEndpoint {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+func (x *ReconcileEndpointsResponse) GetAdopted() []*ReconciledEndpoint {
+	if x != nil {
+		return x.Adopted
+	}
+	return nil
+}
+
+func (x *ReconcileEndpointsResponse) GetForgotten() []*ReconciledEndpoint {
+	if x != nil {
+		return x.Forgotten
+	}
+	return nil
+}
+
+func (x *ReconcileEndpointsResponse) GetUnknown() []*ReconciledEndpoint {
+	if x != nil {
+		return x.Unknown
+	}
+	return nil
+}
+
+func (x *ReconcileEndpointsResponse) GetFailed() []*ReconciledEndpoint {
+	if x != nil {
+		return x.Failed
+	}
+	return nil
+}
+
 var File_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto protoreflect.FileDescriptor
 
 const file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDesc = "" +
@@ -2281,7 +2485,23 @@ const file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfig
 	"\tendpoints\x18\x01 \x03(\v2#.ncproxygrpc.v1.GetEndpointResponseR\tendpoints\"\x14\n" +
 	"\x12GetNetworksRequest\"U\n" +
 	"\x13GetNetworksResponse\x12>\n" +
-	"\bnetworks\x18\x01 \x03(\v2\".ncproxygrpc.v1.GetNetworkResponseR\bnetworks2\xcf\b\n" +
+	"\bnetworks\x18\x01 \x03(\v2\".ncproxygrpc.v1.GetNetworkResponseR\bnetworks\"4\n" +
+	"\x19ReconcileEndpointsRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"\x8d\x01\n" +
+	"\x12ReconciledEndpoint\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"network_id\x18\x03 \x01(\tR\tnetworkId\x12\x1c\n" +
+	"\tnamespace\x18\x04 \x01(\tR\tnamespace\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\"\xed\x02\n" +
+	"\x1aReconcileEndpointsResponse\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\x12<\n" +
+	"\adeleted\x18\x02 \x03(\v2\".ncproxygrpc.v1.ReconciledEndpointR\adeleted\x12<\n" +
+	"\aadopted\x18\x03 \x03(\v2\".ncproxygrpc.v1.ReconciledEndpointR\aadopted\x12@\n" +
+	"\tforgotten\x18\x04 \x03(\v2\".ncproxygrpc.v1.ReconciledEndpointR\tforgotten\x12<\n" +
+	"\aunknown\x18\x05 \x03(\v2\".ncproxygrpc.v1.ReconciledEndpointR\aunknown\x12:\n" +
+	"\x06failed\x18\x06 \x03(\v2\".ncproxygrpc.v1.ReconciledEndpointR\x06failed2\xbe\t\n" +
 	"\x12NetworkConfigProxy\x12I\n" +
 	"\x06AddNIC\x12\x1d.ncproxygrpc.v1.AddNICRequest\x1a\x1e.ncproxygrpc.v1.AddNICResponse\"\x00\x12R\n" +
 	"\tModifyNIC\x12 .ncproxygrpc.v1.ModifyNICRequest\x1a!.ncproxygrpc.v1.ModifyNICResponse\"\x00\x12R\n" +
@@ -2295,7 +2515,8 @@ const file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfig
 	"\n" +
 	"GetNetwork\x12!.ncproxygrpc.v1.GetNetworkRequest\x1a\".ncproxygrpc.v1.GetNetworkResponse\"\x00\x12[\n" +
 	"\fGetEndpoints\x12#.ncproxygrpc.v1.GetEndpointsRequest\x1a$.ncproxygrpc.v1.GetEndpointsResponse\"\x00\x12X\n" +
-	"\vGetNetworks\x12\".ncproxygrpc.v1.GetNetworksRequest\x1a#.ncproxygrpc.v1.GetNetworksResponse\"\x00B9Z7github.com/Microsoft/hcsshim/pkg/ncproxy/ncproxygrpc/v1b\x06proto3"
+	"\vGetNetworks\x12\".ncproxygrpc.v1.GetNetworksRequest\x1a#.ncproxygrpc.v1.GetNetworksResponse\"\x00\x12m\n" +
+	"\x12ReconcileEndpoints\x12).ncproxygrpc.v1.ReconcileEndpointsRequest\x1a*.ncproxygrpc.v1.ReconcileEndpointsResponse\"\x00B9Z7github.com/Microsoft/hcsshim/pkg/ncproxy/ncproxygrpc/v1b\x06proto3"
 
 var (
 	file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDescOnce sync.Once
@@ -2310,7 +2531,7 @@ func file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigp
 }
 
 var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes = make([]protoimpl.MessageInfo, 37)
+var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_msgTypes = make([]protoimpl.MessageInfo, 40)
 var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_goTypes = []any{
 	(HostComputeNetworkSettings_NetworkMode)(0), // 0: ncproxygrpc.v1.HostComputeNetworkSettings.NetworkMode
 	(HostComputeNetworkSettings_IpamType)(0),    // 1: ncproxygrpc.v1.HostComputeNetworkSettings.IpamType
@@ -2351,6 +2572,9 @@ var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigpr
 	(*GetEndpointsResponse)(nil),                // 36: ncproxygrpc.v1.GetEndpointsResponse
 	(*GetNetworksRequest)(nil),                  // 37: ncproxygrpc.v1.GetNetworksRequest
 	(*GetNetworksResponse)(nil),                 // 38: ncproxygrpc.v1.GetNetworksResponse
+	(*ReconcileEndpointsRequest)(nil),           // 39: ncproxygrpc.v1.ReconcileEndpointsRequest
+	(*ReconciledEndpoint)(nil),                  // 40: ncproxygrpc.v1.ReconciledEndpoint
+	(*ReconcileEndpointsResponse)(nil),          // 41: ncproxygrpc.v1.ReconcileEndpointsResponse
 }
 var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_depIdxs = []int32{
 	17, // 0: ncproxygrpc.v1.AddNICRequest.endpoint_settings:type_name -> ncproxygrpc.v1.EndpointSettings
@@ -2374,35 +2598,42 @@ var file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigpr
 	34, // 18: ncproxygrpc.v1.GetNetworkResponse.macRange:type_name -> ncproxygrpc.v1.MacRange
 	31, // 19: ncproxygrpc.v1.GetEndpointsResponse.endpoints:type_name -> ncproxygrpc.v1.GetEndpointResponse
 	33, // 20: ncproxygrpc.v1.GetNetworksResponse.networks:type_name -> ncproxygrpc.v1.GetNetworkResponse
-	2,  // 21: ncproxygrpc.v1.NetworkConfigProxy.AddNIC:input_type -> ncproxygrpc.v1.AddNICRequest
-	4,  // 22: ncproxygrpc.v1.NetworkConfigProxy.ModifyNIC:input_type -> ncproxygrpc.v1.ModifyNICRequest
-	6,  // 23: ncproxygrpc.v1.NetworkConfigProxy.DeleteNIC:input_type -> ncproxygrpc.v1.DeleteNICRequest
-	8,  // 24: ncproxygrpc.v1.NetworkConfigProxy.CreateNetwork:input_type -> ncproxygrpc.v1.CreateNetworkRequest
-	16, // 25: ncproxygrpc.v1.NetworkConfigProxy.CreateEndpoint:input_type -> ncproxygrpc.v1.CreateEndpointRequest
-	24, // 26: ncproxygrpc.v1.NetworkConfigProxy.AddEndpoint:input_type -> ncproxygrpc.v1.AddEndpointRequest
-	26, // 27: ncproxygrpc.v1.NetworkConfigProxy.DeleteEndpoint:input_type -> ncproxygrpc.v1.DeleteEndpointRequest
-	28, // 28: ncproxygrpc.v1.NetworkConfigProxy.DeleteNetwork:input_type -> ncproxygrpc.v1.DeleteNetworkRequest
-	30, // 29: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoint:input_type -> ncproxygrpc.v1.GetEndpointRequest
-	32, // 30: ncproxygrpc.v1.NetworkConfigProxy.GetNetwork:input_type -> ncproxygrpc.v1.GetNetworkRequest
-	35, // 31: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoints:input_type -> ncproxygrpc.v1.GetEndpointsRequest
-	37, // 32: ncproxygrpc.v1.NetworkConfigProxy.GetNetworks:input_type -> ncproxygrpc.v1.GetNetworksRequest
-	3,  // 33: ncproxygrpc.v1.NetworkConfigProxy.AddNIC:output_type -> ncproxygrpc.v1.AddNICResponse
-	5,  // 34: ncproxygrpc.v1.NetworkConfigProxy.ModifyNIC:output_type -> ncproxygrpc.v1.ModifyNICResponse
-	7,  // 35: ncproxygrpc.v1.NetworkConfigProxy.DeleteNIC:output_type -> ncproxygrpc.v1.DeleteNICResponse
-	12, // 36: ncproxygrpc.v1.NetworkConfigProxy.CreateNetwork:output_type -> ncproxygrpc.v1.CreateNetworkResponse
-	23, // 37: ncproxygrpc.v1.NetworkConfigProxy.CreateEndpoint:output_type -> ncproxygrpc.v1.CreateEndpointResponse
-	25, // 38: ncproxygrpc.v1.NetworkConfigProxy.AddEndpoint:output_type -> ncproxygrpc.v1.AddEndpointResponse
-	27, // 39: ncproxygrpc.v1.NetworkConfigProxy.DeleteEndpoint:output_type -> ncproxygrpc.v1.DeleteEndpointResponse
-	29, // 40: ncproxygrpc.v1.NetworkConfigProxy.DeleteNetwork:output_type -> ncproxygrpc.v1.DeleteNetworkResponse
-	31, // 41: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoint:output_type -> ncproxygrpc.v1.GetEndpointResponse
-	33, // 42: ncproxygrpc.v1.NetworkConfigProxy.GetNetwork:output_type -> ncproxygrpc.v1.GetNetworkResponse
-	36, // 43: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoints:output_type -> ncproxygrpc.v1.GetEndpointsResponse
-	38, // 44: ncproxygrpc.v1.NetworkConfigProxy.GetNetworks:output_type -> ncproxygrpc.v1.GetNetworksResponse
-	33, // [33:45] is the sub-list for method output_type
-	21, // [21:33] is the sub-list for method input_type
-	21, // [21:21] is the sub-list for extension type_name
-	21, // [21:21] is the sub-list for extension extendee
-	0,  // [0:21] is the sub-list for field type_name
+	40, // 21: ncproxygrpc.v1.ReconcileEndpointsResponse.deleted:type_name -> ncproxygrpc.v1.ReconciledEndpoint
+	40, // 22: ncproxygrpc.v1.ReconcileEndpointsResponse.adopted:type_name -> ncproxygrpc.v1.ReconciledEndpoint
+	40, // 23: ncproxygrpc.v1.ReconcileEndpointsResponse.forgotten:type_name -> ncproxygrpc.v1.ReconciledEndpoint
+	40, // 24: ncproxygrpc.v1.ReconcileEndpointsResponse.unknown:type_name -> ncproxygrpc.v1.ReconciledEndpoint
+	40, // 25: ncproxygrpc.v1.ReconcileEndpointsResponse.failed:type_name -> ncproxygrpc.v1.ReconciledEndpoint
+	2,  // 26: ncproxygrpc.v1.NetworkConfigProxy.AddNIC:input_type -> ncproxygrpc.v1.AddNICRequest
+	4,  // 27: ncproxygrpc.v1.NetworkConfigProxy.ModifyNIC:input_type -> ncproxygrpc.v1.ModifyNICRequest
+	6,  // 28: ncproxygrpc.v1.NetworkConfigProxy.DeleteNIC:input_type -> ncproxygrpc.v1.DeleteNICRequest
+	8,  // 29: ncproxygrpc.v1.NetworkConfigProxy.CreateNetwork:input_type -> ncproxygrpc.v1.CreateNetworkRequest
+	16, // 30: ncproxygrpc.v1.NetworkConfigProxy.CreateEndpoint:input_type -> ncproxygrpc.v1.CreateEndpointRequest
+	24, // 31: ncproxygrpc.v1.NetworkConfigProxy.AddEndpoint:input_type -> ncproxygrpc.v1.AddEndpointRequest
+	26, // 32: ncproxygrpc.v1.NetworkConfigProxy.DeleteEndpoint:input_type -> ncproxygrpc.v1.DeleteEndpointRequest
+	28, // 33: ncproxygrpc.v1.NetworkConfigProxy.DeleteNetwork:input_type -> ncproxygrpc.v1.DeleteNetworkRequest
+	30, // 34: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoint:input_type -> ncproxygrpc.v1.GetEndpointRequest
+	32, // 35: ncproxygrpc.v1.NetworkConfigProxy.GetNetwork:input_type -> ncproxygrpc.v1.GetNetworkRequest
+	35, // 36: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoints:input_type -> ncproxygrpc.v1.GetEndpointsRequest
+	37, // 37: ncproxygrpc.v1.NetworkConfigProxy.GetNetworks:input_type -> ncproxygrpc.v1.GetNetworksRequest
+	39, // 38: ncproxygrpc.v1.NetworkConfigProxy.ReconcileEndpoints:input_type -> ncproxygrpc.v1.ReconcileEndpointsRequest
+	3,  // 39: ncproxygrpc.v1.NetworkConfigProxy.AddNIC:output_type -> ncproxygrpc.v1.AddNICResponse
+	5,  // 40: ncproxygrpc.v1.NetworkConfigProxy.ModifyNIC:output_type -> ncproxygrpc.v1.ModifyNICResponse
+	7,  // 41: ncproxygrpc.v1.NetworkConfigProxy.DeleteNIC:output_type -> ncproxygrpc.v1.DeleteNICResponse
+	12, // 42: ncproxygrpc.v1.NetworkConfigProxy.CreateNetwork:output_type -> ncproxygrpc.v1.CreateNetworkResponse
+	23, // 43: ncproxygrpc.v1.NetworkConfigProxy.CreateEndpoint:output_type -> ncproxygrpc.v1.CreateEndpointResponse
+	25, // 44: ncproxygrpc.v1.NetworkConfigProxy.AddEndpoint:output_type -> ncproxygrpc.v1.AddEndpointResponse
+	27, // 45: ncproxygrpc.v1.NetworkConfigProxy.DeleteEndpoint:output_type -> ncproxygrpc.v1.DeleteEndpointResponse
+	29, // 46: ncproxygrpc.v1.NetworkConfigProxy.DeleteNetwork:output_type -> ncproxygrpc.v1.DeleteNetworkResponse
+	31, // 47: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoint:output_type -> ncproxygrpc.v1.GetEndpointResponse
+	33, // 48: ncproxygrpc.v1.NetworkConfigProxy.GetNetwork:output_type -> ncproxygrpc.v1.GetNetworkResponse
+	36, // 49: ncproxygrpc.v1.NetworkConfigProxy.GetEndpoints:output_type -> ncproxygrpc.v1.GetEndpointsResponse
+	38, // 50: ncproxygrpc.v1.NetworkConfigProxy.GetNetworks:output_type -> ncproxygrpc.v1.GetNetworksResponse
+	41, // 51: ncproxygrpc.v1.NetworkConfigProxy.ReconcileEndpoints:output_type -> ncproxygrpc.v1.ReconcileEndpointsResponse
+	39, // [39:52] is the sub-list for method output_type
+	26, // [26:39] is the sub-list for method input_type
+	26, // [26:26] is the sub-list for extension type_name
+	26, // [26:26] is the sub-list for extension extendee
+	0,  // [0:26] is the sub-list for field type_name
 }
 
 func init() {
@@ -2429,7 +2660,7 @@ func file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigp
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDesc), len(file_github_com_Microsoft_hcsshim_pkg_ncproxy_ncproxygrpc_v1_networkconfigproxy_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   37,
+			NumMessages:   40,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,18 +19,19 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	NetworkConfigProxy_AddNIC_FullMethodName         = "/ncproxygrpc.v1.NetworkConfigProxy/AddNIC"
-	NetworkConfigProxy_ModifyNIC_FullMethodName      = "/ncproxygrpc.v1.NetworkConfigProxy/ModifyNIC"
-	NetworkConfigProxy_DeleteNIC_FullMethodName      = "/ncproxygrpc.v1.NetworkConfigProxy/DeleteNIC"
-	NetworkConfigProxy_CreateNetwork_FullMethodName  = "/ncproxygrpc.v1.NetworkConfigProxy/CreateNetwork"
-	NetworkConfigProxy_CreateEndpoint_FullMethodName = "/ncproxygrpc.v1.NetworkConfigProxy/CreateEndpoint"
-	NetworkConfigProxy_AddEndpoint_FullMethodName    = "/ncproxygrpc.v1.NetworkConfigProxy/AddEndpoint"
-	NetworkConfigProxy_DeleteEndpoint_FullMethodName = "/ncproxygrpc.v1.NetworkConfigProxy/DeleteEndpoint"
-	NetworkConfigProxy_DeleteNetwork_FullMethodName  = "/ncproxygrpc.v1.NetworkConfigProxy/DeleteNetwork"
-	NetworkConfigProxy_GetEndpoint_FullMethodName    = "/ncproxygrpc.v1.NetworkConfigProxy/GetEndpoint"
-	NetworkConfigProxy_GetNetwork_FullMethodName     = "/ncproxygrpc.v1.NetworkConfigProxy/GetNetwork"
-	NetworkConfigProxy_GetEndpoints_FullMethodName   = "/ncproxygrpc.v1.NetworkConfigProxy/GetEndpoints"
-	NetworkConfigProxy_GetNetworks_FullMethodName    = "/ncproxygrpc.v1.NetworkConfigProxy/GetNetworks"
+	NetworkConfigProxy_AddNIC_FullMethodName             = "/ncproxygrpc.v1.NetworkConfigProxy/AddNIC"
+	NetworkConfigProxy_ModifyNIC_FullMethodName          = "/ncproxygrpc.v1.NetworkConfigProxy/ModifyNIC"
+	NetworkConfigProxy_DeleteNIC_FullMethodName          = "/ncproxygrpc.v1.NetworkConfigProxy/DeleteNIC"
+	NetworkConfigProxy_CreateNetwork_FullMethodName      = "/ncproxygrpc.v1.NetworkConfigProxy/CreateNetwork"
+	NetworkConfigProxy_CreateEndpoint_FullMethodName     = "/ncproxygrpc.v1.NetworkConfigProxy/CreateEndpoint"
+	NetworkConfigProxy_AddEndpoint_FullMethodName        = "/ncproxygrpc.v1.NetworkConfigProxy/AddEndpoint"
+	NetworkConfigProxy_DeleteEndpoint_FullMethodName     = "/ncproxygrpc.v1.NetworkConfigProxy/DeleteEndpoint"
+	NetworkConfigProxy_DeleteNetwork_FullMethodName      = "/ncproxygrpc.v1.NetworkConfigProxy/DeleteNetwork"
+	NetworkConfigProxy_GetEndpoint_FullMethodName        = "/ncproxygrpc.v1.NetworkConfigProxy/GetEndpoint"
+	NetworkConfigProxy_GetNetwork_FullMethodName         = "/ncproxygrpc.v1.NetworkConfigProxy/GetNetwork"
+	NetworkConfigProxy_GetEndpoints_FullMethodName       = "/ncproxygrpc.v1.NetworkConfigProxy/GetEndpoints"
+	NetworkConfigProxy_GetNetworks_FullMethodName        = "/ncproxygrpc.v1.NetworkConfigProxy/GetNetworks"
+	NetworkConfigProxy_ReconcileEndpoints_FullMethodName = "/ncproxygrpc.v1.NetworkConfigProxy/ReconcileEndpoints"
 )
 
 // NetworkConfigProxyClient is the client API for NetworkConfigProxy service.
@@ -49,6 +50,7 @@ type NetworkConfigProxyClient interface {
 	GetNetwork(ctx context.Context, in *GetNetworkRequest, opts ...grpc.CallOption) (*GetNetworkResponse, error)
 	GetEndpoints(ctx context.Context, in *GetEndpointsRequest, opts ...grpc.CallOption) (*GetEndpointsResponse, error)
 	GetNetworks(ctx context.Context, in *GetNetworksRequest, opts ...grpc.CallOption) (*GetNetworksResponse, error)
+	ReconcileEndpoints(ctx context.Context, in *ReconcileEndpointsRequest, opts ...grpc.CallOption) (*ReconcileEndpointsResponse, error)
 }
 
 type networkConfigProxyClient struct {
@@ -179,6 +181,16 @@ func (c *networkConfigProxyClient) GetNetworks(ctx context.Context, in *GetNetwo
 	return out, nil
 }
 
+func (c *networkConfigProxyClient) ReconcileEndpoints(ctx context.Context, in *ReconcileEndpointsRequest, opts ...grpc.CallOption) (*ReconcileEndpointsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReconcileEndpointsResponse)
+	err := c.cc.Invoke(ctx, NetworkConfigProxy_ReconcileEndpoints_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NetworkConfigProxyServer is the server API for NetworkConfigProxy service.
 // All implementations must embed UnimplementedNetworkConfigProxyServer
 // for forward compatibility.
@@ -195,6 +207,7 @@ type NetworkConfigProxyServer interface {
 	GetNetwork(context.Context, *GetNetworkRequest) (*GetNetworkResponse, error)
 	GetEndpoints(context.Context, *GetEndpointsRequest) (*GetEndpointsResponse, error)
 	GetNetworks(context.Context, *GetNetworksRequest) (*GetNetworksResponse, error)
+	ReconcileEndpoints(context.Context, *ReconcileEndpointsRequest) (*ReconcileEndpointsResponse, error)
 	mustEmbedUnimplementedNetworkConfigProxyServer()
 }
 
@@ -241,6 +254,9 @@ func (UnimplementedNetworkConfigProxyServer) GetEndpoints(context.Context, *GetE
 func (UnimplementedNetworkConfigProxyServer) GetNetworks(context.Context, *GetNetworksRequest) (*GetNetworksResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetNetworks not implemented")
 }
+func (UnimplementedNetworkConfigProxyServer) ReconcileEndpoints(context.Context, *ReconcileEndpointsRequest) (*ReconcileEndpointsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReconcileEndpoints not implemented")
+}
 func (UnimplementedNetworkConfigProxyServer) mustEmbedUnimplementedNetworkConfigProxyServer() {}
 func (UnimplementedNetworkConfigProxyServer) testEmbeddedByValue()                            {}
 
@@ -478,6 +494,24 @@ func _NetworkConfigProxy_GetNetworks_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NetworkConfigProxy_ReconcileEndpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileEndpointsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NetworkConfigProxyServer).ReconcileEndpoints(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NetworkConfigProxy_ReconcileEndpoints_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NetworkConfigProxyServer).ReconcileEndpoints(ctx, req.(*ReconcileEndpointsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NetworkConfigProxy_ServiceDesc is the grpc.ServiceDesc for NetworkConfigProxy service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -533,6 +567,10 @@ var NetworkConfigProxy_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetNetworks",
 			Handler:    _NetworkConfigProxy_GetNetworks_Handler,
 		},
+		{
+			MethodName: "ReconcileEndpoints",
+			Handler:    _NetworkConfigProxy_ReconcileEndpoints_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "github.com/Microsoft/hcsshim/pkg/ncproxy/ncproxygrpc/v1/networkconfigproxy.proto",
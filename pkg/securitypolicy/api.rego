@@ -21,4 +21,5 @@ enforcement_points := {
     "load_fragment": {"introducedVersion": "0.9.0", "default_results": {"allowed": false, "add_module": false}},
     "scratch_mount": {"introducedVersion": "0.10.0", "default_results": {"allowed": true}},
     "scratch_unmount": {"introducedVersion": "0.10.0", "default_results": {"allowed": true}},
+    "tpm_access": {"introducedVersion": "0.12.0", "default_results": {"allowed": false}},
 }
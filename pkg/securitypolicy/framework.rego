@@ -1042,6 +1042,12 @@ runtime_logging := {"allowed": true} {
     allow_runtime_logging
 }
 
+default tpm_access := {"allowed": false}
+
+tpm_access := {"allowed": true} {
+    allow_tpm_access
+}
+
 default fragment_containers := []
 
 fragment_containers := data[input.namespace].containers
@@ -1595,6 +1601,11 @@ errors["unencrypted scratch not allowed"] {
     not input.encrypted
 }
 
+errors["TPM access not allowed"] {
+    input.rule == "tpm_access"
+    not allow_tpm_access
+}
+
 errors["no scratch at path to unmount"] {
     input.rule == "scratch_unmount"
     not scratch_mounted(input.unmountTarget)
@@ -2076,6 +2087,13 @@ allow_capability_dropping := flag {
     flag := data.policy.allow_capability_dropping
 }
 
+default allow_tpm_access := false
+
+allow_tpm_access := flag {
+    semver.compare(policy_framework_version, "0.5.0") >= 0
+    flag := data.policy.allow_tpm_access
+}
+
 default policy_framework_version := null
 default policy_api_version := null
 
@@ -122,6 +122,13 @@ func WithAllowCapabilityDropping(allow bool) PolicyConfigOpt {
 	}
 }
 
+func WithAllowTPMAccess(allow bool) PolicyConfigOpt {
+	return func(config *PolicyConfig) error {
+		config.AllowTPMAccess = allow
+		return nil
+	}
+}
+
 func WithAllowRuntimeLogging(allow bool) PolicyConfigOpt {
 	return func(config *PolicyConfig) error {
 		config.AllowRuntimeLogging = allow
@@ -23,4 +23,5 @@ runtime_logging := data.framework.runtime_logging
 load_fragment := data.framework.load_fragment
 scratch_mount := data.framework.scratch_mount
 scratch_unmount := data.framework.scratch_unmount
+tpm_access := data.framework.tpm_access
 reason := data.framework.reason
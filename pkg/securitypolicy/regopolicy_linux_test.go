@@ -73,6 +73,7 @@ func Test_MarshalRego_Policy(t *testing.T) {
 			p.allowEnvironmentVariableDropping,
 			p.allowUnencryptedScratch,
 			p.allowCapabilityDropping,
+			p.allowTPMAccess,
 		)
 		if err != nil {
 			t.Error(err)
@@ -3525,6 +3526,37 @@ func Test_EnforceRuntimeLogging_Not_Allowed(t *testing.T) {
 	}
 }
 
+func Test_Rego_TPMAccess_Allowed(t *testing.T) {
+	gc := generateConstraints(testRand, maxContainersInGeneratedConstraints)
+	gc.allowTPMAccess = true
+
+	tc, err := setupRegoPolicyOnlyTest(gc)
+	if err != nil {
+		t.Fatalf("unable to setup test: %v", err)
+	}
+
+	err = tc.policy.EnforceTPMAccessPolicy(gc.ctx, generateContainerID(testRand))
+	if err != nil {
+		t.Fatalf("Policy enforcement unexpectedly was denied: %v", err)
+	}
+}
+
+func Test_Rego_TPMAccess_Not_Allowed(t *testing.T) {
+	gc := generateConstraints(testRand, maxContainersInGeneratedConstraints)
+	gc.allowTPMAccess = false
+
+	tc, err := setupRegoPolicyOnlyTest(gc)
+	if err != nil {
+		t.Fatalf("unable to setup test: %v", err)
+	}
+
+	err = tc.policy.EnforceTPMAccessPolicy(gc.ctx, generateContainerID(testRand))
+	if err == nil {
+		t.Fatal("Policy enforcement unexpectedly was allowed")
+	}
+	assertDecisionJSONContains(t, err, "TPM access not allowed")
+}
+
 func Test_Rego_LoadFragment_Container(t *testing.T) {
 	f := func(p *generatedConstraints) bool {
 		tc, err := setupRegoFragmentTestConfigWithIncludes(p, []string{"containers"})
@@ -6338,3 +6370,147 @@ func substituteUVMPath(sandboxID string, m mountInternal) mountInternal {
 	}
 	return m
 }
+
+func Test_Rego_EnforceCreateContainerPolicy_Sysctls(t *testing.T) {
+	code := fmt.Sprintf(`package policy
+
+import future.keywords.every
+
+api_version := "%s"
+framework_version := "%s"
+
+default create_container := {"allowed": false}
+
+create_container := {"allowed": true, "allow_stdio_access": true} {
+	every name, _ in input.sysctls {
+		startswith(name, "net.")
+	}
+}
+`, apiVersion, frameworkVersion)
+
+	for _, tc := range []struct {
+		name    string
+		sysctls map[string]string
+		allowed bool
+	}{
+		{
+			name:    "None",
+			allowed: true,
+		},
+		{
+			name:    "Allowed",
+			sysctls: map[string]string{"net.core.somaxconn": "1024"},
+			allowed: true,
+		},
+		{
+			name:    "Denied",
+			sysctls: map[string]string{"net.core.somaxconn": "1024", "kernel.sem": "250 32000 32 128"},
+			allowed: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := newRegoPolicy(code, []oci.Mount{}, []oci.Mount{}, testOSType)
+			if err != nil {
+				t.Fatalf("unable to create policy: %v", err)
+			}
+
+			_, _, _, err = policy.EnforceCreateContainerPolicyV2(context.Background(), "container", []string{"sh"}, nil, "/", nil, IDName{},
+				&CreateContainerOptions{
+					Capabilities: &oci.LinuxCapabilities{},
+					Sysctls:      tc.sysctls,
+				})
+			if tc.allowed && err != nil {
+				t.Fatalf("expected sysctls to be allowed: %v", err)
+			}
+			if !tc.allowed && err == nil {
+				t.Fatal("expected sysctls to be denied")
+			}
+		})
+	}
+}
+
+func Test_Rego_EnforceCreateContainerPolicy_HostnameSuffix(t *testing.T) {
+	code := fmt.Sprintf(`package policy
+
+import future.keywords.in
+
+api_version := "%s"
+framework_version := "%s"
+
+default create_container := {"allowed": false}
+
+create_container := {"allowed": true, "allow_stdio_access": true} {
+	input.hostnameSuffix in {"", "svc.cluster.local"}
+}
+`, apiVersion, frameworkVersion)
+
+	for _, tc := range []struct {
+		name    string
+		suffix  string
+		allowed bool
+	}{
+		{
+			name:    "None",
+			allowed: true,
+		},
+		{
+			name:    "Allowed",
+			suffix:  "svc.cluster.local",
+			allowed: true,
+		},
+		{
+			name:    "Denied",
+			suffix:  "attacker.example.com",
+			allowed: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, err := newRegoPolicy(code, []oci.Mount{}, []oci.Mount{}, testOSType)
+			if err != nil {
+				t.Fatalf("unable to create policy: %v", err)
+			}
+
+			_, _, _, err = policy.EnforceCreateContainerPolicyV2(context.Background(), "container", []string{"sh"}, nil, "/", nil, IDName{},
+				&CreateContainerOptions{
+					Capabilities:   &oci.LinuxCapabilities{},
+					HostnameSuffix: tc.suffix,
+				})
+			if tc.allowed && err != nil {
+				t.Fatalf("expected hostname suffix to be allowed: %v", err)
+			}
+			if !tc.allowed && err == nil {
+				t.Fatal("expected hostname suffix to be denied")
+			}
+		})
+	}
+}
+
+func Test_newOptionsFromConfig_Propagation(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   MountConfig
+		expected []string
+	}{
+		{
+			name:     "Default",
+			config:   MountConfig{HostPath: "/host"},
+			expected: []string{"rbind", "rprivate", "rw"},
+		},
+		{
+			name:     "DefaultSandbox",
+			config:   MountConfig{HostPath: guestpath.SandboxMountPrefix + "/test", Readonly: true},
+			expected: []string{"rbind", "rshared", "ro"},
+		},
+		{
+			name:     "Explicit",
+			config:   MountConfig{HostPath: "/host", Propagation: "rshared"},
+			expected: []string{"rbind", "rshared", "rw"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if opts := newOptionsFromConfig(&tc.config); !slices.Equal(opts, tc.expected) {
+				t.Fatalf("expected options %v, got %v", tc.expected, opts)
+			}
+		})
+	}
+}
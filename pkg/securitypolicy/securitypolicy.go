@@ -67,6 +67,8 @@ type PolicyConfig struct {
 	// all containers within a pod to be run without scratch encryption.
 	AllowUnencryptedScratch bool `json:"allow_unencrypted_scratch" toml:"allow_unencrypted_scratch"`
 	AllowCapabilityDropping bool `json:"allow_capability_dropping" toml:"allow_capability_dropping"`
+	// AllowTPMAccess allows containers to access the virtual TPM of the UVM.
+	AllowTPMAccess bool `json:"allow_tpm_access" toml:"allow_tpm_access"`
 }
 
 func NewPolicyConfig(opts ...PolicyConfigOpt) (*PolicyConfig, error) {
@@ -180,6 +182,9 @@ type MountConfig struct {
 	HostPath      string `json:"host_path" toml:"host_path"`
 	ContainerPath string `json:"container_path" toml:"container_path"`
 	Readonly      bool   `json:"readonly" toml:"readonly"`
+	// Propagation is the mount propagation the mount must use (`rprivate`, `rslave`,
+	// or `rshared`). If empty, it follows the CRI plugin's defaults.
+	Propagation string `json:"propagation,omitempty" toml:"propagation"`
 }
 
 // ExecProcessConfig contains toml or JSON config for exec process security
@@ -464,7 +469,9 @@ func newMountOptions(opts []string) Options {
 func newOptionsFromConfig(mCfg *MountConfig) []string {
 	mountOpts := []string{"rbind"}
 
-	if strings.HasPrefix(mCfg.HostPath, guestpath.SandboxMountPrefix) ||
+	if mCfg.Propagation != "" {
+		mountOpts = append(mountOpts, mCfg.Propagation)
+	} else if strings.HasPrefix(mCfg.HostPath, guestpath.SandboxMountPrefix) ||
 		strings.HasPrefix(mCfg.HostPath, guestpath.HugePagesMountPrefix) {
 		mountOpts = append(mountOpts, "rshared")
 	} else {
@@ -67,6 +67,7 @@ type OSAwareMarshalFunc func(
 	allowEnvironmentVariableDropping bool,
 	allowUnencryptedScratch bool,
 	allowCapabilityDropping bool,
+	allowTPMAccess bool,
 ) (string, error)
 
 // osAwareMarshalRego handles both Linux and Windows containers
@@ -83,6 +84,7 @@ func osAwareMarshalRego(
 	allowEnvironmentVariableDropping bool,
 	allowUnencryptedScratch bool,
 	allowCapabilityDropping bool,
+	allowTPMAccess bool,
 ) (string, error) {
 	if allowAll {
 		if len(linuxContainers) > 0 || len(windowsContainers) > 0 {
@@ -98,7 +100,7 @@ func osAwareMarshalRego(
 		}
 		return marshalRego(allowAll, linuxContainers, externalProcesses, fragments,
 			allowPropertiesAccess, allowDumpStacks, allowRuntimeLogging,
-			allowEnvironmentVariableDropping, allowUnencryptedScratch, allowCapabilityDropping)
+			allowEnvironmentVariableDropping, allowUnencryptedScratch, allowCapabilityDropping, allowTPMAccess)
 
 	case "windows":
 		if len(linuxContainers) > 0 {
@@ -106,7 +108,7 @@ func osAwareMarshalRego(
 		}
 		return marshalWindowsRego(allowAll, windowsContainers, externalProcesses, fragments,
 			allowPropertiesAccess, allowDumpStacks, allowRuntimeLogging,
-			allowEnvironmentVariableDropping, allowUnencryptedScratch, allowCapabilityDropping)
+			allowEnvironmentVariableDropping, allowUnencryptedScratch, allowCapabilityDropping, allowTPMAccess)
 
 	default:
 		return "", fmt.Errorf("unsupported OS type: %s", osType)
@@ -125,6 +127,7 @@ func marshalWindowsRego(
 	allowEnvironmentVariableDropping bool,
 	allowUnencryptedScratch bool,
 	allowCapabilityDropping bool,
+	allowTPMAccess bool,
 ) (string, error) {
 	if allowAll {
 		if len(containers) > 0 {
@@ -149,6 +152,7 @@ func marshalWindowsRego(
 		AllowEnvironmentVariableDropping: allowEnvironmentVariableDropping,
 		AllowUnencryptedScratch:          allowUnencryptedScratch,
 		AllowCapabilityDropping:          allowCapabilityDropping,
+		AllowTPMAccess:                   allowTPMAccess,
 	}
 
 	return policy.marshalWindowsRego(), nil
@@ -167,6 +171,7 @@ func marshalJSON(
 	_ bool,
 	_ bool,
 	_ bool,
+	_ bool,
 ) (string, error) {
 	var policy *SecurityPolicy
 	if allowAll {
@@ -198,6 +203,7 @@ func marshalRego(
 	allowEnvironmentVariableDropping bool,
 	allowUnencryptedScratch bool,
 	allowCapabilityDropping bool,
+	allowTPMAccess bool,
 ) (string, error) {
 	if allowAll {
 		if len(containers) > 0 {
@@ -217,6 +223,7 @@ func marshalRego(
 		allowEnvironmentVariableDropping,
 		allowUnencryptedScratch,
 		allowCapabilityDropping,
+		allowTPMAccess,
 	)
 	if err != nil {
 		return "", err
@@ -251,6 +258,7 @@ func MarshalPolicy(
 	allowEnvironmentVariableDropping bool,
 	allowUnencryptedScratch bool,
 	allowCapbilitiesDropping bool,
+	allowTPMAccess bool,
 ) (string, error) {
 	if marshaller == "" {
 		marshaller = defaultMarshaller
@@ -272,6 +280,7 @@ func MarshalPolicy(
 			allowEnvironmentVariableDropping,
 			allowUnencryptedScratch,
 			allowCapbilitiesDropping,
+			allowTPMAccess,
 		)
 	}
 }
@@ -591,6 +600,7 @@ func (p securityPolicyInternal) marshalRego() string {
 	writeLine(builder, "allow_environment_variable_dropping := %t", p.AllowEnvironmentVariableDropping)
 	writeLine(builder, "allow_unencrypted_scratch := %t", p.AllowUnencryptedScratch)
 	writeLine(builder, "allow_capability_dropping := %t", p.AllowCapabilityDropping)
+	writeLine(builder, "allow_tpm_access := %t", p.AllowTPMAccess)
 	result := strings.Replace(policyRegoTemplate, "@@OBJECTS@@", builder.String(), 1)
 	result = strings.Replace(result, "@@API_VERSION@@", apiVersion, 1)
 	result = strings.Replace(result, "@@FRAMEWORK_VERSION@@", frameworkVersion, 1)
@@ -616,6 +626,7 @@ func (p securityPolicyWindowsInternal) marshalWindowsRego() string {
 	writeLine(builder, "allow_environment_variable_dropping := %t", p.AllowEnvironmentVariableDropping)
 	writeLine(builder, "allow_unencrypted_scratch := %t", p.AllowUnencryptedScratch)
 	writeLine(builder, "allow_capability_dropping := %t", p.AllowCapabilityDropping)
+	writeLine(builder, "allow_tpm_access := %t", p.AllowTPMAccess)
 	result := strings.Replace(policyRegoTemplate, "@@OBJECTS@@", builder.String(), 1)
 	result = strings.Replace(result, "@@API_VERSION@@", apiVersion, 1)
 	result = strings.Replace(result, "@@FRAMEWORK_VERSION@@", frameworkVersion, 1)
@@ -29,6 +29,11 @@ type CreateContainerOptions struct {
 	Umask                string
 	Capabilities         *oci.LinuxCapabilities
 	SeccompProfileSHA256 string
+	// Sysctls are the sysctls set in the container's OCI spec.
+	Sysctls map[string]string
+	// HostnameSuffix is the DNS domain the GCS adds to the search domains of
+	// the container's /etc/resolv.conf, if any.
+	HostnameSuffix string
 }
 type SignalContainerOptions struct {
 	IsInitProcess bool
@@ -125,6 +130,7 @@ type SecurityPolicyEnforcer interface {
 	EnforceScratchUnmountPolicy(ctx context.Context, scratchPath string) (err error)
 	GetUserInfo(spec *oci.Process, rootPath string) (IDName, []IDName, string, error)
 	EnforceVerifiedCIMsPolicy(ctx context.Context, containerID string, layerHashes []string) (err error)
+	EnforceTPMAccessPolicy(ctx context.Context, containerID string) (err error)
 }
 
 //nolint:unused
@@ -309,6 +315,10 @@ func (OpenDoorSecurityPolicyEnforcer) EnforceVerifiedCIMsPolicy(ctx context.Cont
 	return nil
 }
 
+func (OpenDoorSecurityPolicyEnforcer) EnforceTPMAccessPolicy(context.Context, string) error {
+	return nil
+}
+
 type ClosedDoorSecurityPolicyEnforcer struct{}
 
 var _ SecurityPolicyEnforcer = (*ClosedDoorSecurityPolicyEnforcer)(nil)
@@ -425,3 +435,7 @@ func (ClosedDoorSecurityPolicyEnforcer) GetUserInfo(spec *oci.Process, rootPath
 func (ClosedDoorSecurityPolicyEnforcer) EnforceVerifiedCIMsPolicy(ctx context.Context, containerID string, layerHashes []string) error {
 	return nil
 }
+
+func (ClosedDoorSecurityPolicyEnforcer) EnforceTPMAccessPolicy(context.Context, string) error {
+	return errors.New("accessing the TPM is denied by policy")
+}
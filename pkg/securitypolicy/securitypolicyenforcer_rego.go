@@ -699,6 +699,10 @@ func (policy *regoEnforcer) EnforceCreateContainerPolicyV2(
 	if envList == nil {
 		envList = []string{}
 	}
+	sysctls := opts.Sysctls
+	if sysctls == nil {
+		sysctls = map[string]string{}
+	}
 	switch policy.osType {
 	case "linux":
 		input = inputData{
@@ -716,6 +720,8 @@ func (policy *regoEnforcer) EnforceCreateContainerPolicyV2(
 			"umask":                opts.Umask,
 			"capabilities":         mapifyCapabilities(opts.Capabilities),
 			"seccompProfileSHA256": opts.SeccompProfileSHA256,
+			"sysctls":              sysctls,
+			"hostnameSuffix":       opts.HostnameSuffix,
 		}
 	case "windows":
 		input = inputData{
@@ -1075,6 +1081,14 @@ func (policy *regoEnforcer) EnforceVerifiedCIMsPolicy(ctx context.Context, conta
 	return err
 }
 
+func (policy *regoEnforcer) EnforceTPMAccessPolicy(ctx context.Context, containerID string) error {
+	input := inputData{
+		"containerID": containerID,
+	}
+	_, err := policy.enforce(ctx, "tpm_access", input)
+	return err
+}
+
 func (policy *regoEnforcer) GetUserInfo(process *oci.Process, rootPath string) (IDName, []IDName, string, error) {
 	return GetAllUserInfo(process, rootPath)
 }
@@ -0,0 +1,53 @@
+//go:build windows
+
+// Package uvmestimate estimates the host memory used by the utility VMs that
+// the containerd shim creates for hypervisor-isolated pod sandboxes, so that
+// schedulers can account for a sandbox before it is created.
+package uvmestimate
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+)
+
+// MemoryEstimate is the host memory a utility VM is expected to use.
+type MemoryEstimate = uvm.MemoryEstimate
+
+// ForPodSandbox returns the host memory that the utility VM of the pod sandbox
+// with the OCI spec `s` is expected to use, when it is created by a shim with
+// the runtime options `shimOpts`, which may be nil.
+//
+// The spec is parsed as the shim parses it, and the estimate is computed from
+// the processors, memory and devices the utility VM would be created with. The
+// processor count is lowered to what this host has. `s` is not modified.
+func ForPodSandbox(ctx context.Context, s *specs.Spec, shimOpts *runhcsopts.Options) (MemoryEstimate, error) {
+	spec := *s
+	spec.Annotations = maps.Clone(s.Annotations)
+	if spec.Annotations == nil {
+		spec.Annotations = make(map[string]string)
+	}
+	spec = oci.UpdateSpecFromOptions(spec, shimOpts)
+	if err := oci.ProcessAnnotations(ctx, &spec); err != nil {
+		return MemoryEstimate{}, fmt.Errorf("failed to process OCI spec annotations: %w", err)
+	}
+
+	opts, err := oci.SpecToUVMCreateOpts(ctx, &spec, "", "")
+	if err != nil {
+		return MemoryEstimate{}, err
+	}
+	switch opts := opts.(type) {
+	case *uvm.OptionsLCOW:
+		return uvm.EstimateLCOWOverhead(ctx, opts)
+	case *uvm.OptionsWCOW:
+		return uvm.EstimateWCOWOverhead(ctx, opts)
+	default:
+		return MemoryEstimate{}, fmt.Errorf("unexpected utility VM options type %T", opts)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package uvmestimate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+)
+
+func TestForPodSandboxProcessIsolated(t *testing.T) {
+	s := &specs.Spec{Windows: &specs.Windows{}}
+	if e, err := ForPodSandbox(context.Background(), s, &runhcsopts.Options{VmMemorySizeInMb: 1024}); err == nil {
+		t.Fatalf("expected error estimating a process-isolated sandbox, got %+v", e)
+	}
+	if s.Annotations != nil {
+		t.Fatalf("expected the spec to be unmodified, got annotations %v", s.Annotations)
+	}
+}
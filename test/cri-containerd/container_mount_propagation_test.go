@@ -0,0 +1,88 @@
+//go:build windows && functional
+// +build windows,functional
+
+package cri_containerd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	runtime "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/Microsoft/hcsshim/pkg/annotations"
+)
+
+func Test_Container_MountPropagation_LCOW(t *testing.T) {
+	requireFeatures(t, featureLCOW)
+
+	pullRequiredLCOWImages(t, []string{imageLcowK8sPause, imageLcowAlpine})
+
+	client := newTestRuntimeClient(t)
+	ctx := context.Background()
+
+	sbRequest := getRunPodSandboxRequest(t, lcowRuntimeHandler)
+	podID := runPodSandbox(t, client, ctx, sbRequest)
+	defer removePodSandbox(t, client, ctx, podID)
+	defer stopPodSandbox(t, client, ctx, podID)
+
+	command := []string{"top"}
+	mountsWith := func(p runtime.MountPropagation) []*runtime.Mount {
+		return []*runtime.Mount{
+			{
+				HostPath:      "sandbox:///propagation",
+				ContainerPath: "/test",
+				Propagation:   p,
+			},
+		}
+	}
+
+	t.Run("SharedNotAllowed", func(t *testing.T) {
+		cID := createContainerInSandbox(t, client, ctx, podID, t.Name()+"-Container", imageLcowAlpine, command,
+			map[string]string{annotations.LCOWPrivileged: "true"},
+			mountsWith(runtime.MountPropagation_PROPAGATION_BIDIRECTIONAL), sbRequest.Config)
+		defer removeContainer(t, client, ctx, cID)
+
+		_, err := client.StartContainer(ctx, &runtime.StartContainerRequest{ContainerId: cID})
+		if err == nil {
+			stopContainer(t, client, ctx, cID)
+			t.Fatal("expected container with rshared mount to fail without the annotation")
+		}
+		if !strings.Contains(err.Error(), annotations.LCOWAllowRSharedMounts) {
+			t.Fatalf("expected error to mention %q, got: %v", annotations.LCOWAllowRSharedMounts, err)
+		}
+	})
+
+	// the first container creates a mount after it has started, which must be visible
+	// in the second container through its rslave mount of the same directory
+	sharedID := createContainerInSandbox(t, client, ctx, podID, t.Name()+"-Shared", imageLcowAlpine, command,
+		map[string]string{
+			annotations.LCOWPrivileged:         "true",
+			annotations.LCOWAllowRSharedMounts: "true",
+		},
+		mountsWith(runtime.MountPropagation_PROPAGATION_BIDIRECTIONAL), sbRequest.Config)
+	defer removeContainer(t, client, ctx, sharedID)
+	startContainer(t, client, ctx, sharedID)
+	defer stopContainer(t, client, ctx, sharedID)
+
+	slaveID := createContainerInSandbox(t, client, ctx, podID, t.Name()+"-Slave", imageLcowAlpine, command,
+		nil, mountsWith(runtime.MountPropagation_PROPAGATION_HOST_TO_CONTAINER), sbRequest.Config)
+	defer removeContainer(t, client, ctx, slaveID)
+	startContainer(t, client, ctx, slaveID)
+	defer stopContainer(t, client, ctx, slaveID)
+
+	_, errorMsg, exitCode := execContainer(t, client, ctx, sharedID, []string{
+		"sh", "-c", "mkdir -p /test/tmpfs && mount -t tmpfs tmpfs /test/tmpfs && echo propagated > /test/tmpfs/file",
+	})
+	if exitCode != 0 {
+		t.Fatalf("failed to create mount in container %s: %s (exit code %d)", sharedID, errorMsg, exitCode)
+	}
+
+	output, errorMsg, exitCode := execContainer(t, client, ctx, slaveID, []string{"cat", "/test/tmpfs/file"})
+	if exitCode != 0 {
+		t.Fatalf("mount was not propagated to container %s: %s (exit code %d)", slaveID, errorMsg, exitCode)
+	}
+	if strings.TrimSpace(output) != "propagated" {
+		t.Fatalf("expected propagated file contents, got %q", output)
+	}
+}
@@ -0,0 +1,84 @@
+//go:build windows && functional
+// +build windows,functional
+
+package cri_containerd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/test/pkg/definitions/shimdiag"
+)
+
+func Test_Container_Snapshot_WCOW(t *testing.T) {
+	requireAnyFeature(t, featureWCOWProcess, featureWCOWHypervisor)
+
+	for _, tc := range []struct {
+		name           string
+		feature        string
+		runtimeHandler string
+	}{
+		{
+			name:           "Process",
+			feature:        featureWCOWProcess,
+			runtimeHandler: wcowProcessRuntimeHandler,
+		},
+		{
+			name:           "Hypervisor",
+			feature:        featureWCOWHypervisor,
+			runtimeHandler: wcowHypervisorRuntimeHandler,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requireFeatures(t, tc.feature)
+			pullRequiredImages(t, []string{imageWindowsNanoserver})
+
+			client := newTestRuntimeClient(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sbRequest := getRunPodSandboxRequest(t, tc.runtimeHandler)
+			podID := runPodSandbox(t, client, ctx, sbRequest)
+			defer removePodSandbox(t, client, ctx, podID)
+			defer stopPodSandbox(t, client, ctx, podID)
+
+			cID := createContainerInSandbox(t, client, ctx, podID, t.Name()+"-Container", imageWindowsNanoserver,
+				[]string{"cmd", "/c", "ping", "-t", "127.0.0.1"}, nil, nil, sbRequest.Config)
+			defer removeContainer(t, client, ctx, cID)
+			startContainer(t, client, ctx, cID)
+			defer stopContainer(t, client, ctx, cID)
+
+			shim, err := shimdiag.GetShim(fmt.Sprintf("k8s.io-%s", podID))
+			if err != nil {
+				t.Fatalf("could not get shim for pod %q: %v", podID, err)
+			}
+			defer shim.Close()
+			svc := shimdiag.NewShimDiagClient(shim)
+
+			path := filepath.Join(t.TempDir(), "snapshot")
+			resp, err := svc.DiagSnapshot(ctx, &shimdiag.SnapshotRequest{ID: cID, Path: path})
+			if err != nil {
+				t.Fatalf("failed to snapshot container %s: %v", cID, err)
+			}
+			if resp.Path != path {
+				t.Fatalf("expected snapshot at %q, got %q", path, resp.Path)
+			}
+			if _, err := os.Stat(filepath.Join(path, "sandbox.vhdx")); err != nil {
+				t.Fatalf("snapshot is missing the scratch VHD: %v", err)
+			}
+
+			// the container must have been resumed after the snapshot
+			_, errorMsg, exitCode := execContainer(t, client, ctx, cID, []string{"cmd", "/c", "echo", "resumed"})
+			if exitCode != 0 {
+				t.Fatalf("failed to exec in container %s after snapshot: %s (exit code %d)", cID, errorMsg, exitCode)
+			}
+
+			if _, err := svc.DiagSnapshot(ctx, &shimdiag.SnapshotRequest{ID: cID, Path: path}); err == nil {
+				t.Fatal("expected snapshot into an existing directory to fail")
+			}
+		})
+	}
+}
@@ -5,20 +5,36 @@ package functional
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/Microsoft/go-winio/pkg/guid"
 	ctrdoci "github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/windows"
 
+	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/stats"
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	hcsschema "github.com/Microsoft/hcsshim/internal/hcs/schema2"
+	"github.com/Microsoft/hcsshim/internal/hcsoci"
 	"github.com/Microsoft/hcsshim/internal/jobcontainers"
+	"github.com/Microsoft/hcsshim/internal/layers"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/osversion"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 
 	testcmd "github.com/Microsoft/hcsshim/test/internal/cmd"
 	testcontainer "github.com/Microsoft/hcsshim/test/internal/container"
 	testlayers "github.com/Microsoft/hcsshim/test/internal/layers"
 	testoci "github.com/Microsoft/hcsshim/test/internal/oci"
 	"github.com/Microsoft/hcsshim/test/internal/util"
+	testimages "github.com/Microsoft/hcsshim/test/pkg/images"
 	"github.com/Microsoft/hcsshim/test/pkg/require"
 	testuvm "github.com/Microsoft/hcsshim/test/pkg/uvm"
 )
@@ -663,3 +679,540 @@ func TestContainerExec_DoubleQuotes(t *testing.T) {
 		io.TestStdOutContains(t, []string{acl}, nil)
 	}) // WCOW HostProcess
 }
+
+func TestContainer_WCOW_ExtraLayerFolders(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	const want = "extra layer"
+	ls := windowsImageLayers(ctx, t)
+	extra := testlayers.WCOWLayerFromFiles(ctx, t, map[string]string{"extra.txt": want}, ls)
+
+	cID := testName(t, "container")
+	scratch := testlayers.WCOWScratchDir(ctx, t, "")
+	spec := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts(cID,
+			ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+			ctrdoci.WithAnnotations(map[string]string{
+				annotations.WCOWExtraLayerFolders: extra,
+			}),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)),
+		)...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+	init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	ps := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts(cID,
+			ctrdoci.WithProcessCommandLine(`cmd /c type C:\extra.txt`),
+		)...).Process
+	io := testcmd.NewBufferedIO()
+	p := testcmd.Create(ctx, t, c, ps, io)
+	testcmd.Start(ctx, t, p)
+	testcmd.WaitExitCode(ctx, t, p, 0)
+	io.TestOutput(t, want, nil)
+}
+
+func Test_CreateContainer_WCOW_Process_StorageSandbox_DifferentDrive(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	// the sandbox must be on a local fixed drive other than the one holding the image layers
+	const drive = `D:\`
+	if windows.GetDriveType(windows.StringToUTF16Ptr(drive)) != windows.DRIVE_FIXED {
+		t.Skipf("drive %s is not a fixed drive", drive)
+	}
+	ls := windowsImageLayers(ctx, t)
+	if v := filepath.VolumeName(ls[0]); !strings.EqualFold(v, "C:") {
+		t.Skipf("image layers must be on C:, got %s", v)
+	}
+
+	dir, err := os.MkdirTemp(drive, util.CleanName(t))
+	if err != nil {
+		t.Fatalf("could not create sandbox directory on %s: %v", drive, err)
+	}
+	scratch := testlayers.WCOWScratchDir(ctx, t, dir)
+
+	cID := testName(t, "container")
+	spec := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts(cID,
+			ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)),
+		)...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+	init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	if _, err := os.Stat(filepath.Join(scratch, "sandbox.vhdx")); err != nil {
+		t.Fatalf("sandbox VHD was not created on %s: %v", drive, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		cmd  string
+		want string
+	}{
+		{
+			name: "sandbox writable",
+			cmd:  `cmd /c echo sandbox> C:\sandbox.txt && type C:\sandbox.txt`,
+			want: "sandbox",
+		},
+		{
+			name: "image layers accessible",
+			cmd:  `cmd /c if exist C:\Windows\System32\cmd.exe (echo found) else (echo missing)`,
+			want: "found",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := testoci.CreateWindowsSpec(ctx, t, cID,
+				testoci.DefaultWindowsSpecOpts(cID,
+					ctrdoci.WithProcessCommandLine(tt.cmd),
+				)...).Process
+			io := testcmd.NewBufferedIO()
+			p := testcmd.Create(ctx, t, c, ps, io)
+			testcmd.Start(ctx, t, p)
+			testcmd.WaitExitCode(ctx, t, p, 0)
+			io.TestOutput(t, tt.want, nil)
+		})
+	}
+}
+
+func TestContainer_WCOW_Process_PropertiesV2(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	cID := testName(t, "container")
+	scratch := testlayers.WCOWScratchDir(ctx, t, "")
+	spec := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts(cID,
+			ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+			testoci.WithWindowsLayerFolders(append(windowsImageLayers(ctx, t), scratch)),
+		)...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+	init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	system, ok := c.(*hcs.System)
+	if !ok {
+		t.Fatalf("expected type *hcs.System; got %T", c)
+	}
+
+	s, err := system.Statistics(ctx)
+	if err != nil {
+		t.Fatalf("failed to query statistics: %v", err)
+	}
+	if s.Processor == nil || s.Memory == nil {
+		t.Fatalf("expected processor and memory statistics, got %+v", s)
+	}
+
+	g, err := system.SiloGUID(ctx)
+	if err != nil {
+		t.Fatalf("failed to query silo GUID: %v", err)
+	}
+	if g == (guid.GUID{}) {
+		t.Fatal("expected non-zero silo GUID")
+	}
+}
+
+func TestContainer_WCOW_Process_MappedDirectory(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	cID := testName(t, "container")
+	scratch := testlayers.WCOWScratchDir(ctx, t, "")
+	spec := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts(cID,
+			ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+			testoci.WithWindowsLayerFolders(append(windowsImageLayers(ctx, t), scratch)),
+		)...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+	init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	system, ok := c.(*hcs.System)
+	if !ok {
+		t.Fatalf("expected type *hcs.System; got %T", c)
+	}
+
+	const (
+		containerPath = `C:\mapped`
+		contents      = "hello from the host"
+	)
+	hostPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(hostPath, "file.txt"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	readFile := func(tb testing.TB, exitCode int) *testcmd.BufferedIO {
+		tb.Helper()
+		ps := testoci.CreateWindowsSpec(ctx, tb, cID,
+			testoci.DefaultWindowsSpecOpts(cID,
+				ctrdoci.WithProcessCommandLine(`cmd /c type `+containerPath+`\file.txt`),
+			)...).Process
+		io := testcmd.NewBufferedIO()
+		p := testcmd.Create(ctx, tb, c, ps, io)
+		testcmd.Start(ctx, tb, p)
+		testcmd.WaitExitCode(ctx, tb, p, exitCode)
+		return io
+	}
+
+	if err := system.ModifyMappedDirectory(ctx, true, hostPath, containerPath, true); err != nil {
+		if hcs.IsNotSupported(err) {
+			t.Skipf("adding mapped directories to a running container is not supported: %v", err)
+		}
+		t.Fatalf("failed to add mapped directory: %v", err)
+	}
+	readFile(t, 0).TestOutput(t, contents, nil)
+
+	if err := system.ModifyMappedDirectory(ctx, false, hostPath, containerPath, true); err != nil {
+		t.Fatalf("failed to remove mapped directory: %v", err)
+	}
+	readFile(t, 1)
+}
+
+func TestContainer_WCOW_Process_Events(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	cID := testName(t, "container")
+	scratch := testlayers.WCOWScratchDir(ctx, t, "")
+	spec := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts(cID,
+			ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+			testoci.WithWindowsLayerFolders(append(windowsImageLayers(ctx, t), scratch)),
+		)...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	system, ok := c.(*hcs.System)
+	if !ok {
+		t.Fatalf("expected type *hcs.System; got %T", c)
+	}
+	events := system.Events()
+
+	// waitEvent returns the next event of type n, failing the test if the channel is
+	// closed first.
+	waitEvent := func(t *testing.T, n hcs.ContainerNotification) hcs.ContainerEvent {
+		t.Helper()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					t.Fatalf("events channel closed before %s event", n)
+				}
+				t.Logf("received event %s: %v", e.Notification, e.Err)
+				if e.Notification == n {
+					return e
+				}
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for %s event: %v", n, ctx.Err())
+			}
+		}
+	}
+
+	init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+	t.Cleanup(func() {
+		// the init process is killed along with the container
+		_ = init.Wait()
+	})
+	if e := waitEvent(t, hcs.ContainerNotificationStartCompleted); e.Err != nil {
+		t.Fatalf("expected start event without error, got: %v", e.Err)
+	}
+
+	if err := c.Terminate(ctx); err != nil {
+		t.Fatalf("failed to terminate container: %v", err)
+	}
+	waitEvent(t, hcs.ContainerNotificationExited)
+	testcontainer.Wait(ctx, t, c)
+
+	// the channel is closed after the exit event
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Fatalf("expected events channel to be closed, got event %s", e.Notification)
+		}
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for events channel to close: %v", ctx.Err())
+	}
+}
+
+func TestContainer_WCOW_StorageStatistics(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	// write enough data that the write is flushed to the scratch disk, rather than
+	// only to the file cache
+	const ioCmd = `powershell -NoProfile -Command "$b = New-Object byte[] 1048576; ` +
+		`$f = [IO.File]::Open('C:\io.bin', 'Create', 'ReadWrite', 'None'); ` +
+		`for ($i = 0; $i -lt 32; $i++) { $f.Write($b, 0, $b.Length) }; $f.Flush($true); $f.Close()"`
+
+	runIO := func(t *testing.T, c cow.Container, cID string) {
+		t.Helper()
+		ps := testoci.CreateWindowsSpec(ctx, t, cID,
+			testoci.DefaultWindowsSpecOpts(cID,
+				ctrdoci.WithProcessCommandLine(ioCmd),
+			)...).Process
+		p := testcmd.Create(ctx, t, c, ps, nil)
+		testcmd.Start(ctx, t, p)
+		testcmd.WaitExitCode(ctx, t, p, 0)
+	}
+
+	t.Run("WCOW Process", func(t *testing.T) {
+		cID := testName(t, "container")
+		scratch := testlayers.WCOWScratchDir(ctx, t, "")
+		spec := testoci.CreateWindowsSpec(ctx, t, cID,
+			testoci.DefaultWindowsSpecOpts(cID,
+				ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+				testoci.WithWindowsLayerFolders(append(windowsImageLayers(ctx, t), scratch)),
+			)...)
+
+		c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+		t.Cleanup(cleanup)
+		init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+		t.Cleanup(func() {
+			testcmd.Kill(ctx, t, init)
+			testcmd.Wait(ctx, t, init)
+			testcontainer.Kill(ctx, t, c)
+			testcontainer.Wait(ctx, t, c)
+		})
+
+		system, ok := c.(*hcs.System)
+		if !ok {
+			t.Fatalf("expected type *hcs.System; got %T", c)
+		}
+		storage := func() *hcsschema.StorageStats {
+			t.Helper()
+			s, err := system.Statistics(ctx)
+			if err != nil {
+				t.Fatalf("failed to query statistics: %v", err)
+			}
+			if s.Storage == nil {
+				t.Skip("storage statistics are not reported on this build")
+			}
+			return s.Storage
+		}
+
+		before := storage()
+		runIO(t, c, cID)
+		after := storage()
+
+		if after.WriteSizeBytes <= before.WriteSizeBytes || after.WriteCountNormalized <= before.WriteCountNormalized {
+			t.Fatalf("expected container write counters to increase: before %+v, after %+v", before, after)
+		}
+	}) // WCOW Process
+
+	t.Run("WCOW Hyper-V", func(t *testing.T) {
+		requireFeatures(t, featureUVM)
+
+		vm := testuvm.CreateAndStart(ctx, t, defaultWCOWOptions(ctx, t))
+
+		cID := vm.ID() + "-container"
+		scratch := testlayers.WCOWScratchDir(ctx, t, "")
+		spec := testoci.CreateWindowsSpec(ctx, t, cID,
+			testoci.DefaultWindowsSpecOpts(cID,
+				ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+				testoci.WithWindowsLayerFolders(append(windowsImageLayers(ctx, t), scratch)),
+			)...)
+
+		c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+		t.Cleanup(cleanup)
+		init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+		t.Cleanup(func() {
+			testcmd.Kill(ctx, t, init)
+			testcmd.Wait(ctx, t, init)
+			testcontainer.Kill(ctx, t, c)
+			testcontainer.Wait(ctx, t, c)
+		})
+
+		storage := func() *stats.VirtualMachineStorageStatistics {
+			t.Helper()
+			s, err := vm.Stats(ctx)
+			if err != nil {
+				t.Fatalf("failed to query uVM statistics: %v", err)
+			}
+			if s.Storage == nil {
+				t.Skip("uVM storage statistics are not reported on this build")
+			}
+			return s.Storage
+		}
+
+		before := storage()
+		runIO(t, c, cID)
+		after := storage()
+
+		if after.WriteSizeBytes <= before.WriteSizeBytes || after.WriteCountNormalized <= before.WriteCountNormalized {
+			t.Fatalf("expected uVM write counters to increase: before %+v, after %+v", before, after)
+		}
+	}) // WCOW Hyper-V
+}
+
+func TestContainer_WCOW_ImageVolumes(t *testing.T) {
+	requireFeatures(t, featureContainer, featureWCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	wcow, err := wcowImagePathsOnce()
+	if err != nil {
+		t.Fatalf("could not get Windows Nano Server image: %v", err)
+	}
+	// the image declares a volume that none of its layers create, so it only exists in the
+	// container if it is backed by a mount
+	const volume = `C:\data`
+	img := &testlayers.LazyImageLayers{
+		Image:        wcow.nanoserver.Image,
+		Platform:     testimages.PlatformWindows,
+		ExtraVolumes: []string{volume},
+	}
+	t.Cleanup(func() {
+		if err := img.Close(ctx); err != nil {
+			t.Errorf("could not remove image layers: %v", err)
+		}
+	})
+	ls := img.Layers(ctx, t)
+	// the shim does not read the image config, so its volumes are passed by the caller
+	imageVolumes := strings.Join(img.Volumes(ctx, t), ",")
+	if imageVolumes != volume {
+		t.Fatalf("got image volumes %q, expected %q", imageVolumes, volume)
+	}
+
+	createSpec := func(t *testing.T, cID, mode string) *specs.Spec {
+		t.Helper()
+		scratch := testlayers.WCOWScratchDir(ctx, t, "")
+		return testoci.CreateWindowsSpec(ctx, t, cID,
+			testoci.DefaultWindowsSpecOpts(cID,
+				ctrdoci.WithProcessCommandLine(testoci.PingSelfCmd),
+				ctrdoci.WithAnnotations(map[string]string{
+					annotations.WCOWImageVolumes:    imageVolumes,
+					annotations.WCOWImageVolumeMode: mode,
+				}),
+				testoci.WithWindowsLayerFolders(append(ls, scratch)),
+			)...)
+	}
+
+	// run creates and starts a container with `spec` and writes to the volume, returning
+	// a function to stop it and release its resources
+	run := func(t *testing.T, cID string, spec *specs.Spec, exitCode int) func() {
+		t.Helper()
+		c, _, cleanup := testcontainer.Create(ctx, t, nil, spec, cID, hcsOwner)
+		init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+		stop := func() {
+			testcmd.Kill(ctx, t, init)
+			testcmd.Wait(ctx, t, init)
+			testcontainer.Kill(ctx, t, c)
+			testcontainer.Wait(ctx, t, c)
+			cleanup()
+		}
+
+		ps := testoci.CreateWindowsSpec(ctx, t, cID,
+			testoci.DefaultWindowsSpecOpts(cID,
+				ctrdoci.WithProcessCommandLine(`cmd /c echo volume> `+volume+`\file.txt`),
+			)...).Process
+		p := testcmd.Create(ctx, t, c, ps, nil)
+		testcmd.Start(ctx, t, p)
+		testcmd.WaitExitCode(ctx, t, p, exitCode)
+		return stop
+	}
+
+	t.Run("ignore", func(t *testing.T) {
+		cID := testName(t, "container")
+		stop := run(t, cID, createSpec(t, cID, "ignore"), 1)
+		stop()
+	})
+
+	t.Run("emptydir", func(t *testing.T) {
+		// the directories backing the volumes are created in the temp directory
+		tmp := t.TempDir()
+		t.Setenv("TMP", tmp)
+
+		cID := testName(t, "container")
+		stop := run(t, cID, createSpec(t, cID, "emptydir"), 0)
+
+		entries, err := os.ReadDir(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("expected one directory backing the volume in %s, got %d", tmp, len(entries))
+		}
+		b, err := os.ReadFile(filepath.Join(tmp, entries[0].Name(), "file.txt"))
+		if err != nil {
+			t.Fatalf("expected the write to the volume to be on the host: %v", err)
+		}
+		if s := strings.TrimSpace(string(b)); s != "volume" {
+			t.Fatalf("got volume file contents %q, expected %q", s, "volume")
+		}
+
+		stop()
+		if entries, err = os.ReadDir(tmp); err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("expected the directory backing the volume to be removed, got %d entries in %s", len(entries), tmp)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		cID := testName(t, "container")
+		spec := createSpec(t, cID, "error")
+		wcowLayers, err := layers.ParseWCOWLayers(nil, spec.Windows.LayerFolders)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, r, err := hcsoci.CreateContainer(ctx, &hcsoci.CreateOptions{
+			ID:         cID,
+			Owner:      hcsOwner,
+			Spec:       spec,
+			WCOWLayers: wcowLayers,
+		})
+		if err == nil {
+			_ = resources.ReleaseResources(ctx, r, nil, true)
+			_ = c.Close()
+			t.Fatal("expected creating the container to fail")
+		}
+		if !errors.Is(err, oci.ErrInvalidImageVolume) || !strings.Contains(err.Error(), volume) {
+			t.Fatalf("expected error %v listing %s, got: %v", oci.ErrInvalidImageVolume, volume, err)
+		}
+	})
+}
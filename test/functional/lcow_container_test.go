@@ -5,16 +5,31 @@ package functional
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/bits"
 	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/containerd/containerd/v2/core/containers"
 	ctrdoci "github.com/containerd/containerd/v2/pkg/oci"
 	"github.com/opencontainers/runtime-spec/specs-go"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcs"
+	"github.com/Microsoft/hcsshim/internal/guestpath"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/osversion"
 	"github.com/Microsoft/hcsshim/pkg/annotations"
 
+	testutilities "github.com/Microsoft/hcsshim/test/internal"
 	testcmd "github.com/Microsoft/hcsshim/test/internal/cmd"
 	testcontainer "github.com/Microsoft/hcsshim/test/internal/container"
 	testlayers "github.com/Microsoft/hcsshim/test/internal/layers"
@@ -163,3 +178,1016 @@ another new line, with more letters`
 		logIO.TestOutput(t, want, nil)
 	})
 }
+
+func TestLCOW_RawBlockDevice(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW, featureSCSI)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	const (
+		blockDevPath = "/dev/rawblock"
+		want         = "raw block data"
+	)
+
+	opts := defaultLCOWOptions(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	cID := testName(t, "container")
+
+	disk := filepath.Join(testutilities.CreateLCOWBlankRWLayer(ctx, t), "sandbox.vhdx")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			ctrdoci.WithMounts([]specs.Mount{
+				{
+					Type:        "virtual-disk",
+					Source:      disk,
+					Destination: guestpath.BlockDevMountPrefix + blockDevPath,
+				},
+			}),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	// write to the raw device, bypassing any filesystem, then read it back
+	ps := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithDefaultPathEnv,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", fmt.Sprintf(
+				"printf '%[1]s' | dd of=%[2]s bs=512 count=1 conv=fsync 2>/dev/null && dd if=%[2]s bs=%[3]d count=1 2>/dev/null",
+				want, blockDevPath, len(want))),
+		)...,
+	).Process
+	execIO := testcmd.NewBufferedIO()
+	execCmd := testcmd.Create(ctx, t, c, ps, execIO)
+	testcmd.Start(ctx, t, execCmd)
+	testcmd.WaitExitCode(ctx, t, execCmd, 0)
+
+	execIO.TestOutput(t, want, nil)
+}
+
+func TestLCOW_ExecExecutableNotFound(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		ps := &specs.Process{
+			Args: []string{"does-not-exist"},
+			Env:  []string{"PATH=/usr/bin:/bin"},
+			Cwd:  "/",
+		}
+		// the guest only looks for the executable of an exec with fallback shells
+		execCmd := testcmd.Create(ctx, t, c, ps, nil)
+		execCmd.ShellCandidates = []string{"/bin/sh"}
+		err := execCmd.Start()
+		if !errors.Is(err, gcs.ErrExecutableNotFound) {
+			t.Fatalf("expected %v, got: %v", gcs.ErrExecutableNotFound, err)
+		}
+		if !strings.Contains(err.Error(), "/usr/bin/does-not-exist, /bin/does-not-exist") {
+			t.Fatalf("expected error to list the paths tried, got: %v", err)
+		}
+	})
+
+	t.Run("fallback shell", func(t *testing.T) {
+		// the image only has busybox's /bin/sh
+		ps := &specs.Process{
+			Args:     []string{"bash", "-c", "exit 7"},
+			Env:      []string{"PATH=/usr/bin:/bin"},
+			Cwd:      "/",
+			Terminal: true,
+		}
+		execCmd := testcmd.Create(ctx, t, c, ps, testcmd.NewBufferedIO())
+		execCmd.ShellCandidates = []string{"/bin/bash", "/bin/sh"}
+		testcmd.Start(ctx, t, execCmd)
+		testcmd.WaitExitCode(ctx, t, execCmd, 7)
+	})
+}
+
+func TestLCOW_ExecCreateWorkingDirectory(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	const cwd = "/does/not/exist"
+	ps := &specs.Process{
+		Args: []string{"/bin/sh", "-c", "pwd"},
+		Env:  []string{"PATH=/usr/bin:/bin"},
+		Cwd:  cwd,
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		if err := testcmd.Create(ctx, t, c, ps, nil).Start(); err == nil {
+			t.Fatal("expected exec with a missing working directory to fail")
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		execIO := testcmd.NewBufferedIO()
+		execCmd := testcmd.Create(ctx, t, c, ps, execIO)
+		execCmd.WorkingDirectoryCreateIfMissing = true
+		testcmd.Start(ctx, t, execCmd)
+		testcmd.WaitExitCode(ctx, t, execCmd, 0)
+		execIO.TestOutput(t, cwd, nil)
+	})
+}
+
+func TestLCOW_ExecInContainerNamespaces(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	// The GCS does not name the network namespaces of containers, so
+	// `ip netns identify` has no name to report for them: compare the
+	// namespace links instead.
+	netns := func(tb testing.TB, host cow.ProcessHost, ns *guestresource.LCOWExecNamespaceConfig, pid string) string {
+		tb.Helper()
+		ps := &specs.Process{
+			Args: []string{"readlink", "/proc/" + pid + "/ns/net"},
+			Env:  []string{"PATH=/usr/sbin:/usr/bin:/sbin:/bin"},
+			Cwd:  "/",
+		}
+		execIO := testcmd.NewBufferedIO()
+		execCmd := testcmd.Create(ctx, tb, host, ps, execIO)
+		execCmd.Namespace = ns
+		testcmd.Start(ctx, tb, execCmd)
+		testcmd.WaitExitCode(ctx, tb, execCmd, 0)
+		out, err := execIO.Output()
+		if err != nil {
+			tb.Fatalf("failed to read network namespace: %v", err)
+		}
+		return strings.TrimSpace(out)
+	}
+
+	// the namespace of the container's init process, as seen from the uVM
+	want := netns(t, vm, nil, strconv.Itoa(init.Process.Pid()))
+	if uvmNS := netns(t, vm, nil, "self"); uvmNS == want {
+		t.Fatalf("container shares the uVM's network namespace %s", uvmNS)
+	}
+	if got := netns(t, vm, &guestresource.LCOWExecNamespaceConfig{ContainerID: cID}, "self"); got != want {
+		t.Fatalf("process in the uVM joined network namespace %s, expected the container's %s", got, want)
+	}
+}
+
+func TestLCOW_SeccompProfile(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+	if !vm.SeccompProfilesSupported() {
+		t.Skip("guest does not support seccomp profiles")
+	}
+
+	// deny creating directories, which is allowed by default
+	eperm := uint(1)
+	withSeccomp := func(_ context.Context, _ ctrdoci.Client, _ *containers.Container, s *specs.Spec) error {
+		s.Linux.Seccomp = &specs.LinuxSeccomp{
+			DefaultAction: specs.ActAllow,
+			Syscalls: []specs.LinuxSyscall{
+				{
+					Names:    []string{"mkdir", "mkdirat"},
+					Action:   specs.ActErrno,
+					ErrnoRet: &eperm,
+				},
+			},
+		}
+		return nil
+	}
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			withSeccomp,
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	ps := &specs.Process{
+		Args: []string{"/bin/sh", "-c", "mkdir /seccomp-test"},
+		Env:  []string{"PATH=/usr/bin:/bin"},
+		Cwd:  "/",
+	}
+	execIO := testcmd.NewBufferedIO()
+	execCmd := testcmd.Create(ctx, t, c, ps, execIO)
+	testcmd.Start(ctx, t, execCmd)
+	testcmd.WaitExitCode(ctx, t, execCmd, 1)
+
+	if _, err := execIO.Output(); err == nil || !strings.Contains(err.Error(), "Operation not permitted") {
+		t.Fatalf("expected mkdir to fail with EPERM, got stderr: %v", err)
+	}
+}
+
+func Test_CreateContainer_LCOW_DeviceCgroup_Allowlist(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	cID := testName(t, "container")
+
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			// only allow /dev/null (1:3) and /dev/zero (1:5)
+			func(_ context.Context, _ ctrdoci.Client, _ *containers.Container, s *specs.Spec) error {
+				var major, nullMinor, zeroMinor int64 = 1, 3, 5
+				s.Linux.Resources.Devices = []specs.LinuxDeviceCgroup{
+					{Allow: false, Access: "rwm"},
+					{Allow: true, Type: "c", Major: &major, Minor: &nullMinor, Access: "rwm"},
+					{Allow: true, Type: "c", Major: &major, Minor: &zeroMinor, Access: "rwm"},
+				}
+				return nil
+			},
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	exec := func(tb testing.TB, script string, exitCode int) *testcmd.BufferedIO {
+		tb.Helper()
+		ps := testoci.CreateLinuxSpec(ctx, tb, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithDefaultPathEnv,
+				ctrdoci.WithProcessArgs("/bin/sh", "-c", script),
+			)...,
+		).Process
+		execIO := testcmd.NewBufferedIO()
+		execCmd := testcmd.Create(ctx, tb, c, ps, execIO)
+		testcmd.Start(ctx, tb, execCmd)
+		testcmd.WaitExitCode(ctx, tb, execCmd, exitCode)
+		return execIO
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		// Creating device nodes is allowed (runc always allows mknod), but /dev/sda
+		// is not in the allowlist, so opening it must fail.
+		// The device cgroup denies access with EPERM.
+		execIO := exec(t, "mknod /dev/sda b 8 0 && head -c 1 /dev/sda 2>&1 >/dev/null", 1)
+		execIO.TestStdOutContains(t, []string{"operation not permitted"}, nil)
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		execIO := exec(t, "echo hello > /dev/null && head -c 4 /dev/zero | wc -c", 0)
+		execIO.TestOutput(t, "4", nil)
+	})
+}
+
+func Test_CreateContainer_LCOW_TmpfsWithSizeLimit(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+
+	const tmpfsPath = "/mytmpfs"
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			ctrdoci.WithAnnotations(map[string]string{
+				annotations.ContainerTmpfsPath:      tmpfsPath,
+				annotations.ContainerTmpfsSizeBytes: strconv.Itoa(64 * 1024 * 1024),
+			}),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	exec := func(tb testing.TB, script string, exitCode int) *testcmd.BufferedIO {
+		tb.Helper()
+		ps := testoci.CreateLinuxSpec(ctx, tb, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithDefaultPathEnv,
+				ctrdoci.WithProcessArgs("/bin/sh", "-c", script),
+			)...,
+		).Process
+		execIO := testcmd.NewBufferedIO()
+		execCmd := testcmd.Create(ctx, tb, c, ps, execIO)
+		testcmd.Start(ctx, tb, execCmd)
+		testcmd.WaitExitCode(ctx, tb, execCmd, exitCode)
+		return execIO
+	}
+
+	// 50 MiB fits in the 64 MiB tmpfs
+	exec(t, fmt.Sprintf("dd if=/dev/zero of=%s/first bs=1M count=50", tmpfsPath), 0)
+
+	// but another 20 MiB does not
+	execIO := exec(t, fmt.Sprintf("dd if=/dev/zero of=%s/second bs=1M count=20 2>&1", tmpfsPath), 1)
+	execIO.TestStdOutContains(t, []string{"No space left on device"}, nil)
+}
+
+func Test_CreateContainer_LCOW_LargeLayerCount(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW, featureVPMEM, featureSCSI)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	// more layers than fit in the uVM's vPMEM devices, so the rest fall back to SCSI
+	const (
+		layerCount = 150
+		vpmemCount = 16
+	)
+	ls := linuxImageLayers(ctx, t)
+	want := make([]string, 0, layerCount)
+	for i := 0; i < layerCount; i++ {
+		name := fmt.Sprintf("layer-%03d", i)
+		want = append(want, name)
+		ls = append(ls, testlayers.LCOWLayerFromFiles(ctx, t, map[string]string{
+			path.Join("layers", name): strconv.Itoa(i),
+		}))
+	}
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	opts.VPMemDeviceCount = vpmemCount
+	opts.VPMemNoMultiMapping = true
+	opts.SCSIControllerCount = uvm.MaxSCSIControllers
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	exec := func(tb testing.TB, args ...string) string {
+		tb.Helper()
+		ps := testoci.CreateLinuxSpec(ctx, tb, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithDefaultPathEnv,
+				ctrdoci.WithProcessArgs(args...),
+			)...,
+		).Process
+		execIO := testcmd.NewBufferedIO()
+		execCmd := testcmd.Create(ctx, tb, c, ps, execIO)
+		testcmd.Start(ctx, tb, execCmd)
+		testcmd.WaitExitCode(ctx, tb, execCmd, 0)
+		stdout, err := execIO.Output()
+		if err != nil {
+			tb.Fatalf("exec %v: %v", args, err)
+		}
+		return stdout
+	}
+
+	// the files of all the layers are merged in the same directory
+	if got := strings.Fields(exec(t, "ls", "/layers")); !slices.Equal(got, want) {
+		t.Fatalf("got files %v, expected %v", got, want)
+	}
+	// from both the bottom and the top of the layer stack
+	for _, i := range []int{0, vpmemCount, layerCount - 1} {
+		if got := strings.TrimSpace(exec(t, "cat", path.Join("/layers", want[i]))); got != strconv.Itoa(i) {
+			t.Fatalf("got contents %q of %s, expected %q", got, want[i], strconv.Itoa(i))
+		}
+	}
+}
+
+func TestLCOW_CheckpointRestore(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	// checkpointing relies on CRIU being present in the uVM
+	criu := testcmd.Create(ctx, t, vm, &specs.Process{Args: []string{"/bin/sh", "-c", "command -v criu"}}, nil)
+	testcmd.Start(ctx, t, criu)
+	if testcmd.Wait(ctx, t, criu) != 0 {
+		t.Skip("criu is not present in the uVM")
+	}
+
+	cID := testName(t, "container")
+
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			// count up once a second, so the restored process state can be compared to
+			// the checkpointed one
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", "i=0; while true; do echo $i > /tmp/counter; i=$((i+1)); sleep 1; done"),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+	testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	gc, ok := c.(*gcs.Container)
+	if !ok {
+		t.Fatalf("expected container of type %T, got %T", gc, c)
+	}
+
+	counter := func(tb testing.TB) int {
+		tb.Helper()
+		ps := testoci.CreateLinuxSpec(ctx, tb, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithDefaultPathEnv,
+				ctrdoci.WithProcessArgs("/bin/sh", "-c", "cat /tmp/counter"),
+			)...,
+		).Process
+		execIO := testcmd.NewBufferedIO()
+		execCmd := testcmd.Create(ctx, tb, c, ps, execIO)
+		testcmd.Start(ctx, tb, execCmd)
+		testcmd.WaitExitCode(ctx, tb, execCmd, 0)
+
+		out, err := execIO.Output()
+		if err != nil {
+			tb.Fatalf("failed to read counter: %v", err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(out))
+		if err != nil {
+			tb.Fatalf("failed to parse counter %q: %v", out, err)
+		}
+		return n
+	}
+
+	time.Sleep(3 * time.Second)
+	before := counter(t)
+
+	dir := path.Join("/run/checkpoints", cID)
+	if err := gc.Checkpoint(ctx, dir, nil); err != nil {
+		t.Fatalf("failed to checkpoint container: %v", err)
+	}
+	// the checkpointed container is stopped
+	testcontainer.Wait(ctx, t, c)
+
+	p, err := gc.Restore(ctx, dir)
+	if err != nil {
+		t.Fatalf("failed to restore container: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	time.Sleep(3 * time.Second)
+	if after := counter(t); after <= before {
+		t.Fatalf("expected restored counter to continue from %d, got %d", before, after)
+	}
+
+	// the restored init process is tracked, and can be killed and waited on
+	if _, err := p.Kill(ctx); err != nil {
+		t.Fatalf("failed to kill restored process: %v", err)
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("failed to wait for restored process: %v", err)
+	}
+	if code, err := p.ExitCode(); err != nil || code == 0 {
+		t.Fatalf("expected restored process to be killed, got exit code %d: %v", code, err)
+	}
+}
+
+func TestLCOW_TPMDevice(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.V21H2Server)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	opts.EnableTPM = true
+	opts.TPMStateFilePath = filepath.Join(t.TempDir(), "tpm.vmgs")
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	for _, tc := range []struct {
+		name     string
+		tpm      bool
+		exitCode int
+	}{
+		{name: "TPM", tpm: true, exitCode: 0},
+		{name: "NoTPM", tpm: false, exitCode: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cID := testName(t, "container")
+			scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+			spec := testoci.CreateLinuxSpec(ctx, t, cID,
+				testoci.DefaultLinuxSpecOpts(cID,
+					ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+					ctrdoci.WithAnnotations(map[string]string{
+						annotations.LCOWTPMDevice: strconv.FormatBool(tc.tpm),
+					}),
+					testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+			c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+			t.Cleanup(cleanup)
+			init := testcontainer.Start(ctx, t, c, nil)
+			t.Cleanup(func() {
+				testcmd.Kill(ctx, t, init)
+				testcmd.Wait(ctx, t, init)
+				testcontainer.Kill(ctx, t, c)
+				testcontainer.Wait(ctx, t, c)
+			})
+
+			ps := testoci.CreateLinuxSpec(ctx, t, cID,
+				testoci.DefaultLinuxSpecOpts(cID,
+					ctrdoci.WithDefaultPathEnv,
+					ctrdoci.WithProcessArgs("/bin/sh", "-c", "test -c /dev/tpm0"),
+				)...,
+			).Process
+			execCmd := testcmd.Create(ctx, t, c, ps, testcmd.NewBufferedIO())
+			testcmd.Start(ctx, t, execCmd)
+			testcmd.WaitExitCode(ctx, t, execCmd, tc.exitCode)
+		})
+	}
+}
+
+func TestLCOW_PinnedCPUs(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	opts.ProcessorCount = 2
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	cpusAllowed := func(t *testing.T) string {
+		t.Helper()
+		ps := testoci.CreateLinuxSpec(ctx, t, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithDefaultPathEnv,
+				ctrdoci.WithProcessArgs("/bin/sh", "-c", "grep Cpus_allowed_list /proc/self/status"),
+			)...,
+		).Process
+		io := testcmd.NewBufferedIO()
+		p := testcmd.Create(ctx, t, c, ps, io)
+		testcmd.Start(ctx, t, p)
+		testcmd.WaitExitCode(ctx, t, p, 0)
+		out, _ := io.Output()
+		return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(out), "Cpus_allowed_list:"))
+	}
+	pin := func(cpus string) error {
+		return c.Modify(ctx, guestrequest.ModificationRequest{
+			ResourceType: guestresource.ResourceTypeContainerConstraints,
+			RequestType:  guestrequest.RequestTypeUpdate,
+			Settings:     guestresource.LCOWContainerConstraints{PinnedCPUs: cpus},
+		})
+	}
+
+	if got := cpusAllowed(t); got != "0-1" {
+		t.Fatalf("expected container to run on CPUs 0-1 before pinning, got %q", got)
+	}
+
+	if err := pin("0"); err != nil {
+		t.Fatalf("failed to pin container to CPU 0: %v", err)
+	}
+	if got := cpusAllowed(t); got != "0" {
+		t.Fatalf("expected container to be pinned to CPU 0, got %q", got)
+	}
+
+	if err := pin("2"); err == nil {
+		t.Fatal("expected pinning to a CPU the uVM does not have to fail")
+	}
+}
+
+func TestLCOW_RDTClass(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	runInUVM := func(t *testing.T, cmd string) (string, int) {
+		t.Helper()
+		io := testcmd.NewBufferedIO()
+		p := testcmd.Create(ctx, t, vm, &specs.Process{Args: []string{"/bin/sh", "-c", cmd}}, io)
+		testcmd.Start(ctx, t, p)
+		code := testcmd.Wait(ctx, t, p)
+		out, _ := io.Output()
+		return strings.TrimSpace(out), code
+	}
+
+	if _, code := runInUVM(t, "grep -q resctrl /proc/filesystems"); code != 0 {
+		t.Skip("uVM does not support Intel RDT")
+	}
+
+	cID := testName(t, "container")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			ctrdoci.WithAnnotations(map[string]string{annotations.ContainerRDTClass: annotations.RDTClassCATLow}),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	classDir := "/sys/fs/resctrl/" + annotations.RDTClassCATLow
+
+	// a low class gets a quarter of the cache ways, rounded up, and at least the
+	// minimum number of ways
+	if out, code := runInUVM(t, "cat /sys/fs/resctrl/info/L3/cbm_mask /sys/fs/resctrl/info/L3/min_cbm_bits"); code == 0 {
+		fields := strings.Fields(out)
+		if len(fields) != 2 {
+			t.Fatalf("unexpected L3 info: %q", out)
+		}
+		full, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		minBits, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ways := uint64(bits.OnesCount64(full))
+		n := max((ways+3)/4, minBits, 1)
+		want := full
+		if n < ways {
+			want = 1<<n - 1
+		}
+
+		schemata, code := runInUVM(t, "cat "+classDir+"/schemata")
+		if code != 0 {
+			t.Fatalf("failed to read schemata of RDT class %s: %s", annotations.RDTClassCATLow, schemata)
+		}
+		var l3 string
+		for _, line := range strings.Split(schemata, "\n") {
+			if r, domains, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && r == "L3" {
+				l3 = domains
+			}
+		}
+		if l3 == "" {
+			t.Fatalf("expected an L3 schema for RDT class %s, got: %s", annotations.RDTClassCATLow, schemata)
+		}
+		for _, d := range strings.Split(l3, ";") {
+			_, mask, _ := strings.Cut(d, "=")
+			if got, err := strconv.ParseUint(mask, 16, 64); err != nil || got != want {
+				t.Fatalf("expected L3 mask %x in RDT class %s, got schemata: %s", want, annotations.RDTClassCATLow, schemata)
+			}
+		}
+	}
+
+	// exec'd processes are assigned to the class before they run
+	io := testcmd.NewBufferedIO()
+	execCmd := testcmd.Create(ctx, t, c, &specs.Process{
+		Args: []string{"/bin/sh", "-c", "sleep 2"},
+		Cwd:  "/",
+	}, io)
+	testcmd.Start(ctx, t, execCmd)
+	tasks, code := runInUVM(t, "cat "+classDir+"/tasks")
+	testcmd.WaitExitCode(ctx, t, execCmd, 0)
+	if code != 0 {
+		t.Fatalf("failed to read tasks of RDT class %s: %s", annotations.RDTClassCATLow, tasks)
+	}
+	for name, pid := range map[string]int{"init": init.Process.Pid(), "exec": execCmd.Process.Pid()} {
+		if !slices.Contains(strings.Fields(tasks), strconv.Itoa(pid)) {
+			t.Fatalf("expected %s process %d to be in RDT class %s, got tasks: %s", name, pid, annotations.RDTClassCATLow, tasks)
+		}
+	}
+}
+
+func TestLCOW_IOWeight(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	cache := testlayers.CacheFile(ctx, t, "")
+
+	opts := defaultLCOWOptions(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, opts)
+
+	io := testcmd.NewBufferedIO()
+	p := testcmd.Create(ctx, t, vm, &specs.Process{Args: []string{"/bin/sh", "-c", "grep -qw io /sys/fs/cgroup/cgroup.controllers"}}, io)
+	testcmd.Start(ctx, t, p)
+	if code := testcmd.Wait(ctx, t, p); code != 0 {
+		t.Skip("uVM does not have the cgroup v2 io controller")
+	}
+
+	// the first container has its weight set with the annotation when it is
+	// created, and the second when it is updated
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", cache)
+	weights := []uint16{1000, 100}
+	for i, w := range weights {
+		cID := testName(t, "container", strconv.Itoa(i))
+		a := map[string]string{}
+		if i == 0 {
+			a[annotations.ContainerStorageIOWeight] = strconv.Itoa(int(w))
+		}
+		spec := testoci.CreateLinuxSpec(ctx, t, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+				ctrdoci.WithAnnotations(a),
+				testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+		c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+		t.Cleanup(cleanup)
+
+		init := testcontainer.Start(ctx, t, c, nil)
+		t.Cleanup(func() {
+			testcmd.Kill(ctx, t, init)
+			testcmd.Wait(ctx, t, init)
+			testcontainer.Kill(ctx, t, c)
+			testcontainer.Wait(ctx, t, c)
+		})
+
+		if i != 0 {
+			if err := c.Modify(ctx, guestrequest.ModificationRequest{
+				ResourceType: guestresource.ResourceTypeContainerConstraints,
+				RequestType:  guestrequest.RequestTypeUpdate,
+				Settings:     guestresource.LCOWContainerConstraints{IOWeight: w},
+			}); err != nil {
+				t.Fatalf("failed to set I/O weight of container %s to %d: %v", cID, w, err)
+			}
+		}
+
+		// check the weight of the container's cgroup, rather than timing its
+		// I/O, which the scheduler only approximately shares by weight
+		io := testcmd.NewBufferedIO()
+		p := testcmd.Create(ctx, t, vm, &specs.Process{
+			Args: []string{"cat", "/sys/fs/cgroup/containers/" + cID + "/io.weight"},
+		}, io)
+		testcmd.Start(ctx, t, p)
+		testcmd.WaitExitCode(ctx, t, p, 0)
+		out, _ := io.Output()
+		if want := "default " + strconv.Itoa(int(w)); strings.TrimSpace(out) != want {
+			t.Fatalf("expected io.weight of container %s to be %q, got %q", cID, want, out)
+		}
+	}
+}
+
+func TestLCOW_NestedVirtualization(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	opts := defaultLCOWOptions(ctx, t)
+	opts.NestedVirtualization = true
+	vm, err := uvm.CreateLCOW(ctx, opts)
+	if errors.Is(err, uvm.ErrNestedVirtualizationNotSupported) {
+		t.Skipf("skipping: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("could not create LCOW UVM with nested virtualization: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := vm.CloseCtx(ctx); err != nil {
+			t.Errorf("could not close vm %q: %v", vm.ID(), err)
+		}
+	})
+	testuvm.Start(ctx, t, vm)
+
+	ls := linuxImageLayers(ctx, t)
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", "")
+
+	cID := testName(t, "container")
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	// use kvm-ok if the image has it, otherwise make the same check it does
+	// for the processor's virtualization extensions
+	ps := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithDefaultPathEnv,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c",
+				"if command -v kvm-ok >/dev/null; then kvm-ok; else grep -Eqw 'vmx|svm' /proc/cpuinfo; fi"),
+		)...,
+	).Process
+	p := testcmd.Create(ctx, t, c, ps, testcmd.NewBufferedIO())
+	testcmd.Start(ctx, t, p)
+	testcmd.WaitExitCode(ctx, t, p, 0)
+}
+
+func TestLCOW_ReadonlyRootfs(t *testing.T) {
+	requireFeatures(t, featureUVM, featureContainer, featureLCOW)
+	require.Build(t, osversion.RS5)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	ls := linuxImageLayers(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", "")
+
+	cID := testName(t, "container")
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(cID,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			ctrdoci.WithRootFSReadonly(),
+			ctrdoci.WithMounts([]specs.Mount{{
+				Destination: "/tmp",
+				Type:        "tmpfs",
+				Source:      "tmpfs",
+				Options:     []string{"nosuid", "nodev", "mode=1777"},
+			}}),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Cleanup(cleanup)
+
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	for _, tc := range []struct {
+		path string
+		ec   int
+	}{
+		{"/readonly", 1},
+		{"/tmp/writable", 0},
+	} {
+		ps := testoci.CreateLinuxSpec(ctx, t, cID,
+			testoci.DefaultLinuxSpecOpts(cID,
+				ctrdoci.WithDefaultPathEnv,
+				ctrdoci.WithProcessArgs("/bin/sh", "-c", "touch "+tc.path),
+			)...,
+		).Process
+		p := testcmd.Create(ctx, t, c, ps, testcmd.NewBufferedIO())
+		testcmd.Start(ctx, t, p)
+		testcmd.WaitExitCode(ctx, t, p, tc.ec)
+	}
+}
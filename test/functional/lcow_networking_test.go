@@ -5,13 +5,18 @@ package functional
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
 	ctrdoci "github.com/containerd/containerd/v2/pkg/oci"
 
 	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/osversion"
 
 	testcmd "github.com/Microsoft/hcsshim/test/internal/cmd"
@@ -172,6 +177,470 @@ func TestLCOW_IPv6_Assignment(t *testing.T) {
 	}
 }
 
+func TestLCOW_DHCPClasslessStaticRoutes(t *testing.T) {
+	requireFeatures(t, featureLCOW, featureUVM)
+	require.Build(t, osversion.RS5)
+
+	ns, err := newNetworkNamespace()
+	if err != nil {
+		t.Fatalf("namespace creation: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ns.Delete(); err != nil {
+			t.Errorf("namespace delete: %v", err)
+		}
+	})
+	t.Logf("created namespace %s", ns.Id)
+
+	// create network and endpoint without static IP configurations, so the guest
+	// acquires a DHCP lease for the adapter
+	ntwk, err := (&hcn.HostComputeNetwork{
+		Name: hcsOwner + "dhcpnetwork",
+		Type: hcn.NAT,
+		Ipams: []hcn.Ipam{
+			{
+				Type: "DHCP",
+			},
+		},
+		SchemaVersion: hcn.Version{Major: 2, Minor: 2},
+	}).Create()
+	if err != nil {
+		t.Fatalf("network creation: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ntwk.Delete(); err != nil {
+			t.Errorf("network delete: %v", err)
+		}
+	})
+	t.Logf("created network %s (%s)", ntwk.Name, ntwk.Id)
+
+	ep, err := (&hcn.HostComputeEndpoint{
+		Name:               ntwk.Name + "endpoint",
+		HostComputeNetwork: ntwk.Id,
+		SchemaVersion:      hcn.Version{Major: 2, Minor: 2},
+	}).Create()
+	if err != nil {
+		t.Fatalf("endpoint creation: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ep.Delete(); err != nil {
+			t.Errorf("endpoint delete: %v", err)
+		}
+	})
+	t.Logf("created endpoint %s", ep.Id)
+
+	if len(ep.IpConfigurations) != 0 {
+		t.Skipf("endpoint has static IP configurations %v, guest will not use DHCP", ep.IpConfigurations)
+	}
+
+	if err := ep.NamespaceAttach(ns.Id); err != nil {
+		t.Fatalf("network attachment: %v", err)
+	}
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+	ls := linuxImageLayers(ctx, t)
+	opts := defaultLCOWOptions(ctx, t)
+	// route 10.99.0.0/16 on-link, so the route does not depend on the lease's router
+	opts.ClasslessStaticRoutes = []byte{16, 10, 99, 0, 0, 0, 0}
+	vm := testuvm.CreateAndStartLCOWFromOpts(ctx, t, opts)
+
+	if err := vm.CreateAndAssignNetworkSetup(ctx, "", ""); err != nil {
+		t.Fatalf("setting up network: %v", err)
+	}
+	if err := vm.ConfigureNetworking(ctx, ns.Id); err != nil {
+		t.Fatalf("adding network to vm: %v", err)
+	}
+
+	cID := strings.ReplaceAll(t.Name(), "/", "")
+	scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", "")
+	spec := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(ns.Id,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+			ctrdoci.WithWindowsNetworkNamespace(ns.Id),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Logf("created container %s", cID)
+	t.Cleanup(cleanup)
+	init := testcontainer.Start(ctx, t, c, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	ps := testoci.CreateLinuxSpec(ctx, t, cID,
+		testoci.DefaultLinuxSpecOpts(ns.Id,
+			ctrdoci.WithDefaultPathEnv,
+			ctrdoci.WithProcessArgs("/bin/sh", "-c", `ip -4 route show dev eth0`),
+		)...,
+	).Process
+	io := testcmd.NewBufferedIO()
+	p := testcmd.Create(ctx, t, c, ps, io)
+	testcmd.Start(ctx, t, p)
+
+	e := testcmd.Wait(ctx, t, p)
+	out, err := io.Output()
+	t.Logf("cmd output:\n%s", out)
+	if e != 0 || err != nil {
+		t.Fatalf("exit code %d and error %v", e, err)
+	}
+
+	if !strings.Contains(out, "10.99.0.0/16") {
+		t.Errorf("missing static classless route to 10.99.0.0/16")
+	}
+}
+
+func TestLCOW_NetworkMTU(t *testing.T) {
+	requireFeatures(t, featureLCOW, featureUVM)
+	require.Build(t, osversion.RS5)
+
+	encapOverhead, err := json.Marshal(hcn.EncapOverheadEndpointPolicySetting{Overhead: 50})
+	if err != nil {
+		t.Fatalf("marshal encap overhead policy: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		policies []hcn.EndpointPolicy
+		mtu      uint16
+		expected uint16
+	}{
+		{
+			name: "EncapOverhead",
+			policies: []hcn.EndpointPolicy{
+				{
+					Type:     hcn.EncapOverhead,
+					Settings: encapOverhead,
+				},
+			},
+			expected: 1450,
+		},
+		{
+			name: "Override",
+			policies: []hcn.EndpointPolicy{
+				{
+					Type:     hcn.EncapOverhead,
+					Settings: encapOverhead,
+				},
+			},
+			mtu:      1400,
+			expected: 1400,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ns, err := newNetworkNamespace()
+			if err != nil {
+				t.Fatalf("namespace creation: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := ns.Delete(); err != nil {
+					t.Errorf("namespace delete: %v", err)
+				}
+			})
+			t.Logf("created namespace %s", ns.Id)
+
+			ntwk, err := (&hcn.HostComputeNetwork{
+				Name: hcsOwner + "mtunetwork",
+				Type: hcn.NAT,
+				Ipams: []hcn.Ipam{
+					{
+						Type: "Static",
+						Subnets: []hcn.Subnet{
+							{
+								IpAddressPrefix: "192.168.100.0/24",
+								Routes: []hcn.Route{
+									{
+										NextHop:           "192.168.100.1",
+										DestinationPrefix: "0.0.0.0/0",
+									},
+								},
+							},
+						},
+					},
+				},
+				SchemaVersion: hcn.Version{Major: 2, Minor: 2},
+			}).Create()
+			if err != nil {
+				t.Fatalf("network creation: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := ntwk.Delete(); err != nil {
+					t.Errorf("network delete: %v", err)
+				}
+			})
+			t.Logf("created network %s (%s)", ntwk.Name, ntwk.Id)
+
+			ep, err := (&hcn.HostComputeEndpoint{
+				Name:               ntwk.Name + "endpoint",
+				HostComputeNetwork: ntwk.Id,
+				Policies:           tc.policies,
+				SchemaVersion:      hcn.Version{Major: 2, Minor: 2},
+			}).Create()
+			if err != nil {
+				t.Fatalf("endpoint creation: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := ep.Delete(); err != nil {
+					t.Errorf("endpoint delete: %v", err)
+				}
+			})
+			t.Logf("created endpoint %s", ep.Id)
+
+			if err := ep.NamespaceAttach(ns.Id); err != nil {
+				t.Fatalf("network attachment: %v", err)
+			}
+
+			ctx := util.Context(namespacedContext(context.Background()), t)
+			ls := linuxImageLayers(ctx, t)
+			opts := defaultLCOWOptions(ctx, t)
+			opts.NetworkMTU = tc.mtu
+			vm := testuvm.CreateAndStartLCOWFromOpts(ctx, t, opts)
+
+			if err := vm.CreateAndAssignNetworkSetup(ctx, "", ""); err != nil {
+				t.Fatalf("setting up network: %v", err)
+			}
+			if err := vm.ConfigureNetworking(ctx, ns.Id); err != nil {
+				t.Fatalf("adding network to vm: %v", err)
+			}
+
+			cID := strings.ReplaceAll(t.Name(), "/", "")
+			scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", "")
+			spec := testoci.CreateLinuxSpec(ctx, t, cID,
+				testoci.DefaultLinuxSpecOpts(ns.Id,
+					ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+					ctrdoci.WithWindowsNetworkNamespace(ns.Id),
+					testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+			c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+			t.Logf("created container %s", cID)
+			t.Cleanup(cleanup)
+			init := testcontainer.Start(ctx, t, c, nil)
+			t.Cleanup(func() {
+				testcmd.Kill(ctx, t, init)
+				testcmd.Wait(ctx, t, init)
+				testcontainer.Kill(ctx, t, c)
+				testcontainer.Wait(ctx, t, c)
+			})
+
+			ps := testoci.CreateLinuxSpec(ctx, t, cID,
+				testoci.DefaultLinuxSpecOpts(ns.Id,
+					ctrdoci.WithDefaultPathEnv,
+					ctrdoci.WithProcessArgs("/bin/sh", "-c", `ip link show dev eth0`),
+				)...,
+			).Process
+			io := testcmd.NewBufferedIO()
+			p := testcmd.Create(ctx, t, c, ps, io)
+			testcmd.Start(ctx, t, p)
+
+			e := testcmd.Wait(ctx, t, p)
+			out, err := io.Output()
+			t.Logf("cmd output:\n%s", out)
+			if e != 0 || err != nil {
+				t.Fatalf("exit code %d and error %v", e, err)
+			}
+
+			if want := fmt.Sprintf("mtu %d ", tc.expected); !strings.Contains(out, want) {
+				t.Errorf("expected eth0 to have %q", strings.TrimSpace(want))
+			}
+		})
+	}
+}
+
+// TestLCOW_NetworkQoS_Bandwidth shapes the bandwidth of the traffic received and
+// sent by a container to 10 Mbps, and measures it with iperf3 between the host
+// and the container. The test is skipped if iperf3 is not installed on the host,
+// or cannot be installed in the container.
+func TestLCOW_NetworkQoS_Bandwidth(t *testing.T) {
+	requireFeatures(t, featureLCOW, featureUVM)
+	require.Build(t, osversion.RS5)
+
+	iperf, err := exec.LookPath("iperf3")
+	if err != nil {
+		t.Skipf("iperf3 is not installed on the host: %v", err)
+	}
+
+	const (
+		mbps   = 10
+		bps    = mbps * 1_000_000
+		margin = 0.2
+	)
+
+	for _, tc := range []struct {
+		name      string
+		bandwidth uvm.NetworkBandwidth
+		// reverse has the container send the traffic, rather than receive it
+		reverse bool
+	}{
+		{
+			name:      "Ingress",
+			bandwidth: uvm.NetworkBandwidth{IngressMbps: mbps},
+		},
+		{
+			name:      "Egress",
+			bandwidth: uvm.NetworkBandwidth{EgressMbps: mbps},
+			reverse:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ns, err := newNetworkNamespace()
+			if err != nil {
+				t.Fatalf("namespace creation: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := ns.Delete(); err != nil {
+					t.Errorf("namespace delete: %v", err)
+				}
+			})
+			t.Logf("created namespace %s", ns.Id)
+
+			ntwk, err := (&hcn.HostComputeNetwork{
+				Name: hcsOwner + "qosnetwork",
+				Type: hcn.NAT,
+				Ipams: []hcn.Ipam{
+					{
+						Type: "Static",
+						Subnets: []hcn.Subnet{
+							{
+								IpAddressPrefix: "192.168.101.0/24",
+								Routes: []hcn.Route{
+									{
+										NextHop:           "192.168.101.1",
+										DestinationPrefix: "0.0.0.0/0",
+									},
+								},
+							},
+						},
+					},
+				},
+				SchemaVersion: hcn.Version{Major: 2, Minor: 2},
+			}).Create()
+			if err != nil {
+				t.Fatalf("network creation: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := ntwk.Delete(); err != nil {
+					t.Errorf("network delete: %v", err)
+				}
+			})
+			t.Logf("created network %s (%s)", ntwk.Name, ntwk.Id)
+
+			ep, err := (&hcn.HostComputeEndpoint{
+				Name:               ntwk.Name + "endpoint",
+				HostComputeNetwork: ntwk.Id,
+				SchemaVersion:      hcn.Version{Major: 2, Minor: 2},
+			}).Create()
+			if err != nil {
+				t.Fatalf("endpoint creation: %v", err)
+			}
+			t.Cleanup(func() {
+				if err := ep.Delete(); err != nil {
+					t.Errorf("endpoint delete: %v", err)
+				}
+			})
+			t.Logf("created endpoint %s", ep.Id)
+
+			if len(ep.IpConfigurations) == 0 {
+				t.Fatalf("expected an IP address for the endpoint")
+			}
+			ip := ep.IpConfigurations[0].IpAddress
+
+			if err := ep.NamespaceAttach(ns.Id); err != nil {
+				t.Fatalf("network attachment: %v", err)
+			}
+
+			ctx := util.Context(namespacedContext(context.Background()), t)
+			ls := linuxImageLayers(ctx, t)
+			vm := testuvm.CreateAndStartLCOWFromOpts(ctx, t, defaultLCOWOptions(ctx, t))
+
+			if err := vm.CreateAndAssignNetworkSetup(ctx, "", ""); err != nil {
+				t.Fatalf("setting up network: %v", err)
+			}
+			if err := vm.SetNetNSBandwidth(ns.Id, tc.bandwidth); err != nil {
+				t.Fatalf("setting network bandwidth: %v", err)
+			}
+			if err := vm.ConfigureNetworking(ctx, ns.Id); err != nil {
+				t.Fatalf("adding network to vm: %v", err)
+			}
+
+			cID := strings.ReplaceAll(t.Name(), "/", "")
+			scratch, _ := testlayers.ScratchSpace(ctx, t, vm, "", "", "")
+			spec := testoci.CreateLinuxSpec(ctx, t, cID,
+				testoci.DefaultLinuxSpecOpts(ns.Id,
+					ctrdoci.WithProcessArgs("/bin/sh", "-c", testoci.TailNullArgs),
+					ctrdoci.WithWindowsNetworkNamespace(ns.Id),
+					testoci.WithWindowsLayerFolders(append(ls, scratch)))...)
+
+			c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+			t.Logf("created container %s", cID)
+			t.Cleanup(cleanup)
+			init := testcontainer.Start(ctx, t, c, nil)
+			t.Cleanup(func() {
+				testcmd.Kill(ctx, t, init)
+				testcmd.Wait(ctx, t, init)
+				testcontainer.Kill(ctx, t, c)
+				testcontainer.Wait(ctx, t, c)
+			})
+
+			containerCmd := func(args string) *testcmd.BufferedIO {
+				ps := testoci.CreateLinuxSpec(ctx, t, cID,
+					testoci.DefaultLinuxSpecOpts(ns.Id,
+						ctrdoci.WithDefaultPathEnv,
+						ctrdoci.WithProcessArgs("/bin/sh", "-c", args),
+					)...,
+				).Process
+				io := testcmd.NewBufferedIO()
+				p := testcmd.Create(ctx, t, c, ps, io)
+				testcmd.Start(ctx, t, p)
+				if e := testcmd.Wait(ctx, t, p); e != 0 {
+					out, _ := io.Output()
+					t.Skipf("could not run %q in the container, exit code %d:\n%s", args, e, out)
+				}
+				return io
+			}
+			containerCmd("command -v iperf3 || apk add --no-cache iperf3")
+
+			// serve a single test in the background
+			containerCmd("iperf3 --server --one-off --daemon")
+
+			// omit the first seconds, while TCP ramps up to the shaped rate
+			args := []string{"--client", ip, "--time", "15", "--omit", "3", "--json"}
+			if tc.reverse {
+				args = append(args, "--reverse")
+			}
+			var out []byte
+			for i := 0; ; i++ {
+				out, err = exec.CommandContext(ctx, iperf, args...).Output()
+				if err == nil || i == 10 {
+					break
+				}
+				// the server may not be listening yet
+				time.Sleep(time.Second)
+			}
+			if err != nil {
+				t.Fatalf("iperf3 %s: %v:\n%s", strings.Join(args, " "), err, out)
+			}
+
+			var result struct {
+				End struct {
+					SumReceived struct {
+						BitsPerSecond float64 `json:"bits_per_second"`
+					} `json:"sum_received"`
+				} `json:"end"`
+			}
+			if err := json.Unmarshal(out, &result); err != nil {
+				t.Fatalf("iperf3 output: %v:\n%s", err, out)
+			}
+			got := result.End.SumReceived.BitsPerSecond
+			t.Logf("measured %.0f bits per second", got)
+			if math.Abs(got-bps) > bps*margin {
+				t.Fatalf("expected %d bits per second within %.0f%%, got %.0f", bps, margin*100, got)
+			}
+		})
+	}
+}
+
 func newNetworkNamespace() (*hcn.HostComputeNamespace, error) {
 	return (&hcn.HostComputeNamespace{}).Create()
 }
@@ -0,0 +1,106 @@
+//go:build windows && functional
+// +build windows,functional
+
+package functional
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/osversion"
+
+	"github.com/Microsoft/hcsshim/test/internal/util"
+	"github.com/Microsoft/hcsshim/test/pkg/definitions/cpugroup"
+	"github.com/Microsoft/hcsshim/test/pkg/definitions/processorinfo"
+	"github.com/Microsoft/hcsshim/test/pkg/require"
+	testuvm "github.com/Microsoft/hcsshim/test/pkg/uvm"
+)
+
+func TestLCOW_SetCPUGroup(t *testing.T) {
+	requireFeatures(t, featureLCOW, featureUVM)
+	require.Build(t, osversion.V21H1)
+
+	ctx := util.Context(context.Background(), t)
+
+	processorTopology, err := processorinfo.HostProcessorInfo(ctx)
+	if err != nil {
+		t.Fatalf("failed to get host processor information: %s", err)
+	}
+	lpIndices := make([]uint32, processorTopology.LogicalProcessorCount)
+	for i, p := range processorTopology.LogicalProcessors {
+		lpIndices[i] = p.LpIndex
+	}
+
+	groupIDs := []string{
+		"1b3a9a3e-6f0e-4c8e-9b7a-2c3f5e4d6a01",
+		"1b3a9a3e-6f0e-4c8e-9b7a-2c3f5e4d6a02",
+	}
+	deleted := make(map[string]bool)
+	for _, id := range groupIDs {
+		if err := cpugroup.Create(ctx, id, lpIndices); err != nil {
+			t.Fatalf("failed to create test cpugroup %s: %v", id, err)
+		}
+		t.Cleanup(func() {
+			if deleted[id] {
+				return
+			}
+			err := cpugroup.Delete(ctx, id)
+			if err != nil && !errors.Is(err, cpugroup.ErrHVStatusInvalidCPUGroupState) {
+				t.Errorf("failed to clean up test cpugroup %s: %v", id, err)
+			}
+		})
+	}
+
+	vm := testuvm.CreateAndStart(ctx, t, defaultLCOWOptions(ctx, t))
+	defer vm.Close()
+
+	if err := vm.SetCPUGroup(ctx, "not-a-guid"); err == nil {
+		t.Fatal("expected setting an invalid cpugroup ID to fail")
+	}
+	if id := vm.CPUGroupID(); id != "" {
+		t.Fatalf("got cpugroup %q after failing to set it, expected none", id)
+	}
+
+	// HCS does not report the cpugroup of a VM, but a cpugroup can't be deleted
+	// while a VM is in it, which shows whether the uVM is in the cpugroup
+	requireMember := func(id string) {
+		t.Helper()
+		if err := cpugroup.Delete(ctx, id); err == nil {
+			deleted[id] = true
+			t.Fatalf("deleted cpugroup %s, expected the uVM to be in it", id)
+		}
+	}
+	requireNotMember := func(id string) {
+		t.Helper()
+		if err := cpugroup.Delete(ctx, id); err != nil {
+			t.Fatalf("failed to delete cpugroup %s, expected the uVM to have left it: %v", id, err)
+		}
+		deleted[id] = true
+	}
+
+	// move the running uVM between cpugroups
+	prev := ""
+	for _, id := range groupIDs {
+		if err := vm.SetCPUGroup(ctx, id); err != nil {
+			t.Fatalf("failed to set cpugroup %s: %v", id, err)
+		}
+		if got := vm.CPUGroupID(); !strings.EqualFold(got, id) {
+			t.Fatalf("got cpugroup %q, expected %q", got, id)
+		}
+		requireMember(id)
+		if prev != "" {
+			requireNotMember(prev)
+		}
+		prev = id
+	}
+
+	if err := vm.ReleaseCPUGroup(ctx); err != nil {
+		t.Fatalf("failed to release cpugroup: %v", err)
+	}
+	if id := vm.CPUGroupID(); id != "" {
+		t.Fatalf("got cpugroup %q after releasing it, expected none", id)
+	}
+	requireNotMember(prev)
+}
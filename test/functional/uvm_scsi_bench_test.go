@@ -0,0 +1,78 @@
+//go:build windows && functional
+// +build windows,functional
+
+package functional
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/cmd"
+	"github.com/Microsoft/hcsshim/internal/lcow"
+	"github.com/Microsoft/hcsshim/internal/uvm/scsi"
+	"github.com/Microsoft/hcsshim/osversion"
+
+	"github.com/Microsoft/hcsshim/test/internal/util"
+	"github.com/Microsoft/hcsshim/test/pkg/require"
+	testuvm "github.com/Microsoft/hcsshim/test/pkg/uvm"
+)
+
+// BenchmarkLCOW_SCSI_BlockSize compares sequential write throughput to a raw
+// SCSI block device with 512-byte and 4096-byte (4K-native) logical block sizes.
+func BenchmarkLCOW_SCSI_BlockSize(b *testing.B) {
+	requireFeatures(b, featureLCOW, featureUVM, featureSCSI)
+	require.Build(b, osversion.RS5)
+
+	const (
+		writeSizeMB = 256
+		diskSizeGB  = 1
+	)
+
+	pCtx := util.Context(context.Background(), b)
+	vm := testuvm.CreateAndStartLCOWFromOpts(pCtx, b, defaultLCOWOptions(pCtx, b))
+
+	for _, bs := range []uint32{512, 4096} {
+		b.Run(strconv.FormatUint(uint64(bs), 10), func(b *testing.B) {
+			disk := filepath.Join(b.TempDir(), "disk.vhdx")
+			if err := lcow.CreateScratch(pCtx, vm, disk, diskSizeGB, ""); err != nil {
+				b.Fatalf("failed to create disk: %v", err)
+			}
+
+			m, err := vm.SCSIManager.AddVirtualDisk(pCtx, disk, false, vm.ID(), "",
+				&scsi.MountConfig{BlockDev: true, BlockSize: bs})
+			if err != nil {
+				b.Fatalf("failed to add block device: %v", err)
+			}
+			b.Cleanup(func() {
+				if err := m.Release(pCtx); err != nil {
+					b.Errorf("failed to remove block device: %v", err)
+				}
+			})
+
+			// write directly to the device so the page cache does not hide the
+			// cost of the block size
+			args := []string{"dd", "if=/dev/zero", "of=" + m.GuestPath(),
+				"bs=1M", fmt.Sprintf("count=%d", writeSizeMB), "oflag=direct", "conv=fsync"}
+
+			b.SetBytes(writeSizeMB << 20)
+			b.StopTimer()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ctx, cancel := context.WithTimeout(pCtx, benchmarkIterationTimeout)
+
+				c := cmd.CommandContext(ctx, vm, args[0], args[1:]...)
+				b.StartTimer()
+				err := c.Run()
+				b.StopTimer()
+				if err != nil {
+					b.Fatalf("failed to write to block device: %v", err)
+				}
+
+				cancel()
+			}
+		})
+	}
+}
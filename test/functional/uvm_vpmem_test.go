@@ -5,12 +5,18 @@ package functional
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/opencontainers/runtime-spec/specs-go"
+
 	"github.com/Microsoft/hcsshim/internal/copyfile"
+	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/osversion"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 
 	"github.com/Microsoft/hcsshim/test/internal/util"
 	"github.com/Microsoft/hcsshim/test/pkg/require"
@@ -52,3 +58,64 @@ func TestVPMEM(t *testing.T) {
 		}
 	}
 }
+
+// TestVPMEMCountAnnotation tests that the VPMem device count annotation is
+// respected when creating a Linux utility VM.
+func TestVPMEMCountAnnotation(t *testing.T) {
+	require.Build(t, osversion.RS5)
+	requireFeatures(t, featureLCOW, featureUVM, featureVPMEM)
+
+	ctx := util.Context(context.Background(), t)
+	layers := linuxImageLayers(ctx, t)
+
+	const count = 4
+	s := &specs.Spec{
+		Linux: &specs.Linux{},
+		Annotations: map[string]string{
+			annotations.UVMVirtualPMEMCount: strconv.Itoa(count),
+			// disable multi-mapping so that each layer takes up an entire device
+			annotations.VPMemNoMultiMapping: "true",
+		},
+	}
+	o, err := oci.SpecToUVMCreateOpts(ctx, s, testName(t), hcsOwner)
+	if err != nil {
+		t.Fatalf("failed to create UVM options from spec: %s", err)
+	}
+	opts := o.(*uvm.OptionsLCOW)
+	if p := *flagLinuxBootFilesPath; p != "" {
+		opts.UpdateBootFilesPath(ctx, p)
+	}
+	if opts.VPMemDeviceCount != count {
+		t.Fatalf("expected VPMem device count %d, got %d", count, opts.VPMemDeviceCount)
+	}
+	u := testuvm.CreateAndStartLCOWFromOpts(ctx, t, opts)
+	defer u.Close()
+
+	// Use distinct copies of layer.vhd from the image so that each one is
+	// assigned its own device.
+	tempDir := t.TempDir()
+	vhds := make([]string, count+1)
+	for i := range vhds {
+		vhds[i] = filepath.Join(tempDir, fmt.Sprintf("layer%d.vhd", i))
+		if err := copyfile.CopyFile(ctx, filepath.Join(layers[0], "layer.vhd"), vhds[i], true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, vhd := range vhds[:count] {
+		mount, err := u.AddVPMem(ctx, vhd)
+		if err != nil {
+			t.Fatalf("AddVPMEM failed: %s", err)
+		}
+		t.Logf("exposed as %s", mount.GuestPath)
+	}
+	if _, err := u.AddVPMem(ctx, vhds[count]); err == nil {
+		t.Fatalf("expected AddVPMEM to fail after %d devices were added", count)
+	}
+
+	for _, vhd := range vhds[:count] {
+		if err := u.RemoveVPMem(ctx, vhd); err != nil {
+			t.Fatalf("RemoveVPMEM failed: %s", err)
+		}
+	}
+}
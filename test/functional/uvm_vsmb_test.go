@@ -11,9 +11,13 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/opencontainers/runtime-spec/specs-go"
+
 	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/osversion"
 
+	testcmd "github.com/Microsoft/hcsshim/test/internal/cmd"
 	"github.com/Microsoft/hcsshim/test/internal/util"
 	"github.com/Microsoft/hcsshim/test/pkg/require"
 	testuvm "github.com/Microsoft/hcsshim/test/pkg/uvm"
@@ -169,3 +173,96 @@ func TestVSMB_WCOW(t *testing.T) {
 		}
 	})
 }
+
+// TestVSMB_WCOW_SubPath tests sharing multiple directories into a v2 Windows utility VM
+// through a single VSMB share of their parent directory.
+func TestVSMB_WCOW_SubPath(t *testing.T) {
+	require.Build(t, osversion.RS5)
+	requireFeatures(t, featureWCOW, featureUVM, featureVSMB)
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+
+	// create a temp directory before creating the uVM, so the uVM will be closed before
+	// temp dir's cleanup
+	parent := t.TempDir()
+	var subPaths []string
+	for _, d := range []string{"a", "b"} {
+		p := filepath.Join(parent, d)
+		if err := os.Mkdir(p, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(p, "f.txt"), []byte(d), 0600); err != nil {
+			t.Fatal(err)
+		}
+		subPaths = append(subPaths, p)
+	}
+
+	vm := testuvm.CreateAndStart(ctx, t, defaultWCOWOptions(ctx, t))
+
+	options := vm.DefaultVSMBOptions(true)
+	// allow the share to be removed once it is no longer referenced
+	options.NoDirectmap = true
+
+	var shares []*uvm.VSMBShare
+	for _, p := range subPaths {
+		opts := *options
+		s, err := vm.AddVSMBSubPath(ctx, parent, p, subPaths, &opts)
+		if err != nil {
+			t.Fatalf("failed to add vSMB share subdirectory %s: %v", p, err)
+		}
+		shares = append(shares, s)
+	}
+
+	parentGuestPath, err := vm.GetVSMBUvmPath(ctx, parent, true)
+	if err != nil {
+		t.Fatalf("failed to get guest path of parent vSMB share: %v", err)
+	}
+	for i, s := range shares {
+		want := filepath.Join(parentGuestPath, filepath.Base(subPaths[i]))
+		if s.GuestPath() != want {
+			t.Fatalf("expected guest path %q, got %q", want, s.GuestPath())
+		}
+
+		io := testcmd.NewBufferedIO()
+		c := testcmd.Create(ctx, t, vm, &specs.Process{
+			CommandLine: fmt.Sprintf(`cmd /c type %s\f.txt`, s.GuestPath()),
+		}, io)
+		testcmd.Start(ctx, t, c)
+		testcmd.WaitExitCode(ctx, t, c, 0)
+		io.TestOutput(t, filepath.Base(subPaths[i]), nil)
+	}
+
+	if _, err := vm.AddVSMBSubPath(ctx, parent, parent, nil, options); err == nil {
+		t.Fatal("expected adding the parent as its own subdirectory to fail")
+	}
+
+	// the parent is checked again each time the share is referenced
+	other := filepath.Join(parent, "other")
+	if err := os.Mkdir(other, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.AddVSMBSubPath(ctx, parent, subPaths[0], subPaths, options); !errors.Is(err, uvm.ErrVSMBParentNotExclusive) {
+		t.Fatalf("expected adding a subdirectory of a parent holding other directories to fail, got: %v", err)
+	}
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parent share must remain while a subdirectory is still referenced
+	if err := shares[0].Release(ctx); err != nil {
+		t.Fatalf("failed to release vSMB share subdirectory: %v", err)
+	}
+	if _, err := vm.GetVSMBUvmPath(ctx, parent, true); err != nil {
+		t.Fatalf("expected parent vSMB share to remain after releasing one subdirectory: %v", err)
+	}
+	if err := shares[0].Release(ctx); err == nil {
+		t.Fatal("expected releasing an unreferenced subdirectory to fail")
+	}
+
+	if err := shares[1].Release(ctx); err != nil {
+		t.Fatalf("failed to release vSMB share subdirectory: %v", err)
+	}
+	if _, err := vm.GetVSMBUvmPath(ctx, parent, true); !errors.Is(err, uvm.ErrNotAttached) {
+		t.Fatalf("expected parent vSMB share to be removed, got: %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+//go:build windows && functional
+// +build windows,functional
+
+package functional
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/containers"
+	ctrdoci "github.com/containerd/containerd/v2/pkg/oci"
+	"github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/Microsoft/hcsshim/hcn"
+	"github.com/Microsoft/hcsshim/osversion"
+
+	testcmd "github.com/Microsoft/hcsshim/test/internal/cmd"
+	testcontainer "github.com/Microsoft/hcsshim/test/internal/container"
+	testlayers "github.com/Microsoft/hcsshim/test/internal/layers"
+	testoci "github.com/Microsoft/hcsshim/test/internal/oci"
+	"github.com/Microsoft/hcsshim/test/internal/util"
+	"github.com/Microsoft/hcsshim/test/pkg/require"
+	testuvm "github.com/Microsoft/hcsshim/test/pkg/uvm"
+)
+
+// Test_CreateContainer_WCOW_Hypervisor_IPv6_Only creates a WCOW hypervisor
+// isolated container with an endpoint that only has an IPv6 address, and checks
+// that the container can reach its own address and the gateway outside of it.
+//
+// CRI pods get their endpoints from the CNI configuration of the node, so the
+// IPv6-only endpoint is created here and passed in the container's spec, for
+// the shim to create the network namespace of the container with.
+func Test_CreateContainer_WCOW_Hypervisor_IPv6_Only(t *testing.T) {
+	requireFeatures(t, featureWCOW, featureUVM, featureContainer)
+	require.Build(t, osversion.RS5)
+	if err := hcn.IPv6DualStackSupported(); err != nil {
+		t.Skipf("IPv6 is not supported by HNS: %v", err)
+	}
+
+	ipv6Route := hcn.Route{
+		NextHop:           "fd00::101",
+		DestinationPrefix: "::/0",
+	}
+
+	// create network and endpoint with no IPv4 subnets or addresses
+	ntwk, err := (&hcn.HostComputeNetwork{
+		Name: hcsOwner + "ipv6network",
+		Type: hcn.NAT,
+		Ipams: []hcn.Ipam{
+			{
+				Type: "Static",
+				Subnets: []hcn.Subnet{
+					{
+						IpAddressPrefix: "fd00::100/120",
+						Routes:          []hcn.Route{ipv6Route},
+					},
+				},
+			},
+		},
+		SchemaVersion: hcn.Version{Major: 2, Minor: 2},
+	}).Create()
+	if err != nil {
+		t.Fatalf("network creation: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ntwk.Delete(); err != nil {
+			t.Errorf("network delete: %v", err)
+		}
+	})
+	t.Logf("created network %s (%s)", ntwk.Name, ntwk.Id)
+
+	ep, err := (&hcn.HostComputeEndpoint{
+		Name:               ntwk.Name + "endpoint",
+		HostComputeNetwork: ntwk.Id,
+		Routes:             []hcn.Route{ipv6Route},
+		IpConfigurations: []hcn.IpConfig{
+			{
+				IpAddress:    "fd00::106",
+				PrefixLength: 120,
+			},
+		},
+		SchemaVersion: hcn.Version{Major: 2, Minor: 2},
+	}).Create()
+	if err != nil {
+		t.Fatalf("endpoint creation: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ep.Delete(); err != nil {
+			t.Errorf("endpoint delete: %v", err)
+		}
+	})
+	t.Logf("created endpoint %s", ep.Id)
+
+	if len(ep.IpConfigurations) != 1 {
+		t.Fatalf("expected a single IPv6 address for the endpoint, got %v", ep.IpConfigurations)
+	}
+	podIP := ep.IpConfigurations[0].IpAddress
+	if !strings.Contains(podIP, ":") {
+		t.Fatalf("expected an IPv6 address for the endpoint, got %s", podIP)
+	}
+
+	ctx := util.Context(namespacedContext(context.Background()), t)
+	ls := windowsImageLayers(ctx, t)
+	vm := testuvm.CreateAndStart(ctx, t, defaultWCOWOptions(ctx, t))
+
+	cID := vm.ID() + "-container"
+	scratch := testlayers.WCOWScratchDir(ctx, t, "")
+	spec := testoci.CreateWindowsSpec(ctx, t, cID,
+		testoci.DefaultWindowsSpecOpts("",
+			ctrdoci.WithProcessCommandLine("cmd.exe /c ping -t ::1"),
+			testoci.WithWindowsLayerFolders(append(ls, scratch)),
+			withWindowsNetworkEndpoints(ep.Id),
+		)...)
+
+	c, _, cleanup := testcontainer.Create(ctx, t, vm, spec, cID, hcsOwner)
+	t.Logf("created container %s", cID)
+	t.Cleanup(cleanup)
+	init := testcontainer.StartWithSpec(ctx, t, c, spec.Process, nil)
+	t.Cleanup(func() {
+		testcmd.Kill(ctx, t, init)
+		testcmd.Wait(ctx, t, init)
+		testcontainer.Kill(ctx, t, c)
+		testcontainer.Wait(ctx, t, c)
+	})
+
+	exec := func(t *testing.T, cmd string) string {
+		t.Helper()
+		ps := testoci.CreateWindowsSpec(ctx, t, cID,
+			testoci.DefaultWindowsSpecOpts("",
+				ctrdoci.WithProcessCommandLine(cmd),
+			)...).Process
+		io := testcmd.NewBufferedIO()
+		p := testcmd.Create(ctx, t, c, ps, io)
+		testcmd.Start(ctx, t, p)
+
+		e := testcmd.Wait(ctx, t, p)
+		out, err := io.Output()
+		t.Logf("cmd output:\n%s", out)
+		if e != 0 || err != nil {
+			t.Fatalf("exit code %d and error %v", e, err)
+		}
+		return out
+	}
+
+	t.Run("addresses", func(t *testing.T) {
+		out := exec(t, "ipconfig")
+		if !strings.Contains(out, podIP) {
+			t.Errorf("missing ip address %s", podIP)
+		}
+		if strings.Contains(out, "IPv4 Address") {
+			t.Errorf("expected no IPv4 address")
+		}
+	})
+
+	for _, ip := range []string{"::1", podIP, ipv6Route.NextHop} {
+		t.Run(fmt.Sprintf("ping %s", ip), func(t *testing.T) {
+			out := exec(t, "ping -6 -n 1 "+ip)
+			if !strings.Contains(out, "Reply from") {
+				t.Errorf("expected a reply from %s", ip)
+			}
+		})
+	}
+}
+
+// withWindowsNetworkEndpoints sets the HNS endpoints of the container, which
+// are added to a network namespace created for it.
+func withWindowsNetworkEndpoints(ids ...string) ctrdoci.SpecOpts {
+	return func(_ context.Context, _ ctrdoci.Client, _ *containers.Container, s *specs.Spec) error {
+		if s.Windows == nil {
+			s.Windows = &specs.Windows{}
+		}
+		if s.Windows.Network == nil {
+			s.Windows.Network = &specs.WindowsNetwork{}
+		}
+		s.Windows.Network.EndpointList = ids
+		return nil
+	}
+}
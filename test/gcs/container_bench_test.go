@@ -123,8 +123,8 @@ func BenchmarkContainerCompleteExit(b *testing.B) {
 			b.Fatalf("container exit was %s", n)
 		}
 
-		if e != 0 {
-			b.Fatalf("container exit code was %d", e)
+		if e.Code != 0 {
+			b.Fatalf("container exit code was %d", e.Code)
 		}
 
 		killContainer(ctx, b, c)
@@ -182,8 +182,8 @@ func BenchmarkContainerExec(b *testing.B) {
 		b.StartTimer()
 		p := execProcess(ctx, b, c, ps, stdio.ConnectionSettings{})
 		exch, dch := p.Wait()
-		if e := <-exch; e != 0 {
-			b.Errorf("process exited with error code %d", e)
+		if e := <-exch; e.Code != 0 {
+			b.Errorf("process exited with error code %d", e.Code)
 		}
 		b.StopTimer()
 
@@ -5,6 +5,7 @@ package gcs
 import (
 	"context"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
@@ -219,8 +220,8 @@ func TestContainerExec(t *testing.T) {
 			t.Logf("wrote std in: %q", tt.in)
 
 			exch, _ := p.Wait()
-			if i := <-exch; i != 0 {
-				t.Errorf("process exited with error code %d", i)
+			if e := <-exch; e.Code != 0 {
+				t.Errorf("process exited with error code %d", e.Code)
 			}
 
 			_ = g.Wait()
@@ -235,3 +236,66 @@ func TestContainerExec(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerExecExitStatus(t *testing.T) {
+	requireFeatures(t, featureStandalone)
+
+	ctx := namespaces.WithNamespace(context.Background(), testoci.DefaultNamespace)
+	host, rtime := getTestState(ctx, t)
+	assertNumberContainers(ctx, t, rtime, 0)
+
+	id := t.Name()
+	c := createStandaloneContainer(ctx, t, host, id)
+	t.Cleanup(func() {
+		cleanupContainer(ctx, t, host, c)
+	})
+
+	ip := startContainer(ctx, t, c, stdio.ConnectionSettings{})
+	t.Cleanup(func() {
+		killContainer(ctx, t, c)
+		waitContainer(ctx, t, c, ip, true)
+	})
+
+	for _, tt := range []struct {
+		name   string
+		script string
+		code   int
+		signal syscall.Signal
+	}{
+		{
+			name:   "Exited",
+			script: "exit 3",
+			code:   3,
+		},
+		{
+			name:   "SIGKILL",
+			script: "kill -KILL $$",
+			code:   137,
+			signal: syscall.SIGKILL,
+		},
+		{
+			name:   "SIGSEGV",
+			script: "kill -SEGV $$",
+			code:   139,
+			signal: syscall.SIGSEGV,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := testoci.CreateLinuxSpec(ctx, t, id,
+				oci.WithDefaultPathEnv,
+				oci.WithProcessArgs("/bin/sh", "-c", tt.script),
+			).Process
+
+			p := execProcess(ctx, t, c, ps, stdio.ConnectionSettings{})
+			exch, dch := p.Wait()
+			defer close(dch)
+			e := <-exch
+			dch <- true
+
+			// whether the process dumps core depends on the container's limits
+			if e.Code != tt.code || e.Signal != tt.signal {
+				t.Fatalf("expected exit code %d and signal %d, got %+v", tt.code, tt.signal, e)
+			}
+		})
+	}
+}
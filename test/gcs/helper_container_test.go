@@ -62,7 +62,7 @@ func createStandaloneContainer(ctx context.Context, tb testing.TB, host *hcsv2.H
 
 func createContainer(ctx context.Context, tb testing.TB, host *hcsv2.Host, id string, s *prot.VMHostedContainerSettingsV2) *hcsv2.Container {
 	tb.Helper()
-	c, err := host.CreateContainer(ctx, id, s)
+	c, err := host.CreateContainer(ctx, id, s, "")
 	if err != nil {
 		tb.Fatalf("could not create container %q: %v", id, err)
 	}
@@ -88,7 +88,7 @@ func startContainer(ctx context.Context, tb testing.TB, c *hcsv2.Container, conn
 // waitContainer waits on the container's init process, p.
 func waitContainer(ctx context.Context, tb testing.TB, c *hcsv2.Container, p hcsv2.Process, forced bool) {
 	tb.Helper()
-	var e int
+	var e runtime.ExitStatus
 	ch := make(chan prot.NotificationType)
 
 	// have to read the init process exit code to close the container
@@ -120,14 +120,14 @@ func waitContainer(ctx context.Context, tb testing.TB, c *hcsv2.Container, p hcs
 	}
 
 	switch {
-	case e == 0:
-	case forced && e == 137:
+	case e.Code == 0:
+	case forced && e.Code == 137 && e.Signal == syscall.SIGKILL:
 	default:
-		tb.Fatalf("got exit code %d", e)
+		tb.Fatalf("got exit status %+v", e)
 	}
 }
 
-func waitContainerRaw(c *hcsv2.Container, p hcsv2.Process) (int, prot.NotificationType) {
+func waitContainerRaw(c *hcsv2.Container, p hcsv2.Process) (runtime.ExitStatus, prot.NotificationType) {
 	exch, dch := p.Wait()
 	defer close(dch)
 	r := <-exch
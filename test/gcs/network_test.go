@@ -0,0 +1,111 @@
+//go:build linux
+
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/Microsoft/hcsshim/internal/guest/network"
+	"github.com/Microsoft/hcsshim/internal/guest/prot"
+	"github.com/Microsoft/hcsshim/internal/guest/stdio"
+	"github.com/Microsoft/hcsshim/internal/protocol/guestresource"
+)
+
+//
+// tests for configuring networking in (CRI) sandbox containers
+//
+
+// TestCRIVLANTag tests that a VLAN-tagged adapter in a sandbox container's
+// network namespace is configured on a VLAN link with the requested ID.
+func TestCRIVLANTag(t *testing.T) {
+	requireFeatures(t, featureCRI)
+
+	const (
+		ifname = "vlantest0"
+		tag    = 100
+	)
+
+	ctx := context.Background()
+	host, rtime := getTestState(ctx, t)
+	assertNumberContainers(ctx, t, rtime, 0)
+
+	sid := t.Name()
+	scratch, rootfs := mountRootfs(ctx, t, host, sid)
+	t.Cleanup(func() {
+		unmountRootfs(ctx, t, scratch)
+	})
+	createNamespace(ctx, t, sid)
+	t.Cleanup(func() {
+		removeNamespace(ctx, t, sid)
+	})
+
+	spec := sandboxSpec(ctx, t, "test-sandbox", sid, sid, rootfs)
+	sandbox := createContainer(ctx, t, host, sid, &prot.VMHostedContainerSettingsV2{
+		OCIBundlePath:    scratch,
+		OCISpecification: spec,
+	})
+	t.Cleanup(func() {
+		cleanupContainer(ctx, t, host, sandbox)
+		assertNumberContainers(ctx, t, rtime, 0)
+	})
+
+	sandboxInit := startContainer(ctx, t, sandbox, stdio.ConnectionSettings{})
+	t.Cleanup(func() {
+		killContainer(ctx, t, sandbox)
+		waitContainer(ctx, t, sandbox, sandboxInit, true)
+	})
+	pid := sandboxInit.Pid()
+
+	// use a dummy link in place of a VMBus network adapter
+	if err := netlink.LinkAdd(&netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: ifname}}); err != nil {
+		t.Fatalf("could not create link %q: %v", ifname, err)
+	}
+	if err := network.MoveInterfaceToNS(ifname, pid); err != nil {
+		t.Fatalf("could not move link %q to sandbox network namespace: %v", ifname, err)
+	}
+
+	ns, err := netns.GetFromPid(pid)
+	if err != nil {
+		t.Fatalf("could not get sandbox network namespace: %v", err)
+	}
+	t.Cleanup(func() {
+		ns.Close()
+	})
+
+	adapter := &guestresource.LCOWNetworkAdapter{
+		ID:      ifname,
+		VLANTag: tag,
+		IPConfigs: []guestresource.LCOWIPConfig{
+			{
+				IPAddress:    "192.168.0.5",
+				PrefixLength: 24,
+			},
+		},
+	}
+	vlanIfname := fmt.Sprintf("%s.%d", ifname, tag)
+
+	var out []byte
+	if err := network.DoInNetNS(ns, func() error {
+		if err := network.NetNSConfig(ctx, ifname, pid, adapter); err != nil {
+			return err
+		}
+		// the thread is locked to the sandbox network namespace, so the command
+		// will be started in it
+		out, err = exec.Command("ip", "-d", "link", "show", vlanIfname).CombinedOutput()
+		return err
+	}); err != nil {
+		t.Fatalf("could not configure adapter: %v\n%s", err, out)
+	}
+	t.Logf("ip -d link show %s:\n%s", vlanIfname, out)
+
+	if want := fmt.Sprintf("vlan protocol 802.1Q id %d", tag); !strings.Contains(string(out), want) {
+		t.Fatalf("link %q is not VLAN-tagged: missing %q", vlanIfname, want)
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux
+
+package gcs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/v2/pkg/namespaces"
+	"github.com/containerd/containerd/v2/pkg/oci"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/Microsoft/hcsshim/internal/guest/runtime/hcsv2"
+	"github.com/Microsoft/hcsshim/internal/guest/seccomp"
+	"github.com/Microsoft/hcsshim/internal/guest/stdio"
+
+	testoci "github.com/Microsoft/hcsshim/test/internal/oci"
+)
+
+// seccompModeEnv is set in the child processes of [TestContainerSeccomp] to the
+// seccomp mode to apply before creating the container.
+const seccompModeEnv = "HCSSHIM_GCS_TEST_SECCOMP_MODE"
+
+// TestContainerSeccomp creates, starts, execs in, signals and deletes a container
+// with the GCS seccomp filter of each mode applied, as the GCS applies it, so that
+// the runtime and the container's processes inherit it.
+//
+// The filter cannot be removed once applied, so each mode runs in a child process.
+func TestContainerSeccomp(t *testing.T) {
+	requireFeatures(t, featureStandalone)
+
+	if mode := os.Getenv(seccompModeEnv); mode != "" {
+		if err := seccomp.Apply(seccomp.Mode(mode)); err != nil {
+			t.Fatalf("failed to apply seccomp filter: %v", err)
+		}
+		testContainerLifecycle(t, seccomp.Mode(mode))
+		return
+	}
+
+	for _, mode := range []seccomp.Mode{seccomp.ModeNone, seccomp.ModeKernel, seccomp.ModeAll} {
+		t.Run(string(mode), func(t *testing.T) {
+			// the last -test.run flag wins
+			args := append(os.Args[1:], "-test.run=^TestContainerSeccomp$", "-test.v")
+			cmd := exec.Command(os.Args[0], args...)
+			cmd.Env = append(os.Environ(), seccompModeEnv+"="+string(mode))
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("container lifecycle with seccomp mode %q failed: %v\n%s", mode, err, out)
+			}
+			t.Logf("%s", out)
+		})
+	}
+}
+
+// testContainerLifecycle exercises the container operations of the bridge handlers
+// on a real container, and checks that the container's processes run under the
+// seccomp filter of `mode`.
+func testContainerLifecycle(t *testing.T, mode seccomp.Mode) {
+	t.Helper()
+	ctx := namespaces.WithNamespace(context.Background(), testoci.DefaultNamespace)
+	host, rtime := getTestState(ctx, t)
+	assertNumberContainers(ctx, t, rtime, 0)
+
+	id := t.Name()
+	c := createStandaloneContainer(ctx, t, host, id)
+	t.Cleanup(func() {
+		cleanupContainer(ctx, t, host, c)
+	})
+
+	ip := startContainer(ctx, t, c, stdio.ConnectionSettings{})
+	assertContainerState(ctx, t, rtime, id, "running")
+
+	// the filter is inherited by exec'd processes
+	status := execOutput(ctx, t, c, id, "/bin/sh", "-c", "grep '^Seccomp:' /proc/self/status")
+	want := "Seccomp:\t2"
+	if mode == seccomp.ModeNone {
+		want = "Seccomp:\t0"
+	}
+	if got := strings.TrimSpace(status); got != want {
+		t.Fatalf("expected exec'd process seccomp status %q, got %q", want, got)
+	}
+
+	if _, err := c.GetStats(ctx); err != nil {
+		t.Fatalf("failed to get container statistics: %v", err)
+	}
+
+	killContainer(ctx, t, c)
+	waitContainer(ctx, t, c, ip, true)
+}
+
+// execOutput runs `args` in the container `c` and returns its stdout. It fails the
+// test if the process writes to stderr or exits with a non-zero code.
+func execOutput(ctx context.Context, t *testing.T, c *hcsv2.Container, id string, args ...string) string {
+	t.Helper()
+	ps := testoci.CreateLinuxSpec(ctx, t, id,
+		oci.WithDefaultPathEnv,
+		oci.WithProcessArgs(args...),
+	).Process
+	con := newConnectionSettings(false, true, true)
+	f := createStdIO(ctx, t, con)
+
+	var outStr, errStr string
+	g := &errgroup.Group{}
+	g.Go(func() error {
+		outStr = f.ReadAllOut(ctx, t)
+		return nil
+	})
+	g.Go(func() error {
+		errStr = f.ReadAllErr(ctx, t)
+		return nil
+	})
+	// OS pipes can lose some data, so sleep a bit to let ReadAll* kick off
+	time.Sleep(10 * time.Millisecond)
+
+	p := execProcess(ctx, t, c, ps, con)
+	exch, _ := p.Wait()
+	if e := <-exch; e.Code != 0 {
+		t.Fatalf("process %v exited with error code %d", args, e.Code)
+	}
+	_ = g.Wait()
+	if errStr != "" {
+		t.Fatalf("process %v returned error %q", args, errStr)
+	}
+	return outStr
+}
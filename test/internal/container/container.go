@@ -16,8 +16,10 @@ import (
 	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/resources"
 	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/pkg/annotations"
 
 	testcmd "github.com/Microsoft/hcsshim/test/internal/cmd"
+	testlayers "github.com/Microsoft/hcsshim/test/internal/layers"
 )
 
 // TODO: update cleanup func to accept context, same as uVM cleanup
@@ -42,7 +44,11 @@ func Create(
 	if spec.Linux != nil {
 		lcowLayers, err = layers.ParseLCOWLayers(nil, spec.Windows.LayerFolders)
 	} else {
-		wcowLayers, err = layers.ParseWCOWLayers(nil, spec.Windows.LayerFolders)
+		var layerOpts []layers.WCOWLayerOption
+		if extra := oci.ParseAnnotationCommaSeparated(annotations.WCOWExtraLayerFolders, spec.Annotations); len(extra) > 0 {
+			layerOpts = append(layerOpts, layers.WithExtraLayers(testlayers.TempDir(tb), extra))
+		}
+		wcowLayers, err = layers.ParseWCOWLayers(nil, spec.Windows.LayerFolders, layerOpts...)
 	}
 	if err != nil {
 		tb.Fatalf("layer parsing failed: %s", err)
@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,7 @@ import (
 	"github.com/Microsoft/go-winio"
 	"github.com/google/go-containerregistry/pkg/crane"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/sirupsen/logrus"
 
 	"github.com/Microsoft/hcsshim/ext4/dmverity"
@@ -38,6 +40,9 @@ type LazyImageLayers struct {
 	Image        string
 	Platform     string
 	AppendVerity bool
+	// ExtraVolumes are declared by the image config, as with a VOLUME directive, in addition
+	// to the volumes the image declares itself.
+	ExtraVolumes []string
 	// TempPath is the path to create a temporary directory in.
 	// Defaults to [os.TempDir] if left empty.
 	TempPath string
@@ -46,6 +51,7 @@ type LazyImageLayers struct {
 
 	once       sync.Once // sets the [layers] and [extractErr] fields
 	layers     []string  // extracted layer directories, under [dir]
+	volumes    []string  // volumes declared by the image config, sorted
 	extractErr error     // extraction error
 }
 
@@ -98,6 +104,18 @@ func (x *LazyImageLayers) Layers(ctx context.Context, tb testing.TB) []string {
 	return x.layers
 }
 
+// Volumes returns the volumes declared by the config of the image, such as with a VOLUME
+// directive, in sorted order.
+func (x *LazyImageLayers) Volumes(ctx context.Context, tb testing.TB) []string {
+	tb.Helper()
+
+	if x == nil {
+		return nil
+	}
+	x.Layers(ctx, tb)
+	return x.volumes
+}
+
 // don't use tb.Error/Log inside Once.Do stack, since we cannot call tb.Helper before executing f()
 // within Once.Do and that will therefore show the wrong stack/location
 func (x *LazyImageLayers) extractLayers(ctx context.Context) (err error) {
@@ -137,6 +155,27 @@ func (x *LazyImageLayers) extractLayers(ctx context.Context) (err error) {
 		return fmt.Errorf("failed to pull image %q: %w", x.Image, err)
 	}
 
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return fmt.Errorf("failed to get image %q config: %w", x.Image, err)
+	}
+	if len(x.ExtraVolumes) > 0 {
+		cfg = cfg.DeepCopy()
+		if cfg.Config.Volumes == nil {
+			cfg.Config.Volumes = make(map[string]struct{})
+		}
+		for _, v := range x.ExtraVolumes {
+			cfg.Config.Volumes[v] = struct{}{}
+		}
+		if img, err = mutate.ConfigFile(img, cfg); err != nil {
+			return fmt.Errorf("failed to add volumes to image %q config: %w", x.Image, err)
+		}
+	}
+	for v := range cfg.Config.Volumes {
+		x.volumes = append(x.volumes, v)
+	}
+	slices.Sort(x.volumes)
+
 	layers, err := img.Layers()
 	if err != nil {
 		return fmt.Errorf("failed to get image %q layers: %w", x.Image, err)
@@ -3,9 +3,14 @@
 package layers
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/Microsoft/hcsshim/internal/lcow"
@@ -69,6 +74,16 @@ func WCOWScratchDir(ctx context.Context, tb testing.TB, dir string) string {
 	return dir
 }
 
+// TempDir returns the directory that the test layers and scratch directories are created in.
+func TempDir(tb testing.TB) string {
+	tb.Helper()
+	dir, err := tempDirOnce()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return dir
+}
+
 func newTestTempDir(_ context.Context, tb testing.TB, name string) string {
 	tb.Helper()
 	dir, err := tempDirOnce()
@@ -90,3 +105,81 @@ func newTestTempDir(_ context.Context, tb testing.TB, name string) string {
 
 	return dir
 }
+
+// WCOWLayerFromFiles creates a read-only WCIFS layer above `parents` that contains `files`,
+// which maps paths relative to the container's root (using forward slashes) to their contents.
+func WCOWLayerFromFiles(ctx context.Context, tb testing.TB, files map[string]string, parents []string) string {
+	tb.Helper()
+
+	dir := WCOWScratchDir(ctx, tb, "")
+	if err := windowsImage(ctx, io.NopCloser(filesTar(tb, "Files", files)), dir, parents); err != nil {
+		tb.Fatalf("create layer from files: %v", err)
+	}
+	return dir
+}
+
+// LCOWLayerFromFiles creates a directory with a read-only ext4 layer VHD that contains `files`,
+// which maps paths relative to the container's root (using forward slashes) to their contents.
+func LCOWLayerFromFiles(ctx context.Context, tb testing.TB, files map[string]string) string {
+	tb.Helper()
+
+	dir := newTestTempDir(ctx, tb, "")
+	extract := withVHDFooter(linuxExt4LayerExtractHandler())
+	if err := extract(ctx, io.NopCloser(filesTar(tb, "", files)), dir, nil); err != nil {
+		tb.Fatalf("create layer from files: %v", err)
+	}
+	return dir
+}
+
+// filesTar returns a tar of `files` under the `root` directory, along with their parent
+// directories.
+func filesTar(tb testing.TB, root string, files map[string]string) *bytes.Buffer {
+	tb.Helper()
+
+	contents := make(map[string]string, len(files))
+	names := make([]string, 0, len(files))
+	for name, content := range files {
+		name = path.Join(root, name)
+		contents[name] = content
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	dirs := make(map[string]bool)
+	var writeDir func(string)
+	writeDir = func(d string) {
+		if d == "." || d == "/" || dirs[d] {
+			return
+		}
+		writeDir(path.Dir(d))
+		dirs[d] = true
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: d + "/", Mode: 0755}); err != nil {
+			tb.Fatalf("write layer tar header %q: %v", d, err)
+		}
+	}
+	if root != "" {
+		writeDir(root)
+	}
+	for _, name := range names {
+		writeDir(path.Dir(name))
+		content := contents[name]
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			tb.Fatalf("write layer tar header %q: %v", name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			tb.Fatalf("write layer tar file %q: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		tb.Fatalf("close layer tar: %v", err)
+	}
+	return buf
+}
@@ -13,4 +13,5 @@ var (
 
 type ExecProcessRequest = internalshimdiag.ExecProcessRequest
 type ShareRequest = internalshimdiag.ShareRequest
+type SnapshotRequest = internalshimdiag.SnapshotRequest
 type ShimDiagService = internalshimdiag.ShimDiagService